@@ -0,0 +1,159 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/jlgallego99/TropesToGo/service/checker"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+)
+
+// pageUrlSelector finds the URL of a TvTropes page saved on disk on the attribute of the first element found,
+// or on its text if there's no attribute
+type pageUrlSelector struct {
+	selector, attribute string
+}
+
+// pageUrlSelectors find the URL of a TvTropes page saved on disk, from the most to the least reliable
+var pageUrlSelectors = []pageUrlSelector{
+	{"meta[property='og:url']", "content"},
+	{"link[rel='canonical']", "href"},
+}
+
+// checkStructureCmd represents the check-structure command
+var (
+	structureMediaInput, structureDir, structureReportPath string
+	structureSamples                                       int
+
+	checkStructureCmd = &cobra.Command{
+		Use:   "check-structure",
+		Short: "Checks whether the layout of TvTropes pages still matches the selectors of TropesToGo",
+		Long: `The check-structure command retrieves a sample of TvTropes pages of every media type given with the -m flag,
+or reads the HTML pages of the directory given with the --dir flag, and evaluates every selector of the scraper and the crawler on them.
+It prints a report of the selectors that matched nothing, the layout of the tropes of the work pages (folders, subpages or a flat list),
+the work pages that wouldn't be scraped and how the results changed since the last run, whose report is saved on the --report file.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			mediaLimits, errMediaLimits := parseMediaLimits(structureMediaInput, structureSamples, false, true)
+			if errMediaLimits != nil {
+				return errMediaLimits
+			}
+
+			cmd.SilenceUsage = true
+			ctx, stop := newSignalContext()
+			defer stop()
+
+			profile, errProfile := loadSelectors()
+			if errProfile != nil {
+				return errProfile
+			}
+
+			checkerCfgs := []checker.CheckerConfig{checker.ConfigSelectors(profile)}
+			if structureDir == "" {
+				pageFetcher, errFetcher := newFetcher()
+				if errFetcher != nil {
+					return errFetcher
+				}
+
+				checkerCfgs = append(checkerCfgs, checker.ConfigFetcher(pageFetcher))
+			}
+
+			serviceChecker, errChecker := checker.NewServiceChecker(checkerCfgs...)
+			if errChecker != nil {
+				return errChecker
+			}
+
+			if structureDir != "" {
+				if errDir := checkStructureDir(serviceChecker, structureDir, profile.Scraper.CurrentUrl); errDir != nil {
+					return errDir
+				}
+			} else if errSample := serviceChecker.CheckSample(ctx, mediaLimits); errSample != nil {
+				return ErrInterrupted
+			}
+
+			return reportStructure(serviceChecker.GetReport())
+		},
+	}
+)
+
+func init() {
+	rootCmd.AddCommand(checkStructureCmd)
+
+	checkStructureCmd.PersistentFlags().StringVarP(&structureMediaInput, "media", "m", "Film,Series,Anime,Literature,VideoGame", "media types whose pages are checked, optionally with their own number of work pages (-m <mediatype>[:<samples>],...)")
+	checkStructureCmd.PersistentFlags().IntVarP(&structureSamples, "samples", "s", 3, "number of work pages checked of every media type (-s <number>)")
+	checkStructureCmd.PersistentFlags().StringVar(&structureDir, "dir", "", "directory with the HTML pages to check instead of retrieving them from TvTropes (--dir <directory>)")
+	checkStructureCmd.PersistentFlags().StringVar(&structureReportPath, "report", "structure.json", "file where the report is saved and compared with the one of the last run (--report <file>)")
+}
+
+// checkStructureDir checks every HTML page of the dir directory, finding its URL on the page itself
+// or on the text of the currentUrlSelector of the profile
+// Pages whose URL can't be found or isn't of a known kind of page are skipped
+func checkStructureDir(serviceChecker *checker.ServiceChecker, dir, currentUrlSelector string) error {
+	pagePaths, errGlob := filepath.Glob(filepath.Join(dir, "*.html"))
+	if errGlob != nil {
+		return errGlob
+	}
+
+	if len(pagePaths) == 0 {
+		return fmt.Errorf("there are no HTML pages on the directory %s", dir)
+	}
+
+	for _, pagePath := range pagePaths {
+		pageFile, errOpen := os.Open(pagePath)
+		if errOpen != nil {
+			return errOpen
+		}
+
+		doc, errDocument := goquery.NewDocumentFromReader(pageFile)
+		pageFile.Close()
+		if errDocument != nil {
+			log.Warn().Err(errDocument).Msg("SKIPPED " + pagePath)
+			continue
+		}
+
+		pageUrl := getPageUrl(doc, currentUrlSelector)
+		if errCheck := serviceChecker.CheckPage(pageUrl, doc); errCheck != nil {
+			log.Warn().Err(errCheck).Msg("SKIPPED " + pagePath)
+		}
+	}
+
+	return nil
+}
+
+// getPageUrl finds the URL of a TvTropes page on its own document, or returns an empty string if it isn't there
+func getPageUrl(doc *goquery.Document, currentUrlSelector string) string {
+	for _, urlSelector := range append(pageUrlSelectors, pageUrlSelector{currentUrlSelector, ""}) {
+		selection := doc.Find(urlSelector.selector).First()
+		pageUrl := strings.TrimSpace(selection.Text())
+		if urlSelector.attribute != "" {
+			pageUrl, _ = selection.Attr(urlSelector.attribute)
+		}
+
+		if pageUrl != "" {
+			return strings.Replace(pageUrl, "http://", "https://", 1)
+		}
+	}
+
+	return ""
+}
+
+// reportStructure prints the report along with the changes since the report of the last run, and saves it for the next one
+func reportStructure(report *checker.Report) error {
+	previous, errPrevious := checker.LoadReport(structureReportPath)
+	if errPrevious != nil {
+		if !errors.Is(errPrevious, os.ErrNotExist) {
+			log.Warn().Err(errPrevious).Msg("Couldn't compare with the report of the last run")
+		}
+		previous = nil
+	}
+
+	if errWrite := report.WriteText(os.Stdout, previous); errWrite != nil {
+		return errWrite
+	}
+
+	return checker.SaveReport(structureReportPath, report)
+}
@@ -0,0 +1,87 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/jlgallego99/TropesToGo/media"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+)
+
+const (
+	DOT string = "DOT"
+)
+
+// graphCmd represents the graph command
+var (
+	graphWorksDataset, graphPath, graphFormat string
+
+	graphCmd = &cobra.Command{
+		Use:   "graph",
+		Short: "Exports the graph of the relationships between the works of a dataset",
+		Long: `The graph command reads the relations of every work of a works dataset, given with the -d flag,
+and exports the graph of the relationships between works, where every edge is a link from a work page to another work
+classified as a sequel, an adaptation, a franchise or a reference.
+The graph can be exported as JSON, with its nodes and edges, or in the DOT language of Graphviz.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if !strings.EqualFold(graphFormat, JSON) && !strings.EqualFold(graphFormat, DOT) {
+				return fmt.Errorf("unknown graph format: %s", graphFormat)
+			}
+
+			if _, errFileExists := os.Stat(graphWorksDataset); errFileExists != nil {
+				log.Error().Err(errFileExists).Msg("Couldn't retrieve the works dataset file " + graphWorksDataset)
+				return errFileExists
+			}
+
+			cmd.SilenceUsage = true
+
+			return exportGraph()
+		},
+	}
+)
+
+func init() {
+	rootCmd.AddCommand(graphCmd)
+
+	graphCmd.PersistentFlags().StringVarP(&graphWorksDataset, "dataset", "d", "dataset.json", "works dataset whose relations are exported, with the extension (-d <datasetfile>)")
+	graphCmd.PersistentFlags().StringVarP(&graphPath, "output", "o", "graph", "specify a name for the graph file (-o <graphname>)")
+	graphCmd.PersistentFlags().StringVarP(&graphFormat, "format", "f", "json", "specify a format for the graph file (-f json, -f dot)")
+}
+
+// exportGraph builds the graph of the relations of the works dataset and writes it on the graph file
+func exportGraph() error {
+	worksRepository, errWorks := openWorksRepository(graphWorksDataset)
+	if errWorks != nil {
+		return errWorks
+	}
+	defer worksRepository.Close()
+
+	workRelations, errRelations := worksRepository.GetWorkRelations()
+	if errRelations != nil {
+		return errRelations
+	}
+
+	graph := media.NewWorkGraph(workRelations)
+	graphPath += "." + strings.ToLower(graphFormat)
+	graphFile, errCreate := os.Create(graphPath)
+	if errCreate != nil {
+		return errCreate
+	}
+	defer graphFile.Close()
+
+	var errWrite error
+	if strings.EqualFold(graphFormat, DOT) {
+		errWrite = graph.WriteDOT(graphFile)
+	} else {
+		errWrite = graph.WriteJSON(graphFile)
+	}
+	if errWrite != nil {
+		return errWrite
+	}
+
+	log.Info().Msgf("Exported the graph of %d works and %d relations of the dataset %s on %s", len(graph.Nodes), len(graph.Edges), graphWorksDataset, graphPath)
+
+	return nil
+}
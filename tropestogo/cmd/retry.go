@@ -0,0 +1,165 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/jlgallego99/TropesToGo/media"
+	"github.com/jlgallego99/TropesToGo/media/dataset_file"
+	"github.com/jlgallego99/TropesToGo/service/crawler"
+	"github.com/jlgallego99/TropesToGo/service/outcome"
+	"github.com/jlgallego99/TropesToGo/service/scraper"
+	"github.com/jlgallego99/TropesToGo/tvtropespages"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+)
+
+// retryCmd represents the retry command
+var (
+	retryDatasetName string
+
+	retryCmd = &cobra.Command{
+		Use:   "retry",
+		Short: "Crawls and scrapes again only the pages that failed on a previous run",
+		Long: `The retry command reads the run report of a previous scrape, update or retry, given with the --report flag,
+and crawls and scrapes again only the pages that failed on it into the same dataset.
+Works that are already on the dataset are updated and the rest are added to it.
+The new outcome of every retried page replaces the old one on the same report, so the command can be run until no page fails.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if _, errFileExists := os.Stat(reportPath); errFileExists != nil {
+				log.Error().Err(errFileExists).Msg("Couldn't retrieve the run report file " + reportPath)
+				return errFileExists
+			}
+
+			cmd.SilenceUsage = true
+			ctx, stop := newSignalContext()
+			defer stop()
+
+			return retryFailedPages(ctx)
+		},
+	}
+)
+
+func init() {
+	rootCmd.AddCommand(retryCmd)
+	addIndexFlags(retryCmd)
+
+	retryCmd.PersistentFlags().StringVar(&reportPath, "report", "dataset"+reportFileSuffix, "run report of a previous run whose failed pages are retried (--report <file>)")
+	retryCmd.PersistentFlags().StringVarP(&retryDatasetName, "dataset", "d", "", "dataset where the retried works are persisted with the extension, by default the one of the report (-d <datasetfile>)")
+	retryCmd.PersistentFlags().IntVarP(&crawlWorkers, "workers", "w", 4, "number of works that are crawled at the same time (-w <number>)")
+}
+
+// retryFailedPages crawls and scrapes again the failed pages of the run report, updating the works already on the dataset and adding the rest
+// The new outcomes are added to the same report, which is saved even if the run is interrupted
+// If the ctx context is cancelled, it stops and returns an ErrInterrupted error, keeping the already persisted works
+func retryFailedPages(ctx context.Context) error {
+	start := time.Now()
+
+	report, errReport := outcome.LoadReport(reportPath, outcome.ConfigErrorClasses(outcomeErrorClasses()...))
+	if errReport != nil {
+		return errReport
+	}
+
+	if retryDatasetName == "" {
+		retryDatasetName = report.Dataset
+	}
+	report.Dataset = retryDatasetName
+
+	failedUrls := report.GetFailedUrls()
+	if len(failedUrls) == 0 {
+		log.Info().Msg("There are no failed pages on the run report " + reportPath)
+		return nil
+	}
+
+	// The dataset is locked for the whole run, so it fails right away if another process is writing it
+	datasetLock, errLock := dataset_file.AcquireLock(retryDatasetName)
+	if errLock != nil {
+		return errLock
+	}
+	defer datasetLock.Release()
+	defer saveOutcomeReport(reportPath, report)
+
+	repository, errRepository := openWorksRepository(retryDatasetName)
+	if errRepository != nil {
+		return errRepository
+	}
+
+	pages, err := retryIntoRepository(ctx, repository, report, failedUrls)
+
+	// Some datasets only write their last works when the repository is closed
+	if errClose := repository.Close(); errClose != nil {
+		return errors.Join(err, fmt.Errorf("error writing the last retried works on the dataset: %w", errClose))
+	}
+
+	log.Info().Msgf("Process finished in %s\n", time.Since(start))
+	if errors.Is(err, context.Canceled) && pages != nil {
+		log.Warn().Msgf("Retrying interrupted after persisting %d works, run the command again to continue", len(pages.Pages))
+		return ErrInterrupted
+	} else if err != nil {
+		return err
+	}
+
+	log.Info().Msg("TropesToGo finished successfully!")
+	log.Info().Msg("The TvTropes dataset is available on: " + retryDatasetName)
+
+	return nil
+}
+
+// retryIntoRepository crawls the failedUrls again, updating the works already on the repository and adding the rest,
+// and records their new outcomes on the report
+// It returns the crawled pages along with the error of the crawl, which wraps the context error if it has been interrupted
+// The repository isn't closed, so the caller can close it once and know if its last works could be written
+func retryIntoRepository(ctx context.Context, repository media.RepositoryMedia, report *outcome.Report, failedUrls []string) (*tvtropespages.TvTropesPages, error) {
+	scraperCfgs := []scraper.ScraperConfig{scraper.ConfigMediaRepository(repository), scraper.ConfigOutcomeReport(report)}
+	mapping, errMapping := loadIndexMapping(ctx)
+	if errMapping != nil {
+		return nil, errMapping
+	} else if mapping != nil {
+		scraperCfgs = append(scraperCfgs, scraper.ConfigIndexMapping(mapping))
+	}
+
+	serviceScraper, err := newScraper(scraperCfgs...)
+	if err != nil {
+		return nil, fmt.Errorf("error creating the TropesToGo scraper: %w", err)
+	}
+
+	scrapedPages, errScrapedPages := serviceScraper.GetScrapedPages()
+	if errScrapedPages != nil {
+		return nil, errScrapedPages
+	}
+	log.Info().Msgf("Retrying the %d failed pages of the run report %s...", len(failedUrls), reportPath)
+
+	// The retried works are persisted even if the run is being interrupted
+	// Works that are already on the dataset are updated, and the rest are checked before being scraped and persisted in batches like on the scrape command
+	retryWork := func(workPages *tvtropespages.TvTropesPages) error {
+		for workPage := range workPages.Pages {
+			if _, scraped := scrapedPages[workPage.GetUrl().String()]; scraped {
+				return serviceScraper.UpdateDataset(context.Background(), workPages)
+			}
+		}
+
+		if errCheck := serviceScraper.CheckTvTropesPages(workPages); errCheck != nil {
+			return errCheck
+		}
+
+		return serviceScraper.AddCheckedTvTropes(workPages)
+	}
+
+	serviceCrawler, err := newCrawler(crawler.ConfigWorkers(crawlWorkers), crawler.ConfigWorkHandler(retryWork),
+		crawler.ConfigBatchHandler(serviceScraper.PersistAdded, persistBatchSize), crawler.ConfigOutcomeReport(report))
+	if err != nil {
+		return nil, fmt.Errorf("error creating the TropesToGo crawler: %w", err)
+	}
+
+	pages, err := serviceCrawler.CrawlWorkURLs(ctx, failedUrls)
+	logFailedPages(serviceCrawler)
+
+	if err != nil && !errors.Is(err, context.Canceled) {
+		return pages, fmt.Errorf("error retrying the failed pages: %w", err)
+	}
+
+	return pages, err
+}
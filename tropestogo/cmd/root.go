@@ -1,10 +1,80 @@
 package cmd
 
 import (
+	"context"
+	"errors"
+	"github.com/jlgallego99/TropesToGo/media/csv_dataset"
+	"github.com/jlgallego99/TropesToGo/media/json_dataset"
+	"github.com/jlgallego99/TropesToGo/media/jsonl_dataset"
+	"github.com/jlgallego99/TropesToGo/media/parquet_dataset"
+	"github.com/jlgallego99/TropesToGo/media/sqlite_dataset"
+	"github.com/jlgallego99/TropesToGo/selectors"
+	"github.com/jlgallego99/TropesToGo/service/crawler"
+	"github.com/jlgallego99/TropesToGo/service/fetcher"
+	"github.com/jlgallego99/TropesToGo/service/outcome"
+	"github.com/jlgallego99/TropesToGo/service/scraper"
+	"github.com/jlgallego99/TropesToGo/trope"
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
 	"github.com/spf13/cobra"
 	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+const (
+	// exitFailure is the exit code when a command fails
+	exitFailure = 1
+
+	// exitInterrupted is the exit code when a run is stopped by a SIGINT or SIGTERM signal (128 + SIGINT)
+	exitInterrupted = 130
+
+	// reportFileSuffix is appended to the dataset name to form the default run report file name
+	reportFileSuffix = ".run.json"
+
+	// persistBatchSize is the number of scraped works that are persisted on the dataset at once, so it isn't written again for every work
+	persistBatchSize = 50
+)
+
+var (
+	ErrInterrupted  = errors.New("the run was interrupted, only the already extracted data has been persisted")
+	ErrOfflineCache = errors.New("the offline mode needs a cache directory (--cache-dir <directory>)")
+)
+
+var (
+	// maxAttempts is the maximum number of requests made to the same TvTropes page before giving up on it
+	maxAttempts int
+
+	// cacheDir is the directory where TvTropes pages are cached, or empty if they aren't cached
+	cacheDir string
+
+	// cacheMaxAge is how long a cached page is used without revalidating it with TvTropes
+	cacheMaxAge time.Duration
+
+	// offline makes all pages be extracted from the cache, without requesting TvTropes
+	offline bool
+
+	// userAgent identifies TropesToGo on every request
+	userAgent string
+
+	// browserHeaders makes the requests look like the ones of a web browser
+	browserHeaders bool
+
+	// indexesPath is the cache file of the trope index mapping, or empty if the scraped tropes aren't classified on indexes
+	indexesPath string
+
+	// indexesMaxAge is how long the cached trope index mapping is used before crawling the indexes again
+	indexesMaxAge time.Duration
+
+	// indexesDepth is how many levels of sub-indexes are crawled below each top-level trope index
+	indexesDepth int
+
+	// selectorsPath is the selector profile file with the CSS selectors and patterns for TvTropes pages, or empty for the built-in one
+	selectorsPath string
+
+	// reportPath is the file where the outcome of every page of a run is reported
+	reportPath string
 )
 
 // rootCmd represents the base command when called without any subcommands
@@ -28,10 +98,221 @@ func Execute() {
 	log.Info().Msg("TropesToGo: A scraper for TvTropes")
 
 	err := rootCmd.Execute()
-	if err != nil {
+	if errors.Is(err, ErrInterrupted) {
+		log.Warn().Err(err).Msg("TropesToGo stopped before finishing")
+		os.Exit(exitInterrupted)
+	} else if err != nil {
 		log.Error().Err(err).Msg("There was a problem on the CLI program")
+		os.Exit(exitFailure)
+	}
+}
+
+// newSignalContext returns a context that is cancelled when the process receives a SIGINT or SIGTERM signal
+// After the first signal the default behaviour is restored, so a second one terminates the process immediately
+func newSignalContext() (context.Context, context.CancelFunc) {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
+
+	return ctx, stop
+}
+
+// newCrawler creates a crawler that requests TvTropes through the fetcher of newFetcher and finds the pages with the selector profile
+// of loadSelectors, with the cfgs configurations
+func newCrawler(cfgs ...crawler.CrawlerConfig) (*crawler.ServiceCrawler, error) {
+	pageFetcher, errFetcher := newFetcher()
+	if errFetcher != nil {
+		return nil, errFetcher
+	}
+
+	profile, errProfile := loadSelectors()
+	if errProfile != nil {
+		return nil, errProfile
+	}
+
+	return crawler.NewCrawler(append([]crawler.CrawlerConfig{crawler.ConfigFetcher(pageFetcher), crawler.ConfigSelectors(profile)}, cfgs...)...)
+}
+
+// newScraper creates a scraper that extracts the data of the pages with the selector profile of loadSelectors, with the cfgs configurations
+func newScraper(cfgs ...scraper.ScraperConfig) (*scraper.ServiceScraper, error) {
+	profile, errProfile := loadSelectors()
+	if errProfile != nil {
+		return nil, errProfile
+	}
+
+	return scraper.NewServiceScraper(append([]scraper.ScraperConfig{scraper.ConfigSelectors(profile)}, cfgs...)...)
+}
+
+// loadSelectors returns the selector profile of the file of the selectorsPath flag, or the built-in one if there's no file
+func loadSelectors() (selectors.Profile, error) {
+	if selectorsPath == "" {
+		return selectors.DefaultProfile(), nil
+	}
+
+	return selectors.LoadProfile(selectorsPath)
+}
+
+// newFetcher creates a fetcher whose requests to TvTropes are retried up to the maxAttempts flag
+// The requests identify themselves with the userAgent flag, or with the headers of a browser if the browserHeaders flag is set
+// and no User-Agent has been given, and they always obey the robots.txt file of TvTropes
+// If there's a cache directory, the pages are cached on it and revalidated when they are older than the cacheMaxAge flag
+func newFetcher() (fetcher.Fetcher, error) {
+	if offline && cacheDir == "" {
+		return nil, ErrOfflineCache
+	}
+
+	var httpCfgs []fetcher.HTTPFetcherConfig
+	if browserHeaders {
+		httpCfgs = append(httpCfgs, fetcher.ConfigBrowserHeaders())
+	}
+	if !browserHeaders || rootCmd.PersistentFlags().Changed("user-agent") {
+		httpCfgs = append(httpCfgs, fetcher.ConfigUserAgent(userAgent))
 	}
+
+	httpFetcher, errFetcher := fetcher.NewHTTPFetcher(httpCfgs...)
+	if errFetcher != nil {
+		return nil, errFetcher
+	}
+
+	retryFetcher, errRetryFetcher := fetcher.NewRetryFetcher(httpFetcher, fetcher.ConfigMaxAttempts(maxAttempts))
+	if errRetryFetcher != nil {
+		return nil, errRetryFetcher
+	}
+
+	var pageFetcher fetcher.Fetcher
+	pageFetcher, errRobotsFetcher := fetcher.NewRobotsFetcher(retryFetcher, httpFetcher.GetUserAgent())
+	if errRobotsFetcher != nil {
+		return nil, errRobotsFetcher
+	}
+
+	if cacheDir != "" {
+		cacheCfgs := []fetcher.CacheFetcherConfig{fetcher.ConfigMaxAge(cacheMaxAge)}
+		if offline {
+			cacheCfgs = append(cacheCfgs, fetcher.ConfigOffline())
+		}
+
+		var errCacheFetcher error
+		pageFetcher, errCacheFetcher = fetcher.NewCacheFetcher(pageFetcher, cacheDir, cacheCfgs...)
+		if errCacheFetcher != nil {
+			return nil, errCacheFetcher
+		}
+	}
+
+	return pageFetcher, nil
+}
+
+// logFailedPages logs every Work page that the serviceCrawler couldn't crawl, so they can be requested again later
+func logFailedPages(serviceCrawler *crawler.ServiceCrawler) {
+	failedPages := serviceCrawler.GetFailedPages()
+	if len(failedPages) == 0 {
+		return
+	}
+
+	log.Warn().Msgf("%d works couldn't be crawled:", len(failedPages))
+	for failedUrl, errFailed := range failedPages {
+		log.Warn().Err(errFailed).Msg("FAILED: " + failedUrl)
+	}
+}
+
+// newOutcomeReport creates an empty outcome Report for the dataset file that classifies the errors of the crawler, the scraper and the datasets
+func newOutcomeReport(dataset string) (*outcome.Report, error) {
+	return outcome.NewReport(dataset, outcome.ConfigErrorClasses(outcomeErrorClasses()...))
+}
+
+// outcomeErrorClasses returns the classes of the errors of the crawler, the scraper and the datasets, in that order
+func outcomeErrorClasses() []outcome.ErrorClass {
+	classes := append([]outcome.ErrorClass{}, crawler.ErrorClasses...)
+	classes = append(classes, scraper.ErrorClasses...)
+
+	return append(classes,
+		outcome.ErrorClass{Name: "ErrDuplicatedMedia", Err: json_dataset.ErrDuplicatedMedia},
+		outcome.ErrorClass{Name: "ErrDuplicatedMedia", Err: csv_dataset.ErrDuplicatedMedia},
+		outcome.ErrorClass{Name: "ErrDuplicatedMedia", Err: sqlite_dataset.ErrDuplicatedMedia},
+		outcome.ErrorClass{Name: "ErrDuplicatedMedia", Err: jsonl_dataset.ErrDuplicatedMedia},
+		outcome.ErrorClass{Name: "ErrDuplicatedMedia", Err: parquet_dataset.ErrDuplicatedMedia},
+		outcome.ErrorClass{Name: "ErrPersist", Err: json_dataset.ErrPersist},
+		outcome.ErrorClass{Name: "ErrPersist", Err: csv_dataset.ErrPersist},
+		outcome.ErrorClass{Name: "ErrPersist", Err: sqlite_dataset.ErrPersist},
+		outcome.ErrorClass{Name: "ErrPersist", Err: jsonl_dataset.ErrPersist},
+		outcome.ErrorClass{Name: "ErrPersist", Err: parquet_dataset.ErrPersist},
+	)
+}
+
+// saveOutcomeReport saves the report on the file at path and logs its summary
+// A report that can't be saved doesn't make the run fail, it's only logged
+func saveOutcomeReport(path string, report *outcome.Report) {
+	summary := report.GetSummary()
+	log.Info().Msgf("%d pages processed, %d succeeded and %d failed", summary.Pages, summary.Succeeded, summary.Failed)
+	for _, stage := range outcome.Stages {
+		if summary.Stages[stage] > 0 {
+			log.Warn().Msgf("%d pages failed on the %s stage", summary.Stages[stage], stage)
+		}
+	}
+	for errorClass, failed := range summary.ErrorClasses {
+		log.Warn().Msgf("%d pages failed with %s", failed, errorClass)
+	}
+
+	if errSave := outcome.SaveReport(path, report); errSave != nil {
+		log.Error().Err(errSave).Msg("Error saving the run report")
+		return
+	}
+
+	log.Info().Msg("The run report is available on: " + path)
+}
+
+// addIndexFlags adds to cmd the flags for classifying the scraped tropes on the TvTropes indexes
+func addIndexFlags(cmd *cobra.Command) {
+	cmd.PersistentFlags().StringVar(&indexesPath, "indexes", "", "cache file of the trope index mapping, if set the tropes are classified on the TvTropes indexes (--indexes <file>)")
+	cmd.PersistentFlags().DurationVar(&indexesMaxAge, "indexes-max-age", 30*24*time.Hour, "how long the cached trope index mapping is used before crawling the indexes again (--indexes-max-age 720h)")
+	cmd.PersistentFlags().IntVar(&indexesDepth, "indexes-depth", 2, "levels of sub-indexes crawled below each top-level trope index (--indexes-depth <number>)")
+}
+
+// loadIndexMapping returns the trope index mapping of the cache file of the indexesPath flag, or nil if there's no file
+// If the file doesn't exist or is older than the indexesMaxAge flag, the TvTropes indexes are crawled again and the new mapping is cached,
+// unless some top-level index couldn't be crawled, so the incomplete mapping is only used on this run
+// If the ctx context is cancelled while crawling, it returns an ErrInterrupted error
+func loadIndexMapping(ctx context.Context) (trope.IndexMapping, error) {
+	if indexesPath == "" {
+		return nil, nil
+	}
+
+	mapping, errLoad := crawler.LoadIndexMapping(indexesPath, indexesMaxAge)
+	if errLoad == nil {
+		log.Info().Msgf("Classifying the tropes with the %d tropes of the index mapping %s", len(mapping), indexesPath)
+		return mapping, nil
+	}
+	log.Info().Msg("Crawling the TvTropes indexes for classifying the tropes: " + errLoad.Error())
+
+	serviceCrawler, errCrawler := newCrawler()
+	if errCrawler != nil {
+		return nil, errCrawler
+	}
+
+	mapping, errCrawl := serviceCrawler.CrawlTropeIndexes(ctx, indexesDepth)
+	logFailedPages(serviceCrawler)
+	if errors.Is(errCrawl, context.Canceled) {
+		return nil, ErrInterrupted
+	} else if errCrawl != nil {
+		log.Warn().Err(errCrawl).Msgf("Some trope indexes couldn't be crawled, the %d classified tropes won't be cached", len(mapping))
+		return mapping, nil
+	}
+
+	if errSave := crawler.SaveIndexMapping(indexesPath, mapping); errSave != nil {
+		log.Error().Err(errSave).Msg("Error caching the trope index mapping")
+	}
+	log.Info().Msgf("Classified %d tropes on the TvTropes indexes, cached on %s", len(mapping), indexesPath)
+
+	return mapping, nil
 }
 
 func init() {
+	rootCmd.PersistentFlags().IntVarP(&maxAttempts, "retries", "r", 4, "maximum number of requests made to the same page before it's considered failed (-r <number>)")
+	rootCmd.PersistentFlags().StringVar(&cacheDir, "cache-dir", "", "directory where the requested pages are cached, so they aren't downloaded again (--cache-dir <directory>)")
+	rootCmd.PersistentFlags().DurationVar(&cacheMaxAge, "cache-max-age", 0, "how long a cached page is used without revalidating it, by default it's always revalidated (--cache-max-age 24h)")
+	rootCmd.PersistentFlags().StringVar(&userAgent, "user-agent", fetcher.DefaultUserAgent, "User-Agent header that identifies TropesToGo and how to contact you on every request (--user-agent \"<name/version (contact)>\")")
+	rootCmd.PersistentFlags().BoolVar(&browserHeaders, "browser-headers", false, "if set, the requests are sent with the headers of a web browser instead of identifying TropesToGo")
+	rootCmd.PersistentFlags().BoolVar(&offline, "offline", false, "if set, all pages are extracted from the cache directory without requesting TvTropes")
+	rootCmd.PersistentFlags().StringVar(&selectorsPath, "selectors", "", "YAML or JSON selector profile file that patches the CSS selectors and patterns for TvTropes pages, by default the built-in ones are used (--selectors <file>)")
 }
@@ -1,12 +1,16 @@
 package cmd
 
 import (
+	"bufio"
+	"context"
+	"errors"
 	"fmt"
 	"github.com/jlgallego99/TropesToGo/media"
-	"github.com/jlgallego99/TropesToGo/media/csv_dataset"
-	"github.com/jlgallego99/TropesToGo/media/json_dataset"
+	"github.com/jlgallego99/TropesToGo/media/dataset_file"
+	"github.com/jlgallego99/TropesToGo/media/jsonl_dataset"
 	"github.com/jlgallego99/TropesToGo/service/crawler"
 	"github.com/jlgallego99/TropesToGo/service/scraper"
+	"github.com/jlgallego99/TropesToGo/tvtropespages"
 	"github.com/rs/zerolog/log"
 	"github.com/spf13/cobra"
 	"golang.org/x/text/cases"
@@ -18,17 +22,31 @@ import (
 )
 
 const (
-	CSV  string = "CSV"
-	JSON        = "JSON"
+	CSV     string = "CSV"
+	JSON           = "JSON"
+	SQLITE         = "SQLITE"
+	JSONL          = "JSONL"
+	PARQUET        = "PARQUET"
+
+	// stdoutDataset is the dataset name for streaming the dataset to the standard output, which only the JSONL format supports
+	stdoutDataset = "-"
+
+	// stdoutStateName is the name that forms the crawl state and run report file names of a dataset streamed to the standard output
+	stdoutStateName = "stdout"
+
+	// stateFileSuffix is appended to the dataset name to form the crawl state file name
+	stateFileSuffix = ".state.json"
 )
 
 // scrapeCmd represents the scrape command
 var (
 	datasetPath, _                          = os.Getwd()
 	datasetName, dataFormat, mediaTypeInput string
-	mediaType                               media.MediaType
-	crawlLimit                              int
-	crawlAll                                bool
+	workUrlsInput                           string
+	workUrls                                []string
+	mediaLimits                             []crawler.MediaLimit
+	crawlLimit, crawlWorkers                int
+	crawlAll, allMedia, resumeCrawl         bool
 
 	scrapeCmd = &cobra.Command{
 		Use:   "scrape",
@@ -37,74 +55,304 @@ var (
 of any media type with its tropes from TvTropes.
 Generates a dataset of the specified format when done.`,
 		RunE: func(cmd *cobra.Command, args []string) error {
-			if !strings.EqualFold(dataFormat, CSV) && !strings.EqualFold(dataFormat, JSON) {
+			if !strings.EqualFold(dataFormat, CSV) && !strings.EqualFold(dataFormat, JSON) && !strings.EqualFold(dataFormat, SQLITE) &&
+				!strings.EqualFold(dataFormat, JSONL) && !strings.EqualFold(dataFormat, PARQUET) {
 				return fmt.Errorf("unknown data format: %s", dataFormat)
 			}
 
-			var errMediaType error
-			caseTitle := cases.Title(language.English)
-			if mediaType, errMediaType = media.ToMediaType(caseTitle.String(mediaTypeInput)); errMediaType != nil {
-				return errMediaType
+			if datasetName == stdoutDataset && !strings.EqualFold(dataFormat, JSONL) {
+				return fmt.Errorf("only the jsonl data format can be streamed to the standard output, not %s", dataFormat)
 			}
 
 			if crawlAll {
-				log.Info().Msg("Extracting all works of type " + mediaType.String() + " in TvTropes...")
 				crawlLimit = -1
+			}
+
+			var errMediaLimits error
+			if mediaLimits, errMediaLimits = parseMediaLimits(mediaTypeInput, crawlLimit, allMedia, cmd.Flags().Changed("media")); errMediaLimits != nil {
+				return errMediaLimits
+			}
+
+			if workUrlsInput != "" {
+				var errWorkUrls error
+				if workUrls, errWorkUrls = readWorkUrls(workUrlsInput); errWorkUrls != nil {
+					return errWorkUrls
+				}
+
+				log.Info().Msg("Extracting the " + strconv.Itoa(len(workUrls)) + " listed works...")
 			} else {
-				log.Info().Msg("Extracting " + strconv.Itoa(crawlLimit) + " works of type " + mediaType.String() + "...")
+				for _, mediaLimit := range mediaLimits {
+					if mediaLimit.Limit <= 0 {
+						log.Info().Msg("Extracting all works of type " + mediaLimit.MediaType.String() + " in TvTropes...")
+					} else {
+						log.Info().Msg("Extracting " + strconv.Itoa(mediaLimit.Limit) + " works of type " + mediaLimit.MediaType.String() + "...")
+					}
+				}
 			}
 
-			scrape()
+			cmd.SilenceUsage = true
+			ctx, stop := newSignalContext()
+			defer stop()
 
-			return nil
+			return scrape(ctx)
 		},
 	}
 )
 
 func init() {
 	rootCmd.AddCommand(scrapeCmd)
+	addIndexFlags(scrapeCmd)
 
-	scrapeCmd.PersistentFlags().StringVarP(&datasetName, "output", "o", "dataset", "specify a name for the dataset (-o <datasetname>)")
-	scrapeCmd.PersistentFlags().StringVarP(&dataFormat, "format", "f", "json", "specify a format for the dataset (-f json, -f csv)")
-	scrapeCmd.PersistentFlags().IntVarP(&crawlLimit, "limit", "l", 1, "limit the number of extracted works (-l <number>)")
+	scrapeCmd.PersistentFlags().StringVarP(&datasetName, "output", "o", "dataset", "specify a name for the dataset, or - for streaming it to the standard output with the jsonl format (-o <datasetname>)")
+	scrapeCmd.PersistentFlags().StringVarP(&dataFormat, "format", "f", "json", "specify a format for the dataset, sqlite only on builds with cgo enabled (-f json, -f csv, -f sqlite, -f jsonl, -f parquet)")
+	scrapeCmd.PersistentFlags().IntVarP(&crawlLimit, "limit", "l", 1, "limit the number of extracted works of each media type (-l <number>)")
 	scrapeCmd.PersistentFlags().BoolVarP(&crawlAll, "all", "a", false, "if set, it extracts all works, on the contrary it will extract the number specified with the -l flag")
-	scrapeCmd.PersistentFlags().StringVarP(&mediaTypeInput, "media", "m", "Film", "choose the media type from which to extract the data (-m <mediatype>)")
+	scrapeCmd.PersistentFlags().StringVarP(&mediaTypeInput, "media", "m", "Film", "choose the media types from which to extract the data, optionally with their own limit (-m <mediatype>[:<limit>],...)")
+	scrapeCmd.PersistentFlags().BoolVar(&allMedia, "all-media", false, "if set, it extracts the works of every media type, with the limits of the -m flag for the media types it lists")
+	scrapeCmd.PersistentFlags().StringVar(&workUrlsInput, "urls", "", "file with the URLs of the works to extract, one per line, or - for reading them from the standard input (--urls <file>)")
+	scrapeCmd.PersistentFlags().BoolVar(&resumeCrawl, "resume", false, "if set, it resumes the stopped crawl of the dataset from its state file, without extracting its works again")
+	scrapeCmd.PersistentFlags().IntVarP(&crawlWorkers, "workers", "w", 4, "number of works that are crawled at the same time (-w <number>)")
+	scrapeCmd.PersistentFlags().StringVar(&reportPath, "report", "", "file where the outcome of every page is reported, by default the dataset name with the .run.json extension (--report <file>)")
 }
 
-func scrape() {
+// scrape crawls the requested works, scraping each of them as soon as it's crawled and persisting them on the dataset in batches
+// The crawl is checkpointed on a state file next to the dataset, so it can be resumed with the --resume flag if it stops
+// The outcome of every page is reported on the file of the --report flag, so the failed ones can be extracted again with the retry command
+// If the ctx context is cancelled, it stops and returns an ErrInterrupted error, keeping the already persisted works
+func scrape(ctx context.Context) error {
 	start := time.Now()
 
-	// Crawling TvTropes Pages
-	serviceCrawler := crawler.NewCrawler()
-	pages, err := serviceCrawler.CrawlWorkPages(crawlLimit, mediaType)
-	if err != nil && pages == nil {
-		log.Error().Err(err).Msg("Error creating TropesToGo crawler")
-		return
+	// The crawl state file of a dataset
+	stateName := datasetName
+	if datasetName == stdoutDataset {
+		stateName = stdoutStateName
+	}
+	statePath := stateName + stateFileSuffix
+	if reportPath == "" {
+		reportPath = stateName + reportFileSuffix
 	}
 
-	// Extracting data from TvTropes Pages and persisting them on a dataset file
-	var repository media.RepositoryMedia
-	if strings.EqualFold(dataFormat, CSV) {
-		repository, _ = csv_dataset.NewCSVRepository(datasetName)
-		datasetName += "." + strings.ToLower(CSV)
-	} else if strings.EqualFold(dataFormat, JSON) {
-		repository, _ = json_dataset.NewJSONRepository(datasetName)
-		datasetName += "." + strings.ToLower(JSON)
+	var frontier *crawler.Frontier
+	if resumeCrawl {
+		var errFrontier error
+		frontier, errFrontier = crawler.LoadFrontier(statePath)
+		if errFrontier != nil {
+			return errFrontier
+		}
+
+		// A resumed crawl always continues with the media types it was started with
+		if frontierMediaLimits := frontier.GetMediaLimits(); len(frontierMediaLimits) > 0 {
+			mediaLimits = frontierMediaLimits
+		} else if frontierMediaType, errMediaType := frontier.GetMediaType(); errMediaType == nil {
+			mediaLimits = []crawler.MediaLimit{{MediaType: frontierMediaType, Limit: crawlLimit}}
+		}
+		log.Info().Msgf("Resuming the crawl of works of %d media types, %d works were already extracted", len(mediaLimits), frontier.CountWorks(crawler.WorkDone))
+	} else {
+		frontier = crawler.NewFrontier(statePath)
 	}
 
-	serviceScraper, err := scraper.NewServiceScraper(scraper.ConfigMediaRepository(repository))
-	if err != nil {
-		log.Error().Err(err).Msg("Error creating TropesToGo scraper")
-		return
+	// Each work is scraped as soon as it's crawled and persisted along with its batch on the dataset file, or written on the standard output
+	datasetLocation := "the standard output"
+	if datasetName != stdoutDataset {
+		datasetName += "." + strings.ToLower(dataFormat)
+		datasetLocation = datasetPath + "/" + datasetName
+
+		// The dataset is locked for the whole run, so it fails right away if another process is writing it
+		datasetLock, errLock := dataset_file.AcquireLock(datasetName)
+		if errLock != nil {
+			return errLock
+		}
+		defer datasetLock.Release()
+	}
+
+	repository, errRepository := newScrapeRepository()
+	if errRepository != nil {
+		return errRepository
+	}
+
+	pages, err := scrapeIntoRepository(ctx, repository, frontier)
+
+	// Some datasets only write their last works when the repository is closed, so it's closed before the crawl state is removed
+	if errClose := repository.Close(); errClose != nil {
+		return errors.Join(err, fmt.Errorf("error writing the last works on the dataset, it can be resumed with the --resume flag: %w", errClose))
+	}
+
+	if errors.Is(err, context.Canceled) && pages != nil {
+		log.Warn().Msgf("Crawling interrupted after persisting %d works, it can be resumed with the --resume flag", len(pages.Pages))
+		log.Info().Msgf("Process finished in %s\n", time.Since(start))
+		log.Info().Msg("The partial TvTropes dataset is available on: " + datasetLocation)
+
+		return ErrInterrupted
+	} else if err != nil {
+		return err
 	}
 
-	errScraping := serviceScraper.ScrapeTvTropes(pages)
-	if errScraping != nil {
-		log.Error().Err(errScraping).Msg("Scraping error")
-		return
+	// The crawl has finished, so there's nothing left to resume
+	if errRemove := frontier.Remove(); errRemove != nil {
+		log.Error().Err(errRemove).Msg("Error removing the crawl state file")
 	}
 
 	log.Info().Msgf("Process finished in %s\n", time.Since(start))
 	log.Info().Msg("TropesToGo finished successfully!")
-	log.Info().Msg("The generated TvTropes dataset is available on: " + datasetPath + "/" + datasetName)
+	log.Info().Msg("The generated TvTropes dataset is available on: " + datasetLocation)
+
+	return nil
+}
+
+// newScrapeRepository creates the repository of the dataset of the scrape command with its name and data format,
+// or the repository that streams it to the standard output
+func newScrapeRepository() (media.RepositoryMedia, error) {
+	if datasetName == stdoutDataset {
+		return jsonl_dataset.NewJSONLStreamRepository(os.Stdout), nil
+	}
+
+	return openWorksRepository(datasetName)
+}
+
+// scrapeIntoRepository crawls the requested works with the frontier, scraping and persisting them on the repository
+// It returns the crawled pages along with the error of the crawl, which wraps the context error if it has been interrupted
+// The repository isn't closed, so the caller can close it once and know if its last works could be written
+func scrapeIntoRepository(ctx context.Context, repository media.RepositoryMedia, frontier *crawler.Frontier) (*tvtropespages.TvTropesPages, error) {
+	report, errReport := newOutcomeReport(datasetName)
+	if errReport != nil {
+		return nil, errReport
+	}
+	defer saveOutcomeReport(reportPath, report)
+
+	scraperCfgs := []scraper.ScraperConfig{scraper.ConfigMediaRepository(repository), scraper.ConfigOutcomeReport(report)}
+	mapping, errMapping := loadIndexMapping(ctx)
+	if errMapping != nil {
+		return nil, errMapping
+	} else if mapping != nil {
+		scraperCfgs = append(scraperCfgs, scraper.ConfigIndexMapping(mapping))
+	}
+
+	serviceScraper, err := newScraper(scraperCfgs...)
+	if err != nil {
+		return nil, fmt.Errorf("error creating the TropesToGo scraper: %w", err)
+	}
+
+	// The crawled works are scraped on the crawl workers and persisted in batches, also when the run is being interrupted
+	// Pages that aren't valid Work pages are reported as failed instead of being scraped, and each page is only checked once
+	scrapeWork := func(workPages *tvtropespages.TvTropesPages) error {
+		if errCheck := serviceScraper.CheckTvTropesPages(workPages); errCheck != nil {
+			return errCheck
+		}
+
+		return serviceScraper.AddCheckedTvTropes(workPages)
+	}
+
+	// Crawling TvTropes Pages
+	serviceCrawler, err := newCrawler(crawler.ConfigWorkers(crawlWorkers), crawler.ConfigFrontier(frontier), crawler.ConfigWorkHandler(scrapeWork),
+		crawler.ConfigBatchHandler(serviceScraper.PersistAdded, persistBatchSize), crawler.ConfigOutcomeReport(report))
+	if err != nil {
+		return nil, fmt.Errorf("error creating the TropesToGo crawler: %w", err)
+	}
+
+	var pages *tvtropespages.TvTropesPages
+	if workUrls != nil {
+		pages, err = serviceCrawler.CrawlWorkURLs(ctx, workUrls)
+	} else {
+		pages, err = serviceCrawler.CrawlMediaWorkPages(ctx, mediaLimits)
+	}
+	logFailedPages(serviceCrawler)
+
+	if err != nil && !errors.Is(err, context.Canceled) {
+		return pages, fmt.Errorf("error crawling TvTropes, it can be resumed with the --resume flag: %w", err)
+	}
+
+	return pages, err
+}
+
+// parseMediaLimits reads the comma separated list of media types of the input, each one optionally followed by a colon and its own limit,
+// and returns them in the same order with the defaultLimit for the ones without a limit
+// If allMedia is set, it returns every media type instead, with the limits of the input only if it was explicitly given
+func parseMediaLimits(input string, defaultLimit int, allMedia, inputGiven bool) ([]crawler.MediaLimit, error) {
+	caseTitle := cases.Title(language.English)
+
+	inputLimits := make([]crawler.MediaLimit, 0)
+	if !allMedia || inputGiven {
+		for _, entry := range strings.Split(input, ",") {
+			mediaTypeName, limitInput, hasLimit := strings.Cut(strings.TrimSpace(entry), ":")
+			if mediaTypeName == "" {
+				continue
+			}
+
+			mediaType, errMediaType := media.ToMediaType(caseTitle.String(mediaTypeName))
+			if errMediaType != nil {
+				return nil, errMediaType
+			}
+
+			limit := defaultLimit
+			if hasLimit {
+				var errLimit error
+				if limit, errLimit = strconv.Atoi(strings.TrimSpace(limitInput)); errLimit != nil {
+					return nil, fmt.Errorf("invalid limit for the media type %s: %s", mediaType, limitInput)
+				}
+			}
+
+			for _, inputLimit := range inputLimits {
+				if inputLimit.MediaType == mediaType {
+					return nil, fmt.Errorf("the media type %s is repeated", mediaType)
+				}
+			}
+
+			inputLimits = append(inputLimits, crawler.MediaLimit{MediaType: mediaType, Limit: limit})
+		}
+	}
+
+	if !allMedia {
+		if len(inputLimits) == 0 {
+			return nil, fmt.Errorf("%w: "+input, media.ErrUnknownMediaType)
+		}
+
+		return inputLimits, nil
+	}
+
+	allLimits := make([]crawler.MediaLimit, 0)
+	for _, mediaTypeName := range media.GetAllMediaTypes() {
+		mediaType, _ := media.ToMediaType(mediaTypeName)
+		mediaLimit := crawler.MediaLimit{MediaType: mediaType, Limit: defaultLimit}
+		for _, inputLimit := range inputLimits {
+			if inputLimit.MediaType == mediaType {
+				mediaLimit.Limit = inputLimit.Limit
+			}
+		}
+
+		allLimits = append(allLimits, mediaLimit)
+	}
+
+	return allLimits, nil
+}
+
+// readWorkUrls reads the list of Work URLs from the file at path, or from the standard input if the path is -
+// There's one URL per line, and empty lines or lines starting with # are ignored
+func readWorkUrls(path string) ([]string, error) {
+	input := os.Stdin
+	if path != "-" {
+		file, errOpen := os.Open(path)
+		if errOpen != nil {
+			return nil, fmt.Errorf("couldn't open the list of works %s: %w", path, errOpen)
+		}
+		defer file.Close()
+
+		input = file
+	}
+
+	urls := make([]string, 0)
+	scanner := bufio.NewScanner(input)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		urls = append(urls, line)
+	}
+
+	if errScan := scanner.Err(); errScan != nil {
+		return nil, fmt.Errorf("couldn't read the list of works %s: %w", path, errScan)
+	}
+
+	return urls, nil
 }
@@ -0,0 +1,171 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jlgallego99/TropesToGo/media"
+	"github.com/jlgallego99/TropesToGo/media/csv_dataset"
+	"github.com/jlgallego99/TropesToGo/media/json_dataset"
+	"github.com/jlgallego99/TropesToGo/media/jsonl_dataset"
+	"github.com/jlgallego99/TropesToGo/media/parquet_dataset"
+	"github.com/jlgallego99/TropesToGo/media/sqlite_dataset"
+	"github.com/jlgallego99/TropesToGo/service/crawler"
+	"github.com/jlgallego99/TropesToGo/service/scraper"
+	"github.com/jlgallego99/TropesToGo/trope"
+	"github.com/jlgallego99/TropesToGo/trope/catalogue_dataset"
+	"github.com/jlgallego99/TropesToGo/tvtropespages"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+)
+
+// tropesCmd represents the tropes command
+var (
+	tropesWorksDataset, cataloguePath, catalogueFormat string
+	catalogueLimit, catalogueWorkers                   int
+
+	tropesCmd = &cobra.Command{
+		Use:   "tropes",
+		Short: "Scrapes the pages of the tropes of a works dataset and generates a trope catalogue",
+		Long: `The tropes command visits the TvTropes page of every trope referenced on a works dataset,
+given with the -d flag, and generates a trope catalogue dataset with the name, the laconic description,
+the first paragraph, the supertropes and subtropes, the indexes and the number of examples of each trope.
+The catalogue can be joined with the works dataset by the trope ID.
+Tropes that are already on the catalogue are not scraped again, so a stopped run continues by running it again.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if !strings.EqualFold(catalogueFormat, CSV) && !strings.EqualFold(catalogueFormat, JSON) {
+				return fmt.Errorf("unknown data format: %s", catalogueFormat)
+			}
+
+			if _, errFileExists := os.Stat(tropesWorksDataset); errFileExists != nil {
+				log.Error().Err(errFileExists).Msg("Couldn't retrieve the works dataset file " + tropesWorksDataset)
+				return errFileExists
+			}
+
+			cmd.SilenceUsage = true
+			ctx, stop := newSignalContext()
+			defer stop()
+
+			return scrapeTropes(ctx)
+		},
+	}
+)
+
+func init() {
+	rootCmd.AddCommand(tropesCmd)
+
+	tropesCmd.PersistentFlags().StringVarP(&tropesWorksDataset, "dataset", "d", "dataset.json", "works dataset whose tropes are extracted, with the extension (-d <datasetfile>)")
+	tropesCmd.PersistentFlags().StringVarP(&cataloguePath, "output", "o", "tropes", "specify a name for the trope catalogue (-o <cataloguename>)")
+	tropesCmd.PersistentFlags().StringVarP(&catalogueFormat, "format", "f", "json", "specify a format for the trope catalogue (-f json, -f csv)")
+	tropesCmd.PersistentFlags().IntVarP(&catalogueLimit, "limit", "l", 0, "limit the number of extracted tropes, 0 for all of them (-l <number>)")
+	tropesCmd.PersistentFlags().IntVarP(&catalogueWorkers, "workers", "w", 4, "number of tropes that are crawled at the same time (-w <number>)")
+}
+
+// openWorksRepository opens the existing works dataset at datasetPath with the repository of the format of its extension
+// It returns an error if the extension isn't of a known data format
+func openWorksRepository(datasetPath string) (media.RepositoryMedia, error) {
+	worksFormat := strings.ReplaceAll(filepath.Ext(datasetPath), ".", "")
+	worksBaseName := strings.TrimSuffix(datasetPath, filepath.Ext(datasetPath))
+	if strings.EqualFold(worksFormat, CSV) {
+		return csv_dataset.NewCSVRepository(worksBaseName)
+	} else if strings.EqualFold(worksFormat, JSON) {
+		return json_dataset.NewJSONRepository(worksBaseName)
+	} else if strings.EqualFold(worksFormat, SQLITE) {
+		return sqlite_dataset.NewSQLiteRepository(worksBaseName)
+	} else if strings.EqualFold(worksFormat, JSONL) {
+		return jsonl_dataset.NewJSONLRepository(worksBaseName)
+	} else if strings.EqualFold(worksFormat, PARQUET) {
+		return parquet_dataset.NewParquetRepository(worksBaseName)
+	}
+
+	return nil, fmt.Errorf("unknown data format of the works dataset: %s", datasetPath)
+}
+
+// scrapeTropes crawls the pages of the tropes of the works dataset that aren't on the catalogue yet,
+// scraping and persisting each of them on the catalogue as soon as it's crawled
+// If the ctx context is cancelled, it stops and returns an ErrInterrupted error, keeping the already persisted tropes
+func scrapeTropes(ctx context.Context) error {
+	start := time.Now()
+
+	worksRepository, errWorks := openWorksRepository(tropesWorksDataset)
+	if errWorks != nil {
+		return errWorks
+	}
+	defer worksRepository.Close()
+
+	var catalogue trope.RepositoryCatalogue
+	var errCatalogue error
+	if strings.EqualFold(catalogueFormat, CSV) {
+		catalogue, errCatalogue = catalogue_dataset.NewCSVCatalogue(cataloguePath)
+		cataloguePath += "." + strings.ToLower(CSV)
+	} else {
+		catalogue, errCatalogue = catalogue_dataset.NewJSONCatalogue(cataloguePath)
+		cataloguePath += "." + strings.ToLower(JSON)
+	}
+	if errCatalogue != nil {
+		return errCatalogue
+	}
+	defer catalogue.Close()
+
+	datasetTropes, errTropes := worksRepository.GetTropes()
+	if errTropes != nil {
+		return errTropes
+	}
+
+	cataloguedTropes, errCatalogued := catalogue.GetTropeIDs()
+	if errCatalogued != nil {
+		return errCatalogued
+	}
+
+	tropeIds := make([]string, 0, len(datasetTropes))
+	for tropeId := range datasetTropes {
+		if _, catalogued := cataloguedTropes[tropeId]; !catalogued {
+			tropeIds = append(tropeIds, tropeId)
+		}
+	}
+	sort.Strings(tropeIds)
+
+	if catalogueLimit > 0 && len(tropeIds) > catalogueLimit {
+		tropeIds = tropeIds[:catalogueLimit]
+	}
+	log.Info().Msgf("Extracting %d tropes of the dataset %s, %d were already on the catalogue...", len(tropeIds), tropesWorksDataset, len(cataloguedTropes))
+
+	serviceScraper, err := newScraper(scraper.ConfigCatalogueRepository(catalogue))
+	if err != nil {
+		return fmt.Errorf("error creating the TropesToGo scraper: %w", err)
+	}
+
+	// The crawled tropes are persisted even if the run is being interrupted
+	scrapeTrope := func(tropePages *tvtropespages.TvTropesPages) error {
+		return serviceScraper.ScrapeTropeCatalogue(context.Background(), tropePages)
+	}
+
+	serviceCrawler, err := newCrawler(crawler.ConfigWorkers(catalogueWorkers), crawler.ConfigWorkHandler(scrapeTrope))
+	if err != nil {
+		return fmt.Errorf("error creating the TropesToGo crawler: %w", err)
+	}
+
+	pages, err := serviceCrawler.CrawlTropePages(ctx, tropeIds)
+	logFailedPages(serviceCrawler)
+	if errors.Is(err, context.Canceled) {
+		log.Warn().Msgf("Crawling interrupted after persisting %d tropes, run the command again to continue", len(pages.Pages))
+		log.Info().Msgf("Process finished in %s\n", time.Since(start))
+		log.Info().Msg("The partial trope catalogue is available on: " + cataloguePath)
+
+		return ErrInterrupted
+	} else if err != nil {
+		return fmt.Errorf("error crawling the tropes: %w", err)
+	}
+
+	log.Info().Msgf("Process finished in %s\n", time.Since(start))
+	log.Info().Msg("TropesToGo finished successfully!")
+	log.Info().Msg("The trope catalogue is available on: " + cataloguePath)
+
+	return nil
+}
@@ -1,15 +1,16 @@
 package cmd
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"os"
 	"path/filepath"
 	"strconv"
 	"strings"
 	"time"
 
-	"github.com/jlgallego99/TropesToGo/media"
-	"github.com/jlgallego99/TropesToGo/media/csv_dataset"
-	"github.com/jlgallego99/TropesToGo/media/json_dataset"
+	"github.com/jlgallego99/TropesToGo/media/dataset_file"
 	"github.com/jlgallego99/TropesToGo/service/crawler"
 	"github.com/jlgallego99/TropesToGo/service/scraper"
 	"github.com/rs/zerolog/log"
@@ -19,7 +20,7 @@ import (
 
 // updateCmd represents the update command
 var (
-	updateDatasetName, updateFileFormat string
+	updateDatasetName string
 
 	updateCmd = &cobra.Command{
 		Use:   "update",
@@ -35,62 +36,114 @@ By default, searches for a file with the name "dataset.json".`,
 				return errFileExists
 			}
 
-			scrapeUpdates()
+			cmd.SilenceUsage = true
+			ctx, stop := newSignalContext()
+			defer stop()
 
-			return nil
+			return scrapeUpdates(ctx)
 		},
 	}
 )
 
 func init() {
 	rootCmd.AddCommand(updateCmd)
+	addIndexFlags(updateCmd)
 
 	updateCmd.PersistentFlags().StringVarP(&updateDatasetName, "dataset", "d", "dataset.json", "must specify a name for the dataset to update with the extension (-d <datasetfile>)")
+	updateCmd.PersistentFlags().StringVar(&reportPath, "report", "", "file where the outcome of every page is reported, by default the dataset name with the .run.json extension (--report <file>)")
 }
 
-func scrapeUpdates() {
+// scrapeUpdates crawls the works of the dataset that have changed on TvTropes and updates them
+// The outcome of every page is reported on the file of the --report flag
+// If the ctx context is cancelled, the already crawled changes are still updated before returning an ErrInterrupted error
+func scrapeUpdates(ctx context.Context) error {
 	start := time.Now()
 
 	// Call scraper to extract the persisted changedPages on the dataset
-	var repository media.RepositoryMedia
-	updateFileFormat = strings.ReplaceAll(filepath.Ext(updateDatasetName), ".", "")
 	datasetBaseName := strings.TrimSuffix(updateDatasetName, filepath.Ext(updateDatasetName))
-	if strings.EqualFold(updateFileFormat, CSV) {
-		repository, _ = csv_dataset.NewCSVRepository(datasetBaseName)
-	} else if strings.EqualFold(updateFileFormat, JSON) {
-		repository, _ = json_dataset.NewJSONRepository(datasetBaseName)
+	repository, errRepository := openWorksRepository(updateDatasetName)
+	if errRepository != nil {
+		return errRepository
 	}
+	defer repository.Close()
 
-	serviceScraper, err := scraper.NewServiceScraper(scraper.ConfigMediaRepository(repository))
+	// The dataset is locked for the whole run, so it fails right away if another process is writing it
+	datasetLock, errLock := dataset_file.AcquireLock(updateDatasetName)
+	if errLock != nil {
+		return errLock
+	}
+	defer datasetLock.Release()
+
+	if reportPath == "" {
+		reportPath = datasetBaseName + reportFileSuffix
+	}
+
+	report, errReport := newOutcomeReport(updateDatasetName)
+	if errReport != nil {
+		return errReport
+	}
+	defer saveOutcomeReport(reportPath, report)
+
+	scraperCfgs := []scraper.ScraperConfig{scraper.ConfigMediaRepository(repository), scraper.ConfigOutcomeReport(report)}
+	mapping, errMapping := loadIndexMapping(ctx)
+	if errMapping != nil {
+		return errMapping
+	} else if mapping != nil {
+		scraperCfgs = append(scraperCfgs, scraper.ConfigIndexMapping(mapping))
+	}
+
+	serviceScraper, err := newScraper(scraperCfgs...)
 	if err != nil {
-		log.Error().Err(err).Msg("Error creating TropesToGo scraper")
-		return
+		return fmt.Errorf("error creating the TropesToGo scraper: %w", err)
 	}
 
 	pagesToBeUpdated, errScrapedPages := serviceScraper.GetScrapedPages()
 	if errScrapedPages != nil {
-		log.Error().Err(errScrapedPages).Msg("Scraping changedPages error")
-		return
+		return fmt.Errorf("error reading the pages of the dataset: %w", errScrapedPages)
 	}
 
 	// Crawling Pages with updates
-	serviceCrawler := crawler.NewCrawler()
-	changedPages, err := serviceCrawler.CrawlChanges(pagesToBeUpdated)
+	serviceCrawler, err := newCrawler(crawler.ConfigOutcomeReport(report))
+	if err != nil {
+		return fmt.Errorf("error creating the TropesToGo crawler: %w", err)
+	}
+
+	changedPages, err := serviceCrawler.CrawlChanges(ctx, pagesToBeUpdated)
+	logFailedPages(serviceCrawler)
 	if err != nil && changedPages == nil {
-		log.Error().Err(err).Msg("Error in TropesToGo crawling changes")
-		return
+		return fmt.Errorf("error crawling the changes of the dataset: %w", err)
+	}
+
+	// If the crawl has been interrupted, the already crawled changes are updated without waiting for the cancelled context
+	interrupted := errors.Is(err, context.Canceled)
+	updateCtx := ctx
+	if interrupted {
+		log.Warn().Msgf("Crawling interrupted, updating the %d already crawled works...", len(changedPages.Pages))
+		updateCtx = context.Background()
 	}
 
-	// Updating changedPages
+	// Updating changedPages, the works that can't be updated are reported and the rest are still updated
+	var errUpdate error
 	if len(changedPages.Pages) > 0 {
-		serviceScraper.UpdateDataset(changedPages)
+		errUpdate = serviceScraper.UpdateDataset(updateCtx, changedPages)
+		if errors.Is(errUpdate, context.Canceled) {
+			interrupted = true
+		}
 
-		log.Info().Msg(strconv.Itoa(len(changedPages.Pages)) + " works have been updated in the dataset " + updateDatasetName)
+		log.Info().Msg(strconv.Itoa(len(changedPages.Pages)) + " works have changed in the dataset " + updateDatasetName)
 		log.Info().Msg("The updated TvTropes dataset is available on: " + datasetPath + "service/" + updateDatasetName)
 	} else {
 		log.Info().Msg("The dataset " + updateDatasetName + " is already up to date!")
 	}
 
 	log.Info().Msgf("Process finished in %s\n", time.Since(start))
+	if interrupted {
+		return ErrInterrupted
+	} else if errUpdate != nil {
+		return fmt.Errorf("some works couldn't be updated, they can be updated again with the retry command: %w", errUpdate)
+	}
+
 	log.Info().Msg("TropesToGo finished successfully!")
+
+	return nil
 }
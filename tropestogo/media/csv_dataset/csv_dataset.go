@@ -1,12 +1,19 @@
 package csv_dataset
 
 import (
+	"bytes"
 	"encoding/csv"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"github.com/jlgallego99/TropesToGo/media"
+	"github.com/jlgallego99/TropesToGo/media/dataset_file"
+	"github.com/jlgallego99/TropesToGo/trope"
+	"io"
 	"os"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 )
 
@@ -19,22 +26,47 @@ var (
 	ErrWriteCsv        = errors.New("error writing on the CSV file")
 	ErrPersist         = errors.New("can't persist data on the CSV file because there's none")
 	ErrParseTime       = errors.New("error parsing the timestamp string from the dataset")
+	ErrHeaders         = errors.New("the CSV file doesn't have the columns of a TropesToGo dataset")
 )
 
-var Headers = []string{"title", "year", "lastupdated", "url", "mediatype", "tropes", "subtropes", "subtropes_namespaces"}
+// The tropes_indexes and subtropes_indexes columns have the indexes of each trope of the tropes and subtropes columns in the same order,
+// separated by "|", and are empty for the tropes that aren't classified
+// The tropes_examples and subtropes_examples columns are JSON arrays with the array of examples of each trope of the tropes and subtropes columns
+// in the same order, where each example is an object with its text, html, sub_items and spoilers, and the folder, header, subpage_url and position of its bullet
+// The description column has the paragraphs of the description of the work separated by new lines, and the creators, see_also and franchises
+// columns have the names of the creators, the URLs of the related works and the IDs of the franchises separated by ";"
+// The relations column is a JSON array with the links to other works, where each one is an object with its url, anchor_text, context and type
+var Headers = []string{"title", "year", "lastupdated", "url", "mediatype", "tropes", "subtropes", "subtropes_namespaces", "tropes_indexes", "subtropes_indexes",
+	"tropes_examples", "subtropes_examples", "description", "image", "image_caption", "creators", "see_also", "franchises", "relations"}
+
+// baseColumns is the number of columns of the first version of the CSV datasets, up to subtropes_namespaces
+// Later versions have only added new columns after them, so the columns of any older dataset are the first ones of the Headers
+const baseColumns = 8
 
 const timeLayout = "2006-01-02 15:04:05"
 
 // CSVRepository implements the RepositoryMedia for creating and handling CSV datasets of all the scraped data on TvTropes
+// It's safe to use by several goroutines at the same time
+// The dataset file is always written atomically, and the repository holds its lock from its first write until it's closed,
+// so no other process can write the same dataset at the same time
 type CSVRepository struct {
 	// name of the file dataset
 	name string
 
-	// writer for modifying the loaded CSV dataset that this repository manages
-	writer *csv.Writer
-
 	// data is the intermediate dataset added here before persisting it all at once
 	data []media.Media
+
+	// index holds the key of every Media object on data, for detecting the duplicated ones
+	index map[media.MediaKey]struct{}
+
+	// lock is the lock of the dataset file, held from the first write on the dataset until the repository is closed
+	lock *dataset_file.Lock
+
+	// backedUp is set when the dataset file has been backed up before the first destructive write of the repository
+	backedUp bool
+
+	// mutex guards the intermediate dataset and the dataset file
+	mutex sync.Mutex
 }
 
 // Error formats a generic error
@@ -48,116 +80,250 @@ func Error(message string, err error, subErr error) error {
 
 // NewCSVRepository is the constructor for CSVRepository objects that handle CSV datasets
 // It receives the name that the CSV dataset file will have and creates and empty file with only the column headers
-// It will return an ErrCreateCsv error if the file couldn't be created
+// If the file already exists with the columns of an older version, it's backed up and migrated to the current columns
+// It will return an ErrCreateCsv error if the file couldn't be created, or if another process is writing it,
+// and an ErrOpenCsv error if the existing file couldn't be migrated or has columns that aren't of any version of the dataset
 func NewCSVRepository(name string) (*CSVRepository, error) {
+	repository := &CSVRepository{
+		name:  name + ".csv",
+		index: make(map[media.MediaKey]struct{}),
+	}
+
 	// If the file doesn't exist, create it
-	var csvFile *os.File
-	var writer *csv.Writer
-	if _, errStat := os.Stat(name + ".csv"); errStat != nil {
-		var errCreate error
-		csvFile, errCreate = os.Create(name + ".csv")
-		if errCreate != nil {
+	if _, errStat := os.Stat(repository.name); errStat != nil {
+		if errLock := repository.lockDataset(); errLock != nil {
+			return nil, Error(name, ErrCreateCsv, errLock)
+		}
+
+		if errCreate := repository.writeRecords([][]string{Headers}); errCreate != nil {
+			repository.Close()
 			return nil, Error(name, ErrCreateCsv, errCreate)
 		}
+	} else if errMigrate := repository.migrateDataset(); errMigrate != nil {
+		repository.Close()
+		return nil, Error(name, ErrOpenCsv, errMigrate)
+	}
 
-		writer = csv.NewWriter(csvFile)
-		writer.Write(Headers)
-		writer.Flush()
-	} else {
-		csvFile, _ = os.Open(name + ".csv")
-		writer = csv.NewWriter(csvFile)
+	return repository, nil
+}
+
+// migrateDataset rewrites the dataset file with the current Headers if it has the columns of an older version of the dataset,
+// filling the new columns of every record with the values of a Work without that data
+// Records persisted after the columns changed may already have all of them, so each record is migrated on its own
+// The dataset file is backed up before migrating it, and it does nothing if it already has the current columns
+// The file is read again holding its lock before migrating it, so the records that another process was writing meanwhile aren't lost,
+// while a file that doesn't need to be migrated can be read even if another process is writing it
+// It returns an ErrHeaders error if the file or any of its records has columns that aren't of any version of the dataset,
+// or an ErrReadCsv or ErrWriteCsv error if it couldn't be read, backed up or written
+func (repository *CSVRepository) migrateDataset() error {
+	records, migrated, errMigrate := repository.readMigratedRecords()
+	if errMigrate != nil || !migrated {
+		return errMigrate
 	}
 
-	repository := &CSVRepository{
-		name:   name + ".csv",
-		writer: writer,
+	if errLock := repository.lockDataset(); errLock != nil {
+		return errLock
 	}
 
-	return repository, nil
+	records, migrated, errMigrate = repository.readMigratedRecords()
+	if errMigrate != nil || !migrated {
+		return errMigrate
+	}
+
+	if errBackup := repository.backupDataset(); errBackup != nil {
+		return errBackup
+	}
+
+	return repository.writeRecords(records)
+}
+
+// readMigratedRecords reads every record of the dataset file, with its headers, and migrates the ones with the columns of an older version
+// It returns whether any of them was migrated, an ErrHeaders error if the file or any of its records has columns
+// that aren't of any version of the dataset, or an ErrReadCsv error if it couldn't be read
+func (repository *CSVRepository) readMigratedRecords() ([][]string, bool, error) {
+	dataset, errOpen := os.Open(repository.name)
+	if errOpen != nil {
+		return nil, false, Error(repository.name, ErrReadCsv, errOpen)
+	}
+
+	reader := csv.NewReader(dataset)
+	reader.FieldsPerRecord = -1
+	records, errReadAll := reader.ReadAll()
+	dataset.Close()
+	if errReadAll != nil {
+		return nil, false, Error(repository.name, ErrReadCsv, errReadAll)
+	}
+
+	// An empty file only needs the headers
+	migrated := len(records) == 0
+	if migrated {
+		records = [][]string{Headers}
+	}
+
+	headers := records[0]
+	if !isVersionHeaders(headers) {
+		return nil, false, Error(repository.name+" has the columns "+strings.Join(headers, ","), ErrHeaders, nil)
+	}
+
+	migrated = migrated || len(headers) != len(Headers)
+	records[0] = Headers
+	for pos, record := range records[1:] {
+		if len(record) < baseColumns || len(record) > len(Headers) {
+			return nil, false, Error(repository.name+" record "+strconv.Itoa(pos+1)+" has "+strconv.Itoa(len(record))+" columns", ErrHeaders, nil)
+		}
+
+		if len(record) != len(Headers) {
+			records[pos+1] = migrateRecord(record)
+			migrated = true
+		}
+	}
+
+	return records, migrated, nil
+}
+
+// isVersionHeaders checks if the headers are the columns of a version of the dataset, which are the first ones of the Headers
+func isVersionHeaders(headers []string) bool {
+	if len(headers) < baseColumns || len(headers) > len(Headers) {
+		return false
+	}
+
+	for column, header := range headers {
+		if header != Headers[column] {
+			return false
+		}
+	}
+
+	return true
+}
+
+// migrateRecord returns a record with the columns of an older version of the dataset with all the current Headers,
+// filling the new columns with the values of a Work without that data, so it can be parsed like a current record
+// Every trope has its own empty list of indexes, and the examples and relations columns are empty JSON arrays
+func migrateRecord(record []string) []string {
+	if len(record) >= len(Headers) {
+		return record
+	}
+
+	migrated := make([]string, len(Headers))
+	copy(migrated, record)
+	for column := len(record); column < len(Headers); column++ {
+		switch Headers[column] {
+		case "tropes_indexes":
+			migrated[column] = emptyIndexes(record[5])
+		case "subtropes_indexes":
+			migrated[column] = emptyIndexes(record[6])
+		case "tropes_examples", "subtropes_examples", "relations":
+			migrated[column] = "[]"
+		}
+	}
+
+	return migrated
+}
+
+// emptyIndexes returns the indexes column of the tropes of the titles column when none of them is classified
+func emptyIndexes(titles string) string {
+	numTitles := len(splitColumn(titles))
+	if numTitles <= 1 {
+		return ""
+	}
+
+	return strings.Repeat(";", numTitles-1)
 }
 
 // GetReader returns a new CSV reader object starting from the top of the file
+// The file is read into memory and closed right away, so the reader doesn't need to be closed
 // If the dataset file doesn't exist, it returns an ErrOpenCsv error
+//
+// Deprecated: the records are read as Media objects with GetMedia, GetMediaByURL or ForEachMedia, which parse and validate them
 func (repository *CSVRepository) GetReader() (*csv.Reader, error) {
-	dataset, err := os.Open(repository.name)
+	dataset, err := os.ReadFile(repository.name)
 	if err != nil {
 		return nil, Error(repository.name, ErrOpenCsv, err)
 	}
 
-	reader := csv.NewReader(dataset)
-	return reader, nil
+	return csv.NewReader(bytes.NewReader(dataset)), nil
 }
 
 // AddMedia adds a newMedia Media object to the in-memory dataset, so it can be later persisted
 // There can only be unique objects on the dataset, so it will return an ErrDuplicatedMedia error if the Media object already exists
+// Works with the same title and year but different media types are different Media objects
 func (repository *CSVRepository) AddMedia(newMedia media.Media) error {
-	for _, mediaData := range repository.data {
-		if mediaData.GetWork().Title == newMedia.GetWork().Title && mediaData.GetWork().Year == newMedia.GetWork().Year {
-			return Error("Title: "+newMedia.GetWork().Title, ErrDuplicatedMedia, nil)
-		}
+	repository.mutex.Lock()
+	defer repository.mutex.Unlock()
+
+	if _, exists := repository.index[newMedia.GetKey()]; exists {
+		return Error("Title: "+newMedia.GetWork().Title, ErrDuplicatedMedia, nil)
 	}
 
+	repository.index[newMedia.GetKey()] = struct{}{}
 	repository.data = append(repository.data, newMedia)
 
 	return nil
 }
 
-// UpdateMedia updates a media record already written on the dataset by checking if it has the same title and year, because that differentiates a record
-// It returns an ErrReadCsv or ErrWriteCsv error if the dataset file couldn't be read or written
-func (repository *CSVRepository) UpdateMedia(title string, year string, media media.Media) error {
-	reader, errReader := repository.GetReader()
-	if errReader != nil {
-		return Error(repository.name, ErrReadCsv, errReader)
+// UpdateMedia updates a media record already written on the dataset by checking if it has the same title and year and the media type of updateMedia, because that differentiates a record
+// Every record is parsed back into a Media object, so the dataset is written again from them with updateMedia on the updated one
+// The dataset file is backed up before the first update of the repository
+// It returns a media.ErrMediaNotFound error without writing the dataset if there's no such record,
+// or an ErrReadCsv or ErrWriteCsv error if the dataset file couldn't be read, backed up or written, or if any of the records isn't a valid Media
+func (repository *CSVRepository) UpdateMedia(title string, year string, updateMedia media.Media) error {
+	repository.mutex.Lock()
+	defer repository.mutex.Unlock()
+
+	if errLock := repository.lockDataset(); errLock != nil {
+		return errLock
 	}
 
-	records, errReadAll := reader.ReadAll()
-	if errReadAll != nil {
-		return Error(repository.name, ErrReadCsv, errReadAll)
+	datasetMedia, errMedia := repository.readMedia()
+	if errMedia != nil {
+		return errMedia
 	}
 
-	updateLine := -1
-	for pos, record := range records {
-		if record[0] == title && record[1] == year {
-			updateLine = pos
-			break
+	updatedRecords := [][]string{Headers}
+	updated := false
+	for _, recordMedia := range datasetMedia {
+		if !updated && recordMedia.GetKey() == media.NewMediaKey(title, year, updateMedia.GetMediaType().String()) {
+			recordMedia = updateMedia
+			updated = true
 		}
-	}
 
-	input, _ := os.ReadFile(repository.name)
-	lines := strings.Split(string(input), "\n")
-	for linePos := range lines {
-		if linePos == updateLine {
-			updatedRecord := CreateMediaRecord(media)
-			lines[linePos] = strings.Join(updatedRecord, ",")
+		updatedRecords = append(updatedRecords, CreateMediaRecord(recordMedia))
+	}
 
-			break
-		}
+	if !updated {
+		return Error("Title: "+title+" Year: "+year, media.ErrMediaNotFound, nil)
 	}
-	output := strings.Join(lines, "\n")
-	errWrite := os.WriteFile(repository.name, []byte(output), 0644)
-	if errWrite != nil {
-		return Error(repository.name, errWrite, nil)
+
+	if errBackup := repository.backupDataset(); errBackup != nil {
+		return errBackup
 	}
 
-	return nil
+	return repository.writeRecords(updatedRecords)
 }
 
 // RemoveAll deletes all data on both the in-memory intermediate data and on the dataset file
-// It tries to recreate the dataset, so it will return an ErrCreateCsv error if that wasn't possible
+// The dataset file is backed up before emptying it for the first time, and then it's recreated with only the column headers,
+// so it will return an ErrCreateCsv error if that wasn't possible
 // If the dataset file doesn't exist, it returns an ErrFileNotExists error
 func (repository *CSVRepository) RemoveAll() error {
+	repository.mutex.Lock()
+	defer repository.mutex.Unlock()
+
 	repository.data = []media.Media{}
+	repository.index = make(map[media.MediaKey]struct{})
 
 	if _, err := os.Stat(repository.name); err == nil {
-		csvFile, errRemove := os.Create(repository.name)
-		if errRemove != nil {
-			return Error(repository.name, errRemove, nil)
+		if errLock := repository.lockDataset(); errLock != nil {
+			return errLock
 		}
 
-		repository.writer = csv.NewWriter(csvFile)
+		if errBackup := repository.backupDataset(); errBackup != nil {
+			return errBackup
+		}
 
-		repository.writer.Write(Headers)
-		repository.writer.Flush()
+		if errCreate := repository.writeRecords([][]string{Headers}); errCreate != nil {
+			return Error(repository.name, ErrCreateCsv, errCreate)
+		}
 
 		return nil
 	} else {
@@ -170,99 +336,175 @@ func (repository *CSVRepository) RemoveAll() error {
 // Persist writes all intermediate Media data into the proper dataset file and empties the structure, because it has already been persisted
 // It checks whether the new records are already on the dataset file, but doesn't return an error, but simply skips it
 // If the internal data structure is empty, it will do nothing and return an ErrPersist error
+// The new records are appended to the ones on the dataset file, which is written again with all of them
 // It returns an ErrReadCsv or ErrWriteCsv error if the dataset file couldn't be read or written
 func (repository *CSVRepository) Persist() error {
+	repository.mutex.Lock()
+	defer repository.mutex.Unlock()
+
 	if len(repository.data) == 0 {
 		return Error(repository.name, ErrPersist, nil)
 	}
 
-	reader, errReader := repository.GetReader()
-	if errReader != nil {
-		return Error(repository.name, ErrReadCsv, errReader)
+	if errLock := repository.lockDataset(); errLock != nil {
+		return errLock
 	}
 
-	records, errReadAll := reader.ReadAll()
-	if errReadAll != nil {
-		return Error(repository.name, ErrReadCsv, errReadAll)
+	datasetRecords, errRecords := repository.readRecords()
+	if errRecords != nil {
+		return errRecords
 	}
 
+	datasetIndex := make(map[media.MediaKey]struct{}, len(datasetRecords))
+	for _, record := range datasetRecords {
+		datasetIndex[media.NewMediaKey(record[0], record[1], record[4])] = struct{}{}
+	}
+
+	records := append([][]string{Headers}, datasetRecords...)
 	for _, mediaData := range repository.data {
-		exists := false
-		for _, record := range records {
-			if record[0] == mediaData.GetWork().Title && record[1] == mediaData.GetWork().Year {
-				exists = true
-				break
-			}
+		if _, exists := datasetIndex[mediaData.GetKey()]; !exists {
+			records = append(records, CreateMediaRecord(mediaData))
 		}
+	}
+
+	repository.data = []media.Media{}
+	repository.index = make(map[media.MediaKey]struct{})
 
-		if !exists {
-			record := CreateMediaRecord(mediaData)
+	return repository.writeRecords(records)
+}
 
-			err := repository.writer.Write(record)
-			if err != nil {
-				return Error(repository.name, ErrWriteCsv, err)
-			}
-			repository.writer.Flush()
-		}
+// writeRecords replaces the dataset file with the records, which must start with the column headers
+// The records are written with a CSV writer, so their fields are properly quoted
+// It returns an ErrWriteCsv error if the dataset file couldn't be written
+func (repository *CSVRepository) writeRecords(records [][]string) error {
+	errReplace := dataset_file.Replace(repository.name, func(datasetFile io.Writer) error {
+		return csv.NewWriter(datasetFile).WriteAll(records)
+	})
+	if errReplace != nil {
+		return Error(repository.name, ErrWriteCsv, errReplace)
 	}
 
-	repository.data = []media.Media{}
+	return nil
+}
+
+// Close releases the lock of the dataset file if the repository holds it, so other processes can write it
+// The repository can still be used after closing it, and it will acquire the lock again on its next write
+func (repository *CSVRepository) Close() error {
+	repository.mutex.Lock()
+	defer repository.mutex.Unlock()
+
+	if repository.lock == nil {
+		return nil
+	}
 
+	errRelease := repository.lock.Release()
+	repository.lock = nil
+
+	return errRelease
+}
+
+// lockDataset acquires the lock of the dataset file if the repository doesn't hold it yet, and holds it until the repository is closed
+// The mutex must be held, and it returns an ErrWriteCsv error with dataset_file.ErrLocked if another process is writing the dataset
+func (repository *CSVRepository) lockDataset() error {
+	if repository.lock != nil {
+		return nil
+	}
+
+	lock, errLock := dataset_file.AcquireLock(repository.name)
+	if errLock != nil {
+		return Error(repository.name, ErrWriteCsv, errLock)
+	}
+
+	repository.lock = lock
+	return nil
+}
+
+// backupDataset backs up the dataset file before the first destructive write of the repository, so it's only backed up once
+// even if many records are updated. The mutex must be held, and it returns an ErrWriteCsv error if it couldn't be backed up
+func (repository *CSVRepository) backupDataset() error {
+	if repository.backedUp {
+		return nil
+	}
+
+	if errBackup := dataset_file.Backup(repository.name); errBackup != nil {
+		return Error(repository.name, ErrWriteCsv, errBackup)
+	}
+
+	repository.backedUp = true
 	return nil
 }
 
 // CreateMediaRecord forms a proper string record from a Media object for inserting in a CSV file
 // Each value on the returned array is a column value for the CSV file
-func CreateMediaRecord(media media.Media) []string {
+func CreateMediaRecord(mediaData media.Media) []string {
 	var tropes []string
 	var subTropes []string
 	var subTropesNamespaces []string
-	//var indexes []string
+	var tropesIndexes []string
+	var subTropesIndexes []string
+	tropesExamples := make([][]media.JsonExample, 0)
+	subTropesExamples := make([][]media.JsonExample, 0)
+	metadata := mediaData.GetMetadata()
 
-	for trope := range media.GetWork().Tropes {
+	for trope := range mediaData.GetWork().Tropes {
 		title := trope.GetTitle()
-		index := trope.GetIndex().String()
 
-		if title != "" && index != "" /*&& index != "UnknownTropeIndex"*/ {
+		if title != "" {
 			tropes = append(tropes, title)
-			//indexes = append(indexes, index)
+			tropesIndexes = append(tropesIndexes, strings.Join(trope.GetIndexStrings(), "|"))
+			tropesExamples = append(tropesExamples, media.GetJsonExamples(mediaData.GetExamples(trope)))
 		}
 	}
 
-	for subTrope := range media.GetWork().SubTropes {
+	for subTrope := range mediaData.GetWork().SubTropes {
 		title := subTrope.GetTitle()
 		namespace := subTrope.GetSubpage()
 
 		if title != "" && namespace != "" {
 			subTropes = append(subTropes, title)
 			subTropesNamespaces = append(subTropesNamespaces, namespace)
+			subTropesIndexes = append(subTropesIndexes, strings.Join(subTrope.GetIndexStrings(), "|"))
+			subTropesExamples = append(subTropesExamples, media.GetJsonExamples(mediaData.GetExamples(subTrope)))
 		}
 	}
 
-	record := []string{media.GetWork().Title, media.GetWork().Year, media.GetWork().LastUpdated.Format(timeLayout),
-		media.GetPage().GetUrl().String(), media.GetMediaType().String(), strings.Join(tropes, ";"),
-		strings.Join(subTropes, ";"), strings.Join(subTropesNamespaces, ";")}
+	record := []string{mediaData.GetWork().Title, mediaData.GetWork().Year, mediaData.GetWork().LastUpdated.Format(timeLayout),
+		mediaData.GetPage().GetUrl().String(), mediaData.GetMediaType().String(), strings.Join(tropes, ";"),
+		strings.Join(subTropes, ";"), strings.Join(subTropesNamespaces, ";"), strings.Join(tropesIndexes, ";"),
+		strings.Join(subTropesIndexes, ";"), marshalExamples(tropesExamples), marshalExamples(subTropesExamples),
+		strings.Join(metadata.Description, "\n"), metadata.Image, metadata.ImageCaption, strings.Join(metadata.Creators, ";"),
+		strings.Join(metadata.SeeAlso, ";"), strings.Join(metadata.Franchises, ";"), marshalRelations(mediaData.GetRelations())}
 
 	return record
 }
 
+// marshalExamples encodes the examples of each trope as a JSON array for a single CSV column
+func marshalExamples(examples [][]media.JsonExample) string {
+	examplesBytes, _ := json.Marshal(examples)
+
+	return string(examplesBytes)
+}
+
+// marshalRelations encodes the relations of a work as a JSON array for a single CSV column
+func marshalRelations(relations []trope.Relation) string {
+	relationsBytes, _ := json.Marshal(media.GetJsonRelations(relations))
+
+	return string(relationsBytes)
+}
+
 // GetWorkPages retrieves all persisted Work urls on the CSV dataset and the last time they were updated
 // Returns a map relating page URLs to the last time they were updated
 func (repository *CSVRepository) GetWorkPages() (map[string]time.Time, error) {
-	datasetPages := make(map[string]time.Time, 0)
+	repository.mutex.Lock()
+	defer repository.mutex.Unlock()
 
-	reader, errReader := repository.GetReader()
-	if errReader != nil {
-		return nil, Error(repository.name, ErrReadCsv, errReader)
-	}
+	datasetPages := make(map[string]time.Time, 0)
 
-	records, errReadAll := reader.ReadAll()
-	if errReadAll != nil {
-		return nil, Error(repository.name, ErrReadCsv, errReadAll)
+	records, errRecords := repository.readRecords()
+	if errRecords != nil {
+		return nil, errRecords
 	}
 
-	// Only iterate from the second row onwards (ignoring the first row, the headers)
-	records = append(records[:0], records[1:]...)
 	for _, record := range records {
 		lastUpdated, errLastUpdated := time.Parse(timeLayout, record[2])
 		if errLastUpdated != nil {
@@ -274,3 +516,324 @@ func (repository *CSVRepository) GetWorkPages() (map[string]time.Time, error) {
 
 	return datasetPages, nil
 }
+
+// GetTropes retrieves the title of every trope and subtrope of the Works persisted on the CSV dataset
+// Returns a set of the trope titles, which are the IDs of their Main pages
+func (repository *CSVRepository) GetTropes() (map[string]struct{}, error) {
+	repository.mutex.Lock()
+	defer repository.mutex.Unlock()
+
+	datasetTropes := make(map[string]struct{})
+
+	records, errRecords := repository.readRecords()
+	if errRecords != nil {
+		return nil, errRecords
+	}
+
+	for _, record := range records {
+		for _, title := range strings.Split(record[5]+";"+record[6], ";") {
+			if title != "" {
+				datasetTropes[title] = struct{}{}
+			}
+		}
+	}
+
+	return datasetTropes, nil
+}
+
+// GetWorkRelations retrieves the relations with other Works of every Work persisted on the CSV dataset
+// Records of datasets without the relations column have no relations
+// Returns a map relating the URL of each Work to its relations
+func (repository *CSVRepository) GetWorkRelations() (map[string][]trope.Relation, error) {
+	repository.mutex.Lock()
+	defer repository.mutex.Unlock()
+
+	datasetRelations := make(map[string][]trope.Relation)
+
+	records, errRecords := repository.readRecords()
+	if errRecords != nil {
+		return nil, errRecords
+	}
+
+	relationsColumn := len(Headers) - 1
+	for _, record := range records {
+		if len(record) <= relationsColumn {
+			datasetRelations[record[3]] = nil
+			continue
+		}
+
+		var jsonRelations []media.JsonRelation
+		if errUnmarshal := json.Unmarshal([]byte(record[relationsColumn]), &jsonRelations); errUnmarshal != nil {
+			return nil, Error(repository.name, ErrReadCsv, errUnmarshal)
+		}
+
+		relations, errRelations := media.ToRelations(jsonRelations)
+		if errRelations != nil {
+			return nil, Error(repository.name, ErrReadCsv, errRelations)
+		}
+
+		datasetRelations[record[3]] = relations
+	}
+
+	return datasetRelations, nil
+}
+
+// GetMediaByURL retrieves the Media of the Work page with the url persisted on the CSV dataset
+// It returns a media.ErrMediaNotFound error if there's none, or an ErrReadCsv error if the dataset couldn't be read
+func (repository *CSVRepository) GetMediaByURL(url string) (media.Media, error) {
+	repository.mutex.Lock()
+	defer repository.mutex.Unlock()
+
+	datasetMedia, errMedia := repository.readMedia()
+	if errMedia != nil {
+		return media.Media{}, errMedia
+	}
+
+	for _, recordMedia := range datasetMedia {
+		if recordMedia.GetPage().GetUrl().String() == url {
+			return recordMedia, nil
+		}
+	}
+
+	return media.Media{}, Error(url, media.ErrMediaNotFound, nil)
+}
+
+// GetMedia retrieves the Media with the title and year persisted on the CSV dataset
+// It returns a media.ErrMediaNotFound error if there's none, or an ErrReadCsv error if the dataset couldn't be read
+func (repository *CSVRepository) GetMedia(title, year string) (media.Media, error) {
+	repository.mutex.Lock()
+	defer repository.mutex.Unlock()
+
+	datasetMedia, errMedia := repository.readMedia()
+	if errMedia != nil {
+		return media.Media{}, errMedia
+	}
+
+	for _, recordMedia := range datasetMedia {
+		if recordMedia.GetWork().Title == title && recordMedia.GetWork().Year == year {
+			return recordMedia, nil
+		}
+	}
+
+	return media.Media{}, Error("Title: "+title+" Year: "+year, media.ErrMediaNotFound, nil)
+}
+
+// ForEachMedia calls handleMedia with every Media persisted on the CSV dataset, in the order of its rows
+// It stops at the first error handleMedia returns and returns it, or returns an ErrReadCsv error if the dataset couldn't be read
+func (repository *CSVRepository) ForEachMedia(handleMedia func(media.Media) error) error {
+	repository.mutex.Lock()
+	datasetMedia, errMedia := repository.readMedia()
+	repository.mutex.Unlock()
+
+	if errMedia != nil {
+		return errMedia
+	}
+
+	for _, recordMedia := range datasetMedia {
+		if errHandle := handleMedia(recordMedia); errHandle != nil {
+			return errHandle
+		}
+	}
+
+	return nil
+}
+
+// GetMediaWithTrope retrieves every Media persisted on the CSV dataset that has the trope with the title as a trope or a subtrope
+// It returns an ErrReadCsv error if the dataset couldn't be read
+func (repository *CSVRepository) GetMediaWithTrope(title string) ([]media.Media, error) {
+	repository.mutex.Lock()
+	defer repository.mutex.Unlock()
+
+	datasetMedia, errMedia := repository.readMedia()
+	if errMedia != nil {
+		return nil, errMedia
+	}
+
+	mediaWithTrope := make([]media.Media, 0)
+	for _, recordMedia := range datasetMedia {
+		if recordMedia.HasTrope(title) {
+			mediaWithTrope = append(mediaWithTrope, recordMedia)
+		}
+	}
+
+	return mediaWithTrope, nil
+}
+
+// CountMedia returns the number of Media records persisted on the CSV dataset, without its headers
+// It returns an ErrReadCsv error if the dataset couldn't be read
+func (repository *CSVRepository) CountMedia() (int, error) {
+	repository.mutex.Lock()
+	defer repository.mutex.Unlock()
+
+	records, errRecords := repository.readRecords()
+	if errRecords != nil {
+		return 0, errRecords
+	}
+
+	return len(records), nil
+}
+
+// readMedia reads every record persisted on the CSV dataset and parses it into a Media object, in the order of its rows
+// It returns an ErrReadCsv error if the file couldn't be read, or with the position of the first record that isn't a valid Media
+func (repository *CSVRepository) readMedia() ([]media.Media, error) {
+	records, errRecords := repository.readRecords()
+	if errRecords != nil {
+		return nil, errRecords
+	}
+
+	datasetMedia := make([]media.Media, 0, len(records))
+	for pos, record := range records {
+		recordMedia, errMedia := repository.toMedia(record, pos)
+		if errMedia != nil {
+			return nil, errMedia
+		}
+
+		datasetMedia = append(datasetMedia, recordMedia)
+	}
+
+	return datasetMedia, nil
+}
+
+// readRecords reads every record persisted on the CSV dataset, without the headers
+// It returns an ErrReadCsv error if the file couldn't be read
+func (repository *CSVRepository) readRecords() ([][]string, error) {
+	dataset, errOpen := os.Open(repository.name)
+	if errOpen != nil {
+		return nil, Error(repository.name, ErrReadCsv, errOpen)
+	}
+	defer dataset.Close()
+
+	records, errReadAll := csv.NewReader(dataset).ReadAll()
+	if errReadAll != nil {
+		return nil, Error(repository.name, ErrReadCsv, errReadAll)
+	}
+
+	// The first row has the headers
+	if len(records) == 0 {
+		return records, nil
+	}
+
+	return records[1:], nil
+}
+
+// toMedia transforms the record on the pos position of the CSV dataset, without counting the headers, back into a Media object
+// It returns an ErrReadCsv error with the position of the record if it isn't a valid Media
+func (repository *CSVRepository) toMedia(record []string, pos int) (media.Media, error) {
+	recordMedia, errMedia := ParseMediaRecord(record)
+	if errMedia != nil {
+		return media.Media{}, Error(repository.name+" record "+strconv.Itoa(pos+1), ErrReadCsv, errMedia)
+	}
+
+	return recordMedia, nil
+}
+
+// ParseMediaRecord forms a Media object from a record of a CSV file, reversing CreateMediaRecord
+// Records with the columns of an older version of the dataset are parsed as Works without the data of the missing columns
+// The tropes, subtropes, namespaces, indexes and examples columns must have the same number of elements
+// It returns a media.ErrInvalidRecord error with the reason if the record doesn't have the columns of any version or any of them isn't valid
+func ParseMediaRecord(record []string) (media.Media, error) {
+	if len(record) < baseColumns {
+		return media.Media{}, fmt.Errorf("%w: the record has "+strconv.Itoa(len(record))+" columns instead of at least "+strconv.Itoa(baseColumns), media.ErrInvalidRecord)
+	}
+	record = migrateRecord(record)
+
+	tropes, errTropes := parseTropes(splitColumn(record[5]), nil, record[8], record[10])
+	if errTropes != nil {
+		return media.Media{}, fmt.Errorf("%w: "+record[0]+"\n%w", media.ErrInvalidRecord, errTropes)
+	}
+
+	subTropes, errSubTropes := parseTropes(splitColumn(record[6]), strings.Split(record[7], ";"), record[9], record[11])
+	if errSubTropes != nil {
+		return media.Media{}, fmt.Errorf("%w: "+record[0]+"\n%w", media.ErrInvalidRecord, errSubTropes)
+	}
+
+	var relations []media.JsonRelation
+	if errRelations := json.Unmarshal([]byte(record[18]), &relations); errRelations != nil {
+		return media.Media{}, fmt.Errorf("%w: "+record[0]+"\n%w", media.ErrInvalidRecord, errRelations)
+	}
+
+	var description []string
+	if record[12] != "" {
+		description = strings.Split(record[12], "\n")
+	}
+
+	return media.ToMedia(media.JsonResponse{
+		Title:       record[0],
+		Year:        record[1],
+		MediaType:   record[4],
+		LastUpdated: record[2],
+		URL:         record[3],
+		Tropes:      tropes,
+		SubTropes:   subTropes,
+		Metadata: media.JsonMetadata{
+			Description:  description,
+			Image:        record[13],
+			ImageCaption: record[14],
+			Creators:     splitColumn(record[15]),
+			SeeAlso:      splitColumn(record[16]),
+			Franchises:   splitColumn(record[17]),
+		},
+		Relations: relations,
+	})
+}
+
+// parseTropes forms the JsonTrope of each of the titles from the namespaces, the indexes column and the examples column of a CSV record
+// Main tropes don't have namespaces, so they are nil
+func parseTropes(titles, namespaces []string, indexesColumn, examplesColumn string) ([]media.JsonTrope, error) {
+	if len(titles) == 0 {
+		return nil, nil
+	}
+
+	// A single trope without indexes has an empty indexes column, so it isn't split like the rest of the lists
+	indexes := strings.Split(indexesColumn, ";")
+	if len(indexes) != len(titles) || (namespaces != nil && len(namespaces) != len(titles)) {
+		return nil, fmt.Errorf("%w: the trope columns don't have the same number of elements", media.ErrMissingValues)
+	}
+
+	var examples [][]media.JsonExample
+	if errExamples := json.Unmarshal([]byte(examplesColumn), &examples); errExamples != nil {
+		return nil, errExamples
+	}
+
+	if len(examples) != 0 && len(examples) != len(titles) {
+		return nil, fmt.Errorf("%w: the trope columns don't have the same number of elements", media.ErrMissingValues)
+	}
+
+	jsonTropes := make([]media.JsonTrope, 0, len(titles))
+	for pos, title := range titles {
+		jsonTrope := media.JsonTrope{
+			Title:   title,
+			Indexes: splitIndexes(indexes[pos]),
+		}
+
+		if namespaces != nil {
+			jsonTrope.Namespace = namespaces[pos]
+		}
+
+		if len(examples) != 0 {
+			jsonTrope.Examples = examples[pos]
+		}
+
+		jsonTropes = append(jsonTropes, jsonTrope)
+	}
+
+	return jsonTropes, nil
+}
+
+// splitColumn separates the values of a column of a CSV record joined by ";", which has none if it's empty
+func splitColumn(column string) []string {
+	if column == "" {
+		return nil
+	}
+
+	return strings.Split(column, ";")
+}
+
+// splitIndexes separates the names of the indexes of a trope joined by "|", which has none if it's empty
+func splitIndexes(indexes string) []string {
+	if indexes == "" {
+		return nil
+	}
+
+	return strings.Split(indexes, "|")
+}
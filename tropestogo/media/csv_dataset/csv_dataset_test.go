@@ -1,18 +1,23 @@
 package csv_dataset_test
 
 import (
+	"context"
 	"encoding/csv"
 	"errors"
 	"fmt"
 	"github.com/jlgallego99/TropesToGo/media"
 	"github.com/jlgallego99/TropesToGo/media/csv_dataset"
+	"github.com/jlgallego99/TropesToGo/media/dataset_file"
+	"github.com/jlgallego99/TropesToGo/media/repository_conformance"
 	"github.com/jlgallego99/TropesToGo/trope"
 	"github.com/jlgallego99/TropesToGo/tvtropespages"
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
 	"math/rand"
 	"os"
+	"path/filepath"
 	"strings"
+	"sync"
 	"time"
 )
 
@@ -25,7 +30,6 @@ const (
 var repository *csv_dataset.CSVRepository
 var errorRepository, errRemoveAll, errAddMedia, errPersist error
 var mediaEntry media.Media
-var reader *csv.Reader
 var datasetFile *os.File
 var tropes map[trope.Trope]struct{}
 var numTropes int
@@ -42,15 +46,14 @@ var _ = BeforeSuite(func() {
 		tropes[subTrope] = struct{}{}
 	}
 
-	tvTropesPage, _ := tvtropespages.NewPage(oldboyUrl, false, nil)
+	tvTropesPage, _ := tvtropespages.NewPage(context.Background(), oldboyUrl, false, nil)
 	mediaEntry, _ = media.NewMedia("Oldboy", "2003", time.Now(), tropes, tvTropesPage, media.Film)
+	remake, _ := trope.NewRelation("https://tvtropes.org/pmwiki/pmwiki.php/Film/Oldboy2013", "English-language remake",
+		"An English-language remake was released on November 27, 2013.", trope.AdaptationRelation)
+	mediaEntry.AddRelations(remake)
 })
 
 var _ = Describe("CsvDataset", func() {
-	BeforeEach(func() {
-		reader, _ = repository.GetReader()
-	})
-
 	AfterEach(func() {
 		// Reset file
 		repository.RemoveAll()
@@ -85,7 +88,7 @@ var _ = Describe("CsvDataset", func() {
 		})
 
 		It("Should have added the correct record to the CSV", func() {
-			records, err := reader.ReadAll()
+			records, err := readDataset()
 			Expect(err).To(BeNil())
 
 			correctRecords(records)
@@ -105,7 +108,7 @@ var _ = Describe("CsvDataset", func() {
 		})
 
 		It("Should only be one record on the CSV file", func() {
-			records, err := reader.ReadAll()
+			records, err := readDataset()
 
 			Expect(err).To(BeNil())
 			Expect(len(records)).To(Equal(2))
@@ -142,6 +145,7 @@ var _ = Describe("CsvDataset", func() {
 		})
 
 		AfterEach(func() {
+			repository.Close()
 			repository, errorRepository = csv_dataset.NewCSVRepository("dataset")
 		})
 
@@ -169,14 +173,14 @@ var _ = Describe("CsvDataset", func() {
 			for subTrope := range newSubTropes {
 				newTropes[subTrope] = struct{}{}
 			}
-			tvTropesPage, _ := tvtropespages.NewPage(oldboyUrl, false, nil)
+			tvTropesPage, _ := tvtropespages.NewPage(context.Background(), oldboyUrl, false, nil)
 			updatedMediaEntry, _ := media.NewMedia("Oldboy", "2013", time.Now(), newTropes, tvTropesPage, media.Film)
 
 			errUpdate = repository.UpdateMedia("Oldboy", "2003", updatedMediaEntry)
 		})
 
 		It("Should have the new record updated", func() {
-			records, err := reader.ReadAll()
+			records, err := readDataset()
 			Expect(err).To(BeNil())
 
 			correctRecords(records)
@@ -187,9 +191,196 @@ var _ = Describe("CsvDataset", func() {
 			Expect(records[1][5]).To(Not(Equal(createTropesString(mediaEntry.GetWork().Tropes))))
 		})
 
+		It("Should hold the lock of the CSV file", func() {
+			Expect("dataset.csv" + dataset_file.LockSuffix).To(BeAnExistingFile())
+		})
+
+		It("Shouldn't return an error", func() {
+			Expect(errUpdate).To(BeNil())
+			Expect(errPersist).To(BeNil())
+		})
+	})
+
+	Context("Update the metadata and relations of a Film in the CSV file", func() {
+		var errUpdate error
+
+		BeforeEach(func() {
+			errAddMedia = repository.AddMedia(mediaEntry)
+			Expect(errAddMedia).To(BeNil())
+			errPersist = repository.Persist()
+			Expect(errPersist).To(BeNil())
+
+			tvTropesPage, _ := tvtropespages.NewPage(context.Background(), oldboyUrl, false, nil)
+			updatedMediaEntry, _ := media.NewMedia("Oldboy", "2003", time.Now(), tropes, tvTropesPage, media.Film)
+			updatedMediaEntry.SetMetadata(trope.WorkMetadata{Description: []string{"A man is imprisoned for fifteen years."}, Creators: []string{"Park Chan-wook"}})
+			sequel, _ := trope.NewRelation("https://tvtropes.org/pmwiki/pmwiki.php/Film/SympathyForLadyVengeance", "Lady Vengeance", "", trope.SequelRelation)
+			updatedMediaEntry.AddRelations(sequel)
+
+			errUpdate = repository.UpdateMedia("Oldboy", "2003", updatedMediaEntry)
+		})
+
 		It("Shouldn't return an error", func() {
 			Expect(errUpdate).To(BeNil())
+		})
+
+		It("Should load the record back with its new metadata and relations", func() {
+			updatedMedia, errGet := repository.GetMedia("Oldboy", "2003")
+
+			Expect(errGet).To(BeNil())
+			Expect(updatedMedia.GetMetadata().Description).To(Equal([]string{"A man is imprisoned for fifteen years."}))
+			Expect(updatedMedia.GetMetadata().Creators).To(Equal([]string{"Park Chan-wook"}))
+			Expect(updatedMedia.GetRelations()).To(HaveLen(1))
+			Expect(updatedMedia.GetRelations()[0].Type).To(Equal(trope.SequelRelation))
+		})
+	})
+
+	Context("Parse a record of the CSV file back into a Media", func() {
+		var record []string
+		var recordMedia media.Media
+		var errParse error
+
+		BeforeEach(func() {
+			record = csv_dataset.CreateMediaRecord(mediaEntry)
+			recordMedia, errParse = csv_dataset.ParseMediaRecord(record)
+		})
+
+		It("Shouldn't return an error", func() {
+			Expect(errParse).To(BeNil())
+		})
+
+		It("Should have the same Work, tropes and relations", func() {
+			Expect(recordMedia.GetKey()).To(Equal(mediaEntry.GetKey()))
+			Expect(recordMedia.GetPage().GetUrl().String()).To(Equal(oldboyUrl))
+			Expect(recordMedia.GetWork().Tropes).To(Equal(mediaEntry.GetWork().Tropes))
+			Expect(recordMedia.GetWork().SubTropes).To(Equal(mediaEntry.GetWork().SubTropes))
+			Expect(recordMedia.GetRelations()).To(Equal(mediaEntry.GetRelations()))
+		})
+
+		It("Should form the same record again", func() {
+			Expect(csv_dataset.CreateMediaRecord(recordMedia)[:5]).To(Equal(record[:5]))
+			Expect(csv_dataset.CreateMediaRecord(recordMedia)[12:]).To(Equal(record[12:]))
+		})
+
+		It("Should return an error if the record isn't valid", func() {
+			_, errColumns := csv_dataset.ParseMediaRecord(record[:5])
+			record[7] = ""
+			_, errNamespaces := csv_dataset.ParseMediaRecord(record)
+
+			Expect(errors.Is(errColumns, media.ErrInvalidRecord)).To(BeTrue())
+			Expect(errors.Is(errNamespaces, media.ErrInvalidRecord)).To(BeTrue())
+		})
+	})
+
+	Context("Read a CSV file with an invalid record", func() {
+		var errUpdate, errGet error
+
+		BeforeEach(func() {
+			errAddMedia = repository.AddMedia(mediaEntry)
+			Expect(errAddMedia).To(BeNil())
+			errPersist = repository.Persist()
 			Expect(errPersist).To(BeNil())
+
+			// The second record has a trope on an index that doesn't exist
+			invalidRecord := csv_dataset.CreateMediaRecord(mediaEntry)
+			invalidRecord[1], invalidRecord[5], invalidRecord[8], invalidRecord[10] = "2013", "RevengeTragedy", "NotAnIndex", "[]"
+			datasetFile, _ := os.OpenFile("dataset.csv", os.O_APPEND|os.O_WRONLY, 0644)
+			writer := csv.NewWriter(datasetFile)
+			writer.Write(invalidRecord)
+			writer.Flush()
+			datasetFile.Close()
+			errUpdate = repository.UpdateMedia("Oldboy", "2003", mediaEntry)
+			_, errGet = repository.GetMedia("Oldboy", "2003")
+		})
+
+		It("Should report which record isn't valid", func() {
+			Expect(errors.Is(errUpdate, csv_dataset.ErrReadCsv)).To(BeTrue())
+			Expect(errors.Is(errUpdate, media.ErrInvalidRecord)).To(BeTrue())
+			Expect(errUpdate.Error()).To(ContainSubstring("record 2"))
+			Expect(errors.Is(errGet, media.ErrInvalidRecord)).To(BeTrue())
+		})
+	})
+
+	Context("Open a CSV file with the columns of an older version", func() {
+		var oldRecord []string
+		var errGet error
+		var oldMedia media.Media
+
+		BeforeEach(func() {
+			// The first version only had 8 columns, and a later record was persisted with all of them
+			record := csv_dataset.CreateMediaRecord(mediaEntry)
+			oldRecord = append([]string{}, record[:8]...)
+			newRecord := append([]string{}, record...)
+			newRecord[1] = "2013"
+			datasetFile, _ := os.Create("dataset.csv")
+			writer := csv.NewWriter(datasetFile)
+			writer.Write(csv_dataset.Headers[:8])
+			writer.Write(oldRecord)
+			writer.Write(newRecord)
+			writer.Flush()
+			datasetFile.Close()
+
+			repository.Close()
+			repository, errorRepository = csv_dataset.NewCSVRepository("dataset")
+			oldMedia, errGet = repository.GetMedia("Oldboy", "2003")
+		})
+
+		It("Shouldn't return an error", func() {
+			Expect(errorRepository).To(BeNil())
+			Expect(errGet).To(BeNil())
+		})
+
+		It("Should back up the old CSV file", func() {
+			backup, errBackup := os.ReadFile("dataset.csv" + dataset_file.BackupSuffix)
+
+			Expect(errBackup).To(BeNil())
+			Expect(string(backup)).To(HavePrefix(strings.Join(csv_dataset.Headers[:8], ",") + "\n"))
+		})
+
+		It("Should have migrated every record to the current columns", func() {
+			records, err := readDataset()
+
+			Expect(err).To(BeNil())
+			Expect(len(records)).To(Equal(3))
+			Expect(records[0]).To(Equal(csv_dataset.Headers))
+			Expect(records[1][:8]).To(Equal(oldRecord))
+			Expect(records[2][1]).To(Equal("2013"))
+		})
+
+		It("Should read the old record without the data of the new columns", func() {
+			Expect(oldMedia.GetWork().Tropes).To(HaveLen(len(mediaEntry.GetWork().Tropes)))
+			Expect(oldMedia.GetWork().SubTropes).To(HaveLen(len(mediaEntry.GetWork().SubTropes)))
+			Expect(oldMedia.GetRelations()).To(BeEmpty())
+		})
+
+		It("Should parse records with the columns of an older version", func() {
+			_, errParse := csv_dataset.ParseMediaRecord(oldRecord)
+
+			Expect(errParse).To(BeNil())
+		})
+	})
+
+	Context("Open a CSV file with unknown columns", func() {
+		BeforeEach(func() {
+			os.WriteFile("dataset.csv", []byte("name,released\nOldboy,2003\n"), 0644)
+
+			repository.Close()
+			_, errorRepository = csv_dataset.NewCSVRepository("dataset")
+		})
+
+		AfterEach(func() {
+			os.Remove("dataset.csv")
+			repository, _ = csv_dataset.NewCSVRepository("dataset")
+		})
+
+		It("Should refuse to open it", func() {
+			Expect(errors.Is(errorRepository, csv_dataset.ErrOpenCsv)).To(BeTrue())
+			Expect(errors.Is(errorRepository, csv_dataset.ErrHeaders)).To(BeTrue())
+		})
+
+		It("Shouldn't modify the CSV file", func() {
+			contents, _ := os.ReadFile("dataset.csv")
+
+			Expect(string(contents)).To(Equal("name,released\nOldboy,2003\n"))
 		})
 	})
 
@@ -205,13 +396,61 @@ var _ = Describe("CsvDataset", func() {
 		})
 
 		It("Should only be one Media record on the CSV file", func() {
-			records, err := reader.ReadAll()
+			records, err := readDataset()
 
 			Expect(err).To(BeNil())
 			Expect(len(records)).To(Equal(2))
 		})
 	})
 
+	Context("Add Media from several goroutines at the same time", func() {
+		const addingGoroutines = 20
+		var addErrors []error
+
+		BeforeEach(func() {
+			addErrors = make([]error, addingGoroutines)
+			var adding sync.WaitGroup
+			for i := 0; i < addingGoroutines; i++ {
+				adding.Add(1)
+				go func(i int) {
+					defer adding.Done()
+
+					// Half of the goroutines add the same Media and the other half a different Media each
+					addedMedia := mediaEntry
+					if i%2 == 1 {
+						tvTropesPage, _ := tvtropespages.NewPage(context.Background(), oldboyUrl+fmt.Sprint(i), false, nil)
+						addedMedia, _ = media.NewMedia("Oldboy"+fmt.Sprint(i), "2003", time.Now(), tropes, tvTropesPage, media.Film)
+					}
+
+					addErrors[i] = repository.AddMedia(addedMedia)
+				}(i)
+			}
+			adding.Wait()
+
+			errPersist = repository.Persist()
+		})
+
+		It("Should only add each Media once", func() {
+			duplicated := 0
+			for _, errAdd := range addErrors {
+				if errAdd != nil {
+					Expect(errors.Is(errAdd, csv_dataset.ErrDuplicatedMedia)).To(BeTrue())
+					duplicated++
+				}
+			}
+
+			Expect(duplicated).To(Equal(addingGoroutines/2 - 1))
+			Expect(errPersist).To(BeNil())
+		})
+
+		It("Should persist every different Media", func() {
+			records, err := readDataset()
+
+			Expect(err).To(BeNil())
+			Expect(records).To(HaveLen(addingGoroutines/2 + 2))
+		})
+	})
+
 	Context("Get all the URLs of the persisted Media and its last updated time", func() {
 		var workPages map[string]time.Time
 		var errGetWorkPages error
@@ -236,11 +475,97 @@ var _ = Describe("CsvDataset", func() {
 			}
 		})
 	})
+
+	Context("Get all the tropes of the persisted Media", func() {
+		var datasetTropes map[string]struct{}
+		var errGetTropes error
+
+		BeforeEach(func() {
+			errAddMedia = repository.AddMedia(mediaEntry)
+			Expect(errAddMedia).To(BeNil())
+			errPersist = repository.Persist()
+			Expect(errPersist).To(BeNil())
+
+			datasetTropes, errGetTropes = repository.GetTropes()
+		})
+
+		It("Shouldn't return an error", func() {
+			Expect(errGetTropes).To(BeNil())
+		})
+
+		It("Should return the title of every trope and subtrope", func() {
+			Expect(datasetTropes).To(HaveLen(len(tropes)))
+			for mediaTrope := range tropes {
+				Expect(datasetTropes).To(HaveKey(mediaTrope.GetTitle()))
+			}
+		})
+	})
+
+	Context("Get the relations of the persisted Media", func() {
+		var datasetRelations map[string][]trope.Relation
+		var errGetRelations error
+
+		BeforeEach(func() {
+			errAddMedia = repository.AddMedia(mediaEntry)
+			Expect(errAddMedia).To(BeNil())
+			errPersist = repository.Persist()
+			Expect(errPersist).To(BeNil())
+
+			datasetRelations, errGetRelations = repository.GetWorkRelations()
+		})
+
+		It("Shouldn't return an error", func() {
+			Expect(errGetRelations).To(BeNil())
+		})
+
+		It("Should return the relations of every Work by its URL", func() {
+			Expect(datasetRelations).To(HaveLen(1))
+			Expect(datasetRelations[oldboyUrl]).To(Equal(mediaEntry.GetRelations()))
+		})
+	})
+
+	Context("Get the relations of a CSV file without the relations column", func() {
+		var datasetRelations map[string][]trope.Relation
+		var errGetRelations error
+
+		BeforeEach(func() {
+			// A CSV file of the version before the relations column, written after the repository was opened
+			record := csv_dataset.CreateMediaRecord(mediaEntry)
+			datasetFile, _ := os.Create("dataset.csv")
+			writer := csv.NewWriter(datasetFile)
+			writer.Write(csv_dataset.Headers[:10])
+			writer.Write(record[:10])
+			writer.Flush()
+			datasetFile.Close()
+
+			datasetRelations, errGetRelations = repository.GetWorkRelations()
+		})
+
+		It("Shouldn't return an error", func() {
+			Expect(errGetRelations).To(BeNil())
+		})
+
+		It("Should return every Work without relations", func() {
+			Expect(datasetRelations).To(HaveLen(1))
+			Expect(datasetRelations).To(HaveKey(oldboyUrl))
+			Expect(datasetRelations[oldboyUrl]).To(BeEmpty())
+		})
+	})
 })
 
+var _ = repository_conformance.DescribeRepository("CSV", func(name string) (media.RepositoryMedia, error) {
+	return csv_dataset.NewCSVRepository(name)
+}, repository_conformance.Features{Indexes: true, Examples: true, Metadata: true})
+
 var _ = AfterSuite(func() {
 	datasetFile.Close()
+	repository.Close()
 	os.Remove("dataset.csv")
+
+	backups, _ := filepath.Glob("dataset.csv" + dataset_file.BackupSuffix + "*")
+	for _, backup := range backups {
+		os.Remove(backup)
+	}
 })
 
 // correctRecords checks if a CSV record has all expected fields without any errors
@@ -257,9 +582,19 @@ func correctRecords(records [][]string) {
 	Expect(len(strings.Split(records[1][7], ";")) > 0).To(BeTrue())
 }
 
+// readDataset reads every record of the CSV file with a new reader, because every write replaces the file
+func readDataset() ([][]string, error) {
+	reader, errReader := repository.GetReader()
+	if errReader != nil {
+		return nil, errReader
+	}
+
+	return reader.ReadAll()
+}
+
 // checkHeaders checks if the CSV headers are correct
 func checkHeaders() {
-	records, err := reader.ReadAll()
+	records, err := readDataset()
 
 	Expect(err).To(BeNil())
 	Expect(len(records)).To(Equal(1))
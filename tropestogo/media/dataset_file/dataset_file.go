@@ -0,0 +1,224 @@
+package dataset_file
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+)
+
+var (
+	ErrWrite  = errors.New("error writing the dataset file")
+	ErrBackup = errors.New("error backing up the dataset file")
+	ErrLock   = errors.New("error locking the dataset file")
+	ErrLocked = errors.New("the dataset file is being written by another process")
+)
+
+const (
+	// LockSuffix is appended to the name of a dataset file to form the name of its lock file
+	LockSuffix = ".lock"
+
+	// BackupSuffix is appended to the name of a dataset file to form the name of its newest backup
+	// Older backups have their number appended to it, up to MaxBackups - 1
+	BackupSuffix = ".bak"
+
+	// MaxBackups is the number of backups of a dataset file that are kept
+	MaxBackups = 3
+)
+
+// Error formats a generic error
+func Error(message string, err error, subErr error) error {
+	if subErr != nil {
+		return fmt.Errorf("%w: "+message+"\n%w", err, subErr)
+	} else {
+		return fmt.Errorf("%w: "+message+"", err)
+	}
+}
+
+// WriteFile replaces the contents of the name file with the data, creating it if it doesn't exist, like Replace does
+// It returns an ErrWrite error if the file couldn't be written
+func WriteFile(name string, data []byte) error {
+	return Replace(name, func(writer io.Writer) error {
+		_, errWrite := writer.Write(data)
+		return errWrite
+	})
+}
+
+// Replace replaces the contents of the name file with what the write function writes, creating it if it doesn't exist
+// The contents are written on a temporary file next to it that is synced and renamed to name, and then the directory is synced,
+// so after a crash the file has either its old contents or the new ones, but never a part of them
+// If write returns an error the file isn't replaced, and it returns an ErrWrite error with it
+func Replace(name string, write func(io.Writer) error) error {
+	tempFile, errTemp := os.CreateTemp(filepath.Dir(name), filepath.Base(name)+".*.tmp")
+	if errTemp != nil {
+		return Error(name, ErrWrite, errTemp)
+	}
+	defer os.Remove(tempFile.Name())
+
+	errWrite := write(tempFile)
+	if errWrite == nil {
+		errWrite = tempFile.Sync()
+	}
+	if errClose := tempFile.Close(); errWrite == nil {
+		errWrite = errClose
+	}
+	if errWrite != nil {
+		return Error(name, ErrWrite, errWrite)
+	}
+
+	// Temporary files are created only readable by their owner, so they get the permissions of a new dataset
+	if errChmod := os.Chmod(tempFile.Name(), 0644); errChmod != nil {
+		return Error(name, ErrWrite, errChmod)
+	}
+
+	if errRename := os.Rename(tempFile.Name(), name); errRename != nil {
+		return Error(name, ErrWrite, errRename)
+	}
+
+	if errSync := syncDir(filepath.Dir(name)); errSync != nil {
+		return Error(name, ErrWrite, errSync)
+	}
+
+	return nil
+}
+
+// Backup rotates the backups of the name file and copies it as its newest backup, with the BackupSuffix
+// It does nothing if the file doesn't exist, and returns an ErrBackup error if the backups couldn't be rotated or written
+func Backup(name string) error {
+	datasetFile, errOpen := os.Open(name)
+	if errors.Is(errOpen, os.ErrNotExist) {
+		return nil
+	} else if errOpen != nil {
+		return Error(name, ErrBackup, errOpen)
+	}
+	defer datasetFile.Close()
+
+	backupName, errRotate := RotateBackups(name)
+	if errRotate != nil {
+		return errRotate
+	}
+
+	errCopy := Replace(backupName, func(writer io.Writer) error {
+		_, errCopy := io.Copy(writer, datasetFile)
+		return errCopy
+	})
+	if errCopy != nil {
+		return Error(name, ErrBackup, errCopy)
+	}
+
+	return nil
+}
+
+// RotateBackups renames every backup of the name file to the next older one, dropping the oldest one of MaxBackups,
+// so the newest backup can be written on the returned name
+// It returns an ErrBackup error if any of the backups couldn't be renamed
+func RotateBackups(name string) (string, error) {
+	backupNames := make([]string, 0, MaxBackups)
+	backupNames = append(backupNames, name+BackupSuffix)
+	for backup := 1; backup < MaxBackups; backup++ {
+		backupNames = append(backupNames, name+BackupSuffix+"."+strconv.Itoa(backup))
+	}
+
+	for backup := len(backupNames) - 1; backup > 0; backup-- {
+		errRename := os.Rename(backupNames[backup-1], backupNames[backup])
+		if errRename != nil && !errors.Is(errRename, os.ErrNotExist) {
+			return "", Error(backupNames[backup-1], ErrBackup, errRename)
+		}
+	}
+
+	return backupNames[0], nil
+}
+
+// Lock is the advisory lock of a dataset file, which is held by only one process at a time
+// The lock is a file next to the dataset with the LockSuffix and the process ID of its holder, which is removed when it's released
+// Several repositories of the same process share the lock of a dataset, which is released when all of them release it
+type Lock struct {
+	// path is the absolute path of the lock file
+	path string
+
+	// released is set when this holder has released the lock
+	released bool
+}
+
+// sharedLock is the lock file of a dataset held by this process and the number of holders of the process that haven't released it
+type sharedLock struct {
+	file    *os.File
+	holders int
+}
+
+var (
+	// heldLocks are the locks held by this process, by the absolute path of their lock files
+	heldLocks = make(map[string]*sharedLock)
+
+	// locksMutex guards heldLocks
+	locksMutex sync.Mutex
+)
+
+// AcquireLock acquires the lock of the name dataset file without waiting for it, creating its lock file
+// It returns an ErrLocked error with the process ID of its holder if another process holds it, or an ErrLock error if it couldn't be acquired
+func AcquireLock(name string) (*Lock, error) {
+	path, errPath := filepath.Abs(name + LockSuffix)
+	if errPath != nil {
+		return nil, Error(name, ErrLock, errPath)
+	}
+
+	locksMutex.Lock()
+	defer locksMutex.Unlock()
+
+	if held, exists := heldLocks[path]; exists {
+		held.holders++
+		return &Lock{path: path}, nil
+	}
+
+	lockFile, errLock := lockPath(path)
+	if errLock != nil {
+		return nil, errLock
+	}
+
+	// The process ID only helps to find which process holds the lock, so it doesn't matter if it isn't written
+	lockFile.Truncate(0)
+	lockFile.WriteAt([]byte(strconv.Itoa(os.Getpid())+"\n"), 0)
+
+	heldLocks[path] = &sharedLock{file: lockFile, holders: 1}
+	return &Lock{path: path}, nil
+}
+
+// Release releases the lock for this holder, removing the lock file when the last holder of the process releases it
+// Releasing an already released lock does nothing
+func (lock *Lock) Release() error {
+	locksMutex.Lock()
+	defer locksMutex.Unlock()
+
+	held, exists := heldLocks[lock.path]
+	if lock.released || !exists {
+		return nil
+	}
+	lock.released = true
+
+	held.holders--
+	if held.holders > 0 {
+		return nil
+	}
+	delete(heldLocks, lock.path)
+
+	if errRelease := releasePath(lock.path, held.file); errRelease != nil {
+		return Error(lock.path, ErrLock, errRelease)
+	}
+
+	return nil
+}
+
+// lockedBy returns the process ID written on the lock file at path, or an empty string if it can't be read
+func lockedBy(path string) string {
+	contents, _ := os.ReadFile(path)
+	for pos, char := range contents {
+		if char < '0' || char > '9' {
+			return string(contents[:pos])
+		}
+	}
+
+	return string(contents)
+}
@@ -0,0 +1,13 @@
+package dataset_file_test
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func TestDatasetFile(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "DatasetFile Suite")
+}
@@ -0,0 +1,168 @@
+package dataset_file_test
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/jlgallego99/TropesToGo/media/dataset_file"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var datasetDir, datasetName string
+
+var _ = Describe("DatasetFile", func() {
+	BeforeEach(func() {
+		var errTemp error
+		datasetDir, errTemp = os.MkdirTemp("", "dataset_file")
+		Expect(errTemp).To(BeNil())
+
+		datasetName = filepath.Join(datasetDir, "dataset.json")
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(datasetDir)
+	})
+
+	Context("Write a dataset file", func() {
+		var errWrite error
+
+		BeforeEach(func() {
+			errWrite = dataset_file.WriteFile(datasetName, []byte("{\"tropestogo\": []}"))
+		})
+
+		It("Shouldn't return an error", func() {
+			Expect(errWrite).To(BeNil())
+		})
+
+		It("Should have the new contents", func() {
+			Expect(readFile(datasetName)).To(Equal("{\"tropestogo\": []}"))
+		})
+
+		It("Should be readable by everyone", func() {
+			datasetInfo, errStat := os.Stat(datasetName)
+			Expect(errStat).To(BeNil())
+			Expect(datasetInfo.Mode().Perm()).To(Equal(os.FileMode(0644)))
+		})
+
+		It("Shouldn't leave any temporary file", func() {
+			Expect(datasetFiles()).To(ConsistOf("dataset.json"))
+		})
+	})
+
+	Context("Fail to replace a dataset file", func() {
+		var errReplace, errFailed error
+
+		BeforeEach(func() {
+			Expect(dataset_file.WriteFile(datasetName, []byte("old"))).To(Succeed())
+
+			errFailed = errors.New("interrupted write")
+			errReplace = dataset_file.Replace(datasetName, func(writer io.Writer) error {
+				writer.Write([]byte("new"))
+				return errFailed
+			})
+		})
+
+		It("Should return the error of the write", func() {
+			Expect(errors.Is(errReplace, dataset_file.ErrWrite)).To(BeTrue())
+			Expect(errors.Is(errReplace, errFailed)).To(BeTrue())
+		})
+
+		It("Should keep the old contents", func() {
+			Expect(readFile(datasetName)).To(Equal("old"))
+		})
+
+		It("Shouldn't leave any temporary file", func() {
+			Expect(datasetFiles()).To(ConsistOf("dataset.json"))
+		})
+	})
+
+	Context("Back up a dataset file several times", func() {
+		var errBackup error
+
+		BeforeEach(func() {
+			for version := 1; version <= dataset_file.MaxBackups+1; version++ {
+				Expect(dataset_file.WriteFile(datasetName, []byte(strconv.Itoa(version)))).To(Succeed())
+				errBackup = dataset_file.Backup(datasetName)
+			}
+		})
+
+		It("Shouldn't return an error", func() {
+			Expect(errBackup).To(BeNil())
+		})
+
+		It("Should have the newest contents on the newest backup", func() {
+			Expect(readFile(datasetName + dataset_file.BackupSuffix)).To(Equal(strconv.Itoa(dataset_file.MaxBackups + 1)))
+		})
+
+		It("Should keep only the newest backups", func() {
+			Expect(datasetFiles()).To(ConsistOf("dataset.json", "dataset.json.bak", "dataset.json.bak.1", "dataset.json.bak.2"))
+			Expect(readFile(datasetName + dataset_file.BackupSuffix + ".1")).To(Equal("3"))
+			Expect(readFile(datasetName + dataset_file.BackupSuffix + ".2")).To(Equal("2"))
+		})
+	})
+
+	Context("Back up a dataset file that doesn't exist", func() {
+		It("Shouldn't do anything", func() {
+			Expect(dataset_file.Backup(datasetName)).To(Succeed())
+			Expect(datasetFiles()).To(BeEmpty())
+		})
+	})
+
+	Context("Lock a dataset file from the same process", func() {
+		var lock, otherLock *dataset_file.Lock
+		var errLock, errOtherLock error
+
+		BeforeEach(func() {
+			lock, errLock = dataset_file.AcquireLock(datasetName)
+			otherLock, errOtherLock = dataset_file.AcquireLock(datasetName)
+		})
+
+		AfterEach(func() {
+			lock.Release()
+			otherLock.Release()
+		})
+
+		It("Should share the lock", func() {
+			Expect(errLock).To(BeNil())
+			Expect(errOtherLock).To(BeNil())
+		})
+
+		It("Should write the process ID on the lock file", func() {
+			Expect(readFile(datasetName + dataset_file.LockSuffix)).To(Equal(strconv.Itoa(os.Getpid()) + "\n"))
+		})
+
+		It("Should remove the lock file only when every holder releases it", func() {
+			Expect(lock.Release()).To(Succeed())
+			Expect(lock.Release()).To(Succeed())
+			Expect(datasetName + dataset_file.LockSuffix).To(BeAnExistingFile())
+
+			Expect(otherLock.Release()).To(Succeed())
+			Expect(datasetName + dataset_file.LockSuffix).To(Not(BeAnExistingFile()))
+		})
+	})
+})
+
+// readFile reads the contents of the name file as a string
+func readFile(name string) string {
+	contents, errRead := os.ReadFile(name)
+	Expect(errRead).To(BeNil())
+
+	return string(contents)
+}
+
+// datasetFiles returns the names of every file on the directory of the dataset
+func datasetFiles() []string {
+	entries, errRead := os.ReadDir(datasetDir)
+	Expect(errRead).To(BeNil())
+
+	names := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+
+	return names
+}
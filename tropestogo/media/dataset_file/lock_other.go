@@ -0,0 +1,36 @@
+//go:build !unix
+
+package dataset_file
+
+import (
+	"errors"
+	"os"
+)
+
+// lockPath creates the lock file at path only if it doesn't exist, because there's no flock on this system
+// It returns an ErrLocked error if the file exists, so a lock file left by a process that died must be removed by hand
+func lockPath(path string) (*os.File, error) {
+	lockFile, errOpen := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_EXCL, 0644)
+	if errors.Is(errOpen, os.ErrExist) {
+		return nil, Error(path+" by process "+lockedBy(path), ErrLocked, nil)
+	} else if errOpen != nil {
+		return nil, Error(path, ErrLock, errOpen)
+	}
+
+	return lockFile, nil
+}
+
+// releasePath closes the lock file at path and removes it, which releases the lock
+func releasePath(path string, lockFile *os.File) error {
+	errClose := lockFile.Close()
+	if errRemove := os.Remove(path); errRemove != nil {
+		return errRemove
+	}
+
+	return errClose
+}
+
+// syncDir does nothing, because directories can't be synced on every system
+func syncDir(dir string) error {
+	return nil
+}
@@ -0,0 +1,76 @@
+//go:build unix
+
+package dataset_file
+
+import (
+	"errors"
+	"os"
+	"syscall"
+)
+
+// lockPath creates the lock file at path and locks it with flock, which the system releases if the process dies
+// It returns an ErrLocked error if another process has it locked
+func lockPath(path string) (*os.File, error) {
+	for {
+		lockFile, errOpen := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+		if errOpen != nil {
+			return nil, Error(path, ErrLock, errOpen)
+		}
+
+		errFlock := syscall.Flock(int(lockFile.Fd()), syscall.LOCK_EX|syscall.LOCK_NB)
+		if errors.Is(errFlock, syscall.EWOULDBLOCK) {
+			lockFile.Close()
+			return nil, Error(path+" by process "+lockedBy(path), ErrLocked, nil)
+		} else if errFlock != nil {
+			lockFile.Close()
+			return nil, Error(path, ErrLock, errFlock)
+		}
+
+		// The previous holder may have removed the lock file between opening and locking it, so it's only held
+		// if the locked file is still the one at path
+		lockedInfo, errLocked := lockFile.Stat()
+		pathInfo, errPath := os.Stat(path)
+		if errLocked == nil && errPath == nil && os.SameFile(lockedInfo, pathInfo) {
+			return lockFile, nil
+		}
+
+		unlockFile(lockFile)
+		lockFile.Close()
+		if errLocked != nil {
+			return nil, Error(path, ErrLock, errLocked)
+		} else if errPath != nil && !errors.Is(errPath, os.ErrNotExist) {
+			return nil, Error(path, ErrLock, errPath)
+		}
+	}
+}
+
+// releasePath removes the lock file at path and then unlocks it, so another process can't lock a file that is being removed
+func releasePath(path string, lockFile *os.File) error {
+	errRemove := os.Remove(path)
+	errUnlock := unlockFile(lockFile)
+	if errClose := lockFile.Close(); errUnlock == nil {
+		errUnlock = errClose
+	}
+
+	if errRemove != nil {
+		return errRemove
+	}
+
+	return errUnlock
+}
+
+// unlockFile releases the flock of the lock file
+func unlockFile(lockFile *os.File) error {
+	return syscall.Flock(int(lockFile.Fd()), syscall.LOCK_UN)
+}
+
+// syncDir syncs the directory, so the files renamed on it are persisted
+func syncDir(dir string) error {
+	dirFile, errOpen := os.Open(dir)
+	if errOpen != nil {
+		return errOpen
+	}
+	defer dirFile.Close()
+
+	return dirFile.Sync()
+}
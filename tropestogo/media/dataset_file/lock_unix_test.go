@@ -0,0 +1,61 @@
+//go:build unix
+
+package dataset_file_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"syscall"
+
+	"github.com/jlgallego99/TropesToGo/media/dataset_file"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("DatasetFile lock", func() {
+	BeforeEach(func() {
+		var errTemp error
+		datasetDir, errTemp = os.MkdirTemp("", "dataset_file")
+		Expect(errTemp).To(BeNil())
+
+		datasetName = filepath.Join(datasetDir, "dataset.json")
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(datasetDir)
+	})
+
+	Context("Lock a dataset file locked by another process", func() {
+		var otherProcess *os.File
+		var errLock error
+
+		BeforeEach(func() {
+			// Another process holds the lock through its own open file, as flock locks aren't shared between open files
+			var errOpen error
+			otherProcess, errOpen = os.OpenFile(datasetName+dataset_file.LockSuffix, os.O_RDWR|os.O_CREATE, 0644)
+			Expect(errOpen).To(BeNil())
+			otherProcess.WriteString("12345\n")
+			Expect(syscall.Flock(int(otherProcess.Fd()), syscall.LOCK_EX|syscall.LOCK_NB)).To(Succeed())
+
+			_, errLock = dataset_file.AcquireLock(datasetName)
+		})
+
+		AfterEach(func() {
+			otherProcess.Close()
+		})
+
+		It("Should return an error with the process that holds it", func() {
+			Expect(errors.Is(errLock, dataset_file.ErrLocked)).To(BeTrue())
+			Expect(errLock.Error()).To(ContainSubstring("12345"))
+		})
+
+		It("Should acquire the lock once the other process releases it", func() {
+			Expect(syscall.Flock(int(otherProcess.Fd()), syscall.LOCK_UN)).To(Succeed())
+
+			lock, errAcquire := dataset_file.AcquireLock(datasetName)
+			Expect(errAcquire).To(BeNil())
+			Expect(lock.Release()).To(Succeed())
+		})
+	})
+})
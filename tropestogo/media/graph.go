@@ -0,0 +1,160 @@
+package media
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+
+	"github.com/jlgallego99/TropesToGo/trope"
+)
+
+var (
+	ErrWriteGraph = errors.New("error writing the work graph")
+)
+
+// WorkGraph is the directed graph of the relationships between Works, where every node is the URL of a Work page
+// and every edge is a Relation from a Work of the dataset to another Work, which may or may not be on the dataset
+type WorkGraph struct {
+	// Nodes are the URLs of all the Works on the graph, sorted
+	Nodes []string
+	// Edges are the relations between the Works, sorted by their source Work and in the same order they were found on it
+	Edges []WorkEdge
+	// scraped are the URLs of the Works of the dataset, whose relations are on the graph
+	scraped map[string]bool
+}
+
+// WorkEdge is a relation from the Source Work to the Target Work of a WorkGraph
+type WorkEdge struct {
+	Source     string
+	Target     string
+	AnchorText string
+	Type       trope.RelationType
+}
+
+// JsonGraph is an object for marshaling/unmarshalling a WorkGraph in Json
+type JsonGraph struct {
+	Nodes []JsonGraphNode `json:"nodes"`
+	Edges []JsonGraphEdge `json:"edges"`
+}
+
+// JsonGraphNode is part of JsonGraph, and represent a Work with whether it's on the dataset or only referenced by it
+type JsonGraphNode struct {
+	URL     string `json:"url"`
+	Scraped bool   `json:"scraped"`
+}
+
+// JsonGraphEdge is part of JsonGraph, and represent a relation between two Works
+type JsonGraphEdge struct {
+	Source     string `json:"source"`
+	Target     string `json:"target"`
+	AnchorText string `json:"anchor_text"`
+	Type       string `json:"type"`
+}
+
+// NewWorkGraph builds the WorkGraph of the relations of every Work, given by the URL of the Work as returned by GetWorkRelations
+func NewWorkGraph(workRelations map[string][]trope.Relation) WorkGraph {
+	graph := WorkGraph{
+		Nodes:   make([]string, 0),
+		Edges:   make([]WorkEdge, 0),
+		scraped: make(map[string]bool, len(workRelations)),
+	}
+
+	sources := make([]string, 0, len(workRelations))
+	for source := range workRelations {
+		sources = append(sources, source)
+		graph.scraped[source] = true
+	}
+	sort.Strings(sources)
+
+	nodes := make(map[string]bool)
+	for _, source := range sources {
+		nodes[source] = true
+		for _, relation := range workRelations[source] {
+			nodes[relation.URL] = true
+			graph.Edges = append(graph.Edges, WorkEdge{
+				Source:     source,
+				Target:     relation.URL,
+				AnchorText: relation.AnchorText,
+				Type:       relation.Type,
+			})
+		}
+	}
+
+	for node := range nodes {
+		graph.Nodes = append(graph.Nodes, node)
+	}
+	sort.Strings(graph.Nodes)
+
+	return graph
+}
+
+// IsScraped checks if the Work of the node is on the dataset, instead of being only referenced by its Works
+func (graph WorkGraph) IsScraped(node string) bool {
+	return graph.scraped[node]
+}
+
+// WriteJSON writes the graph on the writer as a JSON object with its nodes and edges
+// It returns an ErrWriteGraph error if it couldn't be written
+func (graph WorkGraph) WriteJSON(writer io.Writer) error {
+	jsonGraph := JsonGraph{
+		Nodes: make([]JsonGraphNode, 0, len(graph.Nodes)),
+		Edges: make([]JsonGraphEdge, 0, len(graph.Edges)),
+	}
+
+	for _, node := range graph.Nodes {
+		jsonGraph.Nodes = append(jsonGraph.Nodes, JsonGraphNode{URL: node, Scraped: graph.IsScraped(node)})
+	}
+
+	for _, edge := range graph.Edges {
+		jsonGraph.Edges = append(jsonGraph.Edges, JsonGraphEdge{
+			Source:     edge.Source,
+			Target:     edge.Target,
+			AnchorText: edge.AnchorText,
+			Type:       edge.Type.String(),
+		})
+	}
+
+	encoder := json.NewEncoder(writer)
+	encoder.SetIndent("", "  ")
+	if errEncode := encoder.Encode(jsonGraph); errEncode != nil {
+		return fmt.Errorf("%w\n%w", ErrWriteGraph, errEncode)
+	}
+
+	return nil
+}
+
+// WriteDOT writes the graph on the writer in the DOT language of Graphviz, labelling every edge with its relation type
+// The Works that aren't on the dataset are drawn with dashed lines
+// It returns an ErrWriteGraph error if it couldn't be written
+func (graph WorkGraph) WriteDOT(writer io.Writer) error {
+	if _, errWrite := io.WriteString(writer, "digraph works {\n"); errWrite != nil {
+		return fmt.Errorf("%w\n%w", ErrWriteGraph, errWrite)
+	}
+
+	for _, node := range graph.Nodes {
+		style := "solid"
+		if !graph.IsScraped(node) {
+			style = "dashed"
+		}
+
+		if _, errWrite := fmt.Fprintf(writer, "  %s [style=%s];\n", strconv.Quote(node), style); errWrite != nil {
+			return fmt.Errorf("%w\n%w", ErrWriteGraph, errWrite)
+		}
+	}
+
+	for _, edge := range graph.Edges {
+		if _, errWrite := fmt.Fprintf(writer, "  %s -> %s [label=%s];\n", strconv.Quote(edge.Source), strconv.Quote(edge.Target),
+			strconv.Quote(edge.Type.String())); errWrite != nil {
+			return fmt.Errorf("%w\n%w", ErrWriteGraph, errWrite)
+		}
+	}
+
+	if _, errWrite := io.WriteString(writer, "}\n"); errWrite != nil {
+		return fmt.Errorf("%w\n%w", ErrWriteGraph, errWrite)
+	}
+
+	return nil
+}
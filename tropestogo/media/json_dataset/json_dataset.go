@@ -5,7 +5,11 @@ import (
 	"errors"
 	"fmt"
 	"github.com/jlgallego99/TropesToGo/media"
+	"github.com/jlgallego99/TropesToGo/media/dataset_file"
+	"github.com/jlgallego99/TropesToGo/trope"
 	"os"
+	"strconv"
+	"sync"
 	"time"
 )
 
@@ -22,21 +26,46 @@ var (
 	ErrParseTime       = errors.New("error parsing the timestamp string from the dataset")
 )
 
-const timeLayout = "2006-01-02 15:04:05"
+const (
+	timeLayout = "2006-01-02 15:04:05"
+
+	// emptyDataset are the contents of a JSON dataset without records
+	emptyDataset = "{\"tropestogo\": []}"
+)
 
 // JSONDataset is an intermediate structure for marshaling/unmarshalling data from the JSON dataset
 type JSONDataset struct {
 	Tropestogo []media.JsonResponse `json:"tropestogo"`
 }
 
+// mediaDataset is an intermediate structure for marshaling the JSON dataset from Media objects, which are marshalled as JsonResponse objects
+type mediaDataset struct {
+	Tropestogo []media.Media `json:"tropestogo"`
+}
+
 // JSONRepository implements the RepositoryMedia for creating and handling JSON datasets of all the scraped data on TvTropes
 // It has an internal data structure of Media objects for better performance that can be persisted into a file all in one go
+// It's safe to use by several goroutines at the same time
+// The dataset file is always written atomically, and the repository holds its lock from its first write until it's closed,
+// so no other process can write the same dataset at the same time
 type JSONRepository struct {
 	// name of the file dataset
 	name string
 
 	// data is the intermediate dataset added here before persisting it all at once
 	data []media.Media
+
+	// index holds the key of every Media object on data, for detecting the duplicated ones
+	index map[media.MediaKey]struct{}
+
+	// lock is the lock of the dataset file, held from the first write on the dataset until the repository is closed
+	lock *dataset_file.Lock
+
+	// backedUp is set when the dataset file has been backed up before the first destructive write of the repository
+	backedUp bool
+
+	// mutex guards the intermediate dataset and the dataset file
+	mutex sync.Mutex
 }
 
 // Error formats a generic error
@@ -50,20 +79,23 @@ func Error(message string, err error, subErr error) error {
 
 // NewJSONRepository is the constructor for JSONRepository objects that handle JSON datasets
 // It receives the name that the JSON dataset file will have and creates the file with a "tropestogo" key with an empty array
-// It will return an ErrCreateJson error if the file couldn't be created
+// It will return an ErrCreateJson error if the file couldn't be created, or if another process is writing it
 func NewJSONRepository(name string) (*JSONRepository, error) {
+	repository := &JSONRepository{
+		name:  name + ".json",
+		index: make(map[media.MediaKey]struct{}),
+	}
+
 	// If the file doesn't exist, create it
-	if _, errStat := os.Stat(name + ".json"); errStat != nil {
-		f, errCreate := os.Create(name + ".json")
-		if errCreate != nil {
-			return nil, Error(name, ErrCreateJson, errCreate)
+	if _, errStat := os.Stat(repository.name); errStat != nil {
+		if errLock := repository.lockDataset(); errLock != nil {
+			return nil, Error(name, ErrCreateJson, errLock)
 		}
 
-		f.WriteString("{\"tropestogo\": []}")
-	}
-
-	repository := &JSONRepository{
-		name: name + ".json",
+		if errCreate := dataset_file.WriteFile(repository.name, []byte(emptyDataset)); errCreate != nil {
+			repository.Close()
+			return nil, Error(name, ErrCreateJson, errCreate)
+		}
 	}
 
 	return repository, nil
@@ -71,54 +103,63 @@ func NewJSONRepository(name string) (*JSONRepository, error) {
 
 // AddMedia adds a newMedia Media object to the in-memory dataset, so it can be later persisted
 // There can only be unique objects on the dataset, so it will return an ErrDuplicatedMedia error if the Media object already exists
+// Works with the same title and year but different media types are different Media objects
 func (repository *JSONRepository) AddMedia(newMedia media.Media) error {
-	for _, mediaData := range repository.data {
-		if mediaData.GetWork().Title == newMedia.GetWork().Title && mediaData.GetWork().Year == newMedia.GetWork().Year {
-			return Error("Title: "+newMedia.GetWork().Title, ErrDuplicatedMedia, nil)
-		}
+	repository.mutex.Lock()
+	defer repository.mutex.Unlock()
+
+	if _, exists := repository.index[newMedia.GetKey()]; exists {
+		return Error("Title: "+newMedia.GetWork().Title, ErrDuplicatedMedia, nil)
 	}
 
+	repository.index[newMedia.GetKey()] = struct{}{}
 	repository.data = append(repository.data, newMedia)
 
 	return nil
 }
 
-// UpdateMedia updates a record already written on the dataset by checking if it has the same title and year, because that differentiates a record
-// It returns an ErrReadJson, ErrWriteJson or an ErrUnmarshalJson error if the dataset couldn't be read, written or unmarshalled into a internal structure
+// UpdateMedia updates a record already written on the dataset by checking if it has the same title and year and the media type of updateMedia, because that differentiates a record
+// Every record is loaded back into a Media object, so the dataset is written again with all the fields of updateMedia on the updated one
+// The dataset file is backed up before the first update of the repository
+// It returns a media.ErrMediaNotFound error without writing the dataset if there's no such record,
+// an ErrReadJson or ErrWriteJson error if the dataset couldn't be read, backed up or written,
+// or an ErrUnmarshalJson error with the position of the first record that isn't a valid Media
 func (repository *JSONRepository) UpdateMedia(title string, year string, updateMedia media.Media) error {
-	var dataset JSONDataset
+	repository.mutex.Lock()
+	defer repository.mutex.Unlock()
 
-	fileContents, errReadDataset := os.ReadFile(repository.name)
-	if errReadDataset != nil {
-		return Error(repository.name, ErrReadJson, errReadDataset)
+	if errLock := repository.lockDataset(); errLock != nil {
+		return errLock
 	}
 
-	errUnmarshal := json.Unmarshal(fileContents, &dataset)
-	if errUnmarshal != nil {
-		return Error(repository.name, ErrUnmarshalJson, errUnmarshal)
+	datasetMedia, errMedia := repository.readMedia()
+	if errMedia != nil {
+		return errMedia
 	}
 
-	for pos, record := range dataset.Tropestogo {
-		if record.Title == title && record.Year == year {
-			tropes, subTropes := media.GetJsonTropes(updateMedia)
-			dataset.Tropestogo[pos].Title = updateMedia.GetWork().Title
-			dataset.Tropestogo[pos].Year = updateMedia.GetWork().Year
-			dataset.Tropestogo[pos].MediaType = updateMedia.GetMediaType().String()
-			dataset.Tropestogo[pos].LastUpdated = formatDate(updateMedia.GetWork().LastUpdated)
-			dataset.Tropestogo[pos].URL = updateMedia.GetPage().GetUrl().String()
-			dataset.Tropestogo[pos].Tropes = tropes
-			dataset.Tropestogo[pos].SubTropes = subTropes
-
+	updated := false
+	for pos, recordMedia := range datasetMedia {
+		if recordMedia.GetKey() == media.NewMediaKey(title, year, updateMedia.GetMediaType().String()) {
+			datasetMedia[pos] = updateMedia
+			updated = true
 			break
 		}
 	}
 
-	jsonBytes, err := json.Marshal(dataset)
+	if !updated {
+		return Error("Title: "+title+" Year: "+year, media.ErrMediaNotFound, nil)
+	}
+
+	jsonBytes, err := json.Marshal(mediaDataset{Tropestogo: datasetMedia})
 	if err != nil {
 		return Error("", ErrMarshalJson, err)
 	}
 
-	errWriteFile := os.WriteFile(repository.name, jsonBytes, 0644)
+	if errBackup := repository.backupDataset(); errBackup != nil {
+		return errBackup
+	}
+
+	errWriteFile := dataset_file.WriteFile(repository.name, jsonBytes)
 	if errWriteFile != nil {
 		return Error(repository.name, ErrWriteJson, errWriteFile)
 	}
@@ -127,21 +168,29 @@ func (repository *JSONRepository) UpdateMedia(title string, year string, updateM
 }
 
 // RemoveAll deletes all data on both the in-memory intermediate data and on the dataset file
-// It tries to recreate the dataset, so it will return an ErrCreateJson error if that wasn't possible
+// The dataset file is backed up before emptying it for the first time, and then it's recreated,
+// so it will return an ErrCreateJson error if that wasn't possible
 // If the dataset file doesn't exist, it returns an ErrFileNotExists error
 func (repository *JSONRepository) RemoveAll() error {
-	var err error
-	var f *os.File
+	repository.mutex.Lock()
+	defer repository.mutex.Unlock()
 
 	repository.data = []media.Media{}
+	repository.index = make(map[media.MediaKey]struct{})
+
+	if _, err := os.Stat(repository.name); err == nil {
+		if errLock := repository.lockDataset(); errLock != nil {
+			return errLock
+		}
 
-	if _, err = os.Stat(repository.name); err == nil {
-		f, err = os.Create(repository.name)
-		if err != nil {
-			return Error(repository.name, ErrCreateJson, err)
+		if errBackup := repository.backupDataset(); errBackup != nil {
+			return errBackup
+		}
+
+		if errCreate := dataset_file.WriteFile(repository.name, []byte(emptyDataset)); errCreate != nil {
+			return Error(repository.name, ErrCreateJson, errCreate)
 		}
 
-		f.WriteString("{\"tropestogo\": []}")
 		return nil
 	} else {
 		pwd, _ := os.Getwd()
@@ -155,10 +204,17 @@ func (repository *JSONRepository) RemoveAll() error {
 // If the internal data structure is empty, it will do nothing and return an ErrPersist error
 // It returns an ErrReadJson, ErrWriteJson or an ErrUnmarshalJson error if the dataset couldn't be read, written or unmarshalled into a internal structure
 func (repository *JSONRepository) Persist() error {
+	repository.mutex.Lock()
+	defer repository.mutex.Unlock()
+
 	if len(repository.data) == 0 {
 		return Error(repository.name, ErrPersist, nil)
 	}
 
+	if errLock := repository.lockDataset(); errLock != nil {
+		return errLock
+	}
+
 	var dataset JSONDataset
 
 	fileContents, errReadDataset := os.ReadFile(repository.name)
@@ -171,16 +227,13 @@ func (repository *JSONRepository) Persist() error {
 		return Error(repository.name, ErrUnmarshalJson, errUnmarshal)
 	}
 
-	for _, mediaData := range repository.data {
-		exists := false
-		for _, datasetMedia := range dataset.Tropestogo {
-			if datasetMedia.Title == mediaData.GetWork().Title && datasetMedia.Year == mediaData.GetWork().Year {
-				exists = true
-				break
-			}
-		}
+	datasetIndex := make(map[media.MediaKey]struct{}, len(dataset.Tropestogo))
+	for _, datasetMedia := range dataset.Tropestogo {
+		datasetIndex[media.NewMediaKey(datasetMedia.Title, datasetMedia.Year, datasetMedia.MediaType)] = struct{}{}
+	}
 
-		if !exists {
+	for _, mediaData := range repository.data {
+		if _, exists := datasetIndex[mediaData.GetKey()]; !exists {
 			tropes, subTropes := media.GetJsonTropes(mediaData)
 			record := media.JsonResponse{
 				Title:       mediaData.GetWork().Title,
@@ -190,6 +243,8 @@ func (repository *JSONRepository) Persist() error {
 				URL:         mediaData.GetPage().GetUrl().String(),
 				Tropes:      tropes,
 				SubTropes:   subTropes,
+				Metadata:    media.GetJsonMetadata(mediaData.GetMetadata()),
+				Relations:   media.GetJsonRelations(mediaData.GetRelations()),
 			}
 
 			dataset.Tropestogo = append(dataset.Tropestogo, record)
@@ -197,13 +252,14 @@ func (repository *JSONRepository) Persist() error {
 	}
 
 	repository.data = []media.Media{}
+	repository.index = make(map[media.MediaKey]struct{})
 
 	jsonBytes, err := json.Marshal(dataset)
 	if err != nil {
 		return Error("", ErrMarshalJson, err)
 	}
 
-	errWriteFile := os.WriteFile(repository.name, jsonBytes, 0644)
+	errWriteFile := dataset_file.WriteFile(repository.name, jsonBytes)
 	if errWriteFile != nil {
 		return Error(repository.name, ErrWriteJson, errWriteFile)
 	}
@@ -214,6 +270,9 @@ func (repository *JSONRepository) Persist() error {
 // GetWorkPages retrieves all persisted Work urls on the JSON dataset and the last time they were updated
 // Returns a map relating page URLs to the last time they were updated
 func (repository *JSONRepository) GetWorkPages() (map[string]time.Time, error) {
+	repository.mutex.Lock()
+	defer repository.mutex.Unlock()
+
 	var dataset JSONDataset
 	datasetPages := make(map[string]time.Time, 0)
 
@@ -239,7 +298,248 @@ func (repository *JSONRepository) GetWorkPages() (map[string]time.Time, error) {
 	return datasetPages, nil
 }
 
+// GetTropes retrieves the title of every trope and subtrope of the Works persisted on the JSON dataset
+// Returns a set of the trope titles, which are the IDs of their Main pages
+func (repository *JSONRepository) GetTropes() (map[string]struct{}, error) {
+	repository.mutex.Lock()
+	defer repository.mutex.Unlock()
+
+	var dataset JSONDataset
+	datasetTropes := make(map[string]struct{})
+
+	fileContents, errReadDataset := os.ReadFile(repository.name)
+	if errReadDataset != nil {
+		return nil, Error(repository.name, ErrReadJson, errReadDataset)
+	}
+
+	errUnmarshal := json.Unmarshal(fileContents, &dataset)
+	if errUnmarshal != nil {
+		return nil, Error(repository.name, ErrUnmarshalJson, errUnmarshal)
+	}
+
+	for _, record := range dataset.Tropestogo {
+		for _, jsonTrope := range append(record.Tropes, record.SubTropes...) {
+			datasetTropes[jsonTrope.Title] = struct{}{}
+		}
+	}
+
+	return datasetTropes, nil
+}
+
+// GetWorkRelations retrieves the relations with other Works of every Work persisted on the JSON dataset
+// Returns a map relating the URL of each Work to its relations
+func (repository *JSONRepository) GetWorkRelations() (map[string][]trope.Relation, error) {
+	repository.mutex.Lock()
+	defer repository.mutex.Unlock()
+
+	var dataset JSONDataset
+	datasetRelations := make(map[string][]trope.Relation)
+
+	fileContents, errReadDataset := os.ReadFile(repository.name)
+	if errReadDataset != nil {
+		return nil, Error(repository.name, ErrReadJson, errReadDataset)
+	}
+
+	errUnmarshal := json.Unmarshal(fileContents, &dataset)
+	if errUnmarshal != nil {
+		return nil, Error(repository.name, ErrUnmarshalJson, errUnmarshal)
+	}
+
+	for _, record := range dataset.Tropestogo {
+		relations, errRelations := media.ToRelations(record.Relations)
+		if errRelations != nil {
+			return nil, Error(repository.name, ErrUnmarshalJson, errRelations)
+		}
+
+		datasetRelations[record.URL] = relations
+	}
+
+	return datasetRelations, nil
+}
+
+// Close releases the lock of the dataset file if the repository holds it, so other processes can write it
+// The repository can still be used after closing it, and it will acquire the lock again on its next write
+func (repository *JSONRepository) Close() error {
+	repository.mutex.Lock()
+	defer repository.mutex.Unlock()
+
+	if repository.lock == nil {
+		return nil
+	}
+
+	errRelease := repository.lock.Release()
+	repository.lock = nil
+
+	return errRelease
+}
+
+// lockDataset acquires the lock of the dataset file if the repository doesn't hold it yet, and holds it until the repository is closed
+// The mutex must be held, and it returns an ErrWriteJson error with dataset_file.ErrLocked if another process is writing the dataset
+func (repository *JSONRepository) lockDataset() error {
+	if repository.lock != nil {
+		return nil
+	}
+
+	lock, errLock := dataset_file.AcquireLock(repository.name)
+	if errLock != nil {
+		return Error(repository.name, ErrWriteJson, errLock)
+	}
+
+	repository.lock = lock
+	return nil
+}
+
+// backupDataset backs up the dataset file before the first destructive write of the repository, so it's only backed up once
+// even if many records are updated. The mutex must be held, and it returns an ErrWriteJson error if it couldn't be backed up
+func (repository *JSONRepository) backupDataset() error {
+	if repository.backedUp {
+		return nil
+	}
+
+	if errBackup := dataset_file.Backup(repository.name); errBackup != nil {
+		return Error(repository.name, ErrWriteJson, errBackup)
+	}
+
+	repository.backedUp = true
+	return nil
+}
+
 // formatDate transforms a date to a unified string format across all datasets
 func formatDate(date time.Time) string {
 	return date.Format(timeLayout)
 }
+
+// GetMediaByURL retrieves the Media of the Work page with the url persisted on the JSON dataset
+// It returns a media.ErrMediaNotFound error if there's none, or an ErrReadJson or ErrUnmarshalJson error if the dataset couldn't be read
+func (repository *JSONRepository) GetMediaByURL(url string) (media.Media, error) {
+	repository.mutex.Lock()
+	defer repository.mutex.Unlock()
+
+	datasetMedia, errMedia := repository.readMedia()
+	if errMedia != nil {
+		return media.Media{}, errMedia
+	}
+
+	for _, recordMedia := range datasetMedia {
+		if recordMedia.GetPage().GetUrl().String() == url {
+			return recordMedia, nil
+		}
+	}
+
+	return media.Media{}, Error(url, media.ErrMediaNotFound, nil)
+}
+
+// GetMedia retrieves the Media with the title and year persisted on the JSON dataset
+// It returns a media.ErrMediaNotFound error if there's none, or an ErrReadJson or ErrUnmarshalJson error if the dataset couldn't be read
+func (repository *JSONRepository) GetMedia(title, year string) (media.Media, error) {
+	repository.mutex.Lock()
+	defer repository.mutex.Unlock()
+
+	datasetMedia, errMedia := repository.readMedia()
+	if errMedia != nil {
+		return media.Media{}, errMedia
+	}
+
+	for _, recordMedia := range datasetMedia {
+		if recordMedia.GetWork().Title == title && recordMedia.GetWork().Year == year {
+			return recordMedia, nil
+		}
+	}
+
+	return media.Media{}, Error("Title: "+title+" Year: "+year, media.ErrMediaNotFound, nil)
+}
+
+// ForEachMedia calls handleMedia with every Media persisted on the JSON dataset, in the order they are on the file
+// It stops at the first error handleMedia returns and returns it, or returns an ErrReadJson or ErrUnmarshalJson error if the dataset couldn't be read
+func (repository *JSONRepository) ForEachMedia(handleMedia func(media.Media) error) error {
+	repository.mutex.Lock()
+	datasetMedia, errMedia := repository.readMedia()
+	repository.mutex.Unlock()
+
+	if errMedia != nil {
+		return errMedia
+	}
+
+	for _, recordMedia := range datasetMedia {
+		if errHandle := handleMedia(recordMedia); errHandle != nil {
+			return errHandle
+		}
+	}
+
+	return nil
+}
+
+// GetMediaWithTrope retrieves every Media persisted on the JSON dataset that has the trope with the title as a trope or a subtrope
+// It returns an ErrReadJson or ErrUnmarshalJson error if the dataset couldn't be read
+func (repository *JSONRepository) GetMediaWithTrope(title string) ([]media.Media, error) {
+	repository.mutex.Lock()
+	defer repository.mutex.Unlock()
+
+	datasetMedia, errMedia := repository.readMedia()
+	if errMedia != nil {
+		return nil, errMedia
+	}
+
+	mediaWithTrope := make([]media.Media, 0)
+	for _, recordMedia := range datasetMedia {
+		if recordMedia.HasTrope(title) {
+			mediaWithTrope = append(mediaWithTrope, recordMedia)
+		}
+	}
+
+	return mediaWithTrope, nil
+}
+
+// CountMedia returns the number of Media records persisted on the JSON dataset
+// It returns an ErrReadJson or ErrUnmarshalJson error if the dataset couldn't be read
+func (repository *JSONRepository) CountMedia() (int, error) {
+	repository.mutex.Lock()
+	defer repository.mutex.Unlock()
+
+	var dataset struct {
+		Tropestogo []json.RawMessage `json:"tropestogo"`
+	}
+
+	fileContents, errReadDataset := os.ReadFile(repository.name)
+	if errReadDataset != nil {
+		return 0, Error(repository.name, ErrReadJson, errReadDataset)
+	}
+
+	errUnmarshal := json.Unmarshal(fileContents, &dataset)
+	if errUnmarshal != nil {
+		return 0, Error(repository.name, ErrUnmarshalJson, errUnmarshal)
+	}
+
+	return len(dataset.Tropestogo), nil
+}
+
+// readMedia reads every record persisted on the JSON dataset and unmarshals it into a Media object, in the order they are on the file
+// It returns an ErrReadJson error if the dataset couldn't be read, or an ErrUnmarshalJson error with the position of the first record
+// that isn't a valid Media
+func (repository *JSONRepository) readMedia() ([]media.Media, error) {
+	var dataset struct {
+		Tropestogo []json.RawMessage `json:"tropestogo"`
+	}
+
+	fileContents, errReadDataset := os.ReadFile(repository.name)
+	if errReadDataset != nil {
+		return nil, Error(repository.name, ErrReadJson, errReadDataset)
+	}
+
+	errUnmarshal := json.Unmarshal(fileContents, &dataset)
+	if errUnmarshal != nil {
+		return nil, Error(repository.name, ErrUnmarshalJson, errUnmarshal)
+	}
+
+	datasetMedia := make([]media.Media, 0, len(dataset.Tropestogo))
+	for pos, rawRecord := range dataset.Tropestogo {
+		var recordMedia media.Media
+		if errMedia := json.Unmarshal(rawRecord, &recordMedia); errMedia != nil {
+			return nil, Error(repository.name+" record "+strconv.Itoa(pos+1), ErrUnmarshalJson, errMedia)
+		}
+
+		datasetMedia = append(datasetMedia, recordMedia)
+	}
+
+	return datasetMedia, nil
+}
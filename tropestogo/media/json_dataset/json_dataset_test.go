@@ -1,6 +1,7 @@
 package json_dataset_test
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -8,10 +9,14 @@ import (
 	"github.com/jlgallego99/TropesToGo/tvtropespages"
 	"math/rand"
 	"os"
+	"path/filepath"
+	"sync"
 	"time"
 
 	"github.com/jlgallego99/TropesToGo/media"
+	"github.com/jlgallego99/TropesToGo/media/dataset_file"
 	"github.com/jlgallego99/TropesToGo/media/json_dataset"
+	"github.com/jlgallego99/TropesToGo/media/repository_conformance"
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
 )
@@ -41,8 +46,11 @@ var _ = BeforeSuite(func() {
 		tropes[subTrope] = struct{}{}
 	}
 
-	tvTropesPage, _ := tvtropespages.NewPage(oldboyUrl, false, nil)
+	tvTropesPage, _ := tvtropespages.NewPage(context.Background(), oldboyUrl, false, nil)
 	mediaEntry, _ = media.NewMedia("Oldboy", "2003", time.Now(), tropes, tvTropesPage, media.Film)
+	remake, _ := trope.NewRelation("https://tvtropes.org/pmwiki/pmwiki.php/Film/Oldboy2013", "English-language remake",
+		"An English-language remake was released on November 27, 2013.", trope.AdaptationRelation)
+	mediaEntry.AddRelations(remake)
 })
 
 var _ = Describe("JsonDataset", func() {
@@ -54,6 +62,7 @@ var _ = Describe("JsonDataset", func() {
 	AfterEach(func() {
 		// Reset file
 		repository.RemoveAll()
+		repository.Close()
 	})
 
 	Context("Create JSON repository", func() {
@@ -164,7 +173,7 @@ var _ = Describe("JsonDataset", func() {
 				newTropes[subTrope] = struct{}{}
 			}
 
-			tvTropesPage, _ := tvtropespages.NewPage(oldboyUrl, false, nil)
+			tvTropesPage, _ := tvtropespages.NewPage(context.Background(), oldboyUrl, false, nil)
 			updatedMediaEntry, _ := media.NewMedia("Oldboy", "2013", time.Now(), newTropes, tvTropesPage, media.Film)
 
 			errUpdate = repository.UpdateMedia("Oldboy", "2003", updatedMediaEntry)
@@ -174,9 +183,81 @@ var _ = Describe("JsonDataset", func() {
 			correctRecord()
 		})
 
+		It("Should have backed up the JSON file before updating it", func() {
+			var backup json_dataset.JSONDataset
+			fileContents, errRead := os.ReadFile("dataset.json" + dataset_file.BackupSuffix)
+			Expect(errRead).To(BeNil())
+			Expect(json.Unmarshal(fileContents, &backup)).To(Succeed())
+
+			Expect(backup.Tropestogo).To(HaveLen(1))
+			Expect(backup.Tropestogo[0].Year).To(Equal("2003"))
+		})
+
+		It("Shouldn't return an error", func() {
+			Expect(errUpdate).To(BeNil())
+			Expect(errPersist).To(BeNil())
+		})
+	})
+
+	Context("Update the metadata and relations of a Film in the JSON file", func() {
+		var errUpdate error
+
+		BeforeEach(func() {
+			errAddMedia = repository.AddMedia(mediaEntry)
+			Expect(errAddMedia).To(BeNil())
+			errPersist = repository.Persist()
+			Expect(errPersist).To(BeNil())
+
+			tvTropesPage, _ := tvtropespages.NewPage(context.Background(), oldboyUrl, false, nil)
+			updatedMediaEntry, _ := media.NewMedia("Oldboy", "2003", time.Now(), tropes, tvTropesPage, media.Film)
+			updatedMediaEntry.SetMetadata(trope.WorkMetadata{Description: []string{"A man is imprisoned for fifteen years."}, Creators: []string{"Park Chan-wook"}})
+			sequel, _ := trope.NewRelation("https://tvtropes.org/pmwiki/pmwiki.php/Film/SympathyForLadyVengeance", "Lady Vengeance", "", trope.SequelRelation)
+			updatedMediaEntry.AddRelations(sequel)
+
+			errUpdate = repository.UpdateMedia("Oldboy", "2003", updatedMediaEntry)
+		})
+
 		It("Shouldn't return an error", func() {
 			Expect(errUpdate).To(BeNil())
+		})
+
+		It("Should load the record back with its new metadata and relations", func() {
+			updatedMedia, errGet := repository.GetMedia("Oldboy", "2003")
+
+			Expect(errGet).To(BeNil())
+			Expect(updatedMedia.GetMetadata().Description).To(Equal([]string{"A man is imprisoned for fifteen years."}))
+			Expect(updatedMedia.GetMetadata().Creators).To(Equal([]string{"Park Chan-wook"}))
+			Expect(updatedMedia.GetRelations()).To(HaveLen(1))
+			Expect(updatedMedia.GetRelations()[0].Type).To(Equal(trope.SequelRelation))
+		})
+	})
+
+	Context("Read a JSON file with an invalid record", func() {
+		var errUpdate, errGet error
+
+		BeforeEach(func() {
+			errAddMedia = repository.AddMedia(mediaEntry)
+			Expect(errAddMedia).To(BeNil())
+			errPersist = repository.Persist()
 			Expect(errPersist).To(BeNil())
+
+			// The second record has a trope on an index that doesn't exist
+			dataset, _ := readDataset()
+			invalidRecord := dataset.Tropestogo[0]
+			invalidRecord.Title, invalidRecord.Tropes = "Oldboy", []media.JsonTrope{{Title: "RevengeTragedy", Indexes: []string{"NotAnIndex"}}}
+			invalidRecord.Year = "2013"
+			dataset.Tropestogo = append(dataset.Tropestogo, invalidRecord)
+			jsonBytes, _ := json.Marshal(dataset)
+			Expect(os.WriteFile("dataset.json", jsonBytes, 0644)).To(Succeed())
+			errUpdate = repository.UpdateMedia("Oldboy", "2003", mediaEntry)
+			_, errGet = repository.GetMedia("Oldboy", "2003")
+		})
+
+		It("Should report which record isn't valid", func() {
+			Expect(errors.Is(errUpdate, json_dataset.ErrUnmarshalJson)).To(BeTrue())
+			Expect(errors.Is(errUpdate, media.ErrInvalidRecord)).To(BeTrue())
+			Expect(errUpdate.Error()).To(ContainSubstring("record 2"))
+			Expect(errors.Is(errGet, media.ErrInvalidRecord)).To(BeTrue())
 		})
 	})
 
@@ -199,6 +280,54 @@ var _ = Describe("JsonDataset", func() {
 		})
 	})
 
+	Context("Add Media from several goroutines at the same time", func() {
+		const addingGoroutines = 20
+		var addErrors []error
+
+		BeforeEach(func() {
+			addErrors = make([]error, addingGoroutines)
+			var adding sync.WaitGroup
+			for i := 0; i < addingGoroutines; i++ {
+				adding.Add(1)
+				go func(i int) {
+					defer adding.Done()
+
+					// Half of the goroutines add the same Media and the other half a different Media each
+					addedMedia := mediaEntry
+					if i%2 == 1 {
+						tvTropesPage, _ := tvtropespages.NewPage(context.Background(), oldboyUrl+fmt.Sprint(i), false, nil)
+						addedMedia, _ = media.NewMedia("Oldboy"+fmt.Sprint(i), "2003", time.Now(), tropes, tvTropesPage, media.Film)
+					}
+
+					addErrors[i] = repository.AddMedia(addedMedia)
+				}(i)
+			}
+			adding.Wait()
+
+			errPersist = repository.Persist()
+		})
+
+		It("Should only add each Media once", func() {
+			duplicated := 0
+			for _, errAdd := range addErrors {
+				if errAdd != nil {
+					Expect(errors.Is(errAdd, json_dataset.ErrDuplicatedMedia)).To(BeTrue())
+					duplicated++
+				}
+			}
+
+			Expect(duplicated).To(Equal(addingGoroutines/2 - 1))
+			Expect(errPersist).To(BeNil())
+		})
+
+		It("Should persist every different Media", func() {
+			dataset, err := readDataset()
+
+			Expect(err).To(BeNil())
+			Expect(dataset.Tropestogo).To(HaveLen(addingGoroutines/2 + 1))
+		})
+	})
+
 	Context("Get all the URLs of the persisted Media and its last updated time", func() {
 		var workPages map[string]time.Time
 		var errGetWorkPages error
@@ -223,11 +352,68 @@ var _ = Describe("JsonDataset", func() {
 			}
 		})
 	})
+
+	Context("Get all the tropes of the persisted Media", func() {
+		var datasetTropes map[string]struct{}
+		var errGetTropes error
+
+		BeforeEach(func() {
+			errAddMedia = repository.AddMedia(mediaEntry)
+			Expect(errAddMedia).To(BeNil())
+			errPersist = repository.Persist()
+			Expect(errPersist).To(BeNil())
+
+			datasetTropes, errGetTropes = repository.GetTropes()
+		})
+
+		It("Shouldn't return an error", func() {
+			Expect(errGetTropes).To(BeNil())
+		})
+
+		It("Should return the title of every trope and subtrope", func() {
+			Expect(datasetTropes).To(HaveLen(len(tropes)))
+			for mediaTrope := range tropes {
+				Expect(datasetTropes).To(HaveKey(mediaTrope.GetTitle()))
+			}
+		})
+	})
+
+	Context("Get the relations of the persisted Media", func() {
+		var datasetRelations map[string][]trope.Relation
+		var errGetRelations error
+
+		BeforeEach(func() {
+			errAddMedia = repository.AddMedia(mediaEntry)
+			Expect(errAddMedia).To(BeNil())
+			errPersist = repository.Persist()
+			Expect(errPersist).To(BeNil())
+
+			datasetRelations, errGetRelations = repository.GetWorkRelations()
+		})
+
+		It("Shouldn't return an error", func() {
+			Expect(errGetRelations).To(BeNil())
+		})
+
+		It("Should return the relations of every Work by its URL", func() {
+			Expect(datasetRelations).To(HaveLen(1))
+			Expect(datasetRelations[oldboyUrl]).To(Equal(mediaEntry.GetRelations()))
+		})
+	})
 })
 
+var _ = repository_conformance.DescribeRepository("JSON", func(name string) (media.RepositoryMedia, error) {
+	return json_dataset.NewJSONRepository(name)
+}, repository_conformance.Features{Indexes: true, Examples: true, Metadata: true})
+
 var _ = AfterSuite(func() {
 	datasetFile.Close()
 	os.Remove("dataset.json")
+
+	backups, _ := filepath.Glob("dataset.json" + dataset_file.BackupSuffix + "*")
+	for _, backup := range backups {
+		os.Remove(backup)
+	}
 })
 
 // correctRecord checks if a JSON record has something strange and no errors
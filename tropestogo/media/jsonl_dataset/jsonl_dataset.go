@@ -0,0 +1,824 @@
+package jsonl_dataset
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/jlgallego99/TropesToGo/media"
+	"github.com/jlgallego99/TropesToGo/media/dataset_file"
+	"github.com/jlgallego99/TropesToGo/trope"
+)
+
+var (
+	ErrFileNotExists   = errors.New("JSON Lines dataset file does not exist")
+	ErrDuplicatedMedia = errors.New("duplicated media, the record already exists on the dataset")
+	ErrReadJsonl       = errors.New("error reading JSON Lines file")
+	ErrCreateJsonl     = errors.New("error creating JSON Lines file")
+	ErrWriteJsonl      = errors.New("error writing on the JSON Lines file")
+	ErrUnmarshalJsonl  = errors.New("error unmarshalling JSON Lines record")
+	ErrMarshalJsonl    = errors.New("error marshalling JSON Lines record")
+	ErrPersist         = errors.New("can't persist data on the JSON Lines file because there's none")
+	ErrParseTime       = errors.New("error parsing the timestamp string from the dataset")
+	ErrStream          = errors.New("the JSON Lines dataset is being streamed, so it can't be read nor updated")
+)
+
+// errStopReading is returned by the functions that handle the records of the dataset to stop reading it before its end
+var errStopReading = errors.New("stop reading the JSON Lines dataset")
+
+const (
+	timeLayout = "2006-01-02 15:04:05"
+
+	// IndexSuffix is appended to the name of the dataset file to form the name of its offset index file
+	IndexSuffix = ".idx"
+)
+
+// IndexEntry is a line of the offset index file, which locates the record of a Work on the dataset file
+// Entries are only appended, so the last entry of a Work is the valid one, and an entry with a negative offset removes the Work from the index
+type IndexEntry struct {
+	Title     string `json:"title"`
+	Year      string `json:"year"`
+	MediaType string `json:"media_type"`
+	Offset    int64  `json:"offset"`
+	Length    int64  `json:"length"`
+}
+
+// recordLocation is where the record of a Work is on the dataset file, with Length counting the final newline
+type recordLocation struct {
+	Offset int64
+	Length int64
+}
+
+// JSONLRepository implements the RepositoryMedia for creating and handling JSON Lines datasets of all the scraped data on TvTropes
+// Every Work is a JsonResponse object on its own line, so persisting only appends the new records to the file without reading it
+// An offset index file next to the dataset locates every record for the duplicate checks and for reading them one by one
+// It can also stream the records to a writer like the standard output, in which case it can't read them back
+// It's safe to use by several goroutines at the same time
+// New records are appended and synced before their index entries, so an interrupted write is detected and discarded on the next load,
+// and the rest of the writes replace the files atomically. The repository holds the lock of the dataset from its first write until it's closed,
+// so no other process can write the same dataset at the same time
+type JSONLRepository struct {
+	// name of the file dataset, empty if the records are streamed
+	name string
+
+	// stream is the writer where the records are streamed instead of being written on a file
+	stream io.Writer
+
+	// data is the intermediate dataset added here before persisting it all at once
+	data []media.Media
+
+	// index holds the key of every Media object on data, for detecting the duplicated ones
+	index map[media.MediaKey]struct{}
+
+	// locations holds where the record of every persisted Work is, as read from the offset index file
+	locations map[media.MediaKey]recordLocation
+
+	// lock is the lock of the dataset file, held from the first write on the dataset until the repository is closed
+	lock *dataset_file.Lock
+
+	// backedUp is set when the dataset file has been backed up before the first destructive write of the repository
+	backedUp bool
+
+	// mutex guards the intermediate dataset, the locations and the dataset files
+	mutex sync.Mutex
+}
+
+// Error formats a generic error
+func Error(message string, err error, subErr error) error {
+	if subErr != nil {
+		return fmt.Errorf("%w: "+message+"\n%w", err, subErr)
+	} else {
+		return fmt.Errorf("%w: "+message+"", err)
+	}
+}
+
+// NewJSONLRepository is the constructor for JSONLRepository objects that handle JSON Lines datasets
+// It receives the name that the JSON Lines dataset file will have and creates it if it doesn't exist
+// The offset index file is loaded, and rebuilt from the dataset file if it's missing or doesn't match it
+// It will return an ErrCreateJsonl error if the files couldn't be created or an ErrReadJsonl error if they couldn't be read
+func NewJSONLRepository(name string) (*JSONLRepository, error) {
+	repository := &JSONLRepository{
+		name:  name + ".jsonl",
+		index: make(map[media.MediaKey]struct{}),
+	}
+
+	// If the file doesn't exist, create it
+	if _, errStat := os.Stat(repository.name); errStat != nil {
+		if errLock := repository.lockDataset(); errLock != nil {
+			return nil, Error(name, ErrCreateJsonl, errLock)
+		}
+
+		if errCreate := dataset_file.WriteFile(repository.name, nil); errCreate != nil {
+			repository.Close()
+			return nil, Error(name, ErrCreateJsonl, errCreate)
+		}
+	}
+
+	if errIndex := repository.loadIndex(); errIndex != nil {
+		repository.Close()
+		return nil, errIndex
+	}
+
+	return repository, nil
+}
+
+// NewJSONLStreamRepository is the constructor for JSONLRepository objects that stream the JSON Lines dataset to the stream writer
+// Persisted records can't be read back nor updated, so duplicate checks only cover the records streamed by this repository
+func NewJSONLStreamRepository(stream io.Writer) *JSONLRepository {
+	return &JSONLRepository{
+		stream:    stream,
+		index:     make(map[media.MediaKey]struct{}),
+		locations: make(map[media.MediaKey]recordLocation),
+	}
+}
+
+// AddMedia adds a newMedia Media object to the in-memory dataset, so it can be later persisted
+// There can only be unique objects on the dataset, so it will return an ErrDuplicatedMedia error if the Media object already exists
+// Works with the same title and year but different media types are different Media objects
+func (repository *JSONLRepository) AddMedia(newMedia media.Media) error {
+	repository.mutex.Lock()
+	defer repository.mutex.Unlock()
+
+	if _, exists := repository.index[newMedia.GetKey()]; exists {
+		return Error("Title: "+newMedia.GetWork().Title, ErrDuplicatedMedia, nil)
+	}
+
+	repository.index[newMedia.GetKey()] = struct{}{}
+	repository.data = append(repository.data, newMedia)
+
+	return nil
+}
+
+// UpdateMedia updates a record already written on the dataset by checking if it has the same title and year and the media type of updateMedia, because that differentiates a record
+// The dataset file is written again with the new record on the line of the old one, and the offset index file with the shifted locations
+// of the records after it. The dataset file is backed up before the first update of the repository
+// It returns a media.ErrMediaNotFound error without writing the dataset if there's no such record, an ErrStream error if the dataset is being streamed,
+// and an ErrWriteJsonl or ErrMarshalJsonl error if the record couldn't be written
+func (repository *JSONLRepository) UpdateMedia(title string, year string, updateMedia media.Media) error {
+	repository.mutex.Lock()
+	defer repository.mutex.Unlock()
+
+	if repository.stream != nil {
+		return Error("Title: "+title, ErrStream, nil)
+	}
+
+	if errLock := repository.lockDataset(); errLock != nil {
+		return errLock
+	}
+
+	oldKey := media.NewMediaKey(title, year, updateMedia.GetMediaType().String())
+	location, found := repository.locations[oldKey]
+	if !found {
+		return Error("Title: "+title+" Year: "+year, media.ErrMediaNotFound, nil)
+	}
+
+	record, errMarshal := marshalRecord(updateMedia)
+	if errMarshal != nil {
+		return Error(repository.name, ErrMarshalJsonl, errMarshal)
+	}
+
+	if errBackup := repository.backupDataset(); errBackup != nil {
+		return errBackup
+	}
+
+	datasetFile, errOpen := os.Open(repository.name)
+	if errOpen != nil {
+		return Error(repository.name, ErrReadJsonl, errOpen)
+	}
+	defer datasetFile.Close()
+
+	// The records before and after the old line are copied around the new one
+	errReplace := dataset_file.Replace(repository.name, func(writer io.Writer) error {
+		if _, errCopy := io.Copy(writer, io.NewSectionReader(datasetFile, 0, location.Offset)); errCopy != nil {
+			return errCopy
+		}
+
+		if _, errWrite := writer.Write(record); errWrite != nil {
+			return errWrite
+		}
+
+		_, errCopy := io.Copy(writer, io.NewSectionReader(datasetFile, location.Offset+location.Length, 1<<62))
+		return errCopy
+	})
+	if errReplace != nil {
+		return Error(repository.name, ErrWriteJsonl, errReplace)
+	}
+
+	shift := int64(len(record)) - location.Length
+	for key, keyLocation := range repository.locations {
+		if keyLocation.Offset > location.Offset {
+			repository.locations[key] = recordLocation{Offset: keyLocation.Offset + shift, Length: keyLocation.Length}
+		}
+	}
+
+	delete(repository.locations, oldKey)
+	repository.locations[updateMedia.GetKey()] = recordLocation{Offset: location.Offset, Length: int64(len(record))}
+
+	return repository.writeIndex()
+}
+
+// RemoveAll deletes all data on both the in-memory intermediate data and on the dataset and offset index files
+// The dataset file is backed up before emptying it for the first time
+// If the dataset file doesn't exist, it returns an ErrFileNotExists error, and an ErrCreateJsonl error if the files couldn't be emptied
+func (repository *JSONLRepository) RemoveAll() error {
+	repository.mutex.Lock()
+	defer repository.mutex.Unlock()
+
+	repository.data = []media.Media{}
+	repository.index = make(map[media.MediaKey]struct{})
+	repository.locations = make(map[media.MediaKey]recordLocation)
+
+	if repository.stream != nil {
+		return nil
+	}
+
+	if _, errStat := os.Stat(repository.name); errStat != nil {
+		pwd, _ := os.Getwd()
+
+		return Error("at "+pwd+"/"+repository.name, ErrFileNotExists, nil)
+	}
+
+	if errLock := repository.lockDataset(); errLock != nil {
+		return errLock
+	}
+
+	if errBackup := repository.backupDataset(); errBackup != nil {
+		return errBackup
+	}
+
+	for _, fileName := range []string{repository.name, repository.name + IndexSuffix} {
+		if errTruncate := dataset_file.WriteFile(fileName, nil); errTruncate != nil {
+			return Error(fileName, ErrCreateJsonl, errTruncate)
+		}
+	}
+
+	return nil
+}
+
+// Persist appends all intermediate Media data as lines of the dataset file, or writes them on the stream, and empties the structure, because it has already been persisted
+// It checks with the offset index whether the new records are already on the dataset file, but doesn't return an error, but simply skips them
+// If the internal data structure is empty, it will do nothing and return an ErrPersist error
+// It returns an ErrWriteJsonl or ErrMarshalJsonl error if the records couldn't be written
+func (repository *JSONLRepository) Persist() error {
+	repository.mutex.Lock()
+	defer repository.mutex.Unlock()
+
+	if len(repository.data) == 0 {
+		return Error(repository.name, ErrPersist, nil)
+	}
+
+	if errLock := repository.lockDataset(); errLock != nil {
+		return errLock
+	}
+
+	var records bytes.Buffer
+	var entries []IndexEntry
+	var end int64
+	if repository.stream == nil {
+		datasetInfo, errStat := os.Stat(repository.name)
+		if errStat != nil {
+			return Error(repository.name, ErrWriteJsonl, errStat)
+		}
+		end = datasetInfo.Size()
+	}
+
+	for _, mediaData := range repository.data {
+		key := mediaData.GetKey()
+		if _, exists := repository.locations[key]; exists {
+			continue
+		}
+
+		record, errMarshal := marshalRecord(mediaData)
+		if errMarshal != nil {
+			return Error(repository.name, ErrMarshalJsonl, errMarshal)
+		}
+
+		location := recordLocation{Offset: end + int64(records.Len()), Length: int64(len(record))}
+		repository.locations[key] = location
+		entries = append(entries, IndexEntry{Title: key.Title, Year: key.Year, MediaType: key.MediaType, Offset: location.Offset, Length: location.Length})
+		records.Write(record)
+	}
+
+	repository.data = []media.Media{}
+	repository.index = make(map[media.MediaKey]struct{})
+
+	if repository.stream != nil {
+		if _, errWrite := repository.stream.Write(records.Bytes()); errWrite != nil {
+			return Error("stream", ErrWriteJsonl, errWrite)
+		}
+
+		return nil
+	}
+
+	datasetFile, errOpen := os.OpenFile(repository.name, os.O_WRONLY|os.O_APPEND, 0644)
+	if errOpen != nil {
+		return Error(repository.name, ErrWriteJsonl, errOpen)
+	}
+	defer datasetFile.Close()
+
+	if _, errWrite := datasetFile.Write(records.Bytes()); errWrite != nil {
+		return Error(repository.name, ErrWriteJsonl, errWrite)
+	}
+
+	// The records are synced before the index entries are appended, so the index never locates a record that isn't on the dataset
+	if errSync := datasetFile.Sync(); errSync != nil {
+		return Error(repository.name, ErrWriteJsonl, errSync)
+	}
+
+	return repository.appendIndex(entries)
+}
+
+// GetWorkPages retrieves all persisted Work urls on the JSON Lines dataset and the last time they were updated
+// Returns a map relating page URLs to the last time they were updated
+func (repository *JSONLRepository) GetWorkPages() (map[string]time.Time, error) {
+	repository.mutex.Lock()
+	defer repository.mutex.Unlock()
+
+	datasetPages := make(map[string]time.Time, 0)
+	errRead := repository.readRecords(func(record media.JsonResponse) error {
+		lastUpdated, errLastUpdated := time.Parse(timeLayout, record.LastUpdated)
+		if errLastUpdated != nil {
+			return Error(repository.name, ErrParseTime, errLastUpdated)
+		}
+
+		datasetPages[record.URL] = lastUpdated
+		return nil
+	})
+	if errRead != nil {
+		return nil, errRead
+	}
+
+	return datasetPages, nil
+}
+
+// GetTropes retrieves the title of every trope and subtrope of the Works persisted on the JSON Lines dataset
+// Returns a set of the trope titles, which are the IDs of their Main pages
+func (repository *JSONLRepository) GetTropes() (map[string]struct{}, error) {
+	repository.mutex.Lock()
+	defer repository.mutex.Unlock()
+
+	datasetTropes := make(map[string]struct{})
+	errRead := repository.readRecords(func(record media.JsonResponse) error {
+		for _, jsonTrope := range append(record.Tropes, record.SubTropes...) {
+			datasetTropes[jsonTrope.Title] = struct{}{}
+		}
+
+		return nil
+	})
+	if errRead != nil {
+		return nil, errRead
+	}
+
+	return datasetTropes, nil
+}
+
+// GetWorkRelations retrieves the relations with other Works of every Work persisted on the JSON Lines dataset
+// Returns a map relating the URL of each Work to its relations
+func (repository *JSONLRepository) GetWorkRelations() (map[string][]trope.Relation, error) {
+	repository.mutex.Lock()
+	defer repository.mutex.Unlock()
+
+	datasetRelations := make(map[string][]trope.Relation)
+	errRead := repository.readRecords(func(record media.JsonResponse) error {
+		relations, errRelations := media.ToRelations(record.Relations)
+		if errRelations != nil {
+			return Error(repository.name, ErrUnmarshalJsonl, errRelations)
+		}
+
+		datasetRelations[record.URL] = relations
+		return nil
+	})
+	if errRead != nil {
+		return nil, errRead
+	}
+
+	return datasetRelations, nil
+}
+
+// GetMediaByURL retrieves the Media of the Work page with the url persisted on the JSON Lines dataset
+// It returns a media.ErrMediaNotFound error if there's none, an ErrStream error if the dataset is being streamed,
+// or an ErrReadJsonl or ErrUnmarshalJsonl error if it couldn't be read
+func (repository *JSONLRepository) GetMediaByURL(url string) (media.Media, error) {
+	repository.mutex.Lock()
+	defer repository.mutex.Unlock()
+
+	var urlMedia media.Media
+	found := false
+	errRead := repository.readRecords(func(record media.JsonResponse) error {
+		if record.URL != url {
+			return nil
+		}
+
+		var errMedia error
+		urlMedia, errMedia = repository.toMedia(record)
+		if errMedia != nil {
+			return errMedia
+		}
+
+		found = true
+		return errStopReading
+	})
+	if errRead != nil && !errors.Is(errRead, errStopReading) {
+		return media.Media{}, errRead
+	}
+
+	if !found {
+		return media.Media{}, Error(url, media.ErrMediaNotFound, nil)
+	}
+
+	return urlMedia, nil
+}
+
+// GetMedia retrieves the Media with the title and year persisted on the JSON Lines dataset, reading only its record with the offset index
+// It returns a media.ErrMediaNotFound error if there's none, an ErrStream error if the dataset is being streamed,
+// or an ErrReadJsonl or ErrUnmarshalJsonl error if it couldn't be read
+func (repository *JSONLRepository) GetMedia(title, year string) (media.Media, error) {
+	repository.mutex.Lock()
+	defer repository.mutex.Unlock()
+
+	if repository.stream != nil {
+		return media.Media{}, Error("Title: "+title, ErrStream, nil)
+	}
+
+	// Records are updated in place, so the one with the lowest offset is the first one that was added
+	var location recordLocation
+	found := false
+	for key, keyLocation := range repository.locations {
+		if key.Title == title && key.Year == year && (!found || keyLocation.Offset < location.Offset) {
+			location, found = keyLocation, true
+		}
+	}
+
+	if !found {
+		return media.Media{}, Error("Title: "+title+" Year: "+year, media.ErrMediaNotFound, nil)
+	}
+
+	datasetFile, errOpen := os.Open(repository.name)
+	if errOpen != nil {
+		return media.Media{}, Error(repository.name, ErrReadJsonl, errOpen)
+	}
+	defer datasetFile.Close()
+
+	line := make([]byte, location.Length)
+	if _, errReadLine := datasetFile.ReadAt(line, location.Offset); errReadLine != nil {
+		return media.Media{}, Error(repository.name, ErrReadJsonl, errReadLine)
+	}
+
+	var record media.JsonResponse
+	if errUnmarshal := json.Unmarshal(line, &record); errUnmarshal != nil {
+		return media.Media{}, Error(repository.name, ErrUnmarshalJsonl, errUnmarshal)
+	}
+
+	return repository.toMedia(record)
+}
+
+// ForEachMedia calls handleMedia with every Media persisted on the JSON Lines dataset, in the order of its lines
+// Records are read one at a time, so handleMedia is called while the repository is locked
+// It stops at the first error handleMedia returns and returns it, or returns an ErrStream error if the dataset is being streamed,
+// or an ErrReadJsonl or ErrUnmarshalJsonl error if it couldn't be read
+func (repository *JSONLRepository) ForEachMedia(handleMedia func(media.Media) error) error {
+	repository.mutex.Lock()
+	defer repository.mutex.Unlock()
+
+	return repository.readRecords(func(record media.JsonResponse) error {
+		recordMedia, errMedia := repository.toMedia(record)
+		if errMedia != nil {
+			return errMedia
+		}
+
+		return handleMedia(recordMedia)
+	})
+}
+
+// GetMediaWithTrope retrieves every Media persisted on the JSON Lines dataset that has the trope with the title as a trope or a subtrope
+// It returns an ErrStream error if the dataset is being streamed, or an ErrReadJsonl or ErrUnmarshalJsonl error if it couldn't be read
+func (repository *JSONLRepository) GetMediaWithTrope(title string) ([]media.Media, error) {
+	repository.mutex.Lock()
+	defer repository.mutex.Unlock()
+
+	mediaWithTrope := make([]media.Media, 0)
+	errRead := repository.readRecords(func(record media.JsonResponse) error {
+		if !record.HasTrope(title) {
+			return nil
+		}
+
+		recordMedia, errMedia := repository.toMedia(record)
+		if errMedia != nil {
+			return errMedia
+		}
+
+		mediaWithTrope = append(mediaWithTrope, recordMedia)
+		return nil
+	})
+	if errRead != nil {
+		return nil, errRead
+	}
+
+	return mediaWithTrope, nil
+}
+
+// CountMedia returns the number of Media records persisted on the JSON Lines dataset, which are the ones on the offset index
+// It returns an ErrStream error if the dataset is being streamed
+func (repository *JSONLRepository) CountMedia() (int, error) {
+	repository.mutex.Lock()
+	defer repository.mutex.Unlock()
+
+	if repository.stream != nil {
+		return 0, Error("stream", ErrStream, nil)
+	}
+
+	return len(repository.locations), nil
+}
+
+// toMedia transforms a record of the JSON Lines dataset back into a Media object
+// It returns an ErrUnmarshalJsonl error if the record isn't a valid Media
+func (repository *JSONLRepository) toMedia(record media.JsonResponse) (media.Media, error) {
+	recordMedia, errMedia := media.ToMedia(record)
+	if errMedia != nil {
+		return media.Media{}, Error(repository.name, ErrUnmarshalJsonl, errMedia)
+	}
+
+	return recordMedia, nil
+}
+
+// readRecords reads the dataset file line by line, calling handleRecord with every record, so only one of them is in memory at a time
+// Blank lines, left by updated records, are skipped
+// It returns an ErrStream error if the dataset is being streamed, an ErrReadJsonl or ErrUnmarshalJsonl error if it couldn't be read,
+// or the error of handleRecord
+func (repository *JSONLRepository) readRecords(handleRecord func(media.JsonResponse) error) error {
+	if repository.stream != nil {
+		return Error("stream", ErrStream, nil)
+	}
+
+	datasetFile, errOpen := os.Open(repository.name)
+	if errOpen != nil {
+		return Error(repository.name, ErrReadJsonl, errOpen)
+	}
+	defer datasetFile.Close()
+
+	reader := bufio.NewReader(datasetFile)
+	for {
+		line, errLine := reader.ReadBytes('\n')
+		if len(bytes.TrimSpace(line)) > 0 {
+			var record media.JsonResponse
+			if errUnmarshal := json.Unmarshal(line, &record); errUnmarshal != nil {
+				return Error(repository.name, ErrUnmarshalJsonl, errUnmarshal)
+			}
+
+			if errHandle := handleRecord(record); errHandle != nil {
+				return errHandle
+			}
+		}
+
+		if errors.Is(errLine, io.EOF) {
+			return nil
+		} else if errLine != nil {
+			return Error(repository.name, ErrReadJsonl, errLine)
+		}
+	}
+}
+
+// loadIndex reads the locations of the records from the offset index file
+// If the index file is missing or doesn't match the end of the dataset file, because the last write was interrupted,
+// the locations are read from the dataset file instead and the index file is written again, unless another process is writing the dataset,
+// in which case the files are left as they are
+func (repository *JSONLRepository) loadIndex() error {
+	repository.locations = make(map[media.MediaKey]recordLocation)
+
+	datasetInfo, errStat := os.Stat(repository.name)
+	if errStat != nil {
+		return Error(repository.name, ErrReadJsonl, errStat)
+	}
+
+	indexFile, errOpen := os.Open(repository.name + IndexSuffix)
+	if errOpen == nil {
+		var end int64
+		decoder := json.NewDecoder(indexFile)
+		for decoder.More() {
+			var entry IndexEntry
+			if errDecode := decoder.Decode(&entry); errDecode != nil {
+				break
+			}
+
+			key := media.NewMediaKey(entry.Title, entry.Year, entry.MediaType)
+			if entry.Offset < 0 {
+				delete(repository.locations, key)
+				continue
+			}
+
+			repository.locations[key] = recordLocation{Offset: entry.Offset, Length: entry.Length}
+			if entry.Offset+entry.Length > end {
+				end = entry.Offset + entry.Length
+			}
+		}
+		indexFile.Close()
+
+		if end == datasetInfo.Size() {
+			return nil
+		}
+	}
+
+	errLock := repository.lockDataset()
+	if errLock != nil && !errors.Is(errLock, dataset_file.ErrLocked) {
+		return errLock
+	}
+
+	return repository.rebuildIndex(errLock == nil)
+}
+
+// rebuildIndex reads the locations of the records from the dataset file, and if write is set, writes the offset index file again with them
+// and discards the last line of the dataset file if its write was interrupted
+func (repository *JSONLRepository) rebuildIndex(write bool) error {
+	repository.locations = make(map[media.MediaKey]recordLocation)
+
+	datasetFile, errOpen := os.Open(repository.name)
+	if errOpen != nil {
+		return Error(repository.name, ErrReadJsonl, errOpen)
+	}
+	defer datasetFile.Close()
+
+	var entries []IndexEntry
+	var offset int64
+	reader := bufio.NewReader(datasetFile)
+	for {
+		line, errLine := reader.ReadBytes('\n')
+
+		// A last line without a newline is a record whose write was interrupted, so the dataset is written again without it
+		if errors.Is(errLine, io.EOF) {
+			if write && len(line) > 0 {
+				errTruncate := dataset_file.Replace(repository.name, func(writer io.Writer) error {
+					_, errCopy := io.Copy(writer, io.NewSectionReader(datasetFile, 0, offset))
+					return errCopy
+				})
+				if errTruncate != nil {
+					return Error(repository.name, ErrWriteJsonl, errTruncate)
+				}
+			}
+
+			break
+		} else if errLine != nil {
+			return Error(repository.name, ErrReadJsonl, errLine)
+		}
+
+		if len(bytes.TrimSpace(line)) > 0 {
+			var entry IndexEntry
+			if errUnmarshal := json.Unmarshal(line, &entry); errUnmarshal != nil {
+				return Error(repository.name, ErrUnmarshalJsonl, errUnmarshal)
+			}
+
+			entry.Offset, entry.Length = offset, int64(len(line))
+			repository.locations[media.NewMediaKey(entry.Title, entry.Year, entry.MediaType)] = recordLocation{Offset: entry.Offset, Length: entry.Length}
+			entries = append(entries, entry)
+		}
+
+		offset += int64(len(line))
+	}
+
+	if !write {
+		return nil
+	}
+
+	return repository.writeIndex()
+}
+
+// writeIndex writes the offset index file again with the locations of the records, in the order they are on the dataset file
+func (repository *JSONLRepository) writeIndex() error {
+	entries := make([]IndexEntry, 0, len(repository.locations))
+	for key, location := range repository.locations {
+		entries = append(entries, IndexEntry{Title: key.Title, Year: key.Year, MediaType: key.MediaType, Offset: location.Offset, Length: location.Length})
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Offset < entries[j].Offset
+	})
+
+	lines, errEncode := encodeIndex(entries)
+	if errEncode != nil {
+		return errEncode
+	}
+
+	if errWrite := dataset_file.WriteFile(repository.name+IndexSuffix, lines); errWrite != nil {
+		return Error(repository.name+IndexSuffix, ErrWriteJsonl, errWrite)
+	}
+
+	return nil
+}
+
+// appendIndex appends the entries to the offset index file and syncs it
+func (repository *JSONLRepository) appendIndex(entries []IndexEntry) error {
+	lines, errEncode := encodeIndex(entries)
+	if errEncode != nil {
+		return errEncode
+	}
+
+	indexFile, errOpen := os.OpenFile(repository.name+IndexSuffix, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if errOpen != nil {
+		return Error(repository.name+IndexSuffix, ErrWriteJsonl, errOpen)
+	}
+	defer indexFile.Close()
+
+	if _, errWrite := indexFile.Write(lines); errWrite != nil {
+		return Error(repository.name+IndexSuffix, ErrWriteJsonl, errWrite)
+	}
+
+	if errSync := indexFile.Sync(); errSync != nil {
+		return Error(repository.name+IndexSuffix, ErrWriteJsonl, errSync)
+	}
+
+	return nil
+}
+
+// encodeIndex encodes the entries as lines of the offset index file
+func encodeIndex(entries []IndexEntry) ([]byte, error) {
+	var lines bytes.Buffer
+	encoder := json.NewEncoder(&lines)
+	for _, entry := range entries {
+		if errEncode := encoder.Encode(entry); errEncode != nil {
+			return nil, Error(IndexSuffix, ErrMarshalJsonl, errEncode)
+		}
+	}
+
+	return lines.Bytes(), nil
+}
+
+// Close releases the lock of the dataset file if the repository holds it, so other processes can write it
+// The repository can still be used after closing it, and it will acquire the lock again on its next write
+func (repository *JSONLRepository) Close() error {
+	repository.mutex.Lock()
+	defer repository.mutex.Unlock()
+
+	if repository.lock == nil {
+		return nil
+	}
+
+	errRelease := repository.lock.Release()
+	repository.lock = nil
+
+	return errRelease
+}
+
+// lockDataset acquires the lock of the dataset file if the repository doesn't hold it yet, and holds it until the repository is closed
+// Streamed datasets aren't locked. The mutex must be held, and it returns an ErrWriteJsonl error with dataset_file.ErrLocked
+// if another process is writing the dataset
+func (repository *JSONLRepository) lockDataset() error {
+	if repository.lock != nil || repository.stream != nil {
+		return nil
+	}
+
+	lock, errLock := dataset_file.AcquireLock(repository.name)
+	if errLock != nil {
+		return Error(repository.name, ErrWriteJsonl, errLock)
+	}
+
+	repository.lock = lock
+	return nil
+}
+
+// backupDataset backs up the dataset file before the first destructive write of the repository, so it's only backed up once
+// even if many records are updated. The offset index isn't backed up, because it's rebuilt from the dataset when it doesn't match it.
+// The mutex must be held, and it returns an ErrWriteJsonl error if it couldn't be backed up
+func (repository *JSONLRepository) backupDataset() error {
+	if repository.backedUp {
+		return nil
+	}
+
+	if errBackup := dataset_file.Backup(repository.name); errBackup != nil {
+		return Error(repository.name, ErrWriteJsonl, errBackup)
+	}
+
+	repository.backedUp = true
+	return nil
+}
+
+// marshalRecord transforms a Media object into its JsonResponse line, ended with a newline
+func marshalRecord(mediaData media.Media) ([]byte, error) {
+	tropes, subTropes := media.GetJsonTropes(mediaData)
+	record, errMarshal := json.Marshal(media.JsonResponse{
+		Title:       mediaData.GetWork().Title,
+		Year:        mediaData.GetWork().Year,
+		MediaType:   mediaData.GetMediaType().String(),
+		LastUpdated: formatDate(mediaData.GetWork().LastUpdated),
+		URL:         mediaData.GetPage().GetUrl().String(),
+		Tropes:      tropes,
+		SubTropes:   subTropes,
+		Metadata:    media.GetJsonMetadata(mediaData.GetMetadata()),
+		Relations:   media.GetJsonRelations(mediaData.GetRelations()),
+	})
+	if errMarshal != nil {
+		return nil, errMarshal
+	}
+
+	return append(record, '\n'), nil
+}
+
+// formatDate transforms a date to a unified string format across all datasets
+func formatDate(date time.Time) string {
+	return date.Format(timeLayout)
+}
@@ -0,0 +1,13 @@
+package jsonl_dataset_test
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func TestJsonlDataset(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "JsonlDataset Suite")
+}
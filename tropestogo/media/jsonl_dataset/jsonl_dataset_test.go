@@ -0,0 +1,548 @@
+package jsonl_dataset_test
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jlgallego99/TropesToGo/media"
+	"github.com/jlgallego99/TropesToGo/media/dataset_file"
+	"github.com/jlgallego99/TropesToGo/media/jsonl_dataset"
+	"github.com/jlgallego99/TropesToGo/media/repository_conformance"
+	"github.com/jlgallego99/TropesToGo/trope"
+	"github.com/jlgallego99/TropesToGo/tvtropespages"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+const (
+	oldboyUrl        = "https://tvtropes.org/pmwiki/pmwiki.php/Film/Oldboy2003"
+	ladyVengeanceUrl = "https://tvtropes.org/pmwiki/pmwiki.php/Film/LadyVengeance"
+	randomMax        = 10
+	randomMin        = 2
+)
+
+var repository *jsonl_dataset.JSONLRepository
+var errorRepository, errRemoveAll, errAddMedia, errPersist error
+var mediaEntry media.Media
+var tropes map[trope.Trope]struct{}
+var numTropes int
+
+var seededRand = rand.New(rand.NewSource(time.Now().UnixNano()))
+
+var _ = BeforeSuite(func() {
+	numTropes = seededRand.Intn(randomMax-randomMin) + randomMin
+
+	tropes = createTropes(numTropes, randomTrope)
+	subTropes := createTropes(numTropes, randomSubTrope)
+	for subTrope := range subTropes {
+		tropes[subTrope] = struct{}{}
+	}
+
+	tvTropesPage, _ := tvtropespages.NewPage(context.Background(), oldboyUrl, false, nil)
+	mediaEntry, _ = media.NewMedia("Oldboy", "2003", time.Now(), tropes, tvTropesPage, media.Film)
+	remake, _ := trope.NewRelation("https://tvtropes.org/pmwiki/pmwiki.php/Film/Oldboy2013", "English-language remake",
+		"An English-language remake was released on November 27, 2013.", trope.AdaptationRelation)
+	mediaEntry.AddRelations(remake)
+})
+
+var _ = Describe("JsonlDataset", func() {
+	BeforeEach(func() {
+		repository, errorRepository = jsonl_dataset.NewJSONLRepository("dataset")
+	})
+
+	AfterEach(func() {
+		// Reset file
+		repository.RemoveAll()
+		repository.Close()
+	})
+
+	Context("Create JSON Lines repository", func() {
+		BeforeEach(func() {
+			errPersist = repository.Persist()
+		})
+
+		It("Should have created an empty JSON Lines file", func() {
+			Expect("dataset.jsonl").To(BeAnExistingFile())
+			Expect(readDataset()).To(BeEmpty())
+		})
+
+		It("Shouldn't return an error", func() {
+			Expect(errorRepository).To(BeNil())
+		})
+
+		It("Shouldn't be able to persist anything", func() {
+			Expect(errors.Is(errPersist, jsonl_dataset.ErrPersist)).To(BeTrue())
+		})
+	})
+
+	Context("Add a Media to the JSON Lines file", func() {
+		BeforeEach(func() {
+			errAddMedia = repository.AddMedia(mediaEntry)
+			errPersist = repository.Persist()
+		})
+
+		It("Should have all the correct fields", func() {
+			correctRecord("2003")
+		})
+
+		It("Should have an entry on the offset index file", func() {
+			Expect(readIndex()).To(HaveLen(1))
+		})
+
+		It("Shouldn't return an error", func() {
+			Expect(errAddMedia).To(BeNil())
+			Expect(errPersist).To(BeNil())
+		})
+	})
+
+	Context("Add duplicated Media to the JSON Lines file", func() {
+		BeforeEach(func() {
+			errAddMedia = repository.AddMedia(mediaEntry)
+			errAddMedia = repository.AddMedia(mediaEntry)
+			errPersist = repository.Persist()
+		})
+
+		It("Should only be one record on the JSON Lines file", func() {
+			Expect(readDataset()).To(HaveLen(1))
+		})
+
+		It("Should return an error", func() {
+			Expect(errors.Is(errAddMedia, jsonl_dataset.ErrDuplicatedMedia)).To(BeTrue())
+			Expect(errPersist).To(BeNil())
+		})
+	})
+
+	Context("Remove JSON Lines file contents", func() {
+		BeforeEach(func() {
+			errAddMedia = repository.AddMedia(mediaEntry)
+			errPersist = repository.Persist()
+			errRemoveAll = repository.RemoveAll()
+		})
+
+		It("Should still exist a JSON Lines file", func() {
+			Expect("dataset.jsonl").To(BeAnExistingFile())
+		})
+
+		It("Should have no records nor index entries", func() {
+			Expect(readDataset()).To(BeEmpty())
+			Expect(readIndex()).To(BeEmpty())
+		})
+
+		It("Should have no errors", func() {
+			Expect(errRemoveAll).To(BeNil())
+		})
+	})
+
+	Context("Remove contents of JSON Lines file that doesn't exist", func() {
+		BeforeEach(func() {
+			os.Remove("dataset.jsonl")
+			errRemoveAll = repository.RemoveAll()
+		})
+
+		AfterEach(func() {
+			repository.Close()
+			repository, errorRepository = jsonl_dataset.NewJSONLRepository("dataset")
+		})
+
+		It("A JSON Lines file shouldn't exist", func() {
+			Expect("dataset.jsonl").To(Not(BeAnExistingFile()))
+		})
+
+		It("Should return an error", func() {
+			Expect(errors.Is(errRemoveAll, jsonl_dataset.ErrFileNotExists)).To(BeTrue())
+		})
+	})
+
+	Context("Update the Year, URL and tropes of a Film in the JSON Lines file", func() {
+		var errUpdate error
+		var newTropes map[trope.Trope]struct{}
+
+		updateMedia := func() {
+			tvTropesPage, _ := tvtropespages.NewPage(context.Background(), oldboyUrl, false, nil)
+			updatedMediaEntry, _ := media.NewMedia("Oldboy", "2013", time.Now(), newTropes, tvTropesPage, media.Film)
+
+			errUpdate = repository.UpdateMedia("Oldboy", "2003", updatedMediaEntry)
+		}
+
+		// The record after the updated one checks that its location is shifted on the offset index
+		BeforeEach(func() {
+			tvTropesPage, _ := tvtropespages.NewPage(context.Background(), ladyVengeanceUrl, false, nil)
+			nextMediaEntry, _ := media.NewMedia("Lady Vengeance", "2005", time.Now(), createTropes(1, randomTrope), tvTropesPage, media.Film)
+
+			errAddMedia = repository.AddMedia(mediaEntry)
+			errAddMedia = repository.AddMedia(nextMediaEntry)
+			errPersist = repository.Persist()
+		})
+
+		When("the updated record is shorter than the old one", func() {
+			BeforeEach(func() {
+				newTropes = createTropes(1, randomTrope)
+				updateMedia()
+			})
+
+			It("Should have the new record on the line of the old one", func() {
+				correctUpdate()
+			})
+
+			It("Should have backed up the JSON Lines file before updating it", func() {
+				backup := readRecords("dataset.jsonl" + dataset_file.BackupSuffix)
+				Expect(backup).To(HaveLen(2))
+				Expect(backup[0].Year).To(Equal("2003"))
+			})
+
+			It("Shouldn't return an error", func() {
+				Expect(errUpdate).To(BeNil())
+			})
+		})
+
+		When("the updated record is longer than the old one", func() {
+			BeforeEach(func() {
+				newTropes = createTropes(numTropes*10, randomTrope)
+				updateMedia()
+			})
+
+			It("Should have the new record on the line of the old one", func() {
+				correctUpdate()
+			})
+
+			It("Should find the updated record with a new repository", func() {
+				reopened, errReopen := jsonl_dataset.NewJSONLRepository("dataset")
+				Expect(errReopen).To(BeNil())
+				defer reopened.Close()
+
+				workPages, errWorkPages := reopened.GetWorkPages()
+				Expect(errWorkPages).To(BeNil())
+				Expect(workPages).To(HaveLen(2))
+
+				errAddMedia = reopened.AddMedia(mediaEntry)
+				errPersist = reopened.Persist()
+				Expect(readDataset()).To(HaveLen(3))
+			})
+
+			It("Shouldn't return an error", func() {
+				Expect(errUpdate).To(BeNil())
+			})
+		})
+	})
+
+	Context("Persist an already persisted before record", func() {
+		BeforeEach(func() {
+			// Persist first
+			errAddMedia = repository.AddMedia(mediaEntry)
+			errPersist = repository.Persist()
+
+			// Try to persist again the same Media, with a new repository that only has the offset index
+			repository.Close()
+			repository, errorRepository = jsonl_dataset.NewJSONLRepository("dataset")
+			errAddMedia = repository.AddMedia(mediaEntry)
+			errPersist = repository.Persist()
+		})
+
+		It("Should only be one Media record on the JSON Lines file", func() {
+			Expect(readDataset()).To(HaveLen(1))
+		})
+
+		It("Shouldn't return an error", func() {
+			Expect(errAddMedia).To(BeNil())
+			Expect(errPersist).To(BeNil())
+		})
+	})
+
+	Context("Open a JSON Lines file without its offset index", func() {
+		BeforeEach(func() {
+			errAddMedia = repository.AddMedia(mediaEntry)
+			errPersist = repository.Persist()
+
+			os.Remove("dataset.jsonl" + jsonl_dataset.IndexSuffix)
+
+			// The record of an interrupted write is discarded
+			datasetFile, _ := os.OpenFile("dataset.jsonl", os.O_WRONLY|os.O_APPEND, 0644)
+			datasetFile.WriteString(`{"title": "Interrupted`)
+			datasetFile.Close()
+
+			repository.Close()
+			repository, errorRepository = jsonl_dataset.NewJSONLRepository("dataset")
+			errAddMedia = repository.AddMedia(mediaEntry)
+			errPersist = repository.Persist()
+		})
+
+		It("Should rebuild the offset index from the JSON Lines file", func() {
+			Expect(errorRepository).To(BeNil())
+			Expect(readIndex()).To(HaveLen(1))
+		})
+
+		It("Should only be one Media record on the JSON Lines file", func() {
+			Expect(readDataset()).To(HaveLen(1))
+		})
+	})
+
+	Context("Stream the Media to a writer", func() {
+		var stream bytes.Buffer
+		var streamRepository *jsonl_dataset.JSONLRepository
+		var errWorkPages, errUpdate error
+
+		BeforeEach(func() {
+			stream.Reset()
+			streamRepository = jsonl_dataset.NewJSONLStreamRepository(&stream)
+			errAddMedia = streamRepository.AddMedia(mediaEntry)
+			errPersist = streamRepository.Persist()
+
+			_, errWorkPages = streamRepository.GetWorkPages()
+			errUpdate = streamRepository.UpdateMedia("Oldboy", "2003", mediaEntry)
+		})
+
+		It("Should write a line with the record", func() {
+			var record media.JsonResponse
+			Expect(strings.Count(stream.String(), "\n")).To(Equal(1))
+			Expect(json.Unmarshal(stream.Bytes(), &record)).To(Succeed())
+			Expect(record.URL).To(Equal(oldboyUrl))
+			Expect(errPersist).To(BeNil())
+		})
+
+		It("Shouldn't be able to read nor update the records", func() {
+			Expect(errors.Is(errWorkPages, jsonl_dataset.ErrStream)).To(BeTrue())
+			Expect(errors.Is(errUpdate, jsonl_dataset.ErrStream)).To(BeTrue())
+		})
+	})
+
+	Context("Add Media from several goroutines at the same time", func() {
+		const addingGoroutines = 20
+		var addErrors []error
+
+		BeforeEach(func() {
+			addErrors = make([]error, addingGoroutines)
+			var adding sync.WaitGroup
+			for i := 0; i < addingGoroutines; i++ {
+				adding.Add(1)
+				go func(i int) {
+					defer adding.Done()
+
+					// Half of the goroutines add the same Media and the other half a different Media each
+					addedMedia := mediaEntry
+					if i%2 == 1 {
+						tvTropesPage, _ := tvtropespages.NewPage(context.Background(), oldboyUrl+fmt.Sprint(i), false, nil)
+						addedMedia, _ = media.NewMedia("Oldboy"+fmt.Sprint(i), "2003", time.Now(), tropes, tvTropesPage, media.Film)
+					}
+
+					addErrors[i] = repository.AddMedia(addedMedia)
+				}(i)
+			}
+			adding.Wait()
+
+			errPersist = repository.Persist()
+		})
+
+		It("Should only add each Media once", func() {
+			duplicated := 0
+			for _, errAdd := range addErrors {
+				if errAdd != nil {
+					Expect(errors.Is(errAdd, jsonl_dataset.ErrDuplicatedMedia)).To(BeTrue())
+					duplicated++
+				}
+			}
+
+			Expect(duplicated).To(Equal(addingGoroutines/2 - 1))
+			Expect(errPersist).To(BeNil())
+		})
+
+		It("Should persist every different Media", func() {
+			Expect(readDataset()).To(HaveLen(addingGoroutines/2 + 1))
+		})
+	})
+
+	Context("Get all the URLs of the persisted Media and its last updated time", func() {
+		var workPages map[string]time.Time
+		var errGetWorkPages error
+
+		BeforeEach(func() {
+			errAddMedia = repository.AddMedia(mediaEntry)
+			Expect(errAddMedia).To(BeNil())
+			errPersist = repository.Persist()
+			Expect(errPersist).To(BeNil())
+
+			workPages, errGetWorkPages = repository.GetWorkPages()
+		})
+
+		It("Shouldn't return an error", func() {
+			Expect(errGetWorkPages).To(BeNil())
+		})
+
+		It("Should return an URL and its last updated time", func() {
+			Expect(workPages).To(HaveLen(1))
+			for workUrl, workLastUpdated := range workPages {
+				Expect(workUrl).To(Equal(oldboyUrl))
+				Expect(workLastUpdated).To(Not(Equal(time.Time{})))
+			}
+		})
+	})
+
+	Context("Get all the tropes of the persisted Media", func() {
+		var datasetTropes map[string]struct{}
+		var errGetTropes error
+
+		BeforeEach(func() {
+			errAddMedia = repository.AddMedia(mediaEntry)
+			Expect(errAddMedia).To(BeNil())
+			errPersist = repository.Persist()
+			Expect(errPersist).To(BeNil())
+
+			datasetTropes, errGetTropes = repository.GetTropes()
+		})
+
+		It("Shouldn't return an error", func() {
+			Expect(errGetTropes).To(BeNil())
+		})
+
+		It("Should return the title of every trope and subtrope", func() {
+			Expect(datasetTropes).To(HaveLen(len(tropes)))
+			for mediaTrope := range tropes {
+				Expect(datasetTropes).To(HaveKey(mediaTrope.GetTitle()))
+			}
+		})
+	})
+
+	Context("Get the relations of the persisted Media", func() {
+		var datasetRelations map[string][]trope.Relation
+		var errGetRelations error
+
+		BeforeEach(func() {
+			errAddMedia = repository.AddMedia(mediaEntry)
+			Expect(errAddMedia).To(BeNil())
+			errPersist = repository.Persist()
+			Expect(errPersist).To(BeNil())
+
+			datasetRelations, errGetRelations = repository.GetWorkRelations()
+		})
+
+		It("Shouldn't return an error", func() {
+			Expect(errGetRelations).To(BeNil())
+		})
+
+		It("Should return the relations of every Work by its URL", func() {
+			Expect(datasetRelations).To(HaveLen(1))
+			Expect(datasetRelations[oldboyUrl]).To(Equal(mediaEntry.GetRelations()))
+		})
+	})
+})
+
+var _ = repository_conformance.DescribeRepository("JSONL", func(name string) (media.RepositoryMedia, error) {
+	return jsonl_dataset.NewJSONLRepository(name)
+}, repository_conformance.Features{Indexes: true, Examples: true, Metadata: true})
+
+var _ = AfterSuite(func() {
+	os.Remove("dataset.jsonl")
+	os.Remove("dataset.jsonl" + jsonl_dataset.IndexSuffix)
+
+	backups, _ := filepath.Glob("dataset.jsonl" + dataset_file.BackupSuffix + "*")
+	for _, backup := range backups {
+		os.Remove(backup)
+	}
+})
+
+// correctRecord checks if the only JSON Lines record has the year and nothing strange
+func correctRecord(year string) {
+	dataset := readDataset()
+
+	Expect(errPersist).To(BeNil())
+	Expect(dataset).To(HaveLen(1))
+	Expect(dataset[0].Title).To(Equal("Oldboy"))
+	Expect(dataset[0].Year).To(Equal(year))
+	Expect(dataset[0].URL).To(Equal(oldboyUrl))
+	Expect(dataset[0].MediaType).To(Not(BeEmpty()))
+	Expect(len(dataset[0].Tropes) > 0).To(BeTrue())
+}
+
+// correctUpdate checks if the updated record is still the first one and the next record can be read with the offset index
+func correctUpdate() {
+	dataset := readDataset()
+
+	Expect(dataset).To(HaveLen(2))
+	Expect(countLines()).To(Equal(2))
+	Expect(dataset[0].Title).To(Equal("Oldboy"))
+	Expect(dataset[0].Year).To(Equal("2013"))
+	Expect(dataset[1].Title).To(Equal("Lady Vengeance"))
+
+	nextMedia, errGet := repository.GetMedia("Lady Vengeance", "2005")
+	Expect(errGet).To(BeNil())
+	Expect(nextMedia.GetPage().GetUrl().String()).To(Equal(ladyVengeanceUrl))
+}
+
+// readDataset reads every non blank line of the JSON Lines file as a record
+func readDataset() []media.JsonResponse {
+	return readRecords("dataset.jsonl")
+}
+
+// readRecords reads every non blank line of the name file as a record
+func readRecords(name string) []media.JsonResponse {
+	var dataset []media.JsonResponse
+	datasetFile, errOpen := os.Open(name)
+	Expect(errOpen).To(BeNil())
+	defer datasetFile.Close()
+
+	scanner := bufio.NewScanner(datasetFile)
+	scanner.Buffer(nil, 1<<24)
+	for scanner.Scan() {
+		if strings.TrimSpace(scanner.Text()) == "" {
+			continue
+		}
+
+		var record media.JsonResponse
+		Expect(json.Unmarshal(scanner.Bytes(), &record)).To(Succeed())
+		dataset = append(dataset, record)
+	}
+
+	return dataset
+}
+
+// countLines counts every line of the JSON Lines file, including the blank ones
+func countLines() int {
+	fileContents, _ := os.ReadFile("dataset.jsonl")
+	return bytes.Count(fileContents, []byte("\n"))
+}
+
+// readIndex reads the entries of the offset index file
+func readIndex() []jsonl_dataset.IndexEntry {
+	var entries []jsonl_dataset.IndexEntry
+	fileContents, _ := os.ReadFile("dataset.jsonl" + jsonl_dataset.IndexSuffix)
+	decoder := json.NewDecoder(bytes.NewReader(fileContents))
+	for decoder.More() {
+		var entry jsonl_dataset.IndexEntry
+		Expect(decoder.Decode(&entry)).To(Succeed())
+		entries = append(entries, entry)
+	}
+
+	return entries
+}
+
+// createTropes generates a map of numTropes size applying a callback function to all elements
+func createTropes(numTropes int, callback func() trope.Trope) map[trope.Trope]struct{} {
+	tropeset := make(map[trope.Trope]struct{}, numTropes)
+
+	for i := 0; i < numTropes; i++ {
+		tropeset[callback()] = struct{}{}
+	}
+
+	return tropeset
+}
+
+var randomTrope = func() trope.Trope {
+	trope, _ := trope.NewTrope("Trope"+fmt.Sprint(seededRand.Int()), 1, "")
+	return trope
+}
+
+var randomSubTrope = func() trope.Trope {
+	subWikis := []string{"SubWiki1", "SubWiki2"}
+	trope, _ := trope.NewTrope("Trope"+fmt.Sprint(seededRand.Int()), 1, subWikis[seededRand.Intn(1)])
+
+	return trope
+}
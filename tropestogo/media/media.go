@@ -1,6 +1,7 @@
 package media
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -15,6 +16,9 @@ var (
 	ErrMissingValues    = errors.New("one or more fields are missing")
 	ErrInvalidYear      = errors.New("year is invalid")
 	ErrUnknownMediaType = errors.New("unknown media type")
+	ErrUnknownTrope     = errors.New("the work doesn't have the trope")
+	ErrMediaNotFound    = errors.New("there's no media with that key on the dataset")
+	ErrInvalidRecord    = errors.New("the persisted record isn't a valid media")
 )
 
 // MediaType enumerates all supported Media types in TropesToGo
@@ -103,19 +107,54 @@ type Media struct {
 
 // JsonResponse is an object for marshaling/unmarshalling a single Media object in Json
 type JsonResponse struct {
-	Title       string      `json:"title"`
-	Year        string      `json:"year"`
-	MediaType   string      `json:"media_type"`
-	LastUpdated string      `json:"last_updated"`
-	URL         string      `json:"url"`
-	Tropes      []JsonTrope `json:"tropes"`
-	SubTropes   []JsonTrope `json:"sub_tropes"`
+	Title       string         `json:"title"`
+	Year        string         `json:"year"`
+	MediaType   string         `json:"media_type"`
+	LastUpdated string         `json:"last_updated"`
+	URL         string         `json:"url"`
+	Tropes      []JsonTrope    `json:"tropes"`
+	SubTropes   []JsonTrope    `json:"sub_tropes"`
+	Metadata    JsonMetadata   `json:"metadata"`
+	Relations   []JsonRelation `json:"relations"`
 }
 
-// JsonTrope is part of JsonResponse, and represent a trope with the index to which it belongs
+// JsonRelation is part of JsonResponse, and represent a link to another work with its text, the sentence where it is and the kind of relationship
+type JsonRelation struct {
+	URL        string `json:"url"`
+	AnchorText string `json:"anchor_text"`
+	Context    string `json:"context"`
+	Type       string `json:"type"`
+}
+
+// JsonMetadata is part of JsonResponse, and represent the information about the work on its page besides the tropes
+type JsonMetadata struct {
+	Description  []string `json:"description"`
+	Image        string   `json:"image"`
+	ImageCaption string   `json:"image_caption"`
+	Creators     []string `json:"creators"`
+	SeeAlso      []string `json:"see_also"`
+	Franchises   []string `json:"franchises"`
+}
+
+// JsonTrope is part of JsonResponse, and represent a trope with the indexes to which it belongs and its examples on the work
+// Tropes that aren't classified on any index don't have indexes
 type JsonTrope struct {
-	Title     string `json:"title"`
-	Namespace string `json:"namespace"`
+	Title     string        `json:"title"`
+	Namespace string        `json:"namespace"`
+	Indexes   []string      `json:"indexes,omitempty"`
+	Examples  []JsonExample `json:"examples,omitempty"`
+}
+
+// JsonExample is part of JsonTrope, and represent an example of a trope on the work along with the folder, header, subpage and position of its bullet
+type JsonExample struct {
+	Text       string   `json:"text"`
+	HTML       string   `json:"html"`
+	SubItems   []string `json:"sub_items"`
+	Spoilers   []string `json:"spoilers"`
+	Folder     string   `json:"folder"`
+	Header     string   `json:"header"`
+	SubpageURL string   `json:"subpage_url"`
+	Position   int      `json:"position"`
 }
 
 // MarshalJSON implements Marshaller interface for custom marshalling of Media objects
@@ -131,9 +170,29 @@ func (media Media) MarshalJSON() ([]byte, error) {
 		URL:         media.page.GetUrl().String(),
 		Tropes:      tropes,
 		SubTropes:   subTropes,
+		Metadata:    GetJsonMetadata(media.work.Metadata),
+		Relations:   GetJsonRelations(media.work.Relations),
 	})
 }
 
+// UnmarshalJSON implements Unmarshaler interface for custom unmarshalling of Media objects, reversing MarshalJSON
+// The JSON object is read as a JsonResponse and transformed back into a Media object with ToMedia
+// It returns an ErrInvalidRecord error if the JSON object doesn't have the fields of a JsonResponse or any of them isn't valid
+func (media *Media) UnmarshalJSON(data []byte) error {
+	var record JsonResponse
+	if errUnmarshal := json.Unmarshal(data, &record); errUnmarshal != nil {
+		return fmt.Errorf("%w\n%w", ErrInvalidRecord, errUnmarshal)
+	}
+
+	recordMedia, errMedia := ToMedia(record)
+	if errMedia != nil {
+		return errMedia
+	}
+
+	*media = recordMedia
+	return nil
+}
+
 // GetJsonTropes receives a media object and transforms it into a JsonTrope array with all its tropes for correct marshalling
 // Return two JsonTrope arrays, the first for the main tropes and the second for the sub tropes
 func GetJsonTropes(media Media) ([]JsonTrope, []JsonTrope) {
@@ -143,10 +202,12 @@ func GetJsonTropes(media Media) ([]JsonTrope, []JsonTrope) {
 		title := trope.GetTitle()
 		namespace := trope.GetSubpage()
 
-		if title != "" && namespace == "" /*&& index != "UnknownTropeIndex"*/ {
+		if title != "" && namespace == "" {
 			tropes = append(tropes, JsonTrope{
 				Title:     title,
 				Namespace: mediaType,
+				Indexes:   trope.GetIndexStrings(),
+				Examples:  GetJsonExamples(media.GetExamples(trope)),
 			})
 		}
 	}
@@ -155,10 +216,12 @@ func GetJsonTropes(media Media) ([]JsonTrope, []JsonTrope) {
 		title := subTrope.GetTitle()
 		namespace := subTrope.GetSubpage()
 
-		if title != "" && namespace != "" /*&& index != "UnknownTropeIndex"*/ {
+		if title != "" && namespace != "" {
 			subTropes = append(subTropes, JsonTrope{
 				Title:     title,
 				Namespace: namespace,
+				Indexes:   subTrope.GetIndexStrings(),
+				Examples:  GetJsonExamples(media.GetExamples(subTrope)),
 			})
 		}
 	}
@@ -166,6 +229,209 @@ func GetJsonTropes(media Media) ([]JsonTrope, []JsonTrope) {
 	return tropes, subTropes
 }
 
+// GetJsonExamples transforms the examples of a trope into a JsonExample array for correct marshalling
+func GetJsonExamples(examples []trope.Example) []JsonExample {
+	jsonExamples := make([]JsonExample, 0, len(examples))
+	for _, example := range examples {
+		jsonExamples = append(jsonExamples, JsonExample{
+			Text:       example.Text,
+			HTML:       example.HTML,
+			SubItems:   example.SubItems,
+			Spoilers:   example.Spoilers,
+			Folder:     example.Location.Folder,
+			Header:     example.Location.Header,
+			SubpageURL: example.Location.SubpageURL,
+			Position:   example.Location.Position,
+		})
+	}
+
+	return jsonExamples
+}
+
+// GetJsonMetadata transforms the metadata of a work into a JsonMetadata object for correct marshalling, with empty arrays instead of null ones
+func GetJsonMetadata(metadata trope.WorkMetadata) JsonMetadata {
+	return JsonMetadata{
+		Description:  nonNilStrings(metadata.Description),
+		Image:        metadata.Image,
+		ImageCaption: metadata.ImageCaption,
+		Creators:     nonNilStrings(metadata.Creators),
+		SeeAlso:      nonNilStrings(metadata.SeeAlso),
+		Franchises:   nonNilStrings(metadata.Franchises),
+	}
+}
+
+// GetJsonRelations transforms the relations of a work into a JsonRelation array for correct marshalling
+func GetJsonRelations(relations []trope.Relation) []JsonRelation {
+	jsonRelations := make([]JsonRelation, 0, len(relations))
+	for _, relation := range relations {
+		jsonRelations = append(jsonRelations, JsonRelation{
+			URL:        relation.URL,
+			AnchorText: relation.AnchorText,
+			Context:    relation.Context,
+			Type:       relation.Type.String(),
+		})
+	}
+
+	return jsonRelations
+}
+
+// ToRelations transforms the JsonRelation array of a persisted work back into its relations
+// It returns an ErrUnknownRelationType or ErrEmptyRelation error if any of them isn't valid
+func ToRelations(jsonRelations []JsonRelation) ([]trope.Relation, error) {
+	relations := make([]trope.Relation, 0, len(jsonRelations))
+	for _, jsonRelation := range jsonRelations {
+		relationType, errRelationType := trope.ToRelationType(jsonRelation.Type)
+		if errRelationType != nil {
+			return nil, fmt.Errorf("%w: "+jsonRelation.Type, errRelationType)
+		}
+
+		relation, errRelation := trope.NewRelation(jsonRelation.URL, jsonRelation.AnchorText, jsonRelation.Context, relationType)
+		if errRelation != nil {
+			return nil, errRelation
+		}
+
+		relations = append(relations, relation)
+	}
+
+	return relations, nil
+}
+
+// HasTrope checks if the persisted work has a trope or subtrope with the title
+func (record JsonResponse) HasTrope(title string) bool {
+	for _, jsonTrope := range record.Tropes {
+		if jsonTrope.Title == title {
+			return true
+		}
+	}
+
+	for _, jsonSubTrope := range record.SubTropes {
+		if jsonSubTrope.Title == title {
+			return true
+		}
+	}
+
+	return false
+}
+
+// ToMedia transforms the JsonResponse of a persisted work back into a Media object with its tropes, examples, metadata and relations
+// Main tropes don't have a subpage and sub tropes are on the subpage of their namespace. The first index of a trope is its main index
+// It returns an ErrInvalidRecord error with the reason if any of the fields of the record isn't valid
+func ToMedia(record JsonResponse) (Media, error) {
+	mediaType, errMediaType := ToMediaType(record.MediaType)
+	if errMediaType != nil {
+		return Media{}, fmt.Errorf("%w: "+record.Title+"\n%w", ErrInvalidRecord, errMediaType)
+	}
+
+	var lastUpdated time.Time
+	if record.LastUpdated != "" {
+		var errLastUpdated error
+		lastUpdated, errLastUpdated = time.Parse("2006-01-02 15:04:05", record.LastUpdated)
+		if errLastUpdated != nil {
+			return Media{}, fmt.Errorf("%w: "+record.Title+"\n%w", ErrInvalidRecord, errLastUpdated)
+		}
+	}
+
+	page, errPage := tvtropespages.NewPage(context.Background(), record.URL, false, nil)
+	if errPage != nil {
+		return Media{}, fmt.Errorf("%w: "+record.Title+"\n%w", ErrInvalidRecord, errPage)
+	}
+
+	tropes := make(map[trope.Trope]struct{})
+	examples := make(map[trope.Trope][]trope.Example)
+	for _, jsonTrope := range record.Tropes {
+		if errTrope := addJsonTrope(tropes, examples, jsonTrope, ""); errTrope != nil {
+			return Media{}, fmt.Errorf("%w: "+record.Title+"\n%w", ErrInvalidRecord, errTrope)
+		}
+	}
+
+	for _, jsonSubTrope := range record.SubTropes {
+		if jsonSubTrope.Namespace == "" {
+			return Media{}, fmt.Errorf("%w: "+record.Title+"\n%w: the subtrope "+jsonSubTrope.Title+" doesn't have a namespace", ErrInvalidRecord, ErrMissingValues)
+		}
+
+		if errSubTrope := addJsonTrope(tropes, examples, jsonSubTrope, jsonSubTrope.Namespace); errSubTrope != nil {
+			return Media{}, fmt.Errorf("%w: "+record.Title+"\n%w", ErrInvalidRecord, errSubTrope)
+		}
+	}
+
+	relations, errRelations := ToRelations(record.Relations)
+	if errRelations != nil {
+		return Media{}, fmt.Errorf("%w: "+record.Title+"\n%w", ErrInvalidRecord, errRelations)
+	}
+
+	newMedia, errMedia := NewMedia(record.Title, record.Year, lastUpdated, tropes, page, mediaType)
+	if errMedia != nil {
+		return Media{}, fmt.Errorf("%w: "+record.Title+"\n%w", ErrInvalidRecord, errMedia)
+	}
+
+	for tropeExample, tropeExamples := range examples {
+		newMedia.work.Examples[tropeExample] = tropeExamples
+	}
+
+	newMedia.SetMetadata(trope.WorkMetadata{
+		Description:  record.Metadata.Description,
+		Image:        record.Metadata.Image,
+		ImageCaption: record.Metadata.ImageCaption,
+		Creators:     record.Metadata.Creators,
+		SeeAlso:      record.Metadata.SeeAlso,
+		Franchises:   record.Metadata.Franchises,
+	})
+	newMedia.AddRelations(relations...)
+
+	return newMedia, nil
+}
+
+// addJsonTrope adds the trope of a persisted work on the subpage to the tropes set, and its examples to the examples of the work
+// The first of the indexes of the trope is its main index
+// It returns an error if the title of the trope is empty, any of its indexes isn't known or any of its examples doesn't have a text
+func addJsonTrope(tropes map[trope.Trope]struct{}, examples map[trope.Trope][]trope.Example, jsonTrope JsonTrope, subpage string) error {
+	indexes := make([]trope.TropeIndex, 0, len(jsonTrope.Indexes))
+	for _, indexString := range jsonTrope.Indexes {
+		index, errIndex := trope.ToTropeIndex(indexString)
+		if errIndex != nil {
+			return fmt.Errorf("%w: "+indexString, errIndex)
+		}
+
+		indexes = append(indexes, index)
+	}
+
+	mainIndex := trope.UnknownTropeIndex
+	if len(indexes) > 0 {
+		mainIndex, indexes = indexes[0], indexes[1:]
+	}
+
+	newTrope, errTrope := trope.NewTrope(jsonTrope.Title, mainIndex, subpage, indexes...)
+	if errTrope != nil {
+		return errTrope
+	}
+
+	tropes[newTrope] = struct{}{}
+	for _, jsonExample := range jsonTrope.Examples {
+		example, errExample := trope.NewExample(jsonExample.Text, jsonExample.HTML, jsonExample.SubItems, jsonExample.Spoilers, trope.Location{
+			Folder:     jsonExample.Folder,
+			Header:     jsonExample.Header,
+			SubpageURL: jsonExample.SubpageURL,
+			Position:   jsonExample.Position,
+		})
+		if errExample != nil {
+			return fmt.Errorf("%w: "+jsonTrope.Title, errExample)
+		}
+
+		examples[newTrope] = append(examples[newTrope], example)
+	}
+
+	return nil
+}
+
+// nonNilStrings returns an empty array instead of a nil one, so it's marshalled as an empty JSON array
+func nonNilStrings(values []string) []string {
+	if values == nil {
+		return make([]string, 0)
+	}
+
+	return values
+}
+
 // NewMedia is a factory that creates a Media aggregate with validations from a title, year, a set of all tropes, a page object and a media type object
 // It divides the tropes between main and secondary
 // It returns a correctly formed Media object and an error of type ErrMissingValues if the title or page are empty
@@ -207,6 +473,8 @@ func NewMedia(title, year string, lastUpdated time.Time, tropes map[trope.Trope]
 		LastUpdated: lastUpdated,
 		Tropes:      mainTropes,
 		SubTropes:   subTropes,
+		Examples:    make(map[trope.Trope][]trope.Example),
+		Relations:   make([]trope.Relation, 0),
 	}
 
 	return Media{
@@ -221,6 +489,61 @@ func (media Media) GetWork() *trope.Work {
 	return media.work
 }
 
+// AddExamples relates the examples with the tropeExample trope of the Work, after the examples it already has
+// It returns an ErrUnknownTrope error if the trope isn't one of the Tropes or SubTropes of the Work
+func (media Media) AddExamples(tropeExample trope.Trope, examples ...trope.Example) error {
+	_, isTrope := media.work.Tropes[tropeExample]
+	_, isSubTrope := media.work.SubTropes[tropeExample]
+	if !isTrope && !isSubTrope {
+		return fmt.Errorf("%w: "+tropeExample.GetTitle(), ErrUnknownTrope)
+	}
+
+	media.work.Examples[tropeExample] = append(media.work.Examples[tropeExample], examples...)
+	return nil
+}
+
+// GetExamples returns the examples of the tropeExample trope on the Work, which are empty if it doesn't have any
+func (media Media) GetExamples(tropeExample trope.Trope) []trope.Example {
+	return media.work.Examples[tropeExample]
+}
+
+// SetMetadata replaces the information about the Work besides its tropes with the metadata
+func (media Media) SetMetadata(metadata trope.WorkMetadata) {
+	media.work.Metadata = metadata
+}
+
+// GetMetadata returns the information about the Work besides its tropes
+func (media Media) GetMetadata() trope.WorkMetadata {
+	return media.work.Metadata
+}
+
+// AddRelations relates the Work with other Works, after the relations it already has
+func (media Media) AddRelations(relations ...trope.Relation) {
+	media.work.Relations = append(media.work.Relations, relations...)
+}
+
+// GetRelations returns the relations of the Work with other Works, which are empty if it doesn't have any
+func (media Media) GetRelations() []trope.Relation {
+	return media.work.Relations
+}
+
+// HasTrope checks if the Work has a trope or subtrope with the title, on any of its subpages
+func (media Media) HasTrope(title string) bool {
+	for workTrope := range media.work.Tropes {
+		if workTrope.GetTitle() == title {
+			return true
+		}
+	}
+
+	for workSubTrope := range media.work.SubTropes {
+		if workSubTrope.GetTitle() == title {
+			return true
+		}
+	}
+
+	return false
+}
+
 // GetPage returns the Page object that this media object manages
 func (media Media) GetPage() tvtropespages.Page {
 	return media.page
@@ -230,3 +553,20 @@ func (media Media) GetPage() tvtropespages.Page {
 func (media Media) GetMediaType() MediaType {
 	return media.mediaType
 }
+
+// MediaKey identifies a Media object on a dataset, because Works with the same title and year but different media types are different
+type MediaKey struct {
+	Title     string
+	Year      string
+	MediaType string
+}
+
+// NewMediaKey forms the key of a record of a dataset from its title, year and media type name
+func NewMediaKey(title, year, mediaType string) MediaKey {
+	return MediaKey{Title: title, Year: year, MediaType: mediaType}
+}
+
+// GetKey returns the key that identifies the media object on a dataset
+func (media Media) GetKey() MediaKey {
+	return NewMediaKey(media.work.Title, media.work.Year, media.mediaType.String())
+}
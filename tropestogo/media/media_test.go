@@ -1,6 +1,9 @@
 package media_test
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	trope "github.com/jlgallego99/TropesToGo/trope"
@@ -31,7 +34,7 @@ var _ = Describe("Media", func() {
 		tropes[trope2] = struct{}{}
 		lastUpdated = time.Now()
 
-		tvTropesPage, _ = tvtropespages.NewPage(avengersUrl, false, nil)
+		tvTropesPage, _ = tvtropespages.NewPage(context.Background(), avengersUrl, false, nil)
 	})
 
 	AfterEach(func() {
@@ -179,6 +182,232 @@ var _ = Describe("Media", func() {
 			})
 		})
 	})
+
+	Describe("Relate the tropes of a Media with their examples", func() {
+		var exampleMedia media.Media
+		var chekhovsGun trope.Trope
+		var errAddExamples, errUnknownTrope error
+
+		BeforeEach(func() {
+			exampleMedia, _ = media.NewMedia("TheAvengers", "2012", lastUpdated, tropes, tvTropesPage, media.Film)
+			chekhovsGun, _ = trope.NewTrope("ChekhovsGun", trope.TropeIndex(0), "")
+
+			example1, _ := trope.NewExample("Chekhov's Gun: The scepter.", "<a>Chekhov's Gun</a>: The scepter.", nil, []string{"The scepter"}, trope.Location{Folder: "Loki", Header: "Tropes A to D", SubpageURL: "https://tvtropes.org/pmwiki/pmwiki.php/TheAvengers2012/TropesAToD", Position: 3})
+			example2, _ := trope.NewExample("Chekhov's Gun: The shield.", "<a>Chekhov's Gun</a>: The shield.", []string{"It comes back later."}, nil, trope.Location{})
+			errAddExamples = exampleMedia.AddExamples(chekhovsGun, example1)
+			exampleMedia.AddExamples(chekhovsGun, example2)
+
+			unknownTrope, _ := trope.NewTrope("RedHerring", trope.TropeIndex(0), "")
+			errUnknownTrope = exampleMedia.AddExamples(unknownTrope, example1)
+		})
+
+		It("Should keep all the examples of the trope in order", func() {
+			Expect(errAddExamples).To(BeNil())
+			Expect(exampleMedia.GetExamples(chekhovsGun)).To(HaveLen(2))
+			Expect(exampleMedia.GetExamples(chekhovsGun)[0].Spoilers).To(Equal([]string{"The scepter"}))
+			Expect(exampleMedia.GetExamples(chekhovsGun)[1].SubItems).To(Equal([]string{"It comes back later."}))
+		})
+
+		It("Should marshal the examples with their trope", func() {
+			jsonTropes, _ := media.GetJsonTropes(exampleMedia)
+			for _, jsonTrope := range jsonTropes {
+				if jsonTrope.Title == "ChekhovsGun" {
+					Expect(jsonTrope.Examples).To(HaveLen(2))
+					Expect(jsonTrope.Examples[0].Text).To(Equal("Chekhov's Gun: The scepter."))
+					Expect(jsonTrope.Examples[0].Folder).To(Equal("Loki"))
+					Expect(jsonTrope.Examples[0].Header).To(Equal("Tropes A to D"))
+					Expect(jsonTrope.Examples[0].SubpageURL).To(Equal("https://tvtropes.org/pmwiki/pmwiki.php/TheAvengers2012/TropesAToD"))
+					Expect(jsonTrope.Examples[0].Position).To(Equal(3))
+				} else {
+					Expect(jsonTrope.Examples).To(BeEmpty())
+				}
+			}
+		})
+
+		It("Shouldn't relate examples with a trope the Media doesn't have", func() {
+			Expect(errors.Is(errUnknownTrope, media.ErrUnknownTrope)).To(BeTrue())
+		})
+	})
+
+	Describe("Build the graph of the relations between Works", func() {
+		var graph media.WorkGraph
+		var oldboyUrl, mangaUrl, remakeUrl string
+
+		BeforeEach(func() {
+			oldboyUrl = "https://tvtropes.org/pmwiki/pmwiki.php/Film/Oldboy2003"
+			mangaUrl = "https://tvtropes.org/pmwiki/pmwiki.php/Manga/Oldboy"
+			remakeUrl = "https://tvtropes.org/pmwiki/pmwiki.php/Film/Oldboy2013"
+
+			relationMedia, _ := media.NewMedia("TheAvengers", "2012", lastUpdated, tropes, tvTropesPage, media.Film)
+			manga, _ := trope.NewRelation(mangaUrl, "the Japanese manga of the same name", "It's based on the Japanese manga of the same name.", trope.AdaptationRelation)
+			remake, _ := trope.NewRelation(remakeUrl, "English-language remake", "An English-language remake was released.", trope.AdaptationRelation)
+			relationMedia.AddRelations(manga, remake)
+			Expect(relationMedia.GetRelations()).To(HaveLen(2))
+
+			graph = media.NewWorkGraph(map[string][]trope.Relation{
+				oldboyUrl: relationMedia.GetRelations(),
+				remakeUrl: {},
+			})
+		})
+
+		It("Should have every Work as a node and every relation as an edge", func() {
+			Expect(graph.Nodes).To(Equal([]string{oldboyUrl, remakeUrl, mangaUrl}))
+			Expect(graph.Edges).To(HaveLen(2))
+			Expect(graph.Edges[0].Source).To(Equal(oldboyUrl))
+			Expect(graph.Edges[0].Target).To(Equal(mangaUrl))
+			Expect(graph.IsScraped(oldboyUrl)).To(BeTrue())
+			Expect(graph.IsScraped(mangaUrl)).To(BeFalse())
+		})
+
+		It("Should export the graph as JSON", func() {
+			var jsonBuffer bytes.Buffer
+			Expect(graph.WriteJSON(&jsonBuffer)).To(Succeed())
+
+			var jsonGraph media.JsonGraph
+			Expect(json.Unmarshal(jsonBuffer.Bytes(), &jsonGraph)).To(Succeed())
+			Expect(jsonGraph.Nodes).To(HaveLen(3))
+			Expect(jsonGraph.Edges[1]).To(Equal(media.JsonGraphEdge{Source: oldboyUrl, Target: remakeUrl, AnchorText: "English-language remake", Type: "Adaptation"}))
+		})
+
+		It("Should export the graph in the DOT language", func() {
+			var dotBuffer bytes.Buffer
+			Expect(graph.WriteDOT(&dotBuffer)).To(Succeed())
+			Expect(dotBuffer.String()).To(HavePrefix("digraph works {"))
+			Expect(dotBuffer.String()).To(ContainSubstring(`"` + mangaUrl + `" [style=dashed];`))
+			Expect(dotBuffer.String()).To(ContainSubstring(`"` + oldboyUrl + `" -> "` + remakeUrl + `" [label="Adaptation"];`))
+		})
+	})
+
+	Describe("Set the metadata of a Media", func() {
+		var metadataMedia, emptyMetadataMedia media.Media
+
+		BeforeEach(func() {
+			metadataMedia, _ = media.NewMedia("TheAvengers", "2012", lastUpdated, tropes, tvTropesPage, media.Film)
+			metadataMedia.SetMetadata(trope.WorkMetadata{
+				Description:  []string{"The Avengers is a 2012 superhero film."},
+				Image:        "https://static.tvtropes.org/pmwiki/pub/images/100420.jpg",
+				ImageCaption: "Some Assembly Required.",
+				Creators:     []string{"Joss Whedon"},
+				SeeAlso:      []string{"https://tvtropes.org/pmwiki/pmwiki.php/Film/AvengersAgeOfUltron"},
+				Franchises:   []string{"MarvelCinematicUniverse"},
+			})
+
+			emptyMetadataMedia, _ = media.NewMedia("TheAvengers", "2012", lastUpdated, tropes, tvTropesPage, media.Film)
+		})
+
+		It("Should keep the metadata on the Work", func() {
+			Expect(metadataMedia.GetWork().Metadata.Creators).To(Equal([]string{"Joss Whedon"}))
+			Expect(metadataMedia.GetMetadata().ImageCaption).To(Equal("Some Assembly Required."))
+		})
+
+		It("Should marshal the metadata with the Media", func() {
+			jsonBytes, errMarshal := metadataMedia.MarshalJSON()
+			Expect(errMarshal).To(BeNil())
+
+			var jsonMedia media.JsonResponse
+			Expect(json.Unmarshal(jsonBytes, &jsonMedia)).To(Succeed())
+			Expect(jsonMedia.Metadata.Description).To(Equal([]string{"The Avengers is a 2012 superhero film."}))
+			Expect(jsonMedia.Metadata.Image).To(Equal("https://static.tvtropes.org/pmwiki/pub/images/100420.jpg"))
+			Expect(jsonMedia.Metadata.SeeAlso).To(Equal([]string{"https://tvtropes.org/pmwiki/pmwiki.php/Film/AvengersAgeOfUltron"}))
+			Expect(jsonMedia.Metadata.Franchises).To(Equal([]string{"MarvelCinematicUniverse"}))
+		})
+
+		It("Should marshal an empty metadata with empty arrays", func() {
+			jsonMetadata := media.GetJsonMetadata(emptyMetadataMedia.GetMetadata())
+			Expect(jsonMetadata.Description).To(Not(BeNil()))
+			Expect(jsonMetadata.Description).To(BeEmpty())
+			Expect(jsonMetadata.Creators).To(BeEmpty())
+		})
+	})
+
+	Describe("Transform a persisted record back into a Media", func() {
+		var originalMedia, recordMedia media.Media
+		var chekhovsGun, bigBad, subTrope trope.Trope
+		var record media.JsonResponse
+		var errRecordMedia error
+
+		BeforeEach(func() {
+			chekhovsGun, _ = trope.NewTrope("ChekhovsGun", trope.NarrativeTrope, "", trope.TopicalTrope)
+			bigBad, _ = trope.NewTrope("BigBad", trope.NarrativeTrope, "", trope.GenreTrope)
+			subTrope, _ = trope.NewTrope("BigDamnHeroes", trope.UnknownTropeIndex, "Heartwarming")
+			workTropes := map[trope.Trope]struct{}{chekhovsGun: {}, bigBad: {}, subTrope: {}}
+
+			originalMedia, _ = media.NewMedia("TheAvengers", "2012", lastUpdated.Truncate(time.Second).UTC(), workTropes, tvTropesPage, media.Film)
+			example, _ := trope.NewExample("Chekhov's Gun: The scepter.", "<a>Chekhov's Gun</a>: The scepter.", nil, []string{"The scepter"}, trope.Location{Folder: "Loki", Position: 3})
+			originalMedia.AddExamples(chekhovsGun, example)
+			originalMedia.SetMetadata(trope.WorkMetadata{
+				Description: []string{"The Avengers is a 2012 superhero film."},
+				Creators:    []string{"Joss Whedon"},
+				SeeAlso:     []string{},
+				Franchises:  []string{"MarvelCinematicUniverse"},
+			})
+			sequel, _ := trope.NewRelation("https://tvtropes.org/pmwiki/pmwiki.php/Film/AvengersAgeOfUltron", "Age of Ultron", "", trope.SequelRelation)
+			originalMedia.AddRelations(sequel)
+
+			jsonBytes, _ := originalMedia.MarshalJSON()
+			json.Unmarshal(jsonBytes, &record)
+			recordMedia, errRecordMedia = media.ToMedia(record)
+		})
+
+		It("Shouldn't return an error", func() {
+			Expect(errRecordMedia).To(BeNil())
+		})
+
+		It("Should have the same Work, page and media type", func() {
+			Expect(recordMedia.GetKey()).To(Equal(originalMedia.GetKey()))
+			Expect(recordMedia.GetPage().GetUrl().String()).To(Equal(avengersUrl))
+			Expect(recordMedia.GetWork().LastUpdated).To(Equal(originalMedia.GetWork().LastUpdated))
+			Expect(recordMedia.GetWork().Tropes).To(Equal(originalMedia.GetWork().Tropes))
+			Expect(recordMedia.GetWork().SubTropes).To(Equal(originalMedia.GetWork().SubTropes))
+		})
+
+		It("Should have the same examples, metadata and relations", func() {
+			Expect(recordMedia.GetExamples(chekhovsGun)).To(Equal(originalMedia.GetExamples(chekhovsGun)))
+			Expect(recordMedia.GetExamples(subTrope)).To(BeEmpty())
+			Expect(recordMedia.GetMetadata()).To(Equal(originalMedia.GetMetadata()))
+			Expect(recordMedia.GetRelations()).To(Equal(originalMedia.GetRelations()))
+		})
+
+		It("Should unmarshal the JSON of the Media back into it", func() {
+			jsonBytes, _ := originalMedia.MarshalJSON()
+
+			var unmarshalledMedia media.Media
+			Expect(json.Unmarshal(jsonBytes, &unmarshalledMedia)).To(Succeed())
+			Expect(unmarshalledMedia.GetKey()).To(Equal(originalMedia.GetKey()))
+			Expect(unmarshalledMedia.GetWork().Tropes).To(Equal(originalMedia.GetWork().Tropes))
+			Expect(unmarshalledMedia.GetExamples(chekhovsGun)).To(Equal(originalMedia.GetExamples(chekhovsGun)))
+			Expect(errors.Is(json.Unmarshal([]byte(`{"title": 2012}`), &unmarshalledMedia), media.ErrInvalidRecord)).To(BeTrue())
+		})
+
+		It("Should keep the main index of the tropes that are on several indexes", func() {
+			Expect(recordMedia.GetWork().Tropes).To(HaveKey(bigBad))
+			for recordTrope := range recordMedia.GetWork().Tropes {
+				if recordTrope.GetTitle() == "BigBad" {
+					Expect(recordTrope.GetIndex()).To(Equal(trope.NarrativeTrope))
+					Expect(recordTrope.GetIndexStrings()).To(Equal([]string{"NarrativeTrope", "GenreTrope"}))
+				}
+			}
+		})
+
+		It("Should know which tropes the Work has", func() {
+			Expect(recordMedia.HasTrope("ChekhovsGun")).To(BeTrue())
+			Expect(recordMedia.HasTrope("BigDamnHeroes")).To(BeTrue())
+			Expect(recordMedia.HasTrope("RedHerring")).To(BeFalse())
+			Expect(record.HasTrope("BigDamnHeroes")).To(BeTrue())
+		})
+
+		It("Should return an error if the record isn't valid", func() {
+			record.Tropes[0].Indexes = []string{"NotAnIndex"}
+			_, errIndex := media.ToMedia(record)
+			_, errMediaType := media.ToMedia(media.JsonResponse{Title: "TheAvengers", MediaType: "NotAMediaType", URL: avengersUrl})
+			_, errUrl := media.ToMedia(media.JsonResponse{Title: "TheAvengers", MediaType: "Film"})
+
+			Expect(errors.Is(errIndex, media.ErrInvalidRecord)).To(BeTrue())
+			Expect(errors.Is(errIndex, trope.ErrUnknownIndex)).To(BeTrue())
+			Expect(errors.Is(errMediaType, media.ErrUnknownMediaType)).To(BeTrue())
+			Expect(errors.Is(errUrl, media.ErrInvalidRecord)).To(BeTrue())
+		})
+	})
 })
 
 func areTropesUnique(tropes map[trope.Trope]struct{}) bool {
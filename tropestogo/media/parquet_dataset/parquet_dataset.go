@@ -0,0 +1,782 @@
+package parquet_dataset
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jlgallego99/TropesToGo/media"
+	"github.com/jlgallego99/TropesToGo/media/dataset_file"
+	"github.com/jlgallego99/TropesToGo/trope"
+)
+
+var (
+	ErrFileNotExists   = errors.New("Parquet dataset file does not exist")
+	ErrDuplicatedMedia = errors.New("duplicated media, the record already exists on the dataset")
+	ErrCreateParquet   = errors.New("error creating Parquet file")
+	ErrReadParquet     = errors.New("error reading Parquet file")
+	ErrWriteParquet    = errors.New("error writing on the Parquet file")
+	ErrInvalidParquet  = errors.New("the file isn't a valid Parquet dataset")
+	ErrPersist         = errors.New("can't persist data on the Parquet file because there's none")
+	ErrParseTime       = errors.New("error parsing the timestamp string from the dataset")
+	ErrInvalidField    = errors.New("one or more fields for the Parquet repository are invalid")
+)
+
+// errStopReading is returned by the functions that handle the records of the dataset to stop reading it before its end
+var errStopReading = errors.New("stop reading the Parquet dataset")
+
+const timeLayout = "2006-01-02 15:04:05"
+
+// DefaultRowGroupSize is the number of records of every row group of the file if the repository isn't configured with another one
+const DefaultRowGroupSize = 1000
+
+// ParquetRepository implements the RepositoryMedia for creating and handling Apache Parquet datasets of all the scraped data on TvTropes
+// Every Work is a row with its title, year, media type, last updated time and URL, and list columns of title and namespace structs
+// with its tropes and subtropes, and of url, anchor text, context and type structs with its relations
+// The persisted records are kept in memory until there are enough of them for a row group, which is appended in place of the footer
+// of the file without writing again what's already on it, and the rest of them are written when the repository is closed,
+// so it must always be closed and the records that haven't been written yet are lost if the process crashes
+// It's safe to use by several goroutines at the same time
+// The dataset file is replaced atomically when it's updated or emptied, and the repository holds its lock from its first write until it's closed,
+// so no other process can write the same dataset at the same time
+type ParquetRepository struct {
+	// name of the file dataset
+	name string
+
+	// data is the intermediate dataset added here before persisting it all at once
+	data []media.Media
+
+	// index holds the key of every Media object on data, for detecting the duplicated ones
+	index map[media.MediaKey]struct{}
+
+	// persisted holds the key of every Work on the dataset file or pending to be written on it, for skipping them when persisting
+	persisted map[media.MediaKey]struct{}
+
+	// pending are the persisted records that haven't been written on the dataset file yet, in the order they were persisted
+	pending []media.JsonResponse
+
+	// rowGroupSize is the number of records of every row group written on the dataset file
+	rowGroupSize int
+
+	// lock is the lock of the dataset file, held from the first write on the dataset until the repository is closed
+	lock *dataset_file.Lock
+
+	// backedUp is set when the dataset file has been backed up before the first destructive write of the repository
+	backedUp bool
+
+	// mutex guards the intermediate dataset and the dataset file
+	mutex sync.Mutex
+}
+
+// Error formats a generic error
+func Error(message string, err error, subErr error) error {
+	if subErr != nil {
+		return fmt.Errorf("%w: "+message+"\n%w", err, subErr)
+	} else {
+		return fmt.Errorf("%w: "+message+"", err)
+	}
+}
+
+// ParquetConfig is an alias for a function that will accept a pointer to a ParquetRepository and modify its fields
+// Each function acts as one configuration for the repository
+type ParquetConfig func(repository *ParquetRepository) error
+
+// NewParquetRepository is the constructor for ParquetRepository objects that handle Parquet datasets
+// It receives the name that the Parquet dataset file will have and creates it without any row group if it doesn't exist,
+// and the cfgs configurations of the repository
+// It will return an ErrCreateParquet error if the file couldn't be created, or an ErrReadParquet or ErrInvalidParquet error if the existing one couldn't be read
+func NewParquetRepository(name string, cfgs ...ParquetConfig) (*ParquetRepository, error) {
+	repository := &ParquetRepository{
+		name:         name + ".parquet",
+		index:        make(map[media.MediaKey]struct{}),
+		persisted:    make(map[media.MediaKey]struct{}),
+		rowGroupSize: DefaultRowGroupSize,
+	}
+
+	for _, cfg := range cfgs {
+		if errConfig := cfg(repository); errConfig != nil {
+			return nil, errConfig
+		}
+	}
+
+	if _, errStat := os.Stat(repository.name); errStat != nil {
+		if errLock := repository.lockDataset(); errLock != nil {
+			return nil, Error(name, ErrCreateParquet, errLock)
+		}
+
+		if errCreate := dataset_file.WriteFile(repository.name, emptyParquet()); errCreate != nil {
+			repository.Close()
+			return nil, Error(name, ErrCreateParquet, errCreate)
+		}
+	}
+
+	errRead := repository.readRecords(func(record media.JsonResponse) error {
+		repository.persisted[media.NewMediaKey(record.Title, record.Year, record.MediaType)] = struct{}{}
+		return nil
+	}, "title", "year", "media_type")
+	if errRead != nil {
+		repository.Close()
+		return nil, errRead
+	}
+
+	return repository, nil
+}
+
+// ConfigRowGroupSize defines the number of records of every row group written on the dataset file,
+// which are kept in memory until there are enough of them
+// It returns an ErrInvalidField error if the number isn't positive
+func ConfigRowGroupSize(rows int) ParquetConfig {
+	return func(repository *ParquetRepository) error {
+		if rows <= 0 {
+			return ErrInvalidField
+		}
+
+		repository.rowGroupSize = rows
+		return nil
+	}
+}
+
+// AddMedia adds a newMedia Media object to the in-memory dataset, so it can be later persisted
+// There can only be unique objects on the dataset, so it will return an ErrDuplicatedMedia error if the Media object already exists
+// Works with the same title and year but different media types are different Media objects
+func (repository *ParquetRepository) AddMedia(newMedia media.Media) error {
+	repository.mutex.Lock()
+	defer repository.mutex.Unlock()
+
+	if _, exists := repository.index[newMedia.GetKey()]; exists {
+		return Error("Title: "+newMedia.GetWork().Title, ErrDuplicatedMedia, nil)
+	}
+
+	repository.index[newMedia.GetKey()] = struct{}{}
+	repository.data = append(repository.data, newMedia)
+
+	return nil
+}
+
+// UpdateMedia updates a record already persisted on the dataset by checking if it has the same title and year and the media type of updateMedia, because that differentiates a record
+// Records that haven't been written on the file yet are updated in memory, but Parquet files can't be modified,
+// so for the rest of them the dataset is written again row group by row group on a temporary file that then replaces it
+// The dataset file is backed up before the first update of the repository that writes it
+// It returns a media.ErrMediaNotFound error without writing the dataset if there's no such record,
+// or an ErrReadParquet or ErrWriteParquet error if the dataset couldn't be read, backed up or written
+func (repository *ParquetRepository) UpdateMedia(title string, year string, updateMedia media.Media) error {
+	repository.mutex.Lock()
+	defer repository.mutex.Unlock()
+
+	if errLock := repository.lockDataset(); errLock != nil {
+		return errLock
+	}
+
+	oldKey := media.NewMediaKey(title, year, updateMedia.GetMediaType().String())
+	if _, found := repository.persisted[oldKey]; !found {
+		return Error("Title: "+title+" Year: "+year, media.ErrMediaNotFound, nil)
+	}
+
+	for pos, record := range repository.pending {
+		if media.NewMediaKey(record.Title, record.Year, record.MediaType) == oldKey {
+			repository.pending[pos] = toRecord(updateMedia)
+			delete(repository.persisted, oldKey)
+			repository.persisted[updateMedia.GetKey()] = struct{}{}
+
+			return nil
+		}
+	}
+
+	metadata, datasetFile, errMetadata := repository.readMetadata()
+	if errMetadata != nil {
+		return errMetadata
+	}
+	defer datasetFile.Close()
+
+	if errBackup := repository.backupDataset(); errBackup != nil {
+		return errBackup
+	}
+
+	errReplace := dataset_file.Replace(repository.name, func(updatedFile io.Writer) error {
+		updatedMetadata := fileMetadata{numRows: metadata.numRows}
+		offset := int64(len(parquetMagic))
+		if _, errWrite := updatedFile.Write(parquetMagic); errWrite != nil {
+			return errWrite
+		}
+
+		updated := false
+		for _, group := range metadata.rowGroups {
+			records, errRecords := repository.readRowGroup(datasetFile, group, nil)
+			if errRecords != nil {
+				return errRecords
+			}
+
+			for pos, record := range records {
+				if !updated && media.NewMediaKey(record.Title, record.Year, record.MediaType) == oldKey {
+					records[pos] = toRecord(updateMedia)
+					updated = true
+				}
+			}
+
+			encoded, encodedGroup := encodeRowGroup(records, offset)
+			if _, errWrite := updatedFile.Write(encoded); errWrite != nil {
+				return errWrite
+			}
+
+			offset += int64(len(encoded))
+			updatedMetadata.rowGroups = append(updatedMetadata.rowGroups, encodedGroup)
+		}
+
+		_, errWrite := updatedFile.Write(encodeFooter(updatedMetadata))
+		return errWrite
+	})
+	if errReplace != nil {
+		return Error(repository.name, ErrWriteParquet, errReplace)
+	}
+
+	delete(repository.persisted, oldKey)
+	repository.persisted[updateMedia.GetKey()] = struct{}{}
+
+	return nil
+}
+
+// RemoveAll deletes all data on both the in-memory intermediate data and on the dataset file, leaving it without any row group
+// The dataset file is backed up before emptying it for the first time, and then it's recreated,
+// so it will return an ErrCreateParquet error if that wasn't possible
+// If the dataset file doesn't exist, it returns an ErrFileNotExists error
+func (repository *ParquetRepository) RemoveAll() error {
+	repository.mutex.Lock()
+	defer repository.mutex.Unlock()
+
+	repository.data = []media.Media{}
+	repository.index = make(map[media.MediaKey]struct{})
+	repository.persisted = make(map[media.MediaKey]struct{})
+	repository.pending = nil
+
+	if _, errStat := os.Stat(repository.name); errStat != nil {
+		pwd, _ := os.Getwd()
+
+		return Error("at "+pwd+"/"+repository.name, ErrFileNotExists, nil)
+	}
+
+	if errLock := repository.lockDataset(); errLock != nil {
+		return errLock
+	}
+
+	if errBackup := repository.backupDataset(); errBackup != nil {
+		return errBackup
+	}
+
+	if errCreate := dataset_file.WriteFile(repository.name, emptyParquet()); errCreate != nil {
+		return Error(repository.name, ErrCreateParquet, errCreate)
+	}
+
+	return nil
+}
+
+// Persist adds all intermediate Media data to the records pending to be written on the dataset file and empties the structure,
+// because it has already been persisted, and appends them as new row groups of the file once there are enough of them for a row group
+// It checks whether the new records are already on the dataset, but doesn't return an error, but simply skips them
+// If the internal data structure is empty, it will do nothing and return an ErrPersist error
+// It returns an ErrReadParquet, ErrInvalidParquet or ErrWriteParquet error if the dataset couldn't be read or written
+func (repository *ParquetRepository) Persist() error {
+	repository.mutex.Lock()
+	defer repository.mutex.Unlock()
+
+	if len(repository.data) == 0 {
+		return Error(repository.name, ErrPersist, nil)
+	}
+
+	if errLock := repository.lockDataset(); errLock != nil {
+		return errLock
+	}
+
+	for _, mediaData := range repository.data {
+		if _, exists := repository.persisted[mediaData.GetKey()]; !exists {
+			repository.pending = append(repository.pending, toRecord(mediaData))
+			repository.persisted[mediaData.GetKey()] = struct{}{}
+		}
+	}
+
+	repository.data = []media.Media{}
+	repository.index = make(map[media.MediaKey]struct{})
+
+	return repository.writePending(false)
+}
+
+// writePending appends the pending records to the dataset file as row groups of rowGroupSize records,
+// writing the last one with the rest of them only if all is set, and keeps the records that aren't written as pending
+// The file is truncated where its footer starts and the new row groups and the footer are appended in place, so the old ones aren't written again
+// The mutex must be held, and it returns an ErrReadParquet, ErrInvalidParquet or ErrWriteParquet error if the dataset couldn't be read or written
+func (repository *ParquetRepository) writePending(all bool) error {
+	numWritten := len(repository.pending) - len(repository.pending)%repository.rowGroupSize
+	if all {
+		numWritten = len(repository.pending)
+	}
+
+	if numWritten == 0 {
+		return nil
+	}
+
+	if errLock := repository.lockDataset(); errLock != nil {
+		return errLock
+	}
+
+	metadata, datasetFile, errMetadata := repository.readMetadata()
+	if errMetadata != nil {
+		return errMetadata
+	}
+	defer datasetFile.Close()
+
+	// The new row groups go where the old footer was
+	footerStart, errOffset := footerOffset(datasetFile)
+	if errOffset != nil {
+		return Error(repository.name, ErrInvalidParquet, errOffset)
+	}
+
+	var appended bytes.Buffer
+	offset := footerStart
+	for start := 0; start < numWritten; start += repository.rowGroupSize {
+		end := start + repository.rowGroupSize
+		if end > numWritten {
+			end = numWritten
+		}
+
+		encoded, group := encodeRowGroup(repository.pending[start:end], offset)
+		appended.Write(encoded)
+		offset += int64(len(encoded))
+		metadata.rowGroups = append(metadata.rowGroups, group)
+		metadata.numRows += group.numRows
+	}
+	appended.Write(encodeFooter(metadata))
+
+	if errAppend := appendAt(repository.name, footerStart, appended.Bytes()); errAppend != nil {
+		return Error(repository.name, ErrWriteParquet, errAppend)
+	}
+
+	repository.pending = append([]media.JsonResponse{}, repository.pending[numWritten:]...)
+	return nil
+}
+
+// Close writes the records that are still pending on the dataset file as its last row group,
+// and releases the lock of the dataset file if the repository holds it, so other processes can write it
+// The lock is released even if the records couldn't be written, which are kept so closing it again tries to write them again
+// The repository can still be used after closing it, and it will acquire the lock again on its next write
+func (repository *ParquetRepository) Close() error {
+	repository.mutex.Lock()
+	defer repository.mutex.Unlock()
+
+	errWrite := repository.writePending(true)
+	if repository.lock == nil {
+		return errWrite
+	}
+
+	errRelease := repository.lock.Release()
+	repository.lock = nil
+
+	return errors.Join(errWrite, errRelease)
+}
+
+// lockDataset acquires the lock of the dataset file if the repository doesn't hold it yet, and holds it until the repository is closed
+// The mutex must be held, and it returns an ErrWriteParquet error with dataset_file.ErrLocked if another process is writing the dataset
+func (repository *ParquetRepository) lockDataset() error {
+	if repository.lock != nil {
+		return nil
+	}
+
+	lock, errLock := dataset_file.AcquireLock(repository.name)
+	if errLock != nil {
+		return Error(repository.name, ErrWriteParquet, errLock)
+	}
+
+	repository.lock = lock
+	return nil
+}
+
+// backupDataset backs up the dataset file before the first destructive write of the repository, so it's only backed up once
+// even if many records are updated. The mutex must be held, and it returns an ErrWriteParquet error if it couldn't be backed up
+func (repository *ParquetRepository) backupDataset() error {
+	if repository.backedUp {
+		return nil
+	}
+
+	if errBackup := dataset_file.Backup(repository.name); errBackup != nil {
+		return Error(repository.name, ErrWriteParquet, errBackup)
+	}
+
+	repository.backedUp = true
+	return nil
+}
+
+// GetWorkPages retrieves all persisted Work urls on the Parquet dataset and the last time they were updated
+// Returns a map relating page URLs to the last time they were updated
+func (repository *ParquetRepository) GetWorkPages() (map[string]time.Time, error) {
+	repository.mutex.Lock()
+	defer repository.mutex.Unlock()
+
+	datasetPages := make(map[string]time.Time, 0)
+	errRead := repository.readRecords(func(record media.JsonResponse) error {
+		lastUpdated, errLastUpdated := time.Parse(timeLayout, record.LastUpdated)
+		if errLastUpdated != nil {
+			return Error(repository.name, ErrParseTime, errLastUpdated)
+		}
+
+		datasetPages[record.URL] = lastUpdated
+		return nil
+	}, "url", "last_updated")
+	if errRead != nil {
+		return nil, errRead
+	}
+
+	return datasetPages, nil
+}
+
+// GetTropes retrieves the title of every trope and subtrope of the Works persisted on the Parquet dataset
+// Returns a set of the trope titles, which are the IDs of their Main pages
+func (repository *ParquetRepository) GetTropes() (map[string]struct{}, error) {
+	repository.mutex.Lock()
+	defer repository.mutex.Unlock()
+
+	datasetTropes := make(map[string]struct{})
+	errRead := repository.readRecords(func(record media.JsonResponse) error {
+		for _, jsonTrope := range append(record.Tropes, record.SubTropes...) {
+			datasetTropes[jsonTrope.Title] = struct{}{}
+		}
+
+		return nil
+	}, "tropes", "sub_tropes")
+	if errRead != nil {
+		return nil, errRead
+	}
+
+	return datasetTropes, nil
+}
+
+// GetWorkRelations retrieves the relations with other Works of every Work persisted on the Parquet dataset
+// Returns a map relating the URL of each Work to its relations
+func (repository *ParquetRepository) GetWorkRelations() (map[string][]trope.Relation, error) {
+	repository.mutex.Lock()
+	defer repository.mutex.Unlock()
+
+	datasetRelations := make(map[string][]trope.Relation)
+	errRead := repository.readRecords(func(record media.JsonResponse) error {
+		relations, errRelations := media.ToRelations(record.Relations)
+		if errRelations != nil {
+			return Error(repository.name, ErrReadParquet, errRelations)
+		}
+
+		datasetRelations[record.URL] = relations
+		return nil
+	}, "url", "relations")
+	if errRead != nil {
+		return nil, errRead
+	}
+
+	return datasetRelations, nil
+}
+
+// GetMediaByURL retrieves the Media of the Work page with the url persisted on the Parquet dataset
+// The dataset only has the columns of the rows, so the tropes don't have indexes nor examples and the Work doesn't have metadata
+// It returns a media.ErrMediaNotFound error if there's none, or an ErrReadParquet or ErrInvalidParquet error if the dataset couldn't be read
+func (repository *ParquetRepository) GetMediaByURL(url string) (media.Media, error) {
+	repository.mutex.Lock()
+	defer repository.mutex.Unlock()
+
+	return repository.findMedia(func(record media.JsonResponse) bool {
+		return record.URL == url
+	}, url)
+}
+
+// GetMedia retrieves the Media with the title and year persisted on the Parquet dataset
+// The dataset only has the columns of the rows, so the tropes don't have indexes nor examples and the Work doesn't have metadata
+// It returns a media.ErrMediaNotFound error if there's none, or an ErrReadParquet or ErrInvalidParquet error if the dataset couldn't be read
+func (repository *ParquetRepository) GetMedia(title, year string) (media.Media, error) {
+	repository.mutex.Lock()
+	defer repository.mutex.Unlock()
+
+	return repository.findMedia(func(record media.JsonResponse) bool {
+		return record.Title == title && record.Year == year
+	}, "Title: "+title+" Year: "+year)
+}
+
+// ForEachMedia calls handleMedia with every Media persisted on the Parquet dataset, in the order of its rows
+// Records are read one row group at a time, so handleMedia is called while the repository is locked
+// It stops at the first error handleMedia returns and returns it, or returns an ErrReadParquet or ErrInvalidParquet error if the dataset couldn't be read
+func (repository *ParquetRepository) ForEachMedia(handleMedia func(media.Media) error) error {
+	repository.mutex.Lock()
+	defer repository.mutex.Unlock()
+
+	return repository.readRecords(func(record media.JsonResponse) error {
+		recordMedia, errMedia := repository.toMedia(record)
+		if errMedia != nil {
+			return errMedia
+		}
+
+		return handleMedia(recordMedia)
+	})
+}
+
+// GetMediaWithTrope retrieves every Media persisted on the Parquet dataset that has the trope with the title as a trope or a subtrope
+// It returns an ErrReadParquet or ErrInvalidParquet error if the dataset couldn't be read
+func (repository *ParquetRepository) GetMediaWithTrope(title string) ([]media.Media, error) {
+	repository.mutex.Lock()
+	defer repository.mutex.Unlock()
+
+	mediaWithTrope := make([]media.Media, 0)
+	errRead := repository.readRecords(func(record media.JsonResponse) error {
+		if !record.HasTrope(title) {
+			return nil
+		}
+
+		recordMedia, errMedia := repository.toMedia(record)
+		if errMedia != nil {
+			return errMedia
+		}
+
+		mediaWithTrope = append(mediaWithTrope, recordMedia)
+		return nil
+	})
+	if errRead != nil {
+		return nil, errRead
+	}
+
+	return mediaWithTrope, nil
+}
+
+// CountMedia returns the number of Media records persisted on the Parquet dataset, without reading the file
+func (repository *ParquetRepository) CountMedia() (int, error) {
+	repository.mutex.Lock()
+	defer repository.mutex.Unlock()
+
+	return len(repository.persisted), nil
+}
+
+// findMedia returns the Media of the first record of the dataset that matches, or a media.ErrMediaNotFound error with the message if there's none
+func (repository *ParquetRepository) findMedia(match func(media.JsonResponse) bool, message string) (media.Media, error) {
+	var foundMedia media.Media
+	found := false
+	errRead := repository.readRecords(func(record media.JsonResponse) error {
+		if !match(record) {
+			return nil
+		}
+
+		var errMedia error
+		foundMedia, errMedia = repository.toMedia(record)
+		if errMedia != nil {
+			return errMedia
+		}
+
+		found = true
+		return errStopReading
+	})
+	if errRead != nil && !errors.Is(errRead, errStopReading) {
+		return media.Media{}, errRead
+	}
+
+	if !found {
+		return media.Media{}, Error(message, media.ErrMediaNotFound, nil)
+	}
+
+	return foundMedia, nil
+}
+
+// toMedia transforms a record of the Parquet dataset back into a Media object
+// It returns an ErrInvalidParquet error if the record isn't a valid Media
+func (repository *ParquetRepository) toMedia(record media.JsonResponse) (media.Media, error) {
+	recordMedia, errMedia := media.ToMedia(record)
+	if errMedia != nil {
+		return media.Media{}, Error(repository.name, ErrInvalidParquet, errMedia)
+	}
+
+	return recordMedia, nil
+}
+
+// readRecords reads the dataset file row group by row group, calling handleRecord with every record, so only one row group is in memory at a time,
+// and then calls it with every pending record that hasn't been written on the file yet
+// Only the given columns are read from the file, by the name of the top level column, and the rest are left empty on the records
+// All of them are read if there isn't any given column
+func (repository *ParquetRepository) readRecords(handleRecord func(media.JsonResponse) error, columns ...string) error {
+	metadata, datasetFile, errMetadata := repository.readMetadata()
+	if errMetadata != nil {
+		return errMetadata
+	}
+	defer datasetFile.Close()
+
+	var readColumns map[string]struct{}
+	if len(columns) > 0 {
+		readColumns = make(map[string]struct{}, len(columns))
+		for _, column := range columns {
+			readColumns[column] = struct{}{}
+		}
+	}
+
+	for _, group := range metadata.rowGroups {
+		records, errRecords := repository.readRowGroup(datasetFile, group, readColumns)
+		if errRecords != nil {
+			return errRecords
+		}
+
+		for _, record := range records {
+			if errHandle := handleRecord(record); errHandle != nil {
+				return errHandle
+			}
+		}
+	}
+
+	// The pending records go after the ones of the file, like when they are written on it
+	for _, record := range repository.pending {
+		if errHandle := handleRecord(record); errHandle != nil {
+			return errHandle
+		}
+	}
+
+	return nil
+}
+
+// readRowGroup reads the records of a row group of the dataset file with the columns of readColumns, or all of them if it's nil
+func (repository *ParquetRepository) readRowGroup(datasetFile *os.File, group rowGroup, readColumns map[string]struct{}) ([]media.JsonResponse, error) {
+	columns := make(map[string]columnValues, len(group.columns))
+	for _, chunk := range group.columns {
+		if len(chunk.path) == 0 {
+			return nil, Error(repository.name, ErrInvalidParquet, nil)
+		}
+		if _, read := readColumns[chunk.path[0]]; readColumns != nil && !read {
+			continue
+		}
+
+		chunkBytes := make([]byte, chunk.compressedSize)
+		if _, errRead := datasetFile.ReadAt(chunkBytes, chunk.dataPageOffset); errRead != nil {
+			return nil, Error(repository.name, ErrReadParquet, errRead)
+		}
+
+		decoded, errDecode := decodeColumnChunk(chunkBytes, len(chunk.path) > 1)
+		if errDecode != nil {
+			return nil, Error(repository.name, ErrInvalidParquet, errDecode)
+		}
+
+		columns[strings.Join(chunk.path, ".")] = decoded
+	}
+
+	records, errRecords := decodeRecords(group.numRows, columns)
+	if errRecords != nil {
+		return nil, Error(repository.name, ErrInvalidParquet, errRecords)
+	}
+
+	return records, nil
+}
+
+// readMetadata opens the dataset file and reads the metadata of its footer
+// It returns the open file, which must be closed, and an ErrReadParquet or ErrInvalidParquet error if the footer couldn't be read
+func (repository *ParquetRepository) readMetadata() (fileMetadata, *os.File, error) {
+	datasetFile, errOpen := os.Open(repository.name)
+	if errOpen != nil {
+		return fileMetadata{}, nil, Error(repository.name, ErrReadParquet, errOpen)
+	}
+
+	offset, errOffset := footerOffset(datasetFile)
+	if errOffset != nil {
+		datasetFile.Close()
+		return fileMetadata{}, nil, Error(repository.name, ErrInvalidParquet, errOffset)
+	}
+
+	datasetInfo, _ := datasetFile.Stat()
+	footer := make([]byte, datasetInfo.Size()-offset-parquetFooterLength)
+	if _, errRead := datasetFile.ReadAt(footer, offset); errRead != nil {
+		datasetFile.Close()
+		return fileMetadata{}, nil, Error(repository.name, ErrReadParquet, errRead)
+	}
+
+	metadata, errDecode := decodeFooter(footer)
+	if errDecode != nil {
+		datasetFile.Close()
+		return fileMetadata{}, nil, Error(repository.name, ErrInvalidParquet, errDecode)
+	}
+
+	return metadata, datasetFile, nil
+}
+
+// appendAt truncates the file with the name at the offset and writes the data after it, syncing it before returning
+func appendAt(name string, offset int64, data []byte) error {
+	datasetFile, errOpen := os.OpenFile(name, os.O_WRONLY, 0)
+	if errOpen != nil {
+		return errOpen
+	}
+
+	if errTruncate := datasetFile.Truncate(offset); errTruncate != nil {
+		datasetFile.Close()
+		return errTruncate
+	}
+
+	if _, errWrite := datasetFile.WriteAt(data, offset); errWrite != nil {
+		datasetFile.Close()
+		return errWrite
+	}
+
+	if errSync := datasetFile.Sync(); errSync != nil {
+		datasetFile.Close()
+		return errSync
+	}
+
+	return datasetFile.Close()
+}
+
+// footerOffset returns where the footer of the Parquet file starts, checking its magic bytes
+func footerOffset(datasetFile *os.File) (int64, error) {
+	datasetInfo, errStat := datasetFile.Stat()
+	if errStat != nil {
+		return 0, errStat
+	}
+
+	size := datasetInfo.Size()
+	if size < int64(len(parquetMagic))+parquetFooterLength {
+		return 0, fmt.Errorf("file of %d bytes", size)
+	}
+
+	header := make([]byte, len(parquetMagic))
+	trailer := make([]byte, parquetFooterLength)
+	if _, errRead := datasetFile.ReadAt(header, 0); errRead != nil {
+		return 0, errRead
+	}
+	if _, errRead := datasetFile.ReadAt(trailer, size-parquetFooterLength); errRead != nil {
+		return 0, errRead
+	}
+
+	if !bytes.Equal(header, parquetMagic) || !bytes.Equal(trailer[4:], parquetMagic) {
+		return 0, errors.New("missing magic bytes")
+	}
+
+	offset := size - parquetFooterLength - int64(binary.LittleEndian.Uint32(trailer))
+	if offset < int64(len(parquetMagic)) {
+		return 0, fmt.Errorf("footer of %d bytes", binary.LittleEndian.Uint32(trailer))
+	}
+
+	return offset, nil
+}
+
+// emptyParquet returns the contents of a Parquet file without any row group
+func emptyParquet() []byte {
+	return append(append([]byte{}, parquetMagic...), encodeFooter(fileMetadata{})...)
+}
+
+// toRecord transforms a Media object into the record of its row, with only the titles and namespaces of its tropes like the columns keep them,
+// so the records that haven't been written on the file yet are read like the rest
+func toRecord(mediaData media.Media) media.JsonResponse {
+	tropes, subTropes := media.GetJsonTropes(mediaData)
+
+	return media.JsonResponse{
+		Title:       mediaData.GetWork().Title,
+		Year:        mediaData.GetWork().Year,
+		MediaType:   mediaData.GetMediaType().String(),
+		LastUpdated: formatDate(mediaData.GetWork().LastUpdated),
+		URL:         mediaData.GetPage().GetUrl().String(),
+		Tropes:      elementTropes(tropeElements(tropes)),
+		SubTropes:   elementTropes(tropeElements(subTropes)),
+		Relations:   media.GetJsonRelations(mediaData.GetRelations()),
+	}
+}
+
+// formatDate transforms a date to a unified string format across all datasets
+func formatDate(date time.Time) string {
+	return date.Format(timeLayout)
+}
@@ -0,0 +1,13 @@
+package parquet_dataset_test
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func TestParquetDataset(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "ParquetDataset Suite")
+}
@@ -0,0 +1,570 @@
+package parquet_dataset_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/jlgallego99/TropesToGo/media"
+	"github.com/jlgallego99/TropesToGo/media/dataset_file"
+	"github.com/jlgallego99/TropesToGo/media/parquet_dataset"
+	"github.com/jlgallego99/TropesToGo/media/repository_conformance"
+	"github.com/jlgallego99/TropesToGo/trope"
+	"github.com/jlgallego99/TropesToGo/tvtropespages"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+const (
+	oldboyUrl     = "https://tvtropes.org/pmwiki/pmwiki.php/Film/Oldboy2003"
+	oldboyRemake  = "https://tvtropes.org/pmwiki/pmwiki.php/Film/Oldboy2013"
+	parquetMagic  = "PAR1"
+	parquetFooter = 8
+	randomMax     = 10
+	randomMin     = 2
+)
+
+var repository *parquet_dataset.ParquetRepository
+var errorRepository, errRemoveAll, errAddMedia, errPersist error
+var mediaEntry media.Media
+var tropes map[trope.Trope]struct{}
+var numTropes int
+
+var seededRand = rand.New(rand.NewSource(time.Now().UnixNano()))
+
+var _ = BeforeSuite(func() {
+	numTropes = seededRand.Intn(randomMax-randomMin) + randomMin
+
+	tropes = createTropes(numTropes, randomTrope)
+	subTropes := createTropes(numTropes, randomSubTrope)
+	for subTrope := range subTropes {
+		tropes[subTrope] = struct{}{}
+	}
+
+	tvTropesPage, _ := tvtropespages.NewPage(context.Background(), oldboyUrl, false, nil)
+	mediaEntry, _ = media.NewMedia("Oldboy", "2003", time.Now(), tropes, tvTropesPage, media.Film)
+	remake, _ := trope.NewRelation(oldboyRemake, "English-language remake",
+		"An English-language remake was released on November 27, 2013.", trope.AdaptationRelation)
+	mediaEntry.AddRelations(remake)
+})
+
+var _ = Describe("ParquetDataset", func() {
+	BeforeEach(func() {
+		repository, errorRepository = parquet_dataset.NewParquetRepository("dataset")
+	})
+
+	AfterEach(func() {
+		// Reset file
+		repository.RemoveAll()
+		repository.Close()
+	})
+
+	Context("Create Parquet repository", func() {
+		BeforeEach(func() {
+			errPersist = repository.Persist()
+		})
+
+		It("Should have created a Parquet file", func() {
+			Expect("dataset.parquet").To(BeAnExistingFile())
+			correctParquetFile()
+		})
+
+		It("Shouldn't return an error", func() {
+			Expect(errorRepository).To(BeNil())
+		})
+
+		It("Shouldn't be able to persist anything", func() {
+			Expect(errors.Is(errPersist, parquet_dataset.ErrPersist)).To(BeTrue())
+		})
+	})
+
+	Context("Add a Media to the Parquet file", func() {
+		BeforeEach(func() {
+			errAddMedia = repository.AddMedia(mediaEntry)
+			errPersist = repository.Persist()
+		})
+
+		It("Should be a valid Parquet file", func() {
+			correctParquetFile()
+		})
+
+		It("Should have all the correct fields", func() {
+			correctRecord(oldboyUrl, tropes)
+		})
+
+		It("Shouldn't return an error", func() {
+			Expect(errAddMedia).To(BeNil())
+			Expect(errPersist).To(BeNil())
+		})
+	})
+
+	Context("Add duplicated Media to the Parquet file", func() {
+		BeforeEach(func() {
+			errAddMedia = repository.AddMedia(mediaEntry)
+			errAddMedia = repository.AddMedia(mediaEntry)
+			errPersist = repository.Persist()
+		})
+
+		It("Should only be one record on the Parquet file", func() {
+			correctRecord(oldboyUrl, tropes)
+		})
+
+		It("Should return an error", func() {
+			Expect(errors.Is(errAddMedia, parquet_dataset.ErrDuplicatedMedia)).To(BeTrue())
+			Expect(errPersist).To(BeNil())
+		})
+	})
+
+	Context("Remove Parquet file contents", func() {
+		BeforeEach(func() {
+			errAddMedia = repository.AddMedia(mediaEntry)
+			errPersist = repository.Persist()
+			errRemoveAll = repository.RemoveAll()
+		})
+
+		It("Should still exist a valid Parquet file", func() {
+			Expect("dataset.parquet").To(BeAnExistingFile())
+			correctParquetFile()
+		})
+
+		It("Should have no records", func() {
+			workPages, errWorkPages := repository.GetWorkPages()
+
+			Expect(errWorkPages).To(BeNil())
+			Expect(workPages).To(BeEmpty())
+		})
+
+		It("Should have no errors", func() {
+			Expect(errRemoveAll).To(BeNil())
+		})
+	})
+
+	Context("Remove contents of Parquet file that doesn't exist", func() {
+		BeforeEach(func() {
+			os.Remove("dataset.parquet")
+			errRemoveAll = repository.RemoveAll()
+		})
+
+		AfterEach(func() {
+			repository, errorRepository = parquet_dataset.NewParquetRepository("dataset")
+		})
+
+		It("A Parquet file shouldn't exist", func() {
+			Expect("dataset.parquet").To(Not(BeAnExistingFile()))
+		})
+
+		It("Should return an error", func() {
+			Expect(errors.Is(errRemoveAll, parquet_dataset.ErrFileNotExists)).To(BeTrue())
+		})
+	})
+
+	Context("Open a file that isn't a Parquet file", func() {
+		BeforeEach(func() {
+			os.WriteFile("invalid.parquet", []byte("{\"tropestogo\": []}"), 0644)
+			_, errorRepository = parquet_dataset.NewParquetRepository("invalid")
+		})
+
+		AfterEach(func() {
+			os.Remove("invalid.parquet")
+		})
+
+		It("Should return an error", func() {
+			Expect(errors.Is(errorRepository, parquet_dataset.ErrInvalidParquet)).To(BeTrue())
+		})
+	})
+
+	Context("Update the Year, URL and tropes of a Film in the Parquet file", func() {
+		var errUpdate error
+		var newTropes map[trope.Trope]struct{}
+
+		BeforeEach(func() {
+			// Every record is written as its own row group, so the update rewrites the file
+			repository.Close()
+			repository, errorRepository = parquet_dataset.NewParquetRepository("dataset", parquet_dataset.ConfigRowGroupSize(1))
+			Expect(errorRepository).To(BeNil())
+
+			errAddMedia = repository.AddMedia(mediaEntry)
+			errPersist = repository.Persist()
+
+			// A second row group that isn't updated
+			tvTropesPage, _ := tvtropespages.NewPage(context.Background(), oldboyUrl+"Other", false, nil)
+			otherMediaEntry, _ := media.NewMedia("Other", "2003", time.Now(), tropes, tvTropesPage, media.Film)
+			repository.AddMedia(otherMediaEntry)
+			repository.Persist()
+
+			// Create the new Media to be updated
+			newTropes = createTropes(numTropes, randomTrope)
+			tvTropesPage, _ = tvtropespages.NewPage(context.Background(), oldboyRemake, false, nil)
+			updatedMediaEntry, _ := media.NewMedia("Oldboy", "2013", time.Now(), newTropes, tvTropesPage, media.Film)
+
+			errUpdate = repository.UpdateMedia("Oldboy", "2003", updatedMediaEntry)
+		})
+
+		It("Should have the new record updated and keep the rest", func() {
+			correctParquetFile()
+
+			workPages, errWorkPages := repository.GetWorkPages()
+			Expect(errWorkPages).To(BeNil())
+			Expect(workPages).To(HaveLen(2))
+			Expect(workPages).To(HaveKey(oldboyRemake))
+			Expect(workPages).To(HaveKey(oldboyUrl + "Other"))
+		})
+
+		It("Should have the tropes and relations of the new record", func() {
+			datasetTropes, errGetTropes := repository.GetTropes()
+			Expect(errGetTropes).To(BeNil())
+			for newTrope := range newTropes {
+				Expect(datasetTropes).To(HaveKey(newTrope.GetTitle()))
+			}
+
+			datasetRelations, errGetRelations := repository.GetWorkRelations()
+			Expect(errGetRelations).To(BeNil())
+			Expect(datasetRelations[oldboyRemake]).To(BeEmpty())
+		})
+
+		It("Shouldn't return an error", func() {
+			Expect(errUpdate).To(BeNil())
+			Expect(errPersist).To(BeNil())
+		})
+	})
+
+	Context("Update a Film that hasn't been written on the Parquet file yet", func() {
+		var errUpdate error
+		var emptySize int64
+
+		BeforeEach(func() {
+			emptySize = fileSize()
+			errAddMedia = repository.AddMedia(mediaEntry)
+			errPersist = repository.Persist()
+
+			tvTropesPage, _ := tvtropespages.NewPage(context.Background(), oldboyRemake, false, nil)
+			updatedMediaEntry, _ := media.NewMedia("Oldboy", "2013", time.Now(), tropes, tvTropesPage, media.Film)
+			errUpdate = repository.UpdateMedia("Oldboy", "2003", updatedMediaEntry)
+		})
+
+		It("Shouldn't have written the file", func() {
+			Expect(fileSize()).To(Equal(emptySize))
+		})
+
+		It("Should write the updated record when closing the repository", func() {
+			Expect(repository.Close()).To(BeNil())
+
+			reopened, errReopen := parquet_dataset.NewParquetRepository("dataset")
+			Expect(errReopen).To(BeNil())
+			workPages, errWorkPages := reopened.GetWorkPages()
+			Expect(errWorkPages).To(BeNil())
+			Expect(workPages).To(HaveLen(1))
+			Expect(workPages).To(HaveKey(oldboyRemake))
+		})
+
+		It("Shouldn't return an error", func() {
+			Expect(errUpdate).To(BeNil())
+			Expect(errPersist).To(BeNil())
+		})
+	})
+
+	Context("Persist Media with a row group size of two records", func() {
+		var sizes []int64
+		var datasetInfo os.FileInfo
+		var persistErrors []error
+		var workPages map[string]time.Time
+		var errWorkPages error
+
+		BeforeEach(func() {
+			repository.Close()
+			repository, errorRepository = parquet_dataset.NewParquetRepository("dataset", parquet_dataset.ConfigRowGroupSize(2))
+			Expect(errorRepository).To(BeNil())
+
+			sizes = []int64{fileSize()}
+			datasetInfo, _ = os.Stat("dataset.parquet")
+			persistErrors = nil
+			for i := 0; i < 3; i++ {
+				tvTropesPage, _ := tvtropespages.NewPage(context.Background(), oldboyUrl+fmt.Sprint(i), false, nil)
+				persistedMedia, _ := media.NewMedia("Oldboy"+fmt.Sprint(i), "2003", time.Now(), tropes, tvTropesPage, media.Film)
+				repository.AddMedia(persistedMedia)
+				persistErrors = append(persistErrors, repository.Persist())
+				sizes = append(sizes, fileSize())
+			}
+
+			workPages, errWorkPages = repository.GetWorkPages()
+		})
+
+		It("Should only write the file when there are enough records for a row group", func() {
+			Expect(sizes[1]).To(Equal(sizes[0]))
+			Expect(sizes[2]).To(BeNumerically(">", sizes[1]))
+			Expect(sizes[3]).To(Equal(sizes[2]))
+		})
+
+		It("Should append the row groups in place instead of replacing the file", func() {
+			Expect(repository.Close()).To(BeNil())
+			closedInfo, errStat := os.Stat("dataset.parquet")
+
+			Expect(errStat).To(BeNil())
+			Expect(os.SameFile(datasetInfo, closedInfo)).To(BeTrue())
+			correctParquetFile()
+		})
+
+		It("Should read the records that haven't been written yet", func() {
+			Expect(errWorkPages).To(BeNil())
+			Expect(workPages).To(HaveLen(3))
+		})
+
+		It("Should write the rest of the records when closing the repository", func() {
+			Expect(repository.Close()).To(BeNil())
+			Expect(fileSize()).To(BeNumerically(">", sizes[3]))
+			correctParquetFile()
+
+			reopened, errReopen := parquet_dataset.NewParquetRepository("dataset")
+			Expect(errReopen).To(BeNil())
+			reopenedPages, errReopenedPages := reopened.GetWorkPages()
+			Expect(errReopenedPages).To(BeNil())
+			Expect(reopenedPages).To(Equal(workPages))
+		})
+
+		It("Shouldn't return an error", func() {
+			for _, errPersisted := range persistErrors {
+				Expect(errPersisted).To(BeNil())
+			}
+		})
+	})
+
+	Context("Create a Parquet repository with an invalid row group size", func() {
+		var invalidRepository *parquet_dataset.ParquetRepository
+		var errInvalid error
+
+		BeforeEach(func() {
+			invalidRepository, errInvalid = parquet_dataset.NewParquetRepository("dataset", parquet_dataset.ConfigRowGroupSize(0))
+		})
+
+		It("Shouldn't create the repository", func() {
+			Expect(invalidRepository).To(BeNil())
+		})
+
+		It("Should return an error", func() {
+			Expect(errors.Is(errInvalid, parquet_dataset.ErrInvalidField)).To(BeTrue())
+		})
+	})
+
+	Context("Persist an already persisted before record", func() {
+		BeforeEach(func() {
+			// Persist first
+			errAddMedia = repository.AddMedia(mediaEntry)
+			errPersist = repository.Persist()
+
+			// Try to persist again the same Media, with a new repository that reads it from the file
+			repository.Close()
+			repository, errorRepository = parquet_dataset.NewParquetRepository("dataset")
+			errAddMedia = repository.AddMedia(mediaEntry)
+			errPersist = repository.Persist()
+		})
+
+		It("Should only be one Media record on the Parquet file", func() {
+			correctRecord(oldboyUrl, tropes)
+		})
+
+		It("Shouldn't return an error", func() {
+			Expect(errorRepository).To(BeNil())
+			Expect(errAddMedia).To(BeNil())
+			Expect(errPersist).To(BeNil())
+		})
+	})
+
+	Context("Add Media from several goroutines at the same time", func() {
+		const addingGoroutines = 20
+		var addErrors []error
+
+		BeforeEach(func() {
+			addErrors = make([]error, addingGoroutines)
+			var adding sync.WaitGroup
+			for i := 0; i < addingGoroutines; i++ {
+				adding.Add(1)
+				go func(i int) {
+					defer adding.Done()
+
+					// Half of the goroutines add the same Media and the other half a different Media each
+					addedMedia := mediaEntry
+					if i%2 == 1 {
+						tvTropesPage, _ := tvtropespages.NewPage(context.Background(), oldboyUrl+fmt.Sprint(i), false, nil)
+						addedMedia, _ = media.NewMedia("Oldboy"+fmt.Sprint(i), "2003", time.Now(), tropes, tvTropesPage, media.Film)
+					}
+
+					addErrors[i] = repository.AddMedia(addedMedia)
+				}(i)
+			}
+			adding.Wait()
+
+			errPersist = repository.Persist()
+		})
+
+		It("Should only add each Media once", func() {
+			duplicated := 0
+			for _, errAdd := range addErrors {
+				if errAdd != nil {
+					Expect(errors.Is(errAdd, parquet_dataset.ErrDuplicatedMedia)).To(BeTrue())
+					duplicated++
+				}
+			}
+
+			Expect(duplicated).To(Equal(addingGoroutines/2 - 1))
+			Expect(errPersist).To(BeNil())
+		})
+
+		It("Should persist every different Media", func() {
+			workPages, errWorkPages := repository.GetWorkPages()
+
+			Expect(errWorkPages).To(BeNil())
+			Expect(workPages).To(HaveLen(addingGoroutines/2 + 1))
+		})
+	})
+
+	Context("Get all the URLs of the persisted Media and its last updated time", func() {
+		var workPages map[string]time.Time
+		var errGetWorkPages error
+
+		BeforeEach(func() {
+			errAddMedia = repository.AddMedia(mediaEntry)
+			Expect(errAddMedia).To(BeNil())
+			errPersist = repository.Persist()
+			Expect(errPersist).To(BeNil())
+
+			workPages, errGetWorkPages = repository.GetWorkPages()
+		})
+
+		It("Shouldn't return an error", func() {
+			Expect(errGetWorkPages).To(BeNil())
+		})
+
+		It("Should return an URL and its last updated time", func() {
+			Expect(workPages).To(HaveLen(1))
+			Expect(workPages[oldboyUrl]).To(BeTemporally("~", mediaEntry.GetWork().LastUpdated, time.Second))
+		})
+	})
+
+	Context("Get all the tropes of the persisted Media", func() {
+		var datasetTropes map[string]struct{}
+		var errGetTropes error
+
+		BeforeEach(func() {
+			errAddMedia = repository.AddMedia(mediaEntry)
+			Expect(errAddMedia).To(BeNil())
+			errPersist = repository.Persist()
+			Expect(errPersist).To(BeNil())
+
+			datasetTropes, errGetTropes = repository.GetTropes()
+		})
+
+		It("Shouldn't return an error", func() {
+			Expect(errGetTropes).To(BeNil())
+		})
+
+		It("Should return the title of every trope and subtrope", func() {
+			Expect(datasetTropes).To(HaveLen(len(tropes)))
+			for mediaTrope := range tropes {
+				Expect(datasetTropes).To(HaveKey(mediaTrope.GetTitle()))
+			}
+		})
+	})
+
+	Context("Get the relations of the persisted Media", func() {
+		var datasetRelations map[string][]trope.Relation
+		var errGetRelations error
+
+		BeforeEach(func() {
+			errAddMedia = repository.AddMedia(mediaEntry)
+			Expect(errAddMedia).To(BeNil())
+			errPersist = repository.Persist()
+			Expect(errPersist).To(BeNil())
+
+			datasetRelations, errGetRelations = repository.GetWorkRelations()
+		})
+
+		It("Shouldn't return an error", func() {
+			Expect(errGetRelations).To(BeNil())
+		})
+
+		It("Should return the relations of every Work by its URL", func() {
+			Expect(datasetRelations).To(HaveLen(1))
+			Expect(datasetRelations[oldboyUrl]).To(Equal(mediaEntry.GetRelations()))
+		})
+	})
+})
+
+var _ = repository_conformance.DescribeRepository("Parquet", func(name string) (media.RepositoryMedia, error) {
+	return parquet_dataset.NewParquetRepository(name)
+}, repository_conformance.Features{})
+
+var _ = AfterSuite(func() {
+	os.Remove("dataset.parquet")
+
+	backups, _ := filepath.Glob("dataset.parquet" + dataset_file.BackupSuffix + "*")
+	for _, backup := range backups {
+		os.Remove(backup)
+	}
+})
+
+// correctParquetFile checks if the Parquet file starts and ends with the magic bytes and has a footer that fits in it
+func correctParquetFile() {
+	fileContents, errRead := os.ReadFile("dataset.parquet")
+	Expect(errRead).To(BeNil())
+	Expect(len(fileContents) > 2*len(parquetMagic)+parquetFooter).To(BeTrue())
+	Expect(string(fileContents[:len(parquetMagic)])).To(Equal(parquetMagic))
+	Expect(string(fileContents[len(fileContents)-len(parquetMagic):])).To(Equal(parquetMagic))
+
+	trailer := fileContents[len(fileContents)-parquetFooter:]
+	footerLength := int(trailer[0]) | int(trailer[1])<<8 | int(trailer[2])<<16 | int(trailer[3])<<24
+	Expect(footerLength <= len(fileContents)-len(parquetMagic)-parquetFooter).To(BeTrue())
+}
+
+// correctRecord checks if the only record of the Parquet file, read with a new repository after closing the current one, has the URL and tropes
+func correctRecord(workUrl string, workTropes map[trope.Trope]struct{}) {
+	Expect(repository.Close()).To(BeNil())
+
+	reopened, errReopen := parquet_dataset.NewParquetRepository("dataset")
+	Expect(errReopen).To(BeNil())
+	Expect(errPersist).To(BeNil())
+
+	workPages, errWorkPages := reopened.GetWorkPages()
+	Expect(errWorkPages).To(BeNil())
+	Expect(workPages).To(HaveLen(1))
+	Expect(workPages).To(HaveKey(workUrl))
+
+	datasetTropes, errGetTropes := reopened.GetTropes()
+	Expect(errGetTropes).To(BeNil())
+	Expect(datasetTropes).To(HaveLen(len(workTropes)))
+}
+
+// fileSize returns the size in bytes of the Parquet file
+func fileSize() int64 {
+	info, errStat := os.Stat("dataset.parquet")
+	Expect(errStat).To(BeNil())
+
+	return info.Size()
+}
+
+// createTropes generates a map of numTropes size applying a callback function to all elements
+func createTropes(numTropes int, callback func() trope.Trope) map[trope.Trope]struct{} {
+	tropeset := make(map[trope.Trope]struct{}, numTropes)
+
+	for i := 0; i < numTropes; i++ {
+		tropeset[callback()] = struct{}{}
+	}
+
+	return tropeset
+}
+
+var randomTrope = func() trope.Trope {
+	trope, _ := trope.NewTrope("Trope"+fmt.Sprint(seededRand.Int()), 1, "")
+	return trope
+}
+
+var randomSubTrope = func() trope.Trope {
+	subWikis := []string{"SubWiki1", "SubWiki2"}
+	trope, _ := trope.NewTrope("Trope"+fmt.Sprint(seededRand.Int()), 1, subWikis[seededRand.Intn(1)])
+
+	return trope
+}
@@ -0,0 +1,554 @@
+package parquet_dataset
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/jlgallego99/TropesToGo/media"
+)
+
+// Values of the Parquet format enums that the dataset uses
+const (
+	typeByteArray       int32 = 6
+	repetitionRequired  int32 = 0
+	repetitionRepeated  int32 = 2
+	convertedUTF8       int32 = 0
+	convertedList       int32 = 3
+	encodingPlain       int32 = 0
+	encodingRLE         int32 = 3
+	codecUncompressed   int32 = 0
+	pageData            int32 = 0
+	parquetVersion      int32 = 1
+	parquetCreatedBy          = "tropestogo"
+	parquetSchemaName         = "tropestogo"
+	parquetListGroup          = "list"
+	parquetListElement        = "element"
+	parquetFooterLength       = 8
+)
+
+// parquetMagic starts and ends every Parquet file
+var parquetMagic = []byte("PAR1")
+
+var errUnsupportedParquet = errors.New("unsupported Parquet encoding")
+
+// flatColumn is a required string column of the dataset with one value per Work
+type flatColumn struct {
+	name string
+
+	// field points to the value of the column on a record
+	field func(record *media.JsonResponse) *string
+}
+
+// listColumn is a required list column of the dataset whose elements are structs of string fields, stored as one leaf column per field
+type listColumn struct {
+	name   string
+	fields []string
+
+	// elements returns the values of the fields of every element of the list on a record
+	elements func(record *media.JsonResponse) [][]string
+
+	// setElements sets on a record the list with the values of the fields of every element
+	setElements func(record *media.JsonResponse, elements [][]string)
+}
+
+// flatColumns are the columns of the dataset with the identification and crawl metadata of each Work
+var flatColumns = []flatColumn{
+	{"title", func(record *media.JsonResponse) *string { return &record.Title }},
+	{"year", func(record *media.JsonResponse) *string { return &record.Year }},
+	{"media_type", func(record *media.JsonResponse) *string { return &record.MediaType }},
+	{"last_updated", func(record *media.JsonResponse) *string { return &record.LastUpdated }},
+	{"url", func(record *media.JsonResponse) *string { return &record.URL }},
+}
+
+// listColumns are the columns of the dataset with the tropes, subtropes and relations of each Work
+var listColumns = []listColumn{
+	{
+		name:        "tropes",
+		fields:      []string{"title", "namespace"},
+		elements:    func(record *media.JsonResponse) [][]string { return tropeElements(record.Tropes) },
+		setElements: func(record *media.JsonResponse, elements [][]string) { record.Tropes = elementTropes(elements) },
+	},
+	{
+		name:        "sub_tropes",
+		fields:      []string{"title", "namespace"},
+		elements:    func(record *media.JsonResponse) [][]string { return tropeElements(record.SubTropes) },
+		setElements: func(record *media.JsonResponse, elements [][]string) { record.SubTropes = elementTropes(elements) },
+	},
+	{
+		name:   "relations",
+		fields: []string{"url", "anchor_text", "context", "type"},
+		elements: func(record *media.JsonResponse) [][]string {
+			elements := make([][]string, 0, len(record.Relations))
+			for _, relation := range record.Relations {
+				elements = append(elements, []string{relation.URL, relation.AnchorText, relation.Context, relation.Type})
+			}
+
+			return elements
+		},
+		setElements: func(record *media.JsonResponse, elements [][]string) {
+			record.Relations = make([]media.JsonRelation, 0, len(elements))
+			for _, element := range elements {
+				record.Relations = append(record.Relations, media.JsonRelation{URL: element[0], AnchorText: element[1], Context: element[2], Type: element[3]})
+			}
+		},
+	},
+}
+
+// tropeElements transforms tropes into list elements with their title and namespace
+func tropeElements(tropes []media.JsonTrope) [][]string {
+	elements := make([][]string, 0, len(tropes))
+	for _, jsonTrope := range tropes {
+		elements = append(elements, []string{jsonTrope.Title, jsonTrope.Namespace})
+	}
+
+	return elements
+}
+
+// elementTropes transforms list elements with their title and namespace back into tropes
+func elementTropes(elements [][]string) []media.JsonTrope {
+	tropes := make([]media.JsonTrope, 0, len(elements))
+	for _, element := range elements {
+		tropes = append(tropes, media.JsonTrope{Title: element[0], Namespace: element[1]})
+	}
+
+	return tropes
+}
+
+// fileMetadata is the part of the Parquet footer that the dataset uses, because its schema is always the same
+type fileMetadata struct {
+	numRows   int64
+	rowGroups []rowGroup
+}
+
+// rowGroup is a horizontal partition of the dataset, with a chunk for every leaf column
+type rowGroup struct {
+	numRows       int64
+	totalByteSize int64
+	columns       []columnChunk
+}
+
+// columnChunk is where the values of a leaf column of a row group are on the file
+type columnChunk struct {
+	path             []string
+	numValues        int64
+	uncompressedSize int64
+	compressedSize   int64
+	dataPageOffset   int64
+}
+
+// encodeFooter encodes the Parquet footer with the schema of the dataset and the metadata, followed by its length and the magic bytes
+func encodeFooter(metadata fileMetadata) []byte {
+	writer := &compactWriter{}
+	writer.structBegin()
+	writer.i32Field(1, parquetVersion)
+
+	schemaSize := 1 + len(flatColumns)
+	for _, column := range listColumns {
+		schemaSize += 3 + len(column.fields)
+	}
+	writer.listField(2, compactStruct, schemaSize)
+	encodeSchemaElement(writer, parquetSchemaName, -1, -1, int32(len(flatColumns)+len(listColumns)), -1)
+	for _, column := range flatColumns {
+		encodeSchemaElement(writer, column.name, typeByteArray, repetitionRequired, 0, convertedUTF8)
+	}
+	for _, column := range listColumns {
+		encodeSchemaElement(writer, column.name, -1, repetitionRequired, 1, convertedList)
+		encodeSchemaElement(writer, parquetListGroup, -1, repetitionRepeated, 1, -1)
+		encodeSchemaElement(writer, parquetListElement, -1, repetitionRequired, int32(len(column.fields)), -1)
+		for _, field := range column.fields {
+			encodeSchemaElement(writer, field, typeByteArray, repetitionRequired, 0, convertedUTF8)
+		}
+	}
+
+	writer.i64Field(3, metadata.numRows)
+	writer.listField(4, compactStruct, len(metadata.rowGroups))
+	for _, group := range metadata.rowGroups {
+		writer.structBegin()
+		writer.listField(1, compactStruct, len(group.columns))
+		for _, chunk := range group.columns {
+			writer.structBegin()
+			writer.i64Field(2, chunk.dataPageOffset)
+			writer.structField(3)
+			writer.i32Field(1, typeByteArray)
+			writer.i32ListField(2, []int32{encodingPlain, encodingRLE})
+			writer.stringListField(3, chunk.path)
+			writer.i32Field(4, codecUncompressed)
+			writer.i64Field(5, chunk.numValues)
+			writer.i64Field(6, chunk.uncompressedSize)
+			writer.i64Field(7, chunk.compressedSize)
+			writer.i64Field(9, chunk.dataPageOffset)
+			writer.structEnd()
+			writer.structEnd()
+		}
+		writer.i64Field(2, group.totalByteSize)
+		writer.i64Field(3, group.numRows)
+		writer.structEnd()
+	}
+	writer.stringField(6, parquetCreatedBy)
+	writer.structEnd()
+
+	footer := writer.buffer.Bytes()
+	footer = binary.LittleEndian.AppendUint32(footer, uint32(len(footer)))
+
+	return append(footer, parquetMagic...)
+}
+
+// encodeSchemaElement encodes an element of the schema, leaving out the optional fields with negative values
+func encodeSchemaElement(writer *compactWriter, name string, elementType, repetition, numChildren, convertedType int32) {
+	writer.structBegin()
+	if elementType >= 0 {
+		writer.i32Field(1, elementType)
+	}
+	if repetition >= 0 {
+		writer.i32Field(3, repetition)
+	}
+	writer.stringField(4, name)
+	if numChildren > 0 {
+		writer.i32Field(5, numChildren)
+	}
+	if convertedType >= 0 {
+		writer.i32Field(6, convertedType)
+	}
+	writer.structEnd()
+}
+
+// decodeFooter decodes the metadata of the Parquet footer, read without its length and magic bytes
+func decodeFooter(footer []byte) (fileMetadata, error) {
+	decoded, errDecode := readStruct(bytes.NewReader(footer))
+	if errDecode != nil {
+		return fileMetadata{}, errDecode
+	}
+
+	metadata := fileMetadata{numRows: decoded.getInt(3)}
+	for _, groupValue := range decoded.getList(4) {
+		groupStruct, _ := groupValue.(thriftStruct)
+		group := rowGroup{numRows: groupStruct.getInt(3), totalByteSize: groupStruct.getInt(2)}
+		for _, chunkValue := range groupStruct.getList(1) {
+			chunkStruct, _ := chunkValue.(thriftStruct)
+			chunkMetadata := chunkStruct.getStruct(3)
+			if codec := chunkMetadata.getInt(4); codec != int64(codecUncompressed) {
+				return fileMetadata{}, fmt.Errorf("%w: compression codec %d", errUnsupportedParquet, codec)
+			}
+
+			chunk := columnChunk{
+				numValues:        chunkMetadata.getInt(5),
+				uncompressedSize: chunkMetadata.getInt(6),
+				compressedSize:   chunkMetadata.getInt(7),
+				dataPageOffset:   chunkMetadata.getInt(9),
+			}
+			for _, pathValue := range chunkMetadata.getList(3) {
+				pathElement, _ := pathValue.([]byte)
+				chunk.path = append(chunk.path, string(pathElement))
+			}
+
+			group.columns = append(group.columns, chunk)
+		}
+
+		metadata.rowGroups = append(metadata.rowGroups, group)
+	}
+
+	return metadata, nil
+}
+
+// encodeRowGroup encodes the records as a row group whose column chunks start at the offset of the file
+// It returns the encoded column chunks, one data page each, and the metadata of the row group
+func encodeRowGroup(records []media.JsonResponse, offset int64) ([]byte, rowGroup) {
+	var encoded bytes.Buffer
+	group := rowGroup{numRows: int64(len(records))}
+
+	writeChunk := func(path []string, numValues int, page []byte) {
+		header := &compactWriter{}
+		header.structBegin()
+		header.i32Field(1, pageData)
+		header.i32Field(2, int32(len(page)))
+		header.i32Field(3, int32(len(page)))
+		header.structField(5)
+		header.i32Field(1, int32(numValues))
+		header.i32Field(2, encodingPlain)
+		header.i32Field(3, encodingRLE)
+		header.i32Field(4, encodingRLE)
+		header.structEnd()
+		header.structEnd()
+
+		chunkSize := int64(header.buffer.Len() + len(page))
+		group.columns = append(group.columns, columnChunk{
+			path:             path,
+			numValues:        int64(numValues),
+			uncompressedSize: chunkSize,
+			compressedSize:   chunkSize,
+			dataPageOffset:   offset + int64(encoded.Len()),
+		})
+		group.totalByteSize += chunkSize
+
+		encoded.Write(header.buffer.Bytes())
+		encoded.Write(page)
+	}
+
+	for _, column := range flatColumns {
+		var page bytes.Buffer
+		for i := range records {
+			writePlain(&page, *column.field(&records[i]))
+		}
+
+		writeChunk([]string{column.name}, len(records), page.Bytes())
+	}
+
+	for _, column := range listColumns {
+		recordElements := make([][][]string, 0, len(records))
+		for i := range records {
+			recordElements = append(recordElements, column.elements(&records[i]))
+		}
+
+		// Every field of the elements has the same levels: an empty list is a single level of 0,
+		// and every element has a definition level of 1 and a repetition level of 1 except the first one of its list
+		var repetitionLevels, definitionLevels []int
+		for _, elements := range recordElements {
+			if len(elements) == 0 {
+				repetitionLevels = append(repetitionLevels, 0)
+				definitionLevels = append(definitionLevels, 0)
+			}
+
+			for position := range elements {
+				repetitionLevels = append(repetitionLevels, min(position, 1))
+				definitionLevels = append(definitionLevels, 1)
+			}
+		}
+
+		for field, fieldName := range column.fields {
+			var page bytes.Buffer
+			writeLevels(&page, repetitionLevels)
+			writeLevels(&page, definitionLevels)
+			for _, elements := range recordElements {
+				for _, element := range elements {
+					writePlain(&page, element[field])
+				}
+			}
+
+			writeChunk([]string{column.name, parquetListGroup, parquetListElement, fieldName}, len(repetitionLevels), page.Bytes())
+		}
+	}
+
+	return encoded.Bytes(), group
+}
+
+// min returns the smallest of two integers
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+
+	return b
+}
+
+// writePlain writes a byte array value with the plain encoding, prefixed by its length
+func writePlain(page *bytes.Buffer, value string) {
+	page.Write(binary.LittleEndian.AppendUint32(nil, uint32(len(value))))
+	page.WriteString(value)
+}
+
+// writeLevels writes levels of a bit width of 1 with the RLE hybrid encoding prefixed by its length, using only runs of repeated values
+func writeLevels(page *bytes.Buffer, levels []int) {
+	var encoded []byte
+	for start := 0; start < len(levels); {
+		end := start
+		for end < len(levels) && levels[end] == levels[start] {
+			end++
+		}
+
+		encoded = binary.AppendUvarint(encoded, uint64(end-start)<<1)
+		encoded = append(encoded, byte(levels[start]))
+		start = end
+	}
+
+	page.Write(binary.LittleEndian.AppendUint32(nil, uint32(len(encoded))))
+	page.Write(encoded)
+}
+
+// columnValues are the decoded levels and values of a leaf column chunk
+type columnValues struct {
+	repetitionLevels []int
+	definitionLevels []int
+	values           []string
+}
+
+// decodeColumnChunk decodes the data pages of a leaf column chunk, whose max levels are 1 for list columns and 0 for flat columns
+func decodeColumnChunk(chunk []byte, isList bool) (columnValues, error) {
+	var decoded columnValues
+	reader := bytes.NewReader(chunk)
+	for reader.Len() > 0 {
+		header, errHeader := readStruct(reader)
+		if errHeader != nil {
+			return columnValues{}, errHeader
+		}
+
+		pageSize := header.getInt(3)
+		if pageSize < 0 || pageSize > int64(reader.Len()) {
+			return columnValues{}, io.ErrUnexpectedEOF
+		}
+		page := make([]byte, pageSize)
+		io.ReadFull(reader, page)
+
+		if pageType := header.getInt(1); pageType != int64(pageData) {
+			return columnValues{}, fmt.Errorf("%w: page type %d", errUnsupportedParquet, pageType)
+		}
+		dataHeader := header.getStruct(5)
+		if encoding := dataHeader.getInt(2); encoding != int64(encodingPlain) {
+			return columnValues{}, fmt.Errorf("%w: value encoding %d", errUnsupportedParquet, encoding)
+		}
+
+		numValues := int(dataHeader.getInt(1))
+		numPresent := numValues
+		pageReader := bytes.NewReader(page)
+		if isList {
+			repetitionLevels, errRepetition := readLevels(pageReader, numValues)
+			if errRepetition != nil {
+				return columnValues{}, errRepetition
+			}
+
+			definitionLevels, errDefinition := readLevels(pageReader, numValues)
+			if errDefinition != nil {
+				return columnValues{}, errDefinition
+			}
+
+			numPresent = 0
+			for _, level := range definitionLevels {
+				numPresent += level
+			}
+
+			decoded.repetitionLevels = append(decoded.repetitionLevels, repetitionLevels...)
+			decoded.definitionLevels = append(decoded.definitionLevels, definitionLevels...)
+		}
+
+		for i := 0; i < numPresent; i++ {
+			var size uint32
+			if errSize := binary.Read(pageReader, binary.LittleEndian, &size); errSize != nil {
+				return columnValues{}, errSize
+			}
+			if int64(size) > int64(pageReader.Len()) {
+				return columnValues{}, io.ErrUnexpectedEOF
+			}
+
+			value := make([]byte, size)
+			io.ReadFull(pageReader, value)
+			decoded.values = append(decoded.values, string(value))
+		}
+	}
+
+	return decoded, nil
+}
+
+// readLevels reads numLevels levels of a bit width of 1 encoded with the RLE hybrid encoding prefixed by its length
+func readLevels(reader *bytes.Reader, numLevels int) ([]int, error) {
+	var size uint32
+	if errSize := binary.Read(reader, binary.LittleEndian, &size); errSize != nil {
+		return nil, errSize
+	}
+	if int64(size) > int64(reader.Len()) {
+		return nil, io.ErrUnexpectedEOF
+	}
+
+	encoded := make([]byte, size)
+	io.ReadFull(reader, encoded)
+	encodedReader := bytes.NewReader(encoded)
+
+	levels := make([]int, 0, numLevels)
+	for len(levels) < numLevels {
+		header, errHeader := binary.ReadUvarint(encodedReader)
+		if errHeader != nil {
+			return nil, errHeader
+		}
+
+		if header&1 == 0 {
+			// Run of a repeated value
+			value, errValue := encodedReader.ReadByte()
+			if errValue != nil {
+				return nil, errValue
+			}
+
+			for i := uint64(0); i < header>>1 && len(levels) < numLevels; i++ {
+				levels = append(levels, int(value&1))
+			}
+		} else {
+			// Groups of 8 bit-packed values, which take one byte each with a bit width of 1
+			for group := uint64(0); group < header>>1; group++ {
+				packed, errPacked := encodedReader.ReadByte()
+				if errPacked != nil {
+					return nil, errPacked
+				}
+
+				for bit := 0; bit < 8 && len(levels) < numLevels; bit++ {
+					levels = append(levels, int(packed>>bit&1))
+				}
+			}
+		}
+	}
+
+	return levels, nil
+}
+
+// decodeRecords assembles the records of a row group from the decoded values of its leaf columns, by their dotted path
+// Columns without values are left empty on the records
+func decodeRecords(numRows int64, columns map[string]columnValues) ([]media.JsonResponse, error) {
+	records := make([]media.JsonResponse, numRows)
+
+	for _, column := range flatColumns {
+		decoded, exists := columns[column.name]
+		if !exists {
+			continue
+		}
+		if len(decoded.values) != len(records) {
+			return nil, fmt.Errorf("%w: column %s has %d values for %d rows", errUnsupportedParquet, column.name, len(decoded.values), numRows)
+		}
+
+		for i := range records {
+			*column.field(&records[i]) = decoded.values[i]
+		}
+	}
+
+	for _, column := range listColumns {
+		fieldValues := make([]columnValues, 0, len(column.fields))
+		for _, field := range column.fields {
+			if decoded, exists := columns[strings.Join([]string{column.name, parquetListGroup, parquetListElement, field}, ".")]; exists {
+				fieldValues = append(fieldValues, decoded)
+			}
+		}
+		if len(fieldValues) != len(column.fields) {
+			continue
+		}
+
+		// The levels of the first field delimit the lists of every record, which take a value of every field per element
+		row, value := -1, 0
+		recordElements := make([][][]string, numRows)
+		for level, repetitionLevel := range fieldValues[0].repetitionLevels {
+			if repetitionLevel == 0 {
+				row++
+			}
+			if row < 0 || row >= len(records) {
+				return nil, fmt.Errorf("%w: column %s has more lists than rows", errUnsupportedParquet, column.name)
+			}
+			if fieldValues[0].definitionLevels[level] == 0 {
+				continue
+			}
+
+			element := make([]string, 0, len(column.fields))
+			for _, decoded := range fieldValues {
+				if value >= len(decoded.values) {
+					return nil, fmt.Errorf("%w: column %s has missing values", errUnsupportedParquet, column.name)
+				}
+				element = append(element, decoded.values[value])
+			}
+			recordElements[row] = append(recordElements[row], element)
+			value++
+		}
+
+		for i := range records {
+			column.setElements(&records[i], recordElements[i])
+		}
+	}
+
+	return records, nil
+}
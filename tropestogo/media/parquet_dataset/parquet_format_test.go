@@ -0,0 +1,651 @@
+package parquet_dataset_test
+
+import (
+	"context"
+	"encoding/binary"
+	"flag"
+	"fmt"
+	"math"
+	"math/bits"
+	"os"
+	"time"
+
+	"github.com/jlgallego99/TropesToGo/media"
+	"github.com/jlgallego99/TropesToGo/media/parquet_dataset"
+	"github.com/jlgallego99/TropesToGo/trope"
+	"github.com/jlgallego99/TropesToGo/tvtropespages"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+// The file is read here following the Apache Parquet and Thrift compact protocol specifications,
+// without the reader of the repository, so a mistake on its encoding can't be hidden by the same mistake on its decoding
+
+// Types of the Thrift compact protocol
+const (
+	thriftStop   = 0
+	thriftTrue   = 1
+	thriftFalse  = 2
+	thriftByte   = 3
+	thriftI16    = 4
+	thriftI32    = 5
+	thriftI64    = 6
+	thriftDouble = 7
+	thriftBinary = 8
+	thriftList   = 9
+	thriftSet    = 10
+	thriftMap    = 11
+	thriftStruct = 12
+)
+
+// Values of the enums of the Parquet specification
+const (
+	specByteArray    int32 = 6
+	specRequired     int32 = 0
+	specOptional     int32 = 1
+	specRepeated     int32 = 2
+	specUTF8         int32 = 0
+	specList         int32 = 3
+	specPlain        int32 = 0
+	specRLE          int32 = 3
+	specUncompressed int32 = 0
+	specDataPage     int32 = 0
+	specNone         int32 = -1
+)
+
+// thriftFields are the values of the fields of a Thrift struct by their id
+type thriftFields map[int16]interface{}
+
+// compactReader decodes values of the Thrift compact protocol
+type compactReader struct {
+	data     []byte
+	position int
+}
+
+// schemaElement is an element of the Parquet schema, with specNone on the fields that it doesn't have
+type schemaElement struct {
+	name          string
+	elementType   int32
+	repetition    int32
+	numChildren   int32
+	convertedType int32
+}
+
+// leafColumn is a leaf of the Parquet schema with its path and the maximum repetition and definition levels of its values
+type leafColumn struct {
+	path          []string
+	maxRepetition int
+	maxDefinition int
+}
+
+// parquetRow is a row of the file with the value of every flat column and the elements of every list column by their field names
+type parquetRow struct {
+	values map[string]string
+	lists  map[string][]map[string]string
+}
+
+// expectedSchema is the schema of the dataset, which must be the same on every file
+var expectedSchema = []schemaElement{
+	{"tropestogo", specNone, specNone, 8, specNone},
+	{"title", specByteArray, specRequired, specNone, specUTF8},
+	{"year", specByteArray, specRequired, specNone, specUTF8},
+	{"media_type", specByteArray, specRequired, specNone, specUTF8},
+	{"last_updated", specByteArray, specRequired, specNone, specUTF8},
+	{"url", specByteArray, specRequired, specNone, specUTF8},
+	{"tropes", specNone, specRequired, 1, specList},
+	{"list", specNone, specRepeated, 1, specNone},
+	{"element", specNone, specRequired, 2, specNone},
+	{"title", specByteArray, specRequired, specNone, specUTF8},
+	{"namespace", specByteArray, specRequired, specNone, specUTF8},
+	{"sub_tropes", specNone, specRequired, 1, specList},
+	{"list", specNone, specRepeated, 1, specNone},
+	{"element", specNone, specRequired, 2, specNone},
+	{"title", specByteArray, specRequired, specNone, specUTF8},
+	{"namespace", specByteArray, specRequired, specNone, specUTF8},
+	{"relations", specNone, specRequired, 1, specList},
+	{"list", specNone, specRepeated, 1, specNone},
+	{"element", specNone, specRequired, 4, specNone},
+	{"url", specByteArray, specRequired, specNone, specUTF8},
+	{"anchor_text", specByteArray, specRequired, specNone, specUTF8},
+	{"context", specByteArray, specRequired, specNone, specUTF8},
+	{"type", specByteArray, specRequired, specNone, specUTF8},
+}
+
+var _ = Describe("ParquetFormat", func() {
+	var works []media.Media
+	var fileContents []byte
+	var metadata thriftFields
+	var footerStart int
+
+	BeforeEach(func() {
+		// Three works of different media types, with and without subtropes and relations, on row groups of two records
+		seriesPage, _ := tvtropespages.NewPage(context.Background(), "https://tvtropes.org/pmwiki/pmwiki.php/Series/Format", false, nil)
+		seriesTropes := createTropes(numTropes, randomSubTrope)
+		for workTrope := range createTropes(numTropes, randomTrope) {
+			seriesTropes[workTrope] = struct{}{}
+		}
+		seriesEntry, _ := media.NewMedia("Format", "2010", time.Now(), seriesTropes, seriesPage, media.Series)
+
+		filmPage, _ := tvtropespages.NewPage(context.Background(), "https://tvtropes.org/pmwiki/pmwiki.php/Film/Format", false, nil)
+		filmEntry, _ := media.NewMedia("Format", "2020", time.Now(), createTropes(numTropes, randomTrope), filmPage, media.Film)
+
+		works = []media.Media{mediaEntry, seriesEntry, filmEntry}
+
+		formatRepository, errFormat := parquet_dataset.NewParquetRepository("format", parquet_dataset.ConfigRowGroupSize(2))
+		Expect(errFormat).To(BeNil())
+		for _, work := range works {
+			Expect(formatRepository.AddMedia(work)).To(BeNil())
+			Expect(formatRepository.Persist()).To(BeNil())
+		}
+		Expect(formatRepository.Close()).To(BeNil())
+
+		var errRead error
+		fileContents, errRead = os.ReadFile("format.parquet")
+		Expect(errRead).To(BeNil())
+
+		// The file ends with the footer, its length and the magic bytes
+		Expect(len(fileContents)).To(BeNumerically(">", 2*len(parquetMagic)+4))
+		footerLength := int(binary.LittleEndian.Uint32(fileContents[len(fileContents)-parquetFooter:]))
+		footerStart = len(fileContents) - parquetFooter - footerLength
+		Expect(footerStart).To(BeNumerically(">=", len(parquetMagic)))
+
+		footer := &compactReader{data: fileContents[footerStart : len(fileContents)-parquetFooter]}
+		metadata = footer.structValue()
+		Expect(footer.position).To(Equal(len(footer.data)))
+	})
+
+	AfterEach(func() {
+		os.Remove("format.parquet")
+	})
+
+	Context("Read the Parquet file following its specification", func() {
+		It("Should start and end with the magic bytes", func() {
+			Expect(string(fileContents[:len(parquetMagic)])).To(Equal(parquetMagic))
+			Expect(string(fileContents[len(fileContents)-len(parquetMagic):])).To(Equal(parquetMagic))
+		})
+
+		It("Should have the metadata of the file", func() {
+			Expect(i32Field(metadata, 1)).To(Equal(int32(1)))
+			Expect(i64Field(metadata, 3)).To(Equal(int64(len(works))))
+			Expect(stringField(metadata, 6)).NotTo(BeEmpty())
+		})
+
+		It("Should have the schema of the dataset", func() {
+			var schema []schemaElement
+			for _, element := range listField(metadata, 2) {
+				schema = append(schema, toSchemaElement(element.(thriftFields)))
+			}
+
+			Expect(schema).To(Equal(expectedSchema))
+		})
+
+		It("Should have row groups of the configured size whose column chunks follow each other", func() {
+			leaves := schemaLeaves(expectedSchema)
+			rowGroups := listField(metadata, 4)
+			Expect(rowGroups).To(HaveLen(2))
+
+			offset := int64(len(parquetMagic))
+			for group, groupValue := range rowGroups {
+				rowGroup := groupValue.(thriftFields)
+				Expect(i64Field(rowGroup, 3)).To(Equal(int64(2 - group)))
+
+				chunks := listField(rowGroup, 1)
+				Expect(chunks).To(HaveLen(len(leaves)))
+
+				var groupSize int64
+				for leaf, chunkValue := range chunks {
+					chunk := chunkValue.(thriftFields)
+					i64Field(chunk, 2)
+
+					chunkMetadata := structField(chunk, 3)
+					Expect(i32Field(chunkMetadata, 1)).To(Equal(specByteArray))
+					Expect(listField(chunkMetadata, 2)).To(ConsistOf(specPlain, specRLE))
+					Expect(listField(chunkMetadata, 3)).To(HaveLen(len(leaves[leaf].path)))
+					for depth, pathElement := range listField(chunkMetadata, 3) {
+						Expect(pathElement).To(Equal(leaves[leaf].path[depth]))
+					}
+					Expect(i32Field(chunkMetadata, 4)).To(Equal(specUncompressed))
+					Expect(i64Field(chunkMetadata, 6)).To(Equal(i64Field(chunkMetadata, 7)))
+					Expect(i64Field(chunkMetadata, 9)).To(Equal(offset))
+
+					offset += i64Field(chunkMetadata, 7)
+					groupSize += i64Field(chunkMetadata, 6)
+				}
+
+				Expect(i64Field(rowGroup, 2)).To(Equal(groupSize))
+			}
+
+			Expect(offset).To(Equal(int64(footerStart)))
+		})
+
+		It("Should have every work with its values on the column chunks", func() {
+			rows := readRows(fileContents, metadata, schemaLeaves(expectedSchema))
+			Expect(rows).To(HaveLen(len(works)))
+
+			for i, work := range works {
+				expected := expectedRow(work)
+				Expect(rows[i].values).To(Equal(expected.values))
+				for column, elements := range expected.lists {
+					Expect(rows[i].lists[column]).To(ConsistOf(elements))
+				}
+			}
+		})
+	})
+})
+
+// expectedRow returns the row of a Work as it's defined by the schema of the dataset
+func expectedRow(work media.Media) parquetRow {
+	row := parquetRow{
+		values: map[string]string{
+			"title":        work.GetWork().Title,
+			"year":         work.GetWork().Year,
+			"media_type":   work.GetMediaType().String(),
+			"last_updated": work.GetWork().LastUpdated.Format(time.DateTime),
+			"url":          work.GetPage().GetUrl().String(),
+		},
+		lists: map[string][]map[string]string{"tropes": {}, "sub_tropes": {}, "relations": {}},
+	}
+
+	tropes, subTropes := media.GetJsonTropes(work)
+	for _, jsonTrope := range tropes {
+		row.lists["tropes"] = append(row.lists["tropes"], map[string]string{"title": jsonTrope.Title, "namespace": jsonTrope.Namespace})
+	}
+	for _, jsonTrope := range subTropes {
+		row.lists["sub_tropes"] = append(row.lists["sub_tropes"], map[string]string{"title": jsonTrope.Title, "namespace": jsonTrope.Namespace})
+	}
+	for _, relation := range media.GetJsonRelations(work.GetRelations()) {
+		row.lists["relations"] = append(row.lists["relations"], map[string]string{
+			"url": relation.URL, "anchor_text": relation.AnchorText, "context": relation.Context, "type": relation.Type,
+		})
+	}
+
+	return row
+}
+
+// readRows reads every row of the file, row group by row group, assembling the lists of the leaf columns of three-level list groups
+func readRows(fileContents []byte, metadata thriftFields, leaves []leafColumn) []parquetRow {
+	var rows []parquetRow
+	for _, groupValue := range listField(metadata, 4) {
+		rowGroup := groupValue.(thriftFields)
+		groupRows := make([]parquetRow, i64Field(rowGroup, 3))
+		for i := range groupRows {
+			groupRows[i] = parquetRow{values: make(map[string]string), lists: make(map[string][]map[string]string)}
+		}
+
+		for leaf, chunkValue := range listField(rowGroup, 1) {
+			chunkMetadata := structField(chunkValue.(thriftFields), 3)
+			start := i64Field(chunkMetadata, 9)
+			chunk := fileContents[start : start+i64Field(chunkMetadata, 7)]
+			repetitionLevels, definitionLevels, values := readColumnChunk(chunk, leaves[leaf])
+			Expect(int64(len(repetitionLevels))).To(Equal(i64Field(chunkMetadata, 5)))
+
+			column := leaves[leaf].path
+			if leaves[leaf].maxRepetition == 0 {
+				Expect(values).To(HaveLen(len(groupRows)))
+				for i, value := range values {
+					groupRows[i].values[column[0]] = value
+				}
+
+				continue
+			}
+
+			// A repetition level of 0 starts the list of the next row, whose elements have the maximum definition level
+			row, element, value := -1, 0, 0
+			for level, repetitionLevel := range repetitionLevels {
+				if repetitionLevel == 0 {
+					row++
+					element = 0
+				}
+				Expect(row).To(BeNumerically("<", len(groupRows)))
+
+				elements := groupRows[row].lists[column[0]]
+				if elements == nil {
+					elements = []map[string]string{}
+				}
+				if definitionLevels[level] == leaves[leaf].maxDefinition {
+					if element == len(elements) {
+						elements = append(elements, make(map[string]string))
+					}
+					elements[element][column[len(column)-1]] = values[value]
+					element++
+					value++
+				}
+				groupRows[row].lists[column[0]] = elements
+			}
+			Expect(row).To(Equal(len(groupRows) - 1))
+		}
+
+		rows = append(rows, groupRows...)
+	}
+
+	return rows
+}
+
+// readColumnChunk reads the data pages of a column chunk, returning the levels of every value and the values that are defined
+func readColumnChunk(chunk []byte, leaf leafColumn) ([]int, []int, []string) {
+	var repetitionLevels, definitionLevels []int
+	var values []string
+
+	reader := &compactReader{data: chunk}
+	for reader.position < len(reader.data) {
+		header := reader.structValue()
+		Expect(i32Field(header, 1)).To(Equal(specDataPage))
+		Expect(i32Field(header, 2)).To(Equal(i32Field(header, 3)))
+
+		dataPageHeader := structField(header, 5)
+		numValues := int(i32Field(dataPageHeader, 1))
+		Expect(i32Field(dataPageHeader, 2)).To(Equal(specPlain))
+		Expect(i32Field(dataPageHeader, 3)).To(Equal(specRLE))
+		Expect(i32Field(dataPageHeader, 4)).To(Equal(specRLE))
+
+		// Repetition levels, definition levels and values, with the levels left out if their maximum is 0
+		page := &compactReader{data: reader.bytes(int(i32Field(header, 3)))}
+		pageRepetition := readLevels(page, leaf.maxRepetition, numValues)
+		pageDefinition := readLevels(page, leaf.maxDefinition, numValues)
+		for _, level := range pageDefinition {
+			if level == leaf.maxDefinition {
+				length := int(binary.LittleEndian.Uint32(page.bytes(4)))
+				values = append(values, string(page.bytes(length)))
+			}
+		}
+		Expect(page.position).To(Equal(len(page.data)))
+
+		repetitionLevels = append(repetitionLevels, pageRepetition...)
+		definitionLevels = append(definitionLevels, pageDefinition...)
+	}
+
+	return repetitionLevels, definitionLevels, values
+}
+
+// readLevels reads the levels of a page, encoded with the RLE/bit-packing hybrid with the bit width of maxLevel and prefixed by their length
+func readLevels(page *compactReader, maxLevel, numValues int) []int {
+	if maxLevel == 0 {
+		return make([]int, numValues)
+	}
+
+	length := int(binary.LittleEndian.Uint32(page.bytes(4)))
+	encoded := &compactReader{data: page.bytes(length)}
+	bitWidth := bits.Len(uint(maxLevel))
+
+	var levels []int
+	for len(levels) < numValues {
+		header := encoded.uvarint()
+		if header&1 == 1 {
+			// Groups of 8 values packed from the least significant bit
+			packed := encoded.bytes(int(header>>1) * bitWidth)
+			for bit := 0; bit+bitWidth <= 8*len(packed) && len(levels) < numValues; bit += bitWidth {
+				level := 0
+				for i := 0; i < bitWidth; i++ {
+					level |= int(packed[(bit+i)/8]>>((bit+i)%8)&1) << i
+				}
+				levels = append(levels, level)
+			}
+		} else {
+			// A run of a value stored on the bytes needed for the bit width
+			level := 0
+			for i, levelByte := range encoded.bytes((bitWidth + 7) / 8) {
+				level |= int(levelByte) << (8 * i)
+			}
+			for i := uint64(0); i < header>>1; i++ {
+				levels = append(levels, level)
+			}
+		}
+	}
+	Expect(levels).To(HaveLen(numValues))
+	Expect(encoded.position).To(Equal(len(encoded.data)))
+
+	return levels
+}
+
+// schemaLeaves returns the leaves of the schema, which is stored flattened depth first, in the order of the column chunks of every row group
+func schemaLeaves(schema []schemaElement) []leafColumn {
+	var leaves []leafColumn
+	position := 1
+
+	var walk func(parent []string, maxRepetition, maxDefinition int)
+	walk = func(parent []string, maxRepetition, maxDefinition int) {
+		element := schema[position]
+		position++
+
+		path := append(append([]string{}, parent...), element.name)
+		switch element.repetition {
+		case specOptional:
+			maxDefinition++
+		case specRepeated:
+			maxRepetition++
+			maxDefinition++
+		}
+
+		if element.numChildren <= 0 {
+			leaves = append(leaves, leafColumn{path, maxRepetition, maxDefinition})
+			return
+		}
+
+		for child := int32(0); child < element.numChildren; child++ {
+			walk(path, maxRepetition, maxDefinition)
+		}
+	}
+
+	for child := int32(0); child < schema[0].numChildren; child++ {
+		walk(nil, 0, 0)
+	}
+	Expect(position).To(Equal(len(schema)))
+
+	return leaves
+}
+
+// toSchemaElement reads the fields of a SchemaElement struct
+func toSchemaElement(element thriftFields) schemaElement {
+	optional := func(id int16) int32 {
+		if _, exists := element[id]; !exists {
+			return specNone
+		}
+
+		return i32Field(element, id)
+	}
+
+	return schemaElement{
+		name:          stringField(element, 4),
+		elementType:   optional(1),
+		repetition:    optional(3),
+		numChildren:   optional(5),
+		convertedType: optional(6),
+	}
+}
+
+// i32Field returns the value of a required i32 field of a Thrift struct
+func i32Field(fields thriftFields, id int16) int32 {
+	value, isI32 := fields[id].(int32)
+	ExpectWithOffset(1, isI32).To(BeTrue(), "field %d isn't an i32", id)
+
+	return value
+}
+
+// i64Field returns the value of a required i64 field of a Thrift struct
+func i64Field(fields thriftFields, id int16) int64 {
+	value, isI64 := fields[id].(int64)
+	ExpectWithOffset(1, isI64).To(BeTrue(), "field %d isn't an i64", id)
+
+	return value
+}
+
+// stringField returns the value of a required binary field of a Thrift struct
+func stringField(fields thriftFields, id int16) string {
+	value, isString := fields[id].(string)
+	ExpectWithOffset(1, isString).To(BeTrue(), "field %d isn't a binary", id)
+
+	return value
+}
+
+// listField returns the elements of a required list field of a Thrift struct
+func listField(fields thriftFields, id int16) []interface{} {
+	value, isList := fields[id].([]interface{})
+	ExpectWithOffset(1, isList).To(BeTrue(), "field %d isn't a list", id)
+
+	return value
+}
+
+// structField returns the fields of a required struct field of a Thrift struct
+func structField(fields thriftFields, id int16) thriftFields {
+	value, isStruct := fields[id].(thriftFields)
+	ExpectWithOffset(1, isStruct).To(BeTrue(), "field %d isn't a struct", id)
+
+	return value
+}
+
+// bytes reads the next length bytes
+func (reader *compactReader) bytes(length int) []byte {
+	ExpectWithOffset(1, length).To(BeNumerically("<=", len(reader.data)-reader.position))
+	read := reader.data[reader.position : reader.position+length]
+	reader.position += length
+
+	return read
+}
+
+// uvarint reads an unsigned integer of 7 bits per byte, from the least significant ones
+func (reader *compactReader) uvarint() uint64 {
+	var value uint64
+	for shift := 0; ; shift += 7 {
+		next := reader.bytes(1)[0]
+		value |= uint64(next&0x7f) << shift
+		if next&0x80 == 0 {
+			return value
+		}
+	}
+}
+
+// zigzag reads a signed integer encoded with zigzag as an unsigned varint
+func (reader *compactReader) zigzag() int64 {
+	value := reader.uvarint()
+
+	return int64(value>>1) ^ -int64(value&1)
+}
+
+// structValue reads the fields of a struct until its stop field, whose ids are deltas from the previous one unless they are 0
+func (reader *compactReader) structValue() thriftFields {
+	fields := make(thriftFields)
+	var id int16
+	for {
+		header := reader.bytes(1)[0]
+		if header == thriftStop {
+			return fields
+		}
+
+		if delta := int16(header >> 4); delta != 0 {
+			id += delta
+		} else {
+			id = int16(reader.zigzag())
+		}
+
+		fields[id] = reader.value(header & 0x0f)
+	}
+}
+
+// value reads a value of the Thrift compact type, as the Go type of the same size
+func (reader *compactReader) value(valueType byte) interface{} {
+	switch valueType {
+	case thriftTrue:
+		return true
+	case thriftFalse:
+		return false
+	case thriftByte:
+		return int8(reader.bytes(1)[0])
+	case thriftI16:
+		return int16(reader.zigzag())
+	case thriftI32:
+		return int32(reader.zigzag())
+	case thriftI64:
+		return reader.zigzag()
+	case thriftDouble:
+		return math.Float64frombits(binary.LittleEndian.Uint64(reader.bytes(8)))
+	case thriftBinary:
+		return string(reader.bytes(int(reader.uvarint())))
+	case thriftList, thriftSet:
+		header := reader.bytes(1)[0]
+		size, elementType := int(header>>4), header&0x0f
+		if size == 15 {
+			size = int(reader.uvarint())
+		}
+
+		elements := make([]interface{}, 0, size)
+		for i := 0; i < size; i++ {
+			if elementType == thriftTrue || elementType == thriftFalse {
+				elements = append(elements, reader.bytes(1)[0] == thriftTrue)
+			} else {
+				elements = append(elements, reader.value(elementType))
+			}
+		}
+
+		return elements
+	case thriftMap:
+		entries := make(map[interface{}]interface{})
+		size := int(reader.uvarint())
+		if size == 0 {
+			return entries
+		}
+
+		types := reader.bytes(1)[0]
+		for i := 0; i < size; i++ {
+			key := reader.value(types >> 4)
+			entries[key] = reader.value(types & 0x0f)
+		}
+
+		return entries
+	case thriftStruct:
+		return reader.structValue()
+	}
+
+	Fail(fmt.Sprintf("unknown Thrift compact type %d", valueType))
+	return nil
+}
+
+// updateGolden writes the golden Parquet file again instead of comparing it, for when the encoding changes on purpose,
+// and then testdata/read_golden.py must read it with pyarrow and DuckDB again
+var updateGolden = flag.Bool("update-golden", false, "write testdata/golden.parquet again with the current encoding")
+
+var _ = Describe("GoldenParquetFile", func() {
+	var goldenContents []byte
+
+	BeforeEach(func() {
+		// Every list has at most one element, so the file doesn't depend on the order of the tropes of the works
+		lastUpdated := time.Date(2023, time.May, 1, 10, 30, 0, 0, time.UTC)
+		chekhovsGun, _ := trope.NewTrope("ChekhovsGun", trope.NarrativeTrope, "")
+		manipulativeBastard, _ := trope.NewTrope("ManipulativeBastard", trope.NarrativeTrope, "Characters")
+		anyoneCanDie, _ := trope.NewTrope("AnyoneCanDie", trope.GenreTrope, "")
+		remake, _ := trope.NewRelation("https://tvtropes.org/pmwiki/pmwiki.php/Film/Oldboy2013", "English-language remake",
+			"An English-language remake was released in 2013.", trope.AdaptationRelation)
+
+		oldboyPage, _ := tvtropespages.NewPage(context.Background(), "https://tvtropes.org/pmwiki/pmwiki.php/Film/Oldboy2003", false, nil)
+		oldboy, _ := media.NewMedia("Oldboy", "2003", lastUpdated, map[trope.Trope]struct{}{chekhovsGun: {}, manipulativeBastard: {}}, oldboyPage, media.Film)
+		oldboy.AddRelations(remake)
+
+		breakingBadPage, _ := tvtropespages.NewPage(context.Background(), "https://tvtropes.org/pmwiki/pmwiki.php/Series/BreakingBad", false, nil)
+		breakingBad, _ := media.NewMedia("Breaking Bad", "2008", lastUpdated, map[trope.Trope]struct{}{anyoneCanDie: {}}, breakingBadPage, media.Series)
+
+		goldenRepository, errGolden := parquet_dataset.NewParquetRepository("golden", parquet_dataset.ConfigRowGroupSize(1))
+		Expect(errGolden).To(BeNil())
+		for _, work := range []media.Media{oldboy, breakingBad} {
+			Expect(goldenRepository.AddMedia(work)).To(BeNil())
+			Expect(goldenRepository.Persist()).To(BeNil())
+		}
+		Expect(goldenRepository.Close()).To(BeNil())
+
+		var errRead error
+		goldenContents, errRead = os.ReadFile("golden.parquet")
+		Expect(errRead).To(BeNil())
+	})
+
+	AfterEach(func() {
+		os.Remove("golden.parquet")
+	})
+
+	It("Should write the same file that pyarrow and DuckDB read on testdata/read_golden.py", func() {
+		if *updateGolden {
+			Expect(os.WriteFile("testdata/golden.parquet", goldenContents, 0644)).To(Succeed())
+		}
+
+		expectedContents, errExpected := os.ReadFile("testdata/golden.parquet")
+		Expect(errExpected).To(BeNil())
+		Expect(goldenContents).To(Equal(expectedContents))
+	})
+})
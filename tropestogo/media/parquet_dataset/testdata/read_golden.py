@@ -0,0 +1,66 @@
+"""Reads golden.parquet, written by the Parquet repository, with pyarrow and DuckDB.
+
+The Go tests check that the repository still writes the same bytes, and this script checks
+that two independent Parquet implementations read those bytes as the expected rows.
+
+Usage: pip install pyarrow duckdb && python3 read_golden.py
+"""
+
+import pathlib
+
+import duckdb
+import pyarrow.parquet as pq
+
+GOLDEN = pathlib.Path(__file__).with_name("golden.parquet")
+
+EXPECTED_ROWS = [
+    {
+        "title": "Oldboy",
+        "year": "2003",
+        "media_type": "Film",
+        "last_updated": "2023-05-01 10:30:00",
+        "url": "https://tvtropes.org/pmwiki/pmwiki.php/Film/Oldboy2003",
+        "tropes": [{"title": "ChekhovsGun", "namespace": "Film"}],
+        "sub_tropes": [{"title": "ManipulativeBastard", "namespace": "Characters"}],
+        "relations": [
+            {
+                "url": "https://tvtropes.org/pmwiki/pmwiki.php/Film/Oldboy2013",
+                "anchor_text": "English-language remake",
+                "context": "An English-language remake was released in 2013.",
+                "type": "Adaptation",
+            }
+        ],
+    },
+    {
+        "title": "Breaking Bad",
+        "year": "2008",
+        "media_type": "Series",
+        "last_updated": "2023-05-01 10:30:00",
+        "url": "https://tvtropes.org/pmwiki/pmwiki.php/Series/BreakingBad",
+        "tropes": [{"title": "AnyoneCanDie", "namespace": "Series"}],
+        "sub_tropes": [],
+        "relations": [],
+    },
+]
+
+
+def read_pyarrow():
+    parquet_file = pq.ParquetFile(GOLDEN)
+    assert parquet_file.metadata.num_row_groups == 2, parquet_file.metadata
+    assert parquet_file.metadata.num_rows == 2, parquet_file.metadata
+
+    return parquet_file.read().to_pylist()
+
+
+def read_duckdb():
+    result = duckdb.sql(f"SELECT * FROM read_parquet('{GOLDEN}')")
+    columns = [column[0] for column in result.description]
+
+    return [dict(zip(columns, row)) for row in result.fetchall()]
+
+
+if __name__ == "__main__":
+    for reader in (read_pyarrow, read_duckdb):
+        rows = reader()
+        assert rows == EXPECTED_ROWS, f"{reader.__name__} read {rows}"
+        print(f"{reader.__name__}: {len(rows)} rows OK")
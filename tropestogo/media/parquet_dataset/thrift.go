@@ -0,0 +1,284 @@
+package parquet_dataset
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Types of the Thrift compact protocol, with which Parquet encodes its metadata
+const (
+	compactStop   byte = 0
+	compactTrue   byte = 1
+	compactFalse  byte = 2
+	compactByte   byte = 3
+	compactI16    byte = 4
+	compactI32    byte = 5
+	compactI64    byte = 6
+	compactDouble byte = 7
+	compactBinary byte = 8
+	compactList   byte = 9
+	compactSet    byte = 10
+	compactMap    byte = 11
+	compactStruct byte = 12
+)
+
+var errThriftType = errors.New("unknown Thrift compact protocol type")
+
+// compactWriter encodes Thrift structs with the compact protocol
+// Structs are opened with structBegin and closed with structEnd, and lists of structs are written element by element after listField
+type compactWriter struct {
+	buffer bytes.Buffer
+
+	// lastFields is the stack of the last field ID written on every open struct, because field IDs are written as deltas
+	lastFields []int16
+}
+
+// structBegin opens a struct, which is the root struct or an element of a list
+func (writer *compactWriter) structBegin() {
+	writer.lastFields = append(writer.lastFields, 0)
+}
+
+// structField opens a struct that is the id field of the current struct
+func (writer *compactWriter) structField(id int16) {
+	writer.fieldHeader(id, compactStruct)
+	writer.structBegin()
+}
+
+// structEnd closes the current struct
+func (writer *compactWriter) structEnd() {
+	writer.buffer.WriteByte(compactStop)
+	writer.lastFields = writer.lastFields[:len(writer.lastFields)-1]
+}
+
+// i32Field writes the id field of the current struct with a 32 bits integer value
+func (writer *compactWriter) i32Field(id int16, value int32) {
+	writer.fieldHeader(id, compactI32)
+	writer.varint(zigzag(int64(value)))
+}
+
+// i64Field writes the id field of the current struct with a 64 bits integer value
+func (writer *compactWriter) i64Field(id int16, value int64) {
+	writer.fieldHeader(id, compactI64)
+	writer.varint(zigzag(value))
+}
+
+// stringField writes the id field of the current struct with a string value
+func (writer *compactWriter) stringField(id int16, value string) {
+	writer.fieldHeader(id, compactBinary)
+	writer.stringValue(value)
+}
+
+// i32ListField writes the id field of the current struct with a list of 32 bits integers
+func (writer *compactWriter) i32ListField(id int16, values []int32) {
+	writer.listField(id, compactI32, len(values))
+	for _, value := range values {
+		writer.varint(zigzag(int64(value)))
+	}
+}
+
+// stringListField writes the id field of the current struct with a list of strings
+func (writer *compactWriter) stringListField(id int16, values []string) {
+	writer.listField(id, compactBinary, len(values))
+	for _, value := range values {
+		writer.stringValue(value)
+	}
+}
+
+// listField writes the header of the id field of the current struct with a list of size elements of the elementType type
+func (writer *compactWriter) listField(id int16, elementType byte, size int) {
+	writer.fieldHeader(id, compactList)
+	if size < 15 {
+		writer.buffer.WriteByte(byte(size)<<4 | elementType)
+	} else {
+		writer.buffer.WriteByte(0xf0 | elementType)
+		writer.varint(uint64(size))
+	}
+}
+
+// fieldHeader writes the ID and type of a field, as a delta from the last field ID of the current struct if it's small enough
+func (writer *compactWriter) fieldHeader(id int16, fieldType byte) {
+	last := &writer.lastFields[len(writer.lastFields)-1]
+	if delta := id - *last; delta > 0 && delta <= 15 {
+		writer.buffer.WriteByte(byte(delta)<<4 | fieldType)
+	} else {
+		writer.buffer.WriteByte(fieldType)
+		writer.varint(zigzag(int64(id)))
+	}
+	*last = id
+}
+
+// stringValue writes a string prefixed by its length
+func (writer *compactWriter) stringValue(value string) {
+	writer.varint(uint64(len(value)))
+	writer.buffer.WriteString(value)
+}
+
+// varint writes an unsigned integer with as many bytes as it needs
+func (writer *compactWriter) varint(value uint64) {
+	var encoded [binary.MaxVarintLen64]byte
+	writer.buffer.Write(encoded[:binary.PutUvarint(encoded[:], value)])
+}
+
+// zigzag maps signed integers to unsigned ones so that small negative numbers are also encoded with few bytes
+func zigzag(value int64) uint64 {
+	return uint64((value << 1) ^ (value >> 63))
+}
+
+// thriftStruct is a decoded Thrift struct, relating the ID of every field to its value
+// Integers are decoded as int64, binaries as []byte, lists and sets as []interface{} and structs as thriftStruct
+type thriftStruct map[int16]interface{}
+
+// readStruct decodes a Thrift struct encoded with the compact protocol from the reader, leaving it right after the struct
+func readStruct(reader *bytes.Reader) (thriftStruct, error) {
+	decoded := make(thriftStruct)
+	var lastField int16
+	for {
+		header, errHeader := reader.ReadByte()
+		if errHeader != nil {
+			return nil, errHeader
+		}
+
+		if header == compactStop {
+			return decoded, nil
+		}
+
+		fieldType := header & 0x0f
+		if delta := int16(header >> 4); delta != 0 {
+			lastField += delta
+		} else {
+			id, errId := binary.ReadUvarint(reader)
+			if errId != nil {
+				return nil, errId
+			}
+			lastField = int16(unzigzag(id))
+		}
+
+		// Boolean fields have their value on the type of the field header
+		if fieldType == compactTrue || fieldType == compactFalse {
+			decoded[lastField] = fieldType == compactTrue
+			continue
+		}
+
+		value, errValue := readValue(reader, fieldType)
+		if errValue != nil {
+			return nil, errValue
+		}
+		decoded[lastField] = value
+	}
+}
+
+// readValue decodes a Thrift value of the valueType type encoded with the compact protocol from the reader
+func readValue(reader *bytes.Reader, valueType byte) (interface{}, error) {
+	switch valueType {
+	case compactTrue, compactFalse:
+		value, errValue := reader.ReadByte()
+		return value == compactTrue, errValue
+	case compactByte:
+		value, errValue := reader.ReadByte()
+		return int64(int8(value)), errValue
+	case compactI16, compactI32, compactI64:
+		value, errValue := binary.ReadUvarint(reader)
+		return unzigzag(value), errValue
+	case compactDouble:
+		var value float64
+		errValue := binary.Read(reader, binary.LittleEndian, &value)
+		return value, errValue
+	case compactBinary:
+		size, errSize := binary.ReadUvarint(reader)
+		if errSize != nil {
+			return nil, errSize
+		}
+		if size > uint64(reader.Len()) {
+			return nil, io.ErrUnexpectedEOF
+		}
+
+		value := make([]byte, size)
+		_, errValue := io.ReadFull(reader, value)
+		return value, errValue
+	case compactList, compactSet:
+		header, errHeader := reader.ReadByte()
+		if errHeader != nil {
+			return nil, errHeader
+		}
+
+		size := uint64(header >> 4)
+		if size == 15 {
+			var errSize error
+			if size, errSize = binary.ReadUvarint(reader); errSize != nil {
+				return nil, errSize
+			}
+		}
+		if size > uint64(reader.Len()) {
+			return nil, io.ErrUnexpectedEOF
+		}
+
+		values := make([]interface{}, 0, size)
+		for i := uint64(0); i < size; i++ {
+			value, errValue := readValue(reader, header&0x0f)
+			if errValue != nil {
+				return nil, errValue
+			}
+			values = append(values, value)
+		}
+
+		return values, nil
+	case compactMap:
+		size, errSize := binary.ReadUvarint(reader)
+		if errSize != nil || size == 0 {
+			return nil, errSize
+		}
+
+		types, errTypes := reader.ReadByte()
+		if errTypes != nil {
+			return nil, errTypes
+		}
+
+		// Maps aren't used by Parquet, so they're only read to skip them
+		for i := uint64(0); i < size; i++ {
+			if _, errKey := readValue(reader, types>>4); errKey != nil {
+				return nil, errKey
+			}
+			if _, errValue := readValue(reader, types&0x0f); errValue != nil {
+				return nil, errValue
+			}
+		}
+
+		return nil, nil
+	case compactStruct:
+		return readStruct(reader)
+	}
+
+	return nil, fmt.Errorf("%w: %d", errThriftType, valueType)
+}
+
+// unzigzag reverses zigzag
+func unzigzag(value uint64) int64 {
+	return int64(value>>1) ^ -int64(value&1)
+}
+
+// getInt returns the integer value of the id field, or 0 if it doesn't have one
+func (decoded thriftStruct) getInt(id int16) int64 {
+	value, _ := decoded[id].(int64)
+	return value
+}
+
+// getString returns the string value of the id field, or an empty string if it doesn't have one
+func (decoded thriftStruct) getString(id int16) string {
+	value, _ := decoded[id].([]byte)
+	return string(value)
+}
+
+// getList returns the list value of the id field, or nil if it doesn't have one
+func (decoded thriftStruct) getList(id int16) []interface{} {
+	value, _ := decoded[id].([]interface{})
+	return value
+}
+
+// getStruct returns the struct value of the id field, or an empty struct if it doesn't have one
+func (decoded thriftStruct) getStruct(id int16) thriftStruct {
+	value, _ := decoded[id].(thriftStruct)
+	return value
+}
@@ -1,16 +1,22 @@
 package media
 
-import "time"
+import (
+	"time"
+
+	"github.com/jlgallego99/TropesToGo/trope"
+)
 
 // RepositoryMedia defines an interface for all kinds of repositories of media tropes in TvTropes
 // The interface allows us to implement multiple structs that handle different data formats like CSV or JSON
 // sharing common methods
+// Implementations must be safe for concurrent use, so several pages can be scraped and added at the same time
 type RepositoryMedia interface {
 	// AddMedia adds a new Media (Work with its Tropes) to the dataset
 	AddMedia(Media) error
 
 	// UpdateMedia updates a Media (Work with its Tropes) within the dataset
-	// It distinguishes between works with the same name by both its title and year
+	// It distinguishes between works with the same name by their title and year and the media type of the updated Media
+	// It returns an ErrMediaNotFound error, without writing the dataset, if there's no such Work on it
 	UpdateMedia(string, string, Media) error
 
 	// RemoveAll delete all Media entries on the repository
@@ -21,4 +27,32 @@ type RepositoryMedia interface {
 
 	// GetWorkPages retrieves all persisted Work urls on the dataset and the last time they were updated
 	GetWorkPages() (map[string]time.Time, error)
+
+	// GetTropes retrieves the title of every trope and subtrope of the persisted Works on the dataset
+	GetTropes() (map[string]struct{}, error)
+
+	// GetWorkRelations retrieves the relations with other Works of every persisted Work on the dataset, by the URL of the Work
+	GetWorkRelations() (map[string][]trope.Relation, error)
+
+	// GetMediaByURL retrieves the persisted Media of the Work page with the URL
+	// It returns an ErrMediaNotFound error if there's no Work with that URL on the dataset
+	GetMediaByURL(string) (Media, error)
+
+	// GetMedia retrieves the persisted Media with the title and year
+	// If there are several of different media types, it retrieves the first one that was persisted
+	// It returns an ErrMediaNotFound error if there's no Work with that title and year on the dataset
+	GetMedia(string, string) (Media, error)
+
+	// ForEachMedia calls the function with every persisted Media on the dataset, in the order they were persisted
+	// It stops at the first error the function returns and returns it. The function must not call the methods of the repository
+	ForEachMedia(func(Media) error) error
+
+	// GetMediaWithTrope retrieves every persisted Media that has the trope, by its title, as a trope or a subtrope
+	GetMediaWithTrope(string) ([]Media, error)
+
+	// CountMedia returns the number of persisted Media records on the dataset
+	CountMedia() (int, error)
+
+	// Close releases the lock that the repository holds on the dataset since its first write, so another process can write it
+	Close() error
 }
@@ -0,0 +1,323 @@
+package repository_conformance
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/jlgallego99/TropesToGo/media"
+	"github.com/jlgallego99/TropesToGo/media/dataset_file"
+	"github.com/jlgallego99/TropesToGo/trope"
+	"github.com/jlgallego99/TropesToGo/tvtropespages"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+// datasetName is the name of the dataset that the specs create, which is removed with all its files after each of them
+const datasetName = "conformance"
+
+// sharedTrope is the trope that the first and last works have and the second one doesn't
+const sharedTrope = "ChekhovsGun"
+
+// NewRepository is the constructor of the repository of a dataset format, which receives the name of the dataset without extension
+type NewRepository func(name string) (media.RepositoryMedia, error)
+
+// Features are the parts of a Media that a dataset format keeps, because not every format can store all of them
+// The parts that aren't kept must be read back empty, so the loss of data is known
+type Features struct {
+	// Indexes is whether the indexes of every trope are kept, or they are read as unclassified tropes
+	// The datasets keep the main index of every trope as the first one of its indexes
+	Indexes bool
+
+	// Examples is whether the examples of every trope are kept
+	Examples bool
+
+	// Metadata is whether the description, image, creators, see also and franchises of every Work are kept
+	Metadata bool
+}
+
+// DescribeRepository adds to the suite of a dataset format the specs that every RepositoryMedia must pass,
+// reading three works of two media types persisted on two different times with a new repository of the format
+// The features are the parts of the works that the format keeps
+func DescribeRepository(format string, newRepository NewRepository, features Features) bool {
+	return Describe(format+" RepositoryMedia conformance", func() {
+		var repository media.RepositoryMedia
+		var works []media.Media
+
+		BeforeEach(func() {
+			works = createWorks()
+
+			writer, errWriter := newRepository(datasetName)
+			Expect(errWriter).To(BeNil())
+			Expect(writer.AddMedia(works[0])).To(BeNil())
+			Expect(writer.AddMedia(works[1])).To(BeNil())
+			Expect(writer.Persist()).To(BeNil())
+			Expect(writer.AddMedia(works[2])).To(BeNil())
+			Expect(writer.Persist()).To(BeNil())
+			Expect(writer.Close()).To(BeNil())
+
+			var errRepository error
+			repository, errRepository = newRepository(datasetName)
+			Expect(errRepository).To(BeNil())
+		})
+
+		AfterEach(func() {
+			repository.RemoveAll()
+			repository.Close()
+
+			datasetFiles, _ := filepath.Glob(datasetName + ".*")
+			for _, datasetFile := range datasetFiles {
+				os.Remove(datasetFile)
+			}
+		})
+
+		Context("Read the persisted Media", func() {
+			It("Should get every Media by the URL of its page", func() {
+				for _, work := range works {
+					urlMedia, errGet := repository.GetMediaByURL(work.GetPage().GetUrl().String())
+
+					Expect(errGet).To(BeNil())
+					expectPersistedMedia(urlMedia, work, features)
+				}
+			})
+
+			It("Should get every Media by its title and year", func() {
+				for _, work := range works {
+					titleMedia, errGet := repository.GetMedia(work.GetWork().Title, work.GetWork().Year)
+
+					Expect(errGet).To(BeNil())
+					expectPersistedMedia(titleMedia, work, features)
+				}
+			})
+
+			It("Should return an error if the Media isn't persisted", func() {
+				_, errGetUrl := repository.GetMediaByURL("https://tvtropes.org/pmwiki/pmwiki.php/Film/Oldboy2013")
+				_, errGet := repository.GetMedia("Oldboy", "2013")
+
+				Expect(errors.Is(errGetUrl, media.ErrMediaNotFound)).To(BeTrue())
+				Expect(errors.Is(errGet, media.ErrMediaNotFound)).To(BeTrue())
+			})
+
+			It("Should iterate over every persisted Media in the order they were persisted", func() {
+				var keys []media.MediaKey
+				errForEach := repository.ForEachMedia(func(persistedMedia media.Media) error {
+					keys = append(keys, persistedMedia.GetKey())
+					return nil
+				})
+
+				Expect(errForEach).To(BeNil())
+				Expect(keys).To(Equal(workKeys(works)))
+			})
+
+			It("Should stop iterating at the first error", func() {
+				errStop := errors.New("stop")
+				calls := 0
+				errForEach := repository.ForEachMedia(func(persistedMedia media.Media) error {
+					calls++
+					return errStop
+				})
+
+				Expect(errForEach).To(Equal(errStop))
+				Expect(calls).To(Equal(1))
+			})
+
+			It("Should find every Media that has a trope in the order they were persisted", func() {
+				mediaWithTrope, errFind := repository.GetMediaWithTrope(sharedTrope)
+
+				Expect(errFind).To(BeNil())
+				Expect(workKeys(mediaWithTrope)).To(Equal(workKeys([]media.Media{works[0], works[2]})))
+			})
+
+			It("Should find the Media that have a subtrope", func() {
+				mediaWithSubTrope, errFind := repository.GetMediaWithTrope("ManipulativeBastard")
+
+				Expect(errFind).To(BeNil())
+				Expect(workKeys(mediaWithSubTrope)).To(Equal(workKeys([]media.Media{works[1]})))
+			})
+
+			It("Shouldn't find any Media for a trope that no Work has", func() {
+				mediaWithoutTrope, errFindNone := repository.GetMediaWithTrope("NotATrope")
+
+				Expect(errFindNone).To(BeNil())
+				Expect(mediaWithoutTrope).To(BeEmpty())
+			})
+
+			It("Should count the persisted Media", func() {
+				count, errCount := repository.CountMedia()
+
+				Expect(errCount).To(BeNil())
+				Expect(count).To(Equal(len(works)))
+			})
+		})
+
+		Context("Update a Media that isn't persisted", func() {
+			It("Should return an error without backing up the dataset", func() {
+				remake := newWork("Oldboy", "2013", "https://tvtropes.org/pmwiki/pmwiki.php/Film/Oldboy2013", media.Film)
+
+				Expect(errors.Is(repository.UpdateMedia("Oldboy", "2013", remake), media.ErrMediaNotFound)).To(BeTrue())
+				Expect(errors.Is(repository.UpdateMedia("Oldboy", "2003", newWork("Oldboy", "2003",
+					"https://tvtropes.org/pmwiki/pmwiki.php/Manga/Oldboy", media.Manga)), media.ErrMediaNotFound)).To(BeTrue())
+				Expect(filepath.Glob(datasetName + ".*" + dataset_file.BackupSuffix + "*")).To(BeEmpty())
+
+				count, errCount := repository.CountMedia()
+				Expect(errCount).To(BeNil())
+				Expect(count).To(Equal(len(works)))
+			})
+		})
+
+		Context("Works of different media types have the same title and year", func() {
+			var manga media.Media
+
+			BeforeEach(func() {
+				genre, _ := trope.NewTrope("AnyoneCanDie", trope.GenreTrope, "")
+				manga = newWork("Akira", "1988", "https://tvtropes.org/pmwiki/pmwiki.php/Manga/Akira", media.Manga, genre)
+
+				Expect(repository.AddMedia(manga)).To(BeNil())
+				Expect(repository.Persist()).To(BeNil())
+			})
+
+			It("Should get the first persisted Media by its title and year", func() {
+				titleMedia, errGet := repository.GetMedia("Akira", "1988")
+
+				Expect(errGet).To(BeNil())
+				expectPersistedMedia(titleMedia, works[2], features)
+			})
+
+			It("Should only update the Media of the media type of the updated one", func() {
+				topical, _ := trope.NewTrope("TimeSkip", trope.TopicalTrope, "")
+				updatedManga := newWork("Akira", "1988", "https://tvtropes.org/pmwiki/pmwiki.php/Manga/Akira", media.Manga, topical)
+
+				Expect(repository.UpdateMedia("Akira", "1988", updatedManga)).To(BeNil())
+
+				filmMedia, errFilm := repository.GetMediaByURL("https://tvtropes.org/pmwiki/pmwiki.php/Film/Akira")
+				mangaMedia, errManga := repository.GetMediaByURL("https://tvtropes.org/pmwiki/pmwiki.php/Manga/Akira")
+				Expect(errFilm).To(BeNil())
+				Expect(errManga).To(BeNil())
+				expectPersistedMedia(filmMedia, works[2], features)
+				expectPersistedMedia(mangaMedia, updatedManga, features)
+			})
+		})
+	})
+}
+
+// expectPersistedMedia checks that a Media read from the dataset is the expected one, with the parts of it that the format keeps,
+// and that the parts that the format doesn't keep are empty
+func expectPersistedMedia(persisted, expected media.Media, features Features) {
+	ExpectWithOffset(1, persisted.GetKey()).To(Equal(expected.GetKey()))
+	ExpectWithOffset(1, persisted.GetMediaType()).To(Equal(expected.GetMediaType()))
+	ExpectWithOffset(1, persisted.GetPage().GetUrl().String()).To(Equal(expected.GetPage().GetUrl().String()))
+	ExpectWithOffset(1, persisted.GetWork().LastUpdated.Format(time.DateTime)).To(Equal(expected.GetWork().LastUpdated.Format(time.DateTime)))
+	ExpectWithOffset(1, persisted.GetRelations()).To(Equal(expected.GetRelations()))
+	ExpectWithOffset(1, len(persisted.GetWork().Tropes)).To(Equal(len(expected.GetWork().Tropes)))
+	ExpectWithOffset(1, len(persisted.GetWork().SubTropes)).To(Equal(len(expected.GetWork().SubTropes)))
+
+	persistedIndexes, expectedIndexes := tropeIndexes(persisted), tropeIndexes(expected)
+	ExpectWithOffset(1, persistedIndexes).To(HaveLen(len(expectedIndexes)))
+	for title, indexes := range expectedIndexes {
+		ExpectWithOffset(1, persistedIndexes).To(HaveKey(title))
+		if features.Indexes {
+			ExpectWithOffset(1, persistedIndexes[title]).To(Equal(indexes))
+		} else {
+			ExpectWithOffset(1, persistedIndexes[title]).To(BeEmpty())
+		}
+	}
+
+	persistedExamples, expectedExamples := tropeExamples(persisted), tropeExamples(expected)
+	if features.Examples {
+		ExpectWithOffset(1, persistedExamples).To(Equal(expectedExamples))
+	} else {
+		ExpectWithOffset(1, persistedExamples).To(BeEmpty())
+	}
+
+	// The metadata is compared as it's persisted, because some formats can't tell an empty list from a missing one
+	if features.Metadata {
+		ExpectWithOffset(1, media.GetJsonMetadata(persisted.GetMetadata())).To(Equal(media.GetJsonMetadata(expected.GetMetadata())))
+	} else {
+		ExpectWithOffset(1, media.GetJsonMetadata(persisted.GetMetadata())).To(Equal(media.GetJsonMetadata(trope.WorkMetadata{})))
+	}
+}
+
+// createWorks creates the works that the specs persist, in the order they are persisted, which isn't the order of their titles
+// The first one is a Film with tropes of several indexes, examples, metadata and a relation, the second one a Series with a subtrope,
+// and the last one another Film without anything but its tropes. The first and last ones share a trope that the second one doesn't have
+func createWorks() []media.Media {
+	shared, _ := trope.NewTrope(sharedTrope, trope.NarrativeTrope, "", trope.MediaTrope)
+	genre, _ := trope.NewTrope("AnyoneCanDie", trope.GenreTrope, "")
+	unclassified, _ := trope.NewTrope("BittersweetEnding", trope.UnknownTropeIndex, "")
+	subTrope, _ := trope.NewTrope("ManipulativeBastard", trope.NarrativeTrope, "Characters")
+	topical, _ := trope.NewTrope("TimeSkip", trope.TopicalTrope, "")
+
+	oldboy := newWork("Oldboy", "2003", "https://tvtropes.org/pmwiki/pmwiki.php/Film/Oldboy2003", media.Film, shared, genre, unclassified)
+	example, _ := trope.NewExample("The gun is used at the end.", "<li>The gun is used at the end.</li>", []string{"Twice."}, []string{"at the end"},
+		trope.Location{Header: "Tropes", SubpageURL: "https://tvtropes.org/pmwiki/pmwiki.php/Film/Oldboy2003", Position: 1})
+	oldboy.AddExamples(shared, example)
+	oldboy.SetMetadata(trope.WorkMetadata{
+		Description:  []string{"Oldboy is a 2003 South Korean film."},
+		Image:        "https://static.tvtropes.org/pmwiki/pub/images/oldboy.png",
+		ImageCaption: "Oldboy",
+		Creators:     []string{"Park Chan-wook"},
+		SeeAlso:      []string{"https://tvtropes.org/pmwiki/pmwiki.php/Film/Oldboy2013"},
+		Franchises:   []string{"Vengeance Trilogy"},
+	})
+	remake, _ := trope.NewRelation("https://tvtropes.org/pmwiki/pmwiki.php/Film/Oldboy2013", "English-language remake",
+		"An English-language remake was released on November 27, 2013.", trope.AdaptationRelation)
+	oldboy.AddRelations(remake)
+
+	breakingBad := newWork("Breaking Bad", "2008", "https://tvtropes.org/pmwiki/pmwiki.php/Series/BreakingBad", media.Series, genre, subTrope)
+	akira := newWork("Akira", "1988", "https://tvtropes.org/pmwiki/pmwiki.php/Film/Akira", media.Film, shared, topical)
+
+	return []media.Media{oldboy, breakingBad, akira}
+}
+
+// newWork creates a Media of the media type with the tropes and its page on the URL
+func newWork(title, year, url string, mediaType media.MediaType, tropes ...trope.Trope) media.Media {
+	tropeSet := make(map[trope.Trope]struct{}, len(tropes))
+	for _, workTrope := range tropes {
+		tropeSet[workTrope] = struct{}{}
+	}
+
+	page, errPage := tvtropespages.NewPage(context.Background(), url, false, nil)
+	ExpectWithOffset(1, errPage).To(BeNil())
+	work, errWork := media.NewMedia(title, year, time.Now(), tropeSet, page, mediaType)
+	ExpectWithOffset(1, errWork).To(BeNil())
+
+	return work
+}
+
+// workKeys returns the key of every Media in the same order
+func workKeys(works []media.Media) []media.MediaKey {
+	keys := make([]media.MediaKey, 0, len(works))
+	for _, work := range works {
+		keys = append(keys, work.GetKey())
+	}
+
+	return keys
+}
+
+// tropeIndexes returns the indexes of every trope and subtrope of the Media, with the main one first, by its subpage and title
+func tropeIndexes(mediaData media.Media) map[string][]string {
+	indexes := make(map[string][]string)
+	for workTrope := range mediaData.GetWork().Tropes {
+		indexes[workTrope.GetSubpage()+"/"+workTrope.GetTitle()] = workTrope.GetIndexStrings()
+	}
+
+	for workSubTrope := range mediaData.GetWork().SubTropes {
+		indexes[workSubTrope.GetSubpage()+"/"+workSubTrope.GetTitle()] = workSubTrope.GetIndexStrings()
+	}
+
+	return indexes
+}
+
+// tropeExamples returns the examples of every trope and subtrope of the Media that has any by its subpage and title
+func tropeExamples(mediaData media.Media) map[string][]trope.Example {
+	examples := make(map[string][]trope.Example)
+	for workTrope, workExamples := range mediaData.GetWork().Examples {
+		if len(workExamples) > 0 {
+			examples[workTrope.GetSubpage()+"/"+workTrope.GetTitle()] = workExamples
+		}
+	}
+
+	return examples
+}
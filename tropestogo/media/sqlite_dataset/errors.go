@@ -0,0 +1,29 @@
+package sqlite_dataset
+
+import (
+	"errors"
+	"fmt"
+)
+
+// The SQLite datasets are written with the SQLite C library through cgo, so they are only available on builds with cgo enabled
+// Builds without cgo (CGO_ENABLED=0) still have the package, but NewSQLiteRepository always returns an ErrNoCgo error
+var (
+	ErrFileNotExists   = errors.New("SQLite dataset file does not exist")
+	ErrDuplicatedMedia = errors.New("duplicated media, the record already exists on the dataset")
+	ErrOpenSqlite      = errors.New("error opening the SQLite database")
+	ErrCreateSqlite    = errors.New("error creating the schema of the SQLite database")
+	ErrReadSqlite      = errors.New("error reading the SQLite database")
+	ErrWriteSqlite     = errors.New("error writing on the SQLite database")
+	ErrPersist         = errors.New("can't persist data on the SQLite database because there's none")
+	ErrParseTime       = errors.New("error parsing the timestamp string from the dataset")
+	ErrNoCgo           = errors.New("the SQLite datasets need TropesToGo to be built with cgo enabled (CGO_ENABLED=1 and a C compiler)")
+)
+
+// Error formats a generic error
+func Error(message string, err error, subErr error) error {
+	if subErr != nil {
+		return fmt.Errorf("%w: "+message+"\n%w", err, subErr)
+	} else {
+		return fmt.Errorf("%w: "+message+"", err)
+	}
+}
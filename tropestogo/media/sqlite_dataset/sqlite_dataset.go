@@ -0,0 +1,898 @@
+//go:build cgo
+
+package sqlite_dataset
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/jlgallego99/TropesToGo/media"
+	"github.com/jlgallego99/TropesToGo/media/dataset_file"
+	"github.com/jlgallego99/TropesToGo/trope"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+const timeLayout = "2006-01-02 15:04:05"
+
+// Schema is the normalized schema of the SQLite dataset, created if the database doesn't have it yet
+// Every Work is a row of works, unique by its title, year and media type, with the URL of its page and the crawl metadata:
+// the last time it was updated on TvTropes and the last time it was persisted
+// Every trope is a row of tropes, related with the Works that have it on work_tropes with the namespace of the subpage where it is,
+// which is the media type for the main tropes, and with the indexes it's classified on in trope_indexes, inserted with its main index first
+// The examples of each trope on each Work, the paragraphs of the description, the creators, the related works, the franchises
+// and the relations with other works have their own tables, ordered by their position
+var Schema = []string{
+	`CREATE TABLE IF NOT EXISTS works (
+		id INTEGER PRIMARY KEY,
+		title TEXT NOT NULL,
+		year TEXT NOT NULL,
+		media_type TEXT NOT NULL,
+		url TEXT NOT NULL,
+		last_updated TEXT NOT NULL,
+		persisted_at TEXT NOT NULL,
+		image TEXT NOT NULL DEFAULT '',
+		image_caption TEXT NOT NULL DEFAULT '',
+		UNIQUE (title, year, media_type)
+	)`,
+	`CREATE INDEX IF NOT EXISTS works_url ON works (url)`,
+	`CREATE INDEX IF NOT EXISTS works_media_type ON works (media_type)`,
+	`CREATE TABLE IF NOT EXISTS tropes (
+		id INTEGER PRIMARY KEY,
+		title TEXT NOT NULL UNIQUE
+	)`,
+	`CREATE TABLE IF NOT EXISTS trope_indexes (
+		trope_id INTEGER NOT NULL REFERENCES tropes (id) ON DELETE CASCADE,
+		trope_index TEXT NOT NULL,
+		PRIMARY KEY (trope_id, trope_index)
+	)`,
+	`CREATE INDEX IF NOT EXISTS trope_indexes_index ON trope_indexes (trope_index)`,
+	`CREATE TABLE IF NOT EXISTS work_tropes (
+		work_id INTEGER NOT NULL REFERENCES works (id) ON DELETE CASCADE,
+		trope_id INTEGER NOT NULL REFERENCES tropes (id),
+		namespace TEXT NOT NULL,
+		is_main INTEGER NOT NULL,
+		PRIMARY KEY (work_id, trope_id, namespace)
+	)`,
+	`CREATE INDEX IF NOT EXISTS work_tropes_trope ON work_tropes (trope_id)`,
+	`CREATE INDEX IF NOT EXISTS work_tropes_namespace ON work_tropes (namespace)`,
+	`CREATE TABLE IF NOT EXISTS examples (
+		work_id INTEGER NOT NULL REFERENCES works (id) ON DELETE CASCADE,
+		trope_id INTEGER NOT NULL REFERENCES tropes (id),
+		namespace TEXT NOT NULL,
+		example_order INTEGER NOT NULL,
+		text TEXT NOT NULL,
+		html TEXT NOT NULL,
+		sub_items TEXT NOT NULL,
+		spoilers TEXT NOT NULL,
+		folder TEXT NOT NULL,
+		header TEXT NOT NULL,
+		subpage_url TEXT NOT NULL,
+		position INTEGER NOT NULL,
+		PRIMARY KEY (work_id, trope_id, namespace, example_order)
+	)`,
+	`CREATE INDEX IF NOT EXISTS examples_trope ON examples (trope_id)`,
+	`CREATE TABLE IF NOT EXISTS work_descriptions (
+		work_id INTEGER NOT NULL REFERENCES works (id) ON DELETE CASCADE,
+		position INTEGER NOT NULL,
+		value TEXT NOT NULL,
+		PRIMARY KEY (work_id, position)
+	)`,
+	`CREATE TABLE IF NOT EXISTS work_creators (
+		work_id INTEGER NOT NULL REFERENCES works (id) ON DELETE CASCADE,
+		position INTEGER NOT NULL,
+		value TEXT NOT NULL,
+		PRIMARY KEY (work_id, position)
+	)`,
+	`CREATE TABLE IF NOT EXISTS work_see_also (
+		work_id INTEGER NOT NULL REFERENCES works (id) ON DELETE CASCADE,
+		position INTEGER NOT NULL,
+		value TEXT NOT NULL,
+		PRIMARY KEY (work_id, position)
+	)`,
+	`CREATE TABLE IF NOT EXISTS work_franchises (
+		work_id INTEGER NOT NULL REFERENCES works (id) ON DELETE CASCADE,
+		position INTEGER NOT NULL,
+		value TEXT NOT NULL,
+		PRIMARY KEY (work_id, position)
+	)`,
+	`CREATE TABLE IF NOT EXISTS relations (
+		work_id INTEGER NOT NULL REFERENCES works (id) ON DELETE CASCADE,
+		position INTEGER NOT NULL,
+		url TEXT NOT NULL,
+		anchor_text TEXT NOT NULL,
+		context TEXT NOT NULL,
+		type TEXT NOT NULL,
+		PRIMARY KEY (work_id, position)
+	)`,
+	`CREATE INDEX IF NOT EXISTS relations_url ON relations (url)`,
+}
+
+// workTables are the tables with the data of each Work, which are emptied when the Work is updated
+var workTables = []string{"work_tropes", "examples", "work_descriptions", "work_creators", "work_see_also", "work_franchises", "relations"}
+
+// SQLiteRepository implements the RepositoryMedia for creating and handling SQLite datasets of all the scraped data on TvTropes
+// It has an internal data structure of Media objects that is persisted on the database all in one transaction
+// It's safe to use by several goroutines at the same time
+// The repository holds the lock of the dataset from its first write until it's closed, so no other tropestogo process can write it at the same time
+type SQLiteRepository struct {
+	// name of the file dataset
+	name string
+
+	// db is the connection to the SQLite database of the dataset
+	db *sql.DB
+
+	// data is the intermediate dataset added here before persisting it all at once
+	data []media.Media
+
+	// index holds the key of every Media object on data, for detecting the duplicated ones
+	index map[media.MediaKey]struct{}
+
+	// lock is the lock of the dataset file, held from the first write on the dataset until the repository is closed
+	lock *dataset_file.Lock
+
+	// backedUp is set when the dataset file has been backed up before the first destructive write of the repository
+	backedUp bool
+
+	// mutex guards the intermediate dataset and the database
+	mutex sync.Mutex
+}
+
+// NewSQLiteRepository is the constructor for SQLiteRepository objects that handle SQLite datasets
+// It receives the name that the SQLite dataset file will have and creates the file with the Schema if it doesn't exist
+// It will return an ErrOpenSqlite error if the database couldn't be opened or an ErrCreateSqlite error if the schema couldn't be created,
+// or if another process is creating it
+func NewSQLiteRepository(name string) (*SQLiteRepository, error) {
+	repository := &SQLiteRepository{
+		name:  name + ".sqlite",
+		index: make(map[media.MediaKey]struct{}),
+	}
+
+	// Creating the file and its schema is a write, so it needs the lock
+	if _, errStat := os.Stat(repository.name); errStat != nil {
+		if errLock := repository.lockDataset(); errLock != nil {
+			return nil, Error(name, ErrCreateSqlite, errLock)
+		}
+	}
+
+	db, errOpen := sql.Open("sqlite3", "file:"+name+".sqlite?_foreign_keys=on&_busy_timeout=5000")
+	if errOpen != nil {
+		repository.releaseLock()
+		return nil, Error(name, ErrOpenSqlite, errOpen)
+	}
+
+	// A single connection serializes the writes, which SQLite can't do concurrently
+	db.SetMaxOpenConns(1)
+	if errPing := db.Ping(); errPing != nil {
+		db.Close()
+		repository.releaseLock()
+		return nil, Error(name, ErrOpenSqlite, errPing)
+	}
+
+	for _, statement := range Schema {
+		if _, errCreate := db.Exec(statement); errCreate != nil {
+			db.Close()
+			repository.releaseLock()
+			return nil, Error(name, ErrCreateSqlite, errCreate)
+		}
+	}
+
+	repository.db = db
+	return repository, nil
+}
+
+// Close closes the connection to the SQLite database and releases the lock of the dataset file if the repository holds it,
+// after which the repository can't be used
+func (repository *SQLiteRepository) Close() error {
+	repository.mutex.Lock()
+	defer repository.mutex.Unlock()
+
+	errClose := repository.db.Close()
+	if errRelease := repository.releaseLock(); errClose == nil {
+		errClose = errRelease
+	}
+
+	return errClose
+}
+
+// AddMedia adds a newMedia Media object to the in-memory dataset, so it can be later persisted
+// There can only be unique objects on the dataset, so it will return an ErrDuplicatedMedia error if the Media object already exists
+// Works with the same title and year but different media types are different Media objects
+func (repository *SQLiteRepository) AddMedia(newMedia media.Media) error {
+	repository.mutex.Lock()
+	defer repository.mutex.Unlock()
+
+	if _, exists := repository.index[newMedia.GetKey()]; exists {
+		return Error("Title: "+newMedia.GetWork().Title, ErrDuplicatedMedia, nil)
+	}
+
+	repository.index[newMedia.GetKey()] = struct{}{}
+	repository.data = append(repository.data, newMedia)
+
+	return nil
+}
+
+// UpdateMedia updates in place a record already written on the dataset by checking if it has the same title and year and the media type of updateMedia, because that differentiates a record
+// The row of the Work keeps its ID, while its tropes, examples, metadata and relations are replaced, all in one transaction
+// The dataset file is backed up before the first update of the repository
+// It returns a media.ErrMediaNotFound error if there's no such record,
+// or an ErrReadSqlite or ErrWriteSqlite error if the database couldn't be read, backed up or written
+func (repository *SQLiteRepository) UpdateMedia(title string, year string, updateMedia media.Media) error {
+	repository.mutex.Lock()
+	defer repository.mutex.Unlock()
+
+	if errLock := repository.lockDataset(); errLock != nil {
+		return errLock
+	}
+
+	var workId int64
+	errWork := repository.db.QueryRow(`SELECT id FROM works WHERE title = ? AND year = ? AND media_type = ?`,
+		title, year, updateMedia.GetMediaType().String()).Scan(&workId)
+	if errors.Is(errWork, sql.ErrNoRows) {
+		return Error("Title: "+title+" Year: "+year, media.ErrMediaNotFound, nil)
+	} else if errWork != nil {
+		return Error(repository.name, ErrReadSqlite, errWork)
+	}
+
+	if errBackup := repository.backupDataset(); errBackup != nil {
+		return errBackup
+	}
+
+	tx, errBegin := repository.db.Begin()
+	if errBegin != nil {
+		return Error(repository.name, ErrWriteSqlite, errBegin)
+	}
+	defer tx.Rollback()
+
+	metadata := updateMedia.GetMetadata()
+	_, errUpdate := tx.Exec(`UPDATE works SET title = ?, year = ?, media_type = ?, url = ?, last_updated = ?, persisted_at = ?, image = ?, image_caption = ?
+		WHERE id = ?`, updateMedia.GetWork().Title, updateMedia.GetWork().Year, updateMedia.GetMediaType().String(), updateMedia.GetPage().GetUrl().String(),
+		formatDate(updateMedia.GetWork().LastUpdated), formatDate(time.Now()), metadata.Image, metadata.ImageCaption, workId)
+	if errUpdate != nil {
+		return Error(repository.name, ErrWriteSqlite, errUpdate)
+	}
+
+	for _, table := range workTables {
+		if _, errDelete := tx.Exec(`DELETE FROM `+table+` WHERE work_id = ?`, workId); errDelete != nil {
+			return Error(repository.name, ErrWriteSqlite, errDelete)
+		}
+	}
+
+	if errInsert := insertWorkData(tx, workId, updateMedia); errInsert != nil {
+		return Error(repository.name, ErrWriteSqlite, errInsert)
+	}
+
+	if errCommit := tx.Commit(); errCommit != nil {
+		return Error(repository.name, ErrWriteSqlite, errCommit)
+	}
+
+	return nil
+}
+
+// RemoveAll deletes all data on both the in-memory intermediate data and on the dataset file, keeping its schema
+// The dataset file is backed up before emptying it for the first time
+// If the dataset file doesn't exist, it returns an ErrFileNotExists error, and an ErrWriteSqlite error if the data couldn't be deleted
+func (repository *SQLiteRepository) RemoveAll() error {
+	repository.mutex.Lock()
+	defer repository.mutex.Unlock()
+
+	repository.data = []media.Media{}
+	repository.index = make(map[media.MediaKey]struct{})
+
+	if _, errStat := os.Stat(repository.name); errStat != nil {
+		pwd, _ := os.Getwd()
+
+		return Error("at "+pwd+"/"+repository.name, ErrFileNotExists, nil)
+	}
+
+	if errLock := repository.lockDataset(); errLock != nil {
+		return errLock
+	}
+
+	if errBackup := repository.backupDataset(); errBackup != nil {
+		return errBackup
+	}
+
+	tx, errBegin := repository.db.Begin()
+	if errBegin != nil {
+		return Error(repository.name, ErrWriteSqlite, errBegin)
+	}
+	defer tx.Rollback()
+
+	for _, table := range append(append([]string{}, workTables...), "trope_indexes", "tropes", "works") {
+		if _, errDelete := tx.Exec(`DELETE FROM ` + table); errDelete != nil {
+			return Error(repository.name, ErrWriteSqlite, errDelete)
+		}
+	}
+
+	if errCommit := tx.Commit(); errCommit != nil {
+		return Error(repository.name, ErrWriteSqlite, errCommit)
+	}
+
+	return nil
+}
+
+// Persist writes all intermediate Media data into the database in a single transaction and empties the structure, because it has already been persisted
+// It checks whether the new records are already on the dataset, but doesn't return an error, but simply skips them
+// If the internal data structure is empty, it will do nothing and return an ErrPersist error
+// It returns an ErrWriteSqlite error if the transaction couldn't be written, in which case nothing is persisted
+func (repository *SQLiteRepository) Persist() error {
+	repository.mutex.Lock()
+	defer repository.mutex.Unlock()
+
+	if len(repository.data) == 0 {
+		return Error(repository.name, ErrPersist, nil)
+	}
+
+	if errLock := repository.lockDataset(); errLock != nil {
+		return errLock
+	}
+
+	tx, errBegin := repository.db.Begin()
+	if errBegin != nil {
+		return Error(repository.name, ErrWriteSqlite, errBegin)
+	}
+	defer tx.Rollback()
+
+	persistedAt := formatDate(time.Now())
+	for _, mediaData := range repository.data {
+		metadata := mediaData.GetMetadata()
+		result, errInsert := tx.Exec(`INSERT INTO works (title, year, media_type, url, last_updated, persisted_at, image, image_caption)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?) ON CONFLICT (title, year, media_type) DO NOTHING`,
+			mediaData.GetWork().Title, mediaData.GetWork().Year, mediaData.GetMediaType().String(), mediaData.GetPage().GetUrl().String(),
+			formatDate(mediaData.GetWork().LastUpdated), persistedAt, metadata.Image, metadata.ImageCaption)
+		if errInsert != nil {
+			return Error(repository.name, ErrWriteSqlite, errInsert)
+		}
+
+		// The Work is already on the dataset
+		if inserted, _ := result.RowsAffected(); inserted == 0 {
+			continue
+		}
+
+		workId, errId := result.LastInsertId()
+		if errId != nil {
+			return Error(repository.name, ErrWriteSqlite, errId)
+		}
+
+		if errWorkData := insertWorkData(tx, workId, mediaData); errWorkData != nil {
+			return Error(repository.name, ErrWriteSqlite, errWorkData)
+		}
+	}
+
+	if errCommit := tx.Commit(); errCommit != nil {
+		return Error(repository.name, ErrWriteSqlite, errCommit)
+	}
+
+	repository.data = []media.Media{}
+	repository.index = make(map[media.MediaKey]struct{})
+
+	return nil
+}
+
+// GetWorkPages retrieves all persisted Work urls on the SQLite dataset and the last time they were updated
+// Returns a map relating page URLs to the last time they were updated
+func (repository *SQLiteRepository) GetWorkPages() (map[string]time.Time, error) {
+	repository.mutex.Lock()
+	defer repository.mutex.Unlock()
+
+	datasetPages := make(map[string]time.Time, 0)
+
+	rows, errQuery := repository.db.Query(`SELECT url, last_updated FROM works`)
+	if errQuery != nil {
+		return nil, Error(repository.name, ErrReadSqlite, errQuery)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var workUrl, lastUpdatedString string
+		if errScan := rows.Scan(&workUrl, &lastUpdatedString); errScan != nil {
+			return nil, Error(repository.name, ErrReadSqlite, errScan)
+		}
+
+		lastUpdated, errLastUpdated := time.Parse(timeLayout, lastUpdatedString)
+		if errLastUpdated != nil {
+			return nil, Error(repository.name, ErrParseTime, errLastUpdated)
+		}
+
+		datasetPages[workUrl] = lastUpdated
+	}
+
+	if errRows := rows.Err(); errRows != nil {
+		return nil, Error(repository.name, ErrReadSqlite, errRows)
+	}
+
+	return datasetPages, nil
+}
+
+// GetTropes retrieves the title of every trope and subtrope of the Works persisted on the SQLite dataset
+// Returns a set of the trope titles, which are the IDs of their Main pages
+func (repository *SQLiteRepository) GetTropes() (map[string]struct{}, error) {
+	repository.mutex.Lock()
+	defer repository.mutex.Unlock()
+
+	datasetTropes := make(map[string]struct{})
+
+	rows, errQuery := repository.db.Query(`SELECT DISTINCT tropes.title FROM tropes JOIN work_tropes ON work_tropes.trope_id = tropes.id`)
+	if errQuery != nil {
+		return nil, Error(repository.name, ErrReadSqlite, errQuery)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var title string
+		if errScan := rows.Scan(&title); errScan != nil {
+			return nil, Error(repository.name, ErrReadSqlite, errScan)
+		}
+
+		datasetTropes[title] = struct{}{}
+	}
+
+	if errRows := rows.Err(); errRows != nil {
+		return nil, Error(repository.name, ErrReadSqlite, errRows)
+	}
+
+	return datasetTropes, nil
+}
+
+// GetWorkRelations retrieves the relations with other Works of every Work persisted on the SQLite dataset
+// Returns a map relating the URL of each Work to its relations
+func (repository *SQLiteRepository) GetWorkRelations() (map[string][]trope.Relation, error) {
+	repository.mutex.Lock()
+	defer repository.mutex.Unlock()
+
+	datasetRelations := make(map[string][]trope.Relation)
+
+	rows, errQuery := repository.db.Query(`SELECT works.url, relations.url, relations.anchor_text, relations.context, relations.type
+		FROM works LEFT JOIN relations ON relations.work_id = works.id ORDER BY works.id, relations.position`)
+	if errQuery != nil {
+		return nil, Error(repository.name, ErrReadSqlite, errQuery)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var workUrl string
+		var relationUrl, anchorText, context, relationType sql.NullString
+		if errScan := rows.Scan(&workUrl, &relationUrl, &anchorText, &context, &relationType); errScan != nil {
+			return nil, Error(repository.name, ErrReadSqlite, errScan)
+		}
+
+		if _, exists := datasetRelations[workUrl]; !exists {
+			datasetRelations[workUrl] = make([]trope.Relation, 0)
+		}
+
+		// Works without relations only have a row without a relation
+		if !relationUrl.Valid {
+			continue
+		}
+
+		relations, errRelations := media.ToRelations([]media.JsonRelation{{URL: relationUrl.String, AnchorText: anchorText.String,
+			Context: context.String, Type: relationType.String}})
+		if errRelations != nil {
+			return nil, Error(repository.name, ErrReadSqlite, errRelations)
+		}
+
+		datasetRelations[workUrl] = append(datasetRelations[workUrl], relations...)
+	}
+
+	if errRows := rows.Err(); errRows != nil {
+		return nil, Error(repository.name, ErrReadSqlite, errRows)
+	}
+
+	return datasetRelations, nil
+}
+
+// GetMediaByURL retrieves the Media of the Work page with the url persisted on the SQLite dataset
+// It returns a media.ErrMediaNotFound error if there's none, or an ErrReadSqlite error if the database couldn't be read
+func (repository *SQLiteRepository) GetMediaByURL(url string) (media.Media, error) {
+	repository.mutex.Lock()
+	defer repository.mutex.Unlock()
+
+	workIds, errWorkIds := repository.queryWorkIds(`SELECT id FROM works WHERE url = ? ORDER BY id LIMIT 1`, url)
+	if errWorkIds != nil {
+		return media.Media{}, errWorkIds
+	}
+
+	if len(workIds) == 0 {
+		return media.Media{}, Error(url, media.ErrMediaNotFound, nil)
+	}
+
+	return repository.readMedia(workIds[0])
+}
+
+// GetMedia retrieves the Media with the title and year persisted on the SQLite dataset
+// It returns a media.ErrMediaNotFound error if there's none, or an ErrReadSqlite error if the database couldn't be read
+func (repository *SQLiteRepository) GetMedia(title, year string) (media.Media, error) {
+	repository.mutex.Lock()
+	defer repository.mutex.Unlock()
+
+	workIds, errWorkIds := repository.queryWorkIds(`SELECT id FROM works WHERE title = ? AND year = ? ORDER BY id LIMIT 1`, title, year)
+	if errWorkIds != nil {
+		return media.Media{}, errWorkIds
+	}
+
+	if len(workIds) == 0 {
+		return media.Media{}, Error("Title: "+title+" Year: "+year, media.ErrMediaNotFound, nil)
+	}
+
+	return repository.readMedia(workIds[0])
+}
+
+// ForEachMedia calls handleMedia with every Media persisted on the SQLite dataset, in the order they were inserted
+// Works are read one at a time, so handleMedia is called while the repository is locked
+// It stops at the first error handleMedia returns and returns it, or returns an ErrReadSqlite error if the database couldn't be read
+func (repository *SQLiteRepository) ForEachMedia(handleMedia func(media.Media) error) error {
+	repository.mutex.Lock()
+	defer repository.mutex.Unlock()
+
+	workIds, errWorkIds := repository.queryWorkIds(`SELECT id FROM works ORDER BY id`)
+	if errWorkIds != nil {
+		return errWorkIds
+	}
+
+	for _, workId := range workIds {
+		workMedia, errMedia := repository.readMedia(workId)
+		if errMedia != nil {
+			return errMedia
+		}
+
+		if errHandle := handleMedia(workMedia); errHandle != nil {
+			return errHandle
+		}
+	}
+
+	return nil
+}
+
+// GetMediaWithTrope retrieves every Media persisted on the SQLite dataset that has the trope with the title as a trope or a subtrope
+// It returns an ErrReadSqlite error if the database couldn't be read
+func (repository *SQLiteRepository) GetMediaWithTrope(title string) ([]media.Media, error) {
+	repository.mutex.Lock()
+	defer repository.mutex.Unlock()
+
+	workIds, errWorkIds := repository.queryWorkIds(`SELECT DISTINCT work_tropes.work_id FROM work_tropes
+		JOIN tropes ON tropes.id = work_tropes.trope_id WHERE tropes.title = ? ORDER BY work_tropes.work_id`, title)
+	if errWorkIds != nil {
+		return nil, errWorkIds
+	}
+
+	mediaWithTrope := make([]media.Media, 0, len(workIds))
+	for _, workId := range workIds {
+		workMedia, errMedia := repository.readMedia(workId)
+		if errMedia != nil {
+			return nil, errMedia
+		}
+
+		mediaWithTrope = append(mediaWithTrope, workMedia)
+	}
+
+	return mediaWithTrope, nil
+}
+
+// CountMedia returns the number of Works persisted on the SQLite dataset
+// It returns an ErrReadSqlite error if the database couldn't be read
+func (repository *SQLiteRepository) CountMedia() (int, error) {
+	repository.mutex.Lock()
+	defer repository.mutex.Unlock()
+
+	var count int
+	if errCount := repository.db.QueryRow(`SELECT COUNT(*) FROM works`).Scan(&count); errCount != nil {
+		return 0, Error(repository.name, ErrReadSqlite, errCount)
+	}
+
+	return count, nil
+}
+
+// lockDataset acquires the lock of the dataset file if the repository doesn't hold it yet, and holds it until the repository is closed
+// The mutex must be held, and it returns an ErrWriteSqlite error with dataset_file.ErrLocked if another process is writing the dataset
+func (repository *SQLiteRepository) lockDataset() error {
+	if repository.lock != nil {
+		return nil
+	}
+
+	lock, errLock := dataset_file.AcquireLock(repository.name)
+	if errLock != nil {
+		return Error(repository.name, ErrWriteSqlite, errLock)
+	}
+
+	repository.lock = lock
+	return nil
+}
+
+// releaseLock releases the lock of the dataset file if the repository holds it
+func (repository *SQLiteRepository) releaseLock() error {
+	if repository.lock == nil {
+		return nil
+	}
+
+	errRelease := repository.lock.Release()
+	repository.lock = nil
+
+	return errRelease
+}
+
+// backupDataset backs up the dataset file before the first destructive write of the repository, so it's only backed up once
+// even if many records are updated. The database is copied with VACUUM INTO, which writes a consistent snapshot of it
+// instead of copying the file. The mutex must be held, and it returns an ErrWriteSqlite error if it couldn't be backed up
+func (repository *SQLiteRepository) backupDataset() error {
+	if repository.backedUp {
+		return nil
+	}
+
+	backupName, errRotate := dataset_file.RotateBackups(repository.name)
+	if errRotate != nil {
+		return Error(repository.name, ErrWriteSqlite, errRotate)
+	}
+
+	if _, errBackup := repository.db.Exec(`VACUUM INTO ?`, backupName); errBackup != nil {
+		return Error(repository.name, ErrWriteSqlite, Error(backupName, dataset_file.ErrBackup, errBackup))
+	}
+
+	repository.backedUp = true
+	return nil
+}
+
+// queryWorkIds returns the IDs of the Works selected by the query with the args
+// Rows are read before returning, because the database only has one connection and the Works are read with other queries
+func (repository *SQLiteRepository) queryWorkIds(query string, args ...interface{}) ([]int64, error) {
+	var workIds []int64
+	errQuery := queryRows(repository.db, func(rows *sql.Rows) error {
+		var workId int64
+		if errScan := rows.Scan(&workId); errScan != nil {
+			return errScan
+		}
+
+		workIds = append(workIds, workId)
+		return nil
+	}, query, args...)
+	if errQuery != nil {
+		return nil, Error(repository.name, ErrReadSqlite, errQuery)
+	}
+
+	return workIds, nil
+}
+
+// readMedia reads the Work with the workId ID from all the tables of the database and transforms it back into a Media object
+// It returns an ErrReadSqlite error if the database couldn't be read or the Work isn't a valid Media
+func (repository *SQLiteRepository) readMedia(workId int64) (media.Media, error) {
+	record, errRecord := readRecord(repository.db, workId)
+	if errRecord != nil {
+		return media.Media{}, Error(repository.name, ErrReadSqlite, errRecord)
+	}
+
+	workMedia, errMedia := media.ToMedia(record)
+	if errMedia != nil {
+		return media.Media{}, Error(repository.name, ErrReadSqlite, errMedia)
+	}
+
+	return workMedia, nil
+}
+
+// readRecord reads the Work with the workId ID from all the tables of the database into a JsonResponse record
+// The tropes have the indexes they are classified on, in the order they are declared, and every table is read in order
+func readRecord(db *sql.DB, workId int64) (media.JsonResponse, error) {
+	var record media.JsonResponse
+	errWork := db.QueryRow(`SELECT title, year, media_type, url, last_updated, image, image_caption FROM works WHERE id = ?`, workId).
+		Scan(&record.Title, &record.Year, &record.MediaType, &record.URL, &record.LastUpdated, &record.Metadata.Image, &record.Metadata.ImageCaption)
+	if errWork != nil {
+		return media.JsonResponse{}, errWork
+	}
+
+	// Tropes are keyed by their ID and namespace, because the same trope can be on several subpages of the Work
+	type workTrope struct {
+		tropeId   int64
+		namespace string
+	}
+	var workTropes []workTrope
+	jsonTropes := make(map[workTrope]*media.JsonTrope)
+	isMain := make(map[workTrope]bool)
+	errTropes := queryRows(db, func(rows *sql.Rows) error {
+		var key workTrope
+		var jsonTrope media.JsonTrope
+		var main bool
+		if errScan := rows.Scan(&key.tropeId, &jsonTrope.Title, &key.namespace, &main); errScan != nil {
+			return errScan
+		}
+
+		jsonTrope.Namespace = key.namespace
+		workTropes = append(workTropes, key)
+		jsonTropes[key], isMain[key] = &jsonTrope, main
+		return nil
+	}, `SELECT tropes.id, tropes.title, work_tropes.namespace, work_tropes.is_main FROM work_tropes
+		JOIN tropes ON tropes.id = work_tropes.trope_id WHERE work_tropes.work_id = ? ORDER BY work_tropes.rowid`, workId)
+	if errTropes != nil {
+		return media.JsonResponse{}, errTropes
+	}
+
+	tropeIndexes := make(map[int64][]string)
+	errIndexes := queryRows(db, func(rows *sql.Rows) error {
+		var tropeId int64
+		var tropeIndex string
+		if errScan := rows.Scan(&tropeId, &tropeIndex); errScan != nil {
+			return errScan
+		}
+
+		tropeIndexes[tropeId] = append(tropeIndexes[tropeId], tropeIndex)
+		return nil
+	}, `SELECT trope_id, trope_index FROM trope_indexes
+		WHERE trope_id IN (SELECT trope_id FROM work_tropes WHERE work_id = ?) ORDER BY trope_id, rowid`, workId)
+	if errIndexes != nil {
+		return media.JsonResponse{}, errIndexes
+	}
+
+	errExamples := queryRows(db, func(rows *sql.Rows) error {
+		var key workTrope
+		var example media.JsonExample
+		var subItems, spoilers string
+		errScan := rows.Scan(&key.tropeId, &key.namespace, &example.Text, &example.HTML, &subItems, &spoilers,
+			&example.Folder, &example.Header, &example.SubpageURL, &example.Position)
+		if errScan != nil {
+			return errScan
+		}
+
+		if errSubItems := json.Unmarshal([]byte(subItems), &example.SubItems); errSubItems != nil {
+			return errSubItems
+		}
+
+		if errSpoilers := json.Unmarshal([]byte(spoilers), &example.Spoilers); errSpoilers != nil {
+			return errSpoilers
+		}
+
+		if jsonTrope, exists := jsonTropes[key]; exists {
+			jsonTrope.Examples = append(jsonTrope.Examples, example)
+		}
+		return nil
+	}, `SELECT trope_id, namespace, text, html, sub_items, spoilers, folder, header, subpage_url, position FROM examples
+		WHERE work_id = ? ORDER BY trope_id, namespace, example_order`, workId)
+	if errExamples != nil {
+		return media.JsonResponse{}, errExamples
+	}
+
+	for _, key := range workTropes {
+		jsonTrope := jsonTropes[key]
+		jsonTrope.Indexes = tropeIndexes[key.tropeId]
+		if isMain[key] {
+			record.Tropes = append(record.Tropes, *jsonTrope)
+		} else {
+			record.SubTropes = append(record.SubTropes, *jsonTrope)
+		}
+	}
+
+	for table, values := range map[string]*[]string{"work_descriptions": &record.Metadata.Description, "work_creators": &record.Metadata.Creators,
+		"work_see_also": &record.Metadata.SeeAlso, "work_franchises": &record.Metadata.Franchises} {
+		errValues := queryRows(db, func(rows *sql.Rows) error {
+			var value string
+			if errScan := rows.Scan(&value); errScan != nil {
+				return errScan
+			}
+
+			*values = append(*values, value)
+			return nil
+		}, `SELECT value FROM `+table+` WHERE work_id = ? ORDER BY position`, workId)
+		if errValues != nil {
+			return media.JsonResponse{}, errValues
+		}
+	}
+
+	errRelations := queryRows(db, func(rows *sql.Rows) error {
+		var relation media.JsonRelation
+		if errScan := rows.Scan(&relation.URL, &relation.AnchorText, &relation.Context, &relation.Type); errScan != nil {
+			return errScan
+		}
+
+		record.Relations = append(record.Relations, relation)
+		return nil
+	}, `SELECT url, anchor_text, context, type FROM relations WHERE work_id = ? ORDER BY position`, workId)
+	if errRelations != nil {
+		return media.JsonResponse{}, errRelations
+	}
+
+	return record, nil
+}
+
+// queryRows runs the query with the args and calls scanRow with every row, closing them before returning
+func queryRows(db *sql.DB, scanRow func(*sql.Rows) error, query string, args ...interface{}) error {
+	rows, errQuery := db.Query(query, args...)
+	if errQuery != nil {
+		return errQuery
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		if errScan := scanRow(rows); errScan != nil {
+			return errScan
+		}
+	}
+
+	return rows.Err()
+}
+
+// insertWorkData inserts on the tx transaction the tropes, examples, metadata and relations of the mediaData Work with the workId ID
+func insertWorkData(tx *sql.Tx, workId int64, mediaData media.Media) error {
+	tropes, subTropes := media.GetJsonTropes(mediaData)
+	for _, workTropes := range []struct {
+		tropes []media.JsonTrope
+		isMain bool
+	}{{tropes, true}, {subTropes, false}} {
+		for _, jsonTrope := range workTropes.tropes {
+			if errTrope := insertTrope(tx, workId, jsonTrope, workTropes.isMain); errTrope != nil {
+				return errTrope
+			}
+		}
+	}
+
+	metadata := media.GetJsonMetadata(mediaData.GetMetadata())
+	for table, values := range map[string][]string{"work_descriptions": metadata.Description, "work_creators": metadata.Creators,
+		"work_see_also": metadata.SeeAlso, "work_franchises": metadata.Franchises} {
+		for position, value := range values {
+			if _, errInsert := tx.Exec(`INSERT INTO `+table+` (work_id, position, value) VALUES (?, ?, ?)`, workId, position, value); errInsert != nil {
+				return errInsert
+			}
+		}
+	}
+
+	for position, relation := range media.GetJsonRelations(mediaData.GetRelations()) {
+		_, errInsert := tx.Exec(`INSERT INTO relations (work_id, position, url, anchor_text, context, type) VALUES (?, ?, ?, ?, ?, ?)`,
+			workId, position, relation.URL, relation.AnchorText, relation.Context, relation.Type)
+		if errInsert != nil {
+			return errInsert
+		}
+	}
+
+	return nil
+}
+
+// insertTrope inserts on the tx transaction the jsonTrope trope, if it's not on the dataset yet, with its indexes,
+// and relates it with the Work with the workId ID along with its examples
+func insertTrope(tx *sql.Tx, workId int64, jsonTrope media.JsonTrope, isMain bool) error {
+	if _, errInsert := tx.Exec(`INSERT INTO tropes (title) VALUES (?) ON CONFLICT (title) DO NOTHING`, jsonTrope.Title); errInsert != nil {
+		return errInsert
+	}
+
+	var tropeId int64
+	if errTrope := tx.QueryRow(`SELECT id FROM tropes WHERE title = ?`, jsonTrope.Title).Scan(&tropeId); errTrope != nil {
+		return errTrope
+	}
+
+	for _, tropeIndex := range jsonTrope.Indexes {
+		if _, errIndex := tx.Exec(`INSERT OR IGNORE INTO trope_indexes (trope_id, trope_index) VALUES (?, ?)`, tropeId, tropeIndex); errIndex != nil {
+			return errIndex
+		}
+	}
+
+	_, errWorkTrope := tx.Exec(`INSERT OR IGNORE INTO work_tropes (work_id, trope_id, namespace, is_main) VALUES (?, ?, ?, ?)`,
+		workId, tropeId, jsonTrope.Namespace, isMain)
+	if errWorkTrope != nil {
+		return errWorkTrope
+	}
+
+	for order, example := range jsonTrope.Examples {
+		subItems, _ := json.Marshal(nonNilStrings(example.SubItems))
+		spoilers, _ := json.Marshal(nonNilStrings(example.Spoilers))
+		_, errExample := tx.Exec(`INSERT INTO examples (work_id, trope_id, namespace, example_order, text, html, sub_items, spoilers, folder, header, subpage_url, position)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`, workId, tropeId, jsonTrope.Namespace, order, example.Text, example.HTML,
+			string(subItems), string(spoilers), example.Folder, example.Header, example.SubpageURL, example.Position)
+		if errExample != nil {
+			return errExample
+		}
+	}
+
+	return nil
+}
+
+// nonNilStrings returns an empty array instead of a nil one, so it's marshalled as an empty JSON array
+func nonNilStrings(values []string) []string {
+	if values == nil {
+		return make([]string, 0)
+	}
+
+	return values
+}
+
+// formatDate transforms a date to a unified string format across all datasets
+func formatDate(date time.Time) string {
+	return date.Format(timeLayout)
+}
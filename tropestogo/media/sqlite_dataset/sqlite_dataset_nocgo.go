@@ -0,0 +1,17 @@
+//go:build !cgo
+
+package sqlite_dataset
+
+import (
+	"github.com/jlgallego99/TropesToGo/media"
+)
+
+// SQLiteRepository stands for the RepositoryMedia of the SQLite datasets on builds without cgo, where it can't be created
+type SQLiteRepository struct {
+	media.RepositoryMedia
+}
+
+// NewSQLiteRepository always returns an ErrOpenSqlite error with ErrNoCgo, because the SQLite driver needs cgo
+func NewSQLiteRepository(name string) (*SQLiteRepository, error) {
+	return nil, Error(name+".sqlite", ErrOpenSqlite, ErrNoCgo)
+}
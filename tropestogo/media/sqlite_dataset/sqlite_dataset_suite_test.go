@@ -0,0 +1,15 @@
+//go:build cgo
+
+package sqlite_dataset_test
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func TestSqliteDataset(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "SqliteDataset Suite")
+}
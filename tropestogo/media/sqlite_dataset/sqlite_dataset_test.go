@@ -0,0 +1,423 @@
+//go:build cgo
+
+package sqlite_dataset_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/jlgallego99/TropesToGo/media"
+	"github.com/jlgallego99/TropesToGo/media/dataset_file"
+	"github.com/jlgallego99/TropesToGo/media/repository_conformance"
+	"github.com/jlgallego99/TropesToGo/media/sqlite_dataset"
+	"github.com/jlgallego99/TropesToGo/trope"
+	"github.com/jlgallego99/TropesToGo/tvtropespages"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+const (
+	oldboyUrl = "https://tvtropes.org/pmwiki/pmwiki.php/Film/Oldboy2003"
+	randomMax = 10
+	randomMin = 2
+)
+
+var repository *sqlite_dataset.SQLiteRepository
+var errorRepository, errRemoveAll, errAddMedia, errPersist error
+var mediaEntry media.Media
+var tropes map[trope.Trope]struct{}
+var numTropes int
+
+var seededRand = rand.New(rand.NewSource(time.Now().UnixNano()))
+
+var _ = BeforeSuite(func() {
+	numTropes = seededRand.Intn(randomMax-randomMin) + randomMin
+
+	tropes = createTropes(numTropes, randomTrope)
+	subTropes := createTropes(numTropes, randomSubTrope)
+	for subTrope := range subTropes {
+		tropes[subTrope] = struct{}{}
+	}
+
+	tvTropesPage, _ := tvtropespages.NewPage(context.Background(), oldboyUrl, false, nil)
+	mediaEntry, _ = media.NewMedia("Oldboy", "2003", time.Now(), tropes, tvTropesPage, media.Film)
+	mediaEntry.SetMetadata(trope.WorkMetadata{Description: []string{"First paragraph", "Second paragraph"}, Creators: []string{"Park Chan-wook"}})
+	remake, _ := trope.NewRelation("https://tvtropes.org/pmwiki/pmwiki.php/Film/Oldboy2013", "English-language remake",
+		"An English-language remake was released on November 27, 2013.", trope.AdaptationRelation)
+	mediaEntry.AddRelations(remake)
+})
+
+var _ = Describe("SqliteDataset", func() {
+	BeforeEach(func() {
+		repository, errorRepository = sqlite_dataset.NewSQLiteRepository("dataset")
+	})
+
+	AfterEach(func() {
+		// Reset database
+		repository.RemoveAll()
+		repository.Close()
+	})
+
+	Context("Create SQLite repository", func() {
+		BeforeEach(func() {
+			errPersist = repository.Persist()
+		})
+
+		It("Should have created a SQLite file", func() {
+			Expect("dataset.sqlite").To(BeAnExistingFile())
+		})
+
+		It("Shouldn't return an error", func() {
+			Expect(errorRepository).To(BeNil())
+		})
+
+		It("Shouldn't be able to persist anything", func() {
+			Expect(errors.Is(errPersist, sqlite_dataset.ErrPersist)).To(BeTrue())
+		})
+	})
+
+	Context("Add a Media to the SQLite file", func() {
+		BeforeEach(func() {
+			errAddMedia = repository.AddMedia(mediaEntry)
+			errPersist = repository.Persist()
+		})
+
+		It("Should have all the correct fields", func() {
+			correctRecord("2003")
+		})
+
+		It("Should relate every trope with the Work on its namespace", func() {
+			Expect(countRows(`SELECT COUNT(*) FROM work_tropes WHERE is_main = 1 AND namespace = 'Film'`)).To(Equal(numTropes))
+			Expect(countRows(`SELECT COUNT(*) FROM work_tropes`)).To(Equal(len(tropes)))
+		})
+
+		It("Should have the metadata and relations of the Work in order", func() {
+			Expect(queryValue(`SELECT value FROM work_descriptions WHERE position = 1`)).To(Equal("Second paragraph"))
+			Expect(queryValue(`SELECT value FROM work_creators WHERE position = 0`)).To(Equal("Park Chan-wook"))
+			Expect(queryValue(`SELECT type FROM relations WHERE position = 0`)).To(Equal(trope.AdaptationRelation.String()))
+		})
+
+		It("Shouldn't return an error", func() {
+			Expect(errAddMedia).To(BeNil())
+			Expect(errPersist).To(BeNil())
+		})
+	})
+
+	Context("Add duplicated Media to the SQLite file", func() {
+		BeforeEach(func() {
+			errAddMedia = repository.AddMedia(mediaEntry)
+			errAddMedia = repository.AddMedia(mediaEntry)
+			errPersist = repository.Persist()
+		})
+
+		It("Should only be one record on the SQLite file", func() {
+			Expect(countRows(`SELECT COUNT(*) FROM works`)).To(Equal(1))
+		})
+
+		It("Should return an error", func() {
+			Expect(errors.Is(errAddMedia, sqlite_dataset.ErrDuplicatedMedia)).To(BeTrue())
+			Expect(errPersist).To(BeNil())
+		})
+	})
+
+	Context("Remove SQLite file contents", func() {
+		BeforeEach(func() {
+			errAddMedia = repository.AddMedia(mediaEntry)
+			errPersist = repository.Persist()
+			errRemoveAll = repository.RemoveAll()
+		})
+
+		It("Should still exist a SQLite file", func() {
+			Expect("dataset.sqlite").To(BeAnExistingFile())
+		})
+
+		It("Should have no Works nor tropes", func() {
+			Expect(countRows(`SELECT COUNT(*) FROM works`)).To(Equal(0))
+			Expect(countRows(`SELECT COUNT(*) FROM tropes`)).To(Equal(0))
+			Expect(countRows(`SELECT COUNT(*) FROM examples`)).To(Equal(0))
+		})
+
+		It("Should have no errors", func() {
+			Expect(errRemoveAll).To(BeNil())
+		})
+	})
+
+	Context("Remove contents of SQLite file that doesn't exist", func() {
+		BeforeEach(func() {
+			os.Remove("dataset.sqlite")
+			errRemoveAll = repository.RemoveAll()
+		})
+
+		It("A SQLite file shouldn't exist", func() {
+			Expect("dataset.sqlite").To(Not(BeAnExistingFile()))
+		})
+
+		It("Should return an error", func() {
+			Expect(errors.Is(errRemoveAll, sqlite_dataset.ErrFileNotExists)).To(BeTrue())
+		})
+	})
+
+	Context("Update the Year, URL and tropes of a Film in the SQLite file", func() {
+		var errUpdate error
+		var workId int
+		var newTropes map[trope.Trope]struct{}
+
+		BeforeEach(func() {
+			errAddMedia = repository.AddMedia(mediaEntry)
+			errPersist = repository.Persist()
+			workId = countRows(`SELECT id FROM works`)
+
+			// Create the new Media to be updated
+			newTropes = createTropes(numTropes, randomTrope)
+			tvTropesPage, _ := tvtropespages.NewPage(context.Background(), oldboyUrl, false, nil)
+			updatedMediaEntry, _ := media.NewMedia("Oldboy", "2013", time.Now(), newTropes, tvTropesPage, media.Film)
+
+			errUpdate = repository.UpdateMedia("Oldboy", "2003", updatedMediaEntry)
+		})
+
+		It("Should have the new record updated in place", func() {
+			correctRecord("2013")
+			Expect(countRows(`SELECT id FROM works`)).To(Equal(workId))
+		})
+
+		It("Should replace the tropes and relations of the Work", func() {
+			Expect(countRows(`SELECT COUNT(*) FROM work_tropes`)).To(Equal(len(newTropes)))
+			Expect(countRows(`SELECT COUNT(*) FROM relations`)).To(Equal(0))
+		})
+
+		It("Should have backed up the SQLite file before updating it", func() {
+			backup, errOpen := sql.Open("sqlite3", "dataset.sqlite"+dataset_file.BackupSuffix)
+			Expect(errOpen).To(BeNil())
+			defer backup.Close()
+
+			var year string
+			Expect(backup.QueryRow(`SELECT year FROM works`).Scan(&year)).To(Succeed())
+			Expect(year).To(Equal("2003"))
+		})
+
+		It("Shouldn't return an error", func() {
+			Expect(errUpdate).To(BeNil())
+			Expect(errPersist).To(BeNil())
+		})
+	})
+
+	Context("Persist an already persisted before record", func() {
+		BeforeEach(func() {
+			// Persist first
+			errAddMedia = repository.AddMedia(mediaEntry)
+			errPersist = repository.Persist()
+
+			// Try to persist again the same Media
+			errAddMedia = repository.AddMedia(mediaEntry)
+			errPersist = repository.Persist()
+		})
+
+		It("Should only be one Media record on the SQLite file", func() {
+			Expect(countRows(`SELECT COUNT(*) FROM works`)).To(Equal(1))
+			Expect(countRows(`SELECT COUNT(*) FROM work_tropes`)).To(Equal(len(tropes)))
+		})
+
+		It("Shouldn't return an error", func() {
+			Expect(errAddMedia).To(BeNil())
+			Expect(errPersist).To(BeNil())
+		})
+	})
+
+	Context("Add Media from several goroutines at the same time", func() {
+		const addingGoroutines = 20
+		var addErrors []error
+
+		BeforeEach(func() {
+			addErrors = make([]error, addingGoroutines)
+			var adding sync.WaitGroup
+			for i := 0; i < addingGoroutines; i++ {
+				adding.Add(1)
+				go func(i int) {
+					defer adding.Done()
+
+					// Half of the goroutines add the same Media and the other half a different Media each
+					addedMedia := mediaEntry
+					if i%2 == 1 {
+						tvTropesPage, _ := tvtropespages.NewPage(context.Background(), oldboyUrl+fmt.Sprint(i), false, nil)
+						addedMedia, _ = media.NewMedia("Oldboy"+fmt.Sprint(i), "2003", time.Now(), tropes, tvTropesPage, media.Film)
+					}
+
+					addErrors[i] = repository.AddMedia(addedMedia)
+				}(i)
+			}
+			adding.Wait()
+
+			errPersist = repository.Persist()
+		})
+
+		It("Should only add each Media once", func() {
+			duplicated := 0
+			for _, errAdd := range addErrors {
+				if errAdd != nil {
+					Expect(errors.Is(errAdd, sqlite_dataset.ErrDuplicatedMedia)).To(BeTrue())
+					duplicated++
+				}
+			}
+
+			Expect(duplicated).To(Equal(addingGoroutines/2 - 1))
+			Expect(errPersist).To(BeNil())
+		})
+
+		It("Should persist every different Media sharing the same tropes", func() {
+			Expect(countRows(`SELECT COUNT(*) FROM works`)).To(Equal(addingGoroutines/2 + 1))
+			Expect(countRows(`SELECT COUNT(*) FROM tropes`)).To(Equal(len(tropes)))
+		})
+	})
+
+	Context("Get all the URLs of the persisted Media and its last updated time", func() {
+		var workPages map[string]time.Time
+		var errGetWorkPages error
+
+		BeforeEach(func() {
+			errAddMedia = repository.AddMedia(mediaEntry)
+			Expect(errAddMedia).To(BeNil())
+			errPersist = repository.Persist()
+			Expect(errPersist).To(BeNil())
+
+			workPages, errGetWorkPages = repository.GetWorkPages()
+		})
+
+		It("Shouldn't return an error", func() {
+			Expect(errGetWorkPages).To(BeNil())
+		})
+
+		It("Should return an URL and its last updated time", func() {
+			Expect(workPages).To(HaveLen(1))
+			for workUrl, workLastUpdated := range workPages {
+				Expect(workUrl).To(Equal(oldboyUrl))
+				Expect(workLastUpdated).To(Not(Equal(time.Time{})))
+			}
+		})
+	})
+
+	Context("Get all the tropes of the persisted Media", func() {
+		var datasetTropes map[string]struct{}
+		var errGetTropes error
+
+		BeforeEach(func() {
+			errAddMedia = repository.AddMedia(mediaEntry)
+			Expect(errAddMedia).To(BeNil())
+			errPersist = repository.Persist()
+			Expect(errPersist).To(BeNil())
+
+			datasetTropes, errGetTropes = repository.GetTropes()
+		})
+
+		It("Shouldn't return an error", func() {
+			Expect(errGetTropes).To(BeNil())
+		})
+
+		It("Should return the title of every trope and subtrope", func() {
+			Expect(datasetTropes).To(HaveLen(len(tropes)))
+			for mediaTrope := range tropes {
+				Expect(datasetTropes).To(HaveKey(mediaTrope.GetTitle()))
+			}
+		})
+	})
+
+	Context("Get the relations of the persisted Media", func() {
+		var datasetRelations map[string][]trope.Relation
+		var errGetRelations error
+
+		BeforeEach(func() {
+			errAddMedia = repository.AddMedia(mediaEntry)
+			Expect(errAddMedia).To(BeNil())
+			errPersist = repository.Persist()
+			Expect(errPersist).To(BeNil())
+
+			datasetRelations, errGetRelations = repository.GetWorkRelations()
+		})
+
+		It("Shouldn't return an error", func() {
+			Expect(errGetRelations).To(BeNil())
+		})
+
+		It("Should return the relations of every Work by its URL", func() {
+			Expect(datasetRelations).To(HaveLen(1))
+			Expect(datasetRelations[oldboyUrl]).To(Equal(mediaEntry.GetRelations()))
+		})
+	})
+})
+
+var _ = repository_conformance.DescribeRepository("SQLite", func(name string) (media.RepositoryMedia, error) {
+	return sqlite_dataset.NewSQLiteRepository(name)
+}, repository_conformance.Features{Indexes: true, Examples: true, Metadata: true})
+
+var _ = AfterSuite(func() {
+	os.Remove("dataset.sqlite")
+
+	backups, _ := filepath.Glob("dataset.sqlite" + dataset_file.BackupSuffix + "*")
+	for _, backup := range backups {
+		os.Remove(backup)
+	}
+})
+
+// correctRecord checks if the only Work of the SQLite file has the year and no empty fields
+func correctRecord(year string) {
+	Expect(errPersist).To(BeNil())
+	Expect(countRows(`SELECT COUNT(*) FROM works`)).To(Equal(1))
+	Expect(queryValue(`SELECT title FROM works`)).To(Equal("Oldboy"))
+	Expect(queryValue(`SELECT year FROM works`)).To(Equal(year))
+	Expect(queryValue(`SELECT url FROM works`)).To(Equal(oldboyUrl))
+	Expect(queryValue(`SELECT media_type FROM works`)).To(Equal(media.Film.String()))
+	Expect(countRows(`SELECT COUNT(*) FROM work_tropes WHERE is_main = 1`) > 0).To(BeTrue())
+}
+
+// queryValue reads the first column of the first row returned by the query on the SQLite file
+func queryValue(query string) string {
+	db, errOpen := sql.Open("sqlite3", "dataset.sqlite")
+	Expect(errOpen).To(BeNil())
+	defer db.Close()
+
+	var value string
+	Expect(db.QueryRow(query).Scan(&value)).To(Succeed())
+
+	return value
+}
+
+// countRows reads the integer returned by the query on the SQLite file
+func countRows(query string) int {
+	db, errOpen := sql.Open("sqlite3", "dataset.sqlite")
+	Expect(errOpen).To(BeNil())
+	defer db.Close()
+
+	var count int
+	Expect(db.QueryRow(query).Scan(&count)).To(Succeed())
+
+	return count
+}
+
+// createTropes generates a map of numTropes size applying a callback function to all elements
+func createTropes(numTropes int, callback func() trope.Trope) map[trope.Trope]struct{} {
+	tropeset := make(map[trope.Trope]struct{}, numTropes)
+
+	for i := 0; i < numTropes; i++ {
+		tropeset[callback()] = struct{}{}
+	}
+
+	return tropeset
+}
+
+var randomTrope = func() trope.Trope {
+	trope, _ := trope.NewTrope("Trope"+fmt.Sprint(seededRand.Int()), 1, "")
+	return trope
+}
+
+var randomSubTrope = func() trope.Trope {
+	subWikis := []string{"SubWiki1", "SubWiki2"}
+	trope, _ := trope.NewTrope("Trope"+fmt.Sprint(seededRand.Int()), 1, subWikis[seededRand.Intn(1)])
+
+	return trope
+}
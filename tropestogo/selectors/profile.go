@@ -0,0 +1,273 @@
+package selectors
+
+import (
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strings"
+
+	"github.com/andybalholm/cascadia"
+	"gopkg.in/yaml.v3"
+)
+
+// ProfileVersion is the version of the selector profiles that TropesToGo understands
+// It changes whenever selectors are added, removed or change their meaning, so old profiles aren't silently misread
+const ProfileVersion = 1
+
+// Kinds of values of a selector profile, given by the check tag of their fields
+const (
+	CSSValue     = "css"
+	TextValue    = "text"
+	PatternValue = "pattern"
+)
+
+var (
+	ErrLoadProfile     = errors.New("couldn't load the selector profile file")
+	ErrProfileFormat   = errors.New("the selector profile file must be a YAML (.yaml, .yml) or JSON (.json) file")
+	ErrProfileVersion  = errors.New("the version of the selector profile isn't supported")
+	ErrInvalidProfile  = errors.New("the selector profile is invalid")
+	ErrEmptySelector   = errors.New("the selector is empty")
+	ErrInvalidSelector = errors.New("the selector isn't a valid CSS selector")
+	ErrInvalidPattern  = errors.New("the pattern isn't a valid regular expression")
+)
+
+//go:embed default.yaml
+var defaultProfileFile []byte
+
+// defaultProfile is the built-in profile, parsed once from the embedded default.yaml file
+var defaultProfile = mustParseDefault()
+
+// Profile holds every CSS selector and pattern that TropesToGo uses for finding its way on TvTropes pages
+// so they can be patched from a file when TvTropes changes its layout, without a new release
+type Profile struct {
+	// Version of the profile, which must be ProfileVersion
+	Version int `json:"version" yaml:"version"`
+	// Scraper are the selectors for extracting the data of Work and trope pages
+	Scraper ScraperSelectors `json:"scraper" yaml:"scraper"`
+	// Crawler are the selectors for finding the pages to extract
+	Crawler CrawlerSelectors `json:"crawler" yaml:"crawler"`
+	// Patterns are the regular expressions for recognizing parts of the URLs and titles of the pages
+	Patterns Patterns `json:"patterns" yaml:"patterns"`
+}
+
+// ScraperSelectors are the selectors of the scraper service
+// Selectors starting with "~" are relative to the headers of the page
+type ScraperSelectors struct {
+	WorkTitle            string `json:"work_title" yaml:"work_title" check:"css"`
+	WorkIndex            string `json:"work_index" yaml:"work_index" check:"css"`
+	MainArticle          string `json:"main_article" yaml:"main_article" check:"css"`
+	TropeList            string `json:"trope_list" yaml:"trope_list" check:"css"`
+	SubPagesNav          string `json:"subpages_nav" yaml:"subpages_nav" check:"css"`
+	SubPageList          string `json:"subpage_list" yaml:"subpage_list" check:"css"`
+	SubPageLink          string `json:"subpage_link" yaml:"subpage_link" check:"css"`
+	TropeTag             string `json:"trope_tag" yaml:"trope_tag" check:"css"`
+	TropeLink            string `json:"trope_link" yaml:"trope_link" check:"css"`
+	TropeFolder          string `json:"trope_folder" yaml:"trope_folder" check:"css"`
+	FolderToggleFunction string `json:"folder_toggle_function" yaml:"folder_toggle_function" check:"text"`
+	MainTropes           string `json:"main_tropes" yaml:"main_tropes" check:"css"`
+	MainTropesFolder     string `json:"main_tropes_folder" yaml:"main_tropes_folder" check:"css"`
+	CurrentSubpage       string `json:"current_subpage" yaml:"current_subpage" check:"css"`
+	CurrentUrl           string `json:"current_url" yaml:"current_url" check:"css"`
+	Spoiler              string `json:"spoiler" yaml:"spoiler" check:"css"`
+	Folder               string `json:"folder" yaml:"folder" check:"css"`
+	FolderLabel          string `json:"folder_label" yaml:"folder_label" check:"css"`
+	WorkImage            string `json:"work_image" yaml:"work_image" check:"css"`
+	WorkImageCaption     string `json:"work_image_caption" yaml:"work_image_caption" check:"css"`
+	Note                 string `json:"note" yaml:"note" check:"css"`
+	DescriptionEnd       string `json:"description_end" yaml:"description_end" check:"css"`
+	SectionIndexLink     string `json:"section_index_link" yaml:"section_index_link" check:"css"`
+	RelationContext      string `json:"relation_context" yaml:"relation_context" check:"css"`
+	WorkLink             string `json:"work_link" yaml:"work_link" check:"css"`
+	TropeParagraph       string `json:"trope_paragraph" yaml:"trope_paragraph" check:"css"`
+	TropeIndexLinks      string `json:"trope_index_links" yaml:"trope_index_links" check:"css"`
+	TropeExampleList     string `json:"trope_example_list" yaml:"trope_example_list" check:"css"`
+	TropeExampleItem     string `json:"trope_example_item" yaml:"trope_example_item" check:"css"`
+}
+
+// CrawlerSelectors are the selectors of the crawler service
+type CrawlerSelectors struct {
+	WorkPage         string `json:"work_page" yaml:"work_page" check:"css"`
+	CurrentSubpage   string `json:"current_subpage" yaml:"current_subpage" check:"css"`
+	SubWiki          string `json:"subwiki" yaml:"subwiki" check:"css"`
+	SubPage          string `json:"subpage" yaml:"subpage" check:"css"`
+	PaginationNav    string `json:"pagination_nav" yaml:"pagination_nav" check:"css"`
+	WorkHistoryPage  string `json:"work_history_page" yaml:"work_history_page" check:"css"`
+	LastUpdated      string `json:"last_updated" yaml:"last_updated" check:"css"`
+	TropeExampleLink string `json:"trope_example_link" yaml:"trope_example_link" check:"css"`
+	IndexTropeLink   string `json:"index_trope_link" yaml:"index_trope_link" check:"css"`
+}
+
+// Patterns are the regular expressions of the scraper and crawler services
+type Patterns struct {
+	// TropesSubpage matches the name of the subpages where the tropes of a Work are split alphabetically
+	TropesSubpage string `json:"tropes_subpage" yaml:"tropes_subpage" check:"pattern"`
+	// WorkYear matches the year of a Work on its title
+	WorkYear string `json:"work_year" yaml:"work_year" check:"pattern"`
+}
+
+// Field is a value of a Profile, identified by its section and its key on the profile file
+type Field struct {
+	Section string
+	Key     string
+	Value   string
+	Kind    string
+}
+
+// DefaultProfile returns the built-in selector profile of TropesToGo
+func DefaultProfile() Profile {
+	return defaultProfile
+}
+
+// LoadProfile reads the selector profile of the YAML or JSON file at path, validating it
+// Selectors missing on the file keep their value on the default profile, but its version is mandatory and unknown keys aren't allowed
+// It returns an ErrLoadProfile error if the file can't be read or parsed, an ErrProfileFormat error if it isn't a YAML or JSON file,
+// an ErrProfileVersion error if it has another version, or an ErrInvalidProfile error if any selector or pattern isn't valid
+func LoadProfile(path string) (Profile, error) {
+	fileContents, errRead := os.ReadFile(path)
+	if errRead != nil {
+		return Profile{}, fmt.Errorf("%w: "+path+"\n%w", ErrLoadProfile, errRead)
+	}
+
+	profile, errParse := parseProfile(fileContents, strings.ToLower(filepath.Ext(path)))
+	if errParse != nil {
+		return Profile{}, fmt.Errorf("%w: "+path+"\n%w", ErrLoadProfile, errParse)
+	}
+
+	if errValidate := profile.Validate(); errValidate != nil {
+		return Profile{}, fmt.Errorf("%w: "+path+"\n%w", ErrLoadProfile, errValidate)
+	}
+
+	return profile, nil
+}
+
+// Validate checks that the profile has the supported version, that no value is empty, that every CSS selector can be compiled
+// and that every pattern is a valid regular expression
+// It returns an ErrProfileVersion error or an ErrInvalidProfile error joining the errors of all the invalid values
+func (profile Profile) Validate() error {
+	if profile.Version != ProfileVersion {
+		return fmt.Errorf("%w: version %d, expected %d", ErrProfileVersion, profile.Version, ProfileVersion)
+	}
+
+	var errFields []error
+	for _, field := range profile.Fields() {
+		if errField := field.Validate(); errField != nil {
+			errFields = append(errFields, errField)
+		}
+	}
+
+	if len(errFields) > 0 {
+		return fmt.Errorf("%w\n%w", ErrInvalidProfile, errors.Join(errFields...))
+	}
+
+	return nil
+}
+
+// Fields returns every value of the profile, in the same order as on the default profile file
+func (profile Profile) Fields() []Field {
+	fields := make([]Field, 0)
+	for _, section := range []any{profile.Scraper, profile.Crawler, profile.Patterns} {
+		sectionValue := reflect.ValueOf(section)
+		sectionName := sectionTag(sectionValue.Type())
+		for i := 0; i < sectionValue.NumField(); i++ {
+			fieldType := sectionValue.Type().Field(i)
+			fields = append(fields, Field{
+				Section: sectionName,
+				Key:     fieldType.Tag.Get("yaml"),
+				Value:   sectionValue.Field(i).String(),
+				Kind:    fieldType.Tag.Get("check"),
+			})
+		}
+	}
+
+	return fields
+}
+
+// Validate checks that the value of the field isn't empty and, depending on its kind,
+// that it's a valid CSS selector or regular expression
+func (field Field) Validate() error {
+	name := field.Section + "." + field.Key
+	if strings.TrimSpace(field.Value) == "" {
+		return fmt.Errorf("%w: "+name, ErrEmptySelector)
+	}
+
+	switch field.Kind {
+	case CSSValue:
+		if _, errParse := cascadia.ParseGroup(AnyHeader(field.Value)); errParse != nil {
+			return fmt.Errorf("%w: "+name+" %q\n%w", ErrInvalidSelector, field.Value, errParse)
+		}
+	case PatternValue:
+		if _, errCompile := regexp.Compile(field.Value); errCompile != nil {
+			return fmt.Errorf("%w: "+name+" %q\n%w", ErrInvalidPattern, field.Value, errCompile)
+		}
+	}
+
+	return nil
+}
+
+// AnyHeader builds a selector that evaluates a selector relative to the headers of a page, starting with "~", after every header
+// Any other selector is returned as it is
+func AnyHeader(selector string) string {
+	selector = strings.TrimSpace(selector)
+	if !strings.HasPrefix(selector, "~") {
+		return selector
+	}
+
+	headerSelectors := make([]string, 0, 6)
+	for header := 1; header <= 6; header++ {
+		headerSelectors = append(headerSelectors, fmt.Sprintf("h%d %s", header, selector))
+	}
+
+	return strings.Join(headerSelectors, ", ")
+}
+
+// parseProfile parses the profile of the fileContents in the format of the extension over the default profile
+func parseProfile(fileContents []byte, extension string) (Profile, error) {
+	profile := DefaultProfile()
+	profile.Version = 0
+
+	switch extension {
+	case ".yaml", ".yml":
+		decoder := yaml.NewDecoder(strings.NewReader(string(fileContents)))
+		decoder.KnownFields(true)
+		if errDecode := decoder.Decode(&profile); errDecode != nil {
+			return Profile{}, errDecode
+		}
+	case ".json":
+		decoder := json.NewDecoder(strings.NewReader(string(fileContents)))
+		decoder.DisallowUnknownFields()
+		if errDecode := decoder.Decode(&profile); errDecode != nil {
+			return Profile{}, errDecode
+		}
+	default:
+		return Profile{}, ErrProfileFormat
+	}
+
+	return profile, nil
+}
+
+// sectionTag returns the key of the section of the sectionType on the profile file
+func sectionTag(sectionType reflect.Type) string {
+	profileType := reflect.TypeOf(Profile{})
+	for i := 0; i < profileType.NumField(); i++ {
+		if profileType.Field(i).Type == sectionType {
+			return profileType.Field(i).Tag.Get("yaml")
+		}
+	}
+
+	return ""
+}
+
+// mustParseDefault parses the embedded default profile, which is always valid because it's checked by the tests
+func mustParseDefault() Profile {
+	var profile Profile
+	if errDecode := yaml.Unmarshal(defaultProfileFile, &profile); errDecode != nil {
+		panic(errDecode)
+	}
+
+	return profile
+}
@@ -0,0 +1,121 @@
+package selectors_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+
+	"github.com/jlgallego99/TropesToGo/selectors"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+// writeProfile writes the contents on a profile file with the name on a temporary directory and returns its path
+func writeProfile(name, contents string) string {
+	path := filepath.Join(GinkgoT().TempDir(), name)
+	Expect(os.WriteFile(path, []byte(contents), 0644)).To(Succeed())
+
+	return path
+}
+
+var _ = Describe("Profile", func() {
+	Describe("Use the default profile", func() {
+		var profile selectors.Profile
+
+		BeforeEach(func() {
+			profile = selectors.DefaultProfile()
+		})
+
+		It("Should be a valid profile of the supported version", func() {
+			Expect(profile.Version).To(Equal(selectors.ProfileVersion))
+			Expect(profile.Validate()).To(Succeed())
+		})
+
+		It("Should have every selector and pattern of the scraper and the crawler", func() {
+			Expect(profile.Scraper.WorkTitle).To(Equal("h1.entry-title"))
+			Expect(profile.Crawler.SubWiki).To(Equal("a.subpage-link:not(.curr-subpage)"))
+			Expect(profile.Patterns.TropesSubpage).To(Equal("tropes[a-z]to[a-z]"))
+
+			for _, field := range profile.Fields() {
+				Expect(field.Value).NotTo(BeEmpty(), field.Section+"."+field.Key)
+				Expect(field.Kind).To(BeElementOf(selectors.CSSValue, selectors.TextValue, selectors.PatternValue))
+			}
+		})
+
+		It("Shouldn't be modified through the returned copies", func() {
+			profile.Scraper.WorkTitle = "h1.changed"
+
+			Expect(selectors.DefaultProfile().Scraper.WorkTitle).To(Equal("h1.entry-title"))
+		})
+	})
+
+	Describe("Evaluate selectors relative to the headers", func() {
+		It("Should evaluate them after every header", func() {
+			Expect(selectors.AnyHeader("~ ul li a")).To(Equal("h1 ~ ul li a, h2 ~ ul li a, h3 ~ ul li a, h4 ~ ul li a, h5 ~ ul li a, h6 ~ ul li a"))
+		})
+
+		It("Should leave the rest of selectors as they are", func() {
+			Expect(selectors.AnyHeader("#main-article ul")).To(Equal("#main-article ul"))
+		})
+	})
+
+	Describe("Load a profile file", func() {
+		It("Should patch the default profile with the selectors of a YAML file", func() {
+			profile, errLoad := selectors.LoadProfile(writeProfile("selectors.yaml", "version: 1\nscraper:\n  work_title: \"h1.page-title\"\n"))
+
+			Expect(errLoad).To(BeNil())
+			Expect(profile.Scraper.WorkTitle).To(Equal("h1.page-title"))
+			Expect(profile.Scraper.MainArticle).To(Equal(selectors.DefaultProfile().Scraper.MainArticle))
+			Expect(profile.Crawler).To(Equal(selectors.DefaultProfile().Crawler))
+		})
+
+		It("Should patch the default profile with the selectors of a JSON file", func() {
+			profile, errLoad := selectors.LoadProfile(writeProfile("selectors.json", `{"version": 1, "crawler": {"work_page": "table.works a"}}`))
+
+			Expect(errLoad).To(BeNil())
+			Expect(profile.Crawler.WorkPage).To(Equal("table.works a"))
+			Expect(profile.Scraper).To(Equal(selectors.DefaultProfile().Scraper))
+		})
+
+		It("Should reject a file without the supported version", func() {
+			_, errMissing := selectors.LoadProfile(writeProfile("selectors.yml", "scraper:\n  work_title: \"h1\"\n"))
+			_, errNewer := selectors.LoadProfile(writeProfile("selectors.json", `{"version": 2}`))
+
+			Expect(errors.Is(errMissing, selectors.ErrProfileVersion)).To(BeTrue())
+			Expect(errors.Is(errNewer, selectors.ErrProfileVersion)).To(BeTrue())
+		})
+
+		It("Should reject unknown keys", func() {
+			_, errYaml := selectors.LoadProfile(writeProfile("selectors.yaml", "version: 1\nscraper:\n  work_titel: \"h1\"\n"))
+			_, errJson := selectors.LoadProfile(writeProfile("selectors.json", `{"version": 1, "scraper": {"work_titel": "h1"}}`))
+
+			Expect(errors.Is(errYaml, selectors.ErrLoadProfile)).To(BeTrue())
+			Expect(errors.Is(errJson, selectors.ErrLoadProfile)).To(BeTrue())
+		})
+
+		It("Should report every invalid selector and pattern", func() {
+			_, errInvalid := selectors.LoadProfile(writeProfile("selectors.yaml",
+				"version: 1\nscraper:\n  trope_link: \"~ ul li [[\"\n  spoiler: \"\"\npatterns:\n  work_year: \"(19\"\n"))
+
+			Expect(errors.Is(errInvalid, selectors.ErrInvalidProfile)).To(BeTrue())
+			Expect(errors.Is(errInvalid, selectors.ErrInvalidSelector)).To(BeTrue())
+			Expect(errors.Is(errInvalid, selectors.ErrEmptySelector)).To(BeTrue())
+			Expect(errors.Is(errInvalid, selectors.ErrInvalidPattern)).To(BeTrue())
+			Expect(errInvalid.Error()).To(ContainSubstring("scraper.trope_link"))
+			Expect(errInvalid.Error()).To(ContainSubstring("patterns.work_year"))
+		})
+
+		It("Should reject files that aren't YAML or JSON", func() {
+			_, errFormat := selectors.LoadProfile(writeProfile("selectors.toml", "version = 1\n"))
+
+			Expect(errors.Is(errFormat, selectors.ErrProfileFormat)).To(BeTrue())
+		})
+
+		It("Should fail loading a file that doesn't exist", func() {
+			_, errLoad := selectors.LoadProfile(filepath.Join(GinkgoT().TempDir(), "missing.yaml"))
+
+			Expect(errors.Is(errLoad, selectors.ErrLoadProfile)).To(BeTrue())
+			Expect(errors.Is(errLoad, os.ErrNotExist)).To(BeTrue())
+		})
+	})
+})
@@ -0,0 +1,332 @@
+package checker
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/jlgallego99/TropesToGo/media"
+	"github.com/jlgallego99/TropesToGo/selectors"
+	"github.com/jlgallego99/TropesToGo/service/crawler"
+	"github.com/jlgallego99/TropesToGo/service/fetcher"
+	"github.com/jlgallego99/TropesToGo/service/scraper"
+	"github.com/jlgallego99/TropesToGo/trope"
+	"github.com/jlgallego99/TropesToGo/tvtropespages"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// TvTropesHistoryPath is the path of the history pages of TvTropes
+	TvTropesHistoryPath = "/pmwiki/article_history.php"
+	// DefaultWaitingTime is the time waited between two requests to TvTropes
+	DefaultWaitingTime = time.Second
+)
+
+var (
+	ErrInvalidField = errors.New("one or more fields for the Checker are invalid")
+	ErrUnknownPage  = errors.New("the URL isn't of a kind of TvTropes page that can be checked")
+	ErrNotFound     = errors.New("couldn't request the URL")
+	ErrParse        = errors.New("couldn't parse the page")
+)
+
+// CheckerConfig is an alias for a function that will accept a pointer to a ServiceChecker and modify its fields
+// Each function acts as one configuration for the checker
+type CheckerConfig func(sc *ServiceChecker) error
+
+// ServiceChecker checks the structure of TvTropes pages against the selectors of the scraper and the crawler,
+// so changes on the templates of TvTropes are detected before pages are silently skipped
+type ServiceChecker struct {
+	// fetcher retrieves the sample pages from TvTropes
+	fetcher fetcher.Fetcher
+
+	// scraper detects the layout of the Work pages and whether it would scrape them
+	scraper *scraper.ServiceScraper
+
+	// waitingTime is the time waited between two requests to TvTropes, so it isn't overloaded
+	waitingTime time.Duration
+
+	// profile holds the CSS selectors that are checked, which are also used by the scraper for detecting the layouts
+	profile selectors.Profile
+
+	// selectors are the CSS selectors of the profile with the kind of page they're evaluated on
+	selectors []Selector
+
+	// report holds the results of all the checked pages
+	report *Report
+}
+
+// NewServiceChecker creates a ServiceChecker with an empty report, requesting TvTropes through HTTP with the default waiting time
+// and checking the default selector profile, unless cfgs configurations say otherwise
+// It returns an ErrInvalidField error if any configuration isn't valid
+func NewServiceChecker(cfgs ...CheckerConfig) (*ServiceChecker, error) {
+	httpFetcher, errFetcher := fetcher.NewHTTPFetcher()
+	if errFetcher != nil {
+		return nil, errFetcher
+	}
+
+	checker := &ServiceChecker{
+		fetcher:     httpFetcher,
+		waitingTime: DefaultWaitingTime,
+		profile:     selectors.DefaultProfile(),
+	}
+
+	for _, cfg := range cfgs {
+		if errCfg := cfg(checker); errCfg != nil {
+			return nil, errCfg
+		}
+	}
+
+	serviceScraper, errScraper := scraper.NewServiceScraper(scraper.ConfigSelectors(checker.profile))
+	if errScraper != nil {
+		return nil, errScraper
+	}
+
+	checker.scraper = serviceScraper
+	checker.selectors = NewSelectors(checker.profile)
+	checker.report = NewReport(checker.selectors)
+
+	return checker, nil
+}
+
+// ConfigFetcher makes the checker retrieve the sample pages with the pageFetcher
+// It returns an ErrInvalidField error if the fetcher is nil
+func ConfigFetcher(pageFetcher fetcher.Fetcher) CheckerConfig {
+	return func(sc *ServiceChecker) error {
+		if pageFetcher == nil {
+			return ErrInvalidField
+		}
+
+		sc.fetcher = pageFetcher
+		return nil
+	}
+}
+
+// ConfigWaitingTime sets the time waited between two requests to TvTropes
+// It returns an ErrInvalidField error if it's negative
+func ConfigWaitingTime(waitingTime time.Duration) CheckerConfig {
+	return func(sc *ServiceChecker) error {
+		if waitingTime < 0 {
+			return ErrInvalidField
+		}
+
+		sc.waitingTime = waitingTime
+		return nil
+	}
+}
+
+// ConfigSelectors makes the checker check the selectors of the profile instead of the default ones
+// It returns an ErrInvalidField error if the profile isn't valid
+func ConfigSelectors(profile selectors.Profile) CheckerConfig {
+	return func(sc *ServiceChecker) error {
+		if errValidate := profile.Validate(); errValidate != nil {
+			return fmt.Errorf("%w\n%w", ErrInvalidField, errValidate)
+		}
+
+		sc.profile = profile
+		return nil
+	}
+}
+
+// GetReport returns the report of all the pages checked until now
+func (checker *ServiceChecker) GetReport() *Report {
+	return checker.report
+}
+
+// CheckSample retrieves a sample of TvTropes pages and checks all of them: a trope index page and, for every media type of the mediaLimits,
+// its first index page, up to its limit of Work pages listed on it (all of them if the limit is 0 or less),
+// and the history page and the page of the first trope of the first Work
+// Pages that can't be retrieved are reported on the FailedPages of the report
+// If the ctx context is cancelled, it stops and returns the context error, keeping the pages checked until then
+func (checker *ServiceChecker) CheckSample(ctx context.Context, mediaLimits []crawler.MediaLimit) error {
+	checker.checkUrl(ctx, crawler.TvTropesWeb+tvtropespages.TvTropesMainPath+trope.TopLevelIndexes[trope.NarrativeTrope])
+
+	for _, mediaLimit := range mediaLimits {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		indexUrl := crawler.TvTropesWeb + tvtropespages.TvTropesIndexPath + "?t=work&n=" + mediaLimit.MediaType.String()
+		log.Info().Msg("CHECKING STRUCTURE OF " + mediaLimit.MediaType.String() + " PAGES")
+		indexDoc := checker.checkUrl(ctx, indexUrl)
+		if indexDoc == nil {
+			continue
+		}
+
+		workUrls := getLinks(indexDoc, checker.profile.Crawler.WorkPage, mediaLimit.Limit)
+		for i, workUrl := range workUrls {
+			workDoc := checker.checkUrl(ctx, workUrl)
+			if workDoc == nil || i > 0 {
+				continue
+			}
+
+			tropeLinkSelector := checker.profile.Scraper.TropeList + " li a[href*='" + tvtropespages.TvTropesMainPath + "']"
+			for _, linkSelector := range []string{checker.profile.Crawler.WorkHistoryPage, tropeLinkSelector} {
+				for _, linkedUrl := range getLinks(workDoc, linkSelector, 1) {
+					checker.checkUrl(ctx, linkedUrl)
+				}
+			}
+		}
+	}
+
+	return ctx.Err()
+}
+
+// CheckPage checks the doc document of the TvTropes page at pageUrl, evaluating every selector of its kind of page
+// For Work pages, it also detects their layout and whether the scraper would scrape them
+// It returns an ErrUnknownPage error if the URL isn't of any known kind of TvTropes page
+func (checker *ServiceChecker) CheckPage(pageUrl string, doc *goquery.Document) error {
+	parsedUrl, errParse := url.Parse(pageUrl)
+	if errParse != nil {
+		return fmt.Errorf("%w: "+pageUrl+"\n%w", ErrUnknownPage, errParse)
+	}
+
+	kind, mediaType, errKind := getPageKind(parsedUrl)
+	if errKind != nil {
+		return errKind
+	}
+
+	checker.report.Pages[kind]++
+	for i, selector := range checker.selectors {
+		if selector.Kind == kind && doc.Find(selectors.AnyHeader(selector.Selector)).Length() > 0 {
+			checker.report.Selectors[i].Matched++
+		}
+	}
+
+	if kind != WorkPage {
+		return nil
+	}
+
+	if checker.report.Layouts[mediaType] == nil {
+		checker.report.Layouts[mediaType] = make(map[Layout]int)
+	}
+	checker.report.Layouts[mediaType][checker.getLayout(doc)]++
+
+	if valid, errValid := checker.scraper.CheckValidWorkPage(doc, parsedUrl); !valid {
+		reason := "not a valid work page"
+		if errValid != nil {
+			reason = errValid.Error()
+		}
+
+		checker.report.InvalidPages[pageUrl] = reason
+	}
+
+	return nil
+}
+
+// checkUrl retrieves the page at pageUrl and checks it, waiting the waiting time before requesting TvTropes
+// It returns the document of the page, or nil if it couldn't be retrieved or parsed, reporting it on the FailedPages
+func (checker *ServiceChecker) checkUrl(ctx context.Context, pageUrl string) *goquery.Document {
+	select {
+	case <-ctx.Done():
+		return nil
+	case <-time.After(checker.waitingTime):
+	}
+
+	resp, errFetch := checker.fetcher.Fetch(ctx, pageUrl)
+	if errFetch != nil {
+		if ctx.Err() == nil {
+			checker.report.FailedPages[pageUrl] = fmt.Errorf("%w\n%w", ErrNotFound, errFetch).Error()
+		}
+
+		return nil
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		checker.report.FailedPages[pageUrl] = fmt.Sprintf("%s (status %d)", ErrNotFound, resp.StatusCode)
+		return nil
+	}
+
+	doc, errDocument := goquery.NewDocumentFromReader(bytes.NewReader(resp.Body))
+	if errDocument != nil {
+		checker.report.FailedPages[pageUrl] = ErrParse.Error()
+		return nil
+	}
+
+	if errCheck := checker.CheckPage(pageUrl, doc); errCheck != nil {
+		checker.report.FailedPages[pageUrl] = errCheck.Error()
+	}
+
+	return doc
+}
+
+// getLayout detects how the tropes of a Work page are arranged, with the same checks the scraper uses to scrape them
+func (checker *ServiceChecker) getLayout(doc *goquery.Document) Layout {
+	// The scraper needs a title with the media type for detecting the subpages
+	if !strings.Contains(doc.Find(checker.profile.Scraper.WorkTitle).Text(), "/") {
+		return UnknownLayout
+	}
+
+	if checker.scraper.CheckTropesOnFolders(doc) {
+		return FoldersLayout
+	}
+
+	if checker.scraper.CheckTropesOnSubpages(doc) {
+		return SubpagesLayout
+	}
+
+	if doc.Find(checker.profile.Scraper.TropeList+" "+checker.profile.Scraper.TropeTag).Length() > 0 {
+		return ListLayout
+	}
+
+	return UnknownLayout
+}
+
+// getPageKind classifies a TvTropes URL on a kind of page, returning the media type of the Work pages
+// It returns an ErrUnknownPage error if it isn't of a known kind
+func getPageKind(pageUrl *url.URL) (PageKind, string, error) {
+	if pageUrl.Hostname() != crawler.TvTropesHostname {
+		return "", "", fmt.Errorf("%w: "+pageUrl.String(), ErrUnknownPage)
+	}
+
+	if pageUrl.Path == tvtropespages.TvTropesIndexPath {
+		return IndexPage, "", nil
+	}
+
+	if pageUrl.Path == TvTropesHistoryPath {
+		return HistoryPage, "", nil
+	}
+
+	if strings.HasPrefix(pageUrl.Path, tvtropespages.TvTropesMainPath) {
+		tropeId := strings.TrimPrefix(pageUrl.Path, tvtropespages.TvTropesMainPath)
+		for _, indexId := range trope.TopLevelIndexes {
+			if tropeId == indexId {
+				return TropeIndexPage, "", nil
+			}
+		}
+
+		return TropePage, "", nil
+	}
+
+	namespace, _, _ := strings.Cut(strings.TrimPrefix(pageUrl.Path, tvtropespages.TvTropesPmwiki), "/")
+	if mediaType, errMediaType := media.ToMediaType(namespace); strings.HasPrefix(pageUrl.Path, tvtropespages.TvTropesPmwiki) && errMediaType == nil {
+		return WorkPage, mediaType.String(), nil
+	}
+
+	return "", "", fmt.Errorf("%w: "+pageUrl.String(), ErrUnknownPage)
+}
+
+// getLinks returns the absolute URLs of up to limit links of the doc document found by the selector, all of them if limit is 0 or less
+func getLinks(doc *goquery.Document, selector string, limit int) []string {
+	links := make([]string, 0)
+	doc.Find(selector).EachWithBreak(func(_ int, link *goquery.Selection) bool {
+		href, exists := link.Attr("href")
+		if !exists {
+			return true
+		}
+
+		if strings.HasPrefix(href, "/") {
+			href = crawler.TvTropesWeb + href
+		}
+		links = append(links, href)
+
+		return limit <= 0 || len(links) < limit
+	})
+
+	return links
+}
@@ -0,0 +1,13 @@
+package checker_test
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func TestChecker(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Checker Suite")
+}
@@ -0,0 +1,285 @@
+package checker_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/jlgallego99/TropesToGo/media"
+	"github.com/jlgallego99/TropesToGo/selectors"
+	"github.com/jlgallego99/TropesToGo/service/checker"
+	"github.com/jlgallego99/TropesToGo/service/crawler"
+	"github.com/jlgallego99/TropesToGo/service/fetcher"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+const (
+	oldboyResource     = "../scraper/resources/oldboy2003.html"
+	avengersResource   = "../scraper/resources/theavengers2012.html"
+	tropeResource      = "../scraper/resources/chekhovsgun.html"
+	indexResource      = "../crawler/resources/film_index_page1.html"
+	historyResource    = "../crawler/resources/oldboy_history.html"
+	tropeIndexResource = "../crawler/resources/narrativetropes_index.html"
+	oldboyUrl          = "https://tvtropes.org/pmwiki/pmwiki.php/Film/Oldboy2003"
+	avengersUrl        = "https://tvtropes.org/pmwiki/pmwiki.php/Film/TheAvengers2012"
+	tropeUrl           = "https://tvtropes.org/pmwiki/pmwiki.php/Main/ChekhovsGun"
+	filmIndexUrl       = "https://tvtropes.org/pmwiki/pagelist_having_pagetype_in_namespace.php?t=work&n=Film"
+	historyUrl         = "https://tvtropes.org/pmwiki/article_history.php?article=Film.Oldboy2003"
+	tropeIndexUrl      = "https://tvtropes.org/pmwiki/pmwiki.php/Main/NarrativeTropes"
+)
+
+// resourceFetcher is a Fetcher that replays the local resources instead of requesting TvTropes
+// Unknown URLs are answered with a not found error
+type resourceFetcher map[string]string
+
+func (rf resourceFetcher) Fetch(_ context.Context, pageUrl string) (*fetcher.Response, error) {
+	resource, exists := rf[pageUrl]
+	if !exists {
+		return &fetcher.Response{URL: pageUrl, StatusCode: http.StatusNotFound, Header: http.Header{}}, nil
+	}
+
+	body, errRead := os.ReadFile(resource)
+	if errRead != nil {
+		return nil, errRead
+	}
+
+	return &fetcher.Response{URL: pageUrl, StatusCode: http.StatusOK, Header: http.Header{}, Body: body}, nil
+}
+
+func readDocument(resource string) *goquery.Document {
+	body, errRead := os.ReadFile(resource)
+	Expect(errRead).To(BeNil())
+
+	doc, errDocument := goquery.NewDocumentFromReader(bytes.NewReader(body))
+	Expect(errDocument).To(BeNil())
+
+	return doc
+}
+
+func getResult(report *checker.Report, pkg, name string) checker.SelectorResult {
+	for _, result := range report.Selectors {
+		if result.Package == pkg && result.Name == name {
+			return result
+		}
+	}
+
+	Fail("there isn't a selector " + pkg + "." + name)
+	return checker.SelectorResult{}
+}
+
+var _ = Describe("Checker", func() {
+	var serviceChecker *checker.ServiceChecker
+	var errChecker error
+
+	BeforeEach(func() {
+		serviceChecker, errChecker = checker.NewServiceChecker()
+	})
+
+	Describe("Create the checker", func() {
+		It("Should start with an empty report of every selector", func() {
+			Expect(errChecker).To(BeNil())
+			Expect(serviceChecker.GetReport().Selectors).To(HaveLen(len(checker.NewSelectors(selectors.DefaultProfile()))))
+			Expect(serviceChecker.GetReport().Pages).To(BeEmpty())
+			Expect(serviceChecker.GetReport().GetUnmatchedSelectors()).To(BeEmpty())
+		})
+
+		It("Should reject invalid configurations", func() {
+			_, errFetcher := checker.NewServiceChecker(checker.ConfigFetcher(nil))
+			_, errWaitingTime := checker.NewServiceChecker(checker.ConfigWaitingTime(-1))
+
+			Expect(errors.Is(errFetcher, checker.ErrInvalidField)).To(BeTrue())
+			Expect(errors.Is(errWaitingTime, checker.ErrInvalidField)).To(BeTrue())
+		})
+
+		It("Should check the selectors of the configured profile", func() {
+			profile := selectors.DefaultProfile()
+			profile.Scraper.WorkTitle = "h1.moved-title"
+			profileChecker, errProfile := checker.NewServiceChecker(checker.ConfigSelectors(profile))
+			Expect(errProfile).To(BeNil())
+
+			Expect(profileChecker.CheckPage(oldboyUrl, readDocument(oldboyResource))).To(Succeed())
+			report := profileChecker.GetReport()
+
+			Expect(getResult(report, "scraper", "work_title").Selector).To(Equal("h1.moved-title"))
+			Expect(report.GetUnmatchedSelectors()).To(ContainElement(getResult(report, "scraper", "work_title")))
+			Expect(report.Layouts[media.Film.String()][checker.UnknownLayout]).To(Equal(1))
+
+			profile.Scraper.WorkTitle = ""
+			_, errEmpty := checker.NewServiceChecker(checker.ConfigSelectors(profile))
+			Expect(errors.Is(errEmpty, selectors.ErrEmptySelector)).To(BeTrue())
+		})
+	})
+
+	Describe("Check pages", func() {
+		var errOldboy, errAvengers, errIndex, errHistory, errTrope error
+		var report *checker.Report
+
+		BeforeEach(func() {
+			errOldboy = serviceChecker.CheckPage(oldboyUrl, readDocument(oldboyResource))
+			errAvengers = serviceChecker.CheckPage(avengersUrl, readDocument(avengersResource))
+			errIndex = serviceChecker.CheckPage(filmIndexUrl, readDocument(indexResource))
+			errHistory = serviceChecker.CheckPage(historyUrl, readDocument(historyResource))
+			errTrope = serviceChecker.CheckPage(tropeUrl, readDocument(tropeResource))
+			report = serviceChecker.GetReport()
+		})
+
+		It("Should count the checked pages of every kind", func() {
+			Expect(errOldboy).To(BeNil())
+			Expect(errAvengers).To(BeNil())
+			Expect(errIndex).To(BeNil())
+			Expect(errHistory).To(BeNil())
+			Expect(errTrope).To(BeNil())
+
+			Expect(report.Pages[checker.WorkPage]).To(Equal(2))
+			Expect(report.Pages[checker.IndexPage]).To(Equal(1))
+			Expect(report.Pages[checker.HistoryPage]).To(Equal(1))
+			Expect(report.Pages[checker.TropePage]).To(Equal(1))
+			Expect(report.Pages[checker.TropeIndexPage]).To(Equal(0))
+		})
+
+		It("Should detect the layout of the Work pages", func() {
+			Expect(report.Layouts[media.Film.String()][checker.ListLayout]).To(Equal(1))
+			Expect(report.Layouts[media.Film.String()][checker.SubpagesLayout]).To(Equal(1))
+			Expect(report.InvalidPages).To(BeEmpty())
+		})
+
+		It("Should count the pages where every selector matched", func() {
+			Expect(getResult(report, "scraper", "work_title").Matched).To(Equal(2))
+			Expect(getResult(report, "scraper", "subpage_list").Matched).To(Equal(2))
+			Expect(getResult(report, "crawler", "work_page").Matched).To(Equal(1))
+			Expect(getResult(report, "crawler", "last_updated").Matched).To(Equal(1))
+		})
+
+		It("Should only report unmatched selectors of the checked kinds of pages", func() {
+			for _, result := range report.GetUnmatchedSelectors() {
+				Expect(result.Kind).NotTo(Equal(checker.TropeIndexPage))
+				Expect(result.Matched).To(Equal(0))
+			}
+		})
+
+		It("Should report the Work pages that wouldn't be scraped", func() {
+			Expect(serviceChecker.CheckPage("https://tvtropes.org/pmwiki/pmwiki.php/Film/Empty", readDocument("../scraper/resources/empty.html"))).To(Succeed())
+
+			Expect(report.InvalidPages).To(HaveKey("https://tvtropes.org/pmwiki/pmwiki.php/Film/Empty"))
+			Expect(report.Layouts[media.Film.String()][checker.UnknownLayout]).To(Equal(1))
+		})
+
+		It("Should reject pages that aren't of a known kind", func() {
+			errUnknown := serviceChecker.CheckPage("https://example.com/pmwiki/pmwiki.php/Film/Oldboy2003", readDocument(oldboyResource))
+			errNamespace := serviceChecker.CheckPage("https://tvtropes.org/pmwiki/pmwiki.php/Administrivia/AboutTVTropes", readDocument(oldboyResource))
+
+			Expect(errors.Is(errUnknown, checker.ErrUnknownPage)).To(BeTrue())
+			Expect(errors.Is(errNamespace, checker.ErrUnknownPage)).To(BeTrue())
+			Expect(report.Pages[checker.WorkPage]).To(Equal(2))
+		})
+	})
+
+	Describe("Check a sample of pages from TvTropes", func() {
+		var report *checker.Report
+		var errSample error
+
+		BeforeEach(func() {
+			indexDoc := readDocument(indexResource)
+			firstWork, _ := indexDoc.Find(selectors.DefaultProfile().Crawler.WorkPage).First().Attr("href")
+
+			serviceChecker, errChecker = checker.NewServiceChecker(checker.ConfigWaitingTime(0), checker.ConfigFetcher(resourceFetcher{
+				tropeIndexUrl: tropeIndexResource,
+				filmIndexUrl:  indexResource,
+				firstWork:     oldboyResource,
+			}))
+			Expect(errChecker).To(BeNil())
+
+			errSample = serviceChecker.CheckSample(context.Background(), []crawler.MediaLimit{{MediaType: media.Film, Limit: 2}})
+			report = serviceChecker.GetReport()
+		})
+
+		It("Should check the trope index, the media index and the sample of Work pages", func() {
+			Expect(errSample).To(BeNil())
+			Expect(report.Pages[checker.TropeIndexPage]).To(Equal(1))
+			Expect(report.Pages[checker.IndexPage]).To(Equal(1))
+			Expect(report.Pages[checker.WorkPage]).To(Equal(1))
+			Expect(getResult(report, "crawler", "index_trope_link").Matched).To(Equal(1))
+		})
+
+		It("Should report the pages that couldn't be retrieved", func() {
+			Expect(report.FailedPages).NotTo(BeEmpty())
+			for _, reason := range report.FailedPages {
+				Expect(reason).To(ContainSubstring(checker.ErrNotFound.Error()))
+			}
+		})
+
+		It("Should stop when the context is cancelled", func() {
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+
+			Expect(errors.Is(serviceChecker.CheckSample(ctx, []crawler.MediaLimit{{MediaType: media.Film, Limit: 2}}), context.Canceled)).To(BeTrue())
+		})
+	})
+
+	Describe("Compare and save the reports", func() {
+		var report, previous *checker.Report
+
+		BeforeEach(func() {
+			Expect(serviceChecker.CheckPage(oldboyUrl, readDocument(oldboyResource))).To(Succeed())
+			report = serviceChecker.GetReport()
+
+			previous = checker.NewReport(checker.NewSelectors(selectors.DefaultProfile()))
+			previous.Pages[checker.WorkPage] = 1
+			previous.Layouts[media.Film.String()] = map[checker.Layout]int{checker.FoldersLayout: 1}
+			for i := range previous.Selectors {
+				if previous.Selectors[i].Kind == checker.WorkPage {
+					previous.Selectors[i].Matched = 1
+				}
+			}
+			previous.Selectors[0].Selector = "h1.old-title"
+		})
+
+		It("Should describe the changes since the previous report", func() {
+			changes := report.Compare(previous)
+
+			Expect(changes).To(ContainElement(ContainSubstring(`scraper.work_title changed from "h1.old-title"`)))
+			Expect(changes).To(ContainElement("Film pages with a folders layout went from 1 to 0"))
+			Expect(changes).To(ContainElement("Film pages with a list layout went from 0 to 1"))
+			for _, result := range report.GetUnmatchedSelectors() {
+				if result.Kind == checker.WorkPage {
+					Expect(changes).To(ContainElement(result.Package + "." + result.Name + " stopped matching"))
+				}
+			}
+			Expect(report.Compare(report)).To(BeEmpty())
+		})
+
+		It("Should write the report with the changes", func() {
+			var text bytes.Buffer
+
+			Expect(report.WriteText(&text, previous)).To(Succeed())
+			Expect(text.String()).To(ContainSubstring("Selectors that matched nothing:"))
+			Expect(text.String()).To(ContainSubstring("Film: folders 0 subpages 0 list 1 unknown 0"))
+			Expect(text.String()).To(ContainSubstring("Changes since"))
+		})
+
+		It("Should load the same report it saved", func() {
+			path := filepath.Join(GinkgoT().TempDir(), "structure.json")
+
+			Expect(checker.SaveReport(path, report)).To(Succeed())
+			loaded, errLoad := checker.LoadReport(path)
+
+			Expect(errLoad).To(BeNil())
+			Expect(loaded.Pages).To(Equal(report.Pages))
+			Expect(loaded.Selectors).To(Equal(report.Selectors))
+			Expect(loaded.Layouts).To(Equal(report.Layouts))
+			Expect(report.Compare(loaded)).To(BeEmpty())
+		})
+
+		It("Should fail loading a report that doesn't exist", func() {
+			_, errLoad := checker.LoadReport(filepath.Join(GinkgoT().TempDir(), "missing.json"))
+
+			Expect(errors.Is(errLoad, checker.ErrLoadReport)).To(BeTrue())
+			Expect(errors.Is(errLoad, os.ErrNotExist)).To(BeTrue())
+		})
+	})
+})
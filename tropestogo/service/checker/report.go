@@ -0,0 +1,265 @@
+package checker
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"time"
+)
+
+var (
+	ErrLoadReport  = errors.New("couldn't load the structure report file")
+	ErrSaveReport  = errors.New("couldn't save the structure report file")
+	ErrWriteReport = errors.New("couldn't write the structure report")
+)
+
+// Layout enumerates the ways the tropes of a Work page can be arranged
+type Layout string
+
+const (
+	FoldersLayout  Layout = "folders"
+	SubpagesLayout Layout = "subpages"
+	ListLayout     Layout = "list"
+	UnknownLayout  Layout = "unknown"
+)
+
+// Layouts are all the layouts in the order they are reported
+var Layouts = []Layout{FoldersLayout, SubpagesLayout, ListLayout, UnknownLayout}
+
+// SelectorResult is a Selector with the number of checked pages of its kind where it matched something
+type SelectorResult struct {
+	Package  string   `json:"package"`
+	Name     string   `json:"name"`
+	Selector string   `json:"selector"`
+	Kind     PageKind `json:"kind"`
+	Matched  int      `json:"matched"`
+}
+
+// Report is the result of checking the structure of a sample of TvTropes pages against the selectors of TropesToGo
+// It's saved after every run so the next one can tell how the structure of TvTropes has changed
+type Report struct {
+	// Created is when the pages were checked
+	Created time.Time `json:"created"`
+	// Pages is the number of checked pages of each kind
+	Pages map[PageKind]int `json:"pages"`
+	// Selectors are the results of every checked selector, in the same order as the selector profile
+	Selectors []SelectorResult `json:"selectors"`
+	// Layouts is the number of Work pages of each media type with each layout
+	Layouts map[string]map[Layout]int `json:"layouts"`
+	// InvalidPages relates the URL of the Work pages that the scraper wouldn't scrape with the reason
+	InvalidPages map[string]string `json:"invalid_pages"`
+	// FailedPages relates the URL of the pages that couldn't be retrieved with the reason
+	FailedPages map[string]string `json:"failed_pages"`
+}
+
+// NewReport creates an empty Report of all the checkedSelectors
+func NewReport(checkedSelectors []Selector) *Report {
+	report := &Report{
+		Created:      time.Now(),
+		Pages:        make(map[PageKind]int),
+		Selectors:    make([]SelectorResult, 0, len(checkedSelectors)),
+		Layouts:      make(map[string]map[Layout]int),
+		InvalidPages: make(map[string]string),
+		FailedPages:  make(map[string]string),
+	}
+
+	for _, selector := range checkedSelectors {
+		report.Selectors = append(report.Selectors, SelectorResult{
+			Package:  selector.Package,
+			Name:     selector.Name,
+			Selector: selector.Selector,
+			Kind:     selector.Kind,
+		})
+	}
+
+	return report
+}
+
+// GetUnmatchedSelectors returns the selectors that matched nothing on all the checked pages of their kind,
+// which means that TvTropes has probably changed that part of its layout
+// Selectors of a kind of page that hasn't been checked aren't returned
+func (report *Report) GetUnmatchedSelectors() []SelectorResult {
+	unmatched := make([]SelectorResult, 0)
+	for _, result := range report.Selectors {
+		if report.Pages[result.Kind] > 0 && result.Matched == 0 {
+			unmatched = append(unmatched, result)
+		}
+	}
+
+	return unmatched
+}
+
+// Compare describes how the report has changed since the previous one: the selectors that stopped or started matching,
+// the selectors whose value changed, and the number of Work pages of each layout and of invalid pages
+func (report *Report) Compare(previous *Report) []string {
+	changes := make([]string, 0)
+
+	previousResults := make(map[string]SelectorResult, len(previous.Selectors))
+	for _, result := range previous.Selectors {
+		previousResults[result.Package+"."+result.Name] = result
+	}
+
+	for _, result := range report.Selectors {
+		name := result.Package + "." + result.Name
+		previousResult, found := previousResults[name]
+		if !found {
+			changes = append(changes, fmt.Sprintf("%s is a new selector", name))
+			continue
+		}
+
+		if previousResult.Selector != result.Selector {
+			changes = append(changes, fmt.Sprintf("%s changed from %q to %q", name, previousResult.Selector, result.Selector))
+		}
+
+		wasMatched := previousResult.Matched > 0
+		isMatched := result.Matched > 0
+		if previous.Pages[result.Kind] > 0 && report.Pages[result.Kind] > 0 && wasMatched != isMatched {
+			if isMatched {
+				changes = append(changes, fmt.Sprintf("%s matches again", name))
+			} else {
+				changes = append(changes, fmt.Sprintf("%s stopped matching", name))
+			}
+		}
+	}
+
+	for _, mediaType := range sortedKeys(report.Layouts, previous.Layouts) {
+		for _, layout := range Layouts {
+			previousCount, count := previous.Layouts[mediaType][layout], report.Layouts[mediaType][layout]
+			if previousCount != count {
+				changes = append(changes, fmt.Sprintf("%s pages with a %s layout went from %d to %d", mediaType, layout, previousCount, count))
+			}
+		}
+	}
+
+	if len(previous.InvalidPages) != len(report.InvalidPages) {
+		changes = append(changes, fmt.Sprintf("invalid work pages went from %d to %d", len(previous.InvalidPages), len(report.InvalidPages)))
+	}
+
+	return changes
+}
+
+// WriteText writes the report on the writer in a human-readable way, along with the changes since the previous report if it isn't nil
+// It returns an ErrWriteReport error if it couldn't be written
+func (report *Report) WriteText(writer io.Writer, previous *Report) error {
+	var lines []string
+
+	lines = append(lines, fmt.Sprintf("TvTropes structure report of %s", report.Created.Format(time.RFC3339)), "", "Checked pages:")
+	for _, kind := range PageKinds {
+		lines = append(lines, fmt.Sprintf("  %s: %d", kind, report.Pages[kind]))
+	}
+
+	lines = append(lines, "", "Selectors that matched nothing:")
+	unmatched := report.GetUnmatchedSelectors()
+	for _, result := range unmatched {
+		lines = append(lines, fmt.Sprintf("  %s.%s %q on %d %s pages", result.Package, result.Name, result.Selector, report.Pages[result.Kind], result.Kind))
+	}
+	if len(unmatched) == 0 {
+		lines = append(lines, "  none")
+	}
+
+	lines = append(lines, "", "Work page layouts:")
+	for _, mediaType := range sortedKeys(report.Layouts, nil) {
+		line := "  " + mediaType + ":"
+		for _, layout := range Layouts {
+			line += fmt.Sprintf(" %s %d", layout, report.Layouts[mediaType][layout])
+		}
+		lines = append(lines, line)
+	}
+
+	lines = append(lines, "", "Invalid work pages:")
+	lines = append(lines, describePages(report.InvalidPages)...)
+	lines = append(lines, "", "Failed pages:")
+	lines = append(lines, describePages(report.FailedPages)...)
+
+	if previous != nil {
+		lines = append(lines, "", fmt.Sprintf("Changes since %s:", previous.Created.Format(time.RFC3339)))
+		changes := report.Compare(previous)
+		for _, change := range changes {
+			lines = append(lines, "  "+change)
+		}
+		if len(changes) == 0 {
+			lines = append(lines, "  none")
+		}
+	}
+
+	for _, line := range lines {
+		if _, errWrite := io.WriteString(writer, line+"\n"); errWrite != nil {
+			return fmt.Errorf("%w\n%w", ErrWriteReport, errWrite)
+		}
+	}
+
+	return nil
+}
+
+// SaveReport writes the report on the file at path, so it can be compared by later runs
+// It returns an ErrSaveReport error if the file couldn't be written
+func SaveReport(path string, report *Report) error {
+	reportBytes, errMarshal := json.MarshalIndent(report, "", "  ")
+	if errMarshal != nil {
+		return fmt.Errorf("%w: "+path+"\n%w", ErrSaveReport, errMarshal)
+	}
+
+	if errWrite := os.WriteFile(path, reportBytes, 0644); errWrite != nil {
+		return fmt.Errorf("%w: "+path+"\n%w", ErrSaveReport, errWrite)
+	}
+
+	return nil
+}
+
+// LoadReport reads the report saved on the file at path
+// It returns an ErrLoadReport error if the file doesn't exist or doesn't hold a valid report
+func LoadReport(path string) (*Report, error) {
+	fileContents, errRead := os.ReadFile(path)
+	if errRead != nil {
+		return nil, fmt.Errorf("%w: "+path+"\n%w", ErrLoadReport, errRead)
+	}
+
+	var report Report
+	if errUnmarshal := json.Unmarshal(fileContents, &report); errUnmarshal != nil {
+		return nil, fmt.Errorf("%w: "+path+"\n%w", ErrLoadReport, errUnmarshal)
+	}
+
+	return &report, nil
+}
+
+// describePages lists the URLs of the pages with their reason, sorted by URL, or "none" if there isn't any
+func describePages(pages map[string]string) []string {
+	if len(pages) == 0 {
+		return []string{"  none"}
+	}
+
+	urls := make([]string, 0, len(pages))
+	for pageUrl := range pages {
+		urls = append(urls, pageUrl)
+	}
+	sort.Strings(urls)
+
+	lines := make([]string, 0, len(urls))
+	for _, pageUrl := range urls {
+		lines = append(lines, "  "+pageUrl+": "+pages[pageUrl])
+	}
+
+	return lines
+}
+
+// sortedKeys returns the media types of both layouts sorted and without duplicates
+func sortedKeys(layouts, otherLayouts map[string]map[Layout]int) []string {
+	keySet := make(map[string]bool)
+	for mediaType := range layouts {
+		keySet[mediaType] = true
+	}
+	for mediaType := range otherLayouts {
+		keySet[mediaType] = true
+	}
+
+	keys := make([]string, 0, len(keySet))
+	for mediaType := range keySet {
+		keys = append(keys, mediaType)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
@@ -0,0 +1,67 @@
+package checker
+
+import (
+	"github.com/jlgallego99/TropesToGo/selectors"
+)
+
+// PageKind enumerates the kinds of TvTropes pages whose structure is checked, because every selector is meant for one of them
+type PageKind string
+
+const (
+	WorkPage       PageKind = "work"
+	IndexPage      PageKind = "index"
+	HistoryPage    PageKind = "history"
+	TropePage      PageKind = "trope"
+	TropeIndexPage PageKind = "trope_index"
+)
+
+// PageKinds are all the kinds of pages in the order they are reported
+var PageKinds = []PageKind{WorkPage, IndexPage, HistoryPage, TropePage, TropeIndexPage}
+
+// selectorKinds relates every CSS selector of a selector profile, given by its section and key, with the kind of page it's evaluated on
+// Selectors that aren't on it are evaluated on Work pages
+var selectorKinds = map[string]PageKind{
+	"scraper.trope_paragraph":    TropePage,
+	"scraper.trope_index_links":  TropePage,
+	"scraper.trope_example_list": TropePage,
+	"scraper.trope_example_item": TropePage,
+	"crawler.work_page":          IndexPage,
+	"crawler.pagination_nav":     IndexPage,
+	"crawler.last_updated":       HistoryPage,
+	"crawler.trope_example_link": TropePage,
+	"crawler.index_trope_link":   TropeIndexPage,
+}
+
+// Selector is a CSS selector of the scraper or the crawler with the kind of page it's evaluated on
+// Package is the section of the selector profile where it is, and Name its key on that section
+// Selectors starting with a sibling combinator are relative to a header, so they are evaluated after every header
+type Selector struct {
+	Package  string
+	Name     string
+	Selector string
+	Kind     PageKind
+}
+
+// NewSelectors returns all the CSS selectors of the scraper and the crawler services on the profile, leaving out its patterns
+func NewSelectors(profile selectors.Profile) []Selector {
+	profileSelectors := make([]Selector, 0)
+	for _, field := range profile.Fields() {
+		if field.Kind != selectors.CSSValue {
+			continue
+		}
+
+		kind, found := selectorKinds[field.Section+"."+field.Key]
+		if !found {
+			kind = WorkPage
+		}
+
+		profileSelectors = append(profileSelectors, Selector{
+			Package:  field.Section,
+			Name:     field.Key,
+			Selector: field.Value,
+			Kind:     kind,
+		})
+	}
+
+	return profileSelectors
+}
@@ -1,16 +1,21 @@
 package crawler
 
 import (
+	"bytes"
+	"context"
 	"errors"
 	"fmt"
 	"io"
-	"net/http"
 	"regexp"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/PuerkitoBio/goquery"
 	"github.com/jlgallego99/TropesToGo/media"
+	"github.com/jlgallego99/TropesToGo/selectors"
+	"github.com/jlgallego99/TropesToGo/service/fetcher"
+	"github.com/jlgallego99/TropesToGo/service/outcome"
 	"github.com/jlgallego99/TropesToGo/tvtropespages"
 	"github.com/rs/zerolog/log"
 )
@@ -19,39 +24,51 @@ const (
 	// The seed is the starting URL of the crawler
 	seed = "https://tvtropes.org/pmwiki/pagelist_having_pagetype_in_namespace.php?t=work&n="
 
-	// Common headers for a Firefox browser
-	userAgentHeader               = "Mozilla/5.0 (Windows NT 10.0; Win64; x64; rv:53.0) Gecko/20100101 Firefox/53.0"
-	acceptHeader                  = "text/html,application/xhtml+xml,application/xml;q=0.9,image/avif,image/webp,*/*;q=0.8"
-	acceptLanguageHeader          = "es"
-	upgradeInsecureRequestsHeader = "1"
-
 	// TvTropes date formats
 	tvTropesHistoryDateFormat = "Jan 2 2006 at 3:04:05 PM"
 
-	// Referer header for a well-known and trusty webpage
-	refererHeader = "https://www.google.com/"
-
-	TvTropesHostname        = "tvtropes.org"
-	TvTropesWeb             = "https://" + TvTropesHostname
-	TvTropesPmwiki          = TvTropesWeb + "/pmwiki/"
-	WorkPageSelector        = "table a"
-	CurrentSubpageSelector  = ".curr-subpage"
-	SubWikiSelector         = "a.subpage-link:not(" + CurrentSubpageSelector + ")"
-	SubPageSelector         = "ul a.twikilink"
-	PaginationNavSelector   = "nav.pagination-box > a"
-	WorkHistoryPageSelector = "li.link-history a"
-	LastUpdatedSelector     = "#main-article > div:first-of-type .pull-right a"
+	TvTropesHostname = "tvtropes.org"
+	TvTropesWeb      = "https://" + TvTropesHostname
+	TvTropesPmwiki   = TvTropesWeb + "/pmwiki/"
+
+	// laconicNamespace is the namespace of the subpages with the one sentence description of a trope
+	laconicNamespace = "Laconic"
 )
 
+// Selectors of the crawler on the default selector profile, kept for the code that used them before the selectors could be patched
+// The crawler uses the selectors of its profile, so changing these values doesn't change what it crawls
+//
+// Deprecated: use the Crawler selectors of selectors.DefaultProfile, or of the profile the crawler is configured with
 var (
-	ErrNotFound    = errors.New("couldn't request the URL")
-	ErrCrawling    = errors.New("there was an error crawling TvTropes")
-	ErrEndIndex    = errors.New("there's no next page on the index")
-	ErrParse       = errors.New("couldn't parse the HTML contents of the page")
-	ErrLastUpdated = errors.New("couldn't retrieve o the last updated time")
-	ErrParseTime   = errors.New("couldn't parse the TvTropes last updated time")
+	WorkPageSelector        = selectors.DefaultProfile().Crawler.WorkPage
+	CurrentSubpageSelector  = selectors.DefaultProfile().Crawler.CurrentSubpage
+	SubWikiSelector         = selectors.DefaultProfile().Crawler.SubWiki
+	SubPageSelector         = selectors.DefaultProfile().Crawler.SubPage
+	PaginationNavSelector   = selectors.DefaultProfile().Crawler.PaginationNav
+	WorkHistoryPageSelector = selectors.DefaultProfile().Crawler.WorkHistoryPage
+	LastUpdatedSelector     = selectors.DefaultProfile().Crawler.LastUpdated
+)
 
-	httpClient = &http.Client{}
+var (
+	ErrNotFound     = errors.New("couldn't request the URL")
+	ErrCrawling     = errors.New("there was an error crawling TvTropes")
+	ErrEndIndex     = errors.New("there's no next page on the index")
+	ErrParse        = errors.New("couldn't parse the HTML contents of the page")
+	ErrLastUpdated  = errors.New("couldn't retrieve o the last updated time")
+	ErrParseTime    = errors.New("couldn't parse the TvTropes last updated time")
+	ErrInvalidField = errors.New("one or more fields for the Crawler are invalid")
+	ErrInvalidWork  = errors.New("the URL doesn't belong to a TvTropes Work page")
+	ErrInvalidTrope = errors.New("the trope ID doesn't belong to a TvTropes trope page")
+
+	// ErrorClasses are the classes of the errors of the crawler on the outcome Report of a run
+	ErrorClasses = []outcome.ErrorClass{
+		{Name: "ErrInvalidWork", Err: ErrInvalidWork},
+		{Name: "ErrInvalidTrope", Err: ErrInvalidTrope},
+		{Name: "ErrLastUpdated", Err: ErrLastUpdated},
+		{Name: "ErrParseTime", Err: ErrParseTime},
+		{Name: "ErrParse", Err: ErrParse},
+		{Name: "ErrNotFound", Err: ErrNotFound},
+	}
 
 	// date ordinals for removing them on a date string
 	dateOrdinals = []string{"st", "nd", "rd", "th"}
@@ -60,19 +77,251 @@ var (
 	mediaSeed = seed
 )
 
-type ServiceCrawler struct{}
+const (
+	// defaultWorkers is the number of Work pages that are crawled at the same time if no other number is configured
+	defaultWorkers = 4
+
+	// forbiddenPause is how long all workers stop requesting TvTropes after a page has been denied on every attempt
+	forbiddenPause = time.Minute
+)
+
+// CrawlerConfig is an alias for a function that will accept a pointer to a ServiceCrawler and modify its fields
+// Each function acts as one configuration for the crawler
+type CrawlerConfig func(sc *ServiceCrawler) error
+
+// ServiceCrawler manages the TropesToGo crawler for searching TvTropes Work pages and its subpages
+// Independent Work pages are crawled in parallel by a bounded number of workers,
+// while a shared scheduler spaces out the requests to TvTropes so the crawling stays polite
+type ServiceCrawler struct {
+	// workers is the maximum number of Work pages crawled at the same time
+	workers int
+
+	// scheduler decides when each request to TvTropes can be made
+	scheduler *scheduler
+
+	// fetcher retrieves the contents of every crawled page
+	fetcher fetcher.Fetcher
+
+	// frontier checkpoints the crawl so it can be resumed, or nil if it can't
+	frontier *Frontier
+
+	// handler is called with every crawled Work page, or nil if they are only returned at the end of the crawl
+	handler WorkHandler
+
+	// batchHandler is called after every batchSize handled Work pages and at the end of every crawl, or nil if they are done once handled
+	batchHandler BatchHandler
+	batchSize    int
+
+	// failedPages relates the URL of every Work page that couldn't be crawled with the error that made it fail
+	failedPages      map[string]error
+	failedPagesMutex sync.Mutex
+
+	// profile holds the CSS selectors and patterns for finding the pages to crawl
+	profile selectors.Profile
+
+	// report records the outcome of crawling every page, or nil if they aren't recorded
+	report *outcome.Report
+}
+
+// WorkHandler is a function that processes a single crawled Work page with its subpages as soon as it's crawled,
+// for example scraping and persisting it, so it doesn't need to wait for the whole crawl to finish
+// It's called by the worker that crawled the Work page, so it must be safe for concurrent use and several Work pages are handled in parallel
+// If it returns an error, the Work page is considered failed
+type WorkHandler func(workPages *tvtropespages.TvTropesPages) error
+
+// BatchHandler is a function that completes the handling of a batch of Work pages that have been handled by the WorkHandler,
+// for example persisting all of them at once instead of one after the other
+// If it returns an error, all the Work pages of the batch are considered failed
+type BatchHandler func() error
+
+// MediaLimit is a media type whose Work pages are crawled along with the maximum number of them
+// A Limit of 0 or less crawls all Work pages of the media type
+type MediaLimit struct {
+	MediaType media.MediaType
+	Limit     int
+}
+
+// pageCrawler is the task of a single worker, which crawls a page with everything it needs and returns the outcome
+type pageCrawler func(ctx context.Context, pageUrl string, lastUpdated *time.Time) workResult
+
+// workResult is the outcome of crawling a single Work page by a worker
+type workResult struct {
+	workUrl string
+
+	// pages holds the crawled Work page with its subpages, or nil if it didn't need to be crawled
+	pages *tvtropespages.TvTropesPages
+
+	// attempts is the number of requests made for the Work page itself, 0 if it was served from a cache
+	attempts int
+
+	err error
+
+	// errHandle is the error of the WorkHandler of the crawler with the crawled pages, if it failed
+	errHandle error
+}
+
+// NewCrawler takes a variable amount of configuration functions, applies them and returns a ServiceCrawler with all configs passed
+// By default, pages are retrieved with an HTTP fetcher with its default timeouts and User-Agent, whose failed requests are retried
+// with the default retry policy, and that obeys the robots.txt file of TvTropes
+func NewCrawler(cfgs ...CrawlerConfig) (*ServiceCrawler, error) {
+	httpFetcher, errFetcher := fetcher.NewHTTPFetcher()
+	if errFetcher != nil {
+		return nil, errFetcher
+	}
 
-func NewCrawler() *ServiceCrawler {
-	crawler := &ServiceCrawler{}
+	retryFetcher, errRetryFetcher := fetcher.NewRetryFetcher(httpFetcher)
+	if errRetryFetcher != nil {
+		return nil, errRetryFetcher
+	}
+
+	robotsFetcher, errRobotsFetcher := fetcher.NewRobotsFetcher(retryFetcher, httpFetcher.GetUserAgent())
+	if errRobotsFetcher != nil {
+		return nil, errRobotsFetcher
+	}
+
+	crawler := &ServiceCrawler{
+		workers:     defaultWorkers,
+		scheduler:   newScheduler(defaultMinWaitingTime, defaultMaxWaitingTime),
+		fetcher:     robotsFetcher,
+		failedPages: make(map[string]error),
+		profile:     selectors.DefaultProfile(),
+	}
 
-	return crawler
+	for _, cfg := range cfgs {
+		err := cfg(crawler)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return crawler, nil
+}
+
+// ConfigSelectors defines the selector Profile with which the crawler finds the pages to crawl instead of the default one
+// It returns an ErrInvalidField error if the profile isn't valid
+func ConfigSelectors(profile selectors.Profile) CrawlerConfig {
+	return func(sc *ServiceCrawler) error {
+		if errValidate := profile.Validate(); errValidate != nil {
+			return fmt.Errorf("%w\n%w", ErrInvalidField, errValidate)
+		}
+
+		sc.profile = profile
+		return nil
+	}
+}
+
+// ConfigWorkers defines the number of Work pages that the crawler will request at the same time
+// It returns an ErrInvalidField error if the number of workers is less than one
+func ConfigWorkers(workers int) CrawlerConfig {
+	return func(sc *ServiceCrawler) error {
+		if workers < 1 {
+			return ErrInvalidField
+		}
+
+		sc.workers = workers
+		return nil
+	}
+}
+
+// ConfigFetcher defines the Fetcher that will retrieve all the crawled pages
+// It accepts any implementation of a Fetcher, so pages can be requested with a custom HTTP client, a local test server or a replay source
+// The fetcher is used as is, so it must be wrapped on a RetryFetcher for its failed requests to be retried
+// and on a RobotsFetcher for obeying the robots.txt files
+// It returns an ErrInvalidField error if the fetcher is nil
+func ConfigFetcher(pageFetcher fetcher.Fetcher) CrawlerConfig {
+	return func(sc *ServiceCrawler) error {
+		if pageFetcher == nil {
+			return ErrInvalidField
+		}
+
+		sc.fetcher = pageFetcher
+		return nil
+	}
+}
+
+// ConfigFrontier defines the Frontier where the crawl is checkpointed, so it can be resumed if it stops
+// A loaded Frontier makes the crawler continue from where it was saved
+// It returns an ErrInvalidField error if the frontier is nil
+func ConfigFrontier(frontier *Frontier) CrawlerConfig {
+	return func(sc *ServiceCrawler) error {
+		if frontier == nil {
+			return ErrInvalidField
+		}
+
+		sc.frontier = frontier
+		return nil
+	}
+}
+
+// ConfigWorkHandler defines the WorkHandler that receives every Work page as soon as it's crawled
+// Only Work pages that are handled without errors are considered done on the crawler Frontier
+// The pages returned by the crawls only hold the URL and the last updated time of the handled Work pages, without their documents nor subpages,
+// so the memory of a crawl doesn't grow with the handled pages
+// It returns an ErrInvalidField error if the handler is nil
+func ConfigWorkHandler(handler WorkHandler) CrawlerConfig {
+	return func(sc *ServiceCrawler) error {
+		if handler == nil {
+			return ErrInvalidField
+		}
+
+		sc.handler = handler
+		return nil
+	}
+}
+
+// ConfigBatchHandler defines the BatchHandler that is called after every batchSize Work pages handled by the WorkHandler,
+// and once more when every crawl ends or is interrupted with the Work pages handled since the last time it was called
+// Handled Work pages are only considered done on the crawler Frontier once the BatchHandler has been called after them without errors
+// It returns an ErrInvalidField error if the handler is nil or the batch size is less than one
+func ConfigBatchHandler(batchHandler BatchHandler, batchSize int) CrawlerConfig {
+	return func(sc *ServiceCrawler) error {
+		if batchHandler == nil || batchSize < 1 {
+			return ErrInvalidField
+		}
+
+		sc.batchHandler = batchHandler
+		sc.batchSize = batchSize
+		return nil
+	}
+}
+
+// ConfigOutcomeReport defines the outcome Report where the crawler records whether every page could be crawled,
+// with the number of requests it needed
+// It returns an ErrInvalidField error if the report is nil
+func ConfigOutcomeReport(report *outcome.Report) CrawlerConfig {
+	return func(sc *ServiceCrawler) error {
+		if report == nil {
+			return ErrInvalidField
+		}
+
+		sc.report = report
+		return nil
+	}
+}
+
+// ConfigWaitingTime defines the bounds of the random waiting time between two requests to TvTropes, shared by all workers
+// It returns an ErrInvalidField error if the bounds are negative or the minimum is greater than the maximum
+func ConfigWaitingTime(minWaitingTime, maxWaitingTime time.Duration) CrawlerConfig {
+	return func(sc *ServiceCrawler) error {
+		if minWaitingTime < 0 || maxWaitingTime < minWaitingTime {
+			return ErrInvalidField
+		}
+
+		sc.scheduler = newScheduler(minWaitingTime, maxWaitingTime)
+		return nil
+	}
 }
 
 // CrawlWorkPages searches crawlLimit number of Work pages belonging to a mediaType from the defined seed starting page
 // if the crawlLimit is 0 or less, then it crawls all Work pages on the selected MediaType
 // It returns a TvTropesPages object with all crawled pages and subpages from TvTropes
-func (crawler *ServiceCrawler) CrawlWorkPages(crawlLimit int, mediaType media.MediaType) (*tvtropespages.TvTropesPages, error) {
-	crawledPages := tvtropespages.NewTvTropesPages()
+// Work pages that can't be crawled are skipped and reported on GetFailedPages, while a failing index page stops the crawling
+// If the crawler has a Frontier, the crawl is checkpointed on it and continues from the index page where it stopped,
+// counting the done Work pages of the mediaType for the crawlLimit and without requesting them again
+// A mediaType whose whole index was crawled on the Frontier isn't crawled again
+// If the ctx context is cancelled, it stops crawling and returns the fully crawled pages until then along with the context error
+func (crawler *ServiceCrawler) CrawlWorkPages(ctx context.Context, crawlLimit int, mediaType media.MediaType) (*tvtropespages.TvTropesPages, error) {
+	crawled := newCrawledWorks()
 
 	mediaSeed = seed + mediaType.String()
 	indexPage := mediaSeed
@@ -82,86 +331,228 @@ func (crawler *ServiceCrawler) CrawlWorkPages(crawlLimit int, mediaType media.Me
 		limitedCrawling = false
 	}
 
-	for {
-		request, errValidRequest := crawler.makeValidRequest(indexPage)
-		if errValidRequest != nil {
-			return nil, errValidRequest
-		}
+	var workUrls []string
+	var nextIndexPage string
+	resumed := false
+	if crawler.frontier != nil {
+		defer crawler.saveFrontier()
 
-		resp, errDoRequest := httpClient.Do(request)
-		if errDoRequest != nil {
-			log.Error().Err(errDoRequest).Msg("CRAWLING FAILED " + indexPage)
-			return nil, fmt.Errorf("%w: "+indexPage, ErrNotFound)
+		if crawler.frontier.isMediaFinished(mediaType) {
+			return crawled.pages, nil
 		}
 
-		doc, errDocument := goquery.NewDocumentFromReader(resp.Body)
-		if errDocument != nil {
-			return nil, fmt.Errorf("%w: "+indexPage, ErrParse)
+		if limitedCrawling {
+			crawlLimit -= crawler.frontier.countMediaWorks(mediaType, WorkDone)
+			if crawlLimit <= 0 {
+				return crawled.pages, nil
+			}
 		}
 
-		pageSelector := doc.Find(WorkPageSelector)
-		if pageSelector.Length() == 0 {
-			return nil, fmt.Errorf("%w: "+indexPage, ErrCrawling)
+		// Continue from the checkpointed index page of the same media type, with only its pending Work pages
+		frontierMedia, frontierIndex, frontierNextIndex, pending := crawler.frontier.getIndex()
+		if frontierIndex != "" && frontierMedia == mediaType.String() {
+			indexPage, nextIndexPage, workUrls = frontierIndex, frontierNextIndex, pending
+			resumed = true
 		}
+	}
 
-		var errAddPage error
-		var workPage tvtropespages.Page
-		pageSelector.EachWithBreak(func(i int, selection *goquery.Selection) bool {
-			if limitedCrawling && len(crawledPages.Pages) == crawlLimit {
-				return false
+	for {
+		if !resumed {
+			var errIndex error
+			workUrls, nextIndexPage, errIndex = crawler.crawlIndexPage(ctx, indexPage)
+			if errIndex != nil {
+				if ctx.Err() != nil {
+					return crawled.pages, ctx.Err()
+				}
+
+				return nil, errIndex
 			}
 
-			workUrl, urlExists := selection.Attr("href")
-			if !urlExists {
-				return false
+			if crawler.frontier != nil {
+				if errStart := crawler.frontier.startIndex(mediaType, indexPage, nextIndexPage, workUrls); errStart != nil {
+					log.Error().Err(errStart).Msg("CHECKPOINTING FAILED " + indexPage)
+				}
 			}
+		}
+		resumed = false
 
-			log.Info().Msg("CRAWLING: " + workUrl)
+		// Crawl all Work pages of the index in parallel
+		crawler.crawlWorks(ctx, workUrls, nil, crawled, crawlLimit)
 
-			// Create the Work Page with its subpages
-			workPage, errAddPage = crawler.createWorkPage(workUrl, crawledPages)
-			if errAddPage != nil {
-				log.Error().Err(errAddPage).Msg("CRAWLING WORK PAGE FAILED " + workUrl)
-				return false
-			}
+		if limitedCrawling && len(crawled.urls) == crawlLimit {
+			break
+		}
 
-			// Set LastUpdated time
-			lastUpdated, errLastUpdated := crawler.getLastUpdated(workPage.GetDocument())
-			if errLastUpdated != nil {
-				errAddPage = errLastUpdated
-				log.Error().Err(errAddPage).Msg("CRAWLING LAST UPDATE DATE FAILED " + workUrl)
-				return false
+		// The crawl has been interrupted, so only the fully crawled pages are returned
+		if ctx.Err() != nil {
+			return crawled.pages, ctx.Err()
+		}
+
+		if nextIndexPage == "" {
+			if crawler.frontier != nil {
+				if errFinish := crawler.frontier.finishMedia(mediaType); errFinish != nil {
+					log.Error().Err(errFinish).Msg("CHECKPOINTING FAILED " + indexPage)
+				}
 			}
-			crawledPages.Pages[workPage].LastUpdated = lastUpdated
 
-			// Crawl Work subpages and add them
-			errAddPage = crawler.addWorkSubpages(workPage, crawledPages)
-			if errAddPage != nil {
-				log.Error().Err(errAddPage).Msg("CRAWLING WORK SUBPAGES FAILED " + workUrl)
-				return false
+			break
+		}
+		indexPage = nextIndexPage
+	}
+
+	return crawled.pages, nil
+}
+
+// CrawlMediaWorkPages crawls the Work pages of every media type on mediaLimits one after the other, each one up to its own limit
+// It returns a TvTropesPages object with the crawled pages and subpages of all media types
+// A media type whose index fails doesn't stop the crawling of the rest, and its error is returned joined with the others at the end
+// If the crawler has a Frontier, the media types are recorded on it so a resumed crawl goes over the same ones
+// If the ctx context is cancelled, it stops crawling and returns the fully crawled pages until then along with the context error
+func (crawler *ServiceCrawler) CrawlMediaWorkPages(ctx context.Context, mediaLimits []MediaLimit) (*tvtropespages.TvTropesPages, error) {
+	crawledPages := tvtropespages.NewTvTropesPages()
+
+	if crawler.frontier != nil {
+		if errSet := crawler.frontier.setMediaLimits(mediaLimits); errSet != nil {
+			log.Error().Err(errSet).Msg("CHECKPOINTING FAILED")
+		}
+	}
+
+	var errs []error
+	for pos, mediaLimit := range mediaLimits {
+		log.Info().Msgf("CRAWLING MEDIA %s (%d/%d)", mediaLimit.MediaType, pos+1, len(mediaLimits))
+
+		mediaPages, errCrawl := crawler.CrawlWorkPages(ctx, mediaLimit.Limit, mediaLimit.MediaType)
+		if mediaPages != nil {
+			for page, subpages := range mediaPages.Pages {
+				crawledPages.Pages[page] = subpages
 			}
 
-			return true
-		})
+			log.Info().Msgf("CRAWLED %d WORKS OF %s", len(mediaPages.Pages), mediaLimit.MediaType)
+		}
 
-		if limitedCrawling && len(crawledPages.Pages) == crawlLimit {
-			break
+		if ctx.Err() != nil {
+			return crawledPages, ctx.Err()
 		}
 
-		if errAddPage != nil {
-			return nil, fmt.Errorf("error crawling: %w", errAddPage)
+		if errCrawl != nil {
+			log.Error().Err(errCrawl).Msg("CRAWLING FAILED FOR MEDIA " + mediaLimit.MediaType.String())
+			errs = append(errs, errCrawl)
 		}
+	}
 
-		// Get next index page for crawling
-		indexPage, errAddPage = crawler.getNextPageUriFromDocument(doc)
-		indexPage = TvTropesPmwiki + indexPage
-		if errAddPage != nil {
-			log.Error().Err(errAddPage).Msg("CRAWLING NEXT INDEX PAGE FAILED " + indexPage)
-			break
+	return crawledPages, errors.Join(errs...)
+}
+
+// CrawlWorkURLs crawls exactly the Work pages of the workUrls list, with their subpages and last updated time
+// Every URL is validated before requesting it, and the ones that don't belong to a TvTropes Work page of a known media type
+// are reported on GetFailedPages with an ErrInvalidWork error, without stopping the crawling of the rest
+// If the crawler has a Frontier, the Work pages already done are skipped, so a stopped crawl of the same list can be resumed
+// If the ctx context is cancelled, it stops crawling and returns the fully crawled pages until then along with the context error
+func (crawler *ServiceCrawler) CrawlWorkURLs(ctx context.Context, workUrls []string) (*tvtropespages.TvTropesPages, error) {
+	crawled := newCrawledWorks()
+
+	validUrls := make([]string, 0, len(workUrls))
+	listedUrls := make(map[string]bool, len(workUrls))
+	for _, workUrl := range workUrls {
+		workUrl = strings.TrimSpace(workUrl)
+		if listedUrls[workUrl] {
+			continue
 		}
+		listedUrls[workUrl] = true
+
+		if errValid := validateWorkUrl(workUrl); errValid != nil {
+			log.Error().Err(errValid).Msg("INVALID WORK URL " + workUrl)
+			crawler.addFailedPage(workUrl, errValid)
+			crawler.recordFailure(workUrl, errValid, 0)
+			continue
+		}
+
+		validUrls = append(validUrls, workUrl)
 	}
 
-	return crawledPages, nil
+	if crawler.frontier != nil {
+		defer crawler.saveFrontier()
+	}
+
+	crawler.crawlWorks(ctx, validUrls, nil, crawled, 0)
+	if ctx.Err() != nil {
+		return crawled.pages, ctx.Err()
+	}
+
+	return crawled.pages, nil
+}
+
+// validateWorkUrl checks that the workUrl belongs to a TvTropes Work page whose namespace is a known media type
+// It returns an ErrInvalidWork error wrapping the reason why it isn't valid
+func validateWorkUrl(workUrl string) error {
+	workPage, errPage := tvtropespages.NewPage(context.Background(), workUrl, false, nil)
+	if errPage != nil {
+		return fmt.Errorf("%w: "+workUrl+"\n%w", ErrInvalidWork, errPage)
+	}
+
+	if workPage.GetPageType() != tvtropespages.WorkPage {
+		return fmt.Errorf("%w: "+workUrl, ErrInvalidWork)
+	}
+
+	splitPath := strings.Split(workPage.GetUrl().Path, "/")
+	if len(splitPath) < 5 || splitPath[4] == "" {
+		return fmt.Errorf("%w: "+workUrl, ErrInvalidWork)
+	}
+
+	if _, errMediaType := media.ToMediaType(splitPath[3]); errMediaType != nil {
+		return fmt.Errorf("%w: "+workUrl+"\n%w", ErrInvalidWork, errMediaType)
+	}
+
+	return nil
+}
+
+// crawlIndexPage requests an index page and returns the URLs of all Work pages on it and the URL of the next index page,
+// which is empty if it's the last one
+// It returns an ErrNotFound, ErrParse or ErrCrawling error if the index page couldn't be requested, parsed or has no Work pages
+func (crawler *ServiceCrawler) crawlIndexPage(ctx context.Context, indexPage string) ([]string, string, error) {
+	if errWait := crawler.wait(ctx, indexPage); errWait != nil {
+		return nil, "", errWait
+	}
+
+	resp, errFetch := crawler.fetcher.Fetch(ctx, indexPage)
+	if errFetch != nil {
+		if ctx.Err() != nil {
+			return nil, "", ctx.Err()
+		}
+
+		log.Error().Err(errFetch).Msg("CRAWLING FAILED " + indexPage)
+		return nil, "", fmt.Errorf("%w: "+indexPage, ErrNotFound)
+	}
+
+	doc, errDocument := goquery.NewDocumentFromReader(bytes.NewReader(resp.Body))
+	if errDocument != nil {
+		return nil, "", fmt.Errorf("%w: "+indexPage, ErrParse)
+	}
+
+	pageSelector := doc.Find(crawler.profile.Crawler.WorkPage)
+	if pageSelector.Length() == 0 {
+		return nil, "", fmt.Errorf("%w: "+indexPage, ErrCrawling)
+	}
+
+	var workUrls []string
+	pageSelector.EachWithBreak(func(i int, selection *goquery.Selection) bool {
+		workUrl, urlExists := selection.Attr("href")
+		if !urlExists {
+			return false
+		}
+
+		workUrls = append(workUrls, workUrl)
+		return true
+	})
+
+	// Get next index page for crawling
+	nextIndexPage, errNextIndex := crawler.getNextPageUriFromDocument(doc)
+	if errNextIndex != nil {
+		log.Error().Err(errNextIndex).Msg("CRAWLING NEXT INDEX PAGE FAILED " + TvTropesPmwiki)
+		return workUrls, "", nil
+	}
+
+	return workUrls, TvTropesPmwiki + nextIndexPage, nil
 }
 
 // getNextPageUriFromDocument, internal function that looks for the next pagination URI on the current index
@@ -171,7 +562,7 @@ func (crawler *ServiceCrawler) getNextPageUriFromDocument(doc *goquery.Document)
 	// Search the "Next" button on the nav pagination
 	nextPageUri := ""
 	var nextPageExists bool
-	doc.Find(PaginationNavSelector).EachWithBreak(func(_ int, selection *goquery.Selection) bool {
+	doc.Find(crawler.profile.Crawler.PaginationNav).EachWithBreak(func(_ int, selection *goquery.Selection) bool {
 		nextPageUri, nextPageExists = selection.Attr("href")
 
 		if nextPageExists && strings.EqualFold(selection.Find("a span.mobile-off").Text(), "Next") {
@@ -196,7 +587,7 @@ func (crawler *ServiceCrawler) CrawlWorkSubpages(doc *goquery.Document) []string
 	var subPagesUrls []string
 
 	// Get all SubWikis
-	doc.Find(SubWikiSelector).Each(func(_ int, selection *goquery.Selection) {
+	doc.Find(crawler.profile.Crawler.SubWiki).Each(func(_ int, selection *goquery.Selection) {
 		subWikiUri, subWikiExists := selection.Attr("href")
 
 		splitPath := strings.Split(subWikiUri, "/")
@@ -209,9 +600,9 @@ func (crawler *ServiceCrawler) CrawlWorkSubpages(doc *goquery.Document) []string
 	})
 
 	// Get all main trope subpages (if there are any)
-	doc.Find(SubPageSelector).EachWithBreak(func(_ int, selection *goquery.Selection) bool {
+	doc.Find(crawler.profile.Crawler.SubPage).EachWithBreak(func(_ int, selection *goquery.Selection) bool {
 		subPageUri, subPageExists := selection.Attr("href")
-		r, _ := regexp.Compile(`\/tropes[a-z]to[a-z]`)
+		r, _ := regexp.Compile(`\/` + crawler.profile.Patterns.TropesSubpage)
 		matchUri := r.MatchString(strings.ToLower(subPageUri))
 
 		if subPageExists && matchUri {
@@ -243,7 +634,7 @@ func (crawler *ServiceCrawler) CrawlWorkPagesFromReaders(indexReader io.Reader,
 			return nil, fmt.Errorf("%w: "+mediaSeed, ErrParse)
 		}
 
-		listSelector := doc.Find(WorkPageSelector)
+		listSelector := doc.Find(crawler.profile.Crawler.WorkPage)
 		if listSelector.Length() == 0 {
 			return nil, fmt.Errorf("%w: "+mediaSeed, ErrCrawling)
 		}
@@ -263,8 +654,8 @@ func (crawler *ServiceCrawler) CrawlWorkPagesFromReaders(indexReader io.Reader,
 			pageDoc, _ := goquery.NewDocumentFromReader(workReaders[i])
 			subPagesUrls := crawler.CrawlWorkSubpages(pageDoc)
 
-			_, errAddPage = crawledPages.AddTvTropesPage(workUrl, false, nil)
-			errAddPage = crawledPages.AddSubpages(workUrl, subPagesUrls, false, nil)
+			_, errAddPage = crawledPages.AddTvTropesPage(context.Background(), workUrl, false, nil)
+			errAddPage = crawledPages.AddSubpages(context.Background(), workUrl, subPagesUrls, false, nil)
 
 			return true
 		})
@@ -285,118 +676,483 @@ func (crawler *ServiceCrawler) CrawlWorkPagesFromReaders(indexReader io.Reader,
 // Receives a map of already crawled works, relating a name with its last updated time
 // and only crawls them if there's record of them on the history page, and it's newer
 // Returns a TvTropesPages containing the crawled Pages of the Media that needs to be updated
-func (crawler *ServiceCrawler) CrawlChanges(crawledWorks map[string]time.Time) (*tvtropespages.TvTropesPages, error) {
-	crawledPages := tvtropespages.NewTvTropesPages()
+// Work pages that can't be crawled are skipped and reported on GetFailedPages
+// If the ctx context is cancelled, it returns the changed pages crawled until then along with the context error
+func (crawler *ServiceCrawler) CrawlChanges(ctx context.Context, crawledWorks map[string]time.Time) (*tvtropespages.TvTropesPages, error) {
+	crawled := newCrawledWorks()
+
+	workUrls := make([]string, 0, len(crawledWorks))
+	for crawledUrl := range crawledWorks {
+		workUrls = append(workUrls, crawledUrl)
+	}
+
+	crawler.crawlWorks(ctx, workUrls, crawledWorks, crawled, 0)
+	if ctx.Err() != nil {
+		return crawled.pages, ctx.Err()
+	}
+
+	return crawled.pages, nil
+}
+
+// crawlWorks crawls the workUrls Work pages with their subpages in parallel, using as many goroutines as configured workers
+// and adds them to the crawled Work pages as soon as each of them is completely crawled and handled
+// If lastUpdates isn't nil, a Work page is only added if it has been updated after the time it relates to its URL
+// It stops launching new workers when there are enough Work pages to reach the crawlLimit (if it's greater than 0)
+// or when the ctx context is cancelled, waiting for the running ones to finish
+// A Work page that fails doesn't stop the crawling, it's recorded as a failed page along with its error
+// If the crawler has a Frontier, Work pages already done are skipped and the status of every crawled one is checkpointed
+func (crawler *ServiceCrawler) crawlWorks(ctx context.Context, workUrls []string, lastUpdates map[string]time.Time, crawled *crawledWorks, crawlLimit int) {
+	crawler.crawlPages(ctx, workUrls, lastUpdates, crawled, crawlLimit, crawler.crawlWork)
+}
+
+// crawlPages crawls the workUrls pages in parallel like crawlWorks, with the crawlPage task for each of them
+func (crawler *ServiceCrawler) crawlPages(ctx context.Context, workUrls []string, lastUpdates map[string]time.Time, crawled *crawledWorks, crawlLimit int, crawlPage pageCrawler) {
+	if crawler.frontier != nil {
+		pendingUrls := make([]string, 0, len(workUrls))
+		for _, workUrl := range workUrls {
+			if crawler.frontier.GetWorkStatus(workUrl) != WorkDone {
+				pendingUrls = append(pendingUrls, workUrl)
+			}
+		}
+
+		workUrls = pendingUrls
+	}
+
+	results := make(chan workResult)
+	running, next := 0, 0
+
+	// Handled Work pages that wait for the BatchHandler
+	batch := make([]string, 0)
+
+	for {
+		// Launch new workers while there are free ones and more Work pages are needed
+		for next < len(workUrls) && running < crawler.workers && ctx.Err() == nil &&
+			(crawlLimit <= 0 || len(crawled.urls)+running < crawlLimit) {
+			var lastUpdated *time.Time
+			if lastUpdates != nil {
+				workLastUpdated := lastUpdates[workUrls[next]]
+				lastUpdated = &workLastUpdated
+			}
 
-	for crawledUrl, lastUpdated := range crawledWorks {
-		// Create the Work Page
-		newPage, errNewPage := crawler.createWorkPage(crawledUrl, crawledPages)
-		if errNewPage != nil {
-			return nil, errNewPage
+			go crawler.crawlAndHandle(ctx, workUrls[next], lastUpdated, crawlPage, results)
+			next++
+			running++
 		}
 
-		newLastUpdated, errGetLastUpdated := crawler.getLastUpdated(newPage.GetDocument())
-		if errGetLastUpdated != nil {
-			return nil, errGetLastUpdated
+		if running == 0 {
+			break
 		}
 
-		// Only crawl the page if it has been crawled before, and it's been updated
-		if newLastUpdated.After(lastUpdated) {
-			// Set LastUpdated time
-			crawledPages.Pages[newPage].LastUpdated = newLastUpdated
+		result := <-results
+		running--
 
-			// Crawl Work subpages and add them
-			errCrawlSubpages := crawler.addWorkSubpages(newPage, crawledPages)
-			if errCrawlSubpages != nil {
-				return nil, errCrawlSubpages
+		if result.err != nil {
+			// Work pages interrupted by a cancellation haven't failed, they just weren't finished
+			if ctx.Err() == nil {
+				log.Error().Err(result.err).Msg("CRAWLING WORK PAGE FAILED " + result.workUrl)
+				crawler.addFailedPage(result.workUrl, result.err)
+				crawler.recordFailure(result.workUrl, result.err, result.attempts)
+				crawler.setWorkStatus(result.workUrl, WorkFailed)
+			}
+
+			continue
+		}
+
+		if crawler.report != nil {
+			crawler.report.AddSuccess(result.workUrl, outcome.CrawlStage, result.attempts)
+		}
+
+		if result.pages == nil {
+			continue
+		}
+
+		status := WorkCrawled
+		if crawler.handler != nil {
+			if result.errHandle != nil {
+				log.Error().Err(result.errHandle).Msg("HANDLING WORK PAGE FAILED " + result.workUrl)
+				crawler.addFailedPage(result.workUrl, result.errHandle)
+				crawler.setWorkStatus(result.workUrl, WorkFailed)
+				continue
+			}
+
+			status = WorkDone
+			if crawler.batchHandler != nil {
+				status = WorkCrawled
+				batch = append(batch, result.workUrl)
+			}
+		}
+		crawler.setWorkStatus(result.workUrl, status)
+
+		if len(batch) >= crawler.batchSize && crawler.batchHandler != nil {
+			crawler.handleBatch(batch)
+			batch = batch[:0]
+		}
+
+		for workPage, workSubpages := range result.pages.Pages {
+			if crawlLimit > 0 && len(crawled.urls) == crawlLimit {
+				break
+			}
+
+			if !crawled.add(workPage, workSubpages, crawler.handler == nil) {
+				log.Error().Err(tvtropespages.ErrDuplicatedPage).Msg("CRAWLING WORK PAGE FAILED " + result.workUrl)
 			}
-		} else {
-			// Delete from crawled because it hasn't been updated
-			delete(crawledPages.Pages, newPage)
 		}
 	}
 
-	return crawledPages, nil
+	// The last handled Work pages are completed once all workers have finished, even if the crawl has been interrupted
+	crawler.handleBatch(batch)
 }
 
-// createWorkPage forms a valid Work Page object and adds it to the crawledPages object
-func (crawler *ServiceCrawler) createWorkPage(workUrl string, crawledPages *tvtropespages.TvTropesPages) (tvtropespages.Page, error) {
-	validRequest, errRequest := crawler.makeValidRequest(workUrl)
-	if errRequest != nil {
-		return tvtropespages.Page{}, errRequest
+// CrawlTropePages crawls the Main page of every trope on tropeIds, which are the last part of their URL, along with its Laconic subpage
+// and the subpages with its examples on each media
+// It returns a TvTropesPages object with all crawled trope pages and their subpages
+// Trope IDs that aren't valid or whose pages can't be crawled are skipped and reported on GetFailedPages with the URL of their Main page
+// If the crawler has a Frontier, trope pages already done are skipped and the status of every crawled one is checkpointed
+// If the ctx context is cancelled, it stops crawling and returns the fully crawled pages until then along with the context error
+func (crawler *ServiceCrawler) CrawlTropePages(ctx context.Context, tropeIds []string) (*tvtropespages.TvTropesPages, error) {
+	crawled := newCrawledWorks()
+
+	tropeUrls := make([]string, 0, len(tropeIds))
+	seen := make(map[string]bool, len(tropeIds))
+	for _, tropeId := range tropeIds {
+		tropeId = strings.TrimSpace(tropeId)
+		tropeUrl := TvTropesWeb + tvtropespages.TvTropesMainPath + tropeId
+		if tropeId == "" || seen[tropeUrl] {
+			continue
+		}
+		seen[tropeUrl] = true
+
+		if strings.ContainsAny(tropeId, "/?#") {
+			log.Error().Err(ErrInvalidTrope).Msg("CRAWLING TROPE PAGE FAILED " + tropeId)
+			crawler.addFailedPage(tropeUrl, fmt.Errorf("%w: "+tropeId, ErrInvalidTrope))
+			crawler.recordFailure(tropeUrl, fmt.Errorf("%w: "+tropeId, ErrInvalidTrope), 0)
+			continue
+		}
+
+		tropeUrls = append(tropeUrls, tropeUrl)
 	}
-	workPage, errAddPage := crawledPages.AddTvTropesPage(workUrl, true, validRequest)
-	if errAddPage != nil {
-		return tvtropespages.Page{}, errAddPage
+
+	if crawler.frontier != nil {
+		defer crawler.saveFrontier()
+	}
+
+	crawler.crawlPages(ctx, tropeUrls, nil, crawled, 0, crawler.crawlTrope)
+	if ctx.Err() != nil {
+		return crawled.pages, ctx.Err()
 	}
 
-	return workPage, nil
+	return crawled.pages, nil
 }
 
-// addWorkSubpages crawls all Work subpages, creates them and adds them to the referenced crawledPages argument
-func (crawler *ServiceCrawler) addWorkSubpages(workPage tvtropespages.Page, crawledPages *tvtropespages.TvTropesPages) error {
-	// Search for subpages on the new Work Page
-	subPagesUrls := crawler.CrawlWorkSubpages(workPage.GetDocument())
+// CrawlTropeSubpages searches the subpages of a trope Main page with the tropeId: its Laconic page
+// and the subpages with the examples of the trope on each media, which are linked from its main article
+// It returns the full URLs of the subpages without duplicates
+func (crawler *ServiceCrawler) CrawlTropeSubpages(doc *goquery.Document, tropeId string) []string {
+	var subPagesUrls []string
+	found := make(map[string]bool)
 
-	// Add its subpages to the Work Page
-	var requests []*http.Request
-	for _, subPagesUrl := range subPagesUrls {
-		validRequest, errRequest := crawler.makeValidRequest(subPagesUrl)
-		if errRequest != nil {
-			return errRequest
+	addSubpage := func(subPageUri string, isSubpage func(namespace, name string) bool) {
+		subPageUri = strings.TrimPrefix(subPageUri, TvTropesWeb)
+		if !strings.HasPrefix(subPageUri, tvtropespages.TvTropesPmwiki) {
+			return
 		}
 
-		requests = append(requests, validRequest)
+		splitPath := strings.Split(strings.TrimPrefix(subPageUri, tvtropespages.TvTropesPmwiki), "/")
+		if len(splitPath) != 2 || !isSubpage(splitPath[0], splitPath[1]) || found[subPageUri] {
+			return
+		}
+
+		found[subPageUri] = true
+		subPagesUrls = append(subPagesUrls, TvTropesWeb+subPageUri)
 	}
 
-	errSubpages := crawledPages.AddSubpages(workPage.GetUrl().String(), subPagesUrls, true, requests)
+	doc.Find(crawler.profile.Crawler.SubWiki).Each(func(_ int, selection *goquery.Selection) {
+		subWikiUri, _ := selection.Attr("href")
+		addSubpage(subWikiUri, func(namespace, name string) bool {
+			return namespace == laconicNamespace && strings.EqualFold(name, tropeId)
+		})
+	})
 
-	// If there's been too many requests to TvTropes, wait longer
-	if errors.Is(errSubpages, tvtropespages.ErrForbidden) {
-		time.Sleep(time.Minute)
-		errSubpages = nil
+	doc.Find(crawler.profile.Crawler.TropeExampleLink).Each(func(_ int, selection *goquery.Selection) {
+		exampleUri, _ := selection.Attr("href")
+		addSubpage(exampleUri, func(namespace, name string) bool {
+			_, errMediaType := media.ToMediaType(name)
+			return strings.EqualFold(namespace, tropeId) && errMediaType == nil
+		})
+	})
+
+	return subPagesUrls
+}
+
+// GetFailedPages returns the URL of every Work page that couldn't be crawled by this crawler, related with the error that made it fail
+// Failed pages are accumulated through all the crawls made with the same crawler
+func (crawler *ServiceCrawler) GetFailedPages() map[string]error {
+	crawler.failedPagesMutex.Lock()
+	defer crawler.failedPagesMutex.Unlock()
+
+	failedPages := make(map[string]error, len(crawler.failedPages))
+	for failedUrl, errFailed := range crawler.failedPages {
+		failedPages[failedUrl] = errFailed
+	}
+
+	return failedPages
+}
+
+// wait blocks until the request to pageUrl is allowed by the scheduler
+// Pages that the fetcher will serve from its cache are not requested, so they don't wait for their turn
+func (crawler *ServiceCrawler) wait(ctx context.Context, pageUrl string) error {
+	if cachedFetcher, isCached := crawler.fetcher.(fetcher.CachedFetcher); isCached && cachedFetcher.IsCached(pageUrl) {
+		return ctx.Err()
 	}
 
-	return errSubpages
+	return crawler.scheduler.Wait(ctx, pageUrl)
+}
+
+// handleBatch calls the BatchHandler of the crawler, if it has one, after the handled Work pages of the batch
+// and checkpoints them as done, or records all of them as failed with its error if it fails
+func (crawler *ServiceCrawler) handleBatch(batch []string) {
+	if crawler.batchHandler == nil || len(batch) == 0 {
+		return
+	}
+
+	status := WorkDone
+	errBatch := crawler.batchHandler()
+	if errBatch != nil {
+		log.Error().Err(errBatch).Msgf("HANDLING A BATCH OF %d WORK PAGES FAILED", len(batch))
+		status = WorkFailed
+	}
+
+	for _, workUrl := range batch {
+		if errBatch != nil {
+			crawler.addFailedPage(workUrl, errBatch)
+		}
+
+		crawler.setWorkStatus(workUrl, status)
+	}
 }
 
-// makeValidRequests builds an HTTP request to the url page and returns its contents
-// The request sets very specific Headers to pass as a real browser, avoiding banning for being a bot
-// It returns an ErrNotFound error if the request couldn't be made
-func (crawler *ServiceCrawler) makeValidRequest(pageUrl string) (*http.Request, error) {
-	request, errRequest := http.NewRequest("GET", pageUrl, nil)
-	if errRequest != nil {
-		return nil, fmt.Errorf("%w: "+pageUrl, ErrNotFound)
+// setWorkStatus checkpoints the status of the workUrl Work page if the crawler has a Frontier
+// A checkpoint that can't be saved doesn't stop the crawling, it's only logged
+func (crawler *ServiceCrawler) setWorkStatus(workUrl string, status WorkStatus) {
+	if crawler.frontier == nil {
+		return
 	}
 
-	request.Header.Set("User-Agent", userAgentHeader)
-	request.Header.Set("Referer", refererHeader)
-	request.Header.Set("Accept", acceptHeader)
-	request.Header.Set("Accept-Language", acceptLanguageHeader)
-	request.Header.Set("Upgrade-Insecure-Requests", upgradeInsecureRequestsHeader)
+	if errStatus := crawler.frontier.setWorkStatus(workUrl, status); errStatus != nil {
+		log.Error().Err(errStatus).Msg("CHECKPOINTING FAILED " + workUrl)
+	}
+}
+
+// saveFrontier saves the crawler Frontier, only logging the error if it can't be saved
+func (crawler *ServiceCrawler) saveFrontier() {
+	if errSave := crawler.frontier.Save(); errSave != nil {
+		log.Error().Err(errSave).Msg("CHECKPOINTING FAILED")
+	}
+}
+
+// addFailedPage records the workUrl as a failed page with the error that made it fail
+func (crawler *ServiceCrawler) addFailedPage(workUrl string, errFailed error) {
+	crawler.failedPagesMutex.Lock()
+	defer crawler.failedPagesMutex.Unlock()
+
+	crawler.failedPages[workUrl] = errFailed
+}
+
+// recordFailure records on the outcome Report of the crawler, if it has one, that the page at pageUrl couldn't be crawled
+// with the errFailed error after the number of attempts
+func (crawler *ServiceCrawler) recordFailure(pageUrl string, errFailed error, attempts int) {
+	if crawler.report != nil {
+		crawler.report.AddFailure(pageUrl, outcome.CrawlStage, errFailed, attempts)
+	}
+}
+
+// crawlAndHandle crawls the pageUrl with the crawlPage task and handles the crawled pages with the WorkHandler of the crawler, if it has one,
+// sending the outcome of both through the results channel
+// Handling the pages on the worker lets the handlers of different Work pages run in parallel while the crawl goes on
+func (crawler *ServiceCrawler) crawlAndHandle(ctx context.Context, pageUrl string, lastUpdated *time.Time, crawlPage pageCrawler, results chan<- workResult) {
+	result := crawlPage(ctx, pageUrl, lastUpdated)
+	if result.err == nil && result.pages != nil && crawler.handler != nil {
+		result.errHandle = crawler.handler(result.pages)
+	}
+
+	results <- result
+}
+
+// crawlWork is the task of a single worker, which crawls a Work page, its last updated time and all of its subpages
+// If lastUpdated isn't nil, the subpages are only crawled if the Work page has been updated after that time
+// It returns the outcome, with nil pages if the Work page didn't need to be crawled
+func (crawler *ServiceCrawler) crawlWork(ctx context.Context, workUrl string, lastUpdated *time.Time) workResult {
+	workPages := tvtropespages.NewTvTropesPages()
+
+	log.Info().Msg("CRAWLING: " + workUrl)
+
+	// Create the Work Page
+	workPage, attempts, errWorkPage := crawler.createWorkPage(ctx, workUrl, workPages)
+	if errWorkPage != nil {
+		return workResult{workUrl: workUrl, attempts: attempts, err: errWorkPage}
+	}
+
+	// Set LastUpdated time
+	newLastUpdated, errLastUpdated := crawler.getLastUpdated(ctx, workPage.GetDocument())
+	if errLastUpdated != nil {
+		return workResult{workUrl: workUrl, attempts: attempts, err: errLastUpdated}
+	}
+
+	// Only crawl the subpages if the page hasn't been crawled before, or it's been updated
+	if lastUpdated != nil && !newLastUpdated.After(*lastUpdated) {
+		return workResult{workUrl: workUrl, attempts: attempts}
+	}
+	workPages.Pages[workPage].LastUpdated = newLastUpdated
+
+	// Crawl Work subpages and add them
+	errSubpages := crawler.addWorkSubpages(ctx, workPage, workPages)
+	if errSubpages != nil {
+		return workResult{workUrl: workUrl, attempts: attempts, err: errSubpages}
+	}
+
+	return workResult{workUrl: workUrl, pages: workPages, attempts: attempts}
+}
+
+// crawlTrope is the task of a single worker, which crawls a trope Main page and all of its subpages
+// It returns the outcome of crawling the trope pages
+func (crawler *ServiceCrawler) crawlTrope(ctx context.Context, tropeUrl string, _ *time.Time) workResult {
+	tropePages := tvtropespages.NewTvTropesPages()
+
+	log.Info().Msg("CRAWLING: " + tropeUrl)
+
+	tropePage, attempts, errTropePage := crawler.createWorkPage(ctx, tropeUrl, tropePages)
+	if errTropePage != nil {
+		return workResult{workUrl: tropeUrl, attempts: attempts, err: errTropePage}
+	}
+
+	tropeId := strings.TrimPrefix(tropePage.GetUrl().Path, tvtropespages.TvTropesMainPath)
+	subPagesUrls := crawler.CrawlTropeSubpages(tropePage.GetDocument(), tropeId)
+	if errSubpages := crawler.addSubpages(ctx, tropePage, subPagesUrls, tropePages); errSubpages != nil {
+		return workResult{workUrl: tropeUrl, attempts: attempts, err: errSubpages}
+	}
+
+	return workResult{workUrl: tropeUrl, pages: tropePages, attempts: attempts}
+}
+
+// crawledWorks holds the Work pages crawled on a crawl along with the set of their URLs, for counting them and finding the duplicated ones
+type crawledWorks struct {
+	pages *tvtropespages.TvTropesPages
+	urls  map[string]bool
+}
+
+// newCrawledWorks creates the empty crawled Work pages of a crawl
+func newCrawledWorks() *crawledWorks {
+	return &crawledWorks{
+		pages: tvtropespages.NewTvTropesPages(),
+		urls:  make(map[string]bool),
+	}
+}
+
+// add adds the page with its subpages to the crawled Work pages, returning false if there's already a page with the same URL
+// If keepDocuments isn't set, only the URL and the last updated time of the page are kept, without its document nor its subpages,
+// so the pages that have already been handled don't stay in memory for the whole crawl
+func (crawled *crawledWorks) add(page tvtropespages.Page, subpages *tvtropespages.TvTropesSubpages, keepDocuments bool) bool {
+	pageUrl := page.GetUrl().String()
+	if crawled.urls[pageUrl] {
+		return false
+	}
+	crawled.urls[pageUrl] = true
+
+	if !keepDocuments {
+		page, _ = tvtropespages.NewPage(context.Background(), pageUrl, false, nil)
+		subpages = &tvtropespages.TvTropesSubpages{LastUpdated: subpages.LastUpdated, Subpages: make(map[tvtropespages.Page]time.Time)}
+	}
+	crawled.pages.Pages[page] = subpages
+
+	return true
+}
+
+// createWorkPage forms a valid Work Page object and adds it to the crawledPages object
+// It also returns the number of requests made for the page, 0 if it was served from a cache
+func (crawler *ServiceCrawler) createWorkPage(ctx context.Context, workUrl string, crawledPages *tvtropespages.TvTropesPages) (tvtropespages.Page, int, error) {
+	if errWait := crawler.wait(ctx, workUrl); errWait != nil {
+		return tvtropespages.Page{}, 0, errWait
+	}
+
+	pageFetcher := &attemptsFetcher{Fetcher: crawler.fetcher}
+	workPage, errAddPage := crawledPages.AddTvTropesPage(ctx, workUrl, true, pageFetcher)
+	if errAddPage != nil {
+		return tvtropespages.Page{}, pageFetcher.attempts, errAddPage
+	}
+
+	return workPage, pageFetcher.attempts, nil
+}
+
+// attemptsFetcher wraps the Fetcher of the crawler for a single page, keeping the number of requests its Response needed
+type attemptsFetcher struct {
+	fetcher.Fetcher
+
+	attempts int
+}
+
+// Fetch requests the pageUrl with the wrapped Fetcher, keeping the attempts of its Response
+func (af *attemptsFetcher) Fetch(ctx context.Context, pageUrl string) (*fetcher.Response, error) {
+	resp, errFetch := af.Fetcher.Fetch(ctx, pageUrl)
+	if resp != nil {
+		af.attempts = resp.Attempts
+		if resp.Cached {
+			af.attempts = 0
+		}
+	}
+
+	return resp, errFetch
+}
+
+// addWorkSubpages crawls all Work subpages, creates them and adds them to the referenced crawledPages argument
+// Each subpage request waits for its turn on the scheduler, so subpages of different Works are interleaved politely
+func (crawler *ServiceCrawler) addWorkSubpages(ctx context.Context, workPage tvtropespages.Page, crawledPages *tvtropespages.TvTropesPages) error {
+	// Search for subpages on the new Work Page
+	subPagesUrls := crawler.CrawlWorkSubpages(workPage.GetDocument())
+
+	return crawler.addSubpages(ctx, workPage, subPagesUrls, crawledPages)
+}
+
+// addSubpages crawls the subPagesUrls of a page, creates them and adds them to the referenced crawledPages argument
+func (crawler *ServiceCrawler) addSubpages(ctx context.Context, workPage tvtropespages.Page, subPagesUrls []string, crawledPages *tvtropespages.TvTropesPages) error {
+	// Add its subpages to the page
+	for _, subPagesUrl := range subPagesUrls {
+		if errWait := crawler.wait(ctx, subPagesUrl); errWait != nil {
+			return errWait
+		}
+
+		errSubpages := crawledPages.AddSubpages(ctx, workPage.GetUrl().String(), []string{subPagesUrl}, true, crawler.fetcher)
+
+		// If TvTropes keeps denying the requests even after retrying, all workers wait longer
+		if errors.Is(errSubpages, tvtropespages.ErrForbidden) {
+			crawler.scheduler.Pause(subPagesUrl, forbiddenPause)
+		}
+
+		if errSubpages != nil {
+			return errSubpages
+		}
+	}
 
-	return request, nil
+	return nil
 }
 
 // GetLastUpdated retrieves the last updated date from the history page of a Work page and parses it to a valid time object
 // If it couldn't be parsed or obtained, it will return an ErrLastUpdated error
-func (crawler *ServiceCrawler) getLastUpdated(doc *goquery.Document) (time.Time, error) {
-	historyPageUri, historyPageExists := doc.Find(WorkHistoryPageSelector).First().Attr("href")
+func (crawler *ServiceCrawler) getLastUpdated(ctx context.Context, doc *goquery.Document) (time.Time, error) {
+	historyPageUri, historyPageExists := doc.Find(crawler.profile.Crawler.WorkHistoryPage).First().Attr("href")
 	if !historyPageExists {
 		return time.Time{}, nil
 	}
 
-	request, errRequest := crawler.makeValidRequest(TvTropesWeb + historyPageUri)
-	if errRequest != nil {
-		return time.Time{}, errRequest
+	if errWait := crawler.wait(ctx, TvTropesWeb+historyPageUri); errWait != nil {
+		return time.Time{}, errWait
 	}
 
-	resp, errDoRequest := httpClient.Do(request)
-	if errDoRequest != nil {
-		return time.Time{}, fmt.Errorf("%w because there was an error on the HTTP request to the history ", ErrLastUpdated)
+	resp, errFetch := crawler.fetcher.Fetch(ctx, TvTropesWeb+historyPageUri)
+	if errFetch != nil {
+		return time.Time{}, fmt.Errorf("%w because there was an error on the HTTP request to the history \n%w", ErrLastUpdated, errFetch)
 	}
 
-	historyDoc, _ := goquery.NewDocumentFromReader(resp.Body)
+	historyDoc, _ := goquery.NewDocumentFromReader(bytes.NewReader(resp.Body))
 	lastUpdated, errLastUpdated := crawler.ParseTvTropesTime(historyDoc)
 	if errLastUpdated != nil {
 		return time.Time{}, errLastUpdated
@@ -408,7 +1164,7 @@ func (crawler *ServiceCrawler) getLastUpdated(doc *goquery.Document) (time.Time,
 // ParseTvTropesTime searches for the last updated time in a work history page and parses it to a valid time object
 // If it can't be parsed it will return an ErrParseTime error
 func (crawler *ServiceCrawler) ParseTvTropesTime(historyDoc *goquery.Document) (time.Time, error) {
-	lastUpdatedString := historyDoc.Find(LastUpdatedSelector).Text()
+	lastUpdatedString := historyDoc.Find(crawler.profile.Crawler.LastUpdated).Text()
 	for _, ordinal := range dateOrdinals {
 		lastUpdatedString = strings.ReplaceAll(lastUpdatedString, ordinal, "")
 	}
@@ -1,25 +1,83 @@
 package crawler_test
 
 import (
+	"context"
+	"errors"
+	"net/http"
+
 	"github.com/PuerkitoBio/goquery"
+	"github.com/jlgallego99/TropesToGo/media"
+	"github.com/jlgallego99/TropesToGo/selectors"
 	crawler "github.com/jlgallego99/TropesToGo/service/crawler"
+	"github.com/jlgallego99/TropesToGo/service/fetcher"
+	"github.com/jlgallego99/TropesToGo/service/outcome"
+	"github.com/jlgallego99/TropesToGo/trope"
 	"github.com/jlgallego99/TropesToGo/tvtropespages"
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
 	"github.com/rs/zerolog"
 	"io"
 	"os"
+	"path/filepath"
+	"strings"
+	"sync"
 	"time"
 )
 
 const (
-	indexResource = "resources/film_index_page1.html"
-	historyPage   = "resources/oldboy_history.html"
+	indexResource  = "resources/film_index_page1.html"
+	historyPage    = "resources/oldboy_history.html"
+	filmIndexUrl   = "https://tvtropes.org/pmwiki/pagelist_having_pagetype_in_namespace.php?t=work&n=Film"
+	tropeResource  = "../scraper/resources/chekhovsgun.html"
+	chekhovsGunUrl = "https://tvtropes.org/pmwiki/pmwiki.php/Main/ChekhovsGun"
+	mainUrl        = "https://tvtropes.org/pmwiki/pmwiki.php/Main/"
 )
 
 var filmResources = []string{"resources/film1.html", "resources/film2.html", "resources/film3.html",
 	"resources/film4.html", "resources/film5.html"}
 
+var filmUrls = []string{"http://tvtropes.org/pmwiki/pmwiki.php/Film/Aadai", "http://tvtropes.org/pmwiki/pmwiki.php/Film/AaronLovesAngela",
+	"http://tvtropes.org/pmwiki/pmwiki.php/Film/AbacusSmallEnoughToJail", "http://tvtropes.org/pmwiki/pmwiki.php/Film/ABadMomsChristmas",
+	"http://tvtropes.org/pmwiki/pmwiki.php/Film/AbandonedMine"}
+
+// resourceFetcher is a Fetcher that replays the local resources instead of requesting TvTropes
+// Unknown URLs are answered with an empty page, and history pages with the same history resource
+// URLs on the unavailable set are answered with a server error, and all requested URLs are stored on requested
+type resourceFetcher struct {
+	resources   map[string]string
+	unavailable map[string]bool
+	requested   *sync.Map
+}
+
+func (rf resourceFetcher) Fetch(_ context.Context, pageUrl string) (*fetcher.Response, error) {
+	rf.requested.Store(pageUrl, true)
+
+	if rf.unavailable[pageUrl] {
+		return &fetcher.Response{URL: pageUrl, StatusCode: http.StatusServiceUnavailable, Header: http.Header{}}, nil
+	}
+
+	resource, exists := rf.resources[pageUrl]
+	if !exists && strings.Contains(pageUrl, "article_history.php") {
+		resource, exists = historyPage, true
+	}
+
+	body := []byte("<html></html>")
+	if exists {
+		body, _ = os.ReadFile(resource)
+	}
+
+	return &fetcher.Response{URL: pageUrl, StatusCode: http.StatusOK, Header: http.Header{}, Body: body}, nil
+}
+
+func newResourceFetcher() resourceFetcher {
+	resources := map[string]string{filmIndexUrl: indexResource}
+	for i, filmUrl := range filmUrls {
+		resources[filmUrl] = filmResources[i]
+	}
+
+	return resourceFetcher{resources: resources, unavailable: make(map[string]bool), requested: &sync.Map{}}
+}
+
 // A crawler service for test purposes
 var serviceCrawler *crawler.ServiceCrawler
 var errNewCrawler, errCrawling error
@@ -29,7 +87,7 @@ var _ = BeforeSuite(func() {
 	// Do not log during testing
 	zerolog.SetGlobalLevel(zerolog.Disabled)
 
-	serviceCrawler = crawler.NewCrawler()
+	serviceCrawler, errNewCrawler = crawler.NewCrawler()
 	Expect(errNewCrawler).To(BeNil())
 
 	indexReader, _ := os.Open(indexResource)
@@ -63,6 +121,582 @@ var _ = Describe("Crawler", func() {
 		})
 	})
 
+	Context("Creating a crawler with an invalid configuration", func() {
+		var invalidCrawler *crawler.ServiceCrawler
+		var errInvalidWorkers, errInvalidWaiting error
+
+		BeforeEach(func() {
+			invalidCrawler, errInvalidWorkers = crawler.NewCrawler(crawler.ConfigWorkers(0))
+			_, errInvalidWaiting = crawler.NewCrawler(crawler.ConfigWaitingTime(time.Second, time.Millisecond))
+		})
+
+		It("Should return an empty ServiceCrawler", func() {
+			Expect(invalidCrawler).To(BeNil())
+		})
+
+		It("Should return an appropriate error", func() {
+			Expect(errInvalidWorkers).To(Equal(crawler.ErrInvalidField))
+			Expect(errInvalidWaiting).To(Equal(crawler.ErrInvalidField))
+		})
+	})
+
+	Context("Crawling a limited number of Work Pages in parallel through a Fetcher", func() {
+		var fetchedPages *tvtropespages.TvTropesPages
+		var errFetchCrawler, errFetchedCrawling error
+
+		BeforeEach(func() {
+			var fetcherCrawler *crawler.ServiceCrawler
+			fetcherCrawler, errFetchCrawler = crawler.NewCrawler(crawler.ConfigFetcher(newResourceFetcher()),
+				crawler.ConfigWorkers(3), crawler.ConfigWaitingTime(0, 0))
+			Expect(errFetchCrawler).To(BeNil())
+
+			fetchedPages, errFetchedCrawling = fetcherCrawler.CrawlWorkPages(context.Background(), 4, media.Film)
+		})
+
+		It("Shouldn't return an error", func() {
+			Expect(errFetchedCrawling).To(BeNil())
+		})
+
+		It("Should have crawled exactly the limit of Work Pages with their documents", func() {
+			Expect(fetchedPages.Pages).To(HaveLen(4))
+
+			for crawledPage, crawledSubpages := range fetchedPages.Pages {
+				Expect(crawledPage.GetDocument()).To(Not(BeNil()))
+				Expect(crawledPage.GetPageType()).To(Equal(tvtropespages.WorkPage))
+				Expect(crawledSubpages.LastUpdated).To(Not(Equal(time.Time{})))
+			}
+		})
+	})
+
+	Context("Crawling Work Pages with a selector profile", func() {
+		var errMovedCrawling, errInvalidProfile error
+
+		BeforeEach(func() {
+			profile := selectors.DefaultProfile()
+			profile.Crawler.WorkPage = "table.moved-list a"
+			movedCrawler, errMovedCrawler := crawler.NewCrawler(crawler.ConfigFetcher(newResourceFetcher()),
+				crawler.ConfigSelectors(profile), crawler.ConfigWaitingTime(0, 0))
+			Expect(errMovedCrawler).To(BeNil())
+
+			_, errMovedCrawling = movedCrawler.CrawlWorkPages(context.Background(), 4, media.Film)
+
+			profile.Patterns.TropesSubpage = "tropes[a-z"
+			_, errInvalidProfile = crawler.NewCrawler(crawler.ConfigSelectors(profile))
+		})
+
+		It("Should look for the Work pages with the selectors of the profile", func() {
+			Expect(errors.Is(errMovedCrawling, crawler.ErrCrawling)).To(BeTrue())
+		})
+
+		It("Shouldn't accept an invalid profile", func() {
+			Expect(errors.Is(errInvalidProfile, crawler.ErrInvalidField)).To(BeTrue())
+			Expect(errors.Is(errInvalidProfile, selectors.ErrInvalidPattern)).To(BeTrue())
+		})
+
+		It("Should keep the deprecated selectors with the values of the default profile", func() {
+			Expect(crawler.WorkPageSelector).To(Equal(selectors.DefaultProfile().Crawler.WorkPage))
+			Expect(crawler.SubWikiSelector).To(Equal(selectors.DefaultProfile().Crawler.SubWiki))
+			Expect(crawler.LastUpdatedSelector).To(Equal(selectors.DefaultProfile().Crawler.LastUpdated))
+		})
+	})
+
+	Context("Crawling Work Pages when one of them can't be retrieved", func() {
+		var changedPages *tvtropespages.TvTropesPages
+		var failedPages map[string]error
+		var errChangesCrawling error
+
+		BeforeEach(func() {
+			unavailableFetcher := newResourceFetcher()
+			unavailableFetcher.unavailable[filmUrls[1]] = true
+
+			fetcherCrawler, errFetchCrawler := crawler.NewCrawler(crawler.ConfigFetcher(unavailableFetcher),
+				crawler.ConfigWorkers(2), crawler.ConfigWaitingTime(0, 0))
+			Expect(errFetchCrawler).To(BeNil())
+
+			crawledWorks := make(map[string]time.Time)
+			for _, filmUrl := range filmUrls {
+				crawledWorks[filmUrl] = time.Time{}
+			}
+
+			changedPages, errChangesCrawling = fetcherCrawler.CrawlChanges(context.Background(), crawledWorks)
+			failedPages = fetcherCrawler.GetFailedPages()
+		})
+
+		It("Shouldn't stop the crawling", func() {
+			Expect(errChangesCrawling).To(BeNil())
+			Expect(changedPages.Pages).To(HaveLen(len(filmUrls) - 1))
+		})
+
+		It("Should report the failed Work Page with its error", func() {
+			Expect(failedPages).To(HaveLen(1))
+			Expect(failedPages).To(HaveKey(filmUrls[1]))
+			Expect(errors.Is(failedPages[filmUrls[1]], tvtropespages.ErrNotFound)).To(BeTrue())
+		})
+	})
+
+	Context("Resuming a stopped crawl from its Frontier", func() {
+		var statePath string
+		var firstHandled, resumedHandled []string
+		var resumedPages *tvtropespages.TvTropesPages
+		var loadedFrontier *crawler.Frontier
+		var resumedFetcher resourceFetcher
+		var errFirstCrawl, errLoad, errResumedCrawl error
+
+		handleInto := func(handled *[]string) crawler.WorkHandler {
+			return func(workPages *tvtropespages.TvTropesPages) error {
+				for workPage := range workPages.Pages {
+					*handled = append(*handled, workPage.GetUrl().String())
+				}
+
+				return nil
+			}
+		}
+
+		BeforeEach(func() {
+			firstHandled, resumedHandled = nil, nil
+			statePath = filepath.Join(GinkgoT().TempDir(), "dataset.state.json")
+
+			firstCrawler, _ := crawler.NewCrawler(crawler.ConfigFetcher(newResourceFetcher()),
+				crawler.ConfigWorkers(1), crawler.ConfigWaitingTime(0, 0),
+				crawler.ConfigFrontier(crawler.NewFrontier(statePath)), crawler.ConfigWorkHandler(handleInto(&firstHandled)))
+			_, errFirstCrawl = firstCrawler.CrawlWorkPages(context.Background(), 2, media.Film)
+
+			loadedFrontier, errLoad = crawler.LoadFrontier(statePath)
+			Expect(errLoad).To(BeNil())
+
+			resumedFetcher = newResourceFetcher()
+			resumedCrawler, _ := crawler.NewCrawler(crawler.ConfigFetcher(resumedFetcher),
+				crawler.ConfigWorkers(1), crawler.ConfigWaitingTime(0, 0),
+				crawler.ConfigFrontier(loadedFrontier), crawler.ConfigWorkHandler(handleInto(&resumedHandled)))
+			resumedPages, errResumedCrawl = resumedCrawler.CrawlWorkPages(context.Background(), 4, media.Film)
+		})
+
+		It("Shouldn't return an error", func() {
+			Expect(errFirstCrawl).To(BeNil())
+			Expect(errResumedCrawl).To(BeNil())
+		})
+
+		It("Should have checkpointed the handled Work Pages", func() {
+			Expect(firstHandled).To(Equal(filmUrls[:2]))
+			Expect(loadedFrontier.GetWorkStatus(filmUrls[0])).To(Equal(crawler.WorkDone))
+			Expect(loadedFrontier.GetWorkStatus(filmUrls[1])).To(Equal(crawler.WorkDone))
+			Expect(loadedFrontier.GetWorkStatus(filmUrls[4])).To(Equal(crawler.WorkPending))
+
+			mediaType, errMediaType := loadedFrontier.GetMediaType()
+			Expect(errMediaType).To(BeNil())
+			Expect(mediaType).To(Equal(media.Film))
+		})
+
+		It("Should only crawl the remaining Work Pages up to the limit", func() {
+			Expect(resumedHandled).To(Equal(filmUrls[2:4]))
+			Expect(resumedPages.Pages).To(HaveLen(2))
+			Expect(loadedFrontier.CountWorks(crawler.WorkDone)).To(Equal(4))
+		})
+
+		It("Shouldn't keep the documents of the handled Work Pages", func() {
+			for resumedPage, resumedSubpages := range resumedPages.Pages {
+				Expect(resumedPage.GetDocument()).To(BeNil())
+				Expect(resumedSubpages.Subpages).To(BeEmpty())
+			}
+		})
+
+		It("Shouldn't request the done Work Pages or the index again", func() {
+			_, requestedIndex := resumedFetcher.requested.Load(filmIndexUrl)
+			_, requestedFirst := resumedFetcher.requested.Load(filmUrls[0])
+			_, requestedSecond := resumedFetcher.requested.Load(filmUrls[1])
+
+			Expect(requestedIndex).To(BeFalse())
+			Expect(requestedFirst).To(BeFalse())
+			Expect(requestedSecond).To(BeFalse())
+		})
+	})
+
+	Context("Crawling Work Pages of several media types", func() {
+		var statePath string
+		var mediaPages *tvtropespages.TvTropesPages
+		var loadedFrontier *crawler.Frontier
+		var resumedFetcher resourceFetcher
+		var errMediaCrawling, errResumedCrawl error
+		animeIndexUrl := strings.Replace(filmIndexUrl, "n=Film", "n=Anime", 1)
+		mediaLimits := []crawler.MediaLimit{{MediaType: media.Film, Limit: 2}, {MediaType: media.Anime, Limit: 1}}
+
+		BeforeEach(func() {
+			statePath = filepath.Join(GinkgoT().TempDir(), "dataset.state.json")
+
+			// The index of the second media type fails, but it doesn't stop the crawling of the first one
+			mediaFetcher := newResourceFetcher()
+			mediaFetcher.unavailable[animeIndexUrl] = true
+			mediaCrawler, _ := crawler.NewCrawler(crawler.ConfigFetcher(mediaFetcher),
+				crawler.ConfigWorkers(2), crawler.ConfigWaitingTime(0, 0),
+				crawler.ConfigFrontier(crawler.NewFrontier(statePath)), crawler.ConfigWorkHandler(func(*tvtropespages.TvTropesPages) error { return nil }))
+			mediaPages, errMediaCrawling = mediaCrawler.CrawlMediaWorkPages(context.Background(), mediaLimits)
+
+			loadedFrontier, _ = crawler.LoadFrontier(statePath)
+			resumedFetcher = newResourceFetcher()
+			resumedFetcher.unavailable[animeIndexUrl] = true
+			resumedCrawler, _ := crawler.NewCrawler(crawler.ConfigFetcher(resumedFetcher),
+				crawler.ConfigWaitingTime(0, 0), crawler.ConfigFrontier(loadedFrontier))
+			_, errResumedCrawl = resumedCrawler.CrawlMediaWorkPages(context.Background(), loadedFrontier.GetMediaLimits())
+		})
+
+		It("Should crawl each media type up to its own limit", func() {
+			Expect(mediaPages.Pages).To(HaveLen(2))
+			for crawledPage := range mediaPages.Pages {
+				Expect(crawledPage.GetUrl().String()).To(BeElementOf(filmUrls[0], filmUrls[1]))
+			}
+		})
+
+		It("Should return the error of the failing media type", func() {
+			Expect(errors.Is(errMediaCrawling, crawler.ErrCrawling)).To(BeTrue())
+			Expect(errors.Is(errResumedCrawl, crawler.ErrCrawling)).To(BeTrue())
+		})
+
+		It("Should checkpoint the media types with their limits", func() {
+			Expect(loadedFrontier.GetMediaLimits()).To(Equal(mediaLimits))
+		})
+
+		It("Should resume only the media types that haven't reached their limit", func() {
+			_, requestedFilmIndex := resumedFetcher.requested.Load(filmIndexUrl)
+			_, requestedAnimeIndex := resumedFetcher.requested.Load(animeIndexUrl)
+
+			Expect(requestedFilmIndex).To(BeFalse())
+			Expect(requestedAnimeIndex).To(BeTrue())
+		})
+	})
+
+	Context("Crawling an explicit list of Work URLs", func() {
+		var listedPages *tvtropespages.TvTropesPages
+		var failedPages map[string]error
+		var errListCrawling error
+		invalidUrls := []string{"https://example.org/pmwiki/pmwiki.php/Film/Oldboy2003",
+			"https://tvtropes.org/pmwiki/pmwiki.php/Main/ChekhovsGun",
+			"https://tvtropes.org/pmwiki/pmwiki.php/NotAMedia/Oldboy2003"}
+
+		BeforeEach(func() {
+			listCrawler, errListCrawler := crawler.NewCrawler(crawler.ConfigFetcher(newResourceFetcher()),
+				crawler.ConfigWorkers(2), crawler.ConfigWaitingTime(0, 0))
+			Expect(errListCrawler).To(BeNil())
+
+			listedUrls := append([]string{filmUrls[0], " " + filmUrls[3] + " ", filmUrls[0]}, invalidUrls...)
+			listedPages, errListCrawling = listCrawler.CrawlWorkURLs(context.Background(), listedUrls)
+			failedPages = listCrawler.GetFailedPages()
+		})
+
+		It("Shouldn't return an error", func() {
+			Expect(errListCrawling).To(BeNil())
+		})
+
+		It("Should have crawled only the valid and distinct Work Pages", func() {
+			Expect(listedPages.Pages).To(HaveLen(2))
+
+			for crawledPage, crawledSubpages := range listedPages.Pages {
+				Expect(crawledPage.GetUrl().String()).To(BeElementOf(filmUrls[0], filmUrls[3]))
+				Expect(crawledPage.GetDocument()).To(Not(BeNil()))
+				Expect(crawledSubpages.LastUpdated).To(Not(Equal(time.Time{})))
+			}
+		})
+
+		It("Should report every invalid URL", func() {
+			Expect(failedPages).To(HaveLen(len(invalidUrls)))
+			for _, invalidUrl := range invalidUrls {
+				Expect(errors.Is(failedPages[invalidUrl], crawler.ErrInvalidWork)).To(BeTrue())
+			}
+		})
+	})
+
+	Context("Handling the crawled Work Pages with several workers", func() {
+		var handling, maxHandling int
+		var handled []string
+		var errHandledCrawling error
+
+		BeforeEach(func() {
+			handling, maxHandling, handled = 0, 0, nil
+			var handlingMutex sync.Mutex
+
+			// Each handler takes a while, so the handlers of different workers overlap if they run in parallel
+			slowHandler := func(workPages *tvtropespages.TvTropesPages) error {
+				handlingMutex.Lock()
+				handling++
+				if handling > maxHandling {
+					maxHandling = handling
+				}
+				for workPage := range workPages.Pages {
+					handled = append(handled, workPage.GetUrl().String())
+				}
+				handlingMutex.Unlock()
+
+				time.Sleep(100 * time.Millisecond)
+
+				handlingMutex.Lock()
+				handling--
+				handlingMutex.Unlock()
+
+				return nil
+			}
+
+			handlerCrawler, errHandlerCrawler := crawler.NewCrawler(crawler.ConfigFetcher(newResourceFetcher()),
+				crawler.ConfigWorkers(2), crawler.ConfigWaitingTime(0, 0), crawler.ConfigWorkHandler(slowHandler))
+			Expect(errHandlerCrawler).To(BeNil())
+
+			_, errHandledCrawling = handlerCrawler.CrawlWorkURLs(context.Background(), filmUrls[:4])
+		})
+
+		It("Should handle every Work Page", func() {
+			Expect(errHandledCrawling).To(BeNil())
+			Expect(handled).To(ConsistOf(filmUrls[:4]))
+		})
+
+		It("Should handle the Work Pages of different workers at the same time", func() {
+			Expect(maxHandling).To(Equal(2))
+		})
+	})
+
+	Context("Handling the crawled Work Pages in batches", func() {
+		var statePath string
+		var batches []int
+		var handledSinceBatch int
+		var errBatchCrawling, errFailedBatchCrawling error
+		var loadedFrontier, failedFrontier *crawler.Frontier
+		var failedCrawler *crawler.ServiceCrawler
+		errBatch := errors.New("the batch couldn't be persisted")
+
+		BeforeEach(func() {
+			batches, handledSinceBatch = nil, 0
+			statePath = filepath.Join(GinkgoT().TempDir(), "dataset.state.json")
+			countHandled := func(*tvtropespages.TvTropesPages) error {
+				handledSinceBatch++
+				return nil
+			}
+
+			batchCrawler, errBatchCrawler := crawler.NewCrawler(crawler.ConfigFetcher(newResourceFetcher()),
+				crawler.ConfigWorkers(1), crawler.ConfigWaitingTime(0, 0), crawler.ConfigFrontier(crawler.NewFrontier(statePath)),
+				crawler.ConfigWorkHandler(countHandled), crawler.ConfigBatchHandler(func() error {
+					batches = append(batches, handledSinceBatch)
+					handledSinceBatch = 0
+					return nil
+				}, 2))
+			Expect(errBatchCrawler).To(BeNil())
+			_, errBatchCrawling = batchCrawler.CrawlWorkURLs(context.Background(), filmUrls[:3])
+			loadedFrontier, _ = crawler.LoadFrontier(statePath)
+
+			failedFrontier = crawler.NewFrontier(filepath.Join(GinkgoT().TempDir(), "failed.state.json"))
+			failedCrawler, _ = crawler.NewCrawler(crawler.ConfigFetcher(newResourceFetcher()),
+				crawler.ConfigWorkers(1), crawler.ConfigWaitingTime(0, 0), crawler.ConfigFrontier(failedFrontier),
+				crawler.ConfigWorkHandler(countHandled), crawler.ConfigBatchHandler(func() error { return errBatch }, 2))
+			_, errFailedBatchCrawling = failedCrawler.CrawlWorkURLs(context.Background(), filmUrls[:3])
+		})
+
+		It("Should complete the batches of handled Work Pages and the last one at the end of the crawl", func() {
+			Expect(errBatchCrawling).To(BeNil())
+			Expect(batches).To(Equal([]int{2, 1}))
+		})
+
+		It("Should only consider done the Work Pages of the completed batches", func() {
+			for _, filmUrl := range filmUrls[:3] {
+				Expect(loadedFrontier.GetWorkStatus(filmUrl)).To(Equal(crawler.WorkDone))
+			}
+		})
+
+		It("Should record the Work Pages of a failed batch as failed with its error", func() {
+			Expect(errFailedBatchCrawling).To(BeNil())
+			failedPages := failedCrawler.GetFailedPages()
+			Expect(failedPages).To(HaveLen(3))
+			for _, filmUrl := range filmUrls[:3] {
+				Expect(failedPages[filmUrl]).To(MatchError(errBatch))
+				Expect(failedFrontier.GetWorkStatus(filmUrl)).To(Equal(crawler.WorkFailed))
+			}
+		})
+
+		It("Shouldn't accept an empty batch handler or batch size", func() {
+			_, errNilHandler := crawler.NewCrawler(crawler.ConfigBatchHandler(nil, 2))
+			_, errBatchSize := crawler.NewCrawler(crawler.ConfigBatchHandler(func() error { return nil }, 0))
+			Expect(errors.Is(errNilHandler, crawler.ErrInvalidField)).To(BeTrue())
+			Expect(errors.Is(errBatchSize, crawler.ErrInvalidField)).To(BeTrue())
+		})
+	})
+
+	Context("Recording the outcome of crawling a list of Work URLs", func() {
+		var report *outcome.Report
+		invalidUrl := "https://tvtropes.org/pmwiki/pmwiki.php/NotAMedia/Oldboy2003"
+
+		BeforeEach(func() {
+			var errReport error
+			report, errReport = outcome.NewReport("dataset.json", outcome.ConfigErrorClasses(crawler.ErrorClasses...))
+			Expect(errReport).To(BeNil())
+
+			unavailableFetcher := newResourceFetcher()
+			unavailableFetcher.unavailable[filmUrls[1]] = true
+
+			reportCrawler, errReportCrawler := crawler.NewCrawler(crawler.ConfigFetcher(unavailableFetcher),
+				crawler.ConfigWorkers(2), crawler.ConfigWaitingTime(0, 0), crawler.ConfigOutcomeReport(report))
+			Expect(errReportCrawler).To(BeNil())
+
+			_, errReportCrawling := reportCrawler.CrawlWorkURLs(context.Background(), []string{filmUrls[0], filmUrls[1], invalidUrl})
+			Expect(errReportCrawling).To(BeNil())
+		})
+
+		It("Should record the crawled Work Pages as succeeded", func() {
+			Expect(report.GetOutcomes()).To(ContainElement(And(
+				HaveField("URL", filmUrls[0]), HaveField("Stage", outcome.CrawlStage), HaveField("Status", outcome.Succeeded))))
+		})
+
+		It("Should record the failed Work Pages with the class of their error", func() {
+			Expect(report.GetFailedUrls()).To(ConsistOf(filmUrls[1], invalidUrl))
+			Expect(report.GetOutcomes()).To(ContainElement(And(
+				HaveField("URL", filmUrls[1]), HaveField("Stage", outcome.CrawlStage), HaveField("ErrorClass", "ErrNotFound"))))
+			Expect(report.GetOutcomes()).To(ContainElement(And(
+				HaveField("URL", invalidUrl), HaveField("Stage", outcome.CrawlStage), HaveField("ErrorClass", "ErrInvalidWork"))))
+		})
+
+		It("Shouldn't accept an empty report", func() {
+			_, errNilReport := crawler.NewCrawler(crawler.ConfigOutcomeReport(nil))
+			Expect(errors.Is(errNilReport, crawler.ErrInvalidField)).To(BeTrue())
+		})
+	})
+
+	Context("Crawling the pages of a list of tropes", func() {
+		var tropePages *tvtropespages.TvTropesPages
+		var failedPages map[string]error
+		var errTropeCrawling error
+
+		BeforeEach(func() {
+			tropeFetcher := newResourceFetcher()
+			tropeFetcher.resources[chekhovsGunUrl] = tropeResource
+
+			tropeCrawler, _ := crawler.NewCrawler(crawler.ConfigFetcher(tropeFetcher), crawler.ConfigWaitingTime(0, 0))
+			tropePages, errTropeCrawling = tropeCrawler.CrawlTropePages(context.Background(), []string{"ChekhovsGun", " ChekhovsGun ", "Chekhovs/Gun"})
+			failedPages = tropeCrawler.GetFailedPages()
+		})
+
+		It("Shouldn't return an error", func() {
+			Expect(errTropeCrawling).To(BeNil())
+		})
+
+		It("Should have crawled each trope once with its Laconic and example subpages", func() {
+			Expect(tropePages.Pages).To(HaveLen(1))
+
+			for tropePage, tropeSubpages := range tropePages.Pages {
+				Expect(tropePage.GetUrl().String()).To(Equal(chekhovsGunUrl))
+
+				subpageUrls := make([]string, 0)
+				for subpage := range tropeSubpages.Subpages {
+					subpageUrls = append(subpageUrls, subpage.GetUrl().String())
+				}
+				Expect(subpageUrls).To(ConsistOf("https://tvtropes.org/pmwiki/pmwiki.php/Laconic/ChekhovsGun",
+					"https://tvtropes.org/pmwiki/pmwiki.php/ChekhovsGun/Film"))
+			}
+		})
+
+		It("Should report the invalid trope IDs", func() {
+			Expect(failedPages).To(HaveLen(1))
+			for _, errFailed := range failedPages {
+				Expect(errors.Is(errFailed, crawler.ErrInvalidTrope)).To(BeTrue())
+			}
+		})
+	})
+
+	Context("Classifying tropes by crawling the trope indexes", func() {
+		var mapping trope.IndexMapping
+		var failedPages map[string]error
+		var errIndexes error
+		var indexFetcher resourceFetcher
+
+		BeforeEach(func() {
+			indexFetcher = newResourceFetcher()
+			indexFetcher.resources[mainUrl+"GenreTropes"] = "resources/genretropes_index.html"
+			indexFetcher.resources[mainUrl+"NarrativeTropes"] = "resources/narrativetropes_index.html"
+			indexFetcher.resources[mainUrl+"ForeshadowingTropes"] = "resources/foreshadowingtropes_index.html"
+			indexFetcher.unavailable[mainUrl+"MediaTropes"] = true
+
+			indexCrawler, _ := crawler.NewCrawler(crawler.ConfigFetcher(indexFetcher), crawler.ConfigWaitingTime(0, 0))
+			mapping, errIndexes = indexCrawler.CrawlTropeIndexes(context.Background(), 1)
+			failedPages = indexCrawler.GetFailedPages()
+		})
+
+		It("Should classify the tropes of the top-level indexes and their sub-indexes", func() {
+			Expect(mapping).To(HaveLen(4))
+			Expect(mapping.GetIndexes("ChekhovsGun")).To(Equal([]trope.TropeIndex{trope.GenreTrope, trope.NarrativeTrope}))
+			Expect(mapping.GetIndexes("SpaceOpera")).To(Equal([]trope.TropeIndex{trope.GenreTrope}))
+			Expect(mapping.GetIndexes("InMediasRes")).To(Equal([]trope.TropeIndex{trope.NarrativeTrope}))
+			Expect(mapping.GetIndexes("RedHerring")).To(Equal([]trope.TropeIndex{trope.NarrativeTrope}))
+		})
+
+		It("Shouldn't classify the indexes as tropes", func() {
+			Expect(mapping).To(Not(HaveKey("ForeshadowingTropes")))
+			Expect(mapping).To(Not(HaveKey("NarrativeTropes")))
+			Expect(mapping.GetIndexes("UnlistedTrope")).To(BeEmpty())
+		})
+
+		It("Shouldn't follow sub-indexes deeper than the maximum depth", func() {
+			_, requested := indexFetcher.requested.Load(mainUrl + "ChekhovsGunnerTropes")
+			Expect(requested).To(BeFalse())
+		})
+
+		It("Should return the errors of the top-level indexes that couldn't be crawled", func() {
+			Expect(errors.Is(errIndexes, crawler.ErrNotFound)).To(BeTrue())
+			Expect(errors.Is(errIndexes, crawler.ErrCrawling)).To(BeTrue())
+		})
+
+		It("Should report the indexes that couldn't be crawled", func() {
+			Expect(failedPages).To(HaveLen(3))
+			Expect(failedPages).To(HaveKey(mainUrl + "MediaTropes"))
+			Expect(failedPages).To(HaveKey(mainUrl + "TopicalTropes"))
+			Expect(failedPages).To(HaveKey(mainUrl + "MysteryTropes"))
+		})
+
+		It("Should cache the mapping on a file until it's too old", func() {
+			mappingPath := filepath.Join(GinkgoT().TempDir(), "indexes.json")
+			Expect(crawler.SaveIndexMapping(mappingPath, mapping)).To(Succeed())
+
+			loadedMapping, errLoad := crawler.LoadIndexMapping(mappingPath, time.Hour)
+			Expect(errLoad).To(BeNil())
+			Expect(loadedMapping).To(Equal(mapping))
+
+			_, errStale := crawler.LoadIndexMapping(mappingPath, time.Nanosecond)
+			Expect(errors.Is(errStale, crawler.ErrStaleIndexMapping)).To(BeTrue())
+		})
+	})
+
+	Context("Loading a Frontier that doesn't exist", func() {
+		It("Should return an appropriate error", func() {
+			_, errLoad := crawler.LoadFrontier(filepath.Join(GinkgoT().TempDir(), "missing.state.json"))
+			Expect(errors.Is(errLoad, crawler.ErrLoadFrontier)).To(BeTrue())
+		})
+	})
+
+	Context("Creating a crawler without a fetcher", func() {
+		var errNilFetcher error
+
+		BeforeEach(func() {
+			_, errNilFetcher = crawler.NewCrawler(crawler.ConfigFetcher(nil))
+		})
+
+		It("Should return an appropriate error", func() {
+			Expect(errNilFetcher).To(Equal(crawler.ErrInvalidField))
+		})
+	})
+
+	Context("Crawling Work Pages with a cancelled context", func() {
+		var interruptedPages *tvtropespages.TvTropesPages
+		var errInterrupted error
+
+		BeforeEach(func() {
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+
+			interruptedPages, errInterrupted = serviceCrawler.CrawlWorkPages(ctx, 5, media.Film)
+		})
+
+		It("Should return the context error", func() {
+			Expect(errors.Is(errInterrupted, context.Canceled)).To(BeTrue())
+		})
+
+		It("Should return the empty crawled pages so they can still be persisted", func() {
+			Expect(interruptedPages).To(Not(BeNil()))
+			Expect(interruptedPages.Pages).To(BeEmpty())
+		})
+	})
+
 	Context("Extract the last updated time from an history page", func() {
 		var lastUpdated time.Time
 		var errLastUpdated error
@@ -0,0 +1,320 @@
+package crawler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jlgallego99/TropesToGo/media"
+	"github.com/jlgallego99/TropesToGo/media/dataset_file"
+)
+
+// checkpointInterval is the minimum time between two checkpoints of the frontier while crawling Work pages
+// Changes of index page and the end of a crawl are always checkpointed
+const checkpointInterval = 10 * time.Second
+
+var (
+	ErrLoadFrontier = errors.New("couldn't load the crawl state file")
+	ErrSaveFrontier = errors.New("couldn't save the crawl state file")
+)
+
+// WorkStatus is the crawling status of a single Work page on the Frontier
+type WorkStatus string
+
+const (
+	// WorkPending is a Work page found on the index that hasn't been crawled yet
+	WorkPending WorkStatus = "pending"
+
+	// WorkCrawled is a Work page that has been crawled but not handled, or whose batch hasn't been handled yet, so its data only lives in memory
+	WorkCrawled WorkStatus = "crawled"
+
+	// WorkDone is a Work page that has been crawled and handled along with its batch, so it never needs to be requested again
+	WorkDone WorkStatus = "done"
+
+	// WorkFailed is a Work page that couldn't be crawled or handled
+	WorkFailed WorkStatus = "failed"
+)
+
+// Frontier is the checkpointed state of a crawl over an index of Work pages, so it can be resumed after it stops
+// It holds the media types to crawl with their limits and the ones whose index has been fully crawled,
+// the media type and index page being crawled, the next index page, the Work pages of the current index that are still pending
+// and the status of every visited Work page, and it's periodically saved on a JSON state file
+type Frontier struct {
+	mutex sync.Mutex
+
+	// path of the JSON state file
+	path string
+
+	// lastSaved is the last time the frontier was written on the state file
+	lastSaved time.Time
+
+	state frontierState
+}
+
+// frontierState is the intermediate structure for marshalling/unmarshalling the Frontier on the state file
+type frontierState struct {
+	Media         []frontierMedia `json:"media"`
+	FinishedMedia []string        `json:"finished_media"`
+
+	MediaType    string                `json:"mediatype"`
+	IndexURL     string                `json:"index_url"`
+	NextIndexURL string                `json:"next_index_url"`
+	Pending      []string              `json:"pending"`
+	Works        map[string]WorkStatus `json:"works"`
+}
+
+// frontierMedia is a media type to crawl with its limit on the state file
+type frontierMedia struct {
+	MediaType string `json:"mediatype"`
+	Limit     int    `json:"limit"`
+}
+
+// NewFrontier creates an empty Frontier that will be saved on the state file at path
+// The state file isn't written until the crawl starts
+func NewFrontier(path string) *Frontier {
+	return &Frontier{
+		path: path,
+		state: frontierState{
+			Pending: make([]string, 0),
+			Works:   make(map[string]WorkStatus),
+		},
+	}
+}
+
+// LoadFrontier reads the Frontier saved on the state file at path, so the crawl can be resumed
+// It returns an ErrLoadFrontier error if the file doesn't exist or doesn't hold a valid crawl state
+func LoadFrontier(path string) (*Frontier, error) {
+	fileContents, errRead := os.ReadFile(path)
+	if errRead != nil {
+		return nil, fmt.Errorf("%w: "+path+"\n%w", ErrLoadFrontier, errRead)
+	}
+
+	frontier := NewFrontier(path)
+	if errUnmarshal := json.Unmarshal(fileContents, &frontier.state); errUnmarshal != nil {
+		return nil, fmt.Errorf("%w: "+path+"\n%w", ErrLoadFrontier, errUnmarshal)
+	}
+
+	if frontier.state.Works == nil {
+		frontier.state.Works = make(map[string]WorkStatus)
+	}
+
+	return frontier, nil
+}
+
+// GetMediaType returns the MediaType of the crawled index
+// It returns an ErrUnknownMediaType error if the crawl hasn't started yet
+func (frontier *Frontier) GetMediaType() (media.MediaType, error) {
+	frontier.mutex.Lock()
+	defer frontier.mutex.Unlock()
+
+	return media.ToMediaType(frontier.state.MediaType)
+}
+
+// GetMediaLimits returns the media types of the crawl with their limits, in the same order they are crawled
+// It's empty if the crawl isn't over several media types or it hasn't started yet
+func (frontier *Frontier) GetMediaLimits() []MediaLimit {
+	frontier.mutex.Lock()
+	defer frontier.mutex.Unlock()
+
+	mediaLimits := make([]MediaLimit, 0, len(frontier.state.Media))
+	for _, stateMedia := range frontier.state.Media {
+		if mediaType, errMediaType := media.ToMediaType(stateMedia.MediaType); errMediaType == nil {
+			mediaLimits = append(mediaLimits, MediaLimit{MediaType: mediaType, Limit: stateMedia.Limit})
+		}
+	}
+
+	return mediaLimits
+}
+
+// GetWorkStatus returns the status of the workUrl Work page, which is WorkPending if it hasn't been visited
+func (frontier *Frontier) GetWorkStatus(workUrl string) WorkStatus {
+	frontier.mutex.Lock()
+	defer frontier.mutex.Unlock()
+
+	if status, visited := frontier.state.Works[workUrl]; visited {
+		return status
+	}
+
+	return WorkPending
+}
+
+// CountWorks returns the number of visited Work pages that have the status
+func (frontier *Frontier) CountWorks(status WorkStatus) int {
+	frontier.mutex.Lock()
+	defer frontier.mutex.Unlock()
+
+	count := 0
+	for _, workStatus := range frontier.state.Works {
+		if workStatus == status {
+			count++
+		}
+	}
+
+	return count
+}
+
+// countMediaWorks returns the number of visited Work pages of the mediaType that have the status
+// The media type of a Work page is the namespace of its URL
+func (frontier *Frontier) countMediaWorks(mediaType media.MediaType, status WorkStatus) int {
+	frontier.mutex.Lock()
+	defer frontier.mutex.Unlock()
+
+	count := 0
+	for workUrl, workStatus := range frontier.state.Works {
+		if workStatus == status && strings.EqualFold(workNamespace(workUrl), mediaType.String()) {
+			count++
+		}
+	}
+
+	return count
+}
+
+// Save writes the Frontier on its state file, replacing it atomically so a crash never leaves a half-written state
+// It returns an ErrSaveFrontier error if the file couldn't be written
+func (frontier *Frontier) Save() error {
+	frontier.mutex.Lock()
+	defer frontier.mutex.Unlock()
+
+	return frontier.save()
+}
+
+// Remove deletes the state file, because the crawl has finished and there's nothing to resume
+func (frontier *Frontier) Remove() error {
+	frontier.mutex.Lock()
+	defer frontier.mutex.Unlock()
+
+	if errRemove := os.Remove(frontier.path); errRemove != nil && !errors.Is(errRemove, os.ErrNotExist) {
+		return fmt.Errorf("%w: "+frontier.path+"\n%w", ErrSaveFrontier, errRemove)
+	}
+
+	return nil
+}
+
+// getIndex returns the media type and index page being crawled, the next index page
+// and the Work pages of the current index that are still pending
+func (frontier *Frontier) getIndex() (string, string, string, []string) {
+	frontier.mutex.Lock()
+	defer frontier.mutex.Unlock()
+
+	pending := make([]string, len(frontier.state.Pending))
+	copy(pending, frontier.state.Pending)
+
+	return frontier.state.MediaType, frontier.state.IndexURL, frontier.state.NextIndexURL, pending
+}
+
+// setMediaLimits records the media types of the crawl with their limits and saves the Frontier
+func (frontier *Frontier) setMediaLimits(mediaLimits []MediaLimit) error {
+	frontier.mutex.Lock()
+	defer frontier.mutex.Unlock()
+
+	frontier.state.Media = make([]frontierMedia, 0, len(mediaLimits))
+	for _, mediaLimit := range mediaLimits {
+		frontier.state.Media = append(frontier.state.Media, frontierMedia{MediaType: mediaLimit.MediaType.String(), Limit: mediaLimit.Limit})
+	}
+
+	return frontier.save()
+}
+
+// isMediaFinished checks if the whole index of the mediaType has already been crawled
+func (frontier *Frontier) isMediaFinished(mediaType media.MediaType) bool {
+	frontier.mutex.Lock()
+	defer frontier.mutex.Unlock()
+
+	for _, finishedMedia := range frontier.state.FinishedMedia {
+		if finishedMedia == mediaType.String() {
+			return true
+		}
+	}
+
+	return false
+}
+
+// finishMedia records that the whole index of the mediaType has been crawled, so there's no current index page, and saves the Frontier
+func (frontier *Frontier) finishMedia(mediaType media.MediaType) error {
+	frontier.mutex.Lock()
+	defer frontier.mutex.Unlock()
+
+	frontier.state.FinishedMedia = append(frontier.state.FinishedMedia, mediaType.String())
+	frontier.state.IndexURL = ""
+	frontier.state.NextIndexURL = ""
+	frontier.state.Pending = make([]string, 0)
+
+	return frontier.save()
+}
+
+// startIndex records that a new index page of the mediaType is being crawled, with all its workUrls pending, and saves the Frontier
+// Work pages that are already done are not pending again
+func (frontier *Frontier) startIndex(mediaType media.MediaType, indexUrl, nextIndexUrl string, workUrls []string) error {
+	frontier.mutex.Lock()
+	defer frontier.mutex.Unlock()
+
+	frontier.state.MediaType = mediaType.String()
+	frontier.state.IndexURL = indexUrl
+	frontier.state.NextIndexURL = nextIndexUrl
+	frontier.state.Pending = make([]string, 0, len(workUrls))
+	for _, workUrl := range workUrls {
+		if frontier.state.Works[workUrl] != WorkDone {
+			frontier.state.Pending = append(frontier.state.Pending, workUrl)
+		}
+	}
+
+	return frontier.save()
+}
+
+// setWorkStatus records the status of the workUrl Work page, removing it from the pending ones if it's no longer pending
+// The Frontier is saved if enough time has passed since the last checkpoint
+func (frontier *Frontier) setWorkStatus(workUrl string, status WorkStatus) error {
+	frontier.mutex.Lock()
+	defer frontier.mutex.Unlock()
+
+	frontier.state.Works[workUrl] = status
+	if status != WorkPending {
+		for pos, pendingUrl := range frontier.state.Pending {
+			if pendingUrl == workUrl {
+				frontier.state.Pending = append(frontier.state.Pending[:pos], frontier.state.Pending[pos+1:]...)
+				break
+			}
+		}
+	}
+
+	if time.Since(frontier.lastSaved) < checkpointInterval {
+		return nil
+	}
+
+	return frontier.save()
+}
+
+// save replaces the state file with the current state, must be called with the mutex locked
+func (frontier *Frontier) save() error {
+	stateBytes, errMarshal := json.Marshal(frontier.state)
+	if errMarshal != nil {
+		return fmt.Errorf("%w: "+frontier.path+"\n%w", ErrSaveFrontier, errMarshal)
+	}
+
+	if errWrite := dataset_file.WriteFile(frontier.path, stateBytes); errWrite != nil {
+		return fmt.Errorf("%w: "+frontier.path+"\n%w", ErrSaveFrontier, errWrite)
+	}
+
+	frontier.lastSaved = time.Now()
+	return nil
+}
+
+// workNamespace returns the namespace of a Work page URL, which is its media type, or an empty string if it doesn't have one
+func workNamespace(workUrl string) string {
+	parsedUrl, errParse := url.Parse(workUrl)
+	if errParse != nil {
+		return ""
+	}
+
+	splitPath := strings.Split(parsedUrl.Path, "/")
+	if len(splitPath) < 5 {
+		return ""
+	}
+
+	return splitPath[3]
+}
@@ -0,0 +1,218 @@
+package crawler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/jlgallego99/TropesToGo/media/dataset_file"
+	"github.com/jlgallego99/TropesToGo/trope"
+	"github.com/jlgallego99/TropesToGo/tvtropespages"
+	"github.com/rs/zerolog/log"
+)
+
+var (
+	ErrLoadIndexMapping  = errors.New("couldn't load the trope index mapping file")
+	ErrSaveIndexMapping  = errors.New("couldn't save the trope index mapping file")
+	ErrStaleIndexMapping = errors.New("the trope index mapping file is older than its maximum age")
+
+	// subIndexSuffixes are the endings of the IDs of the Main pages that are indexes of tropes instead of tropes
+	subIndexSuffixes = []string{"Tropes", "Index", "Indexes", "Indices"}
+)
+
+// indexMappingFile is the intermediate structure for marshalling/unmarshalling an IndexMapping on its cache file
+type indexMappingFile struct {
+	Created time.Time           `json:"created"`
+	Tropes  map[string][]string `json:"tropes"`
+}
+
+// indexPage is a pending index page of the index hierarchy with its depth from the top-level index
+type indexPage struct {
+	id    string
+	depth int
+}
+
+// CrawlTropeIndexes crawls the top-level trope indexes of TvTropes (Genre, Media, Narrative and Topical tropes) and their sub-indexes
+// and classifies every trope listed on them with the top-level index they descend from, so a trope can belong to several indexes
+// Sub-indexes are followed up to maxDepth levels below the top-level index, and the links between top-level indexes aren't followed
+// Sub-indexes that can't be crawled are skipped and reported on GetFailedPages, and if a top-level index can't be crawled,
+// it returns the mapping of the rest of them along with the errors of the failed top-level indexes
+// If the ctx context is cancelled, it stops crawling and returns the mapping until then along with the context error
+func (crawler *ServiceCrawler) CrawlTropeIndexes(ctx context.Context, maxDepth int) (trope.IndexMapping, error) {
+	mapping := trope.NewIndexMapping()
+
+	topLevelIds := make(map[string]bool, len(trope.TopLevelIndexes))
+	for _, indexId := range trope.TopLevelIndexes {
+		topLevelIds[indexId] = true
+	}
+
+	var errTopLevel []error
+	for index := trope.UnknownTropeIndex + 1; index <= trope.TopicalTrope; index++ {
+		log.Info().Msg("CRAWLING INDEX " + index.String())
+
+		visited := map[string]bool{trope.TopLevelIndexes[index]: true}
+		pending := []indexPage{{id: trope.TopLevelIndexes[index], depth: 0}}
+		for len(pending) > 0 {
+			current := pending[0]
+			pending = pending[1:]
+
+			tropeIds, subIndexIds, errIndex := crawler.crawlTropeIndexPage(ctx, current.id)
+			if ctx.Err() != nil {
+				return mapping, ctx.Err()
+			}
+
+			if errIndex != nil {
+				log.Error().Err(errIndex).Msg("CRAWLING INDEX FAILED " + current.id)
+				crawler.addFailedPage(TvTropesWeb+tvtropespages.TvTropesMainPath+current.id, errIndex)
+				if current.depth == 0 {
+					errTopLevel = append(errTopLevel, errIndex)
+				}
+
+				continue
+			}
+
+			for _, tropeId := range tropeIds {
+				mapping.Add(tropeId, index)
+			}
+
+			if current.depth >= maxDepth {
+				continue
+			}
+
+			for _, subIndexId := range subIndexIds {
+				if !visited[subIndexId] && !topLevelIds[subIndexId] {
+					visited[subIndexId] = true
+					pending = append(pending, indexPage{id: subIndexId, depth: current.depth + 1})
+				}
+			}
+		}
+	}
+
+	return mapping, errors.Join(errTopLevel...)
+}
+
+// crawlTropeIndexPage crawls the Main page of the indexId index and returns the IDs of the tropes and the sub-indexes listed on it
+// It returns an ErrNotFound error if the page can't be retrieved, or an ErrCrawling error if it doesn't list anything
+func (crawler *ServiceCrawler) crawlTropeIndexPage(ctx context.Context, indexId string) ([]string, []string, error) {
+	indexUrl := TvTropesWeb + tvtropespages.TvTropesMainPath + indexId
+	if errWait := crawler.wait(ctx, indexUrl); errWait != nil {
+		return nil, nil, errWait
+	}
+
+	resp, errFetch := crawler.fetcher.Fetch(ctx, indexUrl)
+	if errFetch != nil {
+		return nil, nil, fmt.Errorf("%w: "+indexUrl+"\n%w", ErrNotFound, errFetch)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, nil, fmt.Errorf("%w: "+indexUrl+" (status %d)", ErrNotFound, resp.StatusCode)
+	}
+
+	doc, errDocument := goquery.NewDocumentFromReader(bytes.NewReader(resp.Body))
+	if errDocument != nil {
+		return nil, nil, fmt.Errorf("%w: "+indexUrl, ErrParse)
+	}
+
+	if doc.Find(crawler.profile.Crawler.IndexTropeLink).Length() == 0 {
+		return nil, nil, fmt.Errorf("%w: "+indexUrl, ErrCrawling)
+	}
+
+	var tropeIds, subIndexIds []string
+	found := make(map[string]bool)
+	doc.Find(crawler.profile.Crawler.IndexTropeLink).Each(func(_ int, selection *goquery.Selection) {
+		href, _ := selection.Attr("href")
+		href = strings.TrimPrefix(href, TvTropesWeb)
+		if !strings.HasPrefix(href, tvtropespages.TvTropesMainPath) {
+			return
+		}
+
+		linkedId := strings.TrimPrefix(href, tvtropespages.TvTropesMainPath)
+		if linkedId == "" || strings.ContainsAny(linkedId, "/?#") || found[linkedId] {
+			return
+		}
+		found[linkedId] = true
+
+		if isSubIndex(linkedId) {
+			subIndexIds = append(subIndexIds, linkedId)
+		} else {
+			tropeIds = append(tropeIds, linkedId)
+		}
+	})
+
+	return tropeIds, subIndexIds, nil
+}
+
+// isSubIndex checks if the Main page with the pageId is an index of tropes instead of a trope
+func isSubIndex(pageId string) bool {
+	for _, suffix := range subIndexSuffixes {
+		if strings.HasSuffix(pageId, suffix) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// SaveIndexMapping writes the mapping on the cache file at path along with the current time, so it can be reused by later runs
+// It returns an ErrSaveIndexMapping error if the file couldn't be written
+func SaveIndexMapping(path string, mapping trope.IndexMapping) error {
+	mappingFile := indexMappingFile{
+		Created: time.Now(),
+		Tropes:  make(map[string][]string, len(mapping)),
+	}
+
+	for tropeId, indexes := range mapping {
+		mappingFile.Tropes[tropeId] = indexes.GetIndexStrings()
+	}
+
+	mappingBytes, errMarshal := json.Marshal(mappingFile)
+	if errMarshal != nil {
+		return fmt.Errorf("%w: "+path+"\n%w", ErrSaveIndexMapping, errMarshal)
+	}
+
+	if errWrite := dataset_file.WriteFile(path, mappingBytes); errWrite != nil {
+		return fmt.Errorf("%w: "+path+"\n%w", ErrSaveIndexMapping, errWrite)
+	}
+
+	return nil
+}
+
+// LoadIndexMapping reads the mapping saved on the cache file at path
+// It returns an ErrStaleIndexMapping error if the file is older than maxAge, so it must be crawled again, unless maxAge is 0 or less
+// It returns an ErrLoadIndexMapping error if the file doesn't exist or doesn't hold a valid mapping
+func LoadIndexMapping(path string, maxAge time.Duration) (trope.IndexMapping, error) {
+	fileContents, errRead := os.ReadFile(path)
+	if errRead != nil {
+		return nil, fmt.Errorf("%w: "+path+"\n%w", ErrLoadIndexMapping, errRead)
+	}
+
+	var mappingFile indexMappingFile
+	if errUnmarshal := json.Unmarshal(fileContents, &mappingFile); errUnmarshal != nil {
+		return nil, fmt.Errorf("%w: "+path+"\n%w", ErrLoadIndexMapping, errUnmarshal)
+	}
+
+	if maxAge > 0 && time.Since(mappingFile.Created) > maxAge {
+		return nil, fmt.Errorf("%w: "+path, ErrStaleIndexMapping)
+	}
+
+	mapping := trope.NewIndexMapping()
+	for tropeId, indexStrings := range mappingFile.Tropes {
+		for _, indexString := range indexStrings {
+			index, errIndex := trope.ToTropeIndex(indexString)
+			if errIndex != nil {
+				return nil, fmt.Errorf("%w: "+path+"\n%w", ErrLoadIndexMapping, errIndex)
+			}
+
+			mapping.Add(tropeId, index)
+		}
+	}
+
+	return mapping, nil
+}
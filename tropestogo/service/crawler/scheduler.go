@@ -0,0 +1,94 @@
+package crawler
+
+import (
+	"context"
+	"github.com/jlgallego99/TropesToGo/service/fetcher"
+	"math/rand"
+	"net/url"
+	"sync"
+	"time"
+)
+
+const (
+	// Default random waiting time between two requests to the same host
+	defaultMinWaitingTime = 500 * time.Millisecond
+	defaultMaxWaitingTime = 2 * time.Second
+)
+
+// scheduler is the politeness policy shared by all the crawler workers
+// It spaces out the requests made to the same host with a random waiting time, no matter how many workers are crawling
+type scheduler struct {
+	mutex sync.Mutex
+
+	// minWaitingTime and maxWaitingTime are the bounds of the random waiting time between two requests to a host
+	minWaitingTime time.Duration
+	maxWaitingTime time.Duration
+
+	// nextRequest relates each host with the earliest time the next request to it can be made
+	nextRequest map[string]time.Time
+
+	// seededRand generates the random waiting times, guarded by the mutex because it isn't safe for concurrent use
+	seededRand *rand.Rand
+}
+
+// newScheduler creates a scheduler that waits between minWaitingTime and maxWaitingTime between requests to the same host
+func newScheduler(minWaitingTime, maxWaitingTime time.Duration) *scheduler {
+	if maxWaitingTime < minWaitingTime {
+		maxWaitingTime = minWaitingTime
+	}
+
+	return &scheduler{
+		minWaitingTime: minWaitingTime,
+		maxWaitingTime: maxWaitingTime,
+		nextRequest:    make(map[string]time.Time),
+		seededRand:     rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+}
+
+// Wait blocks until a request to the host of pageUrl is allowed, reserving that turn for the caller
+// It returns the context error if the ctx context is cancelled while waiting
+func (scheduler *scheduler) Wait(ctx context.Context, pageUrl string) error {
+	host := hostOf(pageUrl)
+
+	scheduler.mutex.Lock()
+	turn := scheduler.nextRequest[host]
+	if now := time.Now(); turn.Before(now) {
+		turn = now
+	}
+	scheduler.nextRequest[host] = turn.Add(scheduler.randomWaitingTime())
+	scheduler.mutex.Unlock()
+
+	return fetcher.Sleep(ctx, time.Until(turn))
+}
+
+// Pause delays all the following requests to the host of pageUrl at least by the waiting duration
+// It is used when the host starts denying requests, so all workers back off at the same time
+func (scheduler *scheduler) Pause(pageUrl string, waiting time.Duration) {
+	host := hostOf(pageUrl)
+
+	scheduler.mutex.Lock()
+	defer scheduler.mutex.Unlock()
+
+	if resume := time.Now().Add(waiting); scheduler.nextRequest[host].Before(resume) {
+		scheduler.nextRequest[host] = resume
+	}
+}
+
+// randomWaitingTime returns a random duration between the minimum and maximum waiting times
+func (scheduler *scheduler) randomWaitingTime() time.Duration {
+	if scheduler.maxWaitingTime == scheduler.minWaitingTime {
+		return scheduler.minWaitingTime
+	}
+
+	return scheduler.minWaitingTime + time.Duration(scheduler.seededRand.Int63n(int64(scheduler.maxWaitingTime-scheduler.minWaitingTime)))
+}
+
+// hostOf returns the hostname of a URL string, or the string itself if it can't be parsed
+func hostOf(pageUrl string) string {
+	parsedUrl, errParse := url.Parse(pageUrl)
+	if errParse != nil {
+		return pageUrl
+	}
+
+	return parsedUrl.Hostname()
+}
@@ -0,0 +1,306 @@
+package fetcher
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// File extensions of the two files of every cached response
+	cacheMetadataExtension = ".json"
+	cacheBodyExtension     = ".html"
+)
+
+var (
+	ErrCache     = errors.New("couldn't use the cache directory")
+	ErrNotCached = errors.New("the page isn't cached and requests are not allowed while offline")
+)
+
+// CachedFetcher is a Fetcher that can tell beforehand if a page will be served without making any request
+// It allows the crawler to skip its waiting time between requests for pages that won't be requested
+type CachedFetcher interface {
+	Fetcher
+
+	// IsCached checks if the pageUrl will be served without requesting it
+	IsCached(pageUrl string) bool
+}
+
+// CacheFetcherConfig is an alias for a function that will accept a pointer to a CacheFetcher and modify its fields
+// Each function acts as one configuration for the fetcher
+type CacheFetcherConfig func(cf *CacheFetcher) error
+
+// CacheFetcher implements the Fetcher interface by storing the successful responses of another Fetcher on a directory
+// Every page is stored on two files named after its canonical URL, one with the status code, headers and storing time
+// and another one with the raw body, so the cached pages can be inspected
+// Cached pages younger than the max age are served without any request, while older ones are revalidated with
+// If-None-Match and If-Modified-Since headers when the server sent an ETag or a Last-Modified header
+type CacheFetcher struct {
+	// fetcher requests the pages that aren't cached or must be revalidated
+	fetcher Fetcher
+
+	// dir is the directory where the responses are stored
+	dir string
+
+	// maxAge is how long a cached page is served without revalidating it
+	maxAge time.Duration
+
+	// offline makes every page be served from the cache without making any request
+	offline bool
+}
+
+// cacheEntry is the stored metadata of a cached response
+type cacheEntry struct {
+	URL        string      `json:"url"`
+	StatusCode int         `json:"status_code"`
+	Header     http.Header `json:"header"`
+	StoredAt   time.Time   `json:"stored_at"`
+}
+
+// NewCacheFetcher takes the Fetcher whose responses will be cached on the dir directory and a variable amount of configuration functions,
+// applies them and returns a CacheFetcher with all configs passed
+// By default, cached pages are always revalidated
+// It returns an ErrInvalidField error if the pageFetcher is nil or the dir is empty, or an ErrCache error if the directory can't be created
+func NewCacheFetcher(pageFetcher Fetcher, dir string, cfgs ...CacheFetcherConfig) (*CacheFetcher, error) {
+	if pageFetcher == nil || dir == "" {
+		return nil, ErrInvalidField
+	}
+
+	if errMkdir := os.MkdirAll(dir, 0755); errMkdir != nil {
+		return nil, fmt.Errorf("%w: "+dir+"\n%w", ErrCache, errMkdir)
+	}
+
+	cf := &CacheFetcher{
+		fetcher: pageFetcher,
+		dir:     dir,
+	}
+
+	for _, cfg := range cfgs {
+		err := cfg(cf)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return cf, nil
+}
+
+// ConfigMaxAge defines how long a cached page is served without revalidating it with the server
+// It returns an ErrInvalidField error if it's negative
+func ConfigMaxAge(maxAge time.Duration) CacheFetcherConfig {
+	return func(cf *CacheFetcher) error {
+		if maxAge < 0 {
+			return ErrInvalidField
+		}
+
+		cf.maxAge = maxAge
+		return nil
+	}
+}
+
+// ConfigOffline makes the CacheFetcher serve every page from the cache, no matter how old it is, without making any request
+func ConfigOffline() CacheFetcherConfig {
+	return func(cf *CacheFetcher) error {
+		cf.offline = true
+		return nil
+	}
+}
+
+// Fetch serves the pageUrl from the cache if it's fresh, and otherwise requests or revalidates it and stores the response
+// Only successful responses are stored, and a revalidated page that hasn't changed is served from the cache
+// While offline, it returns an ErrNotCached error if the page isn't cached
+func (cf *CacheFetcher) Fetch(ctx context.Context, pageUrl string) (*Response, error) {
+	key := cacheKey(pageUrl)
+
+	entry, body, errLoad := cf.load(key)
+	cached := errLoad == nil
+	if cached && (cf.offline || time.Since(entry.StoredAt) <= cf.maxAge) {
+		return entry.toResponse(pageUrl, body), nil
+	}
+
+	if cf.offline {
+		return nil, fmt.Errorf("%w: "+pageUrl, ErrNotCached)
+	}
+
+	// Revalidate the cached page if the server sent any validator
+	header := http.Header{}
+	if cached {
+		if etag := entry.Header.Get("ETag"); etag != "" {
+			header.Set("If-None-Match", etag)
+		}
+		if lastModified := entry.Header.Get("Last-Modified"); lastModified != "" {
+			header.Set("If-Modified-Since", lastModified)
+		}
+	}
+
+	response, errFetch := fetchWithHeader(ctx, cf.fetcher, pageUrl, header)
+	if errFetch != nil {
+		return nil, errFetch
+	}
+
+	if response.StatusCode == http.StatusNotModified && cached {
+		for name, values := range response.Header {
+			entry.Header[name] = values
+		}
+		entry.StoredAt = time.Now()
+
+		if errStore := cf.storeMetadata(key, entry); errStore != nil {
+			log.Warn().Err(errStore).Msg("Couldn't refresh the cached page " + pageUrl)
+		}
+
+		revalidated := entry.toResponse(pageUrl, body)
+		revalidated.Attempts = response.Attempts
+		revalidated.Cached = false
+
+		return revalidated, nil
+	}
+
+	if response.StatusCode == http.StatusOK {
+		newEntry := cacheEntry{
+			URL:        pageUrl,
+			StatusCode: response.StatusCode,
+			Header:     response.Header,
+			StoredAt:   time.Now(),
+		}
+
+		if errStore := cf.store(key, newEntry, response.Body); errStore != nil {
+			log.Warn().Err(errStore).Msg("Couldn't cache the page " + pageUrl)
+		}
+	}
+
+	return response, nil
+}
+
+// IsCached checks if the pageUrl will be served from the cache without requesting it, because it's fresh or the fetcher is offline
+func (cf *CacheFetcher) IsCached(pageUrl string) bool {
+	if cf.offline {
+		return true
+	}
+
+	entry, _, errLoad := cf.load(cacheKey(pageUrl))
+	return errLoad == nil && time.Since(entry.StoredAt) <= cf.maxAge
+}
+
+// load reads the metadata and the body of the response stored with the key
+func (cf *CacheFetcher) load(key string) (cacheEntry, []byte, error) {
+	var entry cacheEntry
+
+	metadata, errMetadata := os.ReadFile(filepath.Join(cf.dir, key+cacheMetadataExtension))
+	if errMetadata != nil {
+		return cacheEntry{}, nil, errMetadata
+	}
+
+	if errUnmarshal := json.Unmarshal(metadata, &entry); errUnmarshal != nil {
+		return cacheEntry{}, nil, errUnmarshal
+	}
+
+	body, errBody := os.ReadFile(filepath.Join(cf.dir, key+cacheBodyExtension))
+	if errBody != nil {
+		return cacheEntry{}, nil, errBody
+	}
+
+	if entry.Header == nil {
+		entry.Header = http.Header{}
+	}
+
+	return entry, body, nil
+}
+
+// store writes the body and the metadata of a response with the key
+// The metadata is written last, so a cached page is never loaded with a partially written body
+func (cf *CacheFetcher) store(key string, entry cacheEntry, body []byte) error {
+	if errBody := writeCacheFile(filepath.Join(cf.dir, key+cacheBodyExtension), body); errBody != nil {
+		return fmt.Errorf("%w: "+entry.URL+"\n%w", ErrCache, errBody)
+	}
+
+	return cf.storeMetadata(key, entry)
+}
+
+// storeMetadata writes the metadata of a response with the key
+func (cf *CacheFetcher) storeMetadata(key string, entry cacheEntry) error {
+	metadata, errMarshal := json.Marshal(entry)
+	if errMarshal != nil {
+		return fmt.Errorf("%w: "+entry.URL+"\n%w", ErrCache, errMarshal)
+	}
+
+	if errMetadata := writeCacheFile(filepath.Join(cf.dir, key+cacheMetadataExtension), metadata); errMetadata != nil {
+		return fmt.Errorf("%w: "+entry.URL+"\n%w", ErrCache, errMetadata)
+	}
+
+	return nil
+}
+
+// writeCacheFile writes the data on a temporary file of the cache directory and renames it to the name,
+// so a cache file is never read half written. It isn't synced, because a lost cache file is only requested again
+func writeCacheFile(name string, data []byte) error {
+	tempFile, errCreate := os.CreateTemp(filepath.Dir(name), filepath.Base(name)+".*.tmp")
+	if errCreate != nil {
+		return errCreate
+	}
+
+	_, errWrite := tempFile.Write(data)
+	if errClose := tempFile.Close(); errWrite == nil {
+		errWrite = errClose
+	}
+
+	// The temporary file is only readable by its owner, unlike the rest of the files
+	if errWrite == nil {
+		errWrite = os.Chmod(tempFile.Name(), 0644)
+	}
+
+	if errWrite == nil {
+		errWrite = os.Rename(tempFile.Name(), name)
+	}
+
+	if errWrite != nil {
+		os.Remove(tempFile.Name())
+	}
+
+	return errWrite
+}
+
+// toResponse forms the Response of a cached page
+func (entry cacheEntry) toResponse(pageUrl string, body []byte) *Response {
+	return &Response{
+		URL:        pageUrl,
+		StatusCode: entry.StatusCode,
+		Header:     entry.Header,
+		Body:       body,
+		Cached:     true,
+	}
+}
+
+// cacheKey returns the name of the cache files of a page, which is the hash of its canonical URL
+func cacheKey(pageUrl string) string {
+	hash := sha256.Sum256([]byte(CanonicalURL(pageUrl)))
+	return hex.EncodeToString(hash[:])
+}
+
+// CanonicalURL normalizes a pageUrl so the different ways of writing the URL of the same page are equal
+// The scheme is always HTTPS, the host is lowercase and without the www prefix, the query parameters are sorted
+// and the fragment is removed
+// If the pageUrl can't be parsed, it's returned as is
+func CanonicalURL(pageUrl string) string {
+	parsedUrl, errParse := url.Parse(strings.TrimSpace(pageUrl))
+	if errParse != nil || parsedUrl.Host == "" {
+		return pageUrl
+	}
+
+	parsedUrl.Scheme = "https"
+	parsedUrl.Host = strings.TrimPrefix(strings.ToLower(parsedUrl.Host), "www.")
+	parsedUrl.Fragment = ""
+	parsedUrl.RawQuery = parsedUrl.Query().Encode()
+
+	return parsedUrl.String()
+}
@@ -0,0 +1,175 @@
+package fetcher_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"time"
+
+	"github.com/jlgallego99/TropesToGo/service/fetcher"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+const pageETag = `"oldboy-v1"`
+
+var _ = Describe("CacheFetcher", func() {
+	var server *httptest.Server
+	var httpFetcher *fetcher.HTTPFetcher
+	var cacheDir string
+	var requests, revalidations int32
+
+	BeforeEach(func() {
+		atomic.StoreInt32(&requests, 0)
+		atomic.StoreInt32(&revalidations, 0)
+		cacheDir = GinkgoT().TempDir()
+
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			atomic.AddInt32(&requests, 1)
+
+			if r.URL.Path == "/forbidden" {
+				w.WriteHeader(http.StatusForbidden)
+				return
+			}
+
+			if r.Header.Get("If-None-Match") == pageETag {
+				atomic.AddInt32(&revalidations, 1)
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+
+			w.Header().Set("ETag", pageETag)
+			w.Write([]byte(pageContents))
+		}))
+
+		httpFetcher, _ = fetcher.NewHTTPFetcher(fetcher.ConfigClient(server.Client()))
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	Context("Fetch a page twice while it's fresh", func() {
+		var firstResponse, secondResponse *fetcher.Response
+		var errNewFetcher, errFirst, errSecond error
+		var isCached bool
+
+		BeforeEach(func() {
+			var cacheFetcher *fetcher.CacheFetcher
+			cacheFetcher, errNewFetcher = fetcher.NewCacheFetcher(httpFetcher, cacheDir, fetcher.ConfigMaxAge(time.Hour))
+
+			firstResponse, errFirst = cacheFetcher.Fetch(context.Background(), server.URL+"/pmwiki/pmwiki.php/Film/Oldboy2003")
+			isCached = cacheFetcher.IsCached(server.URL + "/pmwiki/pmwiki.php/Film/Oldboy2003")
+			secondResponse, errSecond = cacheFetcher.Fetch(context.Background(), server.URL+"/pmwiki/pmwiki.php/Film/Oldboy2003")
+		})
+
+		It("Shouldn't return an error", func() {
+			Expect(errNewFetcher).To(BeNil())
+			Expect(errFirst).To(BeNil())
+			Expect(errSecond).To(BeNil())
+		})
+
+		It("Should request the page only the first time", func() {
+			Expect(firstResponse.Cached).To(BeFalse())
+			Expect(secondResponse.Cached).To(BeTrue())
+			Expect(isCached).To(BeTrue())
+			Expect(atomic.LoadInt32(&requests)).To(Equal(int32(1)))
+		})
+
+		It("Should serve the same contents from the cache", func() {
+			Expect(secondResponse.StatusCode).To(Equal(http.StatusOK))
+			Expect(secondResponse.Body).To(Equal(firstResponse.Body))
+			Expect(secondResponse.Header.Get("ETag")).To(Equal(pageETag))
+		})
+	})
+
+	Context("Fetch a cached page that must be revalidated", func() {
+		var response *fetcher.Response
+		var errFetch error
+		var isCached bool
+
+		BeforeEach(func() {
+			cacheFetcher, _ := fetcher.NewCacheFetcher(httpFetcher, cacheDir)
+
+			cacheFetcher.Fetch(context.Background(), server.URL+"/pmwiki/pmwiki.php/Film/Oldboy2003")
+			isCached = cacheFetcher.IsCached(server.URL + "/pmwiki/pmwiki.php/Film/Oldboy2003")
+			response, errFetch = cacheFetcher.Fetch(context.Background(), server.URL+"/pmwiki/pmwiki.php/Film/Oldboy2003")
+		})
+
+		It("Should revalidate it with a conditional request", func() {
+			Expect(isCached).To(BeFalse())
+			Expect(atomic.LoadInt32(&requests)).To(Equal(int32(2)))
+			Expect(atomic.LoadInt32(&revalidations)).To(Equal(int32(1)))
+		})
+
+		It("Should serve the cached contents if they haven't changed", func() {
+			Expect(errFetch).To(BeNil())
+			Expect(response.StatusCode).To(Equal(http.StatusOK))
+			Expect(string(response.Body)).To(Equal(pageContents))
+		})
+	})
+
+	Context("Fetch pages while offline", func() {
+		var cachedResponse *fetcher.Response
+		var errCached, errNotCached error
+
+		BeforeEach(func() {
+			onlineFetcher, _ := fetcher.NewCacheFetcher(httpFetcher, cacheDir)
+			onlineFetcher.Fetch(context.Background(), server.URL+"/pmwiki/pmwiki.php/Film/Oldboy2003")
+
+			offlineFetcher, _ := fetcher.NewCacheFetcher(httpFetcher, cacheDir, fetcher.ConfigOffline())
+			cachedResponse, errCached = offlineFetcher.Fetch(context.Background(), server.URL+"/pmwiki/pmwiki.php/Film/Oldboy2003")
+			_, errNotCached = offlineFetcher.Fetch(context.Background(), server.URL+"/pmwiki/pmwiki.php/Film/Parasite")
+		})
+
+		It("Should serve the cached pages without any request", func() {
+			Expect(errCached).To(BeNil())
+			Expect(string(cachedResponse.Body)).To(Equal(pageContents))
+			Expect(atomic.LoadInt32(&requests)).To(Equal(int32(1)))
+		})
+
+		It("Should return an ErrNotCached error for pages that aren't cached", func() {
+			Expect(errors.Is(errNotCached, fetcher.ErrNotCached)).To(BeTrue())
+		})
+	})
+
+	Context("Fetch a page that denies access", func() {
+		var response *fetcher.Response
+
+		BeforeEach(func() {
+			cacheFetcher, _ := fetcher.NewCacheFetcher(httpFetcher, cacheDir, fetcher.ConfigMaxAge(time.Hour))
+
+			cacheFetcher.Fetch(context.Background(), server.URL+"/forbidden")
+			response, _ = cacheFetcher.Fetch(context.Background(), server.URL+"/forbidden")
+		})
+
+		It("Shouldn't cache the response", func() {
+			Expect(response.StatusCode).To(Equal(http.StatusForbidden))
+			Expect(response.Cached).To(BeFalse())
+			Expect(atomic.LoadInt32(&requests)).To(Equal(int32(2)))
+		})
+	})
+
+	Context("Canonicalize URLs", func() {
+		It("Should consider equal the different forms of the same URL", func() {
+			Expect(fetcher.CanonicalURL("http://www.TvTropes.org/pmwiki/pmwiki.php/Film/Oldboy2003#tropes")).
+				To(Equal(fetcher.CanonicalURL("https://tvtropes.org/pmwiki/pmwiki.php/Film/Oldboy2003")))
+			Expect(fetcher.CanonicalURL("https://tvtropes.org/pmwiki/pagelist_having_pagetype_in_namespace.php?t=work&n=Film")).
+				To(Equal(fetcher.CanonicalURL("https://tvtropes.org/pmwiki/pagelist_having_pagetype_in_namespace.php?n=Film&t=work")))
+		})
+	})
+
+	Context("Create a CacheFetcher with invalid configurations", func() {
+		It("Should return an ErrInvalidField error", func() {
+			_, errNilFetcher := fetcher.NewCacheFetcher(nil, cacheDir)
+			_, errEmptyDir := fetcher.NewCacheFetcher(httpFetcher, "")
+			_, errMaxAge := fetcher.NewCacheFetcher(httpFetcher, cacheDir, fetcher.ConfigMaxAge(-time.Second))
+
+			Expect(errors.Is(errNilFetcher, fetcher.ErrInvalidField)).To(BeTrue())
+			Expect(errors.Is(errEmptyDir, fetcher.ErrInvalidField)).To(BeTrue())
+			Expect(errors.Is(errMaxAge, fetcher.ErrInvalidField)).To(BeTrue())
+		})
+	})
+})
@@ -0,0 +1,210 @@
+package fetcher
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/jlgallego99/TropesToGo/tvtropespages"
+)
+
+const (
+	// DefaultTimeout is the maximum time a whole request to TvTropes can take, including reading the response body
+	DefaultTimeout = 30 * time.Second
+
+	// Timeouts for establishing the connection with TvTropes before the response starts
+	dialTimeout           = 10 * time.Second
+	tlsHandshakeTimeout   = 10 * time.Second
+	responseHeaderTimeout = 20 * time.Second
+
+	// DefaultUserAgent identifies the crawler and how to contact its maintainers
+	DefaultUserAgent = "TropesToGo/1.0 (+https://github.com/jlgallego99/TropesToGo)"
+
+	// Accept header for HTML pages
+	acceptHeader = "text/html,application/xhtml+xml,application/xml;q=0.9,image/avif,image/webp,*/*;q=0.8"
+
+	// Common headers for a Firefox browser
+	browserUserAgentHeader        = "Mozilla/5.0 (Windows NT 10.0; Win64; x64; rv:53.0) Gecko/20100101 Firefox/53.0"
+	acceptLanguageHeader          = "es"
+	upgradeInsecureRequestsHeader = "1"
+
+	// Referer header for a well-known and trusty webpage
+	refererHeader = "https://www.google.com/"
+)
+
+var (
+	ErrInvalidField = errors.New("one or more fields for the Fetcher are invalid")
+	ErrRequest      = errors.New("couldn't make the request to the URL")
+	ErrReadBody     = errors.New("couldn't read the body of the response")
+)
+
+// Fetcher defines an interface for retrieving the contents of web pages, which is declared by the pages that are requested with it
+// It allows the crawler to request TvTropes through HTTP, a local test server, a replay source or a cache
+// sharing a common method
+type Fetcher = tvtropespages.Fetcher
+
+// HeaderFetcher is a Fetcher that can also send extra headers on a request, like the conditional headers for revalidating a cached page
+type HeaderFetcher interface {
+	Fetcher
+
+	// FetchWithHeader requests the pageUrl like Fetch, adding the header to the request
+	FetchWithHeader(ctx context.Context, pageUrl string, header http.Header) (*Response, error)
+}
+
+// Response is the retrieved contents of a web page
+type Response = tvtropespages.Response
+
+// HTTPFetcherConfig is an alias for a function that will accept a pointer to an HTTPFetcher and modify its fields
+// Each function acts as one configuration for the fetcher
+type HTTPFetcherConfig func(hf *HTTPFetcher) error
+
+// HTTPFetcher implements the Fetcher interface by making real HTTP requests
+type HTTPFetcher struct {
+	// client makes the HTTP requests, with its own timeouts, proxy and transport
+	client *http.Client
+
+	// headers are set on every request
+	headers http.Header
+}
+
+// NewHTTPFetcher takes a variable amount of configuration functions, applies them and returns an HTTPFetcher with all configs passed
+// By default, requests are made with DefaultTimeout and identify themselves with the DefaultUserAgent
+func NewHTTPFetcher(cfgs ...HTTPFetcherConfig) (*HTTPFetcher, error) {
+	hf := &HTTPFetcher{
+		client: &http.Client{
+			Timeout: DefaultTimeout,
+			Transport: &http.Transport{
+				Proxy:                 http.ProxyFromEnvironment,
+				DialContext:           (&net.Dialer{Timeout: dialTimeout}).DialContext,
+				TLSHandshakeTimeout:   tlsHandshakeTimeout,
+				ResponseHeaderTimeout: responseHeaderTimeout,
+				MaxIdleConnsPerHost:   10,
+			},
+		},
+		headers: http.Header{
+			"User-Agent": {DefaultUserAgent},
+			"Accept":     {acceptHeader},
+		},
+	}
+
+	for _, cfg := range cfgs {
+		err := cfg(hf)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return hf, nil
+}
+
+// ConfigTimeout defines the maximum time a whole request can take
+// It returns an ErrInvalidField error if the timeout isn't positive
+func ConfigTimeout(timeout time.Duration) HTTPFetcherConfig {
+	return func(hf *HTTPFetcher) error {
+		if timeout <= 0 {
+			return ErrInvalidField
+		}
+
+		hf.client.Timeout = timeout
+		return nil
+	}
+}
+
+// ConfigClient defines the HTTP client that will make the requests, so a custom proxy or transport can be used
+// It returns an ErrInvalidField error if the client is nil
+func ConfigClient(client *http.Client) HTTPFetcherConfig {
+	return func(hf *HTTPFetcher) error {
+		if client == nil {
+			return ErrInvalidField
+		}
+
+		hf.client = client
+		return nil
+	}
+}
+
+// ConfigUserAgent defines the User-Agent header sent on every request, which should identify the crawler and a way to contact its maintainers
+// It returns an ErrInvalidField error if the userAgent is empty
+func ConfigUserAgent(userAgent string) HTTPFetcherConfig {
+	return func(hf *HTTPFetcher) error {
+		if userAgent == "" {
+			return ErrInvalidField
+		}
+
+		hf.headers.Set("User-Agent", userAgent)
+		return nil
+	}
+}
+
+// ConfigBrowserHeaders makes the requests look like the ones of a Firefox browser coming from a Google search,
+// including its User-Agent, which replaces any previously configured one
+func ConfigBrowserHeaders() HTTPFetcherConfig {
+	return func(hf *HTTPFetcher) error {
+		hf.headers.Set("User-Agent", browserUserAgentHeader)
+		hf.headers.Set("Referer", refererHeader)
+		hf.headers.Set("Accept-Language", acceptLanguageHeader)
+		hf.headers.Set("Upgrade-Insecure-Requests", upgradeInsecureRequestsHeader)
+		return nil
+	}
+}
+
+// GetUserAgent returns the User-Agent header sent on every request
+func (hf *HTTPFetcher) GetUserAgent() string {
+	return hf.headers.Get("User-Agent")
+}
+
+// Fetch makes a GET request to the pageUrl with the configured headers and reads the whole response
+// It returns an ErrRequest error if the request couldn't be made or an ErrReadBody error if the body couldn't be read
+// If the ctx context is cancelled, the returned error also wraps the context error
+func (hf *HTTPFetcher) Fetch(ctx context.Context, pageUrl string) (*Response, error) {
+	return hf.FetchWithHeader(ctx, pageUrl, nil)
+}
+
+// FetchWithHeader makes the same request as Fetch, adding the header to the configured headers
+func (hf *HTTPFetcher) FetchWithHeader(ctx context.Context, pageUrl string, header http.Header) (*Response, error) {
+	request, errRequest := http.NewRequestWithContext(ctx, http.MethodGet, pageUrl, nil)
+	if errRequest != nil {
+		return nil, fmt.Errorf("%w: "+pageUrl+"\n%w", ErrRequest, errRequest)
+	}
+
+	for name, values := range hf.headers {
+		request.Header[name] = values
+	}
+
+	for name, values := range header {
+		request.Header[name] = values
+	}
+
+	httpResponse, errDoRequest := hf.client.Do(request)
+	if errDoRequest != nil {
+		return nil, fmt.Errorf("%w: "+pageUrl+"\n%w", ErrRequest, errDoRequest)
+	}
+	defer httpResponse.Body.Close()
+
+	body, errReadBody := io.ReadAll(httpResponse.Body)
+	if errReadBody != nil {
+		return nil, fmt.Errorf("%w: "+pageUrl+"\n%w", ErrReadBody, errReadBody)
+	}
+
+	return &Response{
+		URL:        pageUrl,
+		StatusCode: httpResponse.StatusCode,
+		Header:     httpResponse.Header,
+		Body:       body,
+		Attempts:   1,
+	}, nil
+}
+
+// fetchWithHeader requests the pageUrl with the pageFetcher, adding the header if the pageFetcher is a HeaderFetcher
+// Otherwise, or if there are no headers to add, it makes a plain Fetch
+func fetchWithHeader(ctx context.Context, pageFetcher Fetcher, pageUrl string, header http.Header) (*Response, error) {
+	if headerFetcher, isHeaderFetcher := pageFetcher.(HeaderFetcher); isHeaderFetcher && len(header) > 0 {
+		return headerFetcher.FetchWithHeader(ctx, pageUrl, header)
+	}
+
+	return pageFetcher.Fetch(ctx, pageUrl)
+}
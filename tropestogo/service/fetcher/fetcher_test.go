@@ -0,0 +1,146 @@
+package fetcher_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/jlgallego99/TropesToGo/service/fetcher"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+const pageContents = "<html><body><h1>Oldboy</h1></body></html>"
+
+var _ = Describe("Fetcher", func() {
+	var server *httptest.Server
+	var httpFetcher *fetcher.HTTPFetcher
+	var errNewFetcher error
+	var receivedUserAgent, receivedReferer string
+
+	BeforeEach(func() {
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			receivedUserAgent = r.Header.Get("User-Agent")
+			receivedReferer = r.Header.Get("Referer")
+
+			if r.URL.Path == "/forbidden" {
+				w.WriteHeader(http.StatusForbidden)
+				return
+			}
+
+			w.Header().Set("Content-Type", "text/html")
+			w.Write([]byte(pageContents))
+		}))
+
+		httpFetcher, errNewFetcher = fetcher.NewHTTPFetcher(fetcher.ConfigClient(server.Client()))
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	Context("Fetch a page from a server", func() {
+		var response *fetcher.Response
+		var errFetch error
+
+		BeforeEach(func() {
+			response, errFetch = httpFetcher.Fetch(context.Background(), server.URL+"/pmwiki/pmwiki.php/Film/Oldboy2003")
+		})
+
+		It("Shouldn't return an error", func() {
+			Expect(errNewFetcher).To(BeNil())
+			Expect(errFetch).To(BeNil())
+		})
+
+		It("Should return the full response", func() {
+			Expect(response.StatusCode).To(Equal(http.StatusOK))
+			Expect(response.Header.Get("Content-Type")).To(Equal("text/html"))
+			Expect(string(response.Body)).To(Equal(pageContents))
+		})
+
+		It("Should have identified itself with the default User-Agent", func() {
+			Expect(receivedUserAgent).To(Equal(fetcher.DefaultUserAgent))
+			Expect(receivedReferer).To(BeEmpty())
+		})
+	})
+
+	Context("Fetch a page that denies access", func() {
+		var response *fetcher.Response
+		var errFetch error
+
+		BeforeEach(func() {
+			response, errFetch = httpFetcher.Fetch(context.Background(), server.URL+"/forbidden")
+		})
+
+		It("Shouldn't return an error", func() {
+			Expect(errFetch).To(BeNil())
+		})
+
+		It("Should return the status code", func() {
+			Expect(response.StatusCode).To(Equal(http.StatusForbidden))
+		})
+	})
+
+	Context("Fetch a page with a cancelled context", func() {
+		var errFetch error
+
+		BeforeEach(func() {
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+
+			_, errFetch = httpFetcher.Fetch(ctx, server.URL)
+		})
+
+		It("Should return the context error", func() {
+			Expect(errors.Is(errFetch, fetcher.ErrRequest)).To(BeTrue())
+			Expect(errors.Is(errFetch, context.Canceled)).To(BeTrue())
+		})
+	})
+
+	Context("Fetch a page with a custom User-Agent", func() {
+		BeforeEach(func() {
+			customFetcher, _ := fetcher.NewHTTPFetcher(fetcher.ConfigClient(server.Client()),
+				fetcher.ConfigUserAgent("ResearchBot/2.0 (research@example.org)"))
+			customFetcher.Fetch(context.Background(), server.URL)
+		})
+
+		It("Should have sent the configured User-Agent", func() {
+			Expect(receivedUserAgent).To(Equal("ResearchBot/2.0 (research@example.org)"))
+		})
+	})
+
+	Context("Fetch a page with the browser headers", func() {
+		BeforeEach(func() {
+			browserFetcher, _ := fetcher.NewHTTPFetcher(fetcher.ConfigClient(server.Client()), fetcher.ConfigBrowserHeaders())
+			browserFetcher.Fetch(context.Background(), server.URL)
+		})
+
+		It("Should have sent the headers of a browser", func() {
+			Expect(receivedUserAgent).To(ContainSubstring("Firefox"))
+			Expect(receivedReferer).To(Not(BeEmpty()))
+		})
+	})
+
+	Context("Create a fetcher with an invalid configuration", func() {
+		var invalidFetcher *fetcher.HTTPFetcher
+		var errInvalidFetcher error
+
+		BeforeEach(func() {
+			invalidFetcher, errInvalidFetcher = fetcher.NewHTTPFetcher(fetcher.ConfigTimeout(0))
+		})
+
+		It("Should reject an empty User-Agent", func() {
+			_, errUserAgent := fetcher.NewHTTPFetcher(fetcher.ConfigUserAgent(""))
+			Expect(errUserAgent).To(Equal(fetcher.ErrInvalidField))
+		})
+
+		It("Should return an empty HTTPFetcher", func() {
+			Expect(invalidFetcher).To(BeNil())
+		})
+
+		It("Should return an appropriate error", func() {
+			Expect(errInvalidFetcher).To(Equal(fetcher.ErrInvalidField))
+		})
+	})
+})
@@ -0,0 +1,321 @@
+package fetcher
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// Default retry policy
+	defaultMaxAttempts = 4
+	defaultBaseDelay   = 2 * time.Second
+	defaultMaxDelay    = time.Minute
+
+	// maxRetryAfter is the longest Retry-After waiting time that is honored, so a wrong header can't block the crawler forever
+	maxRetryAfter = 10 * time.Minute
+
+	// Default circuit breaker policy
+	defaultBreakerThreshold = 5
+	defaultBreakerPause     = 5 * time.Minute
+)
+
+var (
+	ErrRetriesExhausted = errors.New("couldn't retrieve the URL after all the attempts")
+)
+
+// RetryError is returned by a RetryFetcher when a page couldn't be retrieved at all after every attempt
+type RetryError struct {
+	// URL is the page that couldn't be retrieved
+	URL string
+
+	// Attempts is the number of requests made to the URL
+	Attempts int
+
+	// Err is the error of the last attempt
+	Err error
+}
+
+// Error formats the RetryError with the URL and the number of attempts
+func (retryError *RetryError) Error() string {
+	return fmt.Sprintf("%s: %s after %d attempts\n%s", ErrRetriesExhausted, retryError.URL, retryError.Attempts, retryError.Err)
+}
+
+// Unwrap allows checking the RetryError against both ErrRetriesExhausted and the error of the last attempt
+func (retryError *RetryError) Unwrap() []error {
+	return []error{ErrRetriesExhausted, retryError.Err}
+}
+
+// RetryFetcherConfig is an alias for a function that will accept a pointer to a RetryFetcher and modify its fields
+// Each function acts as one configuration for the fetcher
+type RetryFetcherConfig func(rf *RetryFetcher) error
+
+// RetryFetcher implements the Fetcher interface by retrying the requests of another Fetcher
+// Requests that fail, are denied (403 and 429) or have a server error (5xx) are retried with an exponential backoff with jitter,
+// honoring the Retry-After header if the server sends it
+// It also has a circuit breaker shared by all requests, that pauses all of them when the server is clearly denying too many
+type RetryFetcher struct {
+	// fetcher makes every attempt
+	fetcher Fetcher
+
+	// maxAttempts is the maximum number of requests made to the same URL
+	maxAttempts int
+
+	// baseDelay and maxDelay are the bounds of the exponential backoff between attempts
+	baseDelay time.Duration
+	maxDelay  time.Duration
+
+	// breaker pauses all requests when too many consecutive ones are denied
+	breaker *circuitBreaker
+
+	randMutex  sync.Mutex
+	seededRand *rand.Rand
+}
+
+// NewRetryFetcher takes the Fetcher whose requests will be retried and a variable amount of configuration functions,
+// applies them and returns a RetryFetcher with all configs passed
+// It returns an ErrInvalidField error if the pageFetcher is nil
+func NewRetryFetcher(pageFetcher Fetcher, cfgs ...RetryFetcherConfig) (*RetryFetcher, error) {
+	if pageFetcher == nil {
+		return nil, ErrInvalidField
+	}
+
+	rf := &RetryFetcher{
+		fetcher:     pageFetcher,
+		maxAttempts: defaultMaxAttempts,
+		baseDelay:   defaultBaseDelay,
+		maxDelay:    defaultMaxDelay,
+		breaker:     newCircuitBreaker(defaultBreakerThreshold, defaultBreakerPause),
+		seededRand:  rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+
+	for _, cfg := range cfgs {
+		err := cfg(rf)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return rf, nil
+}
+
+// ConfigMaxAttempts defines the maximum number of requests made to the same URL, including the first one
+// It returns an ErrInvalidField error if it's less than one
+func ConfigMaxAttempts(maxAttempts int) RetryFetcherConfig {
+	return func(rf *RetryFetcher) error {
+		if maxAttempts < 1 {
+			return ErrInvalidField
+		}
+
+		rf.maxAttempts = maxAttempts
+		return nil
+	}
+}
+
+// ConfigBackoff defines the waiting time before the first retry, which is doubled on each attempt until it reaches maxDelay
+// It returns an ErrInvalidField error if the delays are negative or the base delay is greater than the maximum
+func ConfigBackoff(baseDelay, maxDelay time.Duration) RetryFetcherConfig {
+	return func(rf *RetryFetcher) error {
+		if baseDelay < 0 || maxDelay < baseDelay {
+			return ErrInvalidField
+		}
+
+		rf.baseDelay = baseDelay
+		rf.maxDelay = maxDelay
+		return nil
+	}
+}
+
+// ConfigCircuitBreaker defines after how many consecutive denied requests all requests are paused, and for how long
+// It returns an ErrInvalidField error if the threshold is less than one or the pause is negative
+func ConfigCircuitBreaker(threshold int, pause time.Duration) RetryFetcherConfig {
+	return func(rf *RetryFetcher) error {
+		if threshold < 1 || pause < 0 {
+			return ErrInvalidField
+		}
+
+		rf.breaker = newCircuitBreaker(threshold, pause)
+		return nil
+	}
+}
+
+// Fetch requests the pageUrl until it's retrieved or the maximum number of attempts is reached
+// If the last attempt still got a response, it's returned so the caller can handle its status code
+// The number of attempts made is set on the Response
+// If no attempt got a response, it returns a RetryError with the error of the last attempt
+// If the ctx context is cancelled while waiting or requesting, it returns the context error
+func (rf *RetryFetcher) Fetch(ctx context.Context, pageUrl string) (*Response, error) {
+	return rf.FetchWithHeader(ctx, pageUrl, nil)
+}
+
+// FetchWithHeader retries the requests like Fetch, adding the header to every attempt if the retried Fetcher is a HeaderFetcher
+func (rf *RetryFetcher) FetchWithHeader(ctx context.Context, pageUrl string, header http.Header) (*Response, error) {
+	var response *Response
+	var errFetch error
+
+	for attempt := 1; attempt <= rf.maxAttempts; attempt++ {
+		if errWait := rf.breaker.Wait(ctx); errWait != nil {
+			return nil, errWait
+		}
+
+		response, errFetch = fetchWithHeader(ctx, rf.fetcher, pageUrl, header)
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+
+		if errFetch == nil {
+			response.Attempts = attempt
+			rf.breaker.Record(response.StatusCode)
+
+			if !isRetryableStatus(response.StatusCode) {
+				return response, nil
+			}
+		}
+
+		if attempt == rf.maxAttempts {
+			break
+		}
+
+		if errSleep := Sleep(ctx, rf.delay(attempt, response, errFetch)); errSleep != nil {
+			return nil, errSleep
+		}
+	}
+
+	if errFetch != nil {
+		return nil, &RetryError{URL: pageUrl, Attempts: rf.maxAttempts, Err: errFetch}
+	}
+
+	return response, nil
+}
+
+// delay calculates the waiting time before the next attempt, which is an exponential backoff with jitter
+// If the last response had a Retry-After header that asks for waiting longer, that time is used instead
+func (rf *RetryFetcher) delay(attempt int, response *Response, errFetch error) time.Duration {
+	backoff := rf.baseDelay
+	for i := 1; i < attempt && backoff < rf.maxDelay; i++ {
+		backoff *= 2
+	}
+	if backoff > rf.maxDelay {
+		backoff = rf.maxDelay
+	}
+
+	// Equal jitter: half of the backoff is fixed and the other half is random
+	if backoff > 1 {
+		rf.randMutex.Lock()
+		backoff = backoff/2 + time.Duration(rf.seededRand.Int63n(int64(backoff/2)+1))
+		rf.randMutex.Unlock()
+	}
+
+	if errFetch == nil && response != nil {
+		if retryAfter, exists := parseRetryAfter(response.Header.Get("Retry-After")); exists && retryAfter > backoff {
+			return retryAfter
+		}
+	}
+
+	return backoff
+}
+
+// isRetryableStatus checks if a response with the statusCode is worth requesting again
+// That is when the access has been temporarily denied or the server had an error
+func isRetryableStatus(statusCode int) bool {
+	return statusCode == http.StatusForbidden || statusCode == http.StatusTooManyRequests || statusCode >= http.StatusInternalServerError
+}
+
+// parseRetryAfter parses a Retry-After header value, which can be either a number of seconds or an HTTP date
+// It returns false if the value is empty or invalid, and never a duration longer than maxRetryAfter
+func parseRetryAfter(value string) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+
+	var retryAfter time.Duration
+	if seconds, errSeconds := strconv.Atoi(value); errSeconds == nil {
+		retryAfter = time.Duration(seconds) * time.Second
+	} else if date, errDate := http.ParseTime(value); errDate == nil {
+		retryAfter = time.Until(date)
+	} else {
+		return 0, false
+	}
+
+	if retryAfter < 0 {
+		retryAfter = 0
+	} else if retryAfter > maxRetryAfter {
+		retryAfter = maxRetryAfter
+	}
+
+	return retryAfter, true
+}
+
+// circuitBreaker counts the consecutive denied responses of all requests
+// When they reach the threshold, the circuit opens and every request waits until the pause is over
+type circuitBreaker struct {
+	mutex sync.Mutex
+
+	threshold int
+	pause     time.Duration
+
+	// deniedResponses is the number of consecutive 403 or 429 responses
+	deniedResponses int
+
+	// openUntil is the time when requests are allowed again
+	openUntil time.Time
+}
+
+// newCircuitBreaker creates a closed circuitBreaker that opens for pause after threshold consecutive denied responses
+func newCircuitBreaker(threshold int, pause time.Duration) *circuitBreaker {
+	return &circuitBreaker{
+		threshold: threshold,
+		pause:     pause,
+	}
+}
+
+// Wait blocks while the circuit is open, returning the context error if the ctx context is cancelled meanwhile
+func (breaker *circuitBreaker) Wait(ctx context.Context) error {
+	breaker.mutex.Lock()
+	openUntil := breaker.openUntil
+	breaker.mutex.Unlock()
+
+	return Sleep(ctx, time.Until(openUntil))
+}
+
+// Record counts a response status code, opening the circuit if there have been too many consecutive denied responses
+func (breaker *circuitBreaker) Record(statusCode int) {
+	breaker.mutex.Lock()
+	defer breaker.mutex.Unlock()
+
+	if statusCode != http.StatusForbidden && statusCode != http.StatusTooManyRequests {
+		breaker.deniedResponses = 0
+		return
+	}
+
+	breaker.deniedResponses++
+	if breaker.deniedResponses >= breaker.threshold {
+		log.Warn().Msgf("Too many denied requests, pausing all requests for %s", breaker.pause)
+		breaker.openUntil = time.Now().Add(breaker.pause)
+		breaker.deniedResponses = 0
+	}
+}
+
+// Sleep waits for the duration or until the ctx context is cancelled, returning the context error in that case
+func Sleep(ctx context.Context, duration time.Duration) error {
+	if duration <= 0 {
+		return ctx.Err()
+	}
+
+	timer := time.NewTimer(duration)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,217 @@
+package fetcher_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"time"
+
+	"github.com/jlgallego99/TropesToGo/service/fetcher"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+// failingFetcher is a Fetcher that always fails, counting the requests made to it
+type failingFetcher struct {
+	requests int32
+}
+
+func (failing *failingFetcher) Fetch(ctx context.Context, pageUrl string) (*fetcher.Response, error) {
+	atomic.AddInt32(&failing.requests, 1)
+	return nil, fetcher.ErrRequest
+}
+
+var _ = Describe("RetryFetcher", func() {
+	var server *httptest.Server
+	var retryFetcher *fetcher.RetryFetcher
+	var errNewFetcher error
+	var requests int32
+
+	BeforeEach(func() {
+		atomic.StoreInt32(&requests, 0)
+
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			request := atomic.AddInt32(&requests, 1)
+
+			switch r.URL.Path {
+			case "/unavailable":
+				// The server recovers on the third request
+				if request < 3 {
+					w.WriteHeader(http.StatusServiceUnavailable)
+					return
+				}
+			case "/ratelimited":
+				if request < 2 {
+					w.Header().Set("Retry-After", "1")
+					w.WriteHeader(http.StatusTooManyRequests)
+					return
+				}
+			case "/forbidden":
+				w.WriteHeader(http.StatusForbidden)
+				return
+			case "/notfound":
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+
+			w.Write([]byte(pageContents))
+		}))
+
+		httpFetcher, _ := fetcher.NewHTTPFetcher(fetcher.ConfigClient(server.Client()))
+		retryFetcher, errNewFetcher = fetcher.NewRetryFetcher(httpFetcher,
+			fetcher.ConfigBackoff(time.Millisecond, 10*time.Millisecond))
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	Context("Fetch a page that recovers from server errors", func() {
+		var response *fetcher.Response
+		var errFetch error
+
+		BeforeEach(func() {
+			response, errFetch = retryFetcher.Fetch(context.Background(), server.URL+"/unavailable")
+		})
+
+		It("Shouldn't return an error", func() {
+			Expect(errNewFetcher).To(BeNil())
+			Expect(errFetch).To(BeNil())
+		})
+
+		It("Should return the successful response", func() {
+			Expect(response.StatusCode).To(Equal(http.StatusOK))
+			Expect(string(response.Body)).To(Equal(pageContents))
+		})
+
+		It("Should have retried the request", func() {
+			Expect(response.Attempts).To(Equal(3))
+			Expect(atomic.LoadInt32(&requests)).To(Equal(int32(3)))
+		})
+	})
+
+	Context("Fetch a page that is rate limited with a Retry-After header", func() {
+		var response *fetcher.Response
+		var errFetch error
+		var elapsed time.Duration
+
+		BeforeEach(func() {
+			start := time.Now()
+			response, errFetch = retryFetcher.Fetch(context.Background(), server.URL+"/ratelimited")
+			elapsed = time.Since(start)
+		})
+
+		It("Shouldn't return an error", func() {
+			Expect(errFetch).To(BeNil())
+			Expect(response.StatusCode).To(Equal(http.StatusOK))
+		})
+
+		It("Should have waited the time asked by the server", func() {
+			Expect(response.Attempts).To(Equal(2))
+			Expect(elapsed).To(BeNumerically(">=", time.Second))
+		})
+	})
+
+	Context("Fetch a page that always denies access", func() {
+		var response *fetcher.Response
+		var errFetch error
+
+		BeforeEach(func() {
+			response, errFetch = retryFetcher.Fetch(context.Background(), server.URL+"/forbidden")
+		})
+
+		It("Should return the last response after all attempts", func() {
+			Expect(errFetch).To(BeNil())
+			Expect(response.StatusCode).To(Equal(http.StatusForbidden))
+			Expect(response.Attempts).To(Equal(4))
+		})
+	})
+
+	Context("Fetch a page that doesn't exist", func() {
+		var response *fetcher.Response
+		var errFetch error
+
+		BeforeEach(func() {
+			response, errFetch = retryFetcher.Fetch(context.Background(), server.URL+"/notfound")
+		})
+
+		It("Shouldn't retry the request", func() {
+			Expect(errFetch).To(BeNil())
+			Expect(response.StatusCode).To(Equal(http.StatusNotFound))
+			Expect(response.Attempts).To(Equal(1))
+		})
+	})
+
+	Context("Fetch a page whose requests always fail", func() {
+		var failing *failingFetcher
+		var errFetch error
+
+		BeforeEach(func() {
+			failing = &failingFetcher{}
+			failingRetryFetcher, _ := fetcher.NewRetryFetcher(failing,
+				fetcher.ConfigMaxAttempts(3), fetcher.ConfigBackoff(0, 0))
+			_, errFetch = failingRetryFetcher.Fetch(context.Background(), server.URL)
+		})
+
+		It("Should return a RetryError", func() {
+			var retryError *fetcher.RetryError
+			Expect(errors.As(errFetch, &retryError)).To(BeTrue())
+			Expect(retryError.Attempts).To(Equal(3))
+			Expect(retryError.URL).To(Equal(server.URL))
+		})
+
+		It("Should wrap both the exhausted retries and the last error", func() {
+			Expect(errors.Is(errFetch, fetcher.ErrRetriesExhausted)).To(BeTrue())
+			Expect(errors.Is(errFetch, fetcher.ErrRequest)).To(BeTrue())
+		})
+
+		It("Should have made all the attempts", func() {
+			Expect(atomic.LoadInt32(&failing.requests)).To(Equal(int32(3)))
+		})
+	})
+
+	Context("Fetch pages while the circuit breaker is open", func() {
+		var errFetch error
+		var ctx context.Context
+		var cancel context.CancelFunc
+
+		BeforeEach(func() {
+			httpFetcher, _ := fetcher.NewHTTPFetcher(fetcher.ConfigClient(server.Client()))
+			breakerFetcher, _ := fetcher.NewRetryFetcher(httpFetcher,
+				fetcher.ConfigMaxAttempts(2), fetcher.ConfigBackoff(0, 0), fetcher.ConfigCircuitBreaker(2, time.Hour))
+
+			// Two denied requests open the circuit, so the next request waits until the context expires
+			breakerFetcher.Fetch(context.Background(), server.URL+"/forbidden")
+
+			ctx, cancel = context.WithTimeout(context.Background(), 50*time.Millisecond)
+			_, errFetch = breakerFetcher.Fetch(ctx, server.URL)
+		})
+
+		AfterEach(func() {
+			cancel()
+		})
+
+		It("Should have paused the requests", func() {
+			Expect(errors.Is(errFetch, context.DeadlineExceeded)).To(BeTrue())
+			Expect(atomic.LoadInt32(&requests)).To(Equal(int32(2)))
+		})
+	})
+
+	Context("Create a RetryFetcher with invalid configurations", func() {
+		It("Should return an ErrInvalidField error", func() {
+			httpFetcher, _ := fetcher.NewHTTPFetcher()
+
+			_, errNilFetcher := fetcher.NewRetryFetcher(nil)
+			_, errAttempts := fetcher.NewRetryFetcher(httpFetcher, fetcher.ConfigMaxAttempts(0))
+			_, errBackoff := fetcher.NewRetryFetcher(httpFetcher, fetcher.ConfigBackoff(time.Minute, time.Second))
+			_, errBreaker := fetcher.NewRetryFetcher(httpFetcher, fetcher.ConfigCircuitBreaker(0, time.Minute))
+
+			Expect(errors.Is(errNilFetcher, fetcher.ErrInvalidField)).To(BeTrue())
+			Expect(errors.Is(errAttempts, fetcher.ErrInvalidField)).To(BeTrue())
+			Expect(errors.Is(errBackoff, fetcher.ErrInvalidField)).To(BeTrue())
+			Expect(errors.Is(errBreaker, fetcher.ErrInvalidField)).To(BeTrue())
+		})
+	})
+})
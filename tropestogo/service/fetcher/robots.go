@@ -0,0 +1,188 @@
+package fetcher
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/temoto/robotstxt"
+)
+
+// robotsPath is the path of the robots.txt file on every host
+const robotsPath = "/robots.txt"
+
+var (
+	ErrDisallowed = errors.New("the robots.txt file of the host disallows requesting the URL")
+	ErrRobots     = errors.New("couldn't retrieve the robots.txt file of the host")
+)
+
+// RobotsFetcher implements the Fetcher interface by obeying the robots.txt file of every requested host
+// The robots.txt file of a host is requested with the same Fetcher the first time a page of that host is requested,
+// and then the pages disallowed for the user agent are never requested, and the requests are spaced out by its Crawl-delay
+type RobotsFetcher struct {
+	// fetcher requests the robots.txt files and the allowed pages
+	fetcher Fetcher
+
+	// userAgent is the name matched against the User-agent groups of the robots.txt files
+	userAgent string
+
+	mutex sync.Mutex
+
+	// hosts relates every requested host with its robots.txt rules
+	hosts map[string]*robotsHost
+
+	// requests relates every host whose robots.txt file is being requested with that request, so it's requested only once at a time
+	requests map[string]*robotsRequest
+}
+
+// robotsRequest is a request of the robots.txt file of a host, which the other pages of the host wait for
+type robotsRequest struct {
+	// done is closed when the request has finished
+	done chan struct{}
+
+	host *robotsHost
+	err  error
+}
+
+// robotsHost holds the robots.txt rules of a host and when it can be requested again
+type robotsHost struct {
+	robots *robotstxt.RobotsData
+
+	// crawlDelay is the minimum time between two requests to the host
+	crawlDelay time.Duration
+
+	// nextRequest is the earliest time the next request to the host can be made
+	nextRequest time.Time
+}
+
+// NewRobotsFetcher takes the Fetcher whose requests must obey the robots.txt files and the userAgent that it sends
+// and returns a RobotsFetcher
+// It returns an ErrInvalidField error if the pageFetcher is nil or the userAgent is empty
+func NewRobotsFetcher(pageFetcher Fetcher, userAgent string) (*RobotsFetcher, error) {
+	if pageFetcher == nil || userAgent == "" {
+		return nil, ErrInvalidField
+	}
+
+	return &RobotsFetcher{
+		fetcher:   pageFetcher,
+		userAgent: userAgent,
+		hosts:     make(map[string]*robotsHost),
+		requests:  make(map[string]*robotsRequest),
+	}, nil
+}
+
+// Fetch requests the pageUrl if the robots.txt file of its host allows it, waiting for the Crawl-delay of the host if it has one
+// It returns an ErrDisallowed error if the page is disallowed for the user agent,
+// or an ErrRobots error if the robots.txt file couldn't be retrieved
+func (rf *RobotsFetcher) Fetch(ctx context.Context, pageUrl string) (*Response, error) {
+	return rf.FetchWithHeader(ctx, pageUrl, nil)
+}
+
+// FetchWithHeader obeys the robots.txt file like Fetch, adding the header to the request if the wrapped Fetcher is a HeaderFetcher
+func (rf *RobotsFetcher) FetchWithHeader(ctx context.Context, pageUrl string, header http.Header) (*Response, error) {
+	parsedUrl, errParse := url.Parse(pageUrl)
+	if errParse != nil {
+		return nil, fmt.Errorf("%w: "+pageUrl+"\n%w", ErrRequest, errParse)
+	}
+
+	host, errHost := rf.getHost(ctx, parsedUrl)
+	if errHost != nil {
+		return nil, errHost
+	}
+
+	if !host.robots.TestAgent(parsedUrl.RequestURI(), rf.userAgent) {
+		return nil, fmt.Errorf("%w: "+pageUrl, ErrDisallowed)
+	}
+
+	if errWait := rf.wait(ctx, host); errWait != nil {
+		return nil, errWait
+	}
+
+	return fetchWithHeader(ctx, rf.fetcher, pageUrl, header)
+}
+
+// getHost returns the robots.txt rules of the host of pageUrl, requesting them if it's the first time
+// The robots.txt file is requested without locking the other hosts, and the pages of the host that are fetched meanwhile wait for that request
+// A missing robots.txt file allows everything, but if it couldn't be requested or it was forbidden, rate limited or had a server error
+// it isn't stored, so it's requested again for the next page
+func (rf *RobotsFetcher) getHost(ctx context.Context, pageUrl *url.URL) (*robotsHost, error) {
+	rf.mutex.Lock()
+	if host, exists := rf.hosts[pageUrl.Host]; exists {
+		rf.mutex.Unlock()
+		return host, nil
+	}
+
+	request, requesting := rf.requests[pageUrl.Host]
+	if !requesting {
+		request = &robotsRequest{done: make(chan struct{})}
+		rf.requests[pageUrl.Host] = request
+	}
+	rf.mutex.Unlock()
+
+	if requesting {
+		select {
+		case <-request.done:
+			return request.host, request.err
+		case <-ctx.Done():
+			return nil, fmt.Errorf("%w: "+pageUrl.Host+"\n%w", ErrRobots, ctx.Err())
+		}
+	}
+
+	request.host, request.err = rf.requestRobots(ctx, pageUrl.Scheme+"://"+pageUrl.Host+robotsPath)
+
+	rf.mutex.Lock()
+	delete(rf.requests, pageUrl.Host)
+	if request.err == nil {
+		rf.hosts[pageUrl.Host] = request.host
+	}
+	rf.mutex.Unlock()
+	close(request.done)
+
+	return request.host, request.err
+}
+
+// requestRobots requests the robots.txt file on robotsUrl and returns the rules it has for the user agent
+// It returns an ErrRobots error if it couldn't be requested or parsed, or if it was forbidden, rate limited or had a server error,
+// because the host is only refusing the request for now and its rules aren't known
+func (rf *RobotsFetcher) requestRobots(ctx context.Context, robotsUrl string) (*robotsHost, error) {
+	response, errFetch := rf.fetcher.Fetch(ctx, robotsUrl)
+	if errFetch != nil {
+		return nil, fmt.Errorf("%w: "+robotsUrl+"\n%w", ErrRobots, errFetch)
+	}
+
+	if response.StatusCode == http.StatusForbidden || response.StatusCode == http.StatusTooManyRequests ||
+		response.StatusCode >= http.StatusInternalServerError {
+		return nil, fmt.Errorf("%w: "+robotsUrl+" answered with status %d", ErrRobots, response.StatusCode)
+	}
+
+	robots, errRobots := robotstxt.FromStatusAndBytes(response.StatusCode, response.Body)
+	if errRobots != nil {
+		return nil, fmt.Errorf("%w: "+robotsUrl+"\n%w", ErrRobots, errRobots)
+	}
+
+	return &robotsHost{
+		robots:     robots,
+		crawlDelay: robots.FindGroup(rf.userAgent).CrawlDelay,
+	}, nil
+}
+
+// wait blocks until the Crawl-delay of the host has passed since its last request, reserving that turn for the caller
+func (rf *RobotsFetcher) wait(ctx context.Context, host *robotsHost) error {
+	if host.crawlDelay <= 0 {
+		return ctx.Err()
+	}
+
+	rf.mutex.Lock()
+	turn := host.nextRequest
+	if now := time.Now(); turn.Before(now) {
+		turn = now
+	}
+	host.nextRequest = turn.Add(host.crawlDelay)
+	rf.mutex.Unlock()
+
+	return Sleep(ctx, time.Until(turn))
+}
@@ -0,0 +1,197 @@
+package fetcher_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/jlgallego99/TropesToGo/service/fetcher"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+const robotsContents = `User-agent: *
+Disallow: /private
+
+User-agent: TropesToGo
+Disallow: /pmwiki/secret
+Crawl-delay: 1
+`
+
+var _ = Describe("RobotsFetcher", func() {
+	var server *httptest.Server
+	var httpFetcher *fetcher.HTTPFetcher
+	var robotsRequests, pageRequests, robotsStatus int32
+	var robotsDelay int64
+	var serveRobots bool
+
+	BeforeEach(func() {
+		atomic.StoreInt32(&robotsRequests, 0)
+		atomic.StoreInt32(&pageRequests, 0)
+		atomic.StoreInt32(&robotsStatus, http.StatusOK)
+		atomic.StoreInt64(&robotsDelay, 0)
+		serveRobots = true
+
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == "/robots.txt" {
+				atomic.AddInt32(&robotsRequests, 1)
+				time.Sleep(time.Duration(atomic.LoadInt64(&robotsDelay)))
+				if !serveRobots {
+					w.WriteHeader(http.StatusNotFound)
+					return
+				}
+
+				if status := atomic.LoadInt32(&robotsStatus); status != http.StatusOK {
+					w.WriteHeader(int(status))
+					return
+				}
+
+				w.Write([]byte(robotsContents))
+				return
+			}
+
+			atomic.AddInt32(&pageRequests, 1)
+			w.Write([]byte(pageContents))
+		}))
+
+		httpFetcher, _ = fetcher.NewHTTPFetcher(fetcher.ConfigClient(server.Client()))
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	Context("Fetch allowed pages of a host with a Crawl-delay", func() {
+		var firstResponse, secondResponse *fetcher.Response
+		var errNewFetcher, errFirst, errSecond error
+		var elapsed time.Duration
+
+		BeforeEach(func() {
+			var robotsFetcher *fetcher.RobotsFetcher
+			robotsFetcher, errNewFetcher = fetcher.NewRobotsFetcher(httpFetcher, fetcher.DefaultUserAgent)
+
+			start := time.Now()
+			firstResponse, errFirst = robotsFetcher.Fetch(context.Background(), server.URL+"/pmwiki/pmwiki.php/Film/Oldboy2003")
+			secondResponse, errSecond = robotsFetcher.Fetch(context.Background(), server.URL+"/private")
+			elapsed = time.Since(start)
+		})
+
+		It("Shouldn't return an error", func() {
+			Expect(errNewFetcher).To(BeNil())
+			Expect(errFirst).To(BeNil())
+			Expect(errSecond).To(BeNil())
+			Expect(string(firstResponse.Body)).To(Equal(pageContents))
+			Expect(string(secondResponse.Body)).To(Equal(pageContents))
+		})
+
+		It("Should request the robots.txt file only once", func() {
+			Expect(atomic.LoadInt32(&robotsRequests)).To(Equal(int32(1)))
+			Expect(atomic.LoadInt32(&pageRequests)).To(Equal(int32(2)))
+		})
+
+		It("Should space out the requests by the Crawl-delay", func() {
+			Expect(elapsed).To(BeNumerically(">=", time.Second))
+		})
+	})
+
+	Context("Fetch a page disallowed for the User-Agent", func() {
+		var errDisallowed, errOtherAgent error
+
+		BeforeEach(func() {
+			robotsFetcher, _ := fetcher.NewRobotsFetcher(httpFetcher, fetcher.DefaultUserAgent)
+			_, errDisallowed = robotsFetcher.Fetch(context.Background(), server.URL+"/pmwiki/secret/page")
+
+			otherFetcher, _ := fetcher.NewRobotsFetcher(httpFetcher, "OtherBot/1.0")
+			_, errOtherAgent = otherFetcher.Fetch(context.Background(), server.URL+"/private")
+		})
+
+		It("Should return an ErrDisallowed error without requesting the page", func() {
+			Expect(errors.Is(errDisallowed, fetcher.ErrDisallowed)).To(BeTrue())
+			Expect(errors.Is(errOtherAgent, fetcher.ErrDisallowed)).To(BeTrue())
+			Expect(atomic.LoadInt32(&pageRequests)).To(Equal(int32(0)))
+		})
+	})
+
+	Context("Fetch a page of a host without a robots.txt file", func() {
+		var errFetch error
+
+		BeforeEach(func() {
+			serveRobots = false
+			robotsFetcher, _ := fetcher.NewRobotsFetcher(httpFetcher, fetcher.DefaultUserAgent)
+			_, errFetch = robotsFetcher.Fetch(context.Background(), server.URL+"/pmwiki/secret/page")
+		})
+
+		It("Should allow every page", func() {
+			Expect(errFetch).To(BeNil())
+			Expect(atomic.LoadInt32(&pageRequests)).To(Equal(int32(1)))
+		})
+	})
+
+	Context("Fetch pages of a host whose robots.txt file is forbidden or rate limited", func() {
+		var errForbidden, errRateLimited, errAllowed error
+
+		BeforeEach(func() {
+			robotsFetcher, _ := fetcher.NewRobotsFetcher(httpFetcher, fetcher.DefaultUserAgent)
+
+			atomic.StoreInt32(&robotsStatus, http.StatusForbidden)
+			_, errForbidden = robotsFetcher.Fetch(context.Background(), server.URL+"/pmwiki/secret/page")
+			atomic.StoreInt32(&robotsStatus, http.StatusTooManyRequests)
+			_, errRateLimited = robotsFetcher.Fetch(context.Background(), server.URL+"/pmwiki/secret/page")
+			atomic.StoreInt32(&robotsStatus, http.StatusOK)
+			_, errAllowed = robotsFetcher.Fetch(context.Background(), server.URL+"/pmwiki/secret/page")
+		})
+
+		It("Should return an ErrRobots error without requesting the page", func() {
+			Expect(errors.Is(errForbidden, fetcher.ErrRobots)).To(BeTrue())
+			Expect(errors.Is(errRateLimited, fetcher.ErrRobots)).To(BeTrue())
+			Expect(atomic.LoadInt32(&pageRequests)).To(Equal(int32(0)))
+		})
+
+		It("Should request the robots.txt file again for the next page instead of allowing everything", func() {
+			Expect(errors.Is(errAllowed, fetcher.ErrDisallowed)).To(BeTrue())
+			Expect(atomic.LoadInt32(&robotsRequests)).To(Equal(int32(3)))
+		})
+	})
+
+	Context("Fetch several pages of a host at the same time", func() {
+		var errFetches [5]error
+
+		BeforeEach(func() {
+			atomic.StoreInt64(&robotsDelay, int64(200*time.Millisecond))
+			robotsFetcher, _ := fetcher.NewRobotsFetcher(httpFetcher, "OtherBot/1.0")
+
+			var wg sync.WaitGroup
+			for pos := range errFetches {
+				wg.Add(1)
+				go func(pos int) {
+					defer wg.Done()
+					_, errFetches[pos] = robotsFetcher.Fetch(context.Background(), server.URL+"/pmwiki/pmwiki.php/Film/Oldboy2003")
+				}(pos)
+			}
+			wg.Wait()
+		})
+
+		It("Should request the robots.txt file only once and then every page", func() {
+			for _, errFetch := range errFetches {
+				Expect(errFetch).To(BeNil())
+			}
+
+			Expect(atomic.LoadInt32(&robotsRequests)).To(Equal(int32(1)))
+			Expect(atomic.LoadInt32(&pageRequests)).To(Equal(int32(len(errFetches))))
+		})
+	})
+
+	Context("Create a RobotsFetcher with invalid arguments", func() {
+		It("Should return an ErrInvalidField error", func() {
+			_, errNilFetcher := fetcher.NewRobotsFetcher(nil, fetcher.DefaultUserAgent)
+			_, errEmptyAgent := fetcher.NewRobotsFetcher(httpFetcher, "")
+
+			Expect(errors.Is(errNilFetcher, fetcher.ErrInvalidField)).To(BeTrue())
+			Expect(errors.Is(errEmptyAgent, fetcher.ErrInvalidField)).To(BeTrue())
+		})
+	})
+})
@@ -0,0 +1,374 @@
+package outcome
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/jlgallego99/TropesToGo/service/fetcher"
+	"github.com/jlgallego99/TropesToGo/tvtropespages"
+)
+
+// UnknownErrorClass is the class of the errors that aren't any of the known error classes
+const UnknownErrorClass = "Unknown"
+
+var (
+	ErrInvalidField = errors.New("one or more fields for the outcome Report are invalid")
+	ErrLoadReport   = errors.New("couldn't load the run report file")
+	ErrSaveReport   = errors.New("couldn't save the run report file")
+)
+
+// Stage enumerates the steps that every page goes through on a run, in the order they happen
+type Stage string
+
+const (
+	// CrawlStage is the request of a Work page with its history and subpages
+	CrawlStage Stage = "crawl"
+	// CheckStage is the check of whether a crawled page is a Work page that can be scraped
+	CheckStage Stage = "check"
+	// ScrapeStage is the extraction of the data of a Work page
+	ScrapeStage Stage = "scrape"
+	// SubpagesStage is the extraction of the tropes of the subpages of a Work page
+	SubpagesStage Stage = "subpages"
+	// PersistStage is the writing of a scraped Work on the dataset
+	PersistStage Stage = "persist"
+)
+
+// Stages are all the stages in the order they happen
+var Stages = []Stage{CrawlStage, CheckStage, ScrapeStage, SubpagesStage, PersistStage}
+
+// Status is whether a page got through a stage
+type Status string
+
+const (
+	Succeeded Status = "succeeded"
+	Failed    Status = "failed"
+)
+
+// ErrorClass names a sentinel error, so every error wrapping it is reported with that name
+type ErrorClass struct {
+	Name string
+	Err  error
+}
+
+// DefaultErrorClasses are the classes of the errors of requesting TvTropes, which are checked after the configured ones
+var DefaultErrorClasses = []ErrorClass{
+	{"ErrForbidden", tvtropespages.ErrForbidden},
+	{"ErrDisallowed", fetcher.ErrDisallowed},
+	{"ErrNotCached", fetcher.ErrNotCached},
+	{"ErrRetriesExhausted", fetcher.ErrRetriesExhausted},
+	{"ErrNotFound", tvtropespages.ErrNotFound},
+	{"ErrParsing", tvtropespages.ErrParsing},
+	{"ErrNotTvTropes", tvtropespages.ErrNotTvTropes},
+	{"ErrBadUrl", tvtropespages.ErrBadUrl},
+	{"ErrRequest", fetcher.ErrRequest},
+	{"DeadlineExceeded", context.DeadlineExceeded},
+}
+
+// PageOutcome is the result of a page on a stage of a run
+type PageOutcome struct {
+	URL        string    `json:"url"`
+	Stage      Stage     `json:"stage"`
+	Status     Status    `json:"status"`
+	ErrorClass string    `json:"error_class,omitempty"`
+	Error      string    `json:"error,omitempty"`
+	Timestamp  time.Time `json:"timestamp"`
+	// Attempts is the number of requests needed on the crawl stage, 0 if the page was served from a cache
+	Attempts int `json:"attempts,omitempty"`
+}
+
+// Summary counts the pages of a Report by their final status, and the failed ones by their stage and error class
+// A page has failed if its last outcome on any stage is a failure
+type Summary struct {
+	Pages        int            `json:"pages"`
+	Succeeded    int            `json:"succeeded"`
+	Failed       int            `json:"failed"`
+	Stages       map[Stage]int  `json:"stages"`
+	ErrorClasses map[string]int `json:"error_classes"`
+}
+
+// ReportConfig is an alias for a function that will accept a pointer to a Report and modify its fields
+// Each function acts as one configuration for the report
+type ReportConfig func(report *Report) error
+
+// Report is the machine-readable record of what happened to every page on one or more runs of TropesToGo
+// It keeps the last outcome of every page on every stage, so a later run over the same pages replaces their old outcomes
+// It's safe to use by several goroutines at the same time
+type Report struct {
+	// Dataset is the dataset file where the scraped Works are persisted
+	Dataset string
+	// Started is when the first run of the report started, and Updated when an outcome was last recorded
+	Started time.Time
+	Updated time.Time
+
+	// outcomes relates every page URL with its last outcome on every stage
+	outcomes map[string]map[Stage]PageOutcome
+	// order are the page URLs in the order they were first recorded
+	order []string
+	// classes are the error classes tried before the default ones when classifying an error
+	classes []ErrorClass
+	mutex   sync.Mutex
+}
+
+// jsonReport is an object for marshaling/unmarshalling a Report in Json
+type jsonReport struct {
+	Dataset  string        `json:"dataset"`
+	Started  time.Time     `json:"started"`
+	Updated  time.Time     `json:"updated"`
+	Summary  Summary       `json:"summary"`
+	Outcomes []PageOutcome `json:"outcomes"`
+}
+
+// NewReport creates an empty Report for the dataset file, applying the cfgs configurations
+func NewReport(dataset string, cfgs ...ReportConfig) (*Report, error) {
+	report := &Report{
+		Dataset:  dataset,
+		Started:  time.Now(),
+		outcomes: make(map[string]map[Stage]PageOutcome),
+		order:    make([]string, 0),
+	}
+
+	for _, cfg := range cfgs {
+		if errCfg := cfg(report); errCfg != nil {
+			return nil, errCfg
+		}
+	}
+
+	return report, nil
+}
+
+// ConfigErrorClasses adds the classes that the errors of the report are checked against, in order and before the DefaultErrorClasses
+// It returns an ErrInvalidField error if any class doesn't have a name or an error
+func ConfigErrorClasses(classes ...ErrorClass) ReportConfig {
+	return func(report *Report) error {
+		for _, class := range classes {
+			if class.Name == "" || class.Err == nil {
+				return ErrInvalidField
+			}
+		}
+
+		report.classes = append(report.classes, classes...)
+		return nil
+	}
+}
+
+// AddSuccess records that the page at pageUrl got through the stage, with the number of attempts it needed on the crawl stage
+func (report *Report) AddSuccess(pageUrl string, stage Stage, attempts int) {
+	report.add(PageOutcome{URL: pageUrl, Stage: stage, Status: Succeeded, Attempts: attempts})
+}
+
+// AddFailure records that the page at pageUrl failed on the stage with the errFailed error, classifying it
+// If the error comes from retrying the request, the attempts are the ones of the error
+func (report *Report) AddFailure(pageUrl string, stage Stage, errFailed error, attempts int) {
+	var retryError *fetcher.RetryError
+	if errors.As(errFailed, &retryError) {
+		attempts = retryError.Attempts
+	}
+
+	pageOutcome := PageOutcome{URL: pageUrl, Stage: stage, Status: Failed, ErrorClass: report.Classify(errFailed), Attempts: attempts}
+	if errFailed != nil {
+		pageOutcome.Error = errFailed.Error()
+	}
+
+	report.add(pageOutcome)
+}
+
+// Classify returns the name of the first class of the configured ones and the DefaultErrorClasses that the err error wraps,
+// or UnknownErrorClass if it doesn't wrap any of them
+func (report *Report) Classify(err error) string {
+	for _, classes := range [][]ErrorClass{report.classes, DefaultErrorClasses} {
+		for _, class := range classes {
+			if errors.Is(err, class.Err) {
+				return class.Name
+			}
+		}
+	}
+
+	return UnknownErrorClass
+}
+
+// GetOutcomes returns the last outcome of every page on every stage, sorted by the first time each page was recorded and by stage
+func (report *Report) GetOutcomes() []PageOutcome {
+	report.mutex.Lock()
+	defer report.mutex.Unlock()
+
+	return report.getOutcomes()
+}
+
+// GetFailedUrls returns the URL of every page that has failed, in the order they were first recorded
+func (report *Report) GetFailedUrls() []string {
+	report.mutex.Lock()
+	defer report.mutex.Unlock()
+
+	failedUrls := make([]string, 0)
+	for _, pageUrl := range report.order {
+		if _, failed := report.getFailure(pageUrl); failed {
+			failedUrls = append(failedUrls, pageUrl)
+		}
+	}
+
+	return failedUrls
+}
+
+// GetSummary counts the pages of the report by their final status, and the failed ones by the stage and error class of their first failure
+func (report *Report) GetSummary() Summary {
+	report.mutex.Lock()
+	defer report.mutex.Unlock()
+
+	return report.getSummary()
+}
+
+// SaveReport writes the report on the file at path as JSON, along with its summary
+// It returns an ErrSaveReport error if the file couldn't be written
+func SaveReport(path string, report *Report) error {
+	report.mutex.Lock()
+	reportJson := jsonReport{
+		Dataset:  report.Dataset,
+		Started:  report.Started,
+		Updated:  report.Updated,
+		Summary:  report.getSummary(),
+		Outcomes: report.getOutcomes(),
+	}
+	report.mutex.Unlock()
+
+	reportBytes, errMarshal := json.MarshalIndent(reportJson, "", "  ")
+	if errMarshal != nil {
+		return fmt.Errorf("%w: "+path+"\n%w", ErrSaveReport, errMarshal)
+	}
+
+	if errWrite := os.WriteFile(path, reportBytes, 0644); errWrite != nil {
+		return fmt.Errorf("%w: "+path+"\n%w", ErrSaveReport, errWrite)
+	}
+
+	return nil
+}
+
+// LoadReport reads the report saved on the file at path, so a later run can add its outcomes to it, applying the cfgs configurations
+// It returns an ErrLoadReport error if the file doesn't exist or doesn't hold a valid report
+func LoadReport(path string, cfgs ...ReportConfig) (*Report, error) {
+	fileContents, errRead := os.ReadFile(path)
+	if errRead != nil {
+		return nil, fmt.Errorf("%w: "+path+"\n%w", ErrLoadReport, errRead)
+	}
+
+	var reportJson jsonReport
+	if errUnmarshal := json.Unmarshal(fileContents, &reportJson); errUnmarshal != nil {
+		return nil, fmt.Errorf("%w: "+path+"\n%w", ErrLoadReport, errUnmarshal)
+	}
+
+	report, errReport := NewReport(reportJson.Dataset, cfgs...)
+	if errReport != nil {
+		return nil, errReport
+	}
+
+	report.Started = reportJson.Started
+	report.Updated = reportJson.Updated
+	for _, pageOutcome := range reportJson.Outcomes {
+		report.set(pageOutcome)
+	}
+
+	return report, nil
+}
+
+// add records the pageOutcome at the current time, replacing the last outcome of its page on its stage
+func (report *Report) add(pageOutcome PageOutcome) {
+	report.mutex.Lock()
+	defer report.mutex.Unlock()
+
+	pageOutcome.Timestamp = time.Now()
+	report.Updated = pageOutcome.Timestamp
+	report.set(pageOutcome)
+}
+
+// set stores the pageOutcome as the last one of its page on its stage, must be called with the mutex locked
+func (report *Report) set(pageOutcome PageOutcome) {
+	if report.outcomes[pageOutcome.URL] == nil {
+		report.outcomes[pageOutcome.URL] = make(map[Stage]PageOutcome)
+		report.order = append(report.order, pageOutcome.URL)
+	}
+
+	report.outcomes[pageOutcome.URL][pageOutcome.Stage] = pageOutcome
+}
+
+// getOutcomes returns the outcomes like GetOutcomes, must be called with the mutex locked
+func (report *Report) getOutcomes() []PageOutcome {
+	outcomes := make([]PageOutcome, 0, len(report.order))
+	for _, pageUrl := range report.order {
+		for _, stage := range sortedStages(report.outcomes[pageUrl]) {
+			outcomes = append(outcomes, report.outcomes[pageUrl][stage])
+		}
+	}
+
+	return outcomes
+}
+
+// getSummary returns the summary like GetSummary, must be called with the mutex locked
+func (report *Report) getSummary() Summary {
+	summary := Summary{
+		Pages:        len(report.order),
+		Stages:       make(map[Stage]int),
+		ErrorClasses: make(map[string]int),
+	}
+
+	for _, pageUrl := range report.order {
+		failure, failed := report.getFailure(pageUrl)
+		if !failed {
+			summary.Succeeded++
+			continue
+		}
+
+		summary.Failed++
+		summary.Stages[failure.Stage]++
+		summary.ErrorClasses[failure.ErrorClass]++
+	}
+
+	return summary
+}
+
+// getFailure returns the failed outcome of the earliest stage of the page at pageUrl, and whether it has failed on any stage
+// Must be called with the mutex locked
+func (report *Report) getFailure(pageUrl string) (PageOutcome, bool) {
+	for _, stage := range sortedStages(report.outcomes[pageUrl]) {
+		if pageOutcome := report.outcomes[pageUrl][stage]; pageOutcome.Status == Failed {
+			return pageOutcome, true
+		}
+	}
+
+	return PageOutcome{}, false
+}
+
+// sortedStages returns the stages of the outcomes in the order they happen, followed by any unknown stage sorted by name
+func sortedStages(outcomes map[Stage]PageOutcome) []Stage {
+	stages := make([]Stage, 0, len(outcomes))
+	for _, stage := range Stages {
+		if _, exists := outcomes[stage]; exists {
+			stages = append(stages, stage)
+		}
+	}
+
+	unknownStages := make([]Stage, 0)
+	for stage := range outcomes {
+		if stageIndex(stage) < 0 {
+			unknownStages = append(unknownStages, stage)
+		}
+	}
+	sort.Slice(unknownStages, func(i, j int) bool { return unknownStages[i] < unknownStages[j] })
+
+	return append(stages, unknownStages...)
+}
+
+// stageIndex returns the position of the stage on Stages, or -1 if it isn't a known stage
+func stageIndex(stage Stage) int {
+	for i, knownStage := range Stages {
+		if knownStage == stage {
+			return i
+		}
+	}
+
+	return -1
+}
@@ -0,0 +1,114 @@
+package outcome_test
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/jlgallego99/TropesToGo/service/fetcher"
+	"github.com/jlgallego99/TropesToGo/service/outcome"
+	"github.com/jlgallego99/TropesToGo/tvtropespages"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+const (
+	oldboyUrl  = "https://tvtropes.org/pmwiki/pmwiki.php/Film/Oldboy2003"
+	newHopeUrl = "https://tvtropes.org/pmwiki/pmwiki.php/Film/ANewHope"
+	aadaiUrl   = "https://tvtropes.org/pmwiki/pmwiki.php/Film/Aadai"
+)
+
+var errCustom = errors.New("custom error")
+
+var _ = Describe("Report", func() {
+	var report *outcome.Report
+
+	BeforeEach(func() {
+		var errReport error
+		report, errReport = outcome.NewReport("dataset.json", outcome.ConfigErrorClasses(outcome.ErrorClass{Name: "ErrCustom", Err: errCustom}))
+		Expect(errReport).To(BeNil())
+
+		report.AddSuccess(oldboyUrl, outcome.CrawlStage, 1)
+		report.AddSuccess(oldboyUrl, outcome.ScrapeStage, 0)
+		report.AddFailure(newHopeUrl, outcome.CrawlStage, &fetcher.RetryError{URL: newHopeUrl, Attempts: 3, Err: fmt.Errorf("%w: "+newHopeUrl, tvtropespages.ErrForbidden)}, 0)
+		report.AddFailure(aadaiUrl, outcome.SubpagesStage, fmt.Errorf("wrapped: %w", errCustom), 0)
+	})
+
+	Context("Recording the outcome of every page", func() {
+		It("Should classify the errors with the configured and the default classes", func() {
+			Expect(report.Classify(errCustom)).To(Equal("ErrCustom"))
+			Expect(report.Classify(tvtropespages.ErrNotFound)).To(Equal("ErrNotFound"))
+			Expect(report.Classify(errors.New("other error"))).To(Equal(outcome.UnknownErrorClass))
+		})
+
+		It("Should take the attempts of the errors of retried requests", func() {
+			Expect(report.GetOutcomes()).To(ContainElement(And(HaveField("URL", newHopeUrl), HaveField("Attempts", 3),
+				HaveField("ErrorClass", "ErrForbidden"), HaveField("Status", outcome.Failed))))
+		})
+
+		It("Should return the outcomes of every page sorted by stage", func() {
+			outcomes := report.GetOutcomes()
+			Expect(outcomes).To(HaveLen(4))
+			Expect(outcomes[0].Stage).To(Equal(outcome.CrawlStage))
+			Expect(outcomes[1].Stage).To(Equal(outcome.ScrapeStage))
+			for _, pageOutcome := range outcomes {
+				Expect(pageOutcome.Timestamp.IsZero()).To(BeFalse())
+			}
+		})
+
+		It("Should summarize the pages by their status, stage and error class", func() {
+			summary := report.GetSummary()
+			Expect(summary.Pages).To(Equal(3))
+			Expect(summary.Succeeded).To(Equal(1))
+			Expect(summary.Failed).To(Equal(2))
+			Expect(summary.Stages).To(Equal(map[outcome.Stage]int{outcome.CrawlStage: 1, outcome.SubpagesStage: 1}))
+			Expect(summary.ErrorClasses).To(Equal(map[string]int{"ErrForbidden": 1, "ErrCustom": 1}))
+		})
+
+		It("Should replace the old outcome of a page on the same stage", func() {
+			report.AddSuccess(newHopeUrl, outcome.CrawlStage, 1)
+
+			Expect(report.GetFailedUrls()).To(Equal([]string{aadaiUrl}))
+			Expect(report.GetOutcomes()).To(HaveLen(4))
+		})
+
+		It("Shouldn't accept an invalid error class", func() {
+			_, errInvalid := outcome.NewReport("dataset.json", outcome.ConfigErrorClasses(outcome.ErrorClass{Name: "ErrNothing"}))
+			Expect(errInvalid).To(Equal(outcome.ErrInvalidField))
+		})
+	})
+
+	Context("Saving and loading a report", func() {
+		var reportPath string
+		var loadedReport *outcome.Report
+		var errSave, errLoad error
+
+		BeforeEach(func() {
+			reportPath = filepath.Join(GinkgoT().TempDir(), "dataset.run.json")
+			errSave = outcome.SaveReport(reportPath, report)
+			loadedReport, errLoad = outcome.LoadReport(reportPath)
+		})
+
+		It("Shouldn't return an error", func() {
+			Expect(errSave).To(BeNil())
+			Expect(errLoad).To(BeNil())
+		})
+
+		It("Should load the same outcomes and summary", func() {
+			Expect(loadedReport.Dataset).To(Equal("dataset.json"))
+			Expect(loadedReport.GetFailedUrls()).To(Equal(report.GetFailedUrls()))
+			Expect(loadedReport.GetSummary()).To(Equal(report.GetSummary()))
+			Expect(loadedReport.GetOutcomes()).To(HaveLen(len(report.GetOutcomes())))
+		})
+
+		It("Should return an appropriate error if the file isn't a valid report", func() {
+			Expect(os.WriteFile(reportPath, []byte("not a report"), 0644)).To(Succeed())
+			_, errInvalid := outcome.LoadReport(reportPath)
+			Expect(errors.Is(errInvalid, outcome.ErrLoadReport)).To(BeTrue())
+
+			_, errMissing := outcome.LoadReport(reportPath + ".missing")
+			Expect(errors.Is(errMissing, outcome.ErrLoadReport)).To(BeTrue())
+		})
+	})
+})
@@ -0,0 +1,272 @@
+package scraper
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/jlgallego99/TropesToGo/trope"
+	"github.com/jlgallego99/TropesToGo/tvtropespages"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	LaconicNamespace = "Laconic"
+
+	// Links that introduce the supertropes and the subtropes of a trope on its description
+	SubTropeMarkerPath   = TvTropesMainPath + "SubTrope"
+	SuperTropeMarkerPath = TvTropesMainPath + "SuperTrope"
+)
+
+var (
+	ErrNotTropePage = errors.New("the page isn't a TvTropes trope page")
+	ErrNoCatalogue  = errors.New("the scraper doesn't have a trope catalogue repository")
+)
+
+// CheckTropePage validates that a page object is a TvTropes trope Main page that can be scraped
+// If the page doesn't have a parsed document, it returns an ErrEmptyDocument error
+// It returns an ErrNotTropePage error if it isn't a trope Main page, or an ErrUnknownPageStructure if it doesn't have a main article
+func (scraper *ServiceScraper) CheckTropePage(page tvtropespages.Page) (bool, error) {
+	doc := page.GetDocument()
+	if doc == nil {
+		return false, fmt.Errorf("%w: "+page.GetUrl().String(), ErrEmptyDocument)
+	}
+
+	if page.GetUrl().Hostname() != TvTropesHostname {
+		return false, fmt.Errorf("%w: "+page.GetUrl().String(), ErrNotTvTropes)
+	}
+
+	if !strings.HasPrefix(page.GetUrl().Path, TvTropesMainPath) || tropeIdFromUri(page.GetUrl().Path) == "" {
+		return false, fmt.Errorf("%w: "+page.GetUrl().String(), ErrNotTropePage)
+	}
+
+	if doc.Find(scraper.profile.Scraper.MainArticle).Length() == 0 {
+		return false, fmt.Errorf("%w: "+page.GetUrl().String(), ErrUnknownPageStructure)
+	}
+
+	return true, nil
+}
+
+// ScrapeTropeCatalogue scrapes all trope pages of tropePages with their subpages and persists them on the catalogue repository
+// Pages that can't be scraped are skipped, so it only returns an error if it can't persist the catalogue
+// It returns an ErrNoCatalogue error if the scraper doesn't have a catalogue repository
+// If the ctx context is cancelled, it stops scraping, persists all the already scraped tropes and returns the context error
+func (scraper *ServiceScraper) ScrapeTropeCatalogue(ctx context.Context, tropePages *tvtropespages.TvTropesPages) error {
+	if scraper.catalogue == nil {
+		return ErrNoCatalogue
+	}
+
+	for page, subPages := range tropePages.Pages {
+		if ctx.Err() != nil {
+			break
+		}
+
+		if valid, errValid := scraper.CheckTropePage(page); !valid {
+			log.Error().Err(errValid).Msg("SCRAPING: " + page.GetUrl().String())
+			continue
+		}
+
+		log.Info().Msg("SCRAPING: " + page.GetUrl().String())
+		entry, errScrape := scraper.ScrapeTropePage(page, subPages)
+		if errScrape != nil {
+			log.Error().Err(errScrape).Msg("SCRAPING FAILED " + page.GetUrl().String())
+			continue
+		}
+
+		if errAdd := scraper.catalogue.AddEntry(entry); errAdd != nil {
+			log.Error().Msg("DUPLICATED TROPE " + entry.ID)
+		}
+	}
+
+	if errPersist := scraper.catalogue.Persist(); errPersist != nil {
+		log.Error().Err(errPersist).Msg("Persisting the scraped tropes on the catalogue")
+		return errPersist
+	}
+
+	return ctx.Err()
+}
+
+// ScrapeTropePage accepts a trope Main page object and a TvTropesSubpages object with its Laconic page and its example subpages
+// and extracts the trope catalogue entry: its ID and display name, its laconic description, the first paragraph of its description,
+// its supertropes and subtropes, the indexes that list it and the number of examples on the page and its example subpages
+// If the page or subpages don't have a parsed document, it returns an ErrEmptyDocument error
+func (scraper *ServiceScraper) ScrapeTropePage(page tvtropespages.Page, subPages *tvtropespages.TvTropesSubpages) (trope.CatalogueEntry, error) {
+	doc := page.GetDocument()
+	if doc == nil {
+		return trope.CatalogueEntry{}, fmt.Errorf("%w: "+page.GetUrl().String(), ErrEmptyDocument)
+	}
+
+	entry, errEntry := trope.NewCatalogueEntry(tropeIdFromUri(page.GetUrl().Path), scraper.ScrapeTropeName(doc), page.GetUrl().String())
+	if errEntry != nil {
+		return trope.CatalogueEntry{}, errEntry
+	}
+
+	entry.Description = scraper.ScrapeTropeDescription(doc)
+	entry.SuperTropes, entry.SubTropes = scraper.ScrapeRelatedTropes(doc)
+	entry.Indexes = scraper.ScrapeTropeIndexes(doc)
+	entry.Examples = scraper.CountTropeExamples(doc, entry.ID)
+
+	if subPages != nil {
+		for subPage := range subPages.Subpages {
+			subDoc := subPage.GetDocument()
+			if subDoc == nil {
+				return trope.CatalogueEntry{}, fmt.Errorf("%w: "+subPage.GetUrl().String(), ErrEmptyDocument)
+			}
+
+			if strings.HasPrefix(subPage.GetUrl().Path, TvTropesPmwiki+LaconicNamespace+"/") {
+				entry.Laconic = scraper.ScrapeTropeDescription(subDoc)
+			} else {
+				entry.Examples += scraper.CountTropeExamples(subDoc, entry.ID)
+			}
+		}
+	}
+
+	return entry, nil
+}
+
+// ScrapeTropeName extracts the display name of a trope from the title of its page, without any namespace
+func (scraper *ServiceScraper) ScrapeTropeName(doc *goquery.Document) string {
+	title := doc.Find(scraper.profile.Scraper.WorkTitle).First().Clone()
+	title.Find("strong").Remove()
+
+	return collapseBlanks(strings.Trim(strings.TrimSpace(title.Text()), "/"))
+}
+
+// ScrapeTropeDescription extracts the first paragraph with text of the main article of a trope page or its Laconic page
+// Quotes, separators and embedded blocks inside the paragraph are ignored, and the blanks are collapsed
+func (scraper *ServiceScraper) ScrapeTropeDescription(doc *goquery.Document) string {
+	description := ""
+	doc.Find(scraper.profile.Scraper.TropeParagraph).EachWithBreak(func(_ int, selection *goquery.Selection) bool {
+		paragraph := selection.Clone()
+		paragraph.Find("hr, dl, div, script, style").Remove()
+
+		description = collapseBlanks(paragraph.Text())
+		return description == ""
+	})
+
+	return description
+}
+
+// ScrapeRelatedTropes extracts the IDs of the supertropes and the subtropes of a trope from the links of its main article
+// A supertrope is the first trope linked after a link to the SubTrope page ("a Sub-Trope of X"),
+// and the subtropes are the tropes linked after a link to the SuperTrope page on the same element ("the Super-Trope of X and Y")
+// or on the list that comes right after it
+func (scraper *ServiceScraper) ScrapeRelatedTropes(doc *goquery.Document) ([]string, []string) {
+	superTropes := make([]string, 0)
+	subTropes := make([]string, 0)
+
+	doc.Find(scraper.profile.Scraper.MainArticle + " " + scraper.profile.Scraper.TropeTag).Each(func(_ int, selection *goquery.Selection) {
+		href, _ := selection.Attr("href")
+		switch strings.ToLower(strings.TrimPrefix(href, TvTropesWeb)) {
+		case strings.ToLower(SubTropeMarkerPath):
+			if linkedTropes := scraper.followingTropes(selection); len(linkedTropes) > 0 {
+				superTropes = appendUnique(superTropes, linkedTropes[0])
+			}
+		case strings.ToLower(SuperTropeMarkerPath):
+			for _, linkedTrope := range scraper.followingTropes(selection) {
+				subTropes = appendUnique(subTropes, linkedTrope)
+			}
+		}
+	})
+
+	return superTropes, subTropes
+}
+
+// ScrapeTropeIndexes extracts the IDs of the indexes that list a trope from the index section at the bottom of its page
+func (scraper *ServiceScraper) ScrapeTropeIndexes(doc *goquery.Document) []string {
+	indexes := make([]string, 0)
+	doc.Find(scraper.profile.Scraper.TropeIndexLinks).Each(func(_ int, selection *goquery.Selection) {
+		href, _ := selection.Attr("href")
+		if indexId := tropeIdFromUri(href); indexId != "" {
+			indexes = appendUnique(indexes, indexId)
+		}
+	})
+
+	return indexes
+}
+
+// CountTropeExamples counts the examples of the tropeId trope on its page or one of its example subpages,
+// which are the items of the lists of its main article, whether they are on folders or not
+// If the main article has an examples header, only the lists after it are counted, so the lists of the description are left out
+// Nested items are part of their parent example and items that only link to the example subpages of the trope are not examples,
+// so they are not counted
+func (scraper *ServiceScraper) CountTropeExamples(doc *goquery.Document, tropeId string) int {
+	article := doc.Find(scraper.profile.Scraper.MainArticle).First()
+	isExamplesHeader := func(selection *goquery.Selection) bool {
+		return selection.Is(strings.Join(headerSelectors, ", ")) && strings.Contains(strings.ToLower(selection.Text()), "example")
+	}
+
+	counting := article.Children().FilterFunction(func(_ int, selection *goquery.Selection) bool {
+		return isExamplesHeader(selection)
+	}).Length() == 0
+
+	examples := 0
+	article.Children().Each(func(_ int, selection *goquery.Selection) {
+		if isExamplesHeader(selection) {
+			counting = true
+		}
+
+		if !counting || !selection.Is(scraper.profile.Scraper.TropeExampleList) {
+			return
+		}
+
+		list := selection
+		if !selection.Is("ul") {
+			list = selection.ChildrenFiltered("ul")
+		}
+
+		list.ChildrenFiltered(scraper.profile.Scraper.TropeExampleItem).Each(func(_ int, item *goquery.Selection) {
+			href, _ := item.ChildrenFiltered(scraper.profile.Scraper.TropeTag).First().Attr("href")
+			if !strings.HasPrefix(strings.ToLower(strings.TrimPrefix(href, TvTropesWeb)), strings.ToLower(TvTropesPmwiki+tropeId+"/")) {
+				examples++
+			}
+		})
+	})
+
+	return examples
+}
+
+// followingTropes returns the IDs of the tropes linked after the link selection on the same element
+// and on the list that comes right after that element, skipping the SubTrope and SuperTrope pages
+func (scraper *ServiceScraper) followingTropes(selection *goquery.Selection) []string {
+	links := selection.NextAllFiltered(scraper.profile.Scraper.TropeTag).AddSelection(selection.Parent().Next().Filter("ul").Find("li > " + scraper.profile.Scraper.TropeTag + ":first-child"))
+
+	tropeIds := make([]string, 0)
+	links.Each(func(_ int, link *goquery.Selection) {
+		href, _ := link.Attr("href")
+		tropeId := tropeIdFromUri(href)
+		if tropeId != "" && !strings.EqualFold(tropeId, "SubTrope") && !strings.EqualFold(tropeId, "SuperTrope") {
+			tropeIds = append(tropeIds, tropeId)
+		}
+	})
+
+	return tropeIds
+}
+
+// tropeIdFromUri returns the ID of the trope of a Main page URI or URL, or an empty string if it isn't a Main page
+func tropeIdFromUri(uri string) string {
+	uri = strings.TrimPrefix(uri, TvTropesWeb)
+	if !strings.HasPrefix(uri, TvTropesMainPath) {
+		return ""
+	}
+
+	tropeId := strings.TrimPrefix(uri, TvTropesMainPath)
+	if strings.ContainsAny(tropeId, "/?#") {
+		return ""
+	}
+
+	return tropeId
+}
+
+// appendUnique appends the value to the values only if it isn't already on them
+func appendUnique(values []string, value string) []string {
+	for _, existing := range values {
+		if existing == value {
+			return values
+		}
+	}
+
+	return append(values, value)
+}
@@ -0,0 +1,131 @@
+package scraper_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/jlgallego99/TropesToGo/service/scraper"
+	"github.com/jlgallego99/TropesToGo/trope"
+	"github.com/jlgallego99/TropesToGo/trope/catalogue_dataset"
+	"github.com/jlgallego99/TropesToGo/tvtropespages"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+const chekhovsGunUrl = "https://tvtropes.org/pmwiki/pmwiki.php/Main/ChekhovsGun"
+
+var (
+	chekhovsGunSubpageFiles = []string{"resources/chekhovsgun_laconic.html", "resources/chekhovsgun_film.html"}
+	chekhovsGunSubpageUrls  = []string{"https://tvtropes.org/pmwiki/pmwiki.php/Laconic/ChekhovsGun",
+		"https://tvtropes.org/pmwiki/pmwiki.php/ChekhovsGun/Film"}
+)
+
+// newResourcePage creates a Page with the document parsed from a local resource file
+func newResourcePage(pageUrl, resource string) tvtropespages.Page {
+	resourceFile, _ := os.Open(resource)
+	defer resourceFile.Close()
+
+	doc, _ := goquery.NewDocumentFromReader(resourceFile)
+	page, _ := tvtropespages.NewPageWithDocument(pageUrl, doc)
+
+	return page
+}
+
+var _ = Describe("Trope catalogue scraper", func() {
+	var tropePage tvtropespages.Page
+	var tropeSubpages *tvtropespages.TvTropesSubpages
+
+	BeforeEach(func() {
+		tropePage = newResourcePage(chekhovsGunUrl, "resources/chekhovsgun.html")
+		tropeSubpages = &tvtropespages.TvTropesSubpages{Subpages: make(map[tvtropespages.Page]time.Time)}
+		for i, subpageFile := range chekhovsGunSubpageFiles {
+			tropeSubpages.Subpages[newResourcePage(chekhovsGunSubpageUrls[i], subpageFile)] = time.Time{}
+		}
+	})
+
+	Context("Check if a page is a trope page", func() {
+		It("Should accept a trope Main page", func() {
+			valid, errValid := serviceScraperJson.CheckTropePage(tropePage)
+
+			Expect(valid).To(BeTrue())
+			Expect(errValid).To(BeNil())
+		})
+
+		It("Should return an ErrNotTropePage error for a Work page", func() {
+			valid, errValid := serviceScraperJson.CheckTropePage(newResourcePage(works[0], workResources[0]))
+
+			Expect(valid).To(BeFalse())
+			Expect(errors.Is(errValid, scraper.ErrNotTropePage)).To(BeTrue())
+		})
+	})
+
+	Context("Scrape a trope page with its subpages", func() {
+		var entry trope.CatalogueEntry
+		var errScrape error
+
+		BeforeEach(func() {
+			entry, errScrape = serviceScraperJson.ScrapeTropePage(tropePage, tropeSubpages)
+		})
+
+		It("Shouldn't return an error", func() {
+			Expect(errScrape).To(BeNil())
+		})
+
+		It("Should extract the ID, the display name and the URL", func() {
+			Expect(entry.ID).To(Equal("ChekhovsGun"))
+			Expect(entry.Name).To(Equal("Chekhov's Gun"))
+			Expect(entry.URL).To(Equal(chekhovsGunUrl))
+		})
+
+		It("Should extract the laconic and the first paragraph of the description", func() {
+			Expect(entry.Laconic).To(Equal("An apparently irrelevant element turns out to be important later."))
+			Expect(entry.Description).To(Equal("A Chekhov's Gun is an element that is introduced early in the story as seemingly irrelevant, but becomes important later on."))
+		})
+
+		It("Should extract the supertropes, the subtropes and the indexes", func() {
+			Expect(entry.SuperTropes).To(Equal([]string{"Foreshadowing"}))
+			Expect(entry.SubTropes).To(Equal([]string{"ChekhovsArmoury", "ChekhovsSkill"}))
+			Expect(entry.Indexes).To(Equal([]string{"ForeshadowingTropes", "NarrativeDevices"}))
+		})
+
+		It("Should count the examples on the page and its example subpages", func() {
+			Expect(entry.Examples).To(Equal(5))
+		})
+	})
+
+	Context("Scrape the trope pages into a catalogue", func() {
+		var catalogueScraper *scraper.ServiceScraper
+		var catalogue *catalogue_dataset.JSONCatalogue
+		var errScrape, errNoCatalogue error
+		var tropeIds map[string]struct{}
+
+		BeforeEach(func() {
+			catalogue, _ = catalogue_dataset.NewJSONCatalogue(filepath.Join(GinkgoT().TempDir(), "tropes"))
+			catalogueScraper, _ = scraper.NewServiceScraper(scraper.ConfigCatalogueRepository(catalogue))
+
+			tropePages := tvtropespages.NewTvTropesPages()
+			tropePages.Pages[tropePage] = tropeSubpages
+			errScrape = catalogueScraper.ScrapeTropeCatalogue(context.Background(), tropePages)
+			tropeIds, _ = catalogue.GetTropeIDs()
+
+			errNoCatalogue = serviceScraperJson.ScrapeTropeCatalogue(context.Background(), tropePages)
+		})
+
+		AfterEach(func() {
+			catalogue.Close()
+		})
+
+		It("Should persist the scraped tropes", func() {
+			Expect(errScrape).To(BeNil())
+			Expect(tropeIds).To(HaveKey("ChekhovsGun"))
+		})
+
+		It("Should return an ErrNoCatalogue error if there's no catalogue repository", func() {
+			Expect(errNoCatalogue).To(Equal(scraper.ErrNoCatalogue))
+		})
+	})
+})
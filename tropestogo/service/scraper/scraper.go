@@ -1,15 +1,20 @@
 package scraper
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"net/url"
 	"regexp"
+	"runtime"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/PuerkitoBio/goquery"
 	"github.com/jlgallego99/TropesToGo/media"
+	"github.com/jlgallego99/TropesToGo/selectors"
+	"github.com/jlgallego99/TropesToGo/service/outcome"
 	"github.com/jlgallego99/TropesToGo/trope"
 	"github.com/jlgallego99/TropesToGo/tvtropespages"
 	"github.com/rs/zerolog/log"
@@ -26,28 +31,50 @@ var (
 	ErrUpdateDataset        = errors.New("can't update the dataset with the new scraped data from the work")
 
 	headerSelectors = []string{"h1", "h2", "h3", "h4", "h5", "h6"}
+
+	// ErrorClasses are the classes of the errors of the scraper on the outcome Report of a run
+	ErrorClasses = []outcome.ErrorClass{
+		{Name: "ErrUnknownPageStructure", Err: ErrUnknownPageStructure},
+		{Name: "ErrInvalidSubpage", Err: ErrInvalidSubpage},
+		{Name: "ErrNotWorkPage", Err: ErrNotWorkPage},
+		{Name: "ErrNotTvTropes", Err: ErrNotTvTropes},
+		{Name: "ErrEmptyDocument", Err: ErrEmptyDocument},
+		{Name: "ErrUpdateDataset", Err: ErrUpdateDataset},
+		{Name: "ErrMissingValues", Err: media.ErrMissingValues},
+		{Name: "ErrInvalidYear", Err: media.ErrInvalidYear},
+		{Name: "ErrUnknownMediaType", Err: media.ErrUnknownMediaType},
+	}
 )
 
 const (
-	TvTropesHostname         = "tvtropes.org"
-	TvTropesWeb              = "https://" + TvTropesHostname
-	TvTropesPmwiki           = "/pmwiki/pmwiki.php/"
-	TvTropesMainPath         = TvTropesPmwiki + "Main/"
-	WorkTitleSelector        = "h1.entry-title"
-	WorkIndexSelector        = WorkTitleSelector + " strong"
-	MainArticleSelector      = "#main-article"
-	TropeListSelector        = MainArticleSelector + " ul"
-	SubPagesNavSelector      = "nav.body-options"
-	SubPageListSelector      = "ul.subpage-links"
-	SubPageLinkSelector      = "a.subpage-link"
-	TropeTag                 = "a.twikilink"
-	TropeLinkSelector        = " ~ ul li " + TropeTag
-	TropeFolderSelector      = MainArticleSelector + " div.folderlabel"
-	FolderToggleFunction     = "toggleAllFolders();"
-	MainTropesSelector       = " ~ ul > li > " + TropeTag + ":first-child"
-	MainTropesFolderSelector = " ~ .folder > ul > li > " + TropeTag + ":first-child"
-	CurrentSubpageSelector   = ".curr-subpage"
-	CurrentUrlSelector       = "#current_url"
+	TvTropesHostname   = "tvtropes.org"
+	TvTropesWeb        = "https://" + TvTropesHostname
+	TvTropesPmwiki     = "/pmwiki/pmwiki.php/"
+	TvTropesMainPath   = TvTropesPmwiki + "Main/"
+	CreatorNamespace   = "Creator"
+	FranchiseNamespace = "Franchise"
+)
+
+// Selectors of the scraper on the default selector profile, kept for the code that used them before the selectors could be patched
+// The scraper uses the selectors of its profile, so changing these values doesn't change what it extracts
+//
+// Deprecated: use the Scraper selectors of selectors.DefaultProfile, or of the profile the scraper is configured with
+var (
+	WorkTitleSelector        = selectors.DefaultProfile().Scraper.WorkTitle
+	WorkIndexSelector        = selectors.DefaultProfile().Scraper.WorkIndex
+	MainArticleSelector      = selectors.DefaultProfile().Scraper.MainArticle
+	TropeListSelector        = selectors.DefaultProfile().Scraper.TropeList
+	SubPagesNavSelector      = selectors.DefaultProfile().Scraper.SubPagesNav
+	SubPageListSelector      = selectors.DefaultProfile().Scraper.SubPageList
+	SubPageLinkSelector      = selectors.DefaultProfile().Scraper.SubPageLink
+	TropeTag                 = selectors.DefaultProfile().Scraper.TropeTag
+	TropeLinkSelector        = selectors.DefaultProfile().Scraper.TropeLink
+	TropeFolderSelector      = selectors.DefaultProfile().Scraper.TropeFolder
+	FolderToggleFunction     = selectors.DefaultProfile().Scraper.FolderToggleFunction
+	MainTropesSelector       = selectors.DefaultProfile().Scraper.MainTropes
+	MainTropesFolderSelector = selectors.DefaultProfile().Scraper.MainTropesFolder
+	CurrentSubpageSelector   = selectors.DefaultProfile().Scraper.CurrentSubpage
+	CurrentUrlSelector       = selectors.DefaultProfile().Scraper.CurrentUrl
 )
 
 // ScraperConfig is an alias for a function that will accept a pointer to a ServiceScraper and modify its fields
@@ -59,11 +86,31 @@ type ScraperConfig func(ss *ServiceScraper) error
 type ServiceScraper struct {
 	// TvTropes dataset
 	data media.RepositoryMedia
+
+	// catalogue is the dataset of the scraped trope pages
+	catalogue trope.RepositoryCatalogue
+
+	// indexes classifies the scraped tropes on the indexes they belong to, or nil if they are left unclassified
+	indexes trope.IndexMapping
+
+	// profile holds the CSS selectors and patterns for extracting the data of the TvTropes pages
+	profile selectors.Profile
+
+	// report records the outcome of checking, scraping and persisting every page, or nil if they aren't recorded
+	report *outcome.Report
+
+	// workers is the maximum number of pages scraped at the same time
+	workers int
+
+	// addedUrls are the URLs of the pages whose Media has been added to the dataset but not persisted yet
+	// addedMutex is held while adding and persisting Media, so they are always the pages of the Media that the next Persist writes
+	addedUrls  []string
+	addedMutex sync.Mutex
 }
 
 // NewServiceScraper takes a variable amount of configuration functions, applies them and returns a ServiceScraper with all configs passed
 func NewServiceScraper(cfgs ...ScraperConfig) (*ServiceScraper, error) {
-	ss := &ServiceScraper{}
+	ss := &ServiceScraper{profile: selectors.DefaultProfile(), workers: runtime.NumCPU()}
 	for _, cfg := range cfgs {
 		err := cfg(ss)
 		if err != nil {
@@ -87,6 +134,73 @@ func ConfigMediaRepository(mr media.RepositoryMedia) ScraperConfig {
 	}
 }
 
+// ConfigCatalogueRepository defines a function that applies a RepositoryCatalogue so it can be used as a config when creating a ServiceScraper
+// It accepts any implementation of a RepositoryCatalogue, which is where the scraped trope pages are persisted
+func ConfigCatalogueRepository(cr trope.RepositoryCatalogue) ScraperConfig {
+	return func(ss *ServiceScraper) error {
+		if cr == nil {
+			return ErrInvalidField
+		}
+
+		ss.catalogue = cr
+		return nil
+	}
+}
+
+// ConfigIndexMapping defines a function that applies a trope IndexMapping so it can be used as a config when creating a ServiceScraper
+// Every scraped trope is classified on the indexes the mapping relates it with
+func ConfigIndexMapping(mapping trope.IndexMapping) ScraperConfig {
+	return func(ss *ServiceScraper) error {
+		if mapping == nil {
+			return ErrInvalidField
+		}
+
+		ss.indexes = mapping
+		return nil
+	}
+}
+
+// ConfigSelectors defines a function that applies a selector Profile so it can be used as a config when creating a ServiceScraper
+// The scraper finds the data of the TvTropes pages with the selectors and patterns of the profile instead of the default ones
+// It returns the validation error of the profile if it isn't valid
+func ConfigSelectors(profile selectors.Profile) ScraperConfig {
+	return func(ss *ServiceScraper) error {
+		if errValidate := profile.Validate(); errValidate != nil {
+			return fmt.Errorf("%w\n%w", ErrInvalidField, errValidate)
+		}
+
+		ss.profile = profile
+		return nil
+	}
+}
+
+// ConfigOutcomeReport defines a function that applies an outcome Report so it can be used as a config when creating a ServiceScraper
+// The scraper records on it whether every page gets through the check, scrape, subpages and persist stages
+func ConfigOutcomeReport(report *outcome.Report) ScraperConfig {
+	return func(ss *ServiceScraper) error {
+		if report == nil {
+			return ErrInvalidField
+		}
+
+		ss.report = report
+		return nil
+	}
+}
+
+// ConfigWorkers defines the maximum number of pages that are scraped at the same time, which by default is the number of CPUs
+// because parsing the pages is CPU-bound
+// It returns an ErrInvalidField error if the number isn't positive
+func ConfigWorkers(workers int) ScraperConfig {
+	return func(ss *ServiceScraper) error {
+		if workers <= 0 {
+			return ErrInvalidField
+		}
+
+		ss.workers = workers
+		return nil
+	}
+}
+
 // CheckTvTropesPage validates the Goquery document from a page object and checks if it's valid for scraping
 // If the page doesn't have a parsed document, it returns an ErrEmptyDocument error
 // It returns true if all checks passes
@@ -136,10 +250,10 @@ func (scraper *ServiceScraper) CheckIsWorkPage(doc *goquery.Document, url *url.U
 		return false, fmt.Errorf("%w: "+url.String(), ErrNotWorkPage)
 	}
 
-	if doc.Find(MainArticleSelector).Length() == 0 ||
-		doc.Find(SubPagesNavSelector).Find(SubPageListSelector).Find(SubPageLinkSelector).Length() == 0 ||
-		doc.Find(TropeListSelector).Length() == 0 ||
-		doc.Find(TropeListSelector+" "+TropeTag).Length() == 0 {
+	if doc.Find(scraper.profile.Scraper.MainArticle).Length() == 0 ||
+		doc.Find(scraper.profile.Scraper.SubPagesNav).Find(scraper.profile.Scraper.SubPageList).Find(scraper.profile.Scraper.SubPageLink).Length() == 0 ||
+		doc.Find(scraper.profile.Scraper.TropeList).Length() == 0 ||
+		doc.Find(scraper.profile.Scraper.TropeList+" "+scraper.profile.Scraper.TropeTag).Length() == 0 {
 		return false, ErrUnknownPageStructure
 	}
 
@@ -167,7 +281,7 @@ func (scraper *ServiceScraper) CheckTropeSection(doc *goquery.Document) (bool, e
 		return true, nil
 	}
 
-	tropeHref, exists := doc.Find(getAnyHeaderSelector(TropeLinkSelector)).First().Attr("href")
+	tropeHref, exists := doc.Find(selectors.AnyHeader(scraper.profile.Scraper.TropeLink)).First().Attr("href")
 	if exists && strings.Contains(tropeHref, TvTropesMainPath) {
 		return true, nil
 	}
@@ -179,8 +293,8 @@ func (scraper *ServiceScraper) CheckTropeSection(doc *goquery.Document) (bool, e
 // CheckTropesOnFolders checks the received goquery Document DOM Tree and validates whether tropes are presented on folders
 // It returns true if all checks passes
 func (scraper *ServiceScraper) CheckTropesOnFolders(doc *goquery.Document) bool {
-	folderFunctionName, existsFolderButton := doc.Find(TropeFolderSelector).Attr("onclick")
-	if existsFolderButton && folderFunctionName == FolderToggleFunction {
+	folderFunctionName, existsFolderButton := doc.Find(scraper.profile.Scraper.TropeFolder).Attr("onclick")
+	if existsFolderButton && folderFunctionName == scraper.profile.Scraper.FolderToggleFunction {
 		return true
 	}
 
@@ -192,7 +306,7 @@ func (scraper *ServiceScraper) CheckTropesOnFolders(doc *goquery.Document) bool
 // It returns a boolean meaning if the check passes or not
 func (scraper *ServiceScraper) CheckTropesOnSubpages(doc *goquery.Document) bool {
 	// Get the first word of the first element of the list, check if is an anchor to a subpage
-	tropeHref, exists := doc.Find(getAnyHeaderSelector(TropeLinkSelector)).First().Attr("href")
+	tropeHref, exists := doc.Find(selectors.AnyHeader(scraper.profile.Scraper.TropeLink)).First().Attr("href")
 	workTitle, _, _, _ := scraper.ScrapeWorkTitleAndYear(doc)
 
 	// Check if the link directs to a subpage with tropes
@@ -209,14 +323,14 @@ func (scraper *ServiceScraper) CheckTropesOnSubpages(doc *goquery.Document) bool
 func (scraper *ServiceScraper) CheckIsMainSubpage(doc *goquery.Document) bool {
 	_, _, _, errMediatype := scraper.ScrapeWorkTitleAndYear(doc)
 	title := scraper.ScrapeNamespace(doc)
-	currentUrl := doc.Find(CurrentUrlSelector).Text()
+	currentUrl := doc.Find(scraper.profile.Scraper.CurrentUrl).Text()
 
 	// Remove all non-alphanumeric characters from the title
 	r, _ := regexp.Compile(`[^\/\p{L}\p{N} ]+`)
 	title = r.ReplaceAllString(title, "")
 
 	title = strings.ToLower(strings.ReplaceAll(title, " ", ""))
-	r, _ = regexp.Compile(`\/` + title + `\/tropes[a-z]to[a-z]`)
+	r, _ = regexp.Compile(`\/` + title + `\/` + scraper.profile.Patterns.TropesSubpage)
 	matchUri := scraper.CheckSubpageUri(currentUrl, title)
 	matchTitle := r.MatchString(strings.ToLower(scraper.ScrapeSubpageFullTitle(doc)))
 
@@ -232,7 +346,7 @@ func (scraper *ServiceScraper) CheckSubpageUri(URI, title string) bool {
 	title = r.ReplaceAllString(title, "")
 
 	title = strings.ToLower(strings.ReplaceAll(title, " ", ""))
-	r, _ = regexp.Compile(`\/` + title + `\/tropes[a-z]to[a-z]`)
+	r, _ = regexp.Compile(`\/` + title + `\/` + scraper.profile.Patterns.TropesSubpage)
 	match := r.MatchString(strings.ToLower(URI))
 
 	return match
@@ -242,7 +356,7 @@ func (scraper *ServiceScraper) CheckSubpageUri(URI, title string) bool {
 // It checks if the SubWiki namespace is not a Media name, because that'll be the Main Work Page
 // Returns a true boolean if it's a SubWiki, a false if it's not
 func (scraper *ServiceScraper) CheckIsSubWiki(doc *goquery.Document) bool {
-	subpageUri, _ := doc.Find(CurrentSubpageSelector).Attr("href")
+	subpageUri, _ := doc.Find(scraper.profile.Scraper.CurrentSubpage).Attr("href")
 	namespace := strings.ToLower(scraper.ScrapeNamespace(doc))
 	title, year, _, errMediatype := scraper.ScrapeWorkTitleAndYear(doc)
 
@@ -259,20 +373,90 @@ func (scraper *ServiceScraper) CheckIsSubWiki(doc *goquery.Document) bool {
 	return matchUri && matchTitle && errMediatype != nil
 }
 
+// CheckTvTropesPages checks if every page of the TvTropesPages is valid for scraping, like CheckTvTropesPage does,
+// and records the outcome of the check of each of them on the outcome Report of the scraper
+// It returns the errors of all the pages that aren't valid joined, or nil if all of them can be scraped with ScrapeCheckedTvTropes
+func (scraper *ServiceScraper) CheckTvTropesPages(pages *tvtropespages.TvTropesPages) error {
+	var errPages []error
+	for page := range pages.Pages {
+		if errValid := scraper.checkTvTropesPage(page); errValid != nil {
+			errPages = append(errPages, errValid)
+		}
+	}
+
+	return errors.Join(errPages...)
+}
+
+// checkTvTropesPage checks if the page is valid for scraping, logging it and recording the outcome of the check on the outcome Report
+// It returns why the page isn't valid, or nil if it is
+func (scraper *ServiceScraper) checkTvTropesPage(page tvtropespages.Page) error {
+	valid, errValid := scraper.CheckTvTropesPage(page)
+	if valid && errValid == nil {
+		log.Info().Msg("SCRAPING: " + page.GetUrl().String())
+		scraper.recordSuccess(page.GetUrl().String(), outcome.CheckStage)
+		return nil
+	}
+
+	if errValid == nil {
+		errValid = fmt.Errorf("%w: "+page.GetUrl().String(), ErrUnknownPageStructure)
+	}
+	log.Error().Err(errValid).Msg("SCRAPING: " + page.GetUrl().String())
+	scraper.recordFailure(page.GetUrl().String(), outcome.CheckStage, errValid)
+
+	return errValid
+}
+
 // ScrapeTvTropes tries to scrape all pages and its subpages that are TvTropesPages by making HTTP requests to TvTropes
-// It only returns an error if it can't write or read the dataset, if the page can't be scraped it skips to the next
-func (scraper *ServiceScraper) ScrapeTvTropes(tvtropespages *tvtropespages.TvTropesPages) error {
-	for page, subPages := range tvtropespages.Pages {
-		if valid, err := scraper.CheckTvTropesPage(page); valid && err == nil {
-			log.Info().Msg("SCRAPING: " + page.GetUrl().String())
+// If a page can't be checked, scraped or added to the dataset it skips to the next, and its error is returned joined with the others at the end
+// along with the error of writing or reading the dataset, if any
+// Pages are checked and scraped in parallel by up to the configured number of workers, and persisted all at once at the end
+// only if at least one of them was scraped
+// If the ctx context is cancelled, it stops scraping, persists all the already scraped Media and also returns the context error
+func (scraper *ServiceScraper) ScrapeTvTropes(ctx context.Context, pages *tvtropespages.TvTropesPages) error {
+	return scraper.scrapeTvTropes(ctx, pages, true)
+}
 
-			scraper.ScrapeTvTropesPage(page, subPages)
-		} else {
-			log.Error().Err(err).Msg("SCRAPING: " + page.GetUrl().String())
+// ScrapeCheckedTvTropes scrapes the pages of the TvTropesPages like ScrapeTvTropes, but without checking them again,
+// because they have already been checked with CheckTvTropesPages
+func (scraper *ServiceScraper) ScrapeCheckedTvTropes(ctx context.Context, pages *tvtropespages.TvTropesPages) error {
+	return scraper.scrapeTvTropes(ctx, pages, false)
+}
+
+// AddCheckedTvTropes scrapes the pages of the TvTropesPages like ScrapeCheckedTvTropes and adds their Media to the dataset without persisting it,
+// so the Media of many calls can be persisted at once with PersistAdded
+// It returns the errors of the pages that couldn't be scraped or added joined, or nil if all of them have been added
+func (scraper *ServiceScraper) AddCheckedTvTropes(pages *tvtropespages.TvTropesPages) error {
+	var errPages []error
+	for page, subPages := range pages.Pages {
+		if errAdd := scraper.addTvTropesPage(page, subPages); errAdd != nil {
+			errPages = append(errPages, errAdd)
 		}
 	}
 
+	return errors.Join(errPages...)
+}
+
+// PersistAdded persists the Media added to the dataset since the last time it was persisted, recording on the outcome Report
+// whether the pages of the Media added with AddCheckedTvTropes could be persisted
+// It does nothing if no Media has been added, or returns the error of Persist if the dataset couldn't be written
+func (scraper *ServiceScraper) PersistAdded() error {
+	scraper.addedMutex.Lock()
+	defer scraper.addedMutex.Unlock()
+
+	if len(scraper.addedUrls) == 0 {
+		return nil
+	}
+
 	errPersist := scraper.Persist()
+	for _, addedUrl := range scraper.addedUrls {
+		if errPersist != nil {
+			scraper.recordFailure(addedUrl, outcome.PersistStage, errPersist)
+		} else {
+			scraper.recordSuccess(addedUrl, outcome.PersistStage)
+		}
+	}
+	scraper.addedUrls = nil
+
 	if errPersist != nil {
 		log.Error().Err(errPersist).Msg("Persisting the scraped data on the dataset")
 		return errPersist
@@ -281,11 +465,107 @@ func (scraper *ServiceScraper) ScrapeTvTropes(tvtropespages *tvtropespages.TvTro
 	return nil
 }
 
+// addTvTropesPage scrapes the page with its subPages and adds its Media to the dataset, keeping its URL until it's persisted with PersistAdded
+// It returns the error of scraping or adding the Media
+func (scraper *ServiceScraper) addTvTropesPage(page tvtropespages.Page, subPages *tvtropespages.TvTropesSubpages) error {
+	newMedia, errScrape := scraper.scrapeRecordedPage(page, subPages)
+	if errScrape != nil {
+		return errScrape
+	}
+
+	scraper.addedMutex.Lock()
+	defer scraper.addedMutex.Unlock()
+
+	if errAddMedia := scraper.addMedia(page, newMedia); errAddMedia != nil {
+		return errAddMedia
+	}
+	scraper.addedUrls = append(scraper.addedUrls, page.GetUrl().String())
+
+	return nil
+}
+
+// scrapeTvTropes scrapes and persists the pages for ScrapeTvTropes and ScrapeCheckedTvTropes, checking them first if check is set
+func (scraper *ServiceScraper) scrapeTvTropes(ctx context.Context, pages *tvtropespages.TvTropesPages, check bool) error {
+	var errPages []error
+	var errPagesMutex sync.Mutex
+	var running sync.WaitGroup
+	freeWorkers := make(chan struct{}, scraper.workers)
+
+	for page, subPages := range pages.Pages {
+		freeWorkers <- struct{}{}
+		if ctx.Err() != nil {
+			break
+		}
+
+		running.Add(1)
+		go func(page tvtropespages.Page, subPages *tvtropespages.TvTropesSubpages) {
+			defer func() {
+				<-freeWorkers
+				running.Done()
+			}()
+
+			var errPage error
+			if check {
+				errPage = scraper.checkTvTropesPage(page)
+			}
+			if errPage == nil {
+				errPage = scraper.addTvTropesPage(page, subPages)
+			}
+
+			if errPage != nil {
+				errPagesMutex.Lock()
+				errPages = append(errPages, errPage)
+				errPagesMutex.Unlock()
+			}
+		}(page, subPages)
+	}
+	running.Wait()
+
+	// If no page has been added there's nothing new to persist, so the errors of the pages are the real cause of the failure
+	errPages = append(errPages, scraper.PersistAdded(), ctx.Err())
+
+	return errors.Join(errPages...)
+}
+
 // ScrapeTvTropesPage accepts a main Work Page object and TvTropesSubpages object which contains all its subpages
 // Full scrapes its contents, extracting the title, year, media type and all tropes, finally returning a correctly formed media object with all the data
 // It calls sub functions for scraping the multiple parts and returns an error if some scraping has failed
 // If the page or subpages doesn't have a parsed document, it returns an ErrEmptyDocument error
+// The subpages that can't be scraped are skipped, so they are only recorded as failed on the outcome Report of the scraper
 func (scraper *ServiceScraper) ScrapeTvTropesPage(page tvtropespages.Page, subPages *tvtropespages.TvTropesSubpages) (media.Media, error) {
+	newMedia, errScrape := scraper.scrapeRecordedPage(page, subPages)
+	if errScrape != nil {
+		return media.Media{}, errScrape
+	}
+
+	return newMedia, scraper.addMedia(page, newMedia)
+}
+
+// scrapeRecordedPage extracts the Media object of a Work page and its subpages, recording the outcome of scraping it on the outcome Report
+func (scraper *ServiceScraper) scrapeRecordedPage(page tvtropespages.Page, subPages *tvtropespages.TvTropesSubpages) (media.Media, error) {
+	newMedia, errScrape := scraper.scrapeTvTropesPage(page, subPages)
+	if errScrape != nil {
+		scraper.recordFailure(page.GetUrl().String(), outcome.ScrapeStage, errScrape)
+		return media.Media{}, errScrape
+	}
+	scraper.recordSuccess(page.GetUrl().String(), outcome.ScrapeStage)
+
+	return newMedia, nil
+}
+
+// addMedia adds the newMedia scraped from the page to the dataset, recording on the outcome Report if it can't be added
+func (scraper *ServiceScraper) addMedia(page tvtropespages.Page, newMedia media.Media) error {
+	errAddMedia := scraper.data.AddMedia(newMedia)
+	if errAddMedia != nil {
+		log.Error().Msg("DUPLICATED MEDIA " + newMedia.GetWork().Title)
+		scraper.recordFailure(page.GetUrl().String(), outcome.PersistStage, errAddMedia)
+	}
+
+	return errAddMedia
+}
+
+// scrapeTvTropesPage extracts the Media object of a Work page and its subpages for ScrapeTvTropesPage, without adding it to the dataset
+func (scraper *ServiceScraper) scrapeTvTropesPage(page tvtropespages.Page, subPages *tvtropespages.TvTropesSubpages) (media.Media, error) {
 	doc := page.GetDocument()
 	if doc == nil {
 		return media.Media{}, fmt.Errorf("%w: "+page.GetUrl().String(), ErrEmptyDocument)
@@ -301,13 +581,14 @@ func (scraper *ServiceScraper) ScrapeTvTropesPage(page tvtropespages.Page, subPa
 	}
 
 	tropes := make(map[trope.Trope]struct{})
+	examples := make(map[trope.Trope][]trope.Example)
 	var errTropes error
 
 	if doc == nil {
 		return media.Media{}, ErrInvalidField
 	}
 
-	page, errNewPage := tvtropespages.NewPage(page.GetUrl().String(), false, nil)
+	page, errNewPage := tvtropespages.NewPage(context.Background(), page.GetUrl().String(), false, nil)
 	if errNewPage != nil {
 		return media.Media{}, fmt.Errorf("Error creating Page object \n%w", errNewPage)
 	}
@@ -322,12 +603,12 @@ func (scraper *ServiceScraper) ScrapeTvTropesPage(page tvtropespages.Page, subPa
 	if !scraper.CheckTropesOnSubpages(doc) {
 		var selector string
 		if scraper.CheckTropesOnFolders(doc) {
-			selector = getAnyHeaderSelector(MainTropesFolderSelector)
+			selector = selectors.AnyHeader(scraper.profile.Scraper.MainTropesFolder)
 		} else {
-			selector = getAnyHeaderSelector(MainTropesSelector)
+			selector = selectors.AnyHeader(scraper.profile.Scraper.MainTropes)
 		}
 
-		tropes, errTropes = scraper.ScrapeTropes(doc, selector)
+		tropes, examples, errTropes = scraper.ScrapeTropesWithExamples(doc, selector)
 		if errTropes != nil {
 			log.Error().Err(errTropes).Msg("SCRAPING MAIN TROPES FAILED " + page.GetUrl().String())
 			return media.Media{}, errTropes
@@ -335,36 +616,53 @@ func (scraper *ServiceScraper) ScrapeTvTropesPage(page tvtropespages.Page, subPa
 	}
 
 	// Scrape all subpages tropes (SubWikis and main SubPages if there are)
-	subpageTropes := scraper.ScrapeSubpageTropes(subDocs)
+	subpageTropes, subpageExamples, errSubpages := scraper.ScrapeSubpageTropes(subDocs)
+	if errSubpages != nil {
+		scraper.recordFailure(page.GetUrl().String(), outcome.SubpagesStage, errSubpages)
+	} else {
+		scraper.recordSuccess(page.GetUrl().String(), outcome.SubpagesStage)
+	}
 
 	for subTrope := range subpageTropes {
 		tropes[subTrope] = struct{}{}
 	}
 
+	for subTrope, subTropeExamples := range subpageExamples {
+		examples[subTrope] = append(examples[subTrope], subTropeExamples...)
+	}
+
 	newMedia, errNewMedia := media.NewMedia(title, year, subPages.LastUpdated, tropes, page, mediaIndex)
 	if errNewMedia != nil {
 		log.Error().Err(errNewMedia).Msg("SCRAPING FAILED")
 		return media.Media{}, errNewMedia
 	}
 
-	errAddMedia := scraper.data.AddMedia(newMedia)
-	if errAddMedia != nil {
-		log.Error().Msg("DUPLICATED MEDIA " + newMedia.GetWork().Title)
+	newMedia.SetMetadata(scraper.ScrapeWorkMetadata(doc))
+	newMedia.AddRelations(scraper.ScrapeRelations(page.GetUrl().String(), append([]*goquery.Document{doc}, subDocs...)...)...)
+
+	for exampleTrope, tropeExamples := range examples {
+		newMedia.AddExamples(exampleTrope, tropeExamples...)
 	}
 
-	return newMedia, errAddMedia
+	return newMedia, nil
 }
 
 // ScrapeWorkTitleAndYear traverses the received goquery Document DOM Tree and extracts
 // the title, the year and the media index from the title section of the article of a Work Page
-// It returns the title, the year, the media type and an ErrUnknownMediaType error if the media type isn't known
+// It returns the title, the year, the media type and an ErrUnknownMediaType error if the media type isn't known,
+// or an ErrUnknownPageStructure error if the title doesn't have a media index
 func (scraper *ServiceScraper) ScrapeWorkTitleAndYear(doc *goquery.Document) (string, string, media.MediaType, error) {
 	var title, year string
 	var mediaIndex media.MediaType
 	var errMediaIndex error
 
-	r, _ := regexp.Compile(`\s\((19|20)\d{2}\)`)
-	fullTitle := strings.TrimSpace(strings.Split(doc.Find(WorkTitleSelector).Text(), "/")[1])
+	_, fullTitle, hasIndex := strings.Cut(doc.Find(scraper.profile.Scraper.WorkTitle).Text(), "/")
+	if !hasIndex {
+		return "", "", media.UnknownMediaType, fmt.Errorf("%w: the title doesn't have a media index", ErrUnknownPageStructure)
+	}
+
+	r, _ := regexp.Compile(scraper.profile.Patterns.WorkYear)
+	fullTitle = strings.TrimSpace(fullTitle)
 	regexSubstringMatch := r.FindStringSubmatch(fullTitle)
 	if len(regexSubstringMatch) > 0 {
 		year = regexSubstringMatch[0]
@@ -385,16 +683,119 @@ func (scraper *ServiceScraper) ScrapeWorkTitleAndYear(doc *goquery.Document) (st
 	return title, year, mediaIndex, errMediaIndex
 }
 
+// ScrapeWorkMetadata traverses the received goquery Document DOM Tree and extracts the information about the Work
+// that is on the main article before the trope list: the description paragraphs, the image and its caption, the creators
+// and the other works mentioned on the description, and the franchises of the Work from the description and the index links of the page
+// Everything that isn't on the page is left empty
+func (scraper *ServiceScraper) ScrapeWorkMetadata(doc *goquery.Document) trope.WorkMetadata {
+	metadata := trope.WorkMetadata{
+		Description: make([]string, 0),
+		Creators:    make([]string, 0),
+		SeeAlso:     make([]string, 0),
+		Franchises:  make([]string, 0),
+	}
+
+	metadata.Image, _ = doc.Find(scraper.profile.Scraper.WorkImage).First().Attr("src")
+
+	caption := doc.Find(scraper.profile.Scraper.WorkImageCaption).First().Clone()
+	caption.Find(scraper.profile.Scraper.Note).Remove()
+	metadata.ImageCaption = collapseBlanks(caption.Text())
+
+	doc.Find(scraper.profile.Scraper.MainArticle).Children().EachWithBreak(func(_ int, child *goquery.Selection) bool {
+		if child.Is(scraper.profile.Scraper.DescriptionEnd) {
+			return false
+		}
+
+		paragraph := collapseBlanks(child.Text())
+		if !child.Is("p") || paragraph == "" {
+			return true
+		}
+		metadata.Description = append(metadata.Description, paragraph)
+
+		child.Find(scraper.profile.Scraper.TropeTag).Each(func(_ int, link *goquery.Selection) {
+			namespace, pageId := getPageNamespaceAndId(link)
+			if namespace == CreatorNamespace {
+				metadata.Creators = appendUnique(metadata.Creators, collapseBlanks(link.Text()))
+			} else if namespace == FranchiseNamespace {
+				metadata.Franchises = appendUnique(metadata.Franchises, pageId)
+			} else if _, errMediaType := media.ToMediaType(namespace); errMediaType == nil {
+				metadata.SeeAlso = appendUnique(metadata.SeeAlso, TvTropesWeb+TvTropesPmwiki+namespace+"/"+pageId)
+			}
+		})
+
+		return true
+	})
+
+	doc.Find(scraper.profile.Scraper.SectionIndexLink).Each(func(_ int, link *goquery.Selection) {
+		if namespace, pageId := getPageNamespaceAndId(link); namespace == FranchiseNamespace {
+			metadata.Franchises = appendUnique(metadata.Franchises, pageId)
+		}
+	})
+
+	return metadata
+}
+
+// ScrapeRelations traverses the received goquery Documents of a Work page and its subpages and extracts the links
+// to the pages of other Works, with their anchor text and the sentence where they are, classifying the kind of relationship from it
+// Links to the workUrl Work itself, to creators and to pages that aren't from works are ignored
+// It returns one relation for each related Work, the first one found unless a later one has a known kind of relationship
+func (scraper *ServiceScraper) ScrapeRelations(workUrl string, docs ...*goquery.Document) []trope.Relation {
+	relations := make([]trope.Relation, 0)
+	relationIndexes := make(map[string]int)
+
+	for _, doc := range docs {
+		doc.Find(scraper.profile.Scraper.WorkLink).Each(func(_ int, link *goquery.Selection) {
+			namespace, pageId := getPageNamespaceAndId(link)
+			mediaType, errMediaType := media.ToMediaType(namespace)
+			if errMediaType != nil || mediaType == media.Creator {
+				return
+			}
+
+			relationUrl := TvTropesWeb + TvTropesPmwiki + namespace + "/" + pageId
+			if relationUrl == workUrl {
+				return
+			}
+
+			anchorText := collapseBlanks(link.Text())
+			context := getSentence(collapseBlanks(link.Closest(scraper.profile.Scraper.RelationContext).Text()), anchorText)
+			relation, errRelation := trope.NewRelation(relationUrl, anchorText, context, trope.ClassifyRelation(mediaType == media.Franchise, context))
+			if errRelation != nil {
+				return
+			}
+
+			if index, found := relationIndexes[relationUrl]; !found {
+				relationIndexes[relationUrl] = len(relations)
+				relations = append(relations, relation)
+			} else if relations[index].Type == trope.ReferencedRelation && relation.Type != trope.ReferencedRelation {
+				relations[index] = relation
+			}
+		})
+	}
+
+	return relations
+}
+
 // ScrapeTropes traverses the received goquery Document DOM Tree and extracts all the tropes that are in a list or in folders
 // The method of finding tropes depends on the selector parameter, so this method can extract all kinds of tropes
+// Each trope is classified on the indexes of the scraper IndexMapping, or on the UnknownTropeIndex if it isn't on it
 // It returns a set (map of trope keys and empty values) of all the unique tropes found on the web page
 func (scraper *ServiceScraper) ScrapeTropes(doc *goquery.Document, selector string) (map[trope.Trope]struct{}, error) {
+	tropes, _, errTropes := scraper.ScrapeTropesWithExamples(doc, selector)
+
+	return tropes, errTropes
+}
+
+// ScrapeTropesWithExamples extracts the same tropes as ScrapeTropes, along with the examples of each trope on the web page,
+// which are the bullets whose first link is the trope
+// It returns the set of all the unique tropes found on the web page and a map relating them with their examples
+func (scraper *ServiceScraper) ScrapeTropesWithExamples(doc *goquery.Document, selector string) (map[trope.Trope]struct{}, map[trope.Trope][]trope.Example, error) {
 	tropes := make(map[trope.Trope]struct{}, 0)
+	examples := make(map[trope.Trope][]trope.Example)
 	var newTrope trope.Trope
 	var newTropeError error
 
 	if doc == nil || selector == "" {
-		return make(map[trope.Trope]struct{}), ErrInvalidField
+		return make(map[trope.Trope]struct{}), make(map[trope.Trope][]trope.Example), ErrInvalidField
 	}
 
 	doc.Find(selector).Each(func(_ int, selection *goquery.Selection) {
@@ -408,25 +809,96 @@ func (scraper *ServiceScraper) ScrapeTropes(doc *goquery.Document, selector stri
 				subPage = scraper.ScrapeNamespace(doc)
 			}
 
-			newTrope, newTropeError = trope.NewTrope(strings.Split(tropeUri, "/")[4], trope.TropeIndex(0), subPage)
+			title := strings.Split(tropeUri, "/")[4]
+			if indexes := scraper.indexes.GetIndexes(title); len(indexes) > 0 {
+				newTrope, newTropeError = trope.NewTrope(title, indexes[0], subPage, indexes[1:]...)
+			} else {
+				newTrope, newTropeError = trope.NewTrope(title, trope.UnknownTropeIndex, subPage)
+			}
 			if newTropeError == nil && tropeUri == TvTropesMainPath+newTrope.GetTitle() {
 				tropes[newTrope] = struct{}{}
+
+				if example, isExample := scraper.ScrapeExample(doc, selection); isExample && !containsExample(examples[newTrope], example) {
+					examples[newTrope] = append(examples[newTrope], example)
+				}
 			}
 		}
 	})
 
 	if newTropeError != nil {
-		return make(map[trope.Trope]struct{}), newTropeError
+		return make(map[trope.Trope]struct{}), make(map[trope.Trope][]trope.Example), newTropeError
 	}
 
-	return tropes, nil
+	return tropes, examples, nil
+}
+
+// ScrapeExample extracts the example of the trope linked by the tropeLink selection of the doc page, which is the bullet of the list that has it as its first link
+// The plain text and the raw HTML of the example leave out its sub-bullets, which are extracted as a list of their plain texts,
+// and the spoiler spans of the bullet and its sub-bullets are extracted on their own
+// The example also has the label of the folder that holds the bullet, the closest header before it, the URL of its page and its position on the list
+// It returns false if the link isn't on a bullet or it isn't its first link
+func (scraper *ServiceScraper) ScrapeExample(doc *goquery.Document, tropeLink *goquery.Selection) (trope.Example, bool) {
+	item := tropeLink.Closest(scraper.profile.Scraper.TropeExampleItem)
+	if item.Length() == 0 {
+		return trope.Example{}, false
+	}
+
+	bullet := item.Clone()
+	bullet.Find("ul").Remove()
+
+	tropeHref, _ := tropeLink.Attr("href")
+	firstHref, _ := bullet.Find(scraper.profile.Scraper.TropeTag).First().Attr("href")
+	if tropeHref != firstHref {
+		return trope.Example{}, false
+	}
+
+	subItems := make([]string, 0)
+	item.ChildrenFiltered("ul").ChildrenFiltered(scraper.profile.Scraper.TropeExampleItem).Each(func(_ int, subItem *goquery.Selection) {
+		if subItemText := collapseBlanks(subItem.Text()); subItemText != "" {
+			subItems = append(subItems, subItemText)
+		}
+	})
+
+	spoilers := make([]string, 0)
+	item.Find(scraper.profile.Scraper.Spoiler).Each(func(_ int, spoiler *goquery.Selection) {
+		if spoilerText := collapseBlanks(spoiler.Text()); spoilerText != "" {
+			spoilers = append(spoilers, spoilerText)
+		}
+	})
+
+	html, _ := bullet.Html()
+	example, errExample := trope.NewExample(collapseBlanks(bullet.Text()), strings.TrimSpace(html), subItems, spoilers, scraper.ScrapeLocation(doc, item))
+
+	return example, errExample == nil
+}
+
+// ScrapeLocation extracts where the item bullet is on the doc page: the label of the folder that holds it, the closest header before it
+// on the main article, the URL of the page and the position of the bullet on its list, starting at 1
+func (scraper *ServiceScraper) ScrapeLocation(doc *goquery.Document, item *goquery.Selection) trope.Location {
+	location := trope.Location{
+		SubpageURL: strings.TrimSpace(doc.Find(scraper.profile.Scraper.CurrentUrl).Text()),
+		Position:   item.Index() + 1,
+	}
+
+	if folder := item.Closest(scraper.profile.Scraper.Folder); folder.Length() > 0 {
+		location.Folder = collapseBlanks(folder.PrevFiltered(scraper.profile.Scraper.FolderLabel).Text())
+	}
+
+	// The header is a sibling of the element of the main article that holds the bullet
+	articleChild := item
+	if ancestors := item.ParentsUntil(scraper.profile.Scraper.MainArticle); ancestors.Length() > 0 && ancestors.Last().Parent().Is(scraper.profile.Scraper.MainArticle) {
+		articleChild = ancestors.Last()
+	}
+	location.Header = collapseBlanks(articleChild.PrevAllFiltered(strings.Join(headerSelectors, ", ")).First().Text())
+
+	return location
 }
 
 // ScrapeSubpageFullTitle scrapes the full title of any Work subpage from a Goquery document
 // (<Title>/<TropesXtoY> for main tropes subpages and <Namespace>/<Title>) for SubWikis)
 // Returns a correctly formatted string without blanks for comparing with URIs
 func (scraper *ServiceScraper) ScrapeSubpageFullTitle(subDoc *goquery.Document) string {
-	subPageTitle := "/" + strings.ReplaceAll(strings.ReplaceAll(subDoc.Find(WorkTitleSelector).Text(), "\n", ""), " ", "")
+	subPageTitle := "/" + strings.ReplaceAll(strings.ReplaceAll(subDoc.Find(scraper.profile.Scraper.WorkTitle).Text(), "\n", ""), " ", "")
 
 	return subPageTitle
 }
@@ -434,41 +906,48 @@ func (scraper *ServiceScraper) ScrapeSubpageFullTitle(subDoc *goquery.Document)
 // ScrapeSubpageTropes extracts all tropes that divided into subpages, whether they are of subpages with main tropes or SubWikis with secondary tropes
 // It depends on the selector passed and traverses the DOM tree document searching for subpages whose URI has a known structure and have the Work title string
 // It performs various ScrapeTropes calls for each of the subpages, adding its tropes to the trope list
-// Returns a trope list of all tropes found on the different subpages
-// If the subpage can't be scraped, it returns an ErrInvalidSubpage error
-func (scraper *ServiceScraper) ScrapeSubpageTropes(subDocs []*goquery.Document) map[trope.Trope]struct{} {
+// Returns a trope list of all tropes found on the different subpages along with their examples
+// If a subpage can't be scraped, it's skipped and an ErrInvalidSubpage error with its URI is joined to the returned error
+func (scraper *ServiceScraper) ScrapeSubpageTropes(subDocs []*goquery.Document) (map[trope.Trope]struct{}, map[trope.Trope][]trope.Example, error) {
 	tropes := make(map[trope.Trope]struct{})
+	examples := make(map[trope.Trope][]trope.Example)
+	var errSubpages error
 
 	for _, subDoc := range subDocs {
 		if scraper.CheckIsMainSubpage(subDoc) || scraper.CheckIsSubWiki(subDoc) {
 			var selector string
 			if scraper.CheckIsSubWiki(subDoc) {
-				selector = TropeTag
+				selector = scraper.profile.Scraper.TropeTag
 			} else if scraper.CheckTropesOnFolders(subDoc) {
-				selector = getAnyHeaderSelector(MainTropesFolderSelector)
+				selector = selectors.AnyHeader(scraper.profile.Scraper.MainTropesFolder)
 			} else {
-				selector = getAnyHeaderSelector(MainTropesSelector)
+				selector = selectors.AnyHeader(scraper.profile.Scraper.MainTropes)
 			}
 
-			subpageTropes, err := scraper.ScrapeTropes(subDoc, selector)
+			subpageTropes, subpageExamples, err := scraper.ScrapeTropesWithExamples(subDoc, selector)
 			if err == nil {
 				for subpageTrope := range subpageTropes {
 					tropes[subpageTrope] = struct{}{}
 				}
+
+				for subpageTrope, subpageTropeExamples := range subpageExamples {
+					examples[subpageTrope] = append(examples[subpageTrope], subpageTropeExamples...)
+				}
 			}
 		} else {
-			failedUri, _ := subDoc.Find(CurrentSubpageSelector).Attr("href")
+			failedUri, _ := subDoc.Find(scraper.profile.Scraper.CurrentSubpage).Attr("href")
 			log.Error().Err(ErrInvalidSubpage).Msg("SCRAPING SUBTROPES FAILED " + failedUri)
+			errSubpages = errors.Join(errSubpages, fmt.Errorf("%w: "+failedUri, ErrInvalidSubpage))
 		}
 	}
 
-	return tropes
+	return tropes, examples, errSubpages
 }
 
 // ScrapeNamespace extracts the namespace from a Goquery document of any Work page or subpage
 // It returns the namespace string
 func (scraper *ServiceScraper) ScrapeNamespace(doc *goquery.Document) string {
-	return strings.ReplaceAll(strings.Trim(doc.Find(WorkIndexSelector).First().Text(), " /"), " ", "")
+	return strings.ReplaceAll(strings.Trim(doc.Find(scraper.profile.Scraper.WorkIndex).First().Text(), " /"), " ", "")
 }
 
 // GetScrapedPages returns a map of all the string URLs of the and the last time they were updated
@@ -478,18 +957,61 @@ func (scraper *ServiceScraper) GetScrapedPages() (map[string]time.Time, error) {
 }
 
 // UpdateDataset receives an array of TvTropes changes pages and updates all Media in the existing dataset that have had changes
-func (scraper *ServiceScraper) UpdateDataset(changedPages *tvtropespages.TvTropesPages) error {
+// The pages that can't be scraped or updated are recorded as failed on the outcome Report, and the rest of them are still updated
+// Pages are scraped and updated in parallel by up to the configured number of workers
+// It returns the errors of all the failed pages joined, or nil if every page has been updated
+// If the ctx context is cancelled, it stops updating and also returns the context error, keeping the already updated Media
+func (scraper *ServiceScraper) UpdateDataset(ctx context.Context, changedPages *tvtropespages.TvTropesPages) error {
+	var errPages []error
+	var errPagesMutex sync.Mutex
+	var running sync.WaitGroup
+	freeWorkers := make(chan struct{}, scraper.workers)
+
 	for page, subPages := range changedPages.Pages {
-		newUpdatedMedia, errScrape := scraper.ScrapeTvTropesPage(page, subPages)
-		if errScrape != nil {
-			return errScrape
+		freeWorkers <- struct{}{}
+		if ctx.Err() != nil {
+			break
 		}
 
-		errUpdate := scraper.data.UpdateMedia(newUpdatedMedia.GetWork().Title, newUpdatedMedia.GetWork().Year, newUpdatedMedia)
-		if errUpdate != nil {
-			return fmt.Errorf("%w: "+newUpdatedMedia.GetWork().Title+newUpdatedMedia.GetWork().Year, ErrUpdateDataset)
-		}
+		running.Add(1)
+		go func(page tvtropespages.Page, subPages *tvtropespages.TvTropesSubpages) {
+			defer func() {
+				<-freeWorkers
+				running.Done()
+			}()
+
+			if errUpdate := scraper.updatePage(page, subPages); errUpdate != nil {
+				errPagesMutex.Lock()
+				errPages = append(errPages, errUpdate)
+				errPagesMutex.Unlock()
+			}
+		}(page, subPages)
 	}
+	running.Wait()
+
+	if ctx.Err() != nil {
+		errPages = append(errPages, ctx.Err())
+	}
+
+	return errors.Join(errPages...)
+}
+
+// updatePage scrapes the page with its subPages and updates its Media on the dataset for UpdateDataset,
+// recording the outcome of the update on the outcome Report
+// It returns an ErrUpdateDataset error if it couldn't be scraped or updated
+func (scraper *ServiceScraper) updatePage(page tvtropespages.Page, subPages *tvtropespages.TvTropesSubpages) error {
+	newUpdatedMedia, errScrape := scraper.ScrapeTvTropesPage(page, subPages)
+	if errScrape != nil {
+		return fmt.Errorf("%w: "+page.GetUrl().String()+"\n%w", ErrUpdateDataset, errScrape)
+	}
+
+	errUpdate := scraper.data.UpdateMedia(newUpdatedMedia.GetWork().Title, newUpdatedMedia.GetWork().Year, newUpdatedMedia)
+	if errUpdate != nil {
+		errUpdate = fmt.Errorf("%w: "+newUpdatedMedia.GetWork().Title+newUpdatedMedia.GetWork().Year+"\n%w", ErrUpdateDataset, errUpdate)
+		scraper.recordFailure(page.GetUrl().String(), outcome.PersistStage, errUpdate)
+		return errUpdate
+	}
+	scraper.recordSuccess(page.GetUrl().String(), outcome.PersistStage)
 
 	return nil
 }
@@ -501,12 +1023,79 @@ func (scraper *ServiceScraper) Persist() error {
 	return scraper.data.Persist()
 }
 
-// getAnyHeaderSelector builds a CSS path selector from a tropeSelector, adding all header identifiers
-func getAnyHeaderSelector(tropeSelector string) string {
-	selector := make([]string, 0)
-	for _, headerSelector := range headerSelectors {
-		selector = append(selector, headerSelector+tropeSelector)
+// recordSuccess records on the outcome Report of the scraper, if it has one, that the page at pageUrl got through the stage
+func (scraper *ServiceScraper) recordSuccess(pageUrl string, stage outcome.Stage) {
+	if scraper.report != nil {
+		scraper.report.AddSuccess(pageUrl, stage, 0)
+	}
+}
+
+// recordFailure records on the outcome Report of the scraper, if it has one, that the page at pageUrl failed on the stage with the errFailed error
+func (scraper *ServiceScraper) recordFailure(pageUrl string, stage outcome.Stage, errFailed error) {
+	if scraper.report != nil {
+		scraper.report.AddFailure(pageUrl, stage, errFailed, 0)
+	}
+}
+
+// collapseBlanks trims a text and replaces every run of blanks inside it with a single space
+func collapseBlanks(text string) string {
+	return strings.Join(strings.Fields(text), " ")
+}
+
+// getPageNamespaceAndId splits the link to a TvTropes page into the namespace and the ID of the page
+// Both are empty if the link isn't to a page of TvTropes
+func getPageNamespaceAndId(link *goquery.Selection) (string, string) {
+	href, _ := link.Attr("href")
+	href = strings.TrimPrefix(href, TvTropesWeb)
+	if !strings.HasPrefix(href, TvTropesPmwiki) || strings.ContainsAny(href, "?#") {
+		return "", ""
 	}
 
-	return strings.Join(selector, ",")
+	namespace, pageId, found := strings.Cut(strings.TrimPrefix(href, TvTropesPmwiki), "/")
+	if !found || pageId == "" || strings.Contains(pageId, "/") {
+		return "", ""
+	}
+
+	return namespace, pageId
+}
+
+// getSentence returns the sentence of the text that contains the anchor, or the whole text if the anchor isn't on it
+// Sentences end on a full stop, exclamation or question mark followed by a blank, but never inside the anchor
+func getSentence(text, anchor string) string {
+	anchorStart := strings.Index(text, anchor)
+	if anchorStart < 0 {
+		return text
+	}
+	anchorEnd := anchorStart + len(anchor)
+
+	start := 0
+	for end := 0; end < anchorStart; end++ {
+		if isSentenceEnd(text, end) {
+			start = end + 1
+		}
+	}
+
+	for end := anchorEnd; end < len(text); end++ {
+		if isSentenceEnd(text, end) {
+			return strings.TrimSpace(text[start : end+1])
+		}
+	}
+
+	return strings.TrimSpace(text[start:])
+}
+
+// isSentenceEnd checks if the character at the position of the text ends a sentence
+func isSentenceEnd(text string, position int) bool {
+	return strings.ContainsRune(".!?", rune(text[position])) && (position+1 == len(text) || text[position+1] == ' ')
+}
+
+// containsExample checks if there's already an example with the same HTML on the examples
+func containsExample(examples []trope.Example, example trope.Example) bool {
+	for _, existing := range examples {
+		if existing.HTML == example.HTML {
+			return true
+		}
+	}
+
+	return false
 }
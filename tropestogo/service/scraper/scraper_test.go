@@ -1,6 +1,7 @@
 package scraper_test
 
 import (
+	"context"
 	"encoding/csv"
 	"encoding/json"
 	"errors"
@@ -13,6 +14,8 @@ import (
 	"github.com/jlgallego99/TropesToGo/media"
 	"github.com/jlgallego99/TropesToGo/media/csv_dataset"
 	"github.com/jlgallego99/TropesToGo/media/json_dataset"
+	"github.com/jlgallego99/TropesToGo/selectors"
+	"github.com/jlgallego99/TropesToGo/service/outcome"
 	"github.com/jlgallego99/TropesToGo/service/scraper"
 	trope "github.com/jlgallego99/TropesToGo/trope"
 	"github.com/jlgallego99/TropesToGo/tvtropespages"
@@ -46,7 +49,8 @@ var (
 		"https://tvtropes.org/pmwiki/pmwiki.php/Awesome/Oldboy2003", "https://tvtropes.org/pmwiki/pmwiki.php/Fridge/Oldboy2003",
 		"https://tvtropes.org/pmwiki/pmwiki.php/Laconic/Oldboy2003", "https://tvtropes.org/pmwiki/pmwiki.php/Trivia/Oldboy2003",
 		"https://tvtropes.org/pmwiki/pmwiki.php/YMMV/Oldboy2003", "https://tvtropes.org/pmwiki/pmwiki.php/VideoExamples/Oldboy2003"}
-	headers = []string{"title", "year", "lastupdated", "url", "mediatype", "tropes", "subtropes", "subtropes_namespaces"}
+	headers = []string{"title", "year", "lastupdated", "url", "mediatype", "tropes", "subtropes", "subtropes_namespaces", "tropes_indexes", "subtropes_indexes",
+		"tropes_examples", "subtropes_examples", "description", "image", "image_caption", "creators", "see_also", "franchises", "relations"}
 )
 
 // A scraper service for test purposes
@@ -237,6 +241,463 @@ var _ = Describe("Scraper", func() {
 		})
 	})
 
+	Describe("Classify the scraped tropes on the trope indexes", func() {
+		var classifiedTropes map[trope.Trope]struct{}
+		var errClassify error
+
+		BeforeEach(func() {
+			mapping := trope.NewIndexMapping()
+			mapping.Add("HallwayFight", trope.GenreTrope)
+			mapping.Add("AwfulTruth", trope.NarrativeTrope)
+			mapping.Add("AwfulTruth", trope.TopicalTrope)
+
+			classifyingScraper, _ := scraper.NewServiceScraper(scraper.ConfigIndexMapping(mapping))
+			pageReader, _ := os.Open(workResources[0])
+			defer pageReader.Close()
+			doc, _ := goquery.NewDocumentFromReader(pageReader)
+
+			classifiedTropes, errClassify = classifyingScraper.ScrapeTropes(doc, selectors.DefaultProfile().Scraper.TropeList+" li "+selectors.DefaultProfile().Scraper.TropeTag)
+		})
+
+		It("Shouldn't return an error", func() {
+			Expect(errClassify).To(BeNil())
+		})
+
+		It("Should classify the tropes on all of their indexes and leave the rest unclassified", func() {
+			classified := 0
+			for classifiedTrope := range classifiedTropes {
+				switch classifiedTrope.GetTitle() {
+				case "HallwayFight":
+					classified++
+					Expect(classifiedTrope.GetIndex()).To(Equal(trope.GenreTrope))
+					Expect(classifiedTrope.GetIndexes().String()).To(Equal("GenreTrope"))
+				case "AwfulTruth":
+					classified++
+					Expect(classifiedTrope.GetIndex()).To(Equal(trope.NarrativeTrope))
+					Expect(classifiedTrope.GetIndexes().String()).To(Equal("NarrativeTrope|TopicalTrope"))
+				default:
+					Expect(classifiedTrope.GetIndex()).To(Equal(trope.UnknownTropeIndex))
+					Expect(classifiedTrope.GetIndexes().GetIndexes()).To(BeEmpty())
+				}
+			}
+			Expect(classified).To(Equal(2))
+		})
+
+		It("Shouldn't accept an empty mapping configuration", func() {
+			_, errNilMapping := scraper.NewServiceScraper(scraper.ConfigIndexMapping(nil))
+			Expect(errNilMapping).To(Equal(scraper.ErrInvalidField))
+		})
+	})
+
+	Describe("Scrape with a selector profile", func() {
+		var validDefault, validPatched bool
+		var patchedTitle string
+
+		BeforeEach(func() {
+			tvTropesUrl, _ := url.Parse(works[0])
+			pageReader, _ := os.Open(workResources[0])
+			defer pageReader.Close()
+			doc, _ := goquery.NewDocumentFromReader(pageReader)
+
+			profile := selectors.DefaultProfile()
+			profile.Scraper.MainArticle = "#moved-article"
+			profile.Patterns.WorkYear = `\s\[(19|20)\d{2}\]`
+			patchedScraper, errPatched := scraper.NewServiceScraper(scraper.ConfigSelectors(profile))
+			Expect(errPatched).To(BeNil())
+
+			validDefault, _ = serviceScraperJson.CheckValidWorkPage(doc, tvTropesUrl)
+			validPatched, _ = patchedScraper.CheckValidWorkPage(doc, tvTropesUrl)
+			patchedTitle, _, _, _ = patchedScraper.ScrapeWorkTitleAndYear(doc)
+		})
+
+		It("Should find the data of the page with the selectors and patterns of the profile", func() {
+			Expect(validDefault).To(BeTrue())
+			Expect(validPatched).To(BeFalse())
+			Expect(patchedTitle).To(Equal("Oldboy (2003)"))
+		})
+
+		It("Shouldn't accept an invalid profile", func() {
+			profile := selectors.DefaultProfile()
+			profile.Scraper.TropeLink = "~ ul li [["
+			_, errInvalid := scraper.NewServiceScraper(scraper.ConfigSelectors(profile))
+
+			Expect(errors.Is(errInvalid, scraper.ErrInvalidField)).To(BeTrue())
+			Expect(errors.Is(errInvalid, selectors.ErrInvalidSelector)).To(BeTrue())
+		})
+
+		It("Should keep the deprecated selectors with the values of the default profile", func() {
+			pageReader, _ := os.Open(workResources[0])
+			defer pageReader.Close()
+			doc, _ := goquery.NewDocumentFromReader(pageReader)
+
+			Expect(scraper.MainArticleSelector).To(Equal(selectors.DefaultProfile().Scraper.MainArticle))
+			Expect(scraper.TropeLinkSelector).To(Equal(selectors.DefaultProfile().Scraper.TropeLink))
+			Expect(scraper.CurrentUrlSelector).To(Equal(selectors.DefaultProfile().Scraper.CurrentUrl))
+			Expect(doc.Find(scraper.TropeListSelector + " li " + scraper.TropeTag).Length()).To(BeNumerically(">", 0))
+			Expect(doc.Find(scraper.WorkTitleSelector).Text()).To(ContainSubstring("Oldboy"))
+		})
+	})
+
+	Describe("Record the outcome of scraping Work pages", func() {
+		var report *outcome.Report
+		var errReportScraping error
+		const invalidSubpageUrl = "https://tvtropes.org/pmwiki/pmwiki.php/Oldboy2003/TropesAToD"
+
+		BeforeEach(func() {
+			var errReport error
+			report, errReport = outcome.NewReport("outcomes.json", outcome.ConfigErrorClasses(scraper.ErrorClasses...))
+			Expect(errReport).To(BeNil())
+
+			outcomesRepository, errRepository := json_dataset.NewJSONRepository("outcomes")
+			Expect(errRepository).To(BeNil())
+			defer outcomesRepository.Close()
+			reportScraper, errReportScraper := scraper.NewServiceScraper(scraper.ConfigMediaRepository(outcomesRepository),
+				scraper.ConfigOutcomeReport(report))
+			Expect(errReportScraper).To(BeNil())
+
+			// Oldboy with a subpage that can't be scraped, and A New Hope without its contents
+			pages := createTvTropesPagesWithEmptySubpages(works[0], workResources[0])
+			invalidSubpages, _ := loadSubpageFiles([]string{workResources[3]}, []string{invalidSubpageUrl})
+			for page := range pages.Pages {
+				pages.Pages[page] = invalidSubpages
+			}
+
+			emptyPages := createTvTropesPagesWithEmptySubpages(works[1], workResources[3])
+			for page, subPages := range emptyPages.Pages {
+				pages.Pages[page] = subPages
+			}
+
+			errReportScraping = reportScraper.ScrapeTvTropes(context.Background(), pages)
+		})
+
+		AfterEach(func() {
+			os.Remove("outcomes.json")
+		})
+
+		It("Should only return the error of the page that can't be scraped", func() {
+			Expect(errors.Is(errReportScraping, scraper.ErrUnknownPageStructure)).To(BeTrue())
+			Expect(errors.Is(errReportScraping, json_dataset.ErrPersist)).To(BeFalse())
+		})
+
+		It("Should record the stages that the scraped page got through", func() {
+			for _, stage := range []outcome.Stage{outcome.CheckStage, outcome.ScrapeStage, outcome.PersistStage} {
+				Expect(report.GetOutcomes()).To(ContainElement(And(
+					HaveField("URL", works[0]), HaveField("Stage", stage), HaveField("Status", outcome.Succeeded))))
+			}
+		})
+
+		It("Should record the subpages that couldn't be scraped", func() {
+			Expect(report.GetOutcomes()).To(ContainElement(And(
+				HaveField("URL", works[0]), HaveField("Stage", outcome.SubpagesStage), HaveField("ErrorClass", "ErrInvalidSubpage"))))
+		})
+
+		It("Should record the pages that can't be scraped with the class of their error", func() {
+			Expect(report.GetFailedUrls()).To(ConsistOf(works[0], works[1]))
+			Expect(report.GetOutcomes()).To(ContainElement(And(
+				HaveField("URL", works[1]), HaveField("Stage", outcome.CheckStage), HaveField("Status", outcome.Failed),
+				HaveField("ErrorClass", Not(Equal(outcome.UnknownErrorClass))))))
+		})
+
+		It("Shouldn't accept an empty report", func() {
+			_, errNilReport := scraper.NewServiceScraper(scraper.ConfigOutcomeReport(nil))
+			Expect(errNilReport).To(Equal(scraper.ErrInvalidField))
+		})
+	})
+
+	Describe("Check Work pages before scraping them", func() {
+		var report *outcome.Report
+		var errValidCheck, errInvalidCheck, errCheckedScraping, errDuplicatedScraping, errInvalidScraping error
+
+		BeforeEach(func() {
+			var errReport error
+			report, errReport = outcome.NewReport("checked.json", outcome.ConfigErrorClasses(scraper.ErrorClasses...))
+			Expect(errReport).To(BeNil())
+
+			checkedRepository, errRepository := json_dataset.NewJSONRepository("checked")
+			Expect(errRepository).To(BeNil())
+			defer checkedRepository.Close()
+			checkScraper, errCheckScraper := scraper.NewServiceScraper(scraper.ConfigMediaRepository(checkedRepository),
+				scraper.ConfigOutcomeReport(report))
+			Expect(errCheckScraper).To(BeNil())
+
+			validPages := createTvTropesPagesWithEmptySubpages(works[0], workResources[0])
+			invalidPages := createTvTropesPagesWithEmptySubpages(works[1], workResources[3])
+			errValidCheck = checkScraper.CheckTvTropesPages(validPages)
+			errInvalidCheck = checkScraper.CheckTvTropesPages(invalidPages)
+			errCheckedScraping = checkScraper.ScrapeCheckedTvTropes(context.Background(), validPages)
+
+			// The same Work page twice, so only one of them can be added
+			duplicatedPages := createTvTropesPagesWithEmptySubpages(works[2], workResources[2])
+			for page, subPages := range createTvTropesPagesWithEmptySubpages(works[2], workResources[2]).Pages {
+				duplicatedPages.Pages[page] = subPages
+			}
+			errDuplicatedScraping = checkScraper.ScrapeCheckedTvTropes(context.Background(), duplicatedPages)
+			errInvalidScraping = checkScraper.ScrapeCheckedTvTropes(context.Background(), invalidPages)
+		})
+
+		AfterEach(func() {
+			os.Remove("checked.json")
+		})
+
+		It("Should only return an error for the pages that aren't valid", func() {
+			Expect(errValidCheck).To(BeNil())
+			Expect(errInvalidCheck).To(Not(BeNil()))
+			Expect(errCheckedScraping).To(BeNil())
+		})
+
+		It("Should return why the pages weren't scraped or added instead of persisting nothing", func() {
+			Expect(errors.Is(errDuplicatedScraping, json_dataset.ErrDuplicatedMedia)).To(BeTrue())
+			Expect(errors.Is(errInvalidScraping, scraper.ErrUnknownPageStructure)).To(BeTrue())
+			Expect(errors.Is(errDuplicatedScraping, json_dataset.ErrPersist)).To(BeFalse())
+			Expect(errors.Is(errInvalidScraping, json_dataset.ErrPersist)).To(BeFalse())
+		})
+
+		It("Should record the check of every page and scrape the checked ones", func() {
+			Expect(report.GetFailedUrls()).To(ConsistOf(works[1]))
+			for _, stage := range []outcome.Stage{outcome.CheckStage, outcome.ScrapeStage, outcome.PersistStage} {
+				Expect(report.GetOutcomes()).To(ContainElement(And(
+					HaveField("URL", works[0]), HaveField("Stage", stage), HaveField("Status", outcome.Succeeded))))
+			}
+			Expect(report.GetOutcomes()).To(ContainElement(And(
+				HaveField("URL", works[1]), HaveField("Stage", outcome.CheckStage), HaveField("Status", outcome.Failed))))
+		})
+	})
+
+	Describe("Add Work pages and persist them at once", func() {
+		var report *outcome.Report
+		var addedRecordsBeforePersist int
+		var errFirstAdd, errSecondAdd, errPersistAdded, errPersistNothing error
+
+		BeforeEach(func() {
+			var errReport error
+			report, errReport = outcome.NewReport("added.json", outcome.ConfigErrorClasses(scraper.ErrorClasses...))
+			Expect(errReport).To(BeNil())
+
+			addedRepository, errRepository := json_dataset.NewJSONRepository("added")
+			Expect(errRepository).To(BeNil())
+			defer addedRepository.Close()
+			addScraper, errAddScraper := scraper.NewServiceScraper(scraper.ConfigMediaRepository(addedRepository), scraper.ConfigOutcomeReport(report))
+			Expect(errAddScraper).To(BeNil())
+
+			errFirstAdd = addScraper.AddCheckedTvTropes(createTvTropesPagesWithEmptySubpages(works[0], workResources[0]))
+			errSecondAdd = addScraper.AddCheckedTvTropes(createTvTropesPagesWithEmptySubpages(works[2], workResources[2]))
+
+			var dataset json_dataset.JSONDataset
+			fileContents, _ := os.ReadFile("added.json")
+			Expect(json.Unmarshal(fileContents, &dataset)).To(Succeed())
+			addedRecordsBeforePersist = len(dataset.Tropestogo)
+
+			errPersistAdded = addScraper.PersistAdded()
+			errPersistNothing = addScraper.PersistAdded()
+		})
+
+		AfterEach(func() {
+			os.Remove("added.json")
+		})
+
+		It("Shouldn't persist the added Work pages until they are persisted at once", func() {
+			Expect(errFirstAdd).To(BeNil())
+			Expect(errSecondAdd).To(BeNil())
+			Expect(addedRecordsBeforePersist).To(Equal(0))
+			Expect(errPersistAdded).To(BeNil())
+
+			var dataset json_dataset.JSONDataset
+			fileContents, _ := os.ReadFile("added.json")
+			Expect(json.Unmarshal(fileContents, &dataset)).To(Succeed())
+			Expect(dataset.Tropestogo).To(HaveLen(2))
+		})
+
+		It("Should record the persisted Work pages", func() {
+			for _, work := range []string{works[0], works[2]} {
+				Expect(report.GetOutcomes()).To(ContainElement(And(
+					HaveField("URL", work), HaveField("Stage", outcome.PersistStage), HaveField("Status", outcome.Succeeded))))
+			}
+		})
+
+		It("Shouldn't return an error if nothing has been added since the last time", func() {
+			Expect(errPersistNothing).To(BeNil())
+		})
+	})
+
+	Describe("Scrape several Work pages in parallel", func() {
+		var errParallelScraping error
+
+		BeforeEach(func() {
+			parallelRepository, errRepository := json_dataset.NewJSONRepository("parallel")
+			Expect(errRepository).To(BeNil())
+			defer parallelRepository.Close()
+			parallelScraper, errParallelScraper := scraper.NewServiceScraper(scraper.ConfigMediaRepository(parallelRepository), scraper.ConfigWorkers(3))
+			Expect(errParallelScraper).To(BeNil())
+
+			pages := tvtropespages.NewTvTropesPages()
+			oldboySubpages, _ := loadSubpageFiles(oldboySubpageFiles, oldboySubpageUrls)
+			avengersSubpages, _ := loadSubpageFiles(avengersSubpageFiles, avengersSubpageUrls)
+			pages.Pages[createPage(works[0], workResources[0])] = oldboySubpages
+			pages.Pages[createPage(works[2], workResources[2])] = avengersSubpages
+			for page, subPages := range createTvTropesPagesWithEmptySubpages(works[1], workResources[1]).Pages {
+				pages.Pages[page] = subPages
+			}
+
+			errParallelScraping = parallelScraper.ScrapeTvTropes(context.Background(), pages)
+		})
+
+		AfterEach(func() {
+			os.Remove("parallel.json")
+		})
+
+		It("Should persist every Work page once", func() {
+			Expect(errParallelScraping).To(BeNil())
+
+			var dataset json_dataset.JSONDataset
+			fileContents, _ := os.ReadFile("parallel.json")
+			Expect(json.Unmarshal(fileContents, &dataset)).To(Succeed())
+			Expect(dataset.Tropestogo).To(HaveLen(len(works)))
+			for _, record := range dataset.Tropestogo {
+				Expect(record.URL).To(BeElementOf(works))
+				Expect(record.Tropes).To(Not(BeEmpty()))
+			}
+		})
+
+		It("Shouldn't accept a number of workers that isn't positive", func() {
+			_, errWorkers := scraper.NewServiceScraper(scraper.ConfigWorkers(0))
+			Expect(errWorkers).To(Equal(scraper.ErrInvalidField))
+		})
+	})
+
+	Describe("Extract the metadata of a Work page", func() {
+		var oldboyMetadata, avengersMetadata trope.WorkMetadata
+
+		BeforeEach(func() {
+			metadataScraper, _ := scraper.NewServiceScraper()
+
+			oldboyReader, _ := os.Open(workResources[0])
+			defer oldboyReader.Close()
+			oldboyDoc, _ := goquery.NewDocumentFromReader(oldboyReader)
+			oldboyMetadata = metadataScraper.ScrapeWorkMetadata(oldboyDoc)
+
+			avengersReader, _ := os.Open(workResources[2])
+			defer avengersReader.Close()
+			avengersDoc, _ := goquery.NewDocumentFromReader(avengersReader)
+			avengersMetadata = metadataScraper.ScrapeWorkMetadata(avengersDoc)
+		})
+
+		It("Should extract the description paragraphs before the trope list", func() {
+			Expect(oldboyMetadata.Description).To(HaveLen(6))
+			Expect(oldboyMetadata.Description[0]).To(HavePrefix("Oldboy is a 2003 South Korean action thriller film"))
+			Expect(avengersMetadata.Description).To(HaveLen(8))
+		})
+
+		It("Should extract the image and its caption without notes", func() {
+			Expect(oldboyMetadata.Image).To(Equal("https://static.tvtropes.org/pmwiki/pub/images/mv5bmti3ntqymzu5m15bml5banbnxkftztcwmtm2mjgymq_v1.jpg"))
+			Expect(oldboyMetadata.ImageCaption).To(Equal("15 years of imprisonment. Five days of vengeance."))
+			Expect(avengersMetadata.ImageCaption).To(Equal("Some Assembly Required."))
+		})
+
+		It("Should extract the creators and the other works mentioned on the description", func() {
+			Expect(oldboyMetadata.Creators).To(HaveLen(9))
+			Expect(oldboyMetadata.Creators[0]).To(Equal("Park Chan-wook"))
+			Expect(oldboyMetadata.Creators).To(ContainElement("Spike Lee"))
+			Expect(oldboyMetadata.SeeAlso).To(ContainElements("https://tvtropes.org/pmwiki/pmwiki.php/Manga/Oldboy",
+				"https://tvtropes.org/pmwiki/pmwiki.php/Film/Oldboy2013"))
+			Expect(avengersMetadata.Creators).To(Equal([]string{"Joss Whedon"}))
+		})
+
+		It("Should extract the franchises of the Work", func() {
+			Expect(oldboyMetadata.Franchises).To(BeEmpty())
+			Expect(avengersMetadata.Franchises).To(Equal([]string{"MarvelCinematicUniverse", "Hawkeye", "BlackWidow", "CaptainAmerica", "TheAvengers", "IronMan"}))
+		})
+	})
+
+	Describe("Extract the relations of a Work page with other Works", func() {
+		var oldboyRelations, avengersRelations []trope.Relation
+
+		BeforeEach(func() {
+			relationScraper, _ := scraper.NewServiceScraper()
+
+			oldboyReader, _ := os.Open(workResources[0])
+			defer oldboyReader.Close()
+			oldboyDoc, _ := goquery.NewDocumentFromReader(oldboyReader)
+			oldboyRelations = relationScraper.ScrapeRelations(works[0], oldboyDoc)
+
+			avengersReader, _ := os.Open(workResources[2])
+			defer avengersReader.Close()
+			avengersDoc, _ := goquery.NewDocumentFromReader(avengersReader)
+			avengersRelations = relationScraper.ScrapeRelations(works[2], avengersDoc)
+		})
+
+		It("Should extract one relation for each other Work with its anchor text and sentence", func() {
+			relationsByUrl := make(map[string]trope.Relation)
+			for _, relation := range oldboyRelations {
+				Expect(relationsByUrl).To(Not(HaveKey(relation.URL)))
+				Expect(relation.URL).To(Not(Equal(works[0])))
+				Expect(relation.URL).To(Not(ContainSubstring("/Creator/")))
+				relationsByUrl[relation.URL] = relation
+			}
+
+			manga := relationsByUrl["https://tvtropes.org/pmwiki/pmwiki.php/Manga/Oldboy"]
+			Expect(manga.AnchorText).To(Equal("the Japanese manga of the same name"))
+			Expect(manga.Context).To(Equal("Oldboy is a 2003 South Korean action thriller film very loosely based on the Japanese manga of the same name."))
+			Expect(manga.Type).To(Equal(trope.AdaptationRelation))
+
+			Expect(relationsByUrl["https://tvtropes.org/pmwiki/pmwiki.php/Film/Oldboy2013"].Type).To(Equal(trope.AdaptationRelation))
+			Expect(relationsByUrl["https://tvtropes.org/pmwiki/pmwiki.php/Film/KingKong1933"].Type).To(Equal(trope.ReferencedRelation))
+		})
+
+		It("Should classify the sequels and the franchises", func() {
+			relationTypes := make(map[string]trope.RelationType)
+			for _, relation := range avengersRelations {
+				relationTypes[relation.URL] = relation.Type
+			}
+
+			Expect(relationTypes["https://tvtropes.org/pmwiki/pmwiki.php/Film/AvengersAgeOfUltron"]).To(Equal(trope.SequelRelation))
+			Expect(relationTypes["https://tvtropes.org/pmwiki/pmwiki.php/Franchise/MarvelCinematicUniverse"]).To(Equal(trope.FranchiseRelation))
+		})
+	})
+
+	Describe("Locate the scraped examples on their pages", func() {
+		var subpageExamples, pageExamples map[trope.Trope][]trope.Example
+
+		BeforeEach(func() {
+			locatingScraper, _ := scraper.NewServiceScraper()
+
+			subpageReader, _ := os.Open(avengersSubpageFiles[0])
+			defer subpageReader.Close()
+			subDoc, _ := goquery.NewDocumentFromReader(subpageReader)
+			_, subpageExamples, _ = locatingScraper.ScrapeSubpageTropes([]*goquery.Document{subDoc})
+
+			pageReader, _ := os.Open(workResources[0])
+			defer pageReader.Close()
+			doc, _ := goquery.NewDocumentFromReader(pageReader)
+			_, pageExamples, _ = locatingScraper.ScrapeTropesWithExamples(doc, selectors.DefaultProfile().Scraper.TropeList+" li "+selectors.DefaultProfile().Scraper.TropeTag)
+		})
+
+		It("Should locate the examples on folders with their folder label, header, subpage and position", func() {
+			Expect(subpageExamples).To(Not(BeEmpty()))
+			for subpageTrope, examples := range subpageExamples {
+				for _, example := range examples {
+					Expect(example.Location.Folder).To(Not(BeEmpty()))
+					if subpageTrope.GetTitle() == "AttackBackfire" {
+						Expect(example.Location.Folder).To(Equal("A"))
+					}
+					Expect(example.Location.Header).To(Equal("The Avengers provides examples of the following tropes:"))
+					Expect(example.Location.SubpageURL).To(Equal("http://tvtropes.org/pmwiki/pmwiki.php/TheAvengers/TropesAToD"))
+					Expect(example.Location.Position >= 1).To(BeTrue())
+				}
+			}
+		})
+
+		It("Should locate the examples on a plain list without a folder", func() {
+			for pageTrope, examples := range pageExamples {
+				Expect(examples[0].Location.Folder).To(BeEmpty())
+				Expect(examples[0].Location.Header).To(Equal("The film provides examples of:"))
+				Expect(examples[0].Location.SubpageURL).To(Equal("http://tvtropes.org/pmwiki/pmwiki.php/Film/Oldboy2003"))
+				if pageTrope.GetTitle() == "ChekhovsGun" {
+					Expect(examples[0].Location.Position).To(Equal(24))
+				}
+			}
+		})
+	})
+
 	Describe("Scrape different Film Pages and persist on the dataset", func() {
 		var validfilm1Csv, validfilm2Csv, validfilm3Csv, validfilm1Json, validfilm2Json, validfilm3Json media.Media
 		var errorfilm1Csv, errorfilm2Csv, errorfilm3Csv, errorfilm1Json, errorfilm2Json, errorfilm3Json error
@@ -354,6 +815,45 @@ var _ = Describe("Scraper", func() {
 				areRepositoryTropesUnique(strings.Split(record[5], ";"))
 				areRepositorySubTropesUnique(strings.Split(record[6], ";"), strings.Split(record[7], ";"))
 			}
+
+			// The examples columns have the examples of every trope in the same order as the trope columns
+			for _, record := range records[1:] {
+				var tropesExamples, subTropesExamples [][]media.JsonExample
+				Expect(json.Unmarshal([]byte(record[10]), &tropesExamples)).To(Succeed())
+				Expect(json.Unmarshal([]byte(record[11]), &subTropesExamples)).To(Succeed())
+				Expect(tropesExamples).To(HaveLen(len(strings.Split(record[5], ";"))))
+			}
+
+			// The metadata columns have the description of every work and the URLs of its related works
+			for _, record := range records[1:] {
+				Expect(record[12]).To(Not(BeEmpty()))
+				for _, seeAlso := range strings.Split(record[16], ";") {
+					Expect(seeAlso).To(HavePrefix(scraper.TvTropesWeb))
+				}
+			}
+		})
+
+		It("Should have extracted the example of every trope with its sub-bullets and spoilers", func() {
+			examplesByTitle := make(map[string][]trope.Example)
+			for mainTrope := range validfilm1Json.GetWork().Tropes {
+				examplesByTitle[mainTrope.GetTitle()] = validfilm1Json.GetExamples(mainTrope)
+				Expect(validfilm1Json.GetExamples(mainTrope)).To(Not(BeEmpty()))
+			}
+
+			Expect(examplesByTitle["ChekhovsGun"]).To(HaveLen(1))
+			Expect(examplesByTitle["ChekhovsGun"][0].Text).To(HavePrefix("Chekhov's Gun"))
+			Expect(examplesByTitle["ChekhovsGun"][0].HTML).To(ContainSubstring("/pmwiki/pmwiki.php/Main/ChekhovsGun"))
+			Expect(examplesByTitle["ChekhovsGun"][0].SubItems).To(HaveLen(3))
+			Expect(examplesByTitle["ChekhovsGun"][0].SubItems[2]).To(HavePrefix("The scissors in Woo-jin's apartment."))
+
+			Expect(examplesByTitle["KarmaHoudini"][0].Spoilers).To(Equal([]string{"and he consented to lose his hand to receive a bigger and better building so he can continue his criminal activities"}))
+			Expect(examplesByTitle["KarmaHoudini"][0].Text).To(ContainSubstring("he simply visits a dentist and gets new ones, and he consented"))
+
+			for subTrope := range validfilm1Json.GetWork().SubTropes {
+				for _, example := range validfilm1Json.GetExamples(subTrope) {
+					Expect(example.Text).To(Not(BeEmpty()))
+				}
+			}
 		})
 
 		Context("Update the contents of one of the Films to not have subtropes", func() {
@@ -363,8 +863,8 @@ var _ = Describe("Scraper", func() {
 				updatedPagesCsv := createTvTropesPagesWithEmptySubpages(works[0], workResources[0])
 				updatedPagesJson := createTvTropesPagesWithEmptySubpages(works[0], workResources[0])
 
-				errUpdateJson = serviceScraperJson.UpdateDataset(updatedPagesJson)
-				errUpdateCsv = serviceScraperCsv.UpdateDataset(updatedPagesCsv)
+				errUpdateJson = serviceScraperJson.UpdateDataset(context.Background(), updatedPagesJson)
+				errUpdateCsv = serviceScraperCsv.UpdateDataset(context.Background(), updatedPagesCsv)
 			})
 
 			It("Shouldn't return an error", func() {
@@ -418,6 +918,41 @@ var _ = Describe("Scraper", func() {
 				}
 			})
 		})
+
+		Context("Update one of the Films together with a page that can't be scraped", func() {
+			var report *outcome.Report
+			var errUpdate error
+
+			BeforeEach(func() {
+				var errReport error
+				report, errReport = outcome.NewReport("dataset.json", outcome.ConfigErrorClasses(scraper.ErrorClasses...))
+				Expect(errReport).To(BeNil())
+				reportScraper, errReportScraper := scraper.NewServiceScraper(scraper.ConfigMediaRepository(jsonRepository),
+					scraper.ConfigOutcomeReport(report))
+				Expect(errReportScraper).To(BeNil())
+
+				// A New Hope without its contents can't be scraped, but Oldboy is still updated
+				updatedPages := createTvTropesPagesWithEmptySubpages(works[0], workResources[0])
+				emptyPages := createTvTropesPagesWithEmptySubpages(works[1], workResources[3])
+				for page, subPages := range emptyPages.Pages {
+					updatedPages.Pages[page] = subPages
+				}
+
+				errUpdate = reportScraper.UpdateDataset(context.Background(), updatedPages)
+			})
+
+			It("Should return the error of the page that can't be scraped", func() {
+				Expect(errors.Is(errUpdate, scraper.ErrUpdateDataset)).To(BeTrue())
+				Expect(errUpdate.Error()).To(ContainSubstring(works[1]))
+				Expect(errUpdate.Error()).To(Not(ContainSubstring(works[0])))
+			})
+
+			It("Should update the rest of the pages and report the failed one", func() {
+				Expect(report.GetFailedUrls()).To(ConsistOf(works[1]))
+				Expect(report.GetOutcomes()).To(ContainElement(And(
+					HaveField("URL", works[0]), HaveField("Stage", outcome.PersistStage), HaveField("Status", outcome.Succeeded))))
+			})
+		})
 	})
 })
 
@@ -0,0 +1,52 @@
+package trope
+
+import "errors"
+
+var (
+	ErrEmptyTropeID = errors.New("a catalogued trope must have an ID")
+)
+
+// CatalogueEntry is the information of a trope extracted from its own TvTropes page. It's a mutable entity because the page can be updated
+// It's identified by its ID, which is the same title that a Trope has on the Works, so the trope catalogue can be joined with the works dataset
+type CatalogueEntry struct {
+	// ID is the canonical name of the trope, the last part of its Main page URL
+	ID string
+	// Name is the display name of the trope, as it's written on the title of its page
+	Name string
+	// URL of the trope Main page
+	URL string
+	// Laconic is the one sentence description of the trope from its Laconic page
+	Laconic string
+	// Description is the first paragraph of the trope page
+	Description string
+	// SuperTropes are the IDs of the tropes this trope is a subtrope of
+	SuperTropes []string
+	// SubTropes are the IDs of the tropes that are subtropes of this trope
+	SubTropes []string
+	// Indexes are the IDs of the index pages that list this trope
+	Indexes []string
+	// Examples is the number of examples of the trope, on its page and on its example subpages
+	Examples int
+}
+
+// NewCatalogueEntry is a factory that creates a CatalogueEntry entity from the trope ID, its display name and its page URL
+// If the display name is empty, the ID is used as the name
+// It returns an ErrEmptyTropeID error if the ID is empty
+func NewCatalogueEntry(id, name, url string) (CatalogueEntry, error) {
+	if len(id) == 0 {
+		return CatalogueEntry{}, ErrEmptyTropeID
+	}
+
+	if len(name) == 0 {
+		name = id
+	}
+
+	return CatalogueEntry{
+		ID:          id,
+		Name:        name,
+		URL:         url,
+		SuperTropes: make([]string, 0),
+		SubTropes:   make([]string, 0),
+		Indexes:     make([]string, 0),
+	}, nil
+}
@@ -0,0 +1,479 @@
+package catalogue_dataset
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/jlgallego99/TropesToGo/media/dataset_file"
+	"github.com/jlgallego99/TropesToGo/trope"
+)
+
+var (
+	ErrDuplicatedTrope = errors.New("duplicated trope, the entry already exists on the catalogue")
+	ErrCreateDataset   = errors.New("error creating the catalogue dataset file")
+	ErrOpenDataset     = errors.New("error opening the catalogue dataset file")
+	ErrReadDataset     = errors.New("error reading the catalogue dataset file")
+	ErrWriteDataset    = errors.New("error writing on the catalogue dataset file")
+	ErrUnmarshalJson   = errors.New("error unmarshalling the JSON catalogue dataset")
+	ErrMarshalJson     = errors.New("error marshalling the JSON catalogue dataset")
+)
+
+// emptyCatalogue is the contents of a new JSON catalogue dataset
+const emptyCatalogue = "{\"tropes\": []}"
+
+// Headers are the columns of the CSV catalogue dataset, where the list columns are separated by semicolons
+var Headers = []string{"id", "name", "url", "laconic", "description", "supertropes", "subtropes", "indexes", "examples"}
+
+// JSONCatalogueDataset is an intermediate structure for marshaling/unmarshalling data from the JSON catalogue dataset
+type JSONCatalogueDataset struct {
+	Tropes []JsonEntry `json:"tropes"`
+}
+
+// JsonEntry is an object for marshaling/unmarshalling a single CatalogueEntry object in JSON
+type JsonEntry struct {
+	ID          string   `json:"id"`
+	Name        string   `json:"name"`
+	URL         string   `json:"url"`
+	Laconic     string   `json:"laconic"`
+	Description string   `json:"description"`
+	SuperTropes []string `json:"supertropes"`
+	SubTropes   []string `json:"subtropes"`
+	Indexes     []string `json:"indexes"`
+	Examples    int      `json:"examples"`
+}
+
+// JSONCatalogue implements the RepositoryCatalogue for creating and handling JSON datasets of the trope catalogue
+// It has an internal data structure of CatalogueEntry objects that can be persisted into a file all in one go
+// It's safe to use by several goroutines at the same time
+// The dataset file is always written atomically and backed up before the first time the catalogue writes it,
+// and the catalogue holds its lock from its first write until it's closed, so no other process can write the same dataset at the same time
+type JSONCatalogue struct {
+	// name of the file dataset
+	name string
+
+	// data is the intermediate catalogue added here before persisting it all at once
+	data []trope.CatalogueEntry
+
+	// lock is the lock of the dataset file, held from the first write on the dataset until the catalogue is closed
+	lock *dataset_file.Lock
+
+	// backedUp is set when the dataset file has been backed up before the first write of the catalogue
+	backedUp bool
+
+	// mutex guards the intermediate catalogue and the dataset file
+	mutex sync.Mutex
+}
+
+// CSVCatalogue implements the RepositoryCatalogue for creating and handling CSV datasets of the trope catalogue
+// New entries are added to the end of the file when persisted
+// It's safe to use by several goroutines at the same time
+// The dataset file is always written atomically and backed up before the first time the catalogue writes it,
+// and the catalogue holds its lock from its first write until it's closed, so no other process can write the same dataset at the same time
+type CSVCatalogue struct {
+	// name of the file dataset
+	name string
+
+	// data is the intermediate catalogue added here before persisting it all at once
+	data []trope.CatalogueEntry
+
+	// lock is the lock of the dataset file, held from the first write on the dataset until the catalogue is closed
+	lock *dataset_file.Lock
+
+	// backedUp is set when the dataset file has been backed up before the first write of the catalogue
+	backedUp bool
+
+	// mutex guards the intermediate catalogue and the dataset file
+	mutex sync.Mutex
+}
+
+// Error formats a generic error
+func Error(message string, err error, subErr error) error {
+	if subErr != nil {
+		return fmt.Errorf("%w: "+message+"\n%w", err, subErr)
+	} else {
+		return fmt.Errorf("%w: "+message+"", err)
+	}
+}
+
+// NewJSONCatalogue is the constructor for JSONCatalogue objects that handle JSON catalogue datasets
+// It receives the name that the JSON dataset file will have and creates the file with a "tropes" key with an empty array
+// It will return an ErrCreateDataset error if the file couldn't be created, or if another process is writing it
+func NewJSONCatalogue(name string) (*JSONCatalogue, error) {
+	catalogue := &JSONCatalogue{
+		name: name + ".json",
+	}
+
+	if _, errStat := os.Stat(catalogue.name); errStat != nil {
+		if errLock := catalogue.lockDataset(); errLock != nil {
+			return nil, Error(name, ErrCreateDataset, errLock)
+		}
+
+		if errWrite := dataset_file.WriteFile(catalogue.name, []byte(emptyCatalogue)); errWrite != nil {
+			catalogue.Close()
+			return nil, Error(name, ErrCreateDataset, errWrite)
+		}
+	}
+
+	return catalogue, nil
+}
+
+// AddEntry adds a newEntry CatalogueEntry object to the in-memory catalogue, so it can be later persisted
+// It returns an ErrDuplicatedTrope error if there's already an entry with the same trope ID
+func (catalogue *JSONCatalogue) AddEntry(newEntry trope.CatalogueEntry) error {
+	catalogue.mutex.Lock()
+	defer catalogue.mutex.Unlock()
+
+	if containsEntry(catalogue.data, newEntry.ID) {
+		return Error("ID: "+newEntry.ID, ErrDuplicatedTrope, nil)
+	}
+
+	catalogue.data = append(catalogue.data, newEntry)
+
+	return nil
+}
+
+// Persist writes all in-memory entries on the JSON catalogue file, skipping the tropes that are already on it
+// It empties the in-memory catalogue afterwards
+// It returns an ErrWriteDataset error if another process is writing the dataset, or if it couldn't be backed up or written
+func (catalogue *JSONCatalogue) Persist() error {
+	catalogue.mutex.Lock()
+	defer catalogue.mutex.Unlock()
+
+	if errLock := catalogue.lockDataset(); errLock != nil {
+		return errLock
+	}
+
+	dataset, errRead := catalogue.read()
+	if errRead != nil {
+		return errRead
+	}
+
+	persisted := make(map[string]struct{}, len(dataset.Tropes))
+	for _, record := range dataset.Tropes {
+		persisted[record.ID] = struct{}{}
+	}
+
+	for _, entry := range catalogue.data {
+		if _, exists := persisted[entry.ID]; !exists {
+			dataset.Tropes = append(dataset.Tropes, JsonEntry{
+				ID:          entry.ID,
+				Name:        entry.Name,
+				URL:         entry.URL,
+				Laconic:     entry.Laconic,
+				Description: entry.Description,
+				SuperTropes: entry.SuperTropes,
+				SubTropes:   entry.SubTropes,
+				Indexes:     entry.Indexes,
+				Examples:    entry.Examples,
+			})
+			persisted[entry.ID] = struct{}{}
+		}
+	}
+
+	catalogue.data = []trope.CatalogueEntry{}
+
+	jsonBytes, errMarshal := json.Marshal(dataset)
+	if errMarshal != nil {
+		return Error(catalogue.name, ErrMarshalJson, errMarshal)
+	}
+
+	if errBackup := catalogue.backupDataset(); errBackup != nil {
+		return errBackup
+	}
+
+	if errWrite := dataset_file.WriteFile(catalogue.name, jsonBytes); errWrite != nil {
+		return Error(catalogue.name, ErrWriteDataset, errWrite)
+	}
+
+	return nil
+}
+
+// GetTropeIDs retrieves the ID of every trope persisted on the JSON catalogue file
+func (catalogue *JSONCatalogue) GetTropeIDs() (map[string]struct{}, error) {
+	catalogue.mutex.Lock()
+	defer catalogue.mutex.Unlock()
+
+	dataset, errRead := catalogue.read()
+	if errRead != nil {
+		return nil, errRead
+	}
+
+	tropeIds := make(map[string]struct{}, len(dataset.Tropes))
+	for _, record := range dataset.Tropes {
+		tropeIds[record.ID] = struct{}{}
+	}
+
+	return tropeIds, nil
+}
+
+// read unmarshalls the whole JSON catalogue file
+func (catalogue *JSONCatalogue) read() (JSONCatalogueDataset, error) {
+	var dataset JSONCatalogueDataset
+
+	fileContents, errRead := os.ReadFile(catalogue.name)
+	if errRead != nil {
+		return dataset, Error(catalogue.name, ErrReadDataset, errRead)
+	}
+
+	if errUnmarshal := json.Unmarshal(fileContents, &dataset); errUnmarshal != nil {
+		return dataset, Error(catalogue.name, ErrUnmarshalJson, errUnmarshal)
+	}
+
+	return dataset, nil
+}
+
+// Close releases the lock of the dataset file if the catalogue holds it, so other processes can write it
+// The catalogue can still be used after closing it, and it will acquire the lock again on its next write
+func (catalogue *JSONCatalogue) Close() error {
+	catalogue.mutex.Lock()
+	defer catalogue.mutex.Unlock()
+
+	errRelease := releaseLock(catalogue.lock)
+	catalogue.lock = nil
+
+	return errRelease
+}
+
+// lockDataset acquires the lock of the dataset file if the catalogue doesn't hold it yet, and holds it until the catalogue is closed
+// The mutex must be held, and it returns an ErrWriteDataset error with dataset_file.ErrLocked if another process is writing the dataset
+func (catalogue *JSONCatalogue) lockDataset() error {
+	lock, errLock := acquireLock(catalogue.name, catalogue.lock)
+	catalogue.lock = lock
+
+	return errLock
+}
+
+// backupDataset backs up the dataset file before the first write of the catalogue, so it's only backed up once
+// even if it's persisted many times. The mutex must be held, and it returns an ErrWriteDataset error if it couldn't be backed up
+func (catalogue *JSONCatalogue) backupDataset() error {
+	if catalogue.backedUp {
+		return nil
+	}
+
+	if errBackup := dataset_file.Backup(catalogue.name); errBackup != nil {
+		return Error(catalogue.name, ErrWriteDataset, errBackup)
+	}
+
+	catalogue.backedUp = true
+	return nil
+}
+
+// NewCSVCatalogue is the constructor for CSVCatalogue objects that handle CSV catalogue datasets
+// It receives the name that the CSV dataset file will have and creates an empty file with only the column headers
+// It will return an ErrCreateDataset error if the file couldn't be created, or if another process is writing it
+func NewCSVCatalogue(name string) (*CSVCatalogue, error) {
+	catalogue := &CSVCatalogue{
+		name: name + ".csv",
+	}
+
+	if _, errStat := os.Stat(catalogue.name); errStat != nil {
+		if errLock := catalogue.lockDataset(); errLock != nil {
+			return nil, Error(name, ErrCreateDataset, errLock)
+		}
+
+		errCreate := dataset_file.Replace(catalogue.name, func(writer io.Writer) error {
+			return writeRecords(writer, [][]string{Headers})
+		})
+		if errCreate != nil {
+			catalogue.Close()
+			return nil, Error(name, ErrCreateDataset, errCreate)
+		}
+	}
+
+	return catalogue, nil
+}
+
+// AddEntry adds a newEntry CatalogueEntry object to the in-memory catalogue, so it can be later persisted
+// It returns an ErrDuplicatedTrope error if there's already an entry with the same trope ID
+func (catalogue *CSVCatalogue) AddEntry(newEntry trope.CatalogueEntry) error {
+	catalogue.mutex.Lock()
+	defer catalogue.mutex.Unlock()
+
+	if containsEntry(catalogue.data, newEntry.ID) {
+		return Error("ID: "+newEntry.ID, ErrDuplicatedTrope, nil)
+	}
+
+	catalogue.data = append(catalogue.data, newEntry)
+
+	return nil
+}
+
+// Persist adds all in-memory entries to the end of the CSV catalogue file, skipping the tropes that are already on it
+// The file is written again with its records followed by the new ones, so it's never left with only a part of them
+// It empties the in-memory catalogue afterwards
+// It returns an ErrWriteDataset error if another process is writing the dataset, or if it couldn't be backed up or written
+func (catalogue *CSVCatalogue) Persist() error {
+	catalogue.mutex.Lock()
+	defer catalogue.mutex.Unlock()
+
+	if errLock := catalogue.lockDataset(); errLock != nil {
+		return errLock
+	}
+
+	persisted, errIds := catalogue.readTropeIDs()
+	if errIds != nil {
+		return errIds
+	}
+
+	var records [][]string
+	for _, entry := range catalogue.data {
+		if _, exists := persisted[entry.ID]; exists {
+			continue
+		}
+
+		records = append(records, CreateEntryRecord(entry))
+		persisted[entry.ID] = struct{}{}
+	}
+
+	catalogue.data = []trope.CatalogueEntry{}
+
+	if len(records) == 0 {
+		return nil
+	}
+
+	if errBackup := catalogue.backupDataset(); errBackup != nil {
+		return errBackup
+	}
+
+	errReplace := dataset_file.Replace(catalogue.name, func(writer io.Writer) error {
+		csvFile, errOpen := os.Open(catalogue.name)
+		if errOpen != nil {
+			return errOpen
+		}
+		defer csvFile.Close()
+
+		if _, errCopy := io.Copy(writer, csvFile); errCopy != nil {
+			return errCopy
+		}
+
+		return writeRecords(writer, records)
+	})
+	if errReplace != nil {
+		return Error(catalogue.name, ErrWriteDataset, errReplace)
+	}
+
+	return nil
+}
+
+// GetTropeIDs retrieves the ID of every trope persisted on the CSV catalogue file
+func (catalogue *CSVCatalogue) GetTropeIDs() (map[string]struct{}, error) {
+	catalogue.mutex.Lock()
+	defer catalogue.mutex.Unlock()
+
+	return catalogue.readTropeIDs()
+}
+
+// readTropeIDs reads the ID of every trope persisted on the CSV catalogue file. The mutex must be held
+func (catalogue *CSVCatalogue) readTropeIDs() (map[string]struct{}, error) {
+	csvFile, errOpen := os.Open(catalogue.name)
+	if errOpen != nil {
+		return nil, Error(catalogue.name, ErrOpenDataset, errOpen)
+	}
+	defer csvFile.Close()
+
+	records, errReadAll := csv.NewReader(csvFile).ReadAll()
+	if errReadAll != nil {
+		return nil, Error(catalogue.name, ErrReadDataset, errReadAll)
+	}
+
+	tropeIds := make(map[string]struct{}, len(records))
+	for pos, record := range records {
+		// The first row holds the headers
+		if pos > 0 && len(record) > 0 {
+			tropeIds[record[0]] = struct{}{}
+		}
+	}
+
+	return tropeIds, nil
+}
+
+// Close releases the lock of the dataset file if the catalogue holds it, so other processes can write it
+// The catalogue can still be used after closing it, and it will acquire the lock again on its next write
+func (catalogue *CSVCatalogue) Close() error {
+	catalogue.mutex.Lock()
+	defer catalogue.mutex.Unlock()
+
+	errRelease := releaseLock(catalogue.lock)
+	catalogue.lock = nil
+
+	return errRelease
+}
+
+// lockDataset acquires the lock of the dataset file if the catalogue doesn't hold it yet, and holds it until the catalogue is closed
+// The mutex must be held, and it returns an ErrWriteDataset error with dataset_file.ErrLocked if another process is writing the dataset
+func (catalogue *CSVCatalogue) lockDataset() error {
+	lock, errLock := acquireLock(catalogue.name, catalogue.lock)
+	catalogue.lock = lock
+
+	return errLock
+}
+
+// backupDataset backs up the dataset file before the first write of the catalogue, so it's only backed up once
+// even if it's persisted many times. The mutex must be held, and it returns an ErrWriteDataset error if it couldn't be backed up
+func (catalogue *CSVCatalogue) backupDataset() error {
+	if catalogue.backedUp {
+		return nil
+	}
+
+	if errBackup := dataset_file.Backup(catalogue.name); errBackup != nil {
+		return Error(catalogue.name, ErrWriteDataset, errBackup)
+	}
+
+	catalogue.backedUp = true
+	return nil
+}
+
+// writeRecords writes the records on the writer with the CSV format
+func writeRecords(writer io.Writer, records [][]string) error {
+	return csv.NewWriter(writer).WriteAll(records)
+}
+
+// acquireLock acquires the lock of the name dataset file if the held lock is nil, and returns the lock that is held afterwards
+// It returns an ErrWriteDataset error with dataset_file.ErrLocked if another process is writing the dataset
+func acquireLock(name string, held *dataset_file.Lock) (*dataset_file.Lock, error) {
+	if held != nil {
+		return held, nil
+	}
+
+	lock, errLock := dataset_file.AcquireLock(name)
+	if errLock != nil {
+		return nil, Error(name, ErrWriteDataset, errLock)
+	}
+
+	return lock, nil
+}
+
+// releaseLock releases the lock of a dataset file if it's held
+func releaseLock(lock *dataset_file.Lock) error {
+	if lock == nil {
+		return nil
+	}
+
+	return lock.Release()
+}
+
+// CreateEntryRecord forms a proper string record from a CatalogueEntry object for inserting in a CSV file
+// Each value on the returned array is a column value for the CSV file
+func CreateEntryRecord(entry trope.CatalogueEntry) []string {
+	return []string{entry.ID, entry.Name, entry.URL, entry.Laconic, entry.Description,
+		strings.Join(entry.SuperTropes, ";"), strings.Join(entry.SubTropes, ";"), strings.Join(entry.Indexes, ";"),
+		strconv.Itoa(entry.Examples)}
+}
+
+// containsEntry checks if there's an entry with the tropeId on the entries
+func containsEntry(entries []trope.CatalogueEntry, tropeId string) bool {
+	for _, entry := range entries {
+		if entry.ID == tropeId {
+			return true
+		}
+	}
+
+	return false
+}
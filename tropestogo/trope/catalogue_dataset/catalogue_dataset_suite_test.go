@@ -0,0 +1,13 @@
+package catalogue_dataset_test
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func TestCatalogueDataset(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "CatalogueDataset Suite")
+}
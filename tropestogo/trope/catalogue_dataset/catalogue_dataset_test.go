@@ -0,0 +1,192 @@
+package catalogue_dataset_test
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+
+	"github.com/jlgallego99/TropesToGo/media/dataset_file"
+	"github.com/jlgallego99/TropesToGo/trope"
+	"github.com/jlgallego99/TropesToGo/trope/catalogue_dataset"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+const chekhovsGunUrl = "https://tvtropes.org/pmwiki/pmwiki.php/Main/ChekhovsGun"
+
+// newChekhovsGun creates a catalogue entry with all its information for test purposes
+func newChekhovsGun() trope.CatalogueEntry {
+	entry, _ := trope.NewCatalogueEntry("ChekhovsGun", "Chekhov's Gun", chekhovsGunUrl)
+	entry.Laconic = "An apparently irrelevant element that becomes important later."
+	entry.Description = "A Chekhov's Gun is an object that becomes important later."
+	entry.SuperTropes = []string{"Foreshadowing"}
+	entry.SubTropes = []string{"ChekhovsArmoury", "ChekhovsSkill"}
+	entry.Indexes = []string{"NarrativeDevices"}
+	entry.Examples = 3
+
+	return entry
+}
+
+var _ = Describe("CatalogueDataset", func() {
+	var datasetName string
+
+	BeforeEach(func() {
+		datasetName = filepath.Join(GinkgoT().TempDir(), "tropes")
+	})
+
+	Context("Persist a trope on a JSON catalogue", func() {
+		var catalogue *catalogue_dataset.JSONCatalogue
+		var errCatalogue, errAdd, errDuplicated, errPersist, errSecondPersist error
+		var dataset catalogue_dataset.JSONCatalogueDataset
+		var tropeIds map[string]struct{}
+
+		BeforeEach(func() {
+			catalogue, errCatalogue = catalogue_dataset.NewJSONCatalogue(datasetName)
+			errAdd = catalogue.AddEntry(newChekhovsGun())
+			errDuplicated = catalogue.AddEntry(newChekhovsGun())
+			errPersist = catalogue.Persist()
+
+			// Persisting the same trope again doesn't duplicate it
+			catalogue.AddEntry(newChekhovsGun())
+			errSecondPersist = catalogue.Persist()
+
+			fileContents, _ := os.ReadFile(datasetName + ".json")
+			json.Unmarshal(fileContents, &dataset)
+			tropeIds, _ = catalogue.GetTropeIDs()
+		})
+
+		AfterEach(func() {
+			catalogue.Close()
+		})
+
+		It("Shouldn't return an error", func() {
+			Expect(errCatalogue).To(BeNil())
+			Expect(errAdd).To(BeNil())
+			Expect(errPersist).To(BeNil())
+			Expect(errSecondPersist).To(BeNil())
+		})
+
+		It("Should return an ErrDuplicatedTrope error when adding the same trope twice", func() {
+			Expect(errors.Is(errDuplicated, catalogue_dataset.ErrDuplicatedTrope)).To(BeTrue())
+		})
+
+		It("Should have written the trope only once with all its information", func() {
+			Expect(dataset.Tropes).To(HaveLen(1))
+			Expect(dataset.Tropes[0].ID).To(Equal("ChekhovsGun"))
+			Expect(dataset.Tropes[0].Name).To(Equal("Chekhov's Gun"))
+			Expect(dataset.Tropes[0].URL).To(Equal(chekhovsGunUrl))
+			Expect(dataset.Tropes[0].SubTropes).To(Equal([]string{"ChekhovsArmoury", "ChekhovsSkill"}))
+			Expect(dataset.Tropes[0].Examples).To(Equal(3))
+		})
+
+		It("Should retrieve the persisted trope IDs", func() {
+			Expect(tropeIds).To(HaveLen(1))
+			Expect(tropeIds).To(HaveKey("ChekhovsGun"))
+		})
+	})
+
+	Context("Persist a trope on a CSV catalogue", func() {
+		var catalogue *catalogue_dataset.CSVCatalogue
+		var errCatalogue, errPersist error
+		var records [][]string
+		var tropeIds map[string]struct{}
+
+		BeforeEach(func() {
+			catalogue, errCatalogue = catalogue_dataset.NewCSVCatalogue(datasetName)
+			catalogue.AddEntry(newChekhovsGun())
+			errPersist = catalogue.Persist()
+
+			reopened, _ := catalogue_dataset.NewCSVCatalogue(datasetName)
+			reopened.AddEntry(newChekhovsGun())
+			reopened.Persist()
+
+			csvFile, _ := os.Open(datasetName + ".csv")
+			defer csvFile.Close()
+			records, _ = csv.NewReader(csvFile).ReadAll()
+			tropeIds, _ = reopened.GetTropeIDs()
+			reopened.Close()
+		})
+
+		AfterEach(func() {
+			catalogue.Close()
+		})
+
+		It("Shouldn't return an error", func() {
+			Expect(errCatalogue).To(BeNil())
+			Expect(errPersist).To(BeNil())
+		})
+
+		It("Should have written the headers and the trope only once", func() {
+			Expect(records).To(HaveLen(2))
+			Expect(records[0]).To(Equal(catalogue_dataset.Headers))
+			Expect(records[1]).To(Equal(catalogue_dataset.CreateEntryRecord(newChekhovsGun())))
+			Expect(records[1][6]).To(Equal("ChekhovsArmoury;ChekhovsSkill"))
+		})
+
+		It("Should retrieve the persisted trope IDs", func() {
+			Expect(tropeIds).To(HaveLen(1))
+			Expect(tropeIds).To(HaveKey("ChekhovsGun"))
+		})
+	})
+
+	Context("Persist a trope on a catalogue that already has tropes", func() {
+		var errPersist error
+		var backupContents []byte
+		var tropeIds map[string]struct{}
+
+		BeforeEach(func() {
+			catalogue, _ := catalogue_dataset.NewCSVCatalogue(datasetName)
+			catalogue.AddEntry(newChekhovsGun())
+			catalogue.Persist()
+			catalogue.Close()
+
+			reopened, _ := catalogue_dataset.NewCSVCatalogue(datasetName)
+			otherEntry, _ := trope.NewCatalogueEntry("Foreshadowing", "Foreshadowing", "https://tvtropes.org/pmwiki/pmwiki.php/Main/Foreshadowing")
+			reopened.AddEntry(otherEntry)
+			errPersist = reopened.Persist()
+			tropeIds, _ = reopened.GetTropeIDs()
+			reopened.Close()
+
+			backupContents, _ = os.ReadFile(datasetName + ".csv" + dataset_file.BackupSuffix)
+		})
+
+		It("Shouldn't return an error", func() {
+			Expect(errPersist).To(BeNil())
+		})
+
+		It("Should keep the tropes that were already on it", func() {
+			Expect(tropeIds).To(HaveLen(2))
+			Expect(tropeIds).To(HaveKey("ChekhovsGun"))
+			Expect(tropeIds).To(HaveKey("Foreshadowing"))
+		})
+
+		It("Should back up the catalogue before writing it", func() {
+			records, errRead := csv.NewReader(bytes.NewReader(backupContents)).ReadAll()
+			Expect(errRead).To(BeNil())
+			Expect(records).To(HaveLen(2))
+			Expect(records[1][0]).To(Equal("ChekhovsGun"))
+		})
+	})
+
+	Context("Close a catalogue after persisting a trope", func() {
+		var catalogue *catalogue_dataset.JSONCatalogue
+		var errClose error
+
+		BeforeEach(func() {
+			catalogue, _ = catalogue_dataset.NewJSONCatalogue(datasetName)
+			catalogue.AddEntry(newChekhovsGun())
+			catalogue.Persist()
+		})
+
+		It("Should hold the lock of the catalogue until it's closed", func() {
+			Expect(datasetName + ".json" + dataset_file.LockSuffix).To(BeAnExistingFile())
+
+			errClose = catalogue.Close()
+			Expect(errClose).To(BeNil())
+			Expect(datasetName + ".json" + dataset_file.LockSuffix).To(Not(BeAnExistingFile()))
+		})
+	})
+})
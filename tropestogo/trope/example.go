@@ -0,0 +1,58 @@
+package trope
+
+import "errors"
+
+var (
+	ErrEmptyExample = errors.New("an example must have a text")
+)
+
+// Location is where a bullet was found on a Work page or one of its subpages. It's a value object
+type Location struct {
+	// Folder is the label of the folder that holds the bullet, or empty if it isn't on a folder
+	Folder string
+	// Header is the text of the closest header before the bullet, or empty if there isn't any
+	Header string
+	// SubpageURL is the URL of the page or subpage where the bullet is
+	SubpageURL string
+	// Position is the position of the bullet on its list, starting at 1
+	Position int
+}
+
+// Example is the bullet of a Work page that explains how a trope appears on the Work, so it's a single occurrence of the trope. It's a value object
+type Example struct {
+	// Text is the plain text of the bullet, without its sub-bullets and with the blanks collapsed
+	Text string
+	// HTML is the raw HTML of the bullet, without its sub-bullets
+	HTML string
+	// SubItems are the plain texts of the sub-bullets nested on the bullet, in the same order
+	SubItems []string
+	// Spoilers are the plain texts of the spoiler spans of the bullet and its sub-bullets, in the same order
+	Spoilers []string
+	// Location is where the bullet was found
+	Location Location
+}
+
+// NewExample is a factory that creates a valid Example value object from the text and the raw HTML of a bullet,
+// its sub-bullets, its spoilers and where it was found
+// It returns an ErrEmptyExample error if the text is empty
+func NewExample(text, html string, subItems, spoilers []string, location Location) (Example, error) {
+	if len(text) == 0 {
+		return Example{}, ErrEmptyExample
+	}
+
+	if subItems == nil {
+		subItems = make([]string, 0)
+	}
+
+	if spoilers == nil {
+		spoilers = make([]string, 0)
+	}
+
+	return Example{
+		Text:     text,
+		HTML:     html,
+		SubItems: subItems,
+		Spoilers: spoilers,
+		Location: location,
+	}, nil
+}
@@ -0,0 +1,29 @@
+package trope
+
+// TopLevelIndexes relates every known TropeIndex with the ID of its top-level index page on TvTropes
+var TopLevelIndexes = map[TropeIndex]string{
+	GenreTrope:     "GenreTropes",
+	MediaTrope:     "MediaTropes",
+	NarrativeTrope: "NarrativeTropes",
+	TopicalTrope:   "TopicalTropes",
+}
+
+// IndexMapping relates the ID of every classified trope with the set of top-level indexes it's listed in,
+// whether it's listed directly on them or on any of their sub-indexes
+type IndexMapping map[string]TropeIndexSet
+
+// NewIndexMapping creates an empty IndexMapping
+func NewIndexMapping() IndexMapping {
+	return make(IndexMapping)
+}
+
+// Add classifies the tropeId trope on the index, keeping the indexes it was already classified on
+func (mapping IndexMapping) Add(tropeId string, index TropeIndex) {
+	mapping[tropeId] = mapping[tropeId].Add(index)
+}
+
+// GetIndexes returns the indexes the tropeId trope is listed in, with its primary index first
+// It's empty if the trope isn't classified
+func (mapping IndexMapping) GetIndexes(tropeId string) []TropeIndex {
+	return mapping[tropeId].GetIndexes()
+}
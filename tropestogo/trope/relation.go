@@ -0,0 +1,112 @@
+package trope
+
+import (
+	"errors"
+	"strings"
+)
+
+var (
+	ErrEmptyRelation       = errors.New("a relation must have the URL of the related work")
+	ErrUnknownRelationType = errors.New("unknown relation type")
+
+	// sequelCues are the words of the context of a link that mark the linked work as a sequel or prequel
+	sequelCues = []string{"sequel", "prequel", "followed by", "follow-up", "spin-off", "spinoff"}
+	// adaptationCues are the words of the context of a link that mark the linked work as an adaptation, a remake or the original work
+	adaptationCues = []string{"based on", "adaptation", "adapted", "remake", "remade"}
+)
+
+// RelationType enumerates the kinds of relationships a Work can have with another Work
+type RelationType int64
+
+const (
+	ReferencedRelation RelationType = iota
+	SequelRelation
+	AdaptationRelation
+	FranchiseRelation
+)
+
+// IsValid checks whether a RelationType is known or not
+func (relationType RelationType) IsValid() bool {
+	return relationType >= ReferencedRelation && relationType <= FranchiseRelation
+}
+
+// ToRelationType converts a string to a RelationType
+// It returns an ErrUnknownRelationType if the RelationType isn't recognized
+func ToRelationType(relationTypeString string) (RelationType, error) {
+	for relationType := ReferencedRelation; relationType <= FranchiseRelation; relationType++ {
+		if relationTypeString == relationType.String() {
+			return relationType, nil
+		}
+	}
+
+	return ReferencedRelation, ErrUnknownRelationType
+}
+
+// Implement Stringer interface for comparing string relation types and avoid using literals
+func (relationType RelationType) String() string {
+	switch relationType {
+	case SequelRelation:
+		return "Sequel"
+	case AdaptationRelation:
+		return "Adaptation"
+	case FranchiseRelation:
+		return "Franchise"
+	default:
+		return "Referenced"
+	}
+}
+
+// ClassifyRelation guesses the RelationType of a link to another work from the text around it
+// Links to franchise pages are always FranchiseRelation, and links without any known cue are ReferencedRelation
+func ClassifyRelation(isFranchise bool, context string) RelationType {
+	if isFranchise {
+		return FranchiseRelation
+	}
+
+	lowerContext := strings.ToLower(context)
+	for _, cue := range sequelCues {
+		if strings.Contains(lowerContext, cue) {
+			return SequelRelation
+		}
+	}
+
+	for _, cue := range adaptationCues {
+		if strings.Contains(lowerContext, cue) {
+			return AdaptationRelation
+		}
+	}
+
+	return ReferencedRelation
+}
+
+// Relation is a link from a Work page to the page of another Work, with the text around it. It's a value object
+type Relation struct {
+	// URL is the URL of the page of the related Work
+	URL string
+	// AnchorText is the plain text of the link
+	AnchorText string
+	// Context is the plain text of the sentence where the link is
+	Context string
+	// Type is the kind of relationship between both Works
+	Type RelationType
+}
+
+// NewRelation is a factory that creates a valid Relation value object from the URL of the related work, the text of the link,
+// the sentence where it is and the kind of relationship
+// It returns an ErrEmptyRelation error if the URL is empty or an ErrUnknownRelationType error if the type isn't valid
+func NewRelation(url, anchorText, context string, relationType RelationType) (Relation, error) {
+	if len(url) == 0 {
+		return Relation{}, ErrEmptyRelation
+	}
+
+	if !relationType.IsValid() {
+		return Relation{}, ErrUnknownRelationType
+	}
+
+	return Relation{
+		URL:        url,
+		AnchorText: anchorText,
+		Context:    context,
+		Type:       relationType,
+	}, nil
+}
@@ -0,0 +1,18 @@
+package trope
+
+// RepositoryCatalogue defines an interface for all kinds of repositories of the trope catalogue extracted from TvTropes
+// The interface allows us to implement multiple structs that handle different data formats like CSV or JSON
+// sharing common methods
+type RepositoryCatalogue interface {
+	// AddEntry adds a new CatalogueEntry (a trope with its information) to the catalogue
+	AddEntry(CatalogueEntry) error
+
+	// Persist adds all repository CatalogueEntry objects to the proper catalogue dataset
+	Persist() error
+
+	// GetTropeIDs retrieves the ID of every trope persisted on the catalogue dataset
+	GetTropeIDs() (map[string]struct{}, error)
+
+	// Close releases the lock that the catalogue holds on its dataset since its first write, so another process can write it
+	Close() error
+}
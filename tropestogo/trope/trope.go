@@ -1,6 +1,9 @@
 package trope
 
-import "errors"
+import (
+	"errors"
+	"strings"
+)
 
 var (
 	ErrMissingValues = errors.New("one or more fields are missing")
@@ -29,7 +32,7 @@ func (index TropeIndex) IsValid() bool {
 
 // ToTropeIndex converts a string to a MediaType
 func ToTropeIndex(tropeIndexString string) (TropeIndex, error) {
-	for tropeindex := UnknownTropeIndex + 1; tropeindex <= GenreTrope; tropeindex++ {
+	for tropeindex := UnknownTropeIndex + 1; tropeindex <= TopicalTrope; tropeindex++ {
 		if tropeIndexString == tropeindex.String() {
 			return tropeindex, nil
 		}
@@ -54,12 +57,96 @@ func (index TropeIndex) String() string {
 	}
 }
 
+// TropeIndexSet is the set of indexes a trope belongs to, because a trope can be listed on several top-level indexes of TvTropes
+// It's a bit set, so a Trope holding it can still be compared and used as a map key
+// The UnknownTropeIndex is never part of the set, so an empty set is an unclassified trope
+type TropeIndexSet uint8
+
+// NewTropeIndexSet creates a TropeIndexSet with all the indexes, ignoring the UnknownTropeIndex
+// It returns an ErrUnknownIndex error if any of the indexes isn't valid
+func NewTropeIndexSet(indexes ...TropeIndex) (TropeIndexSet, error) {
+	var set TropeIndexSet
+	for _, index := range indexes {
+		if !index.IsValid() {
+			return 0, ErrUnknownIndex
+		}
+
+		set = set.Add(index)
+	}
+
+	return set, nil
+}
+
+// ToTropeIndexSet converts a string of index names separated by "|" to a TropeIndexSet
+// An empty string is an empty set, and it returns an ErrUnknownIndex error if any of the names isn't a known index
+func ToTropeIndexSet(tropeIndexSetString string) (TropeIndexSet, error) {
+	var set TropeIndexSet
+	if tropeIndexSetString == "" {
+		return set, nil
+	}
+
+	for _, indexString := range strings.Split(tropeIndexSetString, "|") {
+		index, errIndex := ToTropeIndex(indexString)
+		if errIndex != nil {
+			return 0, errIndex
+		}
+
+		set = set.Add(index)
+	}
+
+	return set, nil
+}
+
+// Add returns the set with the index added to it
+// The UnknownTropeIndex and invalid indexes aren't added
+func (set TropeIndexSet) Add(index TropeIndex) TropeIndexSet {
+	if index == UnknownTropeIndex || !index.IsValid() {
+		return set
+	}
+
+	return set | 1<<uint(index)
+}
+
+// Has checks if the index belongs to the set
+func (set TropeIndexSet) Has(index TropeIndex) bool {
+	return index != UnknownTropeIndex && index.IsValid() && set&(1<<uint(index)) != 0
+}
+
+// GetIndexes returns the indexes of the set in the order they are declared
+func (set TropeIndexSet) GetIndexes() []TropeIndex {
+	indexes := make([]TropeIndex, 0)
+	for index := UnknownTropeIndex + 1; index <= TopicalTrope; index++ {
+		if set.Has(index) {
+			indexes = append(indexes, index)
+		}
+	}
+
+	return indexes
+}
+
+// GetIndexStrings returns the names of the indexes of the set in the order they are declared
+func (set TropeIndexSet) GetIndexStrings() []string {
+	indexStrings := make([]string, 0)
+	for _, index := range set.GetIndexes() {
+		indexStrings = append(indexStrings, index.String())
+	}
+
+	return indexStrings
+}
+
+// Implement Stringer interface, joining the names of the indexes of the set with "|", or an empty string if it's empty
+func (set TropeIndexSet) String() string {
+	return strings.Join(set.GetIndexStrings(), "|")
+}
+
 // Trope represents a reiterative resource that is collected in TvTropes
 type Trope struct {
 	// title is the trope immutable name and is recognised by it
 	title string
-	// index is the conceptual group of tropes to which this trope belongs
+	// index is the main conceptual group of tropes to which this trope belongs
 	index TropeIndex
+	// indexes are all the conceptual groups of tropes to which this trope belongs, including its main index
+	indexes TropeIndexSet
 	// isMain represents if the trope is on the main Work page
 	isMain bool
 	// subpage refers to the subpage within a Work this trope belongs
@@ -67,19 +154,22 @@ type Trope struct {
 }
 
 // NewTrope is a factory that creates a valid Trope value object by receiving its name and the index to which it belongs
-// It checks if the index is valid and returns an ErrUnknownIndex if it's not
-func NewTrope(title string, index TropeIndex, subpage string) (Trope, error) {
+// If the trope belongs to several indexes, the rest of them are passed as otherIndexes
+// It checks if the indexes are valid and returns an ErrUnknownIndex if they are not
+func NewTrope(title string, index TropeIndex, subpage string, otherIndexes ...TropeIndex) (Trope, error) {
 	if len(title) == 0 {
 		return Trope{}, ErrMissingValues
 	}
 
-	if !index.IsValid() {
-		return Trope{}, ErrUnknownIndex
+	indexes, errIndexes := NewTropeIndexSet(append([]TropeIndex{index}, otherIndexes...)...)
+	if errIndexes != nil {
+		return Trope{}, errIndexes
 	}
 
 	return Trope{
 		title:   title,
 		index:   index,
+		indexes: indexes,
 		isMain:  subpage == "",
 		subpage: subpage,
 	}, nil
@@ -95,6 +185,28 @@ func (trope Trope) GetIndex() TropeIndex {
 	return trope.index
 }
 
+// GetIndexes returns all the categories this trope belongs to in narratives, which is empty if it's unclassified
+func (trope Trope) GetIndexes() TropeIndexSet {
+	return trope.indexes
+}
+
+// GetIndexStrings returns the names of all the indexes of the trope with its main index first,
+// so the main index is the first one when they are read back
+func (trope Trope) GetIndexStrings() []string {
+	indexStrings := make([]string, 0)
+	if trope.indexes.Has(trope.index) {
+		indexStrings = append(indexStrings, trope.index.String())
+	}
+
+	for _, index := range trope.indexes.GetIndexes() {
+		if index != trope.index {
+			indexStrings = append(indexStrings, index.String())
+		}
+	}
+
+	return indexStrings
+}
+
 // GetIsMain returns a boolean indicating whether it's a trope on the main Work page
 func (trope Trope) GetIsMain() bool {
 	return trope.isMain
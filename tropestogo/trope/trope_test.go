@@ -51,5 +51,112 @@ var _ = Describe("Trope", func() {
 				Expect(errNoIndex).To(Equal(trope.ErrUnknownIndex))
 			})
 		})
+
+		Context("The Trope belongs to several indexes", func() {
+			var multiIndexTrope trope.Trope
+			var errMultiIndex error
+
+			BeforeEach(func() {
+				multiIndexTrope, errMultiIndex = trope.NewTrope("ChekhovsGun", trope.NarrativeTrope, "", trope.GenreTrope, trope.NarrativeTrope)
+			})
+
+			It("Shouldn't raise an error", func() {
+				Expect(errMultiIndex).To(BeNil())
+			})
+
+			It("Should keep its main index and all of its indexes", func() {
+				Expect(multiIndexTrope.GetIndex()).To(Equal(trope.NarrativeTrope))
+				Expect(multiIndexTrope.GetIndexes().GetIndexes()).To(Equal([]trope.TropeIndex{trope.GenreTrope, trope.NarrativeTrope}))
+				Expect(multiIndexTrope.GetIndexes().String()).To(Equal("GenreTrope|NarrativeTrope"))
+				Expect(multiIndexTrope.GetIndexStrings()).To(Equal([]string{"NarrativeTrope", "GenreTrope"}))
+			})
+		})
+
+		Context("The Trope isn't classified on any index", func() {
+			It("Should have an empty set of indexes", func() {
+				unclassifiedTrope, _ := trope.NewTrope("ChekhovsGun", trope.UnknownTropeIndex, "")
+				Expect(unclassifiedTrope.GetIndexes().GetIndexes()).To(BeEmpty())
+				Expect(unclassifiedTrope.GetIndexes().String()).To(BeEmpty())
+				Expect(unclassifiedTrope.GetIndexStrings()).To(BeEmpty())
+			})
+		})
+	})
+
+	Describe("Create an Example", func() {
+		It("Should keep the text, the HTML, the sub-bullets and the spoilers", func() {
+			example, errExample := trope.NewExample("Chekhov's Gun: The scissors.", "<a>Chekhov's Gun</a>: The scissors.", []string{"Sub-bullet"}, nil, trope.Location{Folder: "Dae-su", Position: 2})
+			Expect(errExample).To(BeNil())
+			Expect(example.Text).To(Equal("Chekhov's Gun: The scissors."))
+			Expect(example.HTML).To(Equal("<a>Chekhov's Gun</a>: The scissors."))
+			Expect(example.SubItems).To(Equal([]string{"Sub-bullet"}))
+			Expect(example.Spoilers).To(BeEmpty())
+		})
+
+		It("Should raise a proper error if it doesn't have a text", func() {
+			_, errExample := trope.NewExample("", "<a></a>", nil, nil, trope.Location{})
+			Expect(errExample).To(Equal(trope.ErrEmptyExample))
+		})
+	})
+
+	Describe("Create a Relation", func() {
+		It("Should keep the URL, the anchor text, the context and the type", func() {
+			relation, errRelation := trope.NewRelation("https://tvtropes.org/pmwiki/pmwiki.php/Film/Oldboy2013", "English-language remake",
+				"An English-language remake was released on November 27, 2013.", trope.AdaptationRelation)
+			Expect(errRelation).To(BeNil())
+			Expect(relation.URL).To(Equal("https://tvtropes.org/pmwiki/pmwiki.php/Film/Oldboy2013"))
+			Expect(relation.AnchorText).To(Equal("English-language remake"))
+			Expect(relation.Type).To(Equal(trope.AdaptationRelation))
+		})
+
+		It("Should raise a proper error if it doesn't have a URL or a valid type", func() {
+			_, errEmpty := trope.NewRelation("", "remake", "", trope.AdaptationRelation)
+			Expect(errEmpty).To(Equal(trope.ErrEmptyRelation))
+
+			_, errType := trope.NewRelation("https://tvtropes.org/pmwiki/pmwiki.php/Film/Oldboy2013", "remake", "", trope.RelationType(42))
+			Expect(errType).To(Equal(trope.ErrUnknownRelationType))
+		})
+
+		It("Should classify the relation from its context", func() {
+			Expect(trope.ClassifyRelation(false, "Sequels include Avengers: Age of Ultron (2015).")).To(Equal(trope.SequelRelation))
+			Expect(trope.ClassifyRelation(false, "It's very loosely based on the Japanese manga of the same name.")).To(Equal(trope.AdaptationRelation))
+			Expect(trope.ClassifyRelation(true, "It's the sixth film of the Marvel Cinematic Universe.")).To(Equal(trope.FranchiseRelation))
+			Expect(trope.ClassifyRelation(false, "There's a picture of King Kong (1933).")).To(Equal(trope.ReferencedRelation))
+		})
+
+		It("Should convert the name of every relation type", func() {
+			for _, relationType := range []trope.RelationType{trope.ReferencedRelation, trope.SequelRelation, trope.AdaptationRelation, trope.FranchiseRelation} {
+				convertedType, errConvert := trope.ToRelationType(relationType.String())
+				Expect(errConvert).To(BeNil())
+				Expect(convertedType).To(Equal(relationType))
+			}
+
+			_, errConvert := trope.ToRelationType("Remake")
+			Expect(errConvert).To(Equal(trope.ErrUnknownRelationType))
+		})
+	})
+
+	Describe("Convert strings to trope indexes", func() {
+		It("Should convert the name of every known index", func() {
+			for _, index := range []trope.TropeIndex{trope.GenreTrope, trope.MediaTrope, trope.NarrativeTrope, trope.TopicalTrope} {
+				convertedIndex, errConvert := trope.ToTropeIndex(index.String())
+				Expect(errConvert).To(BeNil())
+				Expect(convertedIndex).To(Equal(index))
+			}
+		})
+
+		It("Should convert a set of indexes back from its string", func() {
+			set, _ := trope.NewTropeIndexSet(trope.TopicalTrope, trope.MediaTrope)
+			convertedSet, errConvert := trope.ToTropeIndexSet(set.String())
+			Expect(errConvert).To(BeNil())
+			Expect(convertedSet).To(Equal(set))
+		})
+
+		It("Should raise a proper error for unknown indexes", func() {
+			_, errIndex := trope.ToTropeIndex("UnknownTropeIndex")
+			Expect(errIndex).To(Equal(trope.ErrUnknownIndex))
+
+			_, errSet := trope.ToTropeIndexSet("GenreTrope|WeirdTrope")
+			Expect(errSet).To(Equal(trope.ErrUnknownIndex))
+		})
 	})
 })
@@ -22,4 +22,27 @@ type Work struct {
 	// SubTropes that belong to any of the SubWikis of the Work. Is a set, which means that all SubTropes are unique
 	// There can't be two Tropes on the same SubWiki, but the same Trope can be in different SubWikis
 	SubTropes map[Trope]struct{}
+	// Examples relates the Tropes and SubTropes of the Work with the bullets that explain how they appear on it
+	// A Trope can have several examples on the same Work, and a Trope without examples isn't on it
+	Examples map[Trope][]Example
+	// Metadata is the information about the Work on the main article besides its tropes
+	Metadata WorkMetadata
+	// Relations are the links from the Work page to the pages of other Works, one for each related Work
+	Relations []Relation
+}
+
+// WorkMetadata is the information about a Work that its page gives before the trope list. It's a value object
+type WorkMetadata struct {
+	// Description are the plain texts of the paragraphs that describe the Work, in the same order
+	Description []string
+	// Image is the URL of the image of the Work page, or empty if it doesn't have one
+	Image string
+	// ImageCaption is the plain text of the caption of the image, without its notes
+	ImageCaption string
+	// Creators are the names of the creators, directors and actors mentioned on the description, without duplicates
+	Creators []string
+	// SeeAlso are the URLs of the other pages of works mentioned on the description, without duplicates
+	SeeAlso []string
+	// Franchises are the names of the franchises the Work belongs to, without duplicates
+	Franchises []string
 }
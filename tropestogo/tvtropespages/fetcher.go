@@ -0,0 +1,36 @@
+package tvtropespages
+
+import (
+	"context"
+	"net/http"
+)
+
+// Fetcher defines an interface for retrieving the contents of web pages
+// It allows the pages to be requested through HTTP, a local test server, a replay source or a cache
+// sharing a common method, which the service/fetcher package implements
+type Fetcher interface {
+	// Fetch requests the pageUrl bound to the ctx context and returns its Response
+	// A response with an error status code isn't an error, so the caller can decide what to do with it
+	Fetch(ctx context.Context, pageUrl string) (*Response, error)
+}
+
+// Response is the retrieved contents of a web page
+type Response struct {
+	// URL is the requested URL
+	URL string
+
+	// StatusCode is the HTTP status code of the response
+	StatusCode int
+
+	// Header holds the response headers
+	Header http.Header
+
+	// Body is the full content of the page
+	Body []byte
+
+	// Attempts is the number of requests that were needed to get this response
+	Attempts int
+
+	// Cached is true if the response has been served from a cache without requesting the page
+	Cached bool
+}
@@ -1,12 +1,15 @@
 package tvtropespages
 
 import (
+	"bytes"
+	"context"
 	"errors"
 	"fmt"
 	"github.com/PuerkitoBio/goquery"
 	"io"
 	"net/http"
 	"net/url"
+	"strconv"
 	"strings"
 )
 
@@ -24,8 +27,7 @@ var (
 	ErrNotFound    = errors.New("couldn't request the URL")
 	ErrForbidden   = errors.New("http request denied, maybe there has been too many requests")
 	ErrParsing     = errors.New("error parsing the web contents")
-
-	httpClient = &http.Client{}
+	ErrNoFetcher   = errors.New("can't request the page without a fetcher")
 )
 
 // PageType represents all the relevant types a TvTropes Page can be, so the scraper can know what it is traversing
@@ -53,11 +55,14 @@ type Page struct {
 
 // NewPage creates a valid Page value-object that represents a generic and immutable TvTropes web page
 // It accepts a pageUrl string and checks if it belongs to TvTropes and extracts the type of the page from it
-// If requestPage argument is true, it makes an HTTP request to the Page URL and parses its content to a Goquery document
+// If requestPage argument is true, it retrieves the Page URL with the pageFetcher and parses its content to a Goquery document
 // (main page, work page, index page, etc.)
+// The request is bound to the ctx context, so it's aborted if the context is cancelled before the response arrives
 // It returns an ErrEmptyUrl error if it's empty or an ErrBadUrl error if it's not properly represented
-// It returns an ErrNotFound if the web page couldn't be retrieved or an ErrForbidden if it's access has been temporarily denied by a 403 error
-func NewPage(pageUrl string, requestPage bool, req *http.Request) (Page, error) {
+// It returns an ErrNotFound if the web page couldn't be retrieved or had a server error,
+// or an ErrForbidden if it's access has been temporarily denied by a 403 or 429 error
+// If the page must be requested but there's no pageFetcher, it returns an ErrNoFetcher error
+func NewPage(ctx context.Context, pageUrl string, requestPage bool, pageFetcher Fetcher) (Page, error) {
 	if pageUrl == "" {
 		return Page{}, ErrEmptyUrl
 	}
@@ -69,17 +74,21 @@ func NewPage(pageUrl string, requestPage bool, req *http.Request) (Page, error)
 
 	var doc *goquery.Document = nil
 	if requestPage {
-		httpResponse, errRequest := doRequest(req)
+		response, errRequest := doRequest(ctx, pageUrl, pageFetcher)
 		if errRequest != nil {
 			return Page{}, errRequest
 		}
 
-		if httpResponse.StatusCode == 403 || httpResponse.StatusCode == 429 {
+		if response.StatusCode == http.StatusForbidden || response.StatusCode == http.StatusTooManyRequests {
 			return Page{}, fmt.Errorf("%w: "+pageUrl, ErrForbidden)
 		}
 
+		if response.StatusCode >= http.StatusInternalServerError {
+			return Page{}, fmt.Errorf("%w: "+pageUrl+" answered with status "+strconv.Itoa(response.StatusCode), ErrNotFound)
+		}
+
 		var errParseDocument error
-		doc, errParseDocument = parsePageDocument(httpResponse.Body)
+		doc, errParseDocument = parsePageDocument(bytes.NewReader(response.Body))
 		if errParseDocument != nil {
 			return Page{}, errParseDocument
 		}
@@ -96,7 +105,7 @@ func NewPage(pageUrl string, requestPage bool, req *http.Request) (Page, error)
 
 // NewPageWithDocument creates a valid Page value-object with a custom document that represents a generic and immutable TvTropes web page
 func NewPageWithDocument(pageUrl string, doc *goquery.Document) (Page, error) {
-	page, errPage := NewPage(pageUrl, false, nil)
+	page, errPage := NewPage(context.Background(), pageUrl, false, nil)
 	if errPage != nil {
 		return Page{}, errPage
 	}
@@ -121,15 +130,20 @@ func parseTvTropesUrl(pageUrl string) (*url.URL, error) {
 	return newUrl, nil
 }
 
-// doRequest tries to make an HTTP request and returns its contents
-// If the URL isn't available for retrieving its content will return an ErrNotFound or an ErrForbidden error
-func doRequest(request *http.Request) (*http.Response, error) {
-	httpResponse, errDoRequest := httpClient.Do(request)
-	if errDoRequest != nil {
-		return nil, fmt.Errorf("%w: "+request.URL.String(), ErrNotFound)
+// doRequest tries to retrieve the pageUrl with the pageFetcher bound to the ctx context and returns its contents
+// If the URL isn't available for retrieving its content will return an ErrNotFound error, or an ErrNoFetcher if there's no pageFetcher
+// If the context is cancelled, the returned error also wraps the context error
+func doRequest(ctx context.Context, pageUrl string, pageFetcher Fetcher) (*Response, error) {
+	if pageFetcher == nil {
+		return nil, fmt.Errorf("%w: "+pageUrl, ErrNoFetcher)
+	}
+
+	response, errFetch := pageFetcher.Fetch(ctx, pageUrl)
+	if errFetch != nil {
+		return nil, fmt.Errorf("%w: "+pageUrl+"\n%w", ErrNotFound, errFetch)
 	}
 
-	return httpResponse, nil
+	return response, nil
 }
 
 // parsePageDocument accepts a reader object containing the contents of a web page and returns a goquery Document with them
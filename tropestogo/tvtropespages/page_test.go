@@ -1,6 +1,7 @@
 package tvtropespages_test
 
 import (
+	"context"
 	"errors"
 	tropestogo "github.com/jlgallego99/TropesToGo/tvtropespages"
 	. "github.com/onsi/ginkgo/v2"
@@ -20,7 +21,7 @@ var _ = Describe("Page", func() {
 
 	Context("Create a TvTropes Page object of a Film page", func() {
 		BeforeEach(func() {
-			validPage, errValidPage = tropestogo.NewPage(oldboyUrl, false, nil)
+			validPage, errValidPage = tropestogo.NewPage(context.Background(), oldboyUrl, false, nil)
 		})
 
 		It("Shouldn't return an error", func() {
@@ -40,7 +41,7 @@ var _ = Describe("Page", func() {
 
 	Context("Create a TvTropes Page object of a Trope page", func() {
 		BeforeEach(func() {
-			validPage, errValidPage = tropestogo.NewPage(tropeUrl, false, nil)
+			validPage, errValidPage = tropestogo.NewPage(context.Background(), tropeUrl, false, nil)
 		})
 
 		It("Shouldn't return an error", func() {
@@ -60,7 +61,7 @@ var _ = Describe("Page", func() {
 
 	Context("Create a TvTropes Page object of a Index page", func() {
 		BeforeEach(func() {
-			validPage, errValidPage = tropestogo.NewPage(indexUrl, false, nil)
+			validPage, errValidPage = tropestogo.NewPage(context.Background(), indexUrl, false, nil)
 		})
 
 		It("Shouldn't return an error", func() {
@@ -80,7 +81,7 @@ var _ = Describe("Page", func() {
 
 	Context("Create a Page object of a web that isn't TvTropes", func() {
 		BeforeEach(func() {
-			invalidPage, errInvalidPage = tropestogo.NewPage(googleUrl, false, nil)
+			invalidPage, errInvalidPage = tropestogo.NewPage(context.Background(), googleUrl, false, nil)
 		})
 
 		It("Should return an error", func() {
@@ -95,7 +96,7 @@ var _ = Describe("Page", func() {
 
 	Context("Create a Page with an empty URL", func() {
 		BeforeEach(func() {
-			nullPage, errNullPage = tropestogo.NewPage("", false, nil)
+			nullPage, errNullPage = tropestogo.NewPage(context.Background(), "", false, nil)
 		})
 
 		It("Should return an error", func() {
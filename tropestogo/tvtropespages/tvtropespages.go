@@ -1,11 +1,10 @@
 package tvtropespages
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"github.com/rs/zerolog/log"
-	"math/rand"
-	"net/http"
 	"strings"
 	"time"
 )
@@ -13,12 +12,8 @@ import (
 var (
 	ErrDuplicatedPage = errors.New("the page already exists")
 	ErrAddSubpages    = errors.New("can't add subpages to a page that hasn't been added")
-	seededRand        = rand.New(rand.NewSource(time.Now().UnixNano()))
 )
 
-const minWaitingSeconds = 0
-const maxWaitingSeconds = 3
-
 // TvTropesPages is an entity that manages all relevant pages in TvTropes for its extraction
 // Each Page has a last updated date, for checking future TvTropes updates on its Pages
 // and a list of its subpages, each with their own last updated time
@@ -45,14 +40,14 @@ func NewTvTropesPages() *TvTropesPages {
 
 // AddTvTropesPage creates a valid TvTropes Page with no SubPages from a string pageUrl and adds it to the internal structure of all pages
 // except if the page has already been added before, then it will return an ErrDuplicatedPage error
-// It receives a request object with specific headers for unnoticed crawling
-// If the requestPages argument is true, it makes an http request to the page with a random waiting time between requests
+// If the requestPages argument is true, it retrieves the page with the pageFetcher
 // If successful, returns the created Page for its use
 // If the url is empty or has an invalid format, it will return either an ErrEmptyUrl or ErrBadUrl error
 // If the url does not belong to a TvTropes page, it will return an ErrNotTvTropes error
 // If TvTropes denies access because of too many requests, it will not create the Page and return an ErrForbidden error for the crawler to manage
-func (tvtropespages *TvTropesPages) AddTvTropesPage(pageUrl string, requestPages bool, request *http.Request) (Page, error) {
-	newPage, errNewPage := NewPage(pageUrl, requestPages, request)
+// If the ctx context is cancelled, the request is aborted and the context error is returned
+func (tvtropespages *TvTropesPages) AddTvTropesPage(ctx context.Context, pageUrl string, requestPages bool, pageFetcher Fetcher) (Page, error) {
+	newPage, errNewPage := NewPage(ctx, pageUrl, requestPages, pageFetcher)
 	if errNewPage != nil {
 		return Page{}, errNewPage
 	}
@@ -71,32 +66,27 @@ func (tvtropespages *TvTropesPages) AddTvTropesPage(pageUrl string, requestPages
 }
 
 // AddSubpages searches for an existing Page which has the same URL as the pageUrl arguments and adds all the subpageUrls strings
-// If the requestPages argument is true, it makes http requests to all pages with a random waiting time between requests
+// If the requestPages argument is true, it retrieves all pages one after the other with the pageFetcher
+// The waiting time between requests is a crawling policy, so it must be managed by the caller
 // If the url is empty or has an invalid format, it will return either an ErrEmptyUrl or ErrBadUrl error
 // If the url does not belong to a TvTropes page, it will return an ErrNotTvTropes error
 // If TvTropes denies access because of too many requests, it will not create the Page and return an ErrForbidden error for the crawler to manage
-func (tvtropespages *TvTropesPages) AddSubpages(pageUrl string, subpageUrls []string, requestPages bool, requests []*http.Request) error {
+// If the ctx context is cancelled, it stops requesting subpages, doesn't add any of them and returns the context error
+func (tvtropespages *TvTropesPages) AddSubpages(ctx context.Context, pageUrl string, subpageUrls []string, requestPages bool, pageFetcher Fetcher) error {
 	subPages := make(map[Page]time.Time, 0)
-	for i, subpageUrl := range subpageUrls {
-		log.Info().Str("mainPage", pageUrl).Msg("Making HTTP request: " + subpageUrl)
-
-		var req *http.Request = nil
-		if requestPages {
-			req = requests[i]
+	for _, subpageUrl := range subpageUrls {
+		if errCtx := ctx.Err(); errCtx != nil {
+			return errCtx
 		}
 
-		newSubpage, errSubpage := NewPage(subpageUrl, requestPages, req)
+		log.Info().Str("mainPage", pageUrl).Msg("Making HTTP request: " + subpageUrl)
+
+		newSubpage, errSubpage := NewPage(ctx, subpageUrl, requestPages, pageFetcher)
 		if errSubpage != nil {
 			return errSubpage
 		}
 
 		subPages[newSubpage] = time.Time{}
-
-		// Wait random time between HTTP requests
-		if requestPages {
-			waitingFactor := seededRand.Intn(maxWaitingSeconds-minWaitingSeconds) + minWaitingSeconds
-			time.Sleep(time.Second * time.Duration(waitingFactor))
-		}
 	}
 
 	found := false
@@ -1,6 +1,7 @@
 package tvtropespages_test
 
 import (
+	"context"
 	"errors"
 
 	tvtropespages2 "github.com/jlgallego99/TropesToGo/tvtropespages"
@@ -36,8 +37,8 @@ var _ = Describe("Tvtropespages", func() {
 		var mainPage tvtropespages2.Page
 
 		BeforeEach(func() {
-			mainPage, errAddValid = tvtropespages.AddTvTropesPage(oldboyUrl, false, nil)
-			errAddSubpage = tvtropespages.AddSubpages(oldboyUrl, oldboySuburls, false, nil)
+			mainPage, errAddValid = tvtropespages.AddTvTropesPage(context.Background(), oldboyUrl, false, nil)
+			errAddSubpage = tvtropespages.AddSubpages(context.Background(), oldboyUrl, oldboySuburls, false, nil)
 		})
 
 		It("Shouldn't return an error", func() {
@@ -74,11 +75,11 @@ var _ = Describe("Tvtropespages", func() {
 		var mainPage2, mainPage3 tvtropespages2.Page
 
 		BeforeEach(func() {
-			mainPage2, errAddValid2 = tvtropespages.AddTvTropesPage(tropeUrl, false, nil)
-			errAddSubpage2 = tvtropespages.AddSubpages(tropeUrl, []string{}, false, nil)
+			mainPage2, errAddValid2 = tvtropespages.AddTvTropesPage(context.Background(), tropeUrl, false, nil)
+			errAddSubpage2 = tvtropespages.AddSubpages(context.Background(), tropeUrl, []string{}, false, nil)
 
-			mainPage3, errAddValid3 = tvtropespages.AddTvTropesPage(indexUrl, false, nil)
-			errAddSubpage3 = tvtropespages.AddSubpages(indexUrl, []string{}, false, nil)
+			mainPage3, errAddValid3 = tvtropespages.AddTvTropesPage(context.Background(), indexUrl, false, nil)
+			errAddSubpage3 = tvtropespages.AddSubpages(context.Background(), indexUrl, []string{}, false, nil)
 		})
 
 		It("Shouldn't return an error", func() {
@@ -106,7 +107,7 @@ var _ = Describe("Tvtropespages", func() {
 		var errAddSubpage error
 
 		BeforeEach(func() {
-			errAddSubpage = tvtropespages.AddSubpages("NotAnUrl", oldboySuburls, false, nil)
+			errAddSubpage = tvtropespages.AddSubpages(context.Background(), "NotAnUrl", oldboySuburls, false, nil)
 		})
 
 		It("Should return an error", func() {
@@ -114,13 +115,35 @@ var _ = Describe("Tvtropespages", func() {
 		})
 	})
 
+	Context("Add subpages with a cancelled context", func() {
+		var errAddSubpage error
+
+		BeforeEach(func() {
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+
+			tvtropespages.AddTvTropesPage(context.Background(), oldboyUrl, false, nil)
+			errAddSubpage = tvtropespages.AddSubpages(ctx, oldboyUrl, oldboySuburls, false, nil)
+		})
+
+		It("Should return the context error", func() {
+			Expect(errors.Is(errAddSubpage, context.Canceled)).To(BeTrue())
+		})
+
+		It("Shouldn't have added any Subpage", func() {
+			for _, subpages := range tvtropespages.Pages {
+				Expect(subpages.Subpages).To(BeEmpty())
+			}
+		})
+	})
+
 	Context("Add a duplicated Page", func() {
 		var errAddDuplicated error
 		var duplicatedPage tvtropespages2.Page
 
 		BeforeEach(func() {
-			duplicatedPage, errAddDuplicated = tvtropespages.AddTvTropesPage(oldboyUrl, false, nil)
-			duplicatedPage, errAddDuplicated = tvtropespages.AddTvTropesPage(oldboyUrl, false, nil)
+			duplicatedPage, errAddDuplicated = tvtropespages.AddTvTropesPage(context.Background(), oldboyUrl, false, nil)
+			duplicatedPage, errAddDuplicated = tvtropespages.AddTvTropesPage(context.Background(), oldboyUrl, false, nil)
 		})
 
 		It("Should return an error", func() {
@@ -141,7 +164,7 @@ var _ = Describe("Tvtropespages", func() {
 		var emptyPage tvtropespages2.Page
 
 		BeforeEach(func() {
-			emptyPage, errAddEmpty = tvtropespages.AddTvTropesPage("", false, nil)
+			emptyPage, errAddEmpty = tvtropespages.AddTvTropesPage(context.Background(), "", false, nil)
 		})
 
 		It("Should return an error", func() {
@@ -162,7 +185,7 @@ var _ = Describe("Tvtropespages", func() {
 		var badPage tvtropespages2.Page
 
 		BeforeEach(func() {
-			badPage, errAddEmpty = tvtropespages.AddTvTropesPage("htp$p%^^^&&***!!!!!", false, nil)
+			badPage, errAddEmpty = tvtropespages.AddTvTropesPage(context.Background(), "htp$p%^^^&&***!!!!!", false, nil)
 		})
 
 		It("Should return an error", func() {